@@ -211,3 +211,27 @@ const (
 	InvoiceStatusPending     = "待开"   // 待开
 	InvoiceStatusSent        = "已发送" // 已发送
 )
+
+const (
+	StatementStatusPending = "pending" // 待付款
+	StatementStatusPaid    = "paid"    // 已付款
+	StatementStatusOverdue = "overdue" // 已逾期
+)
+
+const (
+	CampaignStatusEnabled  = 1 // don't use 0, 0 is the default value!
+	CampaignStatusDisabled = 2 // also don't use 0
+)
+
+const (
+	CampaignTypeTopUpBonus    = "topup_bonus"    // 充值赠送百分比
+	CampaignTypeFirstTopUp    = "first_topup"    // 首充优惠
+	CampaignTypeModelDiscount = "model_discount" // 限时模型/分组折扣
+	CampaignTypeReferral      = "referral"       // 按被邀请人消费返利
+)
+
+const (
+	CallbackDeliveryStatusPending = "pending" // 待投递/等待重试
+	CallbackDeliveryStatusSuccess = "success" // 投递成功
+	CallbackDeliveryStatusFailed  = "failed"  // 重试耗尽
+)
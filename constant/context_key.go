@@ -19,6 +19,9 @@ const (
 	ContextKeyTokenModelLimitEnabled ContextKey = "token_model_limit_enabled"
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
 	ContextKeyTokenCrossGroupRetry   ContextKey = "token_cross_group_retry"
+	ContextKeyTokenCallbackUrl       ContextKey = "token_callback_url"
+	ContextKeyTokenCallbackSecret    ContextKey = "token_callback_secret"
+	ContextKeyTokenHedgeEnabled      ContextKey = "token_hedge_enabled"
 
 	/* channel related keys */
 	ContextKeyChannelId                ContextKey = "channel_id"
@@ -37,22 +40,29 @@ const (
 	ContextKeyChannelIsMultiKey        ContextKey = "channel_is_multi_key"
 	ContextKeyChannelMultiKeyIndex     ContextKey = "channel_multi_key_index"
 	ContextKeyChannelKey               ContextKey = "channel_key"
+	ContextKeyChannelRoutingPolicy     ContextKey = "channel_routing_policy"
 
 	ContextKeyAutoGroup           ContextKey = "auto_group"
 	ContextKeyAutoGroupIndex      ContextKey = "auto_group_index"
 	ContextKeyAutoGroupRetryIndex ContextKey = "auto_group_retry_index"
 
 	/* user related keys */
-	ContextKeyUserId      ContextKey = "id"
-	ContextKeyUserSetting ContextKey = "user_setting"
-	ContextKeyUserQuota   ContextKey = "user_quota"
-	ContextKeyUserStatus  ContextKey = "user_status"
-	ContextKeyUserEmail   ContextKey = "user_email"
-	ContextKeyUserGroup   ContextKey = "user_group"
-	ContextKeyUsingGroup  ContextKey = "group"
-	ContextKeyUserName    ContextKey = "username"
+	ContextKeyUserId          ContextKey = "id"
+	ContextKeyUserSetting     ContextKey = "user_setting"
+	ContextKeyUserQuota       ContextKey = "user_quota"
+	ContextKeyUserStatus      ContextKey = "user_status"
+	ContextKeyUserEmail       ContextKey = "user_email"
+	ContextKeyUserGroup       ContextKey = "user_group"
+	ContextKeyUserCreditLimit ContextKey = "user_credit_limit"
+	ContextKeyUsingGroup      ContextKey = "group"
+	ContextKeyUserName        ContextKey = "username"
 
 	ContextKeyLocalCountTokens ContextKey = "local_count_tokens"
 
 	ContextKeySystemPromptOverride ContextKey = "system_prompt_override"
+
+	// ContextKeyPriceDryRun 计费模拟、渠道测试等不扣费的计价，不占用折扣活动次数
+	ContextKeyPriceDryRun ContextKey = "price_dry_run"
+	// ContextKeyCampaignClaimed 本次请求已占用使用次数的折扣活动
+	ContextKeyCampaignClaimed ContextKey = "campaign_claimed"
 )
@@ -1,12 +1,24 @@
 package constant
 
+import "strings"
+
 type TaskPlatform string
 
 const (
 	TaskPlatformSuno       TaskPlatform = "suno"
 	TaskPlatformMidjourney              = "mj"
+
+	// 音频生成任务（/v1/audio/generations）按上游区分平台
+	TaskPlatformAudioSuno       TaskPlatform = "audio_suno"
+	TaskPlatformAudioMiniMax    TaskPlatform = "audio_minimax"
+	TaskPlatformAudioElevenLabs TaskPlatform = "audio_elevenlabs"
 )
 
+// IsAudio 是否为音频生成任务平台
+func (p TaskPlatform) IsAudio() bool {
+	return strings.HasPrefix(string(p), "audio_")
+}
+
 const (
 	SunoActionMusic  = "MUSIC"
 	SunoActionLyrics = "LYRICS"
@@ -16,6 +28,11 @@ const (
 	TaskActionFirstTailGenerate = "firstTailGenerate"
 	TaskActionReferenceGenerate = "referenceGenerate"
 	TaskActionRemix             = "remixGenerate"
+
+	// 音频生成类型
+	TaskActionSong        = "song"
+	TaskActionSoundEffect = "sound_effect"
+	TaskActionSpeech      = "speech"
 )
 
 var SunoModel2Action = map[string]string{
@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func validateCampaign(campaign *model.Campaign) error {
+	if utf8.RuneCountInString(campaign.Name) == 0 || utf8.RuneCountInString(campaign.Name) > 64 {
+		return errors.New("活动名称长度必须在1-64之间")
+	}
+	campaign.Code = strings.TrimSpace(campaign.Code)
+	if campaign.EndTime != 0 && campaign.EndTime < campaign.StartTime {
+		return errors.New("结束时间不能早于开始时间")
+	}
+	if campaign.MaxUses < 0 || campaign.PerUserLimit < 0 {
+		return errors.New("使用次数上限不能为负数")
+	}
+	switch campaign.Type {
+	case common.CampaignTypeTopUpBonus, common.CampaignTypeFirstTopUp:
+		if campaign.BonusPercent <= 0 {
+			return errors.New("赠送百分比必须大于0")
+		}
+	case common.CampaignTypeModelDiscount:
+		if campaign.Discount <= 0 || campaign.Discount >= 1 {
+			return errors.New("折扣必须在0-1之间")
+		}
+		if campaign.Code != "" {
+			return errors.New("模型折扣活动不支持优惠码")
+		}
+	case common.CampaignTypeReferral:
+		if campaign.ReferralPercent <= 0 || campaign.ReferralPercent > 100 {
+			return errors.New("返利百分比必须在0-100之间")
+		}
+		if campaign.Code != "" {
+			return errors.New("邀请返利活动不支持优惠码")
+		}
+	default:
+		return errors.New("不支持的活动类型")
+	}
+	return nil
+}
+
+func GetAllCampaigns(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	campaigns, total, err := model.GetAllCampaigns(c.Query("type"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(campaigns)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetCampaign(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	campaign, err := model.GetCampaignById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, campaign)
+}
+
+func AddCampaign(c *gin.Context) {
+	campaign := model.Campaign{}
+	if err := c.ShouldBindJSON(&campaign); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := validateCampaign(&campaign); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	campaign.Id = 0
+	campaign.UsedCount = 0
+	campaign.RewardQuota = 0
+	if campaign.Status == 0 {
+		campaign.Status = common.CampaignStatusEnabled
+	}
+	if err := campaign.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, campaign)
+}
+
+func UpdateCampaign(c *gin.Context) {
+	campaign := model.Campaign{}
+	if err := c.ShouldBindJSON(&campaign); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if _, err := model.GetCampaignById(campaign.Id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := validateCampaign(&campaign); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := campaign.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, campaign)
+}
+
+func DeleteCampaign(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	campaign, err := model.GetCampaignById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := campaign.Delete(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func GetCampaignUsages(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	usages, total, err := model.GetCampaignUsages(id, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(usages)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetCampaignReport(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	report, err := model.GetCampaignReport(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, report)
+}
+
+type CheckCampaignRequest struct {
+	Code   string  `json:"code"`
+	Amount float64 `json:"amount"`
+}
+
+// CheckCampaign 用户充值前校验优惠码并预览赠送金额
+func CheckCampaign(c *gin.Context) {
+	var req CheckCampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "参数错误")
+		return
+	}
+	campaign, bonus, err := model.FindTopUpCampaign(c.GetInt("id"), req.Code, req.Amount)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if campaign == nil {
+		common.ApiSuccess(c, gin.H{"bonus": 0})
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"campaign_id":   campaign.Id,
+		"name":          campaign.Name,
+		"description":   campaign.Description,
+		"type":          campaign.Type,
+		"bonus_percent": campaign.BonusPercent,
+		"bonus":         bonus,
+	})
+}
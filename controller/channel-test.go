@@ -38,9 +38,19 @@ type testResult struct {
 	context     *gin.Context
 	localErr    error
 	newAPIError *types.NewAPIError
+	// 以下字段仅在请求成功时填充
+	respBody      []byte
+	usage         *dto.Usage
+	upstreamModel string
+	isStream      bool
 }
 
 func testChannel(channel *model.Channel, testModel string, endpointType string) testResult {
+	return runChannelTest(channel, testModel, endpointType, nil)
+}
+
+// runChannelTest 向渠道发送测试请求，buildRequest 为空时使用 buildTestRequest 构造默认请求
+func runChannelTest(channel *model.Channel, testModel string, endpointType string, buildRequest func(testModel string) dto.Request) testResult {
 	tik := time.Now()
 	var unsupportedTestChannelTypes = []int{
 		constant.ChannelTypeMidjourney,
@@ -50,6 +60,7 @@ func testChannel(channel *model.Channel, testModel string, endpointType string)
 		constant.ChannelTypeJimeng,
 		constant.ChannelTypeDoubaoVideo,
 		constant.ChannelTypeVidu,
+		constant.ChannelTypeElevenLabs,
 	}
 	if lo.Contains(unsupportedTestChannelTypes, channel.Type) {
 		channelTypeName := constant.GetChannelTypeName(channel.Type)
@@ -59,6 +70,8 @@ func testChannel(channel *model.Channel, testModel string, endpointType string)
 	}
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
+	// 渠道测试不向用户计费，不占用折扣活动次数
+	common.SetContextKey(c, constant.ContextKeyPriceDryRun, true)
 
 	testModel = strings.TrimSpace(testModel)
 	if testModel == "" {
@@ -176,7 +189,12 @@ func testChannel(channel *model.Channel, testModel string, endpointType string)
 		}
 	}
 
-	request := buildTestRequest(testModel, endpointType)
+	var request dto.Request
+	if buildRequest != nil {
+		request = buildRequest(testModel)
+	} else {
+		request = buildTestRequest(testModel, endpointType)
+	}
 
 	info, err := relaycommon.GenRelayInfo(c, relayFormat, request, nil)
 
@@ -380,12 +398,17 @@ func testChannel(channel *model.Channel, testModel string, endpointType string)
 		IsStream:         info.IsStream,
 		Group:            info.UsingGroup,
 		Other:            other,
+		NotBilled:        true,
 	})
 	common.SysLog(fmt.Sprintf("testing channel #%d, response: \n%s", channel.Id, string(respBody)))
 	return testResult{
-		context:     c,
-		localErr:    nil,
-		newAPIError: nil,
+		context:       c,
+		localErr:      nil,
+		newAPIError:   nil,
+		respBody:      respBody,
+		usage:         usage,
+		upstreamModel: info.UpstreamModelName,
+		isStream:      info.IsStream,
 	}
 }
 
@@ -569,6 +592,7 @@ func testAllChannels(notify bool) error {
 			newAPIError := result.newAPIError
 			// request error disables the channel
 			if newAPIError != nil {
+				applyChannelErrorRules(result.context, newAPIError)
 				shouldBanChannel = service.ShouldDisableChannel(channel.Type, result.newAPIError)
 			}
 
@@ -591,10 +615,24 @@ func testAllChannels(notify bool) error {
 				service.EnableChannel(channel.Id, common.GetContextKeyString(result.context, constant.ContextKeyChannelKey), channel.Name)
 			}
 
+			// 基础测试通过的已启用渠道继续执行金丝雀测试
+			if isChannelEnabled && newAPIError == nil {
+				_, failure, err := runChannelCanaries(channel)
+				if err != nil {
+					common.SysLog(fmt.Sprintf("failed to run channel canaries: channel_id=%d, error=%v", channel.Id, err))
+				} else if failure != nil && common.AutomaticDisableChannelEnabled && channel.GetAutoBan() {
+					service.DisableChannel(*types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, failure.usingKey, channel.GetAutoBan()), failure.reason)
+				}
+			}
+
 			channel.UpdateResponseTime(milliseconds)
 			time.Sleep(common.RequestInterval)
 		}
 
+		if err := model.DeleteExpiredChannelTestRuns(); err != nil {
+			common.SysLog("failed to delete expired channel test runs: " + err.Error())
+		}
+
 		if notify {
 			service.NotifyRootUser(dto.NotifyTypeChannelTest, "通道测试完成", "所有通道测试已完成")
 		}
@@ -12,6 +12,7 @@ import (
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 
 	"github.com/gin-gonic/gin"
 )
@@ -60,6 +61,7 @@ func clearChannelInfo(channel *model.Channel) {
 		channel.ChannelInfo.MultiKeyDisabledReason = nil
 		channel.ChannelInfo.MultiKeyDisabledTime = nil
 	}
+	channel.ChannelInfo.FillCooldownRemaining(common.GetTimestamp())
 }
 
 func GetAllChannels(c *gin.Context) {
@@ -136,8 +138,10 @@ func GetAllChannels(c *gin.Context) {
 		}
 	}
 
+	routingStats := make(map[int]model.ChannelRoutingStats, len(channelData))
 	for _, datum := range channelData {
 		clearChannelInfo(datum)
+		routingStats[datum.Id] = model.GetChannelRoutingStats(datum)
 	}
 
 	countQuery := model.DB.Model(&model.Channel{})
@@ -161,6 +165,9 @@ func GetAllChannels(c *gin.Context) {
 		"page":        pageInfo.GetPage(),
 		"page_size":   pageInfo.GetPageSize(),
 		"type_counts": typeCounts,
+		// 路由策略配置及各渠道的实时路由数据（在途请求、首字延迟中位数、成本倍率）
+		"routing_setting": operation_setting.GetRoutingSetting(),
+		"routing_stats":   routingStats,
 	})
 	return
 }
@@ -672,6 +679,9 @@ func AddChannel(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	for _, channel := range channels {
+		recordChannelVersions(c, model.ChannelVersionActionCreate, channel.Id)
+	}
 	service.ResetProxyClientCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -808,11 +818,14 @@ func EditTagChannels(c *gin.Context) {
 		}
 		channelTag.HeaderOverride = common.GetPointer[string](trimmed)
 	}
+	tagChannelIds := getTagChannelIds(channelTag.Tag)
+	ensureChannelBaselines(tagChannelIds...)
 	err = model.EditChannelByTag(channelTag.Tag, channelTag.NewTag, channelTag.ModelMapping, channelTag.Models, channelTag.Groups, channelTag.Priority, channelTag.Weight, channelTag.ParamOverride, channelTag.HeaderOverride)
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	recordChannelVersions(c, model.ChannelVersionActionTagEdit, tagChannelIds...)
 	model.InitChannelCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -952,11 +965,13 @@ func UpdateChannel(c *gin.Context) {
 			// 覆盖模式：直接使用新密钥（默认行为，不需要特殊处理）
 		}
 	}
+	ensureChannelBaselines(channel.Id)
 	err = channel.Update()
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	recordChannelVersions(c, model.ChannelVersionActionUpdate, channel.Id)
 	model.InitChannelCache()
 	service.ResetProxyClientCache()
 	channel.Key = ""
@@ -1060,11 +1075,13 @@ func BatchSetChannelTag(c *gin.Context) {
 		})
 		return
 	}
+	ensureChannelBaselines(channelBatch.Ids...)
 	err = model.BatchSetChannelTag(channelBatch.Ids, channelBatch.Tag)
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	recordChannelVersions(c, model.ChannelVersionActionBatchTag, channelBatch.Ids...)
 	model.InitChannelCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -1156,10 +1173,13 @@ func CopyChannel(c *gin.Context) {
 	}
 
 	// insert
-	if err := model.BatchInsertChannels([]model.Channel{clone}); err != nil {
+	clones := []model.Channel{clone}
+	if err := model.BatchInsertChannels(clones); err != nil {
 		c.JSON(http.StatusOK, gin.H{"success": false, "message": err.Error()})
 		return
 	}
+	clone.Id = clones[0].Id
+	recordChannelVersions(c, model.ChannelVersionActionCreate, clone.Id)
 	model.InitChannelCache()
 	// success
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "", "data": gin.H{"id": clone.Id}})
@@ -1224,11 +1244,13 @@ func SetFallbackChannel(c *gin.Context) {
 		return
 	}
 
+	ensureChannelBaselines(channel.Id)
 	channel.FallbackChannelId = req.FallbackChannelId
 	if err := channel.Update(); err != nil {
 		c.JSON(http.StatusOK, gin.H{"success": false, "message": err.Error()})
 		return
 	}
+	recordChannelVersions(c, model.ChannelVersionActionFallback, channel.Id)
 
 	model.InitChannelCache()
 	c.JSON(http.StatusOK, gin.H{
@@ -1254,11 +1276,13 @@ func ClearFallbackChannel(c *gin.Context) {
 		return
 	}
 
+	ensureChannelBaselines(channel.Id)
 	channel.FallbackChannelId = nil
 	if err := channel.Update(); err != nil {
 		c.JSON(http.StatusOK, gin.H{"success": false, "message": err.Error()})
 		return
 	}
+	recordChannelVersions(c, model.ChannelVersionActionFallback, channel.Id)
 
 	model.InitChannelCache()
 	c.JSON(http.StatusOK, gin.H{
@@ -1291,11 +1315,12 @@ type MultiKeyStatusResponse struct {
 }
 
 type KeyStatus struct {
-	Index        int    `json:"index"`
-	Status       int    `json:"status"` // 1: enabled, 2: disabled
-	DisabledTime int64  `json:"disabled_time,omitempty"`
-	Reason       string `json:"reason,omitempty"`
-	KeyPreview   string `json:"key_preview"` // first 10 chars of key for identification
+	Index             int    `json:"index"`
+	Status            int    `json:"status"` // 1: enabled, 2: disabled
+	DisabledTime      int64  `json:"disabled_time,omitempty"`
+	Reason            string `json:"reason,omitempty"`
+	KeyPreview        string `json:"key_preview"`                  // first 10 chars of key for identification
+	CooldownRemaining int64  `json:"cooldown_remaining,omitempty"` // 临时冷却剩余秒数，到期自动启用
 }
 
 // ManageMultiKeys handles multi-key management operations
@@ -1347,6 +1372,7 @@ func ManageMultiKeys(c *gin.Context) {
 
 		// Build all key status data first
 		var allKeyStatusList []KeyStatus
+		channel.ChannelInfo.FillCooldownRemaining(common.GetTimestamp())
 		for i, key := range keys {
 			status := 1 // default enabled
 			var disabledTime int64
@@ -1384,11 +1410,12 @@ func ManageMultiKeys(c *gin.Context) {
 			}
 
 			allKeyStatusList = append(allKeyStatusList, KeyStatus{
-				Index:        i,
-				Status:       status,
-				DisabledTime: disabledTime,
-				Reason:       reason,
-				KeyPreview:   keyPreview,
+				Index:             i,
+				Status:            status,
+				DisabledTime:      disabledTime,
+				Reason:            reason,
+				KeyPreview:        keyPreview,
+				CooldownRemaining: channel.ChannelInfo.MultiKeyCooldownRemaining[i],
 			})
 		}
 
@@ -1472,6 +1499,9 @@ func ManageMultiKeys(c *gin.Context) {
 		}
 
 		channel.ChannelInfo.MultiKeyStatusList[keyIndex] = 2 // disabled
+		// 手动禁用后不再按冷却自动启用
+		delete(channel.ChannelInfo.MultiKeyCooldownUntil, keyIndex)
+		delete(channel.ChannelInfo.MultiKeyCooldownBackoff, keyIndex)
 
 		err = channel.Update()
 		if err != nil {
@@ -1514,6 +1544,8 @@ func ManageMultiKeys(c *gin.Context) {
 		if channel.ChannelInfo.MultiKeyDisabledReason != nil {
 			delete(channel.ChannelInfo.MultiKeyDisabledReason, keyIndex)
 		}
+		delete(channel.ChannelInfo.MultiKeyCooldownUntil, keyIndex)
+		delete(channel.ChannelInfo.MultiKeyCooldownBackoff, keyIndex)
 
 		err = channel.Update()
 		if err != nil {
@@ -1538,6 +1570,8 @@ func ManageMultiKeys(c *gin.Context) {
 		channel.ChannelInfo.MultiKeyStatusList = make(map[int]int)
 		channel.ChannelInfo.MultiKeyDisabledTime = make(map[int]int64)
 		channel.ChannelInfo.MultiKeyDisabledReason = make(map[int]string)
+		channel.ChannelInfo.MultiKeyCooldownUntil = nil
+		channel.ChannelInfo.MultiKeyCooldownBackoff = nil
 
 		err = channel.Update()
 		if err != nil {
@@ -1622,6 +1656,8 @@ func ManageMultiKeys(c *gin.Context) {
 		var newStatusList = make(map[int]int)
 		var newDisabledTime = make(map[int]int64)
 		var newDisabledReason = make(map[int]string)
+		var newCooldownUntil = make(map[int]int64)
+		var newCooldownBackoff = make(map[int]model.ChannelCooldownBackoff)
 
 		newIndex := 0
 		for i, key := range keys {
@@ -1648,6 +1684,12 @@ func ManageMultiKeys(c *gin.Context) {
 					newDisabledReason[newIndex] = r
 				}
 			}
+			if until, exists := channel.ChannelInfo.MultiKeyCooldownUntil[i]; exists {
+				newCooldownUntil[newIndex] = until
+			}
+			if backoff, exists := channel.ChannelInfo.MultiKeyCooldownBackoff[i]; exists {
+				newCooldownBackoff[newIndex] = backoff
+			}
 			newIndex++
 		}
 
@@ -1665,6 +1707,8 @@ func ManageMultiKeys(c *gin.Context) {
 		channel.ChannelInfo.MultiKeyStatusList = newStatusList
 		channel.ChannelInfo.MultiKeyDisabledTime = newDisabledTime
 		channel.ChannelInfo.MultiKeyDisabledReason = newDisabledReason
+		channel.ChannelInfo.MultiKeyCooldownUntil = newCooldownUntil
+		channel.ChannelInfo.MultiKeyCooldownBackoff = newCooldownBackoff
 
 		err = channel.Update()
 		if err != nil {
@@ -1733,6 +1777,9 @@ func ManageMultiKeys(c *gin.Context) {
 		channel.ChannelInfo.MultiKeyStatusList = newStatusList
 		channel.ChannelInfo.MultiKeyDisabledTime = newDisabledTime
 		channel.ChannelInfo.MultiKeyDisabledReason = newDisabledReason
+		// 冷却中的密钥均为自动禁用状态，已被删除
+		channel.ChannelInfo.MultiKeyCooldownUntil = nil
+		channel.ChannelInfo.MultiKeyCooldownBackoff = nil
 
 		err = channel.Update()
 		if err != nil {
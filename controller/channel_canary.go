@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// canaryDefaultMaxTokens 用例未设置 max_tokens 时的上限，避免测试消耗过多额度
+const canaryDefaultMaxTokens = 1024
+
+// channelCanaryFailure 触发自动禁用的断言失败
+type channelCanaryFailure struct {
+	reason   string
+	usingKey string
+}
+
+func buildCanaryRequest(testModel string, testCase *model.ChannelTestCase) dto.Request {
+	messages := make([]dto.Message, 0, 2)
+	if testCase.System != "" {
+		messages = append(messages, dto.Message{Role: "system", Content: testCase.System})
+	}
+	messages = append(messages, dto.Message{Role: "user", Content: testCase.Prompt})
+	maxTokens := testCase.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = canaryDefaultMaxTokens
+	}
+	request := &dto.GeneralOpenAIRequest{
+		Model:     testModel,
+		Stream:    testCase.Stream,
+		Messages:  messages,
+		MaxTokens: maxTokens,
+		Tools:     testCase.Tools,
+	}
+	if testCase.Stream {
+		request.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+	}
+	return request
+}
+
+// runChannelCanaries 对渠道执行所有适用的金丝雀测试套件并保存结果；
+// 开启了自动禁用的套件出现断言失败时返回首个失败
+func runChannelCanaries(channel *model.Channel) ([]*model.ChannelTestRun, *channelCanaryFailure, error) {
+	targets, err := model.GetChannelTestSuiteTargets(channel)
+	if err != nil {
+		return nil, nil, err
+	}
+	runs := make([]*model.ChannelTestRun, 0)
+	var failure *channelCanaryFailure
+	for _, target := range targets {
+		for i := range target.Suite.Cases {
+			testCase := &target.Suite.Cases[i]
+			tik := time.Now()
+			result := runChannelTest(channel, target.Model, "", func(testModel string) dto.Request {
+				return buildCanaryRequest(testModel, testCase)
+			})
+			latencyMs := time.Since(tik).Milliseconds()
+			run := &model.ChannelTestRun{
+				ChannelId: channel.Id,
+				SuiteId:   target.Suite.Id,
+				SuiteName: target.Suite.Name,
+				CaseName:  testCase.Name,
+				Model:     target.Model,
+				LatencyMs: latencyMs,
+				CreatedAt: common.GetTimestamp(),
+			}
+			var failures []string
+			if result.localErr != nil {
+				failures = []string{"请求失败：" + result.localErr.Error()}
+			} else {
+				run.CompletionTokens = result.usage.CompletionTokens
+				resp, err := service.ParseCanaryResponse(result.respBody, result.isStream)
+				if err != nil {
+					failures = []string{err.Error()}
+				} else {
+					failures = service.CheckCanaryAssertions(testCase, resp, result.isStream, result.upstreamModel, run.CompletionTokens, latencyMs)
+				}
+			}
+			run.Passed = len(failures) == 0
+			run.Failures = strings.Join(failures, "\n")
+			runs = append(runs, run)
+			if !run.Passed && target.Suite.AutoDisable && failure == nil {
+				failure = &channelCanaryFailure{
+					reason: fmt.Sprintf("金丝雀测试「%s / %s」未通过：%s", target.Suite.Name, testCase.Name, strings.Join(failures, "；")),
+				}
+				if result.context != nil {
+					failure.usingKey = common.GetContextKeyString(result.context, constant.ContextKeyChannelKey)
+				}
+			}
+			time.Sleep(common.RequestInterval)
+		}
+	}
+	if err := model.RecordChannelTestRuns(runs); err != nil {
+		common.SysLog(fmt.Sprintf("failed to record channel test runs: channel_id=%d, error=%v", channel.Id, err))
+	}
+	return runs, failure, nil
+}
+
+func GetChannelTestSuites(c *gin.Context) {
+	suites, err := model.GetAllChannelTestSuites()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, suites)
+}
+
+func AddChannelTestSuite(c *gin.Context) {
+	suite := model.ChannelTestSuite{}
+	if err := c.ShouldBindJSON(&suite); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := suite.Validate(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	suite.Id = 0
+	if err := suite.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, &suite)
+}
+
+func UpdateChannelTestSuite(c *gin.Context) {
+	suite := model.ChannelTestSuite{}
+	if err := c.ShouldBindJSON(&suite); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if suite.Id == 0 {
+		common.ApiErrorMsg(c, "缺少套件 ID")
+		return
+	}
+	if err := suite.Validate(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if _, err := model.GetChannelTestSuiteById(suite.Id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := suite.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, &suite)
+}
+
+func DeleteChannelTestSuite(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.DeleteChannelTestSuiteById(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// RunChannelCanary 立即对渠道执行金丝雀测试，手动执行时不会自动禁用渠道
+func RunChannelCanary(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	channel, err := model.GetChannelById(channelId, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	runs, _, err := runChannelCanaries(channel)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if len(runs) == 0 {
+		common.ApiErrorMsg(c, "没有适用于该渠道模型的测试套件")
+		return
+	}
+	common.ApiSuccess(c, runs)
+}
+
+// GetChannelTestRuns 渠道的金丝雀测试历史：?suite_id= 可按套件过滤
+func GetChannelTestRuns(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	suiteId, _ := strconv.Atoi(c.Query("suite_id"))
+	pageInfo := common.GetPageQuery(c)
+	runs, total, err := model.GetChannelTestRuns(channelId, suiteId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(runs)
+	common.ApiSuccess(c, pageInfo)
+}
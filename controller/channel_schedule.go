@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetChannelSchedules 列出配置了定时计划的渠道，以及未来 hours 小时内（默认 24，最多 168）按计划发生的状态变化
+func GetChannelSchedules(c *gin.Context) {
+	hours, _ := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	channels, err := model.GetScheduledChannels()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	now := time.Now()
+	items := make([]gin.H, 0, len(channels))
+	for _, channel := range channels {
+		items = append(items, gin.H{
+			"id":          channel.Id,
+			"name":        channel.Name,
+			"status":      channel.Status,
+			"priority":    channel.GetPriority(),
+			"weight":      channel.GetWeight(),
+			"schedule":    channel.GetSchedule(),
+			"transitions": model.GetChannelScheduleTransitions(channel, now, hours),
+		})
+	}
+	common.ApiSuccess(c, items)
+}
@@ -0,0 +1,223 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// substitutionProbeMaxTokens 探测提示词只需要很短的回复
+const substitutionProbeMaxTokens = 64
+
+// substitutionProbeResult 一次探测的结果
+type substitutionProbeResult struct {
+	Model             string `json:"model"`
+	UpstreamModel     string `json:"upstream_model"` // 模型映射后实际请求的上游模型，返回的模型名与其比较
+	Probe             int    `json:"probe"`
+	ResponseModel     string `json:"response_model"`
+	SystemFingerprint string `json:"system_fingerprint"`
+	AnswerOk          bool   `json:"answer_ok"`
+	PromptTokens      int    `json:"prompt_tokens"`
+	Error             string `json:"error,omitempty"`
+}
+
+// getSubstitutionProbeModels 渠道需要探测的模型：配置了探测模型时取与渠道模型的交集，否则使用渠道的测试模型
+func getSubstitutionProbeModels(channel *model.Channel) []string {
+	probeModels := operation_setting.GetModelSubstitutionSetting().ProbeModels
+	if len(probeModels) == 0 {
+		return []string{""}
+	}
+	channelModels := make(map[string]bool)
+	for _, m := range channel.GetModels() {
+		channelModels[strings.TrimSpace(m)] = true
+	}
+	models := make([]string, 0)
+	for _, m := range probeModels {
+		if channelModels[m] {
+			models = append(models, m)
+		}
+	}
+	return models
+}
+
+// probeChannelSubstitution 用已知答案的提示词探测渠道，记录上游返回的模型名、system_fingerprint 与提示词 token 数
+func probeChannelSubstitution(channel *model.Channel) []substitutionProbeResult {
+	probes := operation_setting.GetModelSubstitutionSetting().Probes
+	results := make([]substitutionProbeResult, 0)
+	for _, probeModel := range getSubstitutionProbeModels(channel) {
+		for i, probe := range probes {
+			expect, err := regexp.Compile(probe.Expect)
+			if err != nil {
+				continue
+			}
+			result := runChannelTest(channel, probeModel, "", func(testModel string) dto.Request {
+				return &dto.GeneralOpenAIRequest{
+					Model:       testModel,
+					Messages:    []dto.Message{{Role: "user", Content: probe.Prompt}},
+					MaxTokens:   substitutionProbeMaxTokens,
+					Temperature: common.GetPointer[float64](0),
+				}
+			})
+			probeResult := substitutionProbeResult{
+				Model:         probeModel,
+				UpstreamModel: result.upstreamModel,
+				Probe:         i,
+			}
+			if result.localErr != nil {
+				// 请求失败不代表模型被替换，交给渠道测试处理
+				probeResult.Error = result.localErr.Error()
+				results = append(results, probeResult)
+				continue
+			}
+			resp, err := service.ParseCanaryResponse(result.respBody, result.isStream)
+			if err != nil {
+				probeResult.Error = err.Error()
+				results = append(results, probeResult)
+				continue
+			}
+			probeResult.ResponseModel = resp.Model
+			probeResult.SystemFingerprint = resp.SystemFingerprint
+			probeResult.AnswerOk = expect.MatchString(resp.Content)
+			probeResult.PromptTokens = result.usage.PromptTokens
+			// 统计按映射后的上游模型归类，与实际请求的 token 比例统计一致
+			service.RecordSubstitutionProbe(channel.Id, result.upstreamModel, i, resp.Model, resp.SystemFingerprint, probeResult.AnswerOk, probeResult.PromptTokens)
+			results = append(results, probeResult)
+			time.Sleep(common.RequestInterval)
+		}
+	}
+	return results
+}
+
+func probeAllChannelsSubstitution() {
+	channels, err := model.GetAllChannels(0, 0, true, false)
+	if err != nil {
+		common.SysLog(fmt.Sprintf("failed to get channels for substitution probe: %v", err))
+		return
+	}
+	for _, channel := range channels {
+		if channel.Status != common.ChannelStatusEnabled {
+			continue
+		}
+		probeChannelSubstitution(channel)
+	}
+	service.EvaluateModelSubstitution()
+}
+
+var autoProbeSubstitutionOnce sync.Once
+
+func AutomaticallyProbeModelSubstitution() {
+	// 只在Master节点定时探测；其它节点只收集实际请求的 token 比例，需要各自定时评估
+	if !common.IsMasterNode {
+		autoProbeSubstitutionOnce.Do(automaticallyEvaluateModelSubstitution)
+		return
+	}
+	autoProbeSubstitutionOnce.Do(func() {
+		for {
+			setting := operation_setting.GetModelSubstitutionSetting()
+			if !setting.Enabled || setting.ProbeIntervalMinutes <= 0 {
+				time.Sleep(1 * time.Minute)
+				continue
+			}
+			time.Sleep(time.Duration(setting.ProbeIntervalMinutes) * time.Minute)
+			if !operation_setting.GetModelSubstitutionSetting().Enabled {
+				continue
+			}
+			common.SysLog("automatically probing channels for model substitution")
+			probeAllChannelsSubstitution()
+			common.SysLog("model substitution probe finished")
+		}
+	})
+}
+
+// substitutionEvaluateInterval 非Master节点评估 token 比例统计的间隔
+const substitutionEvaluateInterval = 10 * time.Minute
+
+func automaticallyEvaluateModelSubstitution() {
+	for {
+		time.Sleep(substitutionEvaluateInterval)
+		if !operation_setting.GetModelSubstitutionSetting().Enabled {
+			continue
+		}
+		service.EvaluateModelSubstitution()
+	}
+}
+
+// GetModelSubstitution 替换检测统计与被标记的渠道
+func GetModelSubstitution(c *gin.Context) {
+	channels, err := model.GetSubstitutionFlaggedChannels()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	flagged := make([]gin.H, 0, len(channels))
+	for _, channel := range channels {
+		flag := channel.GetSubstitutionFlag()
+		if flag == nil {
+			continue
+		}
+		flagged = append(flagged, gin.H{
+			"id":     channel.Id,
+			"name":   channel.Name,
+			"type":   channel.Type,
+			"status": channel.Status,
+			"flag":   flag,
+		})
+	}
+	common.ApiSuccess(c, gin.H{
+		"stats":   service.GetModelSubstitutionStats(),
+		"flagged": flagged,
+	})
+}
+
+// ProbeChannelSubstitution 立即探测渠道并重新评估
+func ProbeChannelSubstitution(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	channel, err := model.GetChannelById(channelId, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	results := probeChannelSubstitution(channel)
+	if len(results) == 0 {
+		common.ApiError(c, errors.New("没有可用的探测模型或提示词"))
+		return
+	}
+	service.EvaluateModelSubstitution()
+	common.ApiSuccess(c, results)
+}
+
+// ClearChannelSubstitutionFlag 管理员确认渠道正常后清除标记并重新统计
+func ClearChannelSubstitutionFlag(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.ClearChannelSubstitutionFlag(channelId); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	service.ResetModelSubstitutionStats(channelId)
+	model.RecordLog(c.GetInt("id"), model.LogTypeManage, fmt.Sprintf("清除渠道 #%d 的模型替换标记", channelId))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ensureChannelBaselines 修改渠道前记录原始配置（仅在渠道还没有版本记录时）
+func ensureChannelBaselines(ids ...int) {
+	for _, id := range ids {
+		if err := model.EnsureChannelBaseline(id); err != nil {
+			common.SysLog(fmt.Sprintf("failed to record channel baseline: channel_id=%d, error=%v", id, err))
+		}
+	}
+}
+
+// recordChannelVersions 修改渠道后记录新版本，记录失败不影响本次修改
+func recordChannelVersions(c *gin.Context, action string, ids ...int) {
+	for _, id := range ids {
+		if err := model.RecordChannelVersion(id, action, c.GetInt("id"), c.GetString("username")); err != nil {
+			common.SysLog(fmt.Sprintf("failed to record channel version: channel_id=%d, error=%v", id, err))
+		}
+	}
+}
+
+func getTagChannelIds(tag string) []int {
+	channels, err := model.GetChannelsByTag(tag, false, false)
+	if err != nil {
+		return nil
+	}
+	ids := make([]int, 0, len(channels))
+	for _, channel := range channels {
+		ids = append(ids, channel.Id)
+	}
+	return ids
+}
+
+// GetChannelVersions 渠道配置的历史版本及每个版本相对上一版本的改动
+func GetChannelVersions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	versions, total, err := model.GetChannelVersions(id, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(versions)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// DiffChannelVersions 比较渠道的任意两个版本：?from=1&to=3
+func DiffChannelVersions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil {
+		common.ApiErrorMsg(c, "from 参数错误")
+		return
+	}
+	to, err := strconv.Atoi(c.Query("to"))
+	if err != nil {
+		common.ApiErrorMsg(c, "to 参数错误")
+		return
+	}
+	changes, err := model.DiffChannelVersions(id, from, to)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, changes)
+}
+
+// RollbackChannel 将渠道配置恢复到指定版本，密钥不随回滚恢复
+func RollbackChannel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := model.RollbackChannel(id, version, c.GetInt("id"), c.GetString("username")); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.InitChannelCache()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+type ChannelTagRollbackRequest struct {
+	Tag    string `json:"tag"`
+	Before int64  `json:"before"` // 恢复到该时间戳（秒）时的配置
+}
+
+// RollbackTagChannels 将带有指定标签的所有渠道恢复到某一时刻的配置
+func RollbackTagChannels(c *gin.Context) {
+	req := ChannelTagRollbackRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Tag == "" || req.Before <= 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "参数错误",
+		})
+		return
+	}
+	rolledBack, skipped, err := model.RollbackChannelsByTag(req.Tag, req.Before, c.GetInt("id"), c.GetString("username"))
+	if rolledBack > 0 {
+		model.InitChannelCache()
+	}
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"rolled_back": rolledBack,
+		"skipped":     skipped,
+	})
+}
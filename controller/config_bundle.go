@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	configBundleFormatJSON = "json"
+	configBundleFormatYAML = "yaml"
+)
+
+// configBundleFormat 配置包格式，由 format 参数或请求的 Content-Type 决定，默认为 JSON
+func configBundleFormat(c *gin.Context) string {
+	format := strings.ToLower(c.Query("format"))
+	if format == "" && strings.Contains(c.ContentType(), "yaml") {
+		format = configBundleFormatYAML
+	}
+	if format == "yml" || format == configBundleFormatYAML {
+		return configBundleFormatYAML
+	}
+	return configBundleFormatJSON
+}
+
+// encodeConfigBundle 编码配置包；YAML 由 JSON 转换而来，字段名与 JSON 一致
+func encodeConfigBundle(bundle *model.ConfigBundle, format string) ([]byte, error) {
+	data, err := common.Marshal(bundle)
+	if err != nil {
+		return nil, err
+	}
+	if format != configBundleFormatYAML {
+		return data, nil
+	}
+	var tree any
+	if err := common.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(tree)
+}
+
+func decodeConfigBundle(c *gin.Context) (*model.ConfigBundle, error) {
+	body, err := common.GetRequestBody(c)
+	if err != nil {
+		return nil, err
+	}
+	if configBundleFormat(c) == configBundleFormatYAML {
+		var tree any
+		if err := yaml.Unmarshal(body, &tree); err != nil {
+			return nil, fmt.Errorf("YAML 格式错误：%s", err.Error())
+		}
+		if body, err = common.Marshal(tree); err != nil {
+			return nil, err
+		}
+	}
+	bundle := &model.ConfigBundle{}
+	if err := common.Unmarshal(body, bundle); err != nil {
+		return nil, fmt.Errorf("配置包格式错误：%s", err.Error())
+	}
+	for i := range bundle.Channels {
+		if err := validateChannel(bundle.Channels[i].ToChannel(), false); err != nil {
+			return nil, fmt.Errorf("渠道 %s：%s", bundle.Channels[i].Name, err.Error())
+		}
+	}
+	return bundle, nil
+}
+
+func exportConfigBundle(c *gin.Context, includeKeys bool) bool {
+	bundle, err := model.ExportConfigBundle(includeKeys)
+	if err != nil {
+		common.ApiError(c, err)
+		return false
+	}
+	format := configBundleFormat(c)
+	data, err := encodeConfigBundle(bundle, format)
+	if err != nil {
+		common.ApiError(c, err)
+		return false
+	}
+	mimeType := "application/json"
+	if format == configBundleFormatYAML {
+		mimeType = "application/yaml"
+	}
+	fileName := fmt.Sprintf("new-api-config-%s.%s", time.Now().Format("20060102-150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileName))
+	c.Data(http.StatusOK, mimeType, data)
+	return true
+}
+
+// ExportConfigBundle 导出配置包（不含渠道密钥与密钥类选项）：?format=json|yaml
+func ExportConfigBundle(c *gin.Context) {
+	exportConfigBundle(c, false)
+}
+
+// ExportConfigBundleWithKeys 导出包含渠道密钥的配置包，需要安全验证
+func ExportConfigBundleWithKeys(c *gin.Context) {
+	if exportConfigBundle(c, true) {
+		model.RecordLog(c.GetInt("id"), model.LogTypeManage, "导出了包含渠道密钥的配置包")
+	}
+}
+
+// PlanConfigBundle 预览导入配置包将产生的修改，不写入数据
+func PlanConfigBundle(c *gin.Context) {
+	bundle, err := decodeConfigBundle(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	plan, err := model.PlanConfigBundle(bundle)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, plan)
+}
+
+// ApplyConfigBundle 导入配置包：?plan_hash= 指定预览得到的计划，数据已变化时拒绝执行；?dry_run=true 时只返回计划
+func ApplyConfigBundle(c *gin.Context) {
+	bundle, err := decodeConfigBundle(c)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if c.Query("dry_run") == "true" {
+		plan, err := model.PlanConfigBundle(bundle)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		common.ApiSuccess(c, plan)
+		return
+	}
+	plan, err := model.ApplyConfigBundle(bundle, c.Query("plan_hash"), c.GetInt("id"), c.GetString("username"))
+	if err == nil && len(plan.Changes) > 0 {
+		model.InitChannelCache()
+		service.ResetProxyClientCache()
+		model.RefreshPricing()
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    plan,
+		})
+		return
+	}
+	if len(plan.Changes) > 0 {
+		model.RecordLog(c.GetInt("id"), model.LogTypeManage, fmt.Sprintf("导入配置包，共 %d 项修改", len(plan.Changes)))
+	}
+	common.ApiSuccess(c, plan)
+}
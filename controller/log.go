@@ -6,6 +6,8 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 
 	"github.com/gin-gonic/gin"
 )
@@ -45,6 +47,7 @@ func GetUserLogs(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	fillLogDisplayAmount(c, logs)
 	pageInfo.SetTotal(int(total))
 	pageInfo.SetItems(logs)
 	common.ApiSuccess(c, pageInfo)
@@ -74,6 +77,7 @@ func SearchUserLogs(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	fillLogDisplayAmount(c, logs)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -167,3 +171,24 @@ func DeleteHistoryLogs(c *gin.Context) {
 	})
 	return
 }
+
+// fillLogDisplayAmount 按用户展示币种（或 currency 参数）换算日志金额
+func fillLogDisplayAmount(c *gin.Context, logs []*model.Log) {
+	currency := c.Query("currency")
+	if currency == "" {
+		userCache, err := model.GetUserCache(c.GetInt("id"))
+		if err != nil {
+			return
+		}
+		currency = service.ResolveDisplayCurrency(userCache.GetSetting())
+	} else {
+		currency = operation_setting.NormalizeCurrency(currency)
+		if !operation_setting.IsSupportedCurrency(currency) {
+			return
+		}
+	}
+	for _, log := range logs {
+		log.Currency = currency
+		log.Amount = service.QuotaToCurrencyAmount(log.Quota, currency)
+	}
+}
@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMedia 通过签名链接访问已归档的生成结果，无需令牌
+func GetMedia(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || !service.VerifyMediaSignature(id, c.Query("expires"), c.Query("signature")) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": gin.H{
+				"message": "invalid or expired signature",
+				"type":    "invalid_request_error",
+			},
+		})
+		return
+	}
+	object, err := model.GetMediaObjectById(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"message": "media not found",
+				"type":    "invalid_request_error",
+			},
+		})
+		return
+	}
+	if object.IsPending() {
+		// 归档尚未完成，跳转到原始地址，不缓存
+		if strings.HasPrefix(object.OriginUrl, "http://") || strings.HasPrefix(object.OriginUrl, "https://") {
+			c.Redirect(http.StatusFound, object.OriginUrl)
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"message": "media not found",
+				"type":    "invalid_request_error",
+			},
+		})
+		return
+	}
+	c.Writer.Header().Set("Cache-Control", "private, max-age=3600")
+	if err := service.ServeMediaObject(c.Writer, c.Request, object); err != nil {
+		logger.LogError(c.Request.Context(), "failed to serve media object "+strconv.Itoa(id)+": "+err.Error())
+		if !c.Writer.Written() {
+			c.JSON(http.StatusBadGateway, gin.H{
+				"error": gin.H{
+					"message": "failed to read media",
+					"type":    "server_error",
+				},
+			})
+		}
+	}
+}
+
+type mediaObjectItem struct {
+	*model.MediaObject
+	Url string `json:"url"`
+}
+
+func listMediaObjects(c *gin.Context, userId int) {
+	pageInfo := common.GetPageQuery(c)
+	objects, total, err := model.GetUserMediaObjects(userId, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	items := make([]mediaObjectItem, 0, len(objects))
+	for _, object := range objects {
+		items = append(items, mediaObjectItem{MediaObject: object, Url: service.SignMediaURL(object.Id)})
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(items)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetAllMediaObjects(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	listMediaObjects(c, userId)
+}
+
+func GetUserMediaObjects(c *gin.Context) {
+	listMediaObjects(c, c.GetInt("id"))
+}
+
+func deleteMediaObject(c *gin.Context, userId int) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	object, err := model.GetMediaObjectById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if userId != 0 && object.UserId != userId {
+		common.ApiError(c, errors.New("文件不存在"))
+		return
+	}
+	if err := service.DeleteMediaObject(c.Request.Context(), object); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
+
+func DeleteMediaObject(c *gin.Context) {
+	deleteMediaObject(c, 0)
+}
+
+func DeleteUserMediaObject(c *gin.Context) {
+	deleteMediaObject(c, c.GetInt("id"))
+}
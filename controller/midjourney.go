@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
@@ -17,6 +18,7 @@ import (
 	"github.com/QuantumNous/new-api/setting"
 	"github.com/QuantumNous/new-api/setting/system_setting"
 
+	"github.com/bytedance/gopkg/util/gopool"
 	"github.com/gin-gonic/gin"
 )
 
@@ -26,6 +28,12 @@ func UpdateMidjourneyTaskBulk() {
 	for {
 		time.Sleep(time.Duration(15) * time.Second)
 
+		// 启用统一任务框架后旧任务迁移到 tasks 表由统一轮询接管，旧轮询不再处理，避免重复更新与重复退款
+		if setting.MjTaskAdaptorEnabled {
+			RunMidjourneyTaskMigration()
+			continue
+		}
+
 		tasks := model.GetAllUnFinishTasks()
 		if len(tasks) == 0 {
 			continue
@@ -130,6 +138,7 @@ func UpdateMidjourneyTaskBulk() {
 				if !checkMjTaskNeedUpdate(task, responseItem) {
 					continue
 				}
+				preStatus := task.Status
 				task.Code = 1
 				task.Progress = responseItem.Progress
 				task.PromptEn = responseItem.PromptEn
@@ -172,10 +181,14 @@ func UpdateMidjourneyTaskBulk() {
 						shouldReturnQuota = true
 					}
 				}
-				err = task.Update()
+				updated, err := task.UpdateIfNotMigrated()
 				if err != nil {
 					logger.LogError(ctx, "UpdateMidjourneyTask task error: "+err.Error())
-				} else {
+				} else if updated {
+					service.EnqueueMidjourneyArchive(task)
+					if preStatus != task.Status {
+						service.NotifyMidjourneyCallback(task)
+					}
 					if shouldReturnQuota {
 						err = model.IncreaseUserQuota(task.UserId, task.Quota, false)
 						if err != nil {
@@ -259,17 +272,23 @@ func GetAllMidjourney(c *gin.Context) {
 	items := model.GetAllTasks(pageInfo.GetStartIdx(), pageInfo.GetPageSize(), queryParams)
 	total := model.CountAllTasks(queryParams)
 
-	if setting.MjForwardUrlEnabled {
-		for i, midjourney := range items {
-			midjourney.ImageUrl = system_setting.ServerAddress + "/mj/image/" + midjourney.MjId
-			items[i] = midjourney
-		}
-	}
+	fillMidjourneyImageUrls(items)
 	pageInfo.SetTotal(int(total))
 	pageInfo.SetItems(items)
 	common.ApiSuccess(c, pageInfo)
 }
 
+// fillMidjourneyImageUrls 开启转发时使用本站图片地址，已归档的图片使用签名地址
+func fillMidjourneyImageUrls(items []*model.Midjourney) {
+	for _, midjourney := range items {
+		if setting.MjForwardUrlEnabled {
+			midjourney.ImageUrl = system_setting.ServerAddress + "/mj/image/" + midjourney.MjId
+		} else if midjourney.MediaObjectId > 0 && midjourney.VideoUrl == "" {
+			midjourney.ImageUrl = service.SignMediaURL(midjourney.MediaObjectId)
+		}
+	}
+}
+
 func GetUserMidjourney(c *gin.Context) {
 	pageInfo := common.GetPageQuery(c)
 
@@ -284,13 +303,46 @@ func GetUserMidjourney(c *gin.Context) {
 	items := model.GetAllUserTask(userId, pageInfo.GetStartIdx(), pageInfo.GetPageSize(), queryParams)
 	total := model.CountAllUserTask(userId, queryParams)
 
-	if setting.MjForwardUrlEnabled {
-		for i, midjourney := range items {
-			midjourney.ImageUrl = system_setting.ServerAddress + "/mj/image/" + midjourney.MjId
-			items[i] = midjourney
-		}
-	}
+	fillMidjourneyImageUrls(items)
 	pageInfo.SetTotal(int(total))
 	pageInfo.SetItems(items)
 	common.ApiSuccess(c, pageInfo)
 }
+
+var midjourneyMigrationRunning atomic.Bool
+
+// RunMidjourneyTaskMigration 将旧 Midjourney 任务分批迁移到 tasks 表，迁移后由统一任务框架轮询
+func RunMidjourneyTaskMigration() {
+	if !midjourneyMigrationRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer midjourneyMigrationRunning.Store(false)
+	total := 0
+	for {
+		migrated, err := model.MigrateMidjourneyToTasks(500)
+		total += migrated
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to migrate midjourney tasks: %s", err.Error()))
+			return
+		}
+		if migrated == 0 {
+			break
+		}
+	}
+	if total > 0 {
+		common.SysLog(fmt.Sprintf("migrated %d midjourney tasks to unified tasks", total))
+	}
+}
+
+func MigrateMidjourneyTasks(c *gin.Context) {
+	if !setting.MjTaskAdaptorEnabled {
+		common.ApiErrorMsg(c, "请先启用 Midjourney 统一任务框架")
+		return
+	}
+	if midjourneyMigrationRunning.Load() {
+		common.ApiErrorMsg(c, "迁移正在进行中")
+		return
+	}
+	gopool.Go(RunMidjourneyTaskMigration)
+	common.ApiSuccess(c, nil)
+}
@@ -8,8 +8,10 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting"
 	"github.com/QuantumNous/new-api/setting/console_setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/QuantumNous/new-api/setting/system_setting"
 
@@ -146,6 +148,33 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "ImageSizeQualityRatio":
+		err = ratio_setting.UpdateImageSizeQualityRatioByJSONString(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "图片尺寸品质倍率设置失败: " + err.Error(),
+			})
+			return
+		}
+	case "VideoPriceRatio":
+		err = ratio_setting.UpdateVideoPriceRatioByJSONString(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "视频计费倍率设置失败: " + err.Error(),
+			})
+			return
+		}
+	case "AudioPriceRatio":
+		err = ratio_setting.UpdateAudioPriceRatioByJSONString(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "音频计费倍率设置失败: " + err.Error(),
+			})
+			return
+		}
 	case "AudioRatio":
 		err = ratio_setting.UpdateAudioRatioByJSONString(option.Value.(string))
 		if err != nil {
@@ -200,6 +229,32 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "routing_setting.default_policy":
+		if !operation_setting.IsValidRoutingPolicy(option.Value.(string)) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无效的路由策略",
+			})
+			return
+		}
+	case "routing_setting.policies":
+		err = operation_setting.ValidateRoutingPolicies(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "model_substitution_setting.probes":
+		err = operation_setting.ValidateSubstitutionProbes(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
 	case "console_setting.uptime_kuma_groups":
 		err = console_setting.ValidateConsoleSettings(option.Value.(string), "UptimeKumaGroups")
 		if err != nil {
@@ -221,3 +276,13 @@ func UpdateOption(c *gin.Context) {
 	})
 	return
 }
+
+// RefreshExchangeRates 立即从配置的汇率来源刷新汇率
+func RefreshExchangeRates(c *gin.Context) {
+	rates, err := service.RefreshExchangeRates()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, rates)
+}
@@ -0,0 +1,236 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxSimulateUsages = 100
+
+type PriceSimulateRequest struct {
+	// openai / responses / claude / gemini
+	Format           string                       `json:"format"`
+	Model            string                       `json:"model"`
+	Group            string                       `json:"group"`
+	Request          json.RawMessage              `json:"request"`
+	CompletionTokens *int                         `json:"completion_tokens"`
+	Usages           []service.PriceSimulateUsage `json:"usages"`
+}
+
+type PriceSimulateResponse struct {
+	Group             string                   `json:"group"`
+	CountTokenEnabled bool                     `json:"count_token_enabled"`
+	Items             []service.PriceBreakdown `json:"items"`
+	TotalQuota        int                      `json:"total_quota"`
+	AmountUSD         float64                  `json:"amount_usd"`
+	Amount            float64                  `json:"amount"`
+}
+
+type RepriceLogsRequest struct {
+	service.RepriceLogFilter
+	Config service.RepriceConfig `json:"config"`
+}
+
+// parseSimulateRequest 解析待模拟的请求体，返回请求、relay 格式与预估的补全 tokens
+func parseSimulateRequest(format string, body json.RawMessage, modelName string) (dto.Request, types.RelayFormat, int, error) {
+	switch format {
+	case "", "openai":
+		request := &dto.GeneralOpenAIRequest{}
+		if err := common.Unmarshal(body, request); err != nil {
+			return nil, "", 0, err
+		}
+		if modelName != "" {
+			request.Model = modelName
+		}
+		maxTokens := int(request.MaxTokens)
+		if request.MaxCompletionTokens > request.MaxTokens {
+			maxTokens = int(request.MaxCompletionTokens)
+		}
+		return request, types.RelayFormatOpenAI, maxTokens, nil
+	case "responses":
+		request := &dto.OpenAIResponsesRequest{}
+		if err := common.Unmarshal(body, request); err != nil {
+			return nil, "", 0, err
+		}
+		if modelName != "" {
+			request.Model = modelName
+		}
+		return request, types.RelayFormatOpenAIResponses, int(request.MaxOutputTokens), nil
+	case "claude":
+		request := &dto.ClaudeRequest{}
+		if err := common.Unmarshal(body, request); err != nil {
+			return nil, "", 0, err
+		}
+		if modelName != "" {
+			request.Model = modelName
+		}
+		return request, types.RelayFormatClaude, int(request.MaxTokens), nil
+	case "gemini":
+		request := &dto.GeminiChatRequest{}
+		if err := common.Unmarshal(body, request); err != nil {
+			return nil, "", 0, err
+		}
+		return request, types.RelayFormatGemini, int(request.GenerationConfig.MaxOutputTokens), nil
+	default:
+		return nil, "", 0, fmt.Errorf("不支持的请求格式: %s", format)
+	}
+}
+
+func simulateRequestModel(request dto.Request) string {
+	switch r := request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		return r.Model
+	case *dto.OpenAIResponsesRequest:
+		return r.Model
+	case *dto.ClaudeRequest:
+		return r.Model
+	}
+	return ""
+}
+
+// SimulatePrice 模拟请求计费，不调用上游也不扣费
+func SimulatePrice(c *gin.Context) {
+	var req PriceSimulateRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if len(req.Request) == 0 && len(req.Usages) == 0 {
+		common.ApiErrorMsg(c, "request 与 usages 不能同时为空")
+		return
+	}
+	if len(req.Usages) > maxSimulateUsages {
+		common.ApiErrorMsg(c, fmt.Sprintf("usages 最多 %d 条", maxSimulateUsages))
+		return
+	}
+
+	userId := c.GetInt("id")
+	userCache, err := model.GetUserCache(userId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	userCache.WriteContext(c)
+
+	group := req.Group
+	if group == "" {
+		group = userCache.Group
+	}
+	if group == "auto" {
+		common.ApiErrorMsg(c, "模拟计费不支持 auto 分组，请指定具体分组")
+		return
+	}
+	if group != userCache.Group && !service.GroupInUserUsableGroups(userCache.Group, group) {
+		common.ApiErrorMsg(c, "无权使用该分组: "+group)
+		return
+	}
+	common.SetContextKey(c, constant.ContextKeyUsingGroup, group)
+	common.SetContextKey(c, constant.ContextKeyPriceDryRun, true)
+
+	response := PriceSimulateResponse{
+		Group:             group,
+		CountTokenEnabled: constant.CountToken,
+		Items:             make([]service.PriceBreakdown, 0),
+	}
+
+	if len(req.Usages) > 0 {
+		for _, usage := range req.Usages {
+			if usage.ModelName == "" {
+				usage.ModelName = req.Model
+			}
+			if usage.ModelName == "" {
+				common.ApiErrorMsg(c, "usages 中的 model_name 不能为空")
+				return
+			}
+			common.SetContextKey(c, constant.ContextKeyOriginalModel, usage.ModelName)
+			info := relaycommon.GenRelayInfoOpenAI(c, nil)
+			priceData, err := helper.ModelPriceHelper(c, info, usage.PromptTokens, &types.TokenCountMeta{})
+			if err != nil {
+				common.ApiError(c, err)
+				return
+			}
+			response.Items = append(response.Items, service.CalculatePriceBreakdown(priceData, usage))
+		}
+	} else {
+		request, relayFormat, maxTokens, err := parseSimulateRequest(req.Format, req.Request, req.Model)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		modelName := simulateRequestModel(request)
+		if modelName == "" {
+			modelName = req.Model
+		}
+		if modelName == "" {
+			common.ApiErrorMsg(c, "模型名称不能为空")
+			return
+		}
+		common.SetContextKey(c, constant.ContextKeyOriginalModel, modelName)
+
+		info, err := relaycommon.GenRelayInfo(c, relayFormat, request, nil)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		meta := request.GetTokenCountMeta()
+		if meta == nil {
+			common.ApiError(c, errors.New("无法解析请求内容"))
+			return
+		}
+		if meta.MaxTokens == 0 {
+			meta.MaxTokens = maxTokens
+		}
+		promptTokens, err := service.EstimateRequestToken(c, meta, info)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		priceData, err := helper.ModelPriceHelper(c, info, promptTokens, meta)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		completionTokens := meta.MaxTokens
+		if req.CompletionTokens != nil {
+			completionTokens = *req.CompletionTokens
+		}
+		response.Items = append(response.Items, service.CalculatePriceBreakdown(priceData, service.PriceSimulateUsage{
+			ModelName:        modelName,
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+		}))
+	}
+
+	for _, item := range response.Items {
+		response.TotalQuota += item.TotalQuota
+	}
+	response.AmountUSD, response.Amount = service.QuotaToAmount(response.TotalQuota)
+	common.ApiSuccess(c, response)
+}
+
+// SimulateRepriceLogs 按拟调整的倍率重算历史消费日志，预估调价对收入的影响
+func SimulateRepriceLogs(c *gin.Context) {
+	var req RepriceLogsRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	result, err := service.RepriceLogs(req.RepriceLogFilter, req.Config)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, result)
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 
 	"github.com/gin-gonic/gin"
@@ -17,10 +18,14 @@ func GetPricing(c *gin.Context) {
 		groupRatio[s] = f
 	}
 	var group string
+	currency := operation_setting.NormalizeCurrency(c.Query("currency"))
 	if exists {
 		user, err := model.GetUserCache(userId.(int))
 		if err == nil {
 			group = user.Group
+			if c.Query("currency") == "" {
+				currency = service.ResolveDisplayCurrency(user.GetSetting())
+			}
 			for g := range groupRatio {
 				ratio, ok := ratio_setting.GetGroupGroupRatio(group, g)
 				if ok {
@@ -30,6 +35,10 @@ func GetPricing(c *gin.Context) {
 		}
 	}
 
+	if !operation_setting.IsSupportedCurrency(currency) {
+		currency = operation_setting.NormalizeCurrency("")
+	}
+
 	usableGroup = service.GetUserUsableGroups(group)
 	// check groupRatio contains usableGroup
 	for group := range ratio_setting.GetGroupRatioCopy() {
@@ -46,6 +55,9 @@ func GetPricing(c *gin.Context) {
 		"usable_group":       usableGroup,
 		"supported_endpoint": model.GetSupportedEndpointMap(),
 		"auto_groups":        service.GetUserAutoGroup(group),
+		"currency":           currency,
+		"currency_rate":      operation_setting.GetExchangeRate(currency),
+		"currency_symbol":    operation_setting.GetCurrencySymbolByCode(currency),
 	})
 }
 
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
@@ -33,7 +34,7 @@ import (
 func relayHandler(c *gin.Context, info *relaycommon.RelayInfo) *types.NewAPIError {
 	var err *types.NewAPIError
 	switch info.RelayMode {
-	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits:
+	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits, relayconstant.RelayModeImagesVariations:
 		err = relay.ImageHelper(c, info)
 	case relayconstant.RelayModeAudioSpeech:
 		fallthrough
@@ -63,6 +64,56 @@ func geminiRelayHandler(c *gin.Context, info *relaycommon.RelayInfo) *types.NewA
 	return err
 }
 
+func dispatchRelay(c *gin.Context, relayInfo *relaycommon.RelayInfo, relayFormat types.RelayFormat) *types.NewAPIError {
+	switch relayFormat {
+	case types.RelayFormatOpenAIRealtime:
+		return relay.WssHelper(c, relayInfo)
+	case types.RelayFormatClaude:
+		return relay.ClaudeHelper(c, relayInfo)
+	case types.RelayFormatGemini:
+		return geminiRelayHandler(c, relayInfo)
+	default:
+		return relayHandler(c, relayInfo)
+	}
+}
+
+// relayOnChannel 在 channel 上执行一次请求，记录渠道在途请求数与首字延迟供路由策略使用
+func relayOnChannel(c *gin.Context, relayInfo *relaycommon.RelayInfo, relayFormat types.RelayFormat, channelId int) *types.NewAPIError {
+	model.ChannelRelayStarted(channelId)
+	attemptStart := time.Now()
+	latencyMs := int64(-1)
+	defer func() {
+		model.ChannelRelayFinished(channelId, latencyMs)
+	}()
+	newAPIError := dispatchRelay(c, relayInfo, relayFormat)
+	// 实时会话为长连接，落败的对冲请求被提前取消，均不计入延迟样本
+	if relayFormat == types.RelayFormatOpenAIRealtime || relayInfo.Hedge.Lost() {
+		return newAPIError
+	}
+	if newAPIError == nil {
+		if relayInfo.FirstResponseTime.After(attemptStart) {
+			latencyMs = relayInfo.FirstResponseTime.Sub(attemptStart).Milliseconds()
+		} else {
+			latencyMs = time.Since(attemptStart).Milliseconds()
+		}
+	} else if isChannelFailure(c, newAPIError) {
+		latencyMs = model.ChannelFailureLatency(time.Since(attemptStart).Milliseconds())
+	}
+	return newAPIError
+}
+
+// isChannelFailure 错误是否由渠道导致（渠道错误、限流或上游 5xx），客户端断开与请求本身的错误不计入渠道
+func isChannelFailure(c *gin.Context, err *types.NewAPIError) bool {
+	if c.Request.Context().Err() != nil || types.IsSkipRetryError(err) {
+		return false
+	}
+	if types.IsChannelError(err) {
+		return true
+	}
+	statusCode := err.GetUpstreamStatusCode()
+	return statusCode == http.StatusTooManyRequests || statusCode/100 == 5
+}
+
 func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 
 	requestId := c.GetString(common.RequestIdKey)
@@ -181,11 +232,25 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		Retry:      common.GetPointer(0),
 	}
 
+	fallbackChain := getModelFallbackChain(c, relayInfo, relayFormat)
+	hedgeEligible := shouldHedge(c, relayInfo, relayFormat)
+
 	for ; retryParam.GetRetry() <= common.RetryTimes; retryParam.IncreaseRetry() {
 		channel, channelErr := getChannel(c, relayInfo, retryParam)
 		if channelErr != nil {
 			logger.LogError(c, channelErr.Error())
 			newAPIError = channelErr
+			if channelErr.GetErrorCode() == types.ErrorCodeGetChannelFailed {
+				// 当前模型已无可用渠道，尝试兜底模型
+				switched, fallbackErr := useNextFallbackModel(c, relayInfo, retryParam, &fallbackChain, tokens, meta)
+				if fallbackErr != nil {
+					newAPIError = fallbackErr
+					break
+				}
+				if switched {
+					continue
+				}
+			}
 			break
 		}
 
@@ -202,15 +267,12 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 
-		switch relayFormat {
-		case types.RelayFormatOpenAIRealtime:
-			newAPIError = relay.WssHelper(c, relayInfo)
-		case types.RelayFormatClaude:
-			newAPIError = relay.ClaudeHelper(c, relayInfo)
-		case types.RelayFormatGemini:
-			newAPIError = geminiRelayHandler(c, relayInfo)
-		default:
-			newAPIError = relayHandler(c, relayInfo)
+		if hedgeEligible {
+			// 仅首次请求参与对冲，重试沿用常规流程
+			hedgeEligible = false
+			channel, newAPIError = relayWithHedge(c, relayInfo, relayFormat, retryParam, channel, requestBody)
+		} else {
+			newAPIError = relayOnChannel(c, relayInfo, relayFormat, channel.Id)
 		}
 
 		if newAPIError == nil {
@@ -226,7 +288,18 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		c.Set("failed_channel_id", channel.Id)
 
 		if !shouldRetry(c, newAPIError, common.RetryTimes-retryParam.GetRetry()) {
-			break
+			// 同模型重试次数已用尽，可重试的错误继续尝试兜底模型
+			if !shouldRetry(c, newAPIError, 1) {
+				break
+			}
+			switched, fallbackErr := useNextFallbackModel(c, relayInfo, retryParam, &fallbackChain, tokens, meta)
+			if fallbackErr != nil {
+				newAPIError = fallbackErr
+				break
+			}
+			if !switched {
+				break
+			}
 		}
 
 		// 重试时，如果 channel_id 存在但不是通过 URL 参数指定的（即 specific_channel_id 不存在），
@@ -240,6 +313,10 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 	}
 
+	if relayInfo.FallbackFromModel != "" && newAPIError != nil {
+		c.Writer.Header().Del(servedModelHeader)
+	}
+
 	useChannel := c.GetStringSlice("use_channel")
 	if len(useChannel) > 1 {
 		retryLogStr := fmt.Sprintf("重试：%s", strings.Trim(strings.Join(strings.Fields(fmt.Sprint(useChannel)), "->"), "[]"))
@@ -461,6 +538,10 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	if _, ok := c.Get("specific_channel_id"); ok {
 		return false
 	}
+	// 渠道错误映射规则明确指定了是否重试
+	if rule := openaiErr.GetErrorRule(); rule != nil && rule.Retry != nil {
+		return *rule.Retry
+	}
 
 	// 检查渠道的全重试开关
 	// 优先从 context 中获取当前失败的渠道ID（更准确）
@@ -512,14 +593,36 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	return true
 }
 
+// applyChannelErrorRules 按当前渠道的错误映射规则改写错误，需在判断禁用与重试之前调用
+func applyChannelErrorRules(c *gin.Context, err *types.NewAPIError) {
+	if c == nil || err == nil {
+		return
+	}
+	setting, ok := common.GetContextKeyType[dto.ChannelSettings](c, constant.ContextKeyChannelSetting)
+	if !ok || len(setting.ErrorRules) == 0 {
+		return
+	}
+	if rule := service.ApplyChannelErrorRules(setting.ErrorRules, err); rule != nil {
+		logger.LogInfo(c, fmt.Sprintf("[错误映射] 命中规则 %s，状态码: %d，错误码: %s", rule.Rule, err.StatusCode, err.GetErrorCode()))
+	}
+}
+
 func processChannelError(c *gin.Context, channelError types.ChannelError, err *types.NewAPIError) {
+	applyChannelErrorRules(c, err)
 	logger.LogError(c, fmt.Sprintf("channel error (channel #%d, status code: %d): %s", channelError.ChannelId, err.StatusCode, err.Error()))
 	// 不要使用context获取渠道信息，异步处理时可能会出现渠道信息不一致的情况
 	// do not use context to get channel info, there may be inconsistent channel info when processing asynchronously
-	if service.ShouldDisableChannel(channelError.ChannelType, err) && channelError.AutoBan {
-		gopool.Go(func() {
-			service.DisableChannel(channelError, err.Error())
-		})
+	if channelError.AutoBan {
+		if service.NeedChannelCooldown(err) {
+			// 限流、额度耗尽等可恢复的错误临时冷却，到期后自动恢复
+			gopool.Go(func() {
+				service.CooldownChannel(channelError, err)
+			})
+		} else if service.ShouldDisableChannel(channelError.ChannelType, err) {
+			gopool.Go(func() {
+				service.DisableChannelByError(channelError, err)
+			})
+		}
 	}
 
 	if constant.ErrorLogEnabled && types.IsRecordErrorLog(err) {
@@ -565,6 +668,12 @@ func RelayMidjourney(c *gin.Context) {
 		return
 	}
 
+	// 提交类请求走统一的异步任务框架
+	if setting.MjTaskAdaptorEnabled && isMidjourneyTaskSubmitMode(relayInfo.RelayMode) {
+		RelayTask(c)
+		return
+	}
+
 	var mjErr *dto.MidjourneyResponse
 	switch relayInfo.RelayMode {
 	case relayconstant.RelayModeMidjourneyNotify:
@@ -596,6 +705,18 @@ func RelayMidjourney(c *gin.Context) {
 	}
 }
 
+func isMidjourneyTaskSubmitMode(relayMode int) bool {
+	switch relayMode {
+	case relayconstant.RelayModeMidjourneyNotify,
+		relayconstant.RelayModeMidjourneyTaskFetch,
+		relayconstant.RelayModeMidjourneyTaskFetchByCondition,
+		relayconstant.RelayModeMidjourneyTaskImageSeed,
+		relayconstant.RelayModeMidjourneyUpload:
+		return false
+	}
+	return true
+}
+
 func RelayNotImplemented(c *gin.Context) {
 	err := types.OpenAIError{
 		Message: "API not implemented",
@@ -673,14 +794,42 @@ func RelayTask(c *gin.Context) {
 		if taskErr.StatusCode == http.StatusTooManyRequests {
 			taskErr.Message = "当前分组上游负载已饱和，请稍后再试"
 		}
+		if c.GetString("platform") == constant.TaskPlatformMidjourney {
+			// 保持 Midjourney Proxy 的错误格式
+			mjCode, err := strconv.Atoi(taskErr.Code)
+			if err != nil {
+				mjCode = constant.MjRequestError
+			}
+			c.JSON(taskErr.StatusCode, gin.H{
+				"description": taskErr.Message,
+				"type":        "upstream_error",
+				"code":        mjCode,
+			})
+			return
+		}
+		c.JSON(taskErr.StatusCode, taskErr)
+	}
+}
+
+// RelayTaskCancel 取消任务或删除已完成任务的结果
+func RelayTaskCancel(c *gin.Context) {
+	task, taskErr := relay.RelayTaskCancel(c, c.Param("task_id"))
+	if taskErr != nil {
 		c.JSON(taskErr.StatusCode, taskErr)
+		return
+	}
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/videos/") {
+		c.JSON(http.StatusOK, relay.NewOpenAIVideoDeleted(task))
+		return
 	}
+	c.JSON(http.StatusOK, relay.TaskCancelResponse(task))
 }
 
 func taskRelayHandler(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dto.TaskError {
 	var err *dto.TaskError
 	switch relayInfo.RelayMode {
-	case relayconstant.RelayModeSunoFetch, relayconstant.RelayModeSunoFetchByID, relayconstant.RelayModeVideoFetchByID:
+	case relayconstant.RelayModeSunoFetch, relayconstant.RelayModeSunoFetchByID, relayconstant.RelayModeVideoFetchByID,
+		relayconstant.RelayModeAudioGenerationFetchByID:
 		err = relay.RelayTaskFetch(c, relayInfo.RelayMode)
 	default:
 		err = relay.RelayTaskSubmit(c, relayInfo)
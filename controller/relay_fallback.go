@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// servedModelHeader 跨模型兜底后告知客户端实际服务的模型
+const servedModelHeader = "X-Served-Model"
+
+// getModelFallbackChain 返回请求可用的兜底模型链；指定渠道与实时会话不做跨模型兜底
+func getModelFallbackChain(c *gin.Context, relayInfo *relaycommon.RelayInfo, relayFormat types.RelayFormat) []string {
+	if relayFormat == types.RelayFormatOpenAIRealtime {
+		return nil
+	}
+	if _, ok := c.Get("specific_channel_id"); ok {
+		return nil
+	}
+	if _, ok := common.GetContextKey(c, constant.ContextKeyTokenSpecificChannelId); ok {
+		return nil
+	}
+	return model_setting.GetModelFallbackChain(relayInfo.UsingGroup, relayInfo.OriginModelName)
+}
+
+// useNextFallbackModel 当前模型的渠道均已失败时改用兜底链中的下一个模型：按新模型重新计价与预扣费，
+// 请求体由渠道适配器按新模型的渠道类型转换。返回是否已切换，预扣费失败时返回错误并终止重试
+func useNextFallbackModel(c *gin.Context, relayInfo *relaycommon.RelayInfo, retryParam *service.RetryParam, chain *[]string, tokens int, meta *types.TokenCountMeta) (bool, *types.NewAPIError) {
+	for len(*chain) > 0 {
+		nextModel := (*chain)[0]
+		*chain = (*chain)[1:]
+
+		if !tokenAllowsModel(c, nextModel) {
+			logger.LogWarn(c, fmt.Sprintf("[模型兜底] 令牌无权访问兜底模型 %s，跳过", nextModel))
+			continue
+		}
+
+		fromModel := relayInfo.OriginModelName
+		oldPriceData := relayInfo.PriceData
+		relayInfo.OriginModelName = nextModel
+		priceData, err := helper.ModelPriceHelper(c, relayInfo, tokens, meta)
+		if err != nil {
+			logger.LogWarn(c, fmt.Sprintf("[模型兜底] 兜底模型 %s 计价失败，跳过: %s", nextModel, err.Error()))
+			relayInfo.OriginModelName = fromModel
+			relayInfo.PriceData = oldPriceData
+			continue
+		}
+
+		// 退还按原模型预扣的额度后按兜底模型重新预扣，同步执行避免与新的预扣费交错
+		if relayInfo.FinalPreConsumedQuota != 0 {
+			if err := service.PostConsumeQuota(relayInfo, -relayInfo.FinalPreConsumedQuota, 0, false); err != nil {
+				return false, types.NewError(err, types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
+			}
+			relayInfo.FinalPreConsumedQuota = 0
+		}
+		if !priceData.FreeModel {
+			if newAPIError := service.PreConsumeQuota(c, priceData.QuotaToPreConsume, relayInfo); newAPIError != nil {
+				return false, newAPIError
+			}
+		}
+
+		if relayInfo.FallbackFromModel == "" {
+			relayInfo.FallbackFromModel = fromModel
+		}
+		common.SetContextKey(c, constant.ContextKeyOriginalModel, nextModel)
+		c.Set("channel_id", 0)
+		common.SetContextKey(c, constant.ContextKeyChannelId, 0)
+		// auto 分组从第一个分组开始为兜底模型选择渠道
+		common.SetContextKey(c, constant.ContextKeyAutoGroupIndex, 0)
+		common.SetContextKey(c, constant.ContextKeyAutoGroupRetryIndex, 0)
+		c.Header(servedModelHeader, nextModel)
+
+		retryParam.ModelName = nextModel
+		retryParam.SetRetry(0)
+		// 跳过本轮循环末尾的重试计数，兜底模型从第 0 次开始
+		retryParam.ResetRetryNextTry()
+		logger.LogInfo(c, fmt.Sprintf("[模型兜底] 模型 %s 无可用渠道，改用兜底模型 %s", fromModel, nextModel))
+		return true, nil
+	}
+	return false, nil
+}
+
+// tokenAllowsModel 令牌开启模型限制时，兜底模型同样需要在允许列表中
+func tokenAllowsModel(c *gin.Context, modelName string) bool {
+	if !common.GetContextKeyBool(c, constant.ContextKeyTokenModelLimitEnabled) {
+		return true
+	}
+	s, ok := common.GetContextKey(c, constant.ContextKeyTokenModelLimit)
+	if !ok {
+		return false
+	}
+	tokenModelLimit, ok := s.(map[string]bool)
+	if !ok {
+		return false
+	}
+	return tokenModelLimit[ratio_setting.FormatMatchingModelName(modelName)]
+}
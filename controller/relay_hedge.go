@@ -0,0 +1,302 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hedgeChannelSelectAttempts 选择对冲渠道时最多尝试的次数（随机调度可能选中已使用的渠道）
+const hedgeChannelSelectAttempts = 3
+
+var errHedgeLost = errors.New("hedge attempt lost")
+
+// hedgeWriter 对冲尝试的响应写入器：首次写入响应体时竞争输出权，胜出前响应头与状态码只保存在本地，
+// 胜出后写入真实响应，落败的写入被丢弃
+type hedgeWriter struct {
+	gin.ResponseWriter
+	attempt *relaycommon.HedgeAttempt
+	header  http.Header
+	status  int
+	won     bool
+}
+
+func newHedgeWriter(w gin.ResponseWriter, attempt *relaycommon.HedgeAttempt) *hedgeWriter {
+	return &hedgeWriter{
+		ResponseWriter: w,
+		attempt:        attempt,
+		header:         http.Header{},
+		status:         http.StatusOK,
+	}
+}
+
+func (w *hedgeWriter) claim() bool {
+	if w.won {
+		return true
+	}
+	if !w.attempt.Claim() {
+		return false
+	}
+	w.won = true
+	header := w.ResponseWriter.Header()
+	for k, v := range w.header {
+		header[k] = v
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	return true
+}
+
+func (w *hedgeWriter) Header() http.Header {
+	if w.won {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+func (w *hedgeWriter) WriteHeader(code int) {
+	if w.won {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *hedgeWriter) WriteHeaderNow() {
+	if w.won {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *hedgeWriter) Write(data []byte) (int, error) {
+	if !w.claim() {
+		return 0, errHedgeLost
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *hedgeWriter) WriteString(s string) (int, error) {
+	if !w.claim() {
+		return 0, errHedgeLost
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *hedgeWriter) Flush() {
+	if w.won {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *hedgeWriter) Status() int {
+	if w.won {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *hedgeWriter) Size() int {
+	if w.won {
+		return w.ResponseWriter.Size()
+	}
+	return -1
+}
+
+func (w *hedgeWriter) Written() bool {
+	return w.won && w.ResponseWriter.Written()
+}
+
+type hedgeResult struct {
+	attempt *relaycommon.HedgeAttempt
+	ctx     *gin.Context
+	info    *relaycommon.RelayInfo
+	channel *model.Channel
+	err     *types.NewAPIError
+}
+
+// shouldHedge 对冲仅用于对话类请求，令牌或分组开启后生效，默认仅流式请求；指定渠道的请求不对冲
+func shouldHedge(c *gin.Context, relayInfo *relaycommon.RelayInfo, relayFormat types.RelayFormat) bool {
+	switch relayFormat {
+	case types.RelayFormatClaude:
+	case types.RelayFormatGemini:
+		if strings.Contains(c.Request.URL.Path, "embed") {
+			return false
+		}
+	case types.RelayFormatOpenAI, types.RelayFormatOpenAIResponses:
+		switch relayInfo.RelayMode {
+		case relayconstant.RelayModeChatCompletions, relayconstant.RelayModeCompletions, relayconstant.RelayModeResponses:
+		default:
+			return false
+		}
+	default:
+		return false
+	}
+	if _, ok := c.Get("specific_channel_id"); ok {
+		return false
+	}
+	if _, ok := common.GetContextKey(c, constant.ContextKeyTokenSpecificChannelId); ok {
+		return false
+	}
+	return operation_setting.IsHedgeEnabledFor(relayInfo.UsingGroup, common.GetContextKeyBool(c, constant.ContextKeyTokenHedgeEnabled), relayInfo.IsStream)
+}
+
+// relayWithHedge 向 channel 发起请求，首个 token 超过配置的延迟仍未返回时向另一渠道发起对冲请求，
+// 先输出响应的一方胜出并取消另一方。两个尝试共用同一份预扣费，只有胜者按用量结算。
+// 返回胜者的渠道与结果；均失败时返回主请求的渠道与错误，由外层处理，另一方的渠道错误在此处理
+func relayWithHedge(c *gin.Context, relayInfo *relaycommon.RelayInfo, relayFormat types.RelayFormat, retryParam *service.RetryParam, channel *model.Channel, requestBody []byte) (*model.Channel, *types.NewAPIError) {
+	hedgeSetting := operation_setting.GetHedgeSetting()
+	service.RecordHedgeEligible()
+
+	race := relaycommon.NewHedgeRace()
+	results := make(chan *hedgeResult, 2)
+	start := func(role string, attemptChannel *model.Channel) {
+		attempt := race.NewAttempt(c.Request.Context(), role)
+		ac := c.Copy()
+		ac.Request = c.Request.WithContext(attempt.Context())
+		ac.Request.Body = io.NopCloser(bytes.NewReader(requestBody))
+		ac.Writer = newHedgeWriter(c.Writer, attempt)
+		info := *relayInfo
+		info.Hedge = attempt
+		// 决出胜者前不发送 ping，避免空数据抢占输出权
+		info.DisablePing = true
+		if info.PriceData.TierInfo != nil {
+			tierInfo := *info.PriceData.TierInfo
+			info.PriceData.TierInfo = &tierInfo
+		}
+		result := &hedgeResult{attempt: attempt, ctx: ac, info: &info, channel: attemptChannel}
+		gopool.Go(func() {
+			defer func() {
+				if r := recover(); r != nil {
+					result.err = types.NewError(fmt.Errorf("hedge attempt panic: %v", r), types.ErrorCodeBadResponse)
+				}
+				results <- result
+			}()
+			result.err = relayOnChannel(ac, result.info, relayFormat, attemptChannel.Id)
+		})
+	}
+
+	start(relaycommon.HedgeRolePrimary, channel)
+	pending := 1
+	hedged := false
+
+	timer := time.NewTimer(time.Duration(hedgeSetting.DelayMs) * time.Millisecond)
+	defer timer.Stop()
+
+	var primaryResult *hedgeResult
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if race.Winner() != nil {
+				continue
+			}
+			hedgeChannel := selectHedgeChannel(c, relayInfo, retryParam)
+			if hedgeChannel == nil {
+				logger.LogWarn(c, "[对冲] 没有可用于对冲的其他渠道")
+				continue
+			}
+			addUsedChannel(c, hedgeChannel.Id)
+			hedged = true
+			service.RecordHedgeFired()
+			logger.LogInfo(c, fmt.Sprintf("[对冲] 渠道 #%d 超过 %dms 未返回首个 token，向渠道 #%d 发起对冲请求", channel.Id, hedgeSetting.DelayMs, hedgeChannel.Id))
+			start(relaycommon.HedgeRoleHedge, hedgeChannel)
+			pending++
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				// 未输出任何内容即正常结束的尝试同样可以胜出
+				result.attempt.Claim()
+			}
+			winner := race.Winner()
+			if winner == result.attempt {
+				result.attempt.Release()
+				if hedged {
+					service.RecordHedgeResult(winner)
+					logger.LogInfo(c, fmt.Sprintf("[对冲] 渠道 #%d（%s）胜出", result.channel.Id, winner.Role))
+				}
+				if primaryResult != nil {
+					processHedgeChannelError(primaryResult)
+				}
+				// 落败方仍在取消中，结束后再结算
+				if remaining := pending; remaining > 0 {
+					gopool.Go(func() {
+						for i := 0; i < remaining; i++ {
+							finishHedgeLoser(<-results, hedgeSetting.BillLoserPrompt)
+						}
+					})
+				}
+				return useHedgeResultChannel(c, result)
+			}
+			if winner != nil {
+				finishHedgeLoser(result, hedgeSetting.BillLoserPrompt)
+				continue
+			}
+			result.attempt.Release()
+			if result.attempt.Role == relaycommon.HedgeRolePrimary {
+				primaryResult = result
+				continue
+			}
+			processHedgeChannelError(result)
+		}
+	}
+
+	if hedged {
+		service.RecordHedgeResult(nil)
+	}
+	return useHedgeResultChannel(c, primaryResult)
+}
+
+// useHedgeResultChannel 恢复返回结果所用渠道的密钥上下文，供外层处理渠道错误
+func useHedgeResultChannel(c *gin.Context, result *hedgeResult) (*model.Channel, *types.NewAPIError) {
+	common.SetContextKey(c, constant.ContextKeyChannelKey, common.GetContextKeyString(result.ctx, constant.ContextKeyChannelKey))
+	return result.channel, result.err
+}
+
+func processHedgeChannelError(result *hedgeResult) {
+	processChannelError(result.ctx, *types.NewChannelError(result.channel.Id, result.channel.Type, result.channel.Name, result.channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(result.ctx, constant.ContextKeyChannelKey), result.channel.GetAutoBan()), result.err)
+}
+
+// selectHedgeChannel 为对冲请求选择一个未使用过的渠道，不影响外层重试计数
+func selectHedgeChannel(c *gin.Context, relayInfo *relaycommon.RelayInfo, retryParam *service.RetryParam) *model.Channel {
+	hedgeParam := *retryParam
+	hedgeParam.Retry = common.GetPointer(retryParam.GetRetry())
+	c.Set("channel_id", 0)
+	common.SetContextKey(c, constant.ContextKeyChannelId, 0)
+	for i := 0; i < hedgeChannelSelectAttempts; i++ {
+		hedgeChannel, err := getChannel(c, relayInfo, &hedgeParam)
+		if err == nil {
+			return hedgeChannel
+		}
+		if err.GetErrorCode() != types.ErrorCodeGetChannelFailed {
+			return nil
+		}
+	}
+	return nil
+}
+
+// finishHedgeLoser 落败的尝试不结算用量，按配置仅对已发往上游的提示词计费
+func finishHedgeLoser(result *hedgeResult, billLoserPrompt bool) {
+	result.attempt.Release()
+	if billLoserPrompt {
+		relay.PostHedgeLoserQuota(result.ctx, result.info)
+	}
+}
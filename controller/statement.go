@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetUserStatements 用户获取自己的账单
+func GetUserStatements(c *gin.Context) {
+	userId := c.GetInt("id")
+	pageInfo := common.GetPageQuery(c)
+	statements, total, err := model.GetStatements(userId, c.Query("status"), pageInfo)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(statements)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetAllStatements 管理员获取账单，可按用户筛选
+func GetAllStatements(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	userId := 0
+	if userIdStr := c.Query("user_id"); userIdStr != "" {
+		var err error
+		userId, err = strconv.Atoi(userIdStr)
+		if err != nil {
+			common.ApiErrorMsg(c, "用户ID格式错误")
+			return
+		}
+	}
+	statements, total, err := model.GetStatements(userId, c.Query("status"), pageInfo)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(statements)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func getStatementWithPermission(c *gin.Context) *model.Statement {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorMsg(c, "账单ID格式错误")
+		return nil
+	}
+	statement := model.GetStatementById(id)
+	if statement == nil {
+		common.ApiErrorMsg(c, "账单不存在")
+		return nil
+	}
+	// 检查权限：管理员或账单所属用户
+	userRole := c.GetInt("role")
+	if userRole != common.RoleRootUser && userRole != common.RoleAdminUser && statement.UserId != c.GetInt("id") {
+		common.ApiErrorMsg(c, "无权访问此账单")
+		return nil
+	}
+	return statement
+}
+
+// GetStatement 获取账单详情（含明细）
+func GetStatement(c *gin.Context) {
+	statement := getStatementWithPermission(c)
+	if statement == nil {
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"statement":  statement,
+		"line_items": statement.GetLineItems(),
+	})
+}
+
+// ExportStatement 导出账单，format 支持 csv 与 pdf
+func ExportStatement(c *gin.Context) {
+	statement := getStatementWithPermission(c)
+	if statement == nil {
+		return
+	}
+	var (
+		data     []byte
+		mimeType string
+		err      error
+	)
+	format := c.DefaultQuery("format", "csv")
+	switch format {
+	case "csv":
+		data, err = service.ExportStatementCSV(statement)
+		mimeType = "text/csv; charset=utf-8"
+	case "pdf":
+		data, err = service.ExportStatementPDF(statement)
+		mimeType = "application/pdf"
+	default:
+		common.ApiErrorMsg(c, "不支持的导出格式")
+		return
+	}
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	fileName := fmt.Sprintf("statement-%d-%s.%s", statement.Id, time.Unix(statement.PeriodStart, 0).Format("200601"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileName))
+	c.Data(200, mimeType, data)
+}
+
+type GenerateStatementRequest struct {
+	UserId int    `json:"user_id"`
+	Month  string `json:"month"` // 格式 2006-01，为空时为上个月
+}
+
+// GenerateStatements 管理员手动生成账单，未指定用户时为所有后付费用户生成
+func GenerateStatements(c *gin.Context) {
+	var req GenerateStatementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "无效的参数")
+		return
+	}
+	month := time.Now().AddDate(0, -1, 0)
+	if req.Month != "" {
+		parsed, err := time.ParseInLocation("2006-01", req.Month, time.Local)
+		if err != nil {
+			common.ApiErrorMsg(c, "月份格式错误，应为 YYYY-MM")
+			return
+		}
+		month = parsed
+	}
+	periodStart, periodEnd := service.GetStatementPeriod(month)
+	if periodEnd.After(time.Now()) {
+		common.ApiErrorMsg(c, "账期尚未结束")
+		return
+	}
+	if req.UserId == 0 {
+		service.GenerateMonthlyStatements(month)
+		common.ApiSuccess(c, nil)
+		return
+	}
+	statement, err := service.GenerateStatement(req.UserId, periodStart, periodEnd)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, statement)
+}
+
+// PayStatement 管理员确认账单已付款
+func PayStatement(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorMsg(c, "账单ID格式错误")
+		return
+	}
+	statement, err := service.SettleStatement(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, statement)
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetAllTaskCallbackDeliveries(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	deliveries, total, err := model.GetTaskCallbackDeliveries(userId, c.Query("task_id"), c.Query("status"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(deliveries)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetUserTaskCallbackDeliveries(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	deliveries, total, err := model.GetTaskCallbackDeliveries(c.GetInt("id"), c.Query("task_id"), c.Query("status"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(deliveries)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func replayTaskCallbackDelivery(c *gin.Context, userId int) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	delivery, err := model.GetTaskCallbackDeliveryById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if userId != 0 && delivery.UserId != userId {
+		common.ApiError(c, errors.New("回调记录不存在"))
+		return
+	}
+	if err := service.ReplayTaskCallbackDelivery(delivery); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, delivery)
+}
+
+// ReplayTaskCallbackDelivery 管理员重放任意失败的回调
+func ReplayTaskCallbackDelivery(c *gin.Context) {
+	replayTaskCallbackDelivery(c, 0)
+}
+
+// ReplayUserTaskCallbackDelivery 用户重放自己失败的回调
+func ReplayUserTaskCallbackDelivery(c *gin.Context) {
+	replayTaskCallbackDelivery(c, c.GetInt("id"))
+}
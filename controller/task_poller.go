@@ -0,0 +1,329 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// taskPollerNodeId 当前节点标识，用于任务租约
+var taskPollerNodeId = func() string {
+	hostname, _ := os.Hostname()
+	id := common.GetUUID()[:8]
+	if hostname == "" {
+		return id
+	}
+	if len(hostname) > 48 {
+		hostname = hostname[:48]
+	}
+	return hostname + "-" + id
+}()
+
+// taskLeaser 任务租约，保证多节点部署时同一任务同一时刻只被一个节点轮询
+type taskLeaser interface {
+	Claim(ids []int64, now int64, leaseUntil int64) ([]*model.Task, error)
+	Release(task *model.Task, nextPollAt int64, pollAttempts int) error
+}
+
+type dbTaskLeaser struct{}
+
+func (dbTaskLeaser) Claim(ids []int64, now int64, leaseUntil int64) ([]*model.Task, error) {
+	return model.ClaimTasksByDB(ids, taskPollerNodeId, now, leaseUntil)
+}
+
+func (dbTaskLeaser) Release(task *model.Task, nextPollAt int64, pollAttempts int) error {
+	return model.ReleaseTaskLease(task.ID, taskPollerNodeId, nextPollAt, pollAttempts)
+}
+
+type redisTaskLeaser struct{}
+
+func taskLeaseKey(id int64) string {
+	return "task_poll_lease:" + strconv.FormatInt(id, 10)
+}
+
+func (redisTaskLeaser) Claim(ids []int64, now int64, leaseUntil int64) ([]*model.Task, error) {
+	claimed := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		ok, err := common.RDB.SetNX(context.Background(), taskLeaseKey(id), taskPollerNodeId, time.Duration(leaseUntil-now)*time.Second).Result()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			claimed = append(claimed, id)
+		}
+	}
+	return model.GetTasksByIds(claimed)
+}
+
+// releaseTaskLeaseScript 仅在租约仍由本节点持有时删除，避免误删其他节点在租约过期后重新领取的租约
+const releaseTaskLeaseScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+
+func (redisTaskLeaser) Release(task *model.Task, nextPollAt int64, pollAttempts int) error {
+	err := model.ReleaseTaskLease(task.ID, "", nextPollAt, pollAttempts)
+	if delErr := common.RDB.Eval(context.Background(), releaseTaskLeaseScript, []string{taskLeaseKey(task.ID)}, taskPollerNodeId).Err(); delErr != nil && err == nil {
+		err = delErr
+	}
+	return err
+}
+
+func getTaskLeaser() taskLeaser {
+	if common.RedisEnabled {
+		return redisTaskLeaser{}
+	}
+	return dbTaskLeaser{}
+}
+
+// taskPoller 按任务的下次轮询时间调度查询，限制每个渠道的并发
+type taskPoller struct {
+	inflight   sync.Map // 本节点正在处理的任务，避免租约到期后重复领取
+	mu         sync.Mutex
+	semaphores map[int]chan struct{}
+}
+
+var defaultTaskPoller = &taskPoller{semaphores: make(map[int]chan struct{})}
+
+func (p *taskPoller) channelSemaphore(channelId int) chan struct{} {
+	size := max(operation_setting.GetTaskPollSetting().ChannelConcurrency, 1)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sem, ok := p.semaphores[channelId]
+	if !ok || cap(sem) != size {
+		sem = make(chan struct{}, size)
+		p.semaphores[channelId] = sem
+	}
+	return sem
+}
+
+type taskPollSnapshot struct {
+	status   model.TaskStatus
+	progress string
+}
+
+// UpdateTaskBulk 异步任务轮询：主节点或开启分布式轮询的节点定期领取到期任务并查询进度
+func UpdateTaskBulk() {
+	lastTimeoutCheck := int64(0)
+	for {
+		time.Sleep(2 * time.Second)
+		setting := operation_setting.GetTaskPollSetting()
+		if !common.IsMasterNode && !setting.Distributed {
+			continue
+		}
+		now := time.Now().Unix()
+		if now-lastTimeoutCheck >= 60 {
+			lastTimeoutCheck = now
+			failTimeoutTasks(now)
+		}
+		defaultTaskPoller.pollDueTasks(now)
+	}
+}
+
+// taskPollUnit 一次查询的调度单元：Suno 按渠道批量查询，其余平台逐个查询
+type taskPollUnit struct {
+	platform  constant.TaskPlatform
+	channelId int
+	ids       []int64
+	sem       chan struct{}
+}
+
+func (p *taskPoller) pollDueTasks(now int64) {
+	ctx := context.TODO()
+	setting := operation_setting.GetTaskPollSetting()
+	dueTasks, err := model.GetDueTasks(now, max(setting.BatchSize, 1))
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("Get due tasks error: %v", err))
+		return
+	}
+
+	// 先占用渠道并发名额再领取租约，名额已满的任务留给下一轮或其他节点，避免租约在排队中过期
+	units := make([]*taskPollUnit, 0)
+	sunoUnits := make(map[int]*taskPollUnit)
+	nullTaskIds := make([]int64, 0)
+	for _, task := range dueTasks {
+		if _, ok := p.inflight.Load(task.ID); ok {
+			continue
+		}
+		if task.TaskID == "" {
+			// 统计失败的未完成任务
+			nullTaskIds = append(nullTaskIds, task.ID)
+			continue
+		}
+		if task.Platform == constant.TaskPlatformSuno {
+			if unit, ok := sunoUnits[task.ChannelId]; ok {
+				unit.ids = append(unit.ids, task.ID)
+				continue
+			}
+		}
+		sem := p.channelSemaphore(task.ChannelId)
+		select {
+		case sem <- struct{}{}:
+		default:
+			continue
+		}
+		unit := &taskPollUnit{platform: task.Platform, channelId: task.ChannelId, ids: []int64{task.ID}, sem: sem}
+		if task.Platform == constant.TaskPlatformSuno {
+			sunoUnits[task.ChannelId] = unit
+		}
+		units = append(units, unit)
+	}
+	if len(units) == 0 && len(nullTaskIds) == 0 {
+		return
+	}
+
+	claimIds := append([]int64{}, nullTaskIds...)
+	for _, unit := range units {
+		claimIds = append(claimIds, unit.ids...)
+	}
+	leaser := getTaskLeaser()
+	tasks, err := leaser.Claim(claimIds, now, now+int64(max(setting.LeaseSeconds, 10)))
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("Claim tasks error: %v", err))
+		for _, unit := range units {
+			<-unit.sem
+		}
+		return
+	}
+	if len(tasks) > 0 {
+		logger.LogInfo(ctx, fmt.Sprintf("节点 %s 领取到期任务: %d", taskPollerNodeId, len(tasks)))
+	}
+	claimed := make(map[int64]*model.Task, len(tasks))
+	for _, task := range tasks {
+		claimed[task.ID] = task
+	}
+
+	claimedNullIds := make([]int64, 0, len(nullTaskIds))
+	for _, id := range nullTaskIds {
+		if _, ok := claimed[id]; ok {
+			claimedNullIds = append(claimedNullIds, id)
+		}
+	}
+	if len(claimedNullIds) > 0 {
+		err := model.TaskBulkUpdateByID(claimedNullIds, map[string]any{
+			"status":      "FAILURE",
+			"progress":    "100%",
+			"lease_owner": "",
+			"lease_until": 0,
+		})
+		if err != nil {
+			logger.LogError(ctx, fmt.Sprintf("Fix null task_id task error: %v", err))
+		} else {
+			logger.LogInfo(ctx, fmt.Sprintf("Fix null task_id task success: %v", claimedNullIds))
+		}
+	}
+
+	for _, unit := range units {
+		unitTasks := make([]*model.Task, 0, len(unit.ids))
+		for _, id := range unit.ids {
+			if task, ok := claimed[id]; ok {
+				unitTasks = append(unitTasks, task)
+			}
+		}
+		if len(unitTasks) == 0 {
+			// 已被其他节点领取，归还名额
+			<-unit.sem
+			continue
+		}
+		p.dispatch(leaser, unit, unitTasks)
+	}
+}
+
+// dispatch 在已占用渠道并发名额的前提下查询任务，完成后归还名额并释放租约
+func (p *taskPoller) dispatch(leaser taskLeaser, unit *taskPollUnit, tasks []*model.Task) {
+	for _, task := range tasks {
+		p.inflight.Store(task.ID, struct{}{})
+	}
+	gopool.Go(func() {
+		defer func() { <-unit.sem }()
+
+		snapshots := make(map[int64]taskPollSnapshot, len(tasks))
+		taskIds := make([]string, 0, len(tasks))
+		taskM := make(map[string]*model.Task, len(tasks))
+		for _, task := range tasks {
+			snapshots[task.ID] = taskPollSnapshot{status: task.Status, progress: task.Progress}
+			taskIds = append(taskIds, task.TaskID)
+			taskM[task.TaskID] = task
+		}
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					common.SysError(fmt.Sprintf("task poller panic: %v", r))
+				}
+			}()
+			UpdateTaskByPlatform(unit.platform, map[int][]string{unit.channelId: taskIds}, taskM)
+		}()
+		now := time.Now().Unix()
+		for _, task := range tasks {
+			snapshot := snapshots[task.ID]
+			pollAttempts := task.PollAttempts + 1
+			if task.Status != snapshot.status || task.Progress != snapshot.progress {
+				// 有进展时恢复最短间隔
+				pollAttempts = 0
+			}
+			nextPollAt := now + operation_setting.GetTaskPollInterval(pollAttempts)
+			if err := leaser.Release(task, nextPollAt, pollAttempts); err != nil {
+				common.SysError(fmt.Sprintf("failed to release task %d lease: %s", task.ID, err.Error()))
+			}
+			p.inflight.Delete(task.ID)
+		}
+	})
+}
+
+// failTimeoutTasks 将超过截止时间仍未完成的任务置为失败并退还额度
+func failTimeoutTasks(now int64) {
+	setting := operation_setting.GetTaskPollSetting()
+	minTimeout := setting.TimeoutMinutes
+	for _, minutes := range setting.PlatformTimeoutMinutes {
+		if minutes > 0 && (minTimeout <= 0 || minutes < minTimeout) {
+			minTimeout = minutes
+		}
+	}
+	if minTimeout <= 0 {
+		return
+	}
+	tasks, err := model.GetTimeoutTasks(now-int64(minTimeout)*60, 100)
+	if err != nil {
+		common.SysError("failed to get timeout tasks: " + err.Error())
+		return
+	}
+	ctx := context.TODO()
+	for _, task := range tasks {
+		timeout := operation_setting.GetTaskTimeoutMinutes(string(task.Platform))
+		if timeout <= 0 || task.SubmitTime >= now-int64(timeout)*60 {
+			continue
+		}
+		reason := fmt.Sprintf("任务超时（超过 %d 分钟未完成）", timeout)
+		ok, err := model.FailTaskIfUnfinished(task.ID, reason, now)
+		if err != nil {
+			logger.LogError(ctx, fmt.Sprintf("Fail timeout task %s error: %v", task.TaskID, err))
+			continue
+		}
+		if !ok {
+			continue
+		}
+		logger.LogInfo(ctx, fmt.Sprintf("Task %s timeout after %d minutes", task.TaskID, timeout))
+		if task.Quota != 0 {
+			if err := model.IncreaseUserQuota(task.UserId, task.Quota, false); err != nil {
+				logger.LogError(ctx, "fail to increase user quota: "+err.Error())
+			} else {
+				logContent := fmt.Sprintf("异步任务超时 %s，补偿 %s", task.TaskID, logger.LogQuota(task.Quota))
+				model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
+			}
+		}
+		task.Status = model.TaskStatusFailure
+		task.Progress = "100%"
+		task.FailReason = reason
+		task.FinishTime = now
+		service.NotifyTaskCallback(task)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
@@ -15,6 +17,7 @@ import (
 	"github.com/QuantumNous/new-api/relay"
 	"github.com/QuantumNous/new-api/relay/channel"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 )
 
@@ -122,6 +125,7 @@ func updateVideoSingleTask(ctx context.Context, adaptor channel.TaskAdaptor, cha
 	shouldRefund := false
 	quota := task.Quota
 	preStatus := task.Status
+	preProgress := task.Progress
 
 	task.Status = model.TaskStatus(taskResult.Status)
 	switch taskResult.Status {
@@ -232,6 +236,10 @@ func updateVideoSingleTask(ctx context.Context, adaptor channel.TaskAdaptor, cha
 				}
 			}
 		}
+		// 按时长计费的任务按实际时长结算
+		if taskResult.Duration > 0 && preStatus != model.TaskStatusSuccess {
+			settleTaskDuration(ctx, task, taskResult.Duration)
+		}
 	case model.TaskStatusFailure:
 		logger.LogJson(ctx, fmt.Sprintf("Task %s failed", taskId), task)
 		task.Status = model.TaskStatusFailure
@@ -255,9 +263,20 @@ func updateVideoSingleTask(ctx context.Context, adaptor channel.TaskAdaptor, cha
 	if taskResult.Progress != "" {
 		task.Progress = taskResult.Progress
 	}
-	if err := task.Update(); err != nil {
+	updated, err := task.UpdateIfUnchanged(preStatus, preProgress)
+	if err != nil {
 		common.SysLog("UpdateVideoTask task error: " + err.Error())
 		shouldRefund = false
+	} else if !updated {
+		// 任务已被超时或取消流程结束，放弃本次轮询结果
+		logger.LogWarn(ctx, fmt.Sprintf("Task %s changed during polling, skip update", task.TaskID))
+		shouldRefund = false
+	} else if preStatus != task.Status {
+		service.NotifyTaskCallback(task)
+		// 任务已落库后再提交归档，避免归档结果被本次更新覆盖
+		if task.Status == model.TaskStatusSuccess && task.PrivateData.MediaObjectId == 0 {
+			archiveTaskVideo(ctx, channel, task, taskResult.Url)
+		}
 	}
 
 	if shouldRefund {
@@ -272,6 +291,67 @@ func updateVideoSingleTask(ctx context.Context, adaptor channel.TaskAdaptor, cha
 	return nil
 }
 
+// settleTaskDuration 按实际时长与预扣时长的差额多退少补
+func settleTaskDuration(ctx context.Context, task *model.Task, seconds float64) {
+	preConsumedQuota := task.Quota
+	preSeconds := task.Properties.BillingSeconds
+	actualQuota, actualSeconds := relay.TaskDurationQuota(task, preConsumedQuota, seconds)
+	quotaDelta := actualQuota - preConsumedQuota
+	if quotaDelta == 0 {
+		return
+	}
+	if quotaDelta > 0 {
+		if err := model.DecreaseUserQuota(task.UserId, quotaDelta); err != nil {
+			logger.LogError(ctx, fmt.Sprintf("补扣费失败: %s", err.Error()))
+			return
+		}
+	} else if err := model.IncreaseUserQuota(task.UserId, -quotaDelta, false); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("退还预扣费失败: %s", err.Error()))
+		return
+	}
+	// 补扣与退还都同步调整用户和渠道的已用额度，请求次数在提交时已计入
+	model.UpdateUserUsedQuota(task.UserId, quotaDelta)
+	model.UpdateChannelUsedQuota(task.ChannelId, quotaDelta)
+	task.Quota = actualQuota
+	task.Properties.BillingSeconds = actualSeconds
+	logContent := fmt.Sprintf("任务 %s 按实际时长结算，预扣时长 %.1f 秒，实际时长 %.1f 秒，预扣费 %s，实际扣费 %s",
+		task.TaskID, preSeconds, actualSeconds, logger.LogQuota(preConsumedQuota), logger.LogQuota(actualQuota))
+	logger.LogInfo(ctx, logContent)
+	model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
+}
+
+// archiveTaskVideo 将成功的任务结果提交到后台归档队列，失败时仅记录日志，不影响任务状态
+func archiveTaskVideo(ctx context.Context, channel *model.Channel, task *model.Task, resultUrl string) {
+	source := model.MediaSourceVideo
+	if task.Platform == constant.TaskPlatformMidjourney {
+		source = model.MediaSourceMidjourney
+	} else if task.Platform.IsAudio() {
+		source = model.MediaSourceAudio
+	}
+	if !service.IsMediaArchiveEnabled(source) {
+		return
+	}
+	archived := *task
+	service.EnqueueMediaArchive("task "+task.TaskID, func(archiveCtx context.Context) error {
+		fetchUrl := resultUrl
+		var header http.Header
+		if !strings.HasPrefix(resultUrl, "data:") {
+			var err error
+			// 部分渠道需请求上游获取下载地址，放在队列中执行
+			fetchUrl, header, err = resolveTaskVideoSource(channel, &archived)
+			if err != nil || fetchUrl == "" {
+				logger.LogWarn(ctx, fmt.Sprintf("Skip archiving task %s: cannot resolve result url", archived.TaskID))
+				return nil
+			}
+		}
+		object, err := service.ArchiveTaskResult(archiveCtx, &archived, source, fetchUrl, header, channel.GetSetting().Proxy)
+		if err != nil {
+			return err
+		}
+		return model.SetTaskMediaObjectId(archived.ID, object.Id)
+	})
+}
+
 func redactVideoResponseBody(body []byte) []byte {
 	var m map[string]any
 	if err := json.Unmarshal(body, &m); err != nil {
@@ -7,6 +7,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
 
 	"github.com/gin-gonic/gin"
 )
@@ -149,6 +150,10 @@ func AddToken(c *gin.Context) {
 		})
 		return
 	}
+	if err := service.ValidateTaskCallbackURL(token.CallbackUrl); err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	key, err := common.GenerateKey()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -158,6 +163,11 @@ func AddToken(c *gin.Context) {
 		common.SysLog("failed to generate token key: " + err.Error())
 		return
 	}
+	callbackSecret, err := model.GenerateTokenCallbackSecret()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	cleanToken := model.Token{
 		UserId:             c.GetInt("id"),
 		Name:               token.Name,
@@ -172,15 +182,22 @@ func AddToken(c *gin.Context) {
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
 		CrossGroupRetry:    token.CrossGroupRetry,
+		CallbackUrl:        token.CallbackUrl,
+		CallbackSecret:     callbackSecret,
+		HedgeEnabled:       token.HedgeEnabled,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	// 回调签名密钥仅在创建时返回一次
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
+		"data": gin.H{
+			"callback_secret": callbackSecret,
+		},
 	})
 	return
 }
@@ -216,6 +233,10 @@ func UpdateToken(c *gin.Context) {
 		})
 		return
 	}
+	if err := service.ValidateTaskCallbackURL(token.CallbackUrl); err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	cleanToken, err := model.GetTokenByIds(token.Id, userId)
 	if err != nil {
 		common.ApiError(c, err)
@@ -250,6 +271,8 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
 		cleanToken.CrossGroupRetry = token.CrossGroupRetry
+		cleanToken.CallbackUrl = token.CallbackUrl
+		cleanToken.HedgeEnabled = token.HedgeEnabled
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -264,6 +287,29 @@ func UpdateToken(c *gin.Context) {
 	return
 }
 
+// tokenWithCallbackSecret 创建令牌时附带返回回调签名密钥
+type tokenWithCallbackSecret struct {
+	*model.Token
+	CallbackSecret string `json:"callback_secret"`
+}
+
+// RotateTokenCallbackSecret 轮换令牌的回调签名密钥，新密钥仅在本次返回
+func RotateTokenCallbackSecret(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	secret, err := model.RotateTokenCallbackSecret(id, c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"callback_secret": secret,
+		},
+	})
+}
+
 type TokenBatch struct {
 	Ids []int `json:"ids"`
 }
@@ -306,6 +352,10 @@ func UpsertToken(c *gin.Context) {
 		})
 		return
 	}
+	if err := service.ValidateTaskCallbackURL(token.CallbackUrl); err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	
 	userId := c.GetInt("id")
 	isEdit := token.Id > 0
@@ -347,6 +397,7 @@ func UpsertToken(c *gin.Context) {
 			cleanToken.AllowIps = token.AllowIps
 			cleanToken.Group = token.Group
 			cleanToken.CrossGroupRetry = token.CrossGroupRetry
+			cleanToken.CallbackUrl = token.CallbackUrl
 		}
 		err = cleanToken.Update()
 		if err != nil {
@@ -370,6 +421,11 @@ func UpsertToken(c *gin.Context) {
 			common.SysLog("failed to generate token key: " + err.Error())
 			return
 		}
+		callbackSecret, err := model.GenerateTokenCallbackSecret()
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
 		cleanToken := model.Token{
 			UserId:             userId,
 			Name:               token.Name,
@@ -384,6 +440,8 @@ func UpsertToken(c *gin.Context) {
 			AllowIps:           token.AllowIps,
 			Group:              token.Group,
 			CrossGroupRetry:    token.CrossGroupRetry,
+			CallbackUrl:        token.CallbackUrl,
+			CallbackSecret:     callbackSecret,
 		}
 		err = cleanToken.Insert()
 		if err != nil {
@@ -393,7 +451,10 @@ func UpsertToken(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
 			"message": "",
-			"data":    cleanToken,
+			"data": tokenWithCallbackSecret{
+				Token:          &cleanToken,
+				CallbackSecret: callbackSecret,
+			},
 		})
 		return
 	}
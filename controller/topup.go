@@ -59,6 +59,9 @@ func GetTopUpInfo(c *gin.Context) {
 		"stripe_min_topup":    setting.StripeMinTopUp,
 		"amount_options":      operation_setting.GetPaymentSetting().AmountOptions,
 		"discount":            operation_setting.GetPaymentSetting().AmountDiscount,
+		"currencies":          operation_setting.GetCurrencySetting().Currencies,
+		"default_currency":    operation_setting.NormalizeCurrency(""),
+		"package_prices":      operation_setting.GetCurrencySetting().PackagePrices,
 	}
 	common.ApiSuccess(c, data)
 }
@@ -74,6 +77,7 @@ type EpayRequest struct {
 type AmountRequest struct {
 	Amount    int64  `json:"amount"`
 	TopUpCode string `json:"top_up_code"`
+	Currency  string `json:"currency"` // 报价币种，为空时使用人民币
 }
 
 func GetEpayClient() *epay.Client {
@@ -91,6 +95,11 @@ func GetEpayClient() *epay.Client {
 }
 
 func getPayMoney(amount int64, group string) float64 {
+	return getPayMoneyInCurrency(amount, group, operation_setting.CurrencyCNY)
+}
+
+// getPayMoneyInCurrency 计算指定币种的实付金额，优先使用该币种的套餐价格
+func getPayMoneyInCurrency(amount int64, group string, currency string) float64 {
 	dAmount := decimal.NewFromInt(amount)
 	// 充值金额以“展示类型”为准：
 	// - USD/CNY: 前端传 amount 为金额单位；TOKENS: 前端传 tokens，需要换成 USD 金额
@@ -105,7 +114,10 @@ func getPayMoney(amount int64, group string) float64 {
 	}
 
 	dTopupGroupRatio := decimal.NewFromFloat(topupGroupRatio)
-	dPrice := decimal.NewFromFloat(operation_setting.Price)
+	if packagePrice, ok := operation_setting.GetPackagePrice(currency, int(amount)); ok {
+		return decimal.NewFromFloat(packagePrice).Mul(dTopupGroupRatio).InexactFloat64()
+	}
+	dPrice := decimal.NewFromFloat(operation_setting.GetTopUpUnitPrice(currency))
 	// apply optional preset discount by the original request amount (if configured), default 1.0
 	discount := 1.0
 	if ds, ok := operation_setting.GetPaymentSetting().AmountDiscount[int(amount)]; ok {
@@ -171,17 +183,20 @@ func RequestEpay(c *gin.Context) {
 	if multiplier <= 0 {
 		multiplier = 1.0
 	}
-	actualAmount := req.ActualAmount
+	actualAmount := getTopUpActualAmount(user, req.Amount, req.ActualAmount)
 	bonusAmount := req.BonusAmount
 	
 	// 如果前端没有传递，则后端计算
-	if actualAmount <= 0 {
-		actualAmount = float64(req.Amount) * multiplier
-	}
 	if bonusAmount <= 0 {
 		bonusAmount = payMoney * (multiplier - 1)
 	}
 
+	campaignId, _, err := matchTopUpCampaign(id, req.TopUpCode, actualAmount)
+	if err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
+
 	callBackAddress := service.GetCallbackAddress()
 	returnUrl, _ := url.Parse(system_setting.ServerAddress + "/console/log")
 	notifyUrl, _ := url.Parse(callBackAddress + "/api/user/epay/notify")
@@ -221,6 +236,8 @@ func RequestEpay(c *gin.Context) {
 		PaymentMethod: req.PaymentMethod,
 		CreateTime:    time.Now().Unix(),
 		Status:        "pending",
+		CampaignId:    campaignId,
+		Currency:      operation_setting.CurrencyCNY,
 	}
 	err = topUp.Insert()
 	if err != nil {
@@ -327,12 +344,55 @@ func RequestAmount(c *gin.Context) {
 		c.JSON(200, gin.H{"message": "error", "data": "获取用户分组失败"})
 		return
 	}
-	payMoney := getPayMoney(req.Amount, group)
+	currency := operation_setting.CurrencyCNY
+	if req.Currency != "" {
+		currency = operation_setting.NormalizeCurrency(req.Currency)
+		if !operation_setting.IsSupportedCurrency(currency) {
+			c.JSON(200, gin.H{"message": "error", "data": "不支持的币种"})
+			return
+		}
+	}
+	payMoney := getPayMoneyInCurrency(req.Amount, group, currency)
 	if payMoney <= 0.01 {
 		c.JSON(200, gin.H{"message": "error", "data": "充值金额过低"})
 		return
 	}
-	c.JSON(200, gin.H{"message": "success", "data": strconv.FormatFloat(payMoney, 'f', 2, 64)})
+	user, err := model.GetUserById(id, false)
+	if err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": "获取用户信息失败"})
+		return
+	}
+	_, campaignBonus, err := matchTopUpCampaign(id, req.TopUpCode, getTopUpActualAmount(user, req.Amount, 0))
+	if err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"message": "success", "data": strconv.FormatFloat(payMoney, 'f', 2, 64), "currency": currency, "campaign_bonus": campaignBonus})
+}
+
+// getTopUpActualAmount 计算订单实际到账金额（美元，已应用用户充值倍率），前端已传递时以前端为准；
+// 金额预览与下单使用同一金额匹配充值活动
+func getTopUpActualAmount(user *model.User, amount int64, requested float64) float64 {
+	if requested > 0 {
+		return requested
+	}
+	multiplier := user.TopupMultiplier
+	if multiplier <= 0 {
+		multiplier = 1.0
+	}
+	return float64(amount) * multiplier
+}
+
+// matchTopUpCampaign 匹配充值活动，返回活动 id 与预计赠送金额（美元），未匹配时 id 为 0
+func matchTopUpCampaign(userId int, code string, amount float64) (int, float64, error) {
+	campaign, bonus, err := model.FindTopUpCampaign(userId, code, amount)
+	if err != nil {
+		return 0, 0, err
+	}
+	if campaign == nil {
+		return 0, 0, nil
+	}
+	return campaign.Id, bonus, nil
 }
 
 func GetUserTopUps(c *gin.Context) {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
@@ -33,6 +34,20 @@ type StripePayRequest struct {
 	PaymentMethod string  `json:"payment_method"` // 支付方式
 	ActualAmount  float64 `json:"actual_amount"` // 实际到账金额（美元，已应用倍率）
 	BonusAmount   float64 `json:"bonus_amount"`   // 赠送金额（人民币）
+	TopUpCode     string  `json:"top_up_code"`    // 优惠码
+	Currency      string  `json:"currency"`       // 结算币种，为空时使用 StripePriceId 定价
+}
+
+// resolveStripeCurrency 校验并返回请求的结算币种，为空表示使用 StripePriceId
+func resolveStripeCurrency(currency string) (string, error) {
+	if currency == "" {
+		return "", nil
+	}
+	currency = operation_setting.NormalizeCurrency(currency)
+	if !operation_setting.IsSupportedCurrency(currency) {
+		return "", fmt.Errorf("不支持的币种: %s", currency)
+	}
+	return currency, nil
 }
 
 type StripeAdaptor struct {
@@ -49,12 +64,30 @@ func (*StripeAdaptor) RequestAmount(c *gin.Context, req *StripePayRequest) {
 		c.JSON(200, gin.H{"message": "error", "data": "获取用户分组失败"})
 		return
 	}
+	currency, err := resolveStripeCurrency(req.Currency)
+	if err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
 	payMoney := getStripePayMoney(float64(req.Amount), group)
+	if currency != "" {
+		payMoney = getPayMoneyInCurrency(req.Amount, group, currency)
+	}
 	if payMoney <= 0.01 {
 		c.JSON(200, gin.H{"message": "error", "data": "充值金额过低"})
 		return
 	}
-	c.JSON(200, gin.H{"message": "success", "data": strconv.FormatFloat(payMoney, 'f', 2, 64)})
+	user, err := model.GetUserById(id, false)
+	if err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": "获取用户信息失败"})
+		return
+	}
+	_, campaignBonus, err := matchTopUpCampaign(id, req.TopUpCode, getTopUpActualAmount(user, req.Amount, req.ActualAmount))
+	if err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"message": "success", "data": strconv.FormatFloat(payMoney, 'f', 2, 64), "currency": currency, "campaign_bonus": campaignBonus})
 }
 
 func (*StripeAdaptor) RequestPay(c *gin.Context, req *StripePayRequest) {
@@ -71,30 +104,42 @@ func (*StripeAdaptor) RequestPay(c *gin.Context, req *StripePayRequest) {
 		return
 	}
 
+	currency, err := resolveStripeCurrency(req.Currency)
+	if err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
+
 	id := c.GetInt("id")
 	user, _ := model.GetUserById(id, false)
 	chargedMoney := GetChargedAmount(float64(req.Amount), *user)
+	if currency != "" {
+		chargedMoney = getPayMoneyInCurrency(req.Amount, user.Group, currency)
+	}
 
 	// 计算实际到账金额和赠送金额
 	multiplier := user.TopupMultiplier
 	if multiplier <= 0 {
 		multiplier = 1.0
 	}
-	actualAmount := req.ActualAmount
+	actualAmount := getTopUpActualAmount(user, req.Amount, req.ActualAmount)
 	bonusAmount := req.BonusAmount
 	
 	// 如果前端没有传递，则后端计算
-	if actualAmount <= 0 {
-		actualAmount = float64(req.Amount) * multiplier
-	}
 	if bonusAmount <= 0 {
 		bonusAmount = chargedMoney * (multiplier - 1)
 	}
 
+	campaignId, _, err := matchTopUpCampaign(id, req.TopUpCode, actualAmount)
+	if err != nil {
+		c.JSON(200, gin.H{"message": "error", "data": err.Error()})
+		return
+	}
+
 	reference := fmt.Sprintf("new-api-ref-%d-%d-%s", user.Id, time.Now().UnixMilli(), randstr.String(4))
 	referenceId := "ref_" + common.Sha1([]byte(reference))
 
-	payLink, err := genStripeLink(referenceId, user.StripeCustomer, user.Email, req.Amount)
+	payLink, err := genStripeLink(referenceId, user.StripeCustomer, user.Email, req.Amount, currency, chargedMoney)
 	if err != nil {
 		log.Println("获取Stripe Checkout支付链接失败", err)
 		c.JSON(200, gin.H{"message": "error", "data": "拉起支付失败"})
@@ -111,6 +156,8 @@ func (*StripeAdaptor) RequestPay(c *gin.Context, req *StripePayRequest) {
 		PaymentMethod: PaymentMethodStripe,
 		CreateTime:    time.Now().Unix(),
 		Status:        common.TopUpStatusPending,
+		CampaignId:    campaignId,
+		Currency:      currency,
 	}
 	err = topUp.Insert()
 	if err != nil {
@@ -230,7 +277,8 @@ func sessionExpired(event stripe.Event) {
 	log.Println("充值订单已过期", referenceId)
 }
 
-func genStripeLink(referenceId string, customerId string, email string, amount int64) (string, error) {
+// genStripeLink 生成 Stripe Checkout 链接，指定 currency 时按 payMoney 动态定价，否则使用 StripePriceId
+func genStripeLink(referenceId string, customerId string, email string, amount int64, currency string, payMoney float64) (string, error) {
 	if !strings.HasPrefix(setting.StripeApiSecret, "sk_") && !strings.HasPrefix(setting.StripeApiSecret, "rk_") {
 		return "", fmt.Errorf("无效的Stripe API密钥")
 	}
@@ -251,6 +299,21 @@ func genStripeLink(referenceId string, customerId string, email string, amount i
 		AllowPromotionCodes: stripe.Bool(setting.StripePromotionCodesEnabled),
 	}
 
+	if currency != "" {
+		params.LineItems = []*stripe.CheckoutSessionLineItemParams{
+			{
+				PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+					Currency:   stripe.String(strings.ToLower(currency)),
+					UnitAmount: stripe.Int64(int64(math.Round(payMoney * 100))),
+					ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+						Name: stripe.String(fmt.Sprintf("TUC%d", amount)),
+					},
+				},
+				Quantity: stripe.Int64(1),
+			},
+		}
+	}
+
 	if "" == customerId {
 		if "" != email {
 			params.CustomerEmail = stripe.String(email)
@@ -15,6 +15,7 @@ import (
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 
 	"github.com/QuantumNous/new-api/constant"
 
@@ -469,6 +470,8 @@ func GetSelf(c *gin.Context) {
 		"wechat_id":          user.WeChatId,
 		"telegram_id":        user.TelegramId,
 		"topup_multiplier":   user.TopupMultiplier,
+		"credit_limit":       user.CreditLimit,
+		"credit_suspended":   user.CreditSuspended,
 		"total_bonus_amount": user.TotalBonusAmount,
 		"total_topup_amount": totalTopupAmount, // 累计充值金额
 		"group":              user.Group,
@@ -489,6 +492,14 @@ func GetSelf(c *gin.Context) {
 		"permissions":        permissions,                // 新增权限字段
 	}
 
+	// 按用户展示币种换算余额
+	displayCurrency := service.ResolveDisplayCurrency(userSetting)
+	responseData["display_currency"] = displayCurrency
+	responseData["currency_symbol"] = operation_setting.GetCurrencySymbolByCode(displayCurrency)
+	responseData["currency_rate"] = operation_setting.GetExchangeRate(displayCurrency)
+	responseData["quota_amount"] = service.QuotaToCurrencyAmount(user.Quota, displayCurrency)
+	responseData["used_quota_amount"] = service.QuotaToCurrencyAmount(user.UsedQuota, displayCurrency)
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -665,6 +676,9 @@ func UpdateUser(c *gin.Context) {
 	if originUser.Quota != updatedUser.Quota {
 		model.RecordLog(originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户额度从 %s修改为 %s", logger.LogQuota(originUser.Quota), logger.LogQuota(updatedUser.Quota)))
 	}
+	if originUser.CreditLimit != updatedUser.CreditLimit {
+		model.RecordLog(originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户信用额度从 %s修改为 %s", logger.LogQuota(originUser.CreditLimit), logger.LogQuota(updatedUser.CreditLimit)))
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -1183,6 +1197,7 @@ type UpdateUserSettingRequest struct {
 	GotifyPriority             int     `json:"gotify_priority,omitempty"`
 	AcceptUnsetModelRatioModel bool    `json:"accept_unset_model_ratio_model"`
 	RecordIpLog                bool    `json:"record_ip_log"`
+	DisplayCurrency            string  `json:"display_currency,omitempty"`
 }
 
 func UpdateUserSetting(c *gin.Context) {
@@ -1305,6 +1320,18 @@ func UpdateUserSetting(c *gin.Context) {
 		}
 	}
 
+	// 验证展示币种
+	if req.DisplayCurrency != "" {
+		req.DisplayCurrency = operation_setting.NormalizeCurrency(req.DisplayCurrency)
+		if !operation_setting.IsSupportedCurrency(req.DisplayCurrency) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "不支持的展示币种",
+			})
+			return
+		}
+	}
+
 	userId := c.GetInt("id")
 	user, err := model.GetUserById(userId, true)
 	if err != nil {
@@ -1318,6 +1345,11 @@ func UpdateUserSetting(c *gin.Context) {
 		QuotaWarningThreshold: req.QuotaWarningThreshold,
 		AcceptUnsetRatioModel: req.AcceptUnsetModelRatioModel,
 		RecordIpLog:           req.RecordIpLog,
+		DisplayCurrency:       req.DisplayCurrency,
+	}
+	// 未传展示币种时保留原设置
+	if settings.DisplayCurrency == "" {
+		settings.DisplayCurrency = user.GetSetting().DisplayCurrency
 	}
 
 	// 如果是webhook类型,添加webhook相关设置
@@ -71,12 +71,15 @@ func VideoProxy(c *gin.Context) {
 		})
 		return
 	}
-	baseURL := channel.GetBaseURL()
-	if baseURL == "" {
-		baseURL = "https://api.openai.com"
+	if task.PrivateData.MediaObjectId > 0 {
+		if object, err := model.GetMediaObjectById(task.PrivateData.MediaObjectId); err == nil {
+			if err := service.ServeMediaObject(c.Writer, c.Request, object); err == nil {
+				return
+			}
+			logger.LogWarn(c.Request.Context(), fmt.Sprintf("Failed to serve archived video for task %s, fallback to upstream", taskID))
+		}
 	}
 
-	var videoURL string
 	proxy := channel.GetSetting().Proxy
 	client, err := service.GetHttpClientWithProxy(proxy)
 	if err != nil {
@@ -104,39 +107,18 @@ func VideoProxy(c *gin.Context) {
 		return
 	}
 
-	switch channel.Type {
-	case constant.ChannelTypeGemini:
-		apiKey := task.PrivateData.Key
-		if apiKey == "" {
-			logger.LogError(c.Request.Context(), fmt.Sprintf("Missing stored API key for Gemini task %s", taskID))
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": gin.H{
-					"message": "API key not stored for task",
-					"type":    "server_error",
-				},
-			})
-			return
-		}
-
-		videoURL, err = getGeminiVideoURL(channel, task, apiKey)
-		if err != nil {
-			logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to resolve Gemini video URL for task %s: %s", taskID, err.Error()))
-			c.JSON(http.StatusBadGateway, gin.H{
-				"error": gin.H{
-					"message": "Failed to resolve Gemini video URL",
-					"type":    "server_error",
-				},
-			})
-			return
-		}
-		req.Header.Set("x-goog-api-key", apiKey)
-	case constant.ChannelTypeOpenAI, constant.ChannelTypeSora:
-		videoURL = fmt.Sprintf("%s/v1/videos/%s/content", baseURL, task.TaskID)
-		req.Header.Set("Authorization", "Bearer "+channel.Key)
-	default:
-		// Video URL is directly in task.FailReason
-		videoURL = task.FailReason
+	videoURL, header, err := resolveTaskVideoSource(channel, task)
+	if err != nil {
+		logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to resolve video URL for task %s: %s", taskID, err.Error()))
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": gin.H{
+				"message": "Failed to resolve video URL",
+				"type":    "server_error",
+			},
+		})
+		return
 	}
+	req.Header = header
 
 	req.URL, err = url.Parse(videoURL)
 	if err != nil {
@@ -187,3 +169,31 @@ func VideoProxy(c *gin.Context) {
 		logger.LogError(c.Request.Context(), fmt.Sprintf("Failed to stream video content: %s", err.Error()))
 	}
 }
+
+// resolveTaskVideoSource 返回任务结果视频的上游地址及访问所需的请求头
+func resolveTaskVideoSource(channel *model.Channel, task *model.Task) (string, http.Header, error) {
+	header := http.Header{}
+	switch channel.Type {
+	case constant.ChannelTypeGemini:
+		apiKey := task.PrivateData.Key
+		if apiKey == "" {
+			return "", nil, fmt.Errorf("api key not stored for task")
+		}
+		videoURL, err := getGeminiVideoURL(channel, task, apiKey)
+		if err != nil {
+			return "", nil, err
+		}
+		header.Set("x-goog-api-key", apiKey)
+		return videoURL, header, nil
+	case constant.ChannelTypeOpenAI, constant.ChannelTypeSora:
+		baseURL := channel.GetBaseURL()
+		if baseURL == "" {
+			baseURL = "https://api.openai.com"
+		}
+		header.Set("Authorization", "Bearer "+channel.Key)
+		return fmt.Sprintf("%s/v1/videos/%s/content", baseURL, task.TaskID), header, nil
+	default:
+		// Video URL is directly in task.FailReason
+		return task.FailReason, header, nil
+	}
+}
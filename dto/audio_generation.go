@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"strconv"
+	"strings"
+)
+
+// AudioGeneration 统一音频生成任务响应（/v1/audio/generations），状态取值与视频任务一致
+type AudioGeneration struct {
+	ID          string            `json:"id"`
+	Object      string            `json:"object"`
+	Model       string            `json:"model"`
+	Type        string            `json:"type"` // song、sound_effect、speech
+	Status      string            `json:"status"`
+	Progress    int               `json:"progress"`
+	CreatedAt   int64             `json:"created_at"`
+	CompletedAt int64             `json:"completed_at,omitempty"`
+	Url         string            `json:"url,omitempty"`
+	Error       *OpenAIVideoError `json:"error,omitempty"`
+}
+
+func (m *AudioGeneration) SetProgressStr(progress string) {
+	progress = strings.TrimSuffix(progress, "%")
+	m.Progress, _ = strconv.Atoi(progress)
+}
+
+func NewAudioGeneration() *AudioGeneration {
+	return &AudioGeneration{
+		Object: "audio.generation",
+	}
+}
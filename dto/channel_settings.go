@@ -1,13 +1,69 @@
 package dto
 
 type ChannelSettings struct {
-	ForceFormat            bool   `json:"force_format,omitempty"`
-	ThinkingToContent      bool   `json:"thinking_to_content,omitempty"`
-	Proxy                  string `json:"proxy"`
-	PassThroughBodyEnabled bool   `json:"pass_through_body_enabled,omitempty"`
-	SystemPrompt           string `json:"system_prompt,omitempty"`
-	SystemPromptOverride   bool   `json:"system_prompt_override,omitempty"`
-	AllowAllRetry          bool   `json:"allow_all_retry,omitempty"` // 是否允许全重试（跳过重试判断）
+	ForceFormat            bool               `json:"force_format,omitempty"`
+	ThinkingToContent      bool               `json:"thinking_to_content,omitempty"`
+	Proxy                  string             `json:"proxy"`
+	PassThroughBodyEnabled bool               `json:"pass_through_body_enabled,omitempty"`
+	SystemPrompt           string             `json:"system_prompt,omitempty"`
+	SystemPromptOverride   bool               `json:"system_prompt_override,omitempty"`
+	AllowAllRetry          bool               `json:"allow_all_retry,omitempty"` // 是否允许全重试（跳过重试判断）
+	CostRatio              float64            `json:"cost_ratio,omitempty"`      // 渠道成本倍率，用于按成本路由，0 视为 1
+	Schedule               *ChannelSchedule   `json:"schedule,omitempty"`        // 定时计划
+	ErrorRules             []ChannelErrorRule `json:"error_rules,omitempty"`     // 错误映射规则，按顺序匹配第一条
+}
+
+// 错误映射规则的禁用动作
+const (
+	ChannelErrorActionNone           = "none"            // 不禁用
+	ChannelErrorActionDisableKey     = "disable_key"     // 禁用当前 Key，单 Key 渠道即禁用渠道
+	ChannelErrorActionDisableChannel = "disable_channel" // 禁用整个渠道
+)
+
+// ChannelErrorRule 渠道错误映射规则：按上游状态码、错误码与错误体内容匹配，
+// 决定返回的状态码与错误码、是否重试以及是否禁用 Key 或渠道
+type ChannelErrorRule struct {
+	Name        string              `json:"name,omitempty"`
+	StatusCodes []int               `json:"status_codes,omitempty"` // 上游原始状态码，为空匹配任意
+	ErrorCodes  []string            `json:"error_codes,omitempty"`  // 解析后的错误码，为空匹配任意
+	ErrorTypes  []string            `json:"error_types,omitempty"`  // 解析后的错误类型，为空匹配任意
+	Match       []ChannelErrorMatch `json:"match,omitempty"`        // 错误体条件，需全部满足
+	// 命中后的处理
+	StatusCode     int    `json:"status_code,omitempty"`     // 改写返回的状态码
+	ErrorCode      string `json:"error_code,omitempty"`      // 改写为规范化的错误码
+	Retry          *bool  `json:"retry,omitempty"`           // 是否重试，为空按默认规则
+	Action         string `json:"action,omitempty"`          // none / disable_key / disable_channel，为空按默认规则
+	DisableSeconds int    `json:"disable_seconds,omitempty"` // 禁用时长，0 表示直到手动或测试后启用
+}
+
+// ChannelErrorMatch 错误体条件：Path 为 JSON 路径（gjson 语法，如 error.code），为空时匹配整个错误体；
+// Regex 为空时只要求路径存在
+type ChannelErrorMatch struct {
+	Path  string `json:"path,omitempty"`
+	Regex string `json:"regex,omitempty"`
+}
+
+// 定时计划时段的动作
+const (
+	ChannelScheduleActionDisable  = "disable"  // 时段内停用（如上游维护）
+	ChannelScheduleActionEnable   = "enable"   // 仅在此类时段内启用（如夜间低价渠道）
+	ChannelScheduleActionOverride = "override" // 时段内覆盖优先级与权重
+)
+
+// ChannelSchedule 渠道定时计划，按时区内的星期与时刻匹配时段
+type ChannelSchedule struct {
+	Timezone string                  `json:"timezone,omitempty"` // IANA 时区，如 Asia/Shanghai，为空使用服务器时区
+	Windows  []ChannelScheduleWindow `json:"windows"`
+}
+
+type ChannelScheduleWindow struct {
+	Action   string `json:"action"`
+	Weekdays []int  `json:"weekdays,omitempty"` // 0 为周日，为空表示每天；跨天时段按开始时刻所在的星期匹配
+	Start    string `json:"start"`              // HH:MM
+	End      string `json:"end"`                // HH:MM，不晚于 Start 时表示跨天
+	Priority *int64 `json:"priority,omitempty"` // override 时段的优先级
+	Weight   *uint  `json:"weight,omitempty"`   // override 时段的权重
+	Remark   string `json:"remark,omitempty"`
 }
 
 type VertexKeyType string
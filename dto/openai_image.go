@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -26,8 +27,8 @@ type ImageRequest struct {
 	OutputFormat      json.RawMessage `json:"output_format,omitempty"`
 	OutputCompression json.RawMessage `json:"output_compression,omitempty"`
 	PartialImages     json.RawMessage `json:"partial_images,omitempty"`
-	// Stream            bool            `json:"stream,omitempty"`
-	Watermark *bool `json:"watermark,omitempty"`
+	Stream            *bool           `json:"stream,omitempty"`
+	Watermark         *bool           `json:"watermark,omitempty"`
 	// zhipu 4v
 	WatermarkEnabled json.RawMessage `json:"watermark_enabled,omitempty"`
 	UserId           json.RawMessage `json:"user_id,omitempty"`
@@ -148,6 +149,12 @@ func (i *ImageRequest) GetTokenCountMeta() *types.TokenCountMeta {
 		}
 	}
 
+	// 配置了尺寸、品质倍率的模型按配置计费，优先于上面的 dall-e 默认规则
+	if ratio, ok := ratio_setting.GetImageSizeQualityRatio(i.Model, i.Size, i.Quality); ok {
+		sizeRatio = ratio
+		qualityRatio = 1
+	}
+
 	// not support token count for dalle
 	return &types.TokenCountMeta{
 		CombineText:     i.Prompt,
@@ -157,7 +164,7 @@ func (i *ImageRequest) GetTokenCountMeta() *types.TokenCountMeta {
 }
 
 func (i *ImageRequest) IsStream(c *gin.Context) bool {
-	return false
+	return i.Stream != nil && *i.Stream
 }
 
 func (i *ImageRequest) SetModelName(modelName string) {
@@ -176,3 +183,33 @@ type ImageData struct {
 	B64Json       string `json:"b64_json"`
 	RevisedPrompt string `json:"revised_prompt"`
 }
+
+const (
+	ImageGenerationPartialImage = "image_generation.partial_image"
+	ImageGenerationCompleted    = "image_generation.completed"
+	ImageEditPartialImage       = "image_edit.partial_image"
+	ImageEditCompleted          = "image_edit.completed"
+)
+
+// ImageStreamEvent 图像生成/编辑接口的流式事件
+type ImageStreamEvent struct {
+	Type              string `json:"type"`
+	B64Json           string `json:"b64_json,omitempty"`
+	Url               string `json:"url,omitempty"` // 非 OpenAI 渠道转换时，上游仅返回链接
+	RevisedPrompt     string `json:"revised_prompt,omitempty"`
+	CreatedAt         int64  `json:"created_at,omitempty"`
+	Size              string `json:"size,omitempty"`
+	Quality           string `json:"quality,omitempty"`
+	Background        string `json:"background,omitempty"`
+	OutputFormat      string `json:"output_format,omitempty"`
+	PartialImageIndex *int   `json:"partial_image_index,omitempty"`
+	Usage             *Usage `json:"usage,omitempty"`
+}
+
+func (e *ImageStreamEvent) IsPartialImage() bool {
+	return e.Type == ImageGenerationPartialImage || e.Type == ImageEditPartialImage
+}
+
+func (e *ImageStreamEvent) IsCompleted() bool {
+	return e.Type == ImageGenerationCompleted || e.Type == ImageEditCompleted
+}
@@ -361,6 +361,8 @@ const (
 const (
 	ResponsesOutputTypeItemAdded = "response.output_item.added"
 	ResponsesOutputTypeItemDone  = "response.output_item.done"
+
+	ResponsesOutputTypeImageGenerationPartialImage = "response.image_generation_call.partial_image"
 )
 
 // ResponsesStreamResponse 用于处理 /v1/responses 流式响应
@@ -19,6 +19,9 @@ const (
 	RealtimeEventResponseFunctionCallArgumentsDelta = "response.function_call_arguments.delta"
 	RealtimeEventResponseFunctionCallArgumentsDone  = "response.function_call_arguments.done"
 	RealtimeEventConversationItemCreated            = "conversation.item.created"
+	RealtimeEventResponseAudioTranscriptDone        = "response.audio_transcript.done"
+	RealtimeEventResponseTextDone                   = "response.text.done"
+	RealtimeEventInputAudioTranscriptionCompleted   = "conversation.item.input_audio_transcription.completed"
 )
 
 type RealtimeEvent struct {
@@ -31,6 +34,10 @@ type RealtimeEvent struct {
 	Response *RealtimeResponse  `json:"response,omitempty"`
 	Delta    string             `json:"delta,omitempty"`
 	Audio    string             `json:"audio,omitempty"`
+	// Transcript 转写完成事件中的完整文本
+	Transcript string `json:"transcript,omitempty"`
+	// Text 文本响应完成事件中的完整文本
+	Text string `json:"text,omitempty"`
 }
 
 type RealtimeResponse struct {
@@ -19,6 +19,7 @@ import (
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/router"
 	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 
 	"github.com/bytedance/gopkg/util/gopool"
@@ -86,9 +87,15 @@ func main() {
 		go model.SyncChannelCache(common.SyncFrequency)
 	}
 
+	// 渠道定时计划
+	go model.SyncChannelSchedules()
+
 	// 热更新配置
 	go model.SyncOptions(common.SyncFrequency)
 
+	// 营销活动缓存与统计
+	go model.SyncCampaigns(common.SyncFrequency)
+
 	// 数据看板
 	go model.UpdateQuotaData()
 
@@ -101,15 +108,49 @@ func main() {
 	}
 
 	go controller.AutomaticallyTestChannels()
+	go controller.AutomaticallyProbeModelSubstitution()
 
 	if common.IsMasterNode && constant.UpdateTask {
 		gopool.Go(func() {
 			controller.UpdateMidjourneyTaskBulk()
 		})
+	}
+	// 启用统一任务框架后，将旧 Midjourney 任务迁移到 tasks 表
+	if common.IsMasterNode && setting.MjTaskAdaptorEnabled {
+		gopool.Go(controller.RunMidjourneyTaskMigration)
+	}
+	if constant.UpdateTask {
+		// 非主节点仅在开启分布式轮询时参与
 		gopool.Go(func() {
 			controller.UpdateTaskBulk()
 		})
 	}
+
+	// 渠道冷却到期自动恢复
+	if common.IsMasterNode {
+		go service.AutomaticallyReinstateChannelCooldowns()
+	}
+
+	// 后付费用户月度账单
+	if common.IsMasterNode {
+		go service.AutomaticallyProcessStatements()
+	}
+
+	// 定时刷新多币种汇率
+	if common.IsMasterNode {
+		go service.AutomaticallyUpdateExchangeRates()
+	}
+
+	// 异步任务回调重试
+	if common.IsMasterNode {
+		go service.AutomaticallyRetryTaskCallbacks()
+	}
+
+	// 清理过期的归档文件
+	if common.IsMasterNode {
+		go service.AutomaticallyCleanMediaObjects()
+	}
+
 	if os.Getenv("BATCH_UPDATE_ENABLED") == "true" {
 		common.BatchUpdateEnabled = true
 		common.SysLog("batch update enabled with interval " + strconv.Itoa(common.BatchUpdateInterval) + "s")
@@ -381,6 +381,9 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 	}
 	common.SetContextKey(c, constant.ContextKeyTokenGroup, token.Group)
 	common.SetContextKey(c, constant.ContextKeyTokenCrossGroupRetry, token.CrossGroupRetry)
+	common.SetContextKey(c, constant.ContextKeyTokenCallbackUrl, token.CallbackUrl)
+	common.SetContextKey(c, constant.ContextKeyTokenCallbackSecret, token.CallbackSecret)
+	common.SetContextKey(c, constant.ContextKeyTokenHedgeEnabled, token.HedgeEnabled)
 	if len(parts) > 1 {
 		if model.IsAdmin(token.UserId) {
 			c.Set("specific_channel_id", parts[1])
@@ -497,6 +500,9 @@ func TokenAuthForAPI() func(c *gin.Context) {
 		}
 		common.SetContextKey(c, constant.ContextKeyTokenGroup, token.Group)
 		common.SetContextKey(c, constant.ContextKeyTokenCrossGroupRetry, token.CrossGroupRetry)
+		common.SetContextKey(c, constant.ContextKeyTokenCallbackUrl, token.CallbackUrl)
+		common.SetContextKey(c, constant.ContextKeyTokenCallbackSecret, token.CallbackSecret)
+		common.SetContextKey(c, constant.ContextKeyTokenHedgeEnabled, token.HedgeEnabled)
 
 		// 处理分组逻辑（复用 TokenAuth 的逻辑）
 		userGroup := userCache.Group
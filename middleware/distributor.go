@@ -15,6 +15,7 @@ import (
 	"github.com/QuantumNous/new-api/model"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/QuantumNous/new-api/types"
 
@@ -126,6 +127,14 @@ func Distribute() func(c *gin.Context) {
 					// 使用随机调度
 					channel, selectGroup, err = service.CacheGetRandomSatisfiedChannel(retryParam)
 				}
+				if (err != nil || channel == nil) && canDeferToModelFallback(c, usingGroup, modelRequest.Model) {
+					// 当前模型无可用渠道但配置了跨模型兜底，交由 controller.Relay 的重试流程改用兜底模型
+					common.SysLog(fmt.Sprintf("[Distribute中间件] 模型 %s 无可用渠道，交由跨模型兜底处理", modelRequest.Model))
+					common.SetContextKey(c, constant.ContextKeyRequestStartTime, time.Now())
+					c.Set("original_model", modelRequest.Model)
+					c.Next()
+					return
+				}
 				if err != nil {
 					showGroup := usingGroup
 					if usingGroup == "auto" {
@@ -197,6 +206,7 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 			}
 			modelRequest.Model = midjourneyModel
 		}
+		c.Set("platform", string(constant.TaskPlatformMidjourney))
 		c.Set("relay_mode", relayMode)
 	} else if strings.Contains(c.Request.URL.Path, "/suno/") {
 		relayMode := relayconstant.Path2RelaySuno(c.Request.Method, c.Request.URL.Path)
@@ -250,6 +260,20 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 		if _, ok := c.Get("relay_mode"); !ok {
 			c.Set("relay_mode", relayMode)
 		}
+	} else if strings.HasPrefix(c.Request.URL.Path, "/v1/audio/generations") {
+		relayMode := relayconstant.RelayModeUnknown
+		if c.Request.Method == http.MethodPost {
+			req, err := getModelFromRequest(c)
+			if err != nil {
+				return nil, false, err
+			}
+			modelRequest.Model = req.Model
+			relayMode = relayconstant.RelayModeAudioGenerationSubmit
+		} else if c.Request.Method == http.MethodGet {
+			relayMode = relayconstant.RelayModeAudioGenerationFetchByID
+			shouldSelectChannel = false
+		}
+		c.Set("relay_mode", relayMode)
 	} else if strings.HasPrefix(c.Request.URL.Path, "/v1beta/models/") || strings.HasPrefix(c.Request.URL.Path, "/v1/models/") {
 		// Gemini API 路径处理: /v1beta/models/gemini-2.0-flash:generateContent
 		relayMode := relayconstant.RelayModeGemini
@@ -281,7 +305,7 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 	}
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/images/generations") {
 		modelRequest.Model = common.GetStringIfEmpty(modelRequest.Model, "dall-e")
-	} else if strings.HasPrefix(c.Request.URL.Path, "/v1/images/edits") {
+	} else if strings.HasPrefix(c.Request.URL.Path, "/v1/images/edits") || strings.HasPrefix(c.Request.URL.Path, "/v1/images/variations") {
 		//modelRequest.Model = common.GetStringIfEmpty(c.PostForm("model"), "gpt-image-1")
 		contentType := c.ContentType()
 		if slices.Contains([]string{gin.MIMEPOSTForm, gin.MIMEMultipartPOSTForm}, contentType) {
@@ -290,8 +314,11 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 				modelRequest.Model = req.Model
 			}
 		}
+		if strings.HasPrefix(c.Request.URL.Path, "/v1/images/variations") {
+			modelRequest.Model = common.GetStringIfEmpty(modelRequest.Model, "dall-e-2")
+		}
 	}
-	if strings.HasPrefix(c.Request.URL.Path, "/v1/audio") {
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/audio") && !strings.HasPrefix(c.Request.URL.Path, "/v1/audio/generations") {
 		relayMode := relayconstant.RelayModeAudioSpeech
 		if strings.HasPrefix(c.Request.URL.Path, "/v1/audio/speech") {
 
@@ -412,3 +439,21 @@ func extractModelNameFromGeminiPath(path string) string {
 	// 返回模型名部分
 	return path[startIndex : startIndex+colonIndex]
 }
+
+// canDeferToModelFallback 仅同步中继请求支持跨模型兜底，任务类与实时请求仍在此处直接返回错误
+func canDeferToModelFallback(c *gin.Context, group string, modelName string) bool {
+	if _, ok := c.Get("platform"); ok {
+		return false
+	}
+	if relayMode, ok := c.Get("relay_mode"); ok {
+		switch relayMode {
+		case relayconstant.RelayModeVideoSubmit, relayconstant.RelayModeVideoFetchByID,
+			relayconstant.RelayModeAudioGenerationSubmit, relayconstant.RelayModeAudioGenerationFetchByID:
+			return false
+		}
+	}
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/realtime") {
+		return false
+	}
+	return len(model_setting.GetModelFallbackChain(group, modelName)) > 0
+}
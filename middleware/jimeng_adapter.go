@@ -34,6 +34,22 @@ func JimengRequestConvert() func(c *gin.Context) {
 			"prompt":   prompt,
 			"metadata": originalReq,
 		}
+		// 同时写入统一视频字段，frames 为 24 帧/秒 + 1
+		if frames, ok := originalReq["frames"].(float64); ok && frames > 0 {
+			unifiedReq["duration"] = int(frames-1) / 24
+		}
+		if aspectRatio, ok := originalReq["aspect_ratio"].(string); ok && aspectRatio != "" {
+			unifiedReq["aspect_ratio"] = aspectRatio
+		}
+		if seed, ok := originalReq["seed"].(float64); ok && seed >= 0 {
+			unifiedReq["seed"] = int64(seed)
+		}
+		for _, key := range []string{"image_urls", "binary_data_base64"} {
+			if images, ok := originalReq[key].([]interface{}); ok && len(images) > 0 {
+				unifiedReq["images"] = images
+				break
+			}
+		}
 
 		jsonData, err := json.Marshal(unifiedReq)
 		if err != nil {
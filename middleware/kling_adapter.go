@@ -3,7 +3,9 @@ package middleware
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
+	"strconv"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
@@ -31,6 +33,25 @@ func KlingRequestConvert() func(c *gin.Context) {
 			"prompt":   prompt,
 			"metadata": originalReq,
 		}
+		// 同时写入统一视频字段，便于按模型能力校验与按时长计费
+		if duration, err := strconv.Atoi(fmt.Sprintf("%v", originalReq["duration"])); err == nil {
+			unifiedReq["duration"] = duration
+		}
+		if aspectRatio, ok := originalReq["aspect_ratio"].(string); ok && aspectRatio != "" {
+			unifiedReq["aspect_ratio"] = aspectRatio
+		}
+		if mode, ok := originalReq["mode"].(string); ok && mode != "" {
+			unifiedReq["mode"] = mode
+		}
+		var images []string
+		for _, key := range []string{"image", "image_tail"} {
+			if image, ok := originalReq[key].(string); ok && image != "" {
+				images = append(images, image)
+			}
+		}
+		if len(images) > 0 {
+			unifiedReq["images"] = images
+		}
 
 		jsonData, err := json.Marshal(unifiedReq)
 		if err != nil {
@@ -3,10 +3,14 @@ package model
 import (
 	"errors"
 	"fmt"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 
 	"github.com/samber/lo"
 	"gorm.io/gorm"
@@ -143,6 +147,57 @@ func GetChannel(group string, model string, retry int) (*Channel, error) {
 	return &channel, err
 }
 
+// getChannelFromDB 未启用内存缓存时从数据库中选择渠道，按定时计划过滤或覆盖优先级与权重后，
+// 同一优先级内按 policy 选择；没有渠道配置定时计划且使用权重策略时与 GetChannel 一致
+func getChannelFromDB(group string, model string, retry int, policy string, excludeIds map[int]bool) (*Channel, error) {
+	var channels []*Channel
+	err := DB.Where("id IN (?)", DB.Model(&Ability{}).Select("channel_id").
+		Where(commonGroupCol+" = ? and model = ? and enabled = ?", group, model, true)).Find(&channels).Error
+	if err != nil {
+		return nil, err
+	}
+	usePolicy := policy != "" && policy != operation_setting.RoutingPolicyWeight
+	now := time.Now()
+	scheduled := false
+	candidates := make([]*Channel, 0, len(channels))
+	for _, channel := range channels {
+		if schedule := channel.GetSchedule(); schedule != nil {
+			scheduled = true
+			if channel = applyChannelSchedule(channel, evaluateChannelSchedule(schedule, now)); channel == nil {
+				continue
+			}
+		}
+		candidates = append(candidates, channel)
+	}
+	if !scheduled && !usePolicy {
+		return GetChannel(group, model, retry)
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	priorities := make([]int64, 0)
+	for _, channel := range candidates {
+		if !slices.Contains(priorities, channel.GetPriority()) {
+			priorities = append(priorities, channel.GetPriority())
+		}
+	}
+	sort.Slice(priorities, func(i, j int) bool { return priorities[i] > priorities[j] })
+	targetPriority := priorities[min(retry, len(priorities)-1)]
+	targetChannels := make([]*Channel, 0, len(candidates))
+	for _, channel := range candidates {
+		if channel.GetPriority() == targetPriority {
+			targetChannels = append(targetChannels, channel)
+		}
+	}
+	if usePolicy {
+		if channel := selectChannelByPolicy(policy, targetChannels, excludeIds); channel != nil {
+			return channel, nil
+		}
+	}
+	return pickWeightedChannel(targetChannels), nil
+}
+
 func (channel *Channel) AddAbilities(tx *gorm.DB) error {
 	models_ := strings.Split(channel.Models, ",")
 	groups_ := strings.Split(channel.Group, ",")
@@ -0,0 +1,582 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+
+	"gorm.io/gorm"
+)
+
+// Campaign 营销活动：充值赠送、首充优惠、限时模型/分组折扣、邀请返利
+type Campaign struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(64);index"`
+	Description string `json:"description" gorm:"type:text"`
+	Type        string `json:"type" gorm:"type:varchar(32);index"`
+	// Code 为空表示自动生效的活动，否则需在充值时填写优惠码
+	Code      string `json:"code" gorm:"type:varchar(64);index"`
+	Status    int    `json:"status" gorm:"default:1"`
+	StartTime int64  `json:"start_time" gorm:"bigint"`
+	EndTime   int64  `json:"end_time" gorm:"bigint"` // 0 表示不过期
+
+	BonusPercent    float64 `json:"bonus_percent" gorm:"type:double;default:0"`    // 充值赠送百分比
+	MinTopUp        float64 `json:"min_top_up" gorm:"type:double;default:0"`       // 最低充值金额（美元）
+	MaxBonusAmount  float64 `json:"max_bonus_amount" gorm:"type:double;default:0"` // 单次最高赠送（美元），0 表示不限
+	Discount        float64 `json:"discount" gorm:"type:double;default:1"`         // 模型/分组折扣，0.8 表示八折
+	Models          string  `json:"models" gorm:"type:text"`                       // 逗号分隔，为空表示所有模型
+	Groups          string  `json:"groups" gorm:"column:target_groups;type:text"`  // 逗号分隔，为空表示所有分组
+	ReferralPercent float64 `json:"referral_percent" gorm:"type:double;default:0"` // 被邀请人消费返利百分比
+	MaxRewardQuota  int     `json:"max_reward_quota" gorm:"default:0"`             // 每个被邀请人最多返利额度，0 表示不限
+
+	MaxUses      int `json:"max_uses" gorm:"default:0"`       // 总使用次数上限，0 表示不限
+	PerUserLimit int `json:"per_user_limit" gorm:"default:0"` // 每用户使用次数上限，0 表示不限
+	UsedCount    int `json:"used_count" gorm:"default:0"`
+	RewardQuota  int `json:"reward_quota" gorm:"default:0"` // 已发放的赠送/返利额度
+
+	CreatedTime int64          `json:"created_time" gorm:"bigint"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// CampaignUsage 活动使用记录
+type CampaignUsage struct {
+	Id            int     `json:"id"`
+	CampaignId    int     `json:"campaign_id" gorm:"index"`
+	UserId        int     `json:"user_id" gorm:"index"`
+	RelatedUserId int     `json:"related_user_id" gorm:"index"` // 邀请返利时为被邀请人
+	TradeNo       string  `json:"trade_no" gorm:"type:varchar(255);index"`
+	TopUpAmount   float64 `json:"top_up_amount" gorm:"type:double;default:0"` // 充值金额（美元）
+	BonusQuota    int     `json:"bonus_quota" gorm:"default:0"`
+	BaseQuota     int     `json:"-" gorm:"default:0"` // 邀请返利时被邀请人已结算到的累计消费额度
+	CreatedTime   int64   `json:"created_time" gorm:"bigint"`
+	UpdatedTime   int64   `json:"updated_time" gorm:"bigint"`
+}
+
+func (campaign *Campaign) Insert() error {
+	campaign.CreatedTime = common.GetTimestamp()
+	err := DB.Create(campaign).Error
+	if err == nil {
+		ReloadCampaignCache()
+	}
+	return err
+}
+
+func (campaign *Campaign) Update() error {
+	err := DB.Model(campaign).Select("name", "description", "type", "code", "status", "start_time", "end_time",
+		"bonus_percent", "min_top_up", "max_bonus_amount", "discount", "models", "target_groups",
+		"referral_percent", "max_reward_quota", "max_uses", "per_user_limit").Updates(campaign).Error
+	if err == nil {
+		ReloadCampaignCache()
+	}
+	return err
+}
+
+func (campaign *Campaign) Delete() error {
+	err := DB.Delete(campaign).Error
+	if err == nil {
+		ReloadCampaignCache()
+	}
+	return err
+}
+
+// IsActive 活动是否在 timestamp 时刻有效（不含次数限制）
+func (campaign *Campaign) IsActive(timestamp int64) bool {
+	if campaign.Status != common.CampaignStatusEnabled {
+		return false
+	}
+	if campaign.StartTime != 0 && timestamp < campaign.StartTime {
+		return false
+	}
+	if campaign.EndTime != 0 && timestamp > campaign.EndTime {
+		return false
+	}
+	return true
+}
+
+func splitCampaignList(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	items := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func matchCampaignList(list string, value string) bool {
+	items := splitCampaignList(list)
+	if len(items) == 0 {
+		return true
+	}
+	for _, item := range items {
+		if item == value {
+			return true
+		}
+		// 支持前缀匹配，如 gpt-4o*
+		if strings.HasSuffix(item, "*") && strings.HasPrefix(value, strings.TrimSuffix(item, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// CalcTopUpBonus 计算充值赠送金额（美元）
+func (campaign *Campaign) CalcTopUpBonus(amount float64) float64 {
+	if campaign.BonusPercent <= 0 || amount < campaign.MinTopUp {
+		return 0
+	}
+	bonus := amount * campaign.BonusPercent / 100
+	if campaign.MaxBonusAmount > 0 && bonus > campaign.MaxBonusAmount {
+		bonus = campaign.MaxBonusAmount
+	}
+	return bonus
+}
+
+func GetAllCampaigns(campaignType string, startIdx int, num int) (campaigns []*Campaign, total int64, err error) {
+	tx := DB.Model(&Campaign{})
+	if campaignType != "" {
+		tx = tx.Where("type = ?", campaignType)
+	}
+	err = tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&campaigns).Error
+	return campaigns, total, err
+}
+
+func GetCampaignById(id int) (*Campaign, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	campaign := Campaign{}
+	err := DB.First(&campaign, "id = ?", id).Error
+	return &campaign, err
+}
+
+func getCampaignByCode(code string) (*Campaign, error) {
+	campaign := Campaign{}
+	err := DB.Where("code = ?", code).Order("id desc").First(&campaign).Error
+	return &campaign, err
+}
+
+func countCampaignUserUsage(tx *gorm.DB, campaignId int, userId int) (int64, error) {
+	var count int64
+	err := tx.Model(&CampaignUsage{}).Where("campaign_id = ? and user_id = ?", campaignId, userId).Count(&count).Error
+	return count, err
+}
+
+func hasSuccessTopUp(tx *gorm.DB, userId int, excludeTopUpId int) bool {
+	var count int64
+	tx.Model(&TopUp{}).Where("user_id = ? and status = ? and id <> ?", userId, common.TopUpStatusSuccess, excludeTopUpId).Count(&count)
+	return count > 0
+}
+
+// checkCampaignUsable 校验次数限制与首充资格，excludeTopUpId 为正在结算的订单
+func checkCampaignUsable(tx *gorm.DB, campaign *Campaign, userId int, excludeTopUpId int) error {
+	if campaign.MaxUses > 0 && campaign.UsedCount >= campaign.MaxUses {
+		return errors.New("优惠已被领完")
+	}
+	if campaign.PerUserLimit > 0 {
+		count, err := countCampaignUserUsage(tx, campaign.Id, userId)
+		if err != nil {
+			return err
+		}
+		if int(count) >= campaign.PerUserLimit {
+			return errors.New("已达到该优惠的使用次数上限")
+		}
+	}
+	if campaign.Type == common.CampaignTypeFirstTopUp && hasSuccessTopUp(tx, userId, excludeTopUpId) {
+		return errors.New("仅限首次充值使用")
+	}
+	return nil
+}
+
+// FindTopUpCampaign 为充值订单选择活动：填写优惠码时校验该码，否则选择赠送最多的自动活动
+func FindTopUpCampaign(userId int, code string, amount float64) (*Campaign, float64, error) {
+	now := common.GetTimestamp()
+	code = strings.TrimSpace(code)
+	if code != "" {
+		campaign, err := getCampaignByCode(code)
+		if err != nil {
+			return nil, 0, errors.New("无效的优惠码")
+		}
+		if campaign.Type != common.CampaignTypeTopUpBonus && campaign.Type != common.CampaignTypeFirstTopUp {
+			return nil, 0, errors.New("该优惠码不适用于充值")
+		}
+		if !campaign.IsActive(now) {
+			return nil, 0, errors.New("优惠码未生效或已过期")
+		}
+		if err := checkCampaignUsable(DB, campaign, userId, 0); err != nil {
+			return nil, 0, err
+		}
+		if amount < campaign.MinTopUp {
+			return nil, 0, fmt.Errorf("充值金额需不少于 %.2f 才能使用该优惠码", campaign.MinTopUp)
+		}
+		return campaign, campaign.CalcTopUpBonus(amount), nil
+	}
+
+	var best *Campaign
+	bestBonus := 0.0
+	for _, campaign := range getCachedCampaigns() {
+		if campaign.Code != "" || !campaign.IsActive(now) {
+			continue
+		}
+		if campaign.Type != common.CampaignTypeTopUpBonus && campaign.Type != common.CampaignTypeFirstTopUp {
+			continue
+		}
+		bonus := campaign.CalcTopUpBonus(amount)
+		if bonus <= bestBonus {
+			continue
+		}
+		if checkCampaignUsable(DB, campaign, userId, 0) != nil {
+			continue
+		}
+		best = campaign
+		bestBonus = bonus
+	}
+	return best, bestBonus, nil
+}
+
+// settleTopUpCampaign 在充值成功的事务中结算活动赠送，返回赠送金额（美元）
+// 下单后活动可能已被领完或达到个人上限，此时不再赠送，但不影响充值本身
+func settleTopUpCampaign(tx *gorm.DB, topUp *TopUp, amount float64) float64 {
+	if topUp.CampaignId == 0 {
+		return 0
+	}
+	campaign := &Campaign{}
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").First(campaign, "id = ?", topUp.CampaignId).Error; err != nil {
+		return 0
+	}
+	if !campaign.IsActive(topUp.CreateTime) || amount < campaign.MinTopUp {
+		return 0
+	}
+	if err := checkCampaignUsable(tx, campaign, topUp.UserId, topUp.Id); err != nil {
+		common.SysLog(fmt.Sprintf("campaign %d not applied to order %s: %s", campaign.Id, topUp.TradeNo, err.Error()))
+		return 0
+	}
+	bonus := campaign.CalcTopUpBonus(amount)
+	if bonus <= 0 {
+		return 0
+	}
+	bonusQuota := int(bonus * common.QuotaPerUnit)
+	now := common.GetTimestamp()
+	usage := &CampaignUsage{
+		CampaignId:  campaign.Id,
+		UserId:      topUp.UserId,
+		TradeNo:     topUp.TradeNo,
+		TopUpAmount: amount,
+		BonusQuota:  bonusQuota,
+		CreatedTime: now,
+		UpdatedTime: now,
+	}
+	if err := tx.Create(usage).Error; err != nil {
+		common.SysLog("failed to record campaign usage: " + err.Error())
+		return 0
+	}
+	tx.Model(&Campaign{}).Where("id = ?", campaign.Id).Updates(map[string]interface{}{
+		"used_count":   gorm.Expr("used_count + ?", 1),
+		"reward_quota": gorm.Expr("reward_quota + ?", bonusQuota),
+	})
+	return bonus
+}
+
+func GetCampaignUsages(campaignId int, startIdx int, num int) (usages []*CampaignUsage, total int64, err error) {
+	// 邀请返利尚未产生返利的记录仅用于保存消费基线，不展示
+	tx := DB.Model(&CampaignUsage{}).Where("campaign_id = ? and bonus_quota > 0", campaignId)
+	err = tx.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Limit(num).Offset(startIdx).Find(&usages).Error
+	return usages, total, err
+}
+
+type CampaignReport struct {
+	Campaign     *Campaign `json:"campaign"`
+	UsageCount   int64     `json:"usage_count"`
+	UserCount    int64     `json:"user_count"`
+	BonusQuota   int64     `json:"bonus_quota"`
+	TopUpAmount  float64   `json:"top_up_amount"`
+	DiscountHits int       `json:"discount_hits"`
+}
+
+func GetCampaignReport(campaignId int) (*CampaignReport, error) {
+	campaign, err := GetCampaignById(campaignId)
+	if err != nil {
+		return nil, err
+	}
+	report := &CampaignReport{Campaign: campaign}
+	var stat struct {
+		UsageCount  int64
+		UserCount   int64
+		BonusQuota  int64
+		TopUpAmount float64
+	}
+	err = DB.Model(&CampaignUsage{}).Where("campaign_id = ? and bonus_quota > 0", campaignId).
+		Select("count(*) as usage_count, count(distinct user_id) as user_count, " +
+			"coalesce(sum(bonus_quota),0) as bonus_quota, coalesce(sum(top_up_amount),0) as top_up_amount").
+		Scan(&stat).Error
+	if err != nil {
+		return nil, err
+	}
+	report.UsageCount = stat.UsageCount
+	report.UserCount = stat.UserCount
+	report.BonusQuota = stat.BonusQuota
+	report.TopUpAmount = stat.TopUpAmount
+	if campaign.Type == common.CampaignTypeModelDiscount {
+		report.DiscountHits = campaign.UsedCount
+	}
+	return report, nil
+}
+
+// 活动缓存，供计费热路径查询
+var (
+	campaignCache     []*Campaign
+	campaignCacheLock sync.RWMutex
+)
+
+func getCachedCampaigns() []*Campaign {
+	campaignCacheLock.RLock()
+	defer campaignCacheLock.RUnlock()
+	return campaignCache
+}
+
+func ReloadCampaignCache() {
+	var campaigns []*Campaign
+	err := DB.Where("status = ?", common.CampaignStatusEnabled).Find(&campaigns).Error
+	if err != nil {
+		common.SysLog("failed to load campaigns: " + err.Error())
+		return
+	}
+	campaignCacheLock.Lock()
+	campaignCache = campaigns
+	campaignCacheLock.Unlock()
+}
+
+// GetModelDiscountCampaign 返回对模型与分组生效的折扣最低的活动
+func GetModelDiscountCampaign(modelName string, group string) (*Campaign, bool) {
+	now := common.GetTimestamp()
+	var best *Campaign
+	for _, campaign := range getCachedCampaigns() {
+		if campaign.Type != common.CampaignTypeModelDiscount || !campaign.IsActive(now) {
+			continue
+		}
+		if campaign.Discount <= 0 || campaign.Discount >= 1 {
+			continue
+		}
+		if campaign.MaxUses > 0 && campaign.UsedCount >= campaign.MaxUses {
+			continue
+		}
+		if !matchCampaignList(campaign.Models, modelName) || !matchCampaignList(campaign.Groups, group) {
+			continue
+		}
+		if best == nil || campaign.Discount < best.Discount {
+			best = campaign
+		}
+	}
+	return best, best != nil
+}
+
+// ClaimCampaignUse 计价命中有次数上限的折扣活动时原子地占用一次使用次数，返回 false 时不应给予折扣。
+// 占用结果直接写入缓存中的计数，次数用完后本节点立即停用该活动，无需重新加载缓存
+func ClaimCampaignUse(campaignId int) bool {
+	result := DB.Model(&Campaign{}).Where("id = ? and (max_uses = 0 or used_count < max_uses)", campaignId).
+		Update("used_count", gorm.Expr("used_count + ?", 1))
+	if result.Error != nil {
+		common.SysLog("failed to claim campaign use: " + result.Error.Error())
+		return false
+	}
+	claimed := result.RowsAffected > 0
+	updateCachedCampaignUsedCount(campaignId, claimed)
+	return claimed
+}
+
+// updateCachedCampaignUsedCount 以复制的方式更新缓存中的使用次数，避免与并发读取的请求共享同一对象
+func updateCachedCampaignUsedCount(campaignId int, claimed bool) {
+	campaignCacheLock.Lock()
+	defer campaignCacheLock.Unlock()
+	for i, cached := range campaignCache {
+		if cached.Id != campaignId {
+			continue
+		}
+		updated := *cached
+		if claimed {
+			updated.UsedCount++
+		} else if updated.MaxUses > 0 {
+			// 其它节点已领完
+			updated.UsedCount = updated.MaxUses
+		}
+		campaigns := make([]*Campaign, len(campaignCache))
+		copy(campaigns, campaignCache)
+		campaigns[i] = &updated
+		campaignCache = campaigns
+		return
+	}
+}
+
+// 不限次数的折扣活动命中次数仅用于统计，在内存中累计后定时落库
+var (
+	campaignHits     = make(map[int]int)
+	campaignStatLock sync.Mutex
+)
+
+// RecordCampaignHit 结算时记录折扣活动的命中次数。有次数上限的活动已在计价时通过 ClaimCampaignUse 计数，此处跳过
+func RecordCampaignHit(campaignId int) {
+	if campaignId == 0 {
+		return
+	}
+	for _, cached := range getCachedCampaigns() {
+		if cached.Id == campaignId && cached.MaxUses > 0 {
+			return
+		}
+	}
+	campaignStatLock.Lock()
+	campaignHits[campaignId]++
+	campaignStatLock.Unlock()
+}
+
+func flushCampaignHits() {
+	campaignStatLock.Lock()
+	hits := campaignHits
+	campaignHits = make(map[int]int)
+	campaignStatLock.Unlock()
+	for id, count := range hits {
+		err := DB.Model(&Campaign{}).Where("id = ?", id).Update("used_count", gorm.Expr("used_count + ?", count)).Error
+		if err != nil {
+			common.SysLog("failed to update campaign used count: " + err.Error())
+		}
+	}
+}
+
+// getReferralCampaign 返回当前返利比例最高的邀请返利活动，多个活动不叠加
+func getReferralCampaign(now int64) *Campaign {
+	var best *Campaign
+	for _, campaign := range getCachedCampaigns() {
+		if campaign.Type != common.CampaignTypeReferral || !campaign.IsActive(now) || campaign.ReferralPercent <= 0 {
+			continue
+		}
+		if best == nil || campaign.ReferralPercent > best.ReferralPercent {
+			best = campaign
+		}
+	}
+	return best
+}
+
+// rewardInviter 按被邀请人自上次结算以来新增的已用额度给邀请人发放返利，计入邀请额度。
+// 基线保存在活动使用记录中，重启不会丢失；首次结算时只记录基线，活动开始前的消费不返利
+func rewardInviter(campaign *Campaign, invitee *User) error {
+	reward := 0
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		usage := &CampaignUsage{}
+		err := tx.Set("gorm:query_option", "FOR UPDATE").
+			Where("campaign_id = ? and user_id = ? and related_user_id = ?", campaign.Id, invitee.InviterId, invitee.Id).
+			First(usage).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		now := common.GetTimestamp()
+		if usage.Id == 0 {
+			return tx.Create(&CampaignUsage{
+				CampaignId:    campaign.Id,
+				UserId:        invitee.InviterId,
+				RelatedUserId: invitee.Id,
+				BaseQuota:     invitee.UsedQuota,
+				CreatedTime:   now,
+				UpdatedTime:   now,
+			}).Error
+		}
+		spend := invitee.UsedQuota - usage.BaseQuota
+		if spend <= 0 {
+			return nil
+		}
+		reward = int(float64(spend) * campaign.ReferralPercent / 100)
+		if campaign.MaxRewardQuota > 0 {
+			reward = common.Max(0, min(reward, campaign.MaxRewardQuota-usage.BonusQuota))
+		}
+		usage.BaseQuota = invitee.UsedQuota
+		usage.BonusQuota += reward
+		usage.UpdatedTime = now
+		if err := tx.Save(usage).Error; err != nil {
+			return err
+		}
+		if reward <= 0 {
+			return nil
+		}
+		if err := tx.Model(&User{}).Where("id = ?", invitee.InviterId).Updates(map[string]interface{}{
+			"aff_quota":   gorm.Expr("aff_quota + ?", reward),
+			"aff_history": gorm.Expr("aff_history + ?", reward),
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Campaign{}).Where("id = ?", campaign.Id).
+			Update("reward_quota", gorm.Expr("reward_quota + ?", reward)).Error
+	})
+	if err != nil {
+		return err
+	}
+	if reward > 0 {
+		RecordLog(invitee.InviterId, LogTypeSystem, fmt.Sprintf("邀请用户 %d 消费返利 %s（活动：%s）", invitee.Id, logger.FormatQuota(reward), campaign.Name))
+	}
+	return nil
+}
+
+// flushReferralRewards 按被邀请人的已用额度增量结算邀请返利
+func flushReferralRewards() {
+	campaign := getReferralCampaign(common.GetTimestamp())
+	if campaign == nil {
+		return
+	}
+	var invitees []*User
+	err := DB.Model(&User{}).Select("id", "inviter_id", "used_quota").Where("inviter_id <> 0").
+		FindInBatches(&invitees, 500, func(tx *gorm.DB, batch int) error {
+			ids := make([]int, 0, len(invitees))
+			for _, invitee := range invitees {
+				ids = append(ids, invitee.Id)
+			}
+			var usages []*CampaignUsage
+			if err := DB.Select("related_user_id", "base_quota").Where("campaign_id = ? and related_user_id in ?", campaign.Id, ids).
+				Find(&usages).Error; err != nil {
+				return err
+			}
+			baseQuotas := make(map[int]int, len(usages))
+			for _, usage := range usages {
+				baseQuotas[usage.RelatedUserId] = usage.BaseQuota
+			}
+			for _, invitee := range invitees {
+				// 已有基线且没有新增消费时跳过
+				if base, ok := baseQuotas[invitee.Id]; ok && invitee.UsedQuota <= base {
+					continue
+				}
+				if err := rewardInviter(campaign, invitee); err != nil {
+					common.SysLog(fmt.Sprintf("failed to reward inviter of user %d: %s", invitee.Id, err.Error()))
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		common.SysLog("failed to load invitees: " + err.Error())
+	}
+}
+
+// SyncCampaigns 定时刷新活动缓存并落库本节点的统计数据，邀请返利仅由主节点结算
+func SyncCampaigns(frequency int) {
+	ReloadCampaignCache()
+	for {
+		time.Sleep(time.Duration(frequency) * time.Second)
+		flushCampaignHits()
+		if common.IsMasterNode {
+			flushReferralRewards()
+		}
+		ReloadCampaignCache()
+	}
+}
@@ -0,0 +1,205 @@
+package model
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+func setupCampaignTestDB(t *testing.T) {
+	t.Helper()
+	setupTestDB(t, &Campaign{}, &CampaignUsage{})
+	t.Cleanup(func() {
+		campaignCacheLock.Lock()
+		campaignCache = nil
+		campaignCacheLock.Unlock()
+		campaignStatLock.Lock()
+		campaignHits = make(map[int]int)
+		campaignStatLock.Unlock()
+	})
+}
+
+func TestModelDiscountCampaignMaxUses(t *testing.T) {
+	setupCampaignTestDB(t)
+	limited := &Campaign{Name: "limited", Type: common.CampaignTypeModelDiscount, Status: common.CampaignStatusEnabled, Discount: 0.5, MaxUses: 2}
+	unlimited := &Campaign{Name: "unlimited", Type: common.CampaignTypeModelDiscount, Status: common.CampaignStatusEnabled, Discount: 0.8, Models: "gpt-4o*"}
+	for _, campaign := range []*Campaign{limited, unlimited} {
+		if err := campaign.Insert(); err != nil {
+			t.Fatalf("insert campaign: %v", err)
+		}
+	}
+
+	// 有次数上限的活动在计价时原子占用次数，缓存计数同步更新，领完后回落到其它活动
+	for i := 0; i < 2; i++ {
+		campaign, ok := GetModelDiscountCampaign("gpt-4o-mini", "default")
+		if !ok || campaign.Id != limited.Id {
+			t.Fatalf("round %d: campaign = %v, want limited", i, campaign)
+		}
+		if !ClaimCampaignUse(campaign.Id) {
+			t.Fatalf("round %d: claim failed", i)
+		}
+		// 结算时不重复计数
+		RecordCampaignHit(campaign.Id)
+	}
+	campaign, ok := GetModelDiscountCampaign("gpt-4o-mini", "default")
+	if !ok || campaign.Id != unlimited.Id {
+		t.Fatalf("campaign after exhausted = %v, want unlimited", campaign)
+	}
+	// 其它节点缓存未刷新时占用失败，按原价计费
+	if ClaimCampaignUse(limited.Id) {
+		t.Fatal("claim after exhausted should fail")
+	}
+	latest, _ := GetCampaignById(limited.Id)
+	if latest.UsedCount != 2 {
+		t.Fatalf("used_count = %d, want 2", latest.UsedCount)
+	}
+
+	// 不限次数的活动命中次数批量落库
+	RecordCampaignHit(unlimited.Id)
+	RecordCampaignHit(unlimited.Id)
+	flushCampaignHits()
+	latest, _ = GetCampaignById(unlimited.Id)
+	if latest.UsedCount != 2 {
+		t.Fatalf("unlimited used_count = %d, want 2", latest.UsedCount)
+	}
+}
+
+func TestClaimCampaignUseCap(t *testing.T) {
+	setupCampaignTestDB(t)
+	tests := []struct {
+		name        string
+		maxUses     int
+		claims      int
+		wantClaimed int
+		wantActive  bool
+	}{
+		{name: "under cap", maxUses: 5, claims: 3, wantClaimed: 3, wantActive: true},
+		{name: "reaches cap", maxUses: 3, claims: 3, wantClaimed: 3, wantActive: false},
+		{name: "over cap", maxUses: 2, claims: 5, wantClaimed: 2, wantActive: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			campaign := &Campaign{Name: tt.name, Type: common.CampaignTypeModelDiscount, Status: common.CampaignStatusEnabled, Discount: 0.5, MaxUses: tt.maxUses, Models: tt.name}
+			if err := campaign.Insert(); err != nil {
+				t.Fatalf("insert campaign: %v", err)
+			}
+			var wg sync.WaitGroup
+			var claimed atomic.Int32
+			for i := 0; i < tt.claims; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if ClaimCampaignUse(campaign.Id) {
+						claimed.Add(1)
+					}
+				}()
+			}
+			wg.Wait()
+			if int(claimed.Load()) != tt.wantClaimed {
+				t.Errorf("claimed = %d, want %d", claimed.Load(), tt.wantClaimed)
+			}
+			latest, _ := GetCampaignById(campaign.Id)
+			if latest.UsedCount != tt.wantClaimed {
+				t.Errorf("used_count = %d, want %d", latest.UsedCount, tt.wantClaimed)
+			}
+			if _, ok := GetModelDiscountCampaign(tt.name, "default"); ok != tt.wantActive {
+				t.Errorf("active = %v, want %v", ok, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestRecordConsumeLogRecordsCampaignHit(t *testing.T) {
+	setupCampaignTestDB(t)
+	setupTestDB(t, &Campaign{}, &CampaignUsage{}, &UserUsage{}, &UserMonthlyQuota{})
+	enableUserUsageBatch(t)
+	// 关闭消费日志时结算仍需计数
+	logConsumeEnabled := common.LogConsumeEnabled
+	common.LogConsumeEnabled = false
+	t.Cleanup(func() { common.LogConsumeEnabled = logConsumeEnabled })
+	campaign := &Campaign{Name: "unlimited", Type: common.CampaignTypeModelDiscount, Status: common.CampaignStatusEnabled, Discount: 0.5}
+	if err := campaign.Insert(); err != nil {
+		t.Fatalf("insert campaign: %v", err)
+	}
+	other := map[string]interface{}{"campaign_id": campaign.Id}
+	RecordConsumeLog(nil, 1, RecordConsumeLogParams{ModelName: "gpt-4o", Quota: 100, Other: other, NotBilled: true})
+	// 渠道测试等不计费的记录不计入活动使用次数
+	flushCampaignHits()
+	latest, _ := GetCampaignById(campaign.Id)
+	if latest.UsedCount != 0 {
+		t.Fatalf("used_count after not billed = %d, want 0", latest.UsedCount)
+	}
+	RecordConsumeLog(nil, 1, RecordConsumeLogParams{ModelName: "gpt-4o", Quota: 100, Other: other})
+	flushCampaignHits()
+	latest, _ = GetCampaignById(campaign.Id)
+	if latest.UsedCount != 1 {
+		t.Fatalf("used_count after settle = %d, want 1", latest.UsedCount)
+	}
+}
+
+func TestReferralRewards(t *testing.T) {
+	setupCampaignTestDB(t)
+	inviter := &User{Username: "inviter", AffCode: "inviter"}
+	if err := DB.Create(inviter).Error; err != nil {
+		t.Fatalf("create inviter: %v", err)
+	}
+	invitee := &User{Username: "invitee", AffCode: "invitee", InviterId: inviter.Id, UsedQuota: 5000}
+	if err := DB.Create(invitee).Error; err != nil {
+		t.Fatalf("create invitee: %v", err)
+	}
+	low := &Campaign{Name: "low", Type: common.CampaignTypeReferral, Status: common.CampaignStatusEnabled, ReferralPercent: 10}
+	high := &Campaign{Name: "high", Type: common.CampaignTypeReferral, Status: common.CampaignStatusEnabled, ReferralPercent: 20, MaxRewardQuota: 300}
+	for _, campaign := range []*Campaign{low, high} {
+		if err := campaign.Insert(); err != nil {
+			t.Fatalf("insert campaign: %v", err)
+		}
+	}
+	affQuota := func() int {
+		user := &User{}
+		DB.Select("aff_quota").First(user, inviter.Id)
+		return user.AffQuota
+	}
+	addUsed := func(quota int) {
+		DB.Model(&User{}).Where("id = ?", invitee.Id).Update("used_quota", invitee.UsedQuota+quota)
+		invitee.UsedQuota += quota
+	}
+
+	tests := []struct {
+		name     string
+		spend    int
+		wantAff  int
+		wantDesc string
+	}{
+		// 活动开始前的消费只记录为基线
+		{name: "baseline", spend: 0, wantAff: 0},
+		// 多个返利活动不叠加，按比例最高的活动返利
+		{name: "reward best campaign only", spend: 1000, wantAff: 200},
+		// 没有新增消费时不重复返利（基线已落库，重启后同样生效）
+		{name: "no new spend", spend: 0, wantAff: 200},
+		// 达到单个被邀请人返利上限
+		{name: "capped", spend: 1000, wantAff: 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addUsed(tt.spend)
+			flushReferralRewards()
+			if got := affQuota(); got != tt.wantAff {
+				t.Fatalf("aff_quota = %d, want %d", got, tt.wantAff)
+			}
+		})
+	}
+	var lowUsages int64
+	DB.Model(&CampaignUsage{}).Where("campaign_id = ?", low.Id).Count(&lowUsages)
+	if lowUsages != 0 {
+		t.Fatalf("low campaign usages = %d, want 0", lowUsages)
+	}
+	report, err := GetCampaignReport(high.Id)
+	if err != nil {
+		t.Fatalf("GetCampaignReport: %v", err)
+	}
+	if report.UsageCount != 1 || report.BonusQuota != 300 {
+		t.Fatalf("report = %+v, want 1 usage with 300 bonus", report)
+	}
+}
@@ -66,10 +66,21 @@ type ChannelInfo struct {
 	MultiKeyDisabledTime   map[int]int64         `json:"multi_key_disabled_time,omitempty"`   // key禁用时间列表，key index -> time
 	MultiKeyPollingIndex   int                   `json:"multi_key_polling_index"`             // 多Key模式下轮询的key索引
 	MultiKeyMode           constant.MultiKeyMode `json:"multi_key_mode"`
+	// 临时冷却，到期后自动启用
+	CooldownUntil         int64         `json:"cooldown_until,omitempty"`           // 渠道冷却结束时间
+	MultiKeyCooldownUntil map[int]int64 `json:"multi_key_cooldown_until,omitempty"` // key冷却结束时间，key index -> time
+	// 连续冷却的退避状态，保存后重启不丢失
+	CooldownBackoff         *ChannelCooldownBackoff        `json:"cooldown_backoff,omitempty"`
+	MultiKeyCooldownBackoff map[int]ChannelCooldownBackoff `json:"multi_key_cooldown_backoff,omitempty"` // key index -> backoff
+	// 剩余冷却秒数，仅在接口返回时计算，不保存
+	CooldownRemaining         int64         `json:"cooldown_remaining,omitempty"`
+	MultiKeyCooldownRemaining map[int]int64 `json:"multi_key_cooldown_remaining,omitempty"`
 }
 
 // Value implements driver.Valuer interface
 func (c ChannelInfo) Value() (driver.Value, error) {
+	c.CooldownRemaining = 0
+	c.MultiKeyCooldownRemaining = nil
 	return common.Marshal(&c)
 }
 
@@ -367,11 +378,15 @@ func BatchInsertChannels(channels []Channel) error {
 		}
 	}()
 
-	for _, chunk := range lo.Chunk(channels, 50) {
+	for i, chunk := range lo.Chunk(channels, 50) {
 		if err := tx.Create(&chunk).Error; err != nil {
 			tx.Rollback()
 			return err
 		}
+		// 回写自增 ID，便于调用方记录渠道版本
+		for j := range chunk {
+			channels[i*50+j].Id = chunk[j].Id
+		}
 		for _, channel_ := range chunk {
 			if err := channel_.AddAbilities(tx); err != nil {
 				tx.Rollback()
@@ -599,7 +614,7 @@ func handlerMultiKeyUpdate(channel *Channel, usingKey string, status int, reason
 		if len(channel.ChannelInfo.MultiKeyStatusList) >= channel.ChannelInfo.MultiKeySize {
 			channel.Status = common.ChannelStatusAutoDisabled
 			info := channel.GetOtherInfo()
-			info["status_reason"] = "All keys are disabled"
+			info["status_reason"] = channelAllKeysDisabledReason
 			info["status_time"] = common.GetTimestamp()
 			channel.SetOtherInfo(info)
 		}
@@ -615,7 +630,8 @@ func UpdateChannelStatus(channelId int, usingKey string, status int, reason stri
 		if channelCache == nil {
 			return false
 		}
-		if channelCache.ChannelInfo.IsMultiKey {
+		// 多Key模式下未指定Key时更新整个渠道的状态
+		if channelCache.ChannelInfo.IsMultiKey && usingKey != "" {
 			// Use per-channel lock to prevent concurrent map read/write with GetNextEnabledKey
 			pollingLock := GetChannelPollingLock(channelId)
 			pollingLock.Lock()
@@ -646,11 +662,12 @@ func UpdateChannelStatus(channelId int, usingKey string, status int, reason stri
 	if err != nil {
 		return false
 	} else {
-		if channel.Status == status {
+		// 多Key模式下更新单个Key时，渠道状态不变也需要保存
+		if channel.Status == status && !(channel.ChannelInfo.IsMultiKey && usingKey != "") {
 			return false
 		}
 
-		if channel.ChannelInfo.IsMultiKey {
+		if channel.ChannelInfo.IsMultiKey && usingKey != "" {
 			beforeStatus := channel.Status
 			// Protect map writes with the same per-channel lock used by readers
 			pollingLock := GetChannelPollingLock(channelId)
@@ -846,6 +863,12 @@ func (channel *Channel) ValidateSettings() error {
 		if err != nil {
 			return err
 		}
+		if err := ValidateChannelSchedule(channelParams.Schedule); err != nil {
+			return fmt.Errorf("定时计划配置错误：%s", err.Error())
+		}
+		if err := ValidateChannelErrorRules(channelParams.ErrorRules); err != nil {
+			return fmt.Errorf("错误映射规则配置错误：%s", err.Error())
+		}
 	}
 	return nil
 }
@@ -12,6 +12,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 )
 
@@ -39,10 +40,22 @@ func InitChannelCache() {
 	for group := range groups {
 		newGroup2model2channels[group] = make(map[string][]int)
 	}
+	// 应用定时计划：停用时段内不参与路由，覆盖时段使用计划中的优先级与权重
+	now := time.Now()
+	newScheduleStates := make(map[int]channelScheduleState)
 	for _, channel := range channels {
 		if channel.Status != common.ChannelStatusEnabled {
 			continue // skip disabled channels
 		}
+		if schedule := channel.GetSchedule(); schedule != nil {
+			state := evaluateChannelSchedule(schedule, now)
+			newScheduleStates[channel.Id] = state
+			scheduled := applyChannelSchedule(channel, state)
+			if scheduled == nil {
+				continue
+			}
+			newChannelId2channel[channel.Id] = scheduled
+		}
 		groups := strings.Split(channel.Group, ",")
 		for _, group := range groups {
 			models := strings.Split(channel.Models, ",")
@@ -82,7 +95,10 @@ func InitChannelCache() {
 		}
 	}
 	channelsIDM = newChannelId2channel
+	oldScheduleStates := channelScheduleStates
+	channelScheduleStates = newScheduleStates
 	channelSyncLock.Unlock()
+	recordChannelScheduleTransitions(oldScheduleStates, newScheduleStates, newChannelId2channel)
 	common.SysLog("channels synced from database")
 }
 
@@ -94,10 +110,12 @@ func SyncChannelCache(frequency int) {
 	}
 }
 
-func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel, error) {
+// GetRandomSatisfiedChannel 选择优先级对应的渠道，同一优先级内按 policy 选择（见 operation_setting.RoutingSetting），
+// excludeIds 为本次请求已失败的渠道，仅非权重策略会跳过
+func GetRandomSatisfiedChannel(group string, model string, retry int, policy string, excludeIds map[int]bool) (*Channel, error) {
 	// if memory cache is disabled, get channel directly from database
 	if !common.MemoryCacheEnabled {
-		return GetChannel(group, model, retry)
+		return getChannelFromDB(group, model, retry, policy, excludeIds)
 	}
 
 	channelSyncLock.RLock()
@@ -160,6 +178,12 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 		return nil, errors.New(fmt.Sprintf("no channel found, group: %s, model: %s, priority: %d", group, model, targetPriority))
 	}
 
+	if policy != "" && policy != operation_setting.RoutingPolicyWeight {
+		if channel := selectChannelByPolicy(policy, targetChannels, excludeIds); channel != nil {
+			return channel, nil
+		}
+	}
+
 	// smoothing factor and adjustment
 	smoothingFactor := 1
 	smoothingAdjustment := 0
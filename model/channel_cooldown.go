@@ -0,0 +1,251 @@
+package model
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// channelAllKeysDisabledReason 多Key渠道全部 Key 被禁用时记录的原因，见 handlerMultiKeyUpdate
+const channelAllKeysDisabledReason = "All keys are disabled"
+
+// channelCooldownBackoffLock 串行化退避状态的读取与更新，避免并发的错误基于同一份旧状态重复翻倍
+var channelCooldownBackoffLock sync.Mutex
+
+// ChannelCooldownBackoff 连续冷却次数与最近一次冷却的结束时间
+type ChannelCooldownBackoff struct {
+	Count int   `json:"count"`
+	Until int64 `json:"until"`
+}
+
+// Next 计算下一次冷却时长：连续冷却时从 base 起翻倍，不超过 maxSeconds；上次冷却结束超过 maxSeconds 后重新计算
+func (b ChannelCooldownBackoff) Next(now int64, base int, maxSeconds int) (ChannelCooldownBackoff, int) {
+	base = max(base, 1)
+	maxSeconds = max(maxSeconds, base)
+	if now-b.Until > int64(maxSeconds) {
+		b = ChannelCooldownBackoff{}
+	}
+	seconds := base
+	for i := 0; i < b.Count && seconds < maxSeconds; i++ {
+		seconds *= 2
+	}
+	seconds = min(seconds, maxSeconds)
+	return ChannelCooldownBackoff{Count: b.Count + 1, Until: now + int64(seconds)}, seconds
+}
+
+// FillCooldownRemaining 计算剩余冷却秒数，供接口展示
+func (c *ChannelInfo) FillCooldownRemaining(now int64) {
+	c.CooldownRemaining = 0
+	if c.CooldownUntil > now {
+		c.CooldownRemaining = c.CooldownUntil - now
+	}
+	c.MultiKeyCooldownRemaining = nil
+	for idx, until := range c.MultiKeyCooldownUntil {
+		if until <= now {
+			continue
+		}
+		if c.MultiKeyCooldownRemaining == nil {
+			c.MultiKeyCooldownRemaining = make(map[int]int64)
+		}
+		c.MultiKeyCooldownRemaining[idx] = until - now
+	}
+}
+
+// getKeyIndex Key 在渠道中的索引，不存在时返回 -1
+func (channel *Channel) getKeyIndex(usingKey string) int {
+	for i, key := range channel.GetKeys() {
+		if key == usingKey {
+			return i
+		}
+	}
+	return -1
+}
+
+// updateChannelCooldown 修改渠道的冷却信息并同步到缓存，只更新 channel_info 字段
+func updateChannelCooldown(channelId int, update func(info *ChannelInfo, channel *Channel)) error {
+	channel, err := GetChannelById(channelId, true)
+	if err != nil {
+		return err
+	}
+	var cached *Channel
+	if common.MemoryCacheEnabled {
+		channelSyncLock.RLock()
+		cached = channelsIDM[channelId]
+		channelSyncLock.RUnlock()
+	}
+	// Protect map writes with the same per-channel lock used by readers
+	pollingLock := GetChannelPollingLock(channelId)
+	pollingLock.Lock()
+	defer pollingLock.Unlock()
+	update(&channel.ChannelInfo, channel)
+	if err := channel.SaveChannelInfo(); err != nil {
+		return err
+	}
+	if cached != nil {
+		update(&cached.ChannelInfo, cached)
+	}
+	return nil
+}
+
+// CooldownChannel 临时禁用渠道或其中一个 Key，until 到期后由 ReinstateExpiredChannelCooldowns 自动启用；
+// 多Key渠道未指定 Key 时冷却整个渠道
+func CooldownChannel(channelId int, usingKey string, reason string, until int64) bool {
+	if !UpdateChannelStatus(channelId, usingKey, common.ChannelStatusAutoDisabled, reason) {
+		return false
+	}
+	err := updateChannelCooldown(channelId, func(info *ChannelInfo, channel *Channel) {
+		if info.IsMultiKey && usingKey != "" {
+			keyIndex := channel.getKeyIndex(usingKey)
+			if keyIndex < 0 {
+				return
+			}
+			if info.MultiKeyCooldownUntil == nil {
+				info.MultiKeyCooldownUntil = make(map[int]int64)
+			}
+			info.MultiKeyCooldownUntil[keyIndex] = until
+			return
+		}
+		info.CooldownUntil = until
+	})
+	if err != nil {
+		common.SysLog(fmt.Sprintf("failed to update channel cooldown: channel_id=%d, error=%v", channelId, err))
+		return false
+	}
+	return true
+}
+
+// NextChannelCooldownSeconds 按渠道或 Key 保存的退避状态计算本次冷却时长并记录；
+// 渠道或 Key 已在冷却中时不推进退避并返回 0，同一次限流的并发请求与冷却后才结束的请求不会重复翻倍
+func NextChannelCooldownSeconds(channelId int, usingKey string, now int64, base int, maxSeconds int) int {
+	channelCooldownBackoffLock.Lock()
+	defer channelCooldownBackoffLock.Unlock()
+
+	channel, err := GetChannelById(channelId, true)
+	if err != nil {
+		common.SysLog(fmt.Sprintf("failed to get channel cooldown backoff: channel_id=%d, error=%v", channelId, err))
+		return max(base, 1)
+	}
+	info := channel.ChannelInfo
+	keyIndex := -1
+	if info.IsMultiKey && usingKey != "" {
+		keyIndex = channel.getKeyIndex(usingKey)
+		if keyIndex < 0 {
+			return max(base, 1)
+		}
+		if info.MultiKeyCooldownUntil[keyIndex] > now || info.MultiKeyCooldownBackoff[keyIndex].Until > now {
+			return 0
+		}
+	} else if info.CooldownUntil > now || (info.CooldownBackoff != nil && info.CooldownBackoff.Until > now) {
+		return 0
+	}
+
+	seconds := max(base, 1)
+	err = updateChannelCooldown(channelId, func(info *ChannelInfo, _ *Channel) {
+		if keyIndex >= 0 {
+			if info.MultiKeyCooldownBackoff == nil {
+				info.MultiKeyCooldownBackoff = make(map[int]ChannelCooldownBackoff)
+			}
+			info.MultiKeyCooldownBackoff[keyIndex], seconds = info.MultiKeyCooldownBackoff[keyIndex].Next(now, base, maxSeconds)
+			return
+		}
+		var backoff ChannelCooldownBackoff
+		if info.CooldownBackoff != nil {
+			backoff = *info.CooldownBackoff
+		}
+		backoff, seconds = backoff.Next(now, base, maxSeconds)
+		info.CooldownBackoff = &backoff
+	})
+	if err != nil {
+		common.SysLog(fmt.Sprintf("failed to update channel cooldown backoff: channel_id=%d, error=%v", channelId, err))
+	}
+	return seconds
+}
+
+// ClearChannelCooldown 渠道或 Key 因其他错误被禁用时清除冷却记录，避免冷却到期后被自动启用
+func ClearChannelCooldown(channelId int, usingKey string) {
+	channel, err := GetChannelById(channelId, true)
+	if err != nil {
+		return
+	}
+	info := channel.ChannelInfo
+	keyIndex := -1
+	if info.IsMultiKey && usingKey != "" {
+		keyIndex = channel.getKeyIndex(usingKey)
+		if _, ok := info.MultiKeyCooldownUntil[keyIndex]; !ok {
+			return
+		}
+	} else if info.CooldownUntil == 0 {
+		return
+	}
+	err = updateChannelCooldown(channelId, func(info *ChannelInfo, _ *Channel) {
+		if keyIndex >= 0 {
+			delete(info.MultiKeyCooldownUntil, keyIndex)
+			return
+		}
+		info.CooldownUntil = 0
+	})
+	if err != nil {
+		common.SysLog(fmt.Sprintf("failed to clear channel cooldown: channel_id=%d, error=%v", channelId, err))
+	}
+}
+
+// ReinstateExpiredChannelCooldowns 启用冷却到期的渠道与 Key，返回恢复的数量；
+// 冷却期间已被启用或手动禁用的只清除冷却记录
+func ReinstateExpiredChannelCooldowns(now int64) int {
+	condition := "channel_info LIKE ?"
+	if common.UsingSQLite || common.UsingPostgreSQL {
+		// SQLite 以 BLOB 保存、PostgreSQL 的 json 类型均不能直接 LIKE
+		condition = "CAST(channel_info AS TEXT) LIKE ?"
+	}
+	var channels []*Channel
+	if err := DB.Where(condition, "%cooldown_until%").Find(&channels).Error; err != nil {
+		common.SysLog(fmt.Sprintf("failed to get cooldown channels: %v", err))
+		return 0
+	}
+	reinstated := 0
+	for _, channel := range channels {
+		info := channel.ChannelInfo
+		expiredKeys := make([]int, 0)
+		for keyIndex, until := range info.MultiKeyCooldownUntil {
+			if until <= now {
+				expiredKeys = append(expiredKeys, keyIndex)
+			}
+		}
+		channelExpired := info.CooldownUntil > 0 && info.CooldownUntil <= now
+		if len(expiredKeys) == 0 && !channelExpired {
+			continue
+		}
+
+		keys := channel.GetKeys()
+		for _, keyIndex := range expiredKeys {
+			if keyIndex >= len(keys) || info.MultiKeyStatusList[keyIndex] != common.ChannelStatusAutoDisabled {
+				continue
+			}
+			if UpdateChannelStatus(channel.Id, keys[keyIndex], common.ChannelStatusEnabled, "") {
+				reinstated++
+			}
+		}
+		enableChannel := channelExpired && channel.Status == common.ChannelStatusAutoDisabled
+		if !enableChannel && len(expiredKeys) > 0 && info.CooldownUntil == 0 && channel.Status == common.ChannelStatusAutoDisabled {
+			// 全部 Key 冷却导致渠道被自动禁用时，Key 恢复后一并恢复渠道
+			enableChannel = channel.GetOtherInfo()["status_reason"] == channelAllKeysDisabledReason
+		}
+		if enableChannel && UpdateChannelStatus(channel.Id, "", common.ChannelStatusEnabled, "") {
+			reinstated++
+		}
+
+		err := updateChannelCooldown(channel.Id, func(info *ChannelInfo, _ *Channel) {
+			for _, keyIndex := range expiredKeys {
+				delete(info.MultiKeyCooldownUntil, keyIndex)
+			}
+			if channelExpired {
+				info.CooldownUntil = 0
+			}
+		})
+		if err != nil {
+			common.SysLog(fmt.Sprintf("failed to clear channel cooldown: channel_id=%d, error=%v", channel.Id, err))
+		}
+	}
+	return reinstated
+}
@@ -0,0 +1,176 @@
+package model
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+func TestChannelCooldownBackoffNext(t *testing.T) {
+	tests := []struct {
+		name        string
+		backoff     ChannelCooldownBackoff
+		now         int64
+		wantSeconds int
+		wantCount   int
+	}{
+		{"first cooldown", ChannelCooldownBackoff{}, 1000, 60, 1},
+		{"consecutive doubles", ChannelCooldownBackoff{Count: 1, Until: 1000}, 1000, 120, 2},
+		{"third doubles again", ChannelCooldownBackoff{Count: 2, Until: 1000}, 1100, 240, 3},
+		{"capped at max", ChannelCooldownBackoff{Count: 10, Until: 1000}, 1000, 3600, 11},
+		{"reset after quiet period", ChannelCooldownBackoff{Count: 5, Until: 1000}, 1000 + 3601, 60, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, seconds := tt.backoff.Next(tt.now, 60, 3600)
+			if seconds != tt.wantSeconds || next.Count != tt.wantCount {
+				t.Errorf("Next() = %d seconds, count %d, want %d seconds, count %d", seconds, next.Count, tt.wantSeconds, tt.wantCount)
+			}
+			if next.Until != tt.now+int64(seconds) {
+				t.Errorf("Until = %d, want %d", next.Until, tt.now+int64(seconds))
+			}
+		})
+	}
+}
+
+func createCooldownTestChannel(t *testing.T) *Channel {
+	t.Helper()
+	channel := &Channel{Name: "cooldown", Key: "sk-test", Status: common.ChannelStatusEnabled, Group: "default", Models: "gpt-4o"}
+	if err := DB.Create(channel).Error; err != nil {
+		t.Fatalf("create channel: %v", err)
+	}
+	return channel
+}
+
+func TestNextChannelCooldownSecondsPersisted(t *testing.T) {
+	setupTestDB(t, &Channel{}, &Ability{})
+	channel := createCooldownTestChannel(t)
+	if seconds := NextChannelCooldownSeconds(channel.Id, channel.Key, 1000, 60, 3600); seconds != 60 {
+		t.Fatalf("first cooldown = %d, want 60", seconds)
+	}
+	// 退避状态保存在渠道信息中，重新读取后继续翻倍
+	if seconds := NextChannelCooldownSeconds(channel.Id, channel.Key, 1060, 60, 3600); seconds != 120 {
+		t.Fatalf("second cooldown = %d, want 120", seconds)
+	}
+	saved, err := GetChannelById(channel.Id, true)
+	if err != nil {
+		t.Fatalf("get channel: %v", err)
+	}
+	if saved.ChannelInfo.CooldownBackoff == nil || saved.ChannelInfo.CooldownBackoff.Count != 2 {
+		t.Fatalf("backoff = %+v, want count 2", saved.ChannelInfo.CooldownBackoff)
+	}
+}
+
+func TestNextChannelCooldownSecondsSkipsActiveCooldown(t *testing.T) {
+	tests := []struct {
+		name      string
+		cooldown  int64 // 已有的冷却结束时间，0 表示未冷却
+		now       int64
+		want      int
+		wantCount int
+	}{
+		{"not cooling down", 0, 1000, 60, 1},
+		{"active cooldown", 1060, 1030, 0, 0},
+		{"cooldown just ended", 1060, 1060, 60, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t, &Channel{}, &Ability{})
+			channel := createCooldownTestChannel(t)
+			if tt.cooldown > 0 && !CooldownChannel(channel.Id, channel.Key, "rate limited", tt.cooldown) {
+				t.Fatal("CooldownChannel failed")
+			}
+			if got := NextChannelCooldownSeconds(channel.Id, channel.Key, tt.now, 60, 3600); got != tt.want {
+				t.Fatalf("cooldown = %d, want %d", got, tt.want)
+			}
+			saved, err := GetChannelById(channel.Id, true)
+			if err != nil {
+				t.Fatalf("get channel: %v", err)
+			}
+			count := 0
+			if saved.ChannelInfo.CooldownBackoff != nil {
+				count = saved.ChannelInfo.CooldownBackoff.Count
+			}
+			if count != tt.wantCount {
+				t.Fatalf("backoff count = %d, want %d", count, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestNextChannelCooldownSecondsConcurrentBurst(t *testing.T) {
+	setupTestDB(t, &Channel{}, &Ability{})
+	channel := createCooldownTestChannel(t)
+
+	// 同一次限流的多个并发请求只推进一次退避
+	var wg sync.WaitGroup
+	results := make([]int, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = NextChannelCooldownSeconds(channel.Id, channel.Key, 1000, 60, 3600)
+		}(i)
+	}
+	wg.Wait()
+	cooled := 0
+	for _, seconds := range results {
+		switch seconds {
+		case 60:
+			cooled++
+		case 0:
+		default:
+			t.Fatalf("unexpected cooldown %d", seconds)
+		}
+	}
+	if cooled != 1 {
+		t.Fatalf("cooldowns = %d, want 1", cooled)
+	}
+	saved, err := GetChannelById(channel.Id, true)
+	if err != nil {
+		t.Fatalf("get channel: %v", err)
+	}
+	if saved.ChannelInfo.CooldownBackoff == nil || saved.ChannelInfo.CooldownBackoff.Count != 1 {
+		t.Fatalf("backoff = %+v, want count 1", saved.ChannelInfo.CooldownBackoff)
+	}
+}
+
+func TestReinstateExpiredChannelCooldowns(t *testing.T) {
+	tests := []struct {
+		name          string
+		hardDisable   bool
+		wantStatus    int
+		wantReinstate int
+	}{
+		{"cooldown expires", false, common.ChannelStatusEnabled, 1},
+		{"hard failure during cooldown", true, common.ChannelStatusAutoDisabled, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t, &Channel{}, &Ability{})
+			channel := createCooldownTestChannel(t)
+			if !CooldownChannel(channel.Id, channel.Key, "rate limited", 1060) {
+				t.Fatal("CooldownChannel failed")
+			}
+			if tt.hardDisable {
+				// 冷却期间渠道测试返回 401，渠道已是自动禁用状态
+				UpdateChannelStatus(channel.Id, channel.Key, common.ChannelStatusAutoDisabled, "invalid key")
+				ClearChannelCooldown(channel.Id, channel.Key)
+			}
+			if got := ReinstateExpiredChannelCooldowns(1100); got != tt.wantReinstate {
+				t.Errorf("reinstated = %d, want %d", got, tt.wantReinstate)
+			}
+			saved, err := GetChannelById(channel.Id, true)
+			if err != nil {
+				t.Fatalf("get channel: %v", err)
+			}
+			if saved.Status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", saved.Status, tt.wantStatus)
+			}
+			if saved.ChannelInfo.CooldownUntil != 0 {
+				t.Errorf("cooldown_until = %d, want cleared", saved.ChannelInfo.CooldownUntil)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// ValidateChannelErrorRules 校验错误映射规则的状态码、正则与动作
+func ValidateChannelErrorRules(rules []dto.ChannelErrorRule) error {
+	for i, rule := range rules {
+		for _, code := range rule.StatusCodes {
+			if code < 100 || code > 599 {
+				return fmt.Errorf("第 %d 条规则的状态码 %d 无效", i+1, code)
+			}
+		}
+		if rule.StatusCode != 0 && (rule.StatusCode < 100 || rule.StatusCode > 599) {
+			return fmt.Errorf("第 %d 条规则改写的状态码 %d 无效", i+1, rule.StatusCode)
+		}
+		for _, match := range rule.Match {
+			if match.Path == "" && match.Regex == "" {
+				return fmt.Errorf("第 %d 条规则的错误体条件需设置路径或正则", i+1)
+			}
+			if match.Regex != "" {
+				if _, err := regexp.Compile(match.Regex); err != nil {
+					return fmt.Errorf("第 %d 条规则的正则无效：%s", i+1, err.Error())
+				}
+			}
+		}
+		switch rule.Action {
+		case "", dto.ChannelErrorActionNone, dto.ChannelErrorActionDisableKey, dto.ChannelErrorActionDisableChannel:
+		default:
+			return fmt.Errorf("第 %d 条规则的动作 %s 无效", i+1, rule.Action)
+		}
+		if rule.DisableSeconds < 0 {
+			return fmt.Errorf("第 %d 条规则的禁用时长不能为负数", i+1)
+		}
+	}
+	return nil
+}
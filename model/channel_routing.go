@@ -0,0 +1,232 @@
+package model
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// channelLatencySamples 计算首字延迟中位数时保留的最近样本数
+const channelLatencySamples = 64
+
+// channelRuntimeStats 渠道的实时运行数据（仅保存在内存中），供路由策略使用
+type channelRuntimeStats struct {
+	inFlight  atomic.Int64
+	mu        sync.Mutex
+	latencies [channelLatencySamples]int64
+	next      int
+	count     int
+}
+
+var channelRuntimeStatsMap sync.Map // channel id -> *channelRuntimeStats
+
+// ChannelRoutingStats 渠道路由相关的实时数据
+type ChannelRoutingStats struct {
+	InFlight       int64   `json:"in_flight"`
+	P50LatencyMs   int64   `json:"p50_latency_ms"` // 无实时样本时为渠道测试的响应时间
+	LatencySamples int     `json:"latency_samples"`
+	CostRatio      float64 `json:"cost_ratio"`
+}
+
+func getChannelRuntimeStats(channelId int) *channelRuntimeStats {
+	if stats, ok := channelRuntimeStatsMap.Load(channelId); ok {
+		return stats.(*channelRuntimeStats)
+	}
+	stats, _ := channelRuntimeStatsMap.LoadOrStore(channelId, &channelRuntimeStats{})
+	return stats.(*channelRuntimeStats)
+}
+
+// ChannelRelayStarted 渠道开始处理一个请求
+func ChannelRelayStarted(channelId int) {
+	getChannelRuntimeStats(channelId).inFlight.Add(1)
+}
+
+// channelFailurePenaltyMs 渠道请求失败时计入的最低延迟样本，使频繁失败的渠道在延迟类策略中排名靠后
+const channelFailurePenaltyMs = 30000
+
+// ChannelFailureLatency 失败请求计入的延迟样本
+func ChannelFailureLatency(elapsedMs int64) int64 {
+	return max(elapsedMs, channelFailurePenaltyMs)
+}
+
+// ChannelRelayFinished 渠道处理完一个请求，latencyMs 为成功请求的首字延迟或失败请求的惩罚延迟（见 ChannelFailureLatency），
+// 小于 0 表示不记录延迟样本
+func ChannelRelayFinished(channelId int, latencyMs int64) {
+	stats := getChannelRuntimeStats(channelId)
+	stats.inFlight.Add(-1)
+	if latencyMs < 0 {
+		return
+	}
+	stats.mu.Lock()
+	stats.latencies[stats.next] = latencyMs
+	stats.next = (stats.next + 1) % channelLatencySamples
+	if stats.count < channelLatencySamples {
+		stats.count++
+	}
+	stats.mu.Unlock()
+}
+
+func (s *channelRuntimeStats) p50Latency() (int64, int) {
+	s.mu.Lock()
+	samples := make([]int64, s.count)
+	copy(samples, s.latencies[:s.count])
+	s.mu.Unlock()
+	if len(samples) == 0 {
+		return 0, 0
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return samples[len(samples)/2], len(samples)
+}
+
+// channelLatency 渠道的首字延迟中位数，没有实时样本时使用渠道测试的响应时间
+func channelLatency(channel *Channel) int64 {
+	if latency, samples := getChannelRuntimeStats(channel.Id).p50Latency(); samples > 0 {
+		return latency
+	}
+	return int64(channel.ResponseTime)
+}
+
+// peekChannelSetting 解析渠道设置，解析失败时返回空设置；与 GetSetting 不同，不会回写数据库，可用于未查询密钥的渠道
+func peekChannelSetting(channel *Channel) dto.ChannelSettings {
+	setting := dto.ChannelSettings{}
+	if channel.Setting != nil && *channel.Setting != "" {
+		_ = common.Unmarshal([]byte(*channel.Setting), &setting)
+	}
+	return setting
+}
+
+func channelCostRatio(channel *Channel) float64 {
+	costRatio := peekChannelSetting(channel).CostRatio
+	if costRatio <= 0 {
+		return 1
+	}
+	return costRatio
+}
+
+func GetChannelRoutingStats(channel *Channel) ChannelRoutingStats {
+	stats := getChannelRuntimeStats(channel.Id)
+	latency, samples := stats.p50Latency()
+	if samples == 0 {
+		latency = int64(channel.ResponseTime)
+	}
+	return ChannelRoutingStats{
+		InFlight:       stats.inFlight.Load(),
+		P50LatencyMs:   latency,
+		LatencySamples: samples,
+		CostRatio:      channelCostRatio(channel),
+	}
+}
+
+// channelScoreTolerance 得分不高于最优得分 (1+channelScoreTolerance) 倍的渠道都视为最优，
+// 在其中按权重随机选择，避免流量全部集中到单个渠道
+const channelScoreTolerance = 0.2
+
+// selectChannelByPolicy 按路由策略从同一优先级的渠道中选择，跳过本次请求已失败的渠道；
+// 得分接近最优的渠道之间按权重随机选择，全部被排除时返回 nil
+func selectChannelByPolicy(policy string, channels []*Channel, excludeIds map[int]bool) *Channel {
+	candidates := make([]*Channel, 0, len(channels))
+	for _, channel := range channels {
+		if !excludeIds[channel.Id] {
+			candidates = append(candidates, channel)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	var score func(channel *Channel) float64
+	switch policy {
+	case operation_setting.RoutingPolicyLatency:
+		latencies := candidateLatencies(candidates)
+		score = func(channel *Channel) float64 { return float64(latencies[channel.Id]) }
+	case operation_setting.RoutingPolicyCost:
+		score = channelCostRatio
+	case operation_setting.RoutingPolicyLeastInFlight:
+		score = func(channel *Channel) float64 { return float64(getChannelRuntimeStats(channel.Id).inFlight.Load()) }
+	case operation_setting.RoutingPolicyPowerOfTwo:
+		if len(candidates) > 2 {
+			first := pickWeightedChannel(candidates)
+			rest := make([]*Channel, 0, len(candidates)-1)
+			for _, channel := range candidates {
+				if channel != first {
+					rest = append(rest, channel)
+				}
+			}
+			candidates = []*Channel{first, pickWeightedChannel(rest)}
+		}
+		latencies := candidateLatencies(candidates)
+		score = func(channel *Channel) float64 {
+			// 在途请求数相同时延迟低的优先
+			return float64(getChannelRuntimeStats(channel.Id).inFlight.Load())*1e9 + float64(latencies[channel.Id])
+		}
+	default:
+		return pickWeightedChannel(candidates)
+	}
+
+	scores := make([]float64, len(candidates))
+	best := math.Inf(1)
+	for i, channel := range candidates {
+		scores[i] = score(channel)
+		best = min(best, scores[i])
+	}
+	bestChannels := make([]*Channel, 0, len(candidates))
+	for i, channel := range candidates {
+		if scores[i] <= best*(1+channelScoreTolerance) {
+			bestChannels = append(bestChannels, channel)
+		}
+	}
+	return pickWeightedChannel(bestChannels)
+}
+
+// candidateLatencies 候选渠道的延迟；既没有实时样本也没有测试结果的渠道使用其它渠道延迟的中位数作为先验，
+// 避免未测速的渠道（响应时间为 0）总是被优先选择
+func candidateLatencies(channels []*Channel) map[int]int64 {
+	latencies := make(map[int]int64, len(channels))
+	known := make([]int64, 0, len(channels))
+	for _, channel := range channels {
+		latency := channelLatency(channel)
+		if latency > 0 {
+			latencies[channel.Id] = latency
+			known = append(known, latency)
+		}
+	}
+	if len(known) == len(channels) {
+		return latencies
+	}
+	var prior int64
+	if len(known) > 0 {
+		sort.Slice(known, func(i, j int) bool { return known[i] < known[j] })
+		prior = known[len(known)/2]
+	}
+	for _, channel := range channels {
+		if _, ok := latencies[channel.Id]; !ok {
+			latencies[channel.Id] = prior
+		}
+	}
+	return latencies
+}
+
+// pickWeightedChannel 按权重随机选择，权重均为 0 时等概率选择
+func pickWeightedChannel(channels []*Channel) *Channel {
+	sumWeight := 0
+	for _, channel := range channels {
+		sumWeight += channel.GetWeight()
+	}
+	if sumWeight == 0 {
+		return channels[rand.Intn(len(channels))]
+	}
+	randomWeight := rand.Intn(sumWeight)
+	for _, channel := range channels {
+		randomWeight -= channel.GetWeight()
+		if randomWeight < 0 {
+			return channel
+		}
+	}
+	return channels[len(channels)-1]
+}
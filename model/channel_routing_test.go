@@ -0,0 +1,162 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+func newRoutingTestChannel(id int, responseTime int, costRatio string) *Channel {
+	weight := uint(10)
+	priority := int64(0)
+	channel := &Channel{Id: id, Name: "routing", Key: "sk-test", Models: "gpt-4o", Group: "default", Status: common.ChannelStatusEnabled,
+		Weight: &weight, Priority: &priority, ResponseTime: responseTime}
+	if costRatio != "" {
+		setting := `{"cost_ratio":` + costRatio + `}`
+		channel.Setting = &setting
+	}
+	return channel
+}
+
+func TestCandidateLatenciesNeutralPrior(t *testing.T) {
+	tests := []struct {
+		name          string
+		responseTimes []int
+		want          []int64
+	}{
+		{name: "all known", responseTimes: []int{100, 300}, want: []int64{100, 300}},
+		{name: "unknown uses median", responseTimes: []int{100, 200, 900, 0}, want: []int64{100, 200, 900, 200}},
+		{name: "all unknown", responseTimes: []int{0, 0}, want: []int64{0, 0}},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channels := make([]*Channel, len(tt.responseTimes))
+			for j, responseTime := range tt.responseTimes {
+				channels[j] = newRoutingTestChannel(91000+i*10+j, responseTime, "")
+			}
+			latencies := candidateLatencies(channels)
+			for j, channel := range channels {
+				if latencies[channel.Id] != tt.want[j] {
+					t.Fatalf("channel %d latency = %d, want %d", j, latencies[channel.Id], tt.want[j])
+				}
+			}
+		})
+	}
+}
+
+func TestSelectChannelByPolicySpreadsNearBest(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		channels []*Channel
+		want     map[int]bool
+	}{
+		{
+			name:   "latency within tolerance",
+			policy: operation_setting.RoutingPolicyLatency,
+			channels: []*Channel{
+				newRoutingTestChannel(92001, 100, ""),
+				newRoutingTestChannel(92002, 110, ""),
+				newRoutingTestChannel(92003, 500, ""),
+			},
+			want: map[int]bool{92001: true, 92002: true},
+		},
+		{
+			// 未测速的渠道按中位数参与比较，不会总是被选中
+			name:   "untested channel is not preferred",
+			policy: operation_setting.RoutingPolicyLatency,
+			channels: []*Channel{
+				newRoutingTestChannel(92011, 100, ""),
+				newRoutingTestChannel(92012, 800, ""),
+				newRoutingTestChannel(92013, 0, ""),
+			},
+			want: map[int]bool{92011: true},
+		},
+		{
+			name:   "cost",
+			policy: operation_setting.RoutingPolicyCost,
+			channels: []*Channel{
+				newRoutingTestChannel(92021, 0, "0.5"),
+				newRoutingTestChannel(92022, 0, "0.55"),
+				newRoutingTestChannel(92023, 0, "1"),
+			},
+			want: map[int]bool{92021: true, 92022: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := make(map[int]bool)
+			for i := 0; i < 200; i++ {
+				channel := selectChannelByPolicy(tt.policy, tt.channels, nil)
+				if !tt.want[channel.Id] {
+					t.Fatalf("selected channel %d, want one of %v", channel.Id, tt.want)
+				}
+				seen[channel.Id] = true
+			}
+			if len(seen) != len(tt.want) {
+				t.Fatalf("selected %v, want all of %v", seen, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelFailureLatencyPenalizesChannel(t *testing.T) {
+	tests := []struct {
+		elapsedMs int64
+		want      int64
+	}{
+		{elapsedMs: 200, want: channelFailurePenaltyMs},
+		{elapsedMs: channelFailurePenaltyMs + 1, want: channelFailurePenaltyMs + 1},
+	}
+	for _, tt := range tests {
+		if got := ChannelFailureLatency(tt.elapsedMs); got != tt.want {
+			t.Fatalf("ChannelFailureLatency(%d) = %d, want %d", tt.elapsedMs, got, tt.want)
+		}
+	}
+
+	fast, slow := newRoutingTestChannel(93001, 100, ""), newRoutingTestChannel(93002, 2000, "")
+	for i := 0; i < 3; i++ {
+		ChannelRelayStarted(fast.Id)
+		ChannelRelayFinished(fast.Id, ChannelFailureLatency(50))
+	}
+	for i := 0; i < 200; i++ {
+		if channel := selectChannelByPolicy(operation_setting.RoutingPolicyLatency, []*Channel{fast, slow}, nil); channel.Id != slow.Id {
+			t.Fatalf("selected failing channel %d", channel.Id)
+		}
+	}
+}
+
+func TestGetRandomSatisfiedChannelPolicyWithoutMemoryCache(t *testing.T) {
+	setupTestDB(t, &Channel{}, &Ability{})
+	memoryCacheEnabled := common.MemoryCacheEnabled
+	common.MemoryCacheEnabled = false
+	t.Cleanup(func() { common.MemoryCacheEnabled = memoryCacheEnabled })
+	for _, channel := range []*Channel{newRoutingTestChannel(94001, 0, "0.3"), newRoutingTestChannel(94002, 0, "1")} {
+		if err := channel.Insert(); err != nil {
+			t.Fatalf("insert channel: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		excludeIds map[int]bool
+		want       int
+	}{
+		{name: "cheapest", want: 94001},
+		{name: "skip failed channel", excludeIds: map[int]bool{94001: true}, want: 94002},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				channel, err := GetRandomSatisfiedChannel("default", "gpt-4o", 0, operation_setting.RoutingPolicyCost, tt.excludeIds)
+				if err != nil || channel == nil {
+					t.Fatalf("GetRandomSatisfiedChannel: channel = %v, err = %v", channel, err)
+				}
+				if channel.Id != tt.want {
+					t.Fatalf("selected channel %d, want %d", channel.Id, tt.want)
+				}
+			}
+		})
+	}
+}
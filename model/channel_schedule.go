@@ -0,0 +1,393 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// channelScheduleMaxHours 查询即将发生的计划变更时最多向后推算的小时数
+const channelScheduleMaxHours = 24 * 7
+
+var channelScheduleStates map[int]channelScheduleState // 渠道当前的计划状态，由 channelSyncLock 保护
+
+// channelScheduleState 渠道在某一时刻按定时计划应处的状态
+type channelScheduleState struct {
+	Disabled bool
+	Priority *int64
+	Weight   *uint
+	Remark   string
+}
+
+func (s channelScheduleState) equal(other channelScheduleState) bool {
+	return s.Disabled == other.Disabled && s.Remark == other.Remark &&
+		equalPtr(s.Priority, other.Priority) && equalPtr(s.Weight, other.Weight)
+}
+
+func equalPtr[T comparable](a *T, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func (s channelScheduleState) describe() string {
+	var desc string
+	switch {
+	case s.Disabled:
+		desc = "停用"
+	case s.Priority != nil || s.Weight != nil:
+		parts := make([]string, 0, 2)
+		if s.Priority != nil {
+			parts = append(parts, fmt.Sprintf("优先级 %d", *s.Priority))
+		}
+		if s.Weight != nil {
+			parts = append(parts, fmt.Sprintf("权重 %d", *s.Weight))
+		}
+		desc = "调整为" + strings.Join(parts, "、")
+	default:
+		desc = "恢复正常"
+	}
+	if s.Remark != "" {
+		desc += "（" + s.Remark + "）"
+	}
+	return desc
+}
+
+// ChannelScheduleTransition 定时计划引起的一次状态变化
+type ChannelScheduleTransition struct {
+	Time     int64  `json:"time"`
+	Disabled bool   `json:"disabled"`
+	Priority *int64 `json:"priority,omitempty"`
+	Weight   *uint  `json:"weight,omitempty"`
+	Remark   string `json:"remark,omitempty"`
+}
+
+func newChannelScheduleTransition(t time.Time, state channelScheduleState) ChannelScheduleTransition {
+	return ChannelScheduleTransition{
+		Time:     t.Unix(),
+		Disabled: state.Disabled,
+		Priority: state.Priority,
+		Weight:   state.Weight,
+		Remark:   state.Remark,
+	}
+}
+
+// GetSchedule 渠道配置的定时计划，未配置任何时段时返回 nil
+func (channel *Channel) GetSchedule() *dto.ChannelSchedule {
+	schedule := peekChannelSetting(channel).Schedule
+	if schedule == nil || len(schedule.Windows) == 0 {
+		return nil
+	}
+	return schedule
+}
+
+func parseScheduleClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return 0, fmt.Errorf("时刻 %s 格式错误，应为 HH:MM", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+var scheduleLocations sync.Map // timezone -> *time.Location，无效时区缓存为 time.Local
+
+// scheduleLocation 返回计划的时区，每分钟都会对所有渠道求值，加载结果按时区名缓存
+func scheduleLocation(timezone string) *time.Location {
+	if timezone == "" {
+		return time.Local
+	}
+	if loc, ok := scheduleLocations.Load(timezone); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.Local
+	}
+	scheduleLocations.Store(timezone, loc)
+	return loc
+}
+
+// ValidateChannelSchedule 校验定时计划的时区、时刻与动作
+func ValidateChannelSchedule(schedule *dto.ChannelSchedule) error {
+	if schedule == nil {
+		return nil
+	}
+	if schedule.Timezone != "" {
+		if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+			return fmt.Errorf("时区 %s 无效", schedule.Timezone)
+		}
+	}
+	for i, window := range schedule.Windows {
+		switch window.Action {
+		case dto.ChannelScheduleActionDisable, dto.ChannelScheduleActionEnable:
+		case dto.ChannelScheduleActionOverride:
+			if window.Priority == nil && window.Weight == nil {
+				return fmt.Errorf("第 %d 个时段未设置要覆盖的优先级或权重", i+1)
+			}
+		default:
+			return fmt.Errorf("第 %d 个时段的动作 %s 无效", i+1, window.Action)
+		}
+		if _, err := parseScheduleClock(window.Start); err != nil {
+			return fmt.Errorf("第 %d 个时段：%s", i+1, err.Error())
+		}
+		if _, err := parseScheduleClock(window.End); err != nil {
+			return fmt.Errorf("第 %d 个时段：%s", i+1, err.Error())
+		}
+		for _, weekday := range window.Weekdays {
+			if weekday < 0 || weekday > 6 {
+				return fmt.Errorf("第 %d 个时段的星期 %d 无效，应为 0-6", i+1, weekday)
+			}
+		}
+	}
+	return nil
+}
+
+func scheduleWindowMatches(window dto.ChannelScheduleWindow, t time.Time) bool {
+	start, err := parseScheduleClock(window.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseScheduleClock(window.End)
+	if err != nil {
+		return false
+	}
+	dayMatches := func(weekday time.Weekday) bool {
+		return len(window.Weekdays) == 0 || slices.Contains(window.Weekdays, int(weekday))
+	}
+	minute := t.Hour()*60 + t.Minute()
+	if start < end {
+		return minute >= start && minute < end && dayMatches(t.Weekday())
+	}
+	// 跨天时段：开始当天的 start 之后，或次日的 end 之前
+	if minute >= start {
+		return dayMatches(t.Weekday())
+	}
+	return minute < end && dayMatches((t.Weekday()+6)%7)
+}
+
+// evaluateChannelSchedule 计算渠道在 now 时按计划应处的状态：命中停用时段，或配置了启用时段但不在其中时停用；
+// 多个覆盖时段同时命中时以靠前的为准
+func evaluateChannelSchedule(schedule *dto.ChannelSchedule, now time.Time) channelScheduleState {
+	state := channelScheduleState{}
+	if schedule == nil {
+		return state
+	}
+	t := now.In(scheduleLocation(schedule.Timezone))
+	hasEnableWindow := false
+	inEnableWindow := false
+	overridden := false
+	for _, window := range schedule.Windows {
+		matched := scheduleWindowMatches(window, t)
+		switch window.Action {
+		case dto.ChannelScheduleActionEnable:
+			hasEnableWindow = true
+			if matched {
+				inEnableWindow = true
+			}
+		case dto.ChannelScheduleActionDisable:
+			if matched && !state.Disabled {
+				state.Disabled = true
+				state.Remark = window.Remark
+			}
+		case dto.ChannelScheduleActionOverride:
+			if matched && !overridden {
+				overridden = true
+				state.Priority = window.Priority
+				state.Weight = window.Weight
+				if !state.Disabled {
+					state.Remark = window.Remark
+				}
+			}
+		}
+	}
+	if hasEnableWindow && !inEnableWindow && !state.Disabled {
+		state.Disabled = true
+		state.Remark = "不在启用时段内"
+	}
+	if state.Disabled {
+		state.Priority = nil
+		state.Weight = nil
+	}
+	return state
+}
+
+// applyChannelSchedule 返回渠道在计划状态下参与路由的缓存对象，覆盖优先级或权重时为副本；停用时返回 nil
+func applyChannelSchedule(channel *Channel, state channelScheduleState) *Channel {
+	if state.Disabled {
+		return nil
+	}
+	if state.Priority == nil && state.Weight == nil {
+		return channel
+	}
+	clone := *channel
+	if state.Priority != nil {
+		priority := *state.Priority
+		clone.Priority = &priority
+	}
+	if state.Weight != nil {
+		weight := *state.Weight
+		clone.Weight = &weight
+	}
+	return &clone
+}
+
+// recordChannelScheduleTransitions 记录计划状态的自动变更（仅主节点写入日志，避免多节点重复）
+func recordChannelScheduleTransitions(oldStates map[int]channelScheduleState, newStates map[int]channelScheduleState, channels map[int]*Channel) {
+	if oldStates == nil {
+		return
+	}
+	for id, channel := range channels {
+		if channel.Status != common.ChannelStatusEnabled {
+			continue
+		}
+		oldState, newState := oldStates[id], newStates[id]
+		if oldState.equal(newState) {
+			continue
+		}
+		content := fmt.Sprintf("渠道 #%d（%s）按定时计划%s", id, channel.Name, newState.describe())
+		common.SysLog(content)
+		if common.IsMasterNode {
+			RecordLog(0, LogTypeManage, content)
+		}
+	}
+}
+
+// channelSchedulesChanged 是否有渠道的计划状态与缓存中的不一致
+func channelSchedulesChanged(now time.Time) bool {
+	channelSyncLock.RLock()
+	defer channelSyncLock.RUnlock()
+	for id, channel := range channelsIDM {
+		if channel.Status != common.ChannelStatusEnabled {
+			continue
+		}
+		state := evaluateChannelSchedule(channel.GetSchedule(), now)
+		if !state.equal(channelScheduleStates[id]) {
+			return true
+		}
+	}
+	return false
+}
+
+// SyncChannelSchedules 每分钟检查定时计划，状态变化时重建渠道缓存；
+// 未启用内存缓存时选择渠道会直接按计划过滤，这里只记录状态变化
+func SyncChannelSchedules() {
+	for {
+		now := time.Now()
+		time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		if !common.MemoryCacheEnabled {
+			syncChannelScheduleStates(time.Now())
+			continue
+		}
+		if channelSchedulesChanged(time.Now()) {
+			common.SysLog("channel schedules changed, syncing channels")
+			InitChannelCache()
+		}
+	}
+}
+
+// syncChannelScheduleStates 未启用内存缓存时从数据库计算计划状态并记录变化
+func syncChannelScheduleStates(now time.Time) {
+	channels, err := GetScheduledChannels()
+	if err != nil {
+		common.SysLog("failed to get scheduled channels: " + err.Error())
+		return
+	}
+	newStates := make(map[int]channelScheduleState, len(channels))
+	channelMap := make(map[int]*Channel, len(channels))
+	for _, channel := range channels {
+		channelMap[channel.Id] = channel
+		if channel.Status == common.ChannelStatusEnabled {
+			newStates[channel.Id] = evaluateChannelSchedule(channel.GetSchedule(), now)
+		}
+	}
+	channelSyncLock.Lock()
+	oldStates := channelScheduleStates
+	channelScheduleStates = newStates
+	channelSyncLock.Unlock()
+	recordChannelScheduleTransitions(oldStates, newStates, channelMap)
+}
+
+// scheduleBoundaries 计划状态只会在各时段的开始、结束时刻（按计划时区计算）及时区偏移变化时变化，返回 (from, to) 内这些时刻
+func scheduleBoundaries(schedule *dto.ChannelSchedule, from time.Time, to time.Time) []time.Time {
+	clocks := make([]int, 0, len(schedule.Windows)*2)
+	for _, window := range schedule.Windows {
+		for _, clock := range []string{window.Start, window.End} {
+			if minute, err := parseScheduleClock(clock); err == nil && !slices.Contains(clocks, minute) {
+				clocks = append(clocks, minute)
+			}
+		}
+	}
+	loc := scheduleLocation(schedule.Timezone)
+	local := from.In(loc)
+	boundaries := make([]time.Time, 0)
+	for day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc); day.Before(to); day = day.AddDate(0, 0, 1) {
+		// 夏令时结束时同一墙上时间会出现两次，按当天前后两个时区偏移分别换算
+		_, startOffset := day.Zone()
+		_, endOffset := day.AddDate(0, 0, 1).Zone()
+		for _, clock := range clocks {
+			wall := time.Date(day.Year(), day.Month(), day.Day(), clock/60, clock%60, 0, 0, time.UTC)
+			for _, offset := range []int{startOffset, endOffset} {
+				t := wall.Add(-time.Duration(offset) * time.Second).In(loc)
+				if t.Hour()*60+t.Minute() == clock && t.After(from) && t.Before(to) {
+					boundaries = append(boundaries, t)
+				}
+			}
+		}
+	}
+	// 夏令时切换时墙上时间跳变，切换时刻也可能改变状态
+	for t := local; ; {
+		_, end := t.ZoneBounds()
+		if end.IsZero() || !end.Before(to) {
+			break
+		}
+		boundaries = append(boundaries, end)
+		t = end
+	}
+	sort.Slice(boundaries, func(i, j int) bool { return boundaries[i].Before(boundaries[j]) })
+	return slices.CompactFunc(boundaries, func(a, b time.Time) bool { return a.Equal(b) })
+}
+
+// GetChannelScheduleTransitions 推算渠道从 from 起 hours 小时内的计划状态变化（只在时段边界求值），第一项为当前状态
+func GetChannelScheduleTransitions(channel *Channel, from time.Time, hours int) []ChannelScheduleTransition {
+	schedule := channel.GetSchedule()
+	if schedule == nil {
+		return nil
+	}
+	if hours <= 0 || hours > channelScheduleMaxHours {
+		hours = channelScheduleMaxHours
+	}
+	from = from.Truncate(time.Minute)
+	current := evaluateChannelSchedule(schedule, from)
+	transitions := []ChannelScheduleTransition{newChannelScheduleTransition(from, current)}
+	for _, t := range scheduleBoundaries(schedule, from, from.Add(time.Duration(hours)*time.Hour)) {
+		state := evaluateChannelSchedule(schedule, t)
+		if !state.equal(current) {
+			transitions = append(transitions, newChannelScheduleTransition(t, state))
+			current = state
+		}
+	}
+	return transitions
+}
+
+// GetScheduledChannels 配置了定时计划的渠道（不含密钥）
+func GetScheduledChannels() ([]*Channel, error) {
+	var channels []*Channel
+	err := DB.Omit("key").Order("id desc").Find(&channels).Error
+	if err != nil {
+		return nil, err
+	}
+	scheduled := make([]*Channel, 0)
+	for _, channel := range channels {
+		if channel.GetSchedule() != nil {
+			scheduled = append(scheduled, channel)
+		}
+	}
+	return scheduled, nil
+}
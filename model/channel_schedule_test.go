@@ -0,0 +1,191 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+func TestScheduleWindowMatches(t *testing.T) {
+	// 2026-10-19 为周一
+	at := func(day int, clock string) time.Time {
+		minute, _ := parseScheduleClock(clock)
+		return time.Date(2026, 10, day, minute/60, minute%60, 0, 0, time.UTC)
+	}
+	daytime := dto.ChannelScheduleWindow{Start: "09:00", End: "18:00"}
+	weekdays := dto.ChannelScheduleWindow{Start: "09:00", End: "18:00", Weekdays: []int{1, 2, 3, 4, 5}}
+	overnight := dto.ChannelScheduleWindow{Start: "22:00", End: "06:00", Weekdays: []int{1}}
+	allDay := dto.ChannelScheduleWindow{Start: "00:00", End: "00:00", Weekdays: []int{0}}
+	tests := []struct {
+		name   string
+		window dto.ChannelScheduleWindow
+		t      time.Time
+		want   bool
+	}{
+		{name: "inside", window: daytime, t: at(19, "12:00"), want: true},
+		{name: "start inclusive", window: daytime, t: at(19, "09:00"), want: true},
+		{name: "end exclusive", window: daytime, t: at(19, "18:00"), want: false},
+		{name: "before start", window: daytime, t: at(19, "08:59"), want: false},
+		{name: "weekday", window: weekdays, t: at(23, "10:00"), want: true},
+		{name: "weekend", window: weekdays, t: at(25, "10:00"), want: false},
+		{name: "overnight before midnight", window: overnight, t: at(19, "23:00"), want: true},
+		{name: "overnight after midnight counts start day", window: overnight, t: at(20, "05:59"), want: true},
+		{name: "overnight end exclusive", window: overnight, t: at(20, "06:00"), want: false},
+		{name: "overnight other day", window: overnight, t: at(20, "23:00"), want: false},
+		{name: "overnight after midnight of other day", window: overnight, t: at(19, "01:00"), want: false},
+		{name: "all day", window: allDay, t: at(25, "23:59"), want: true},
+		{name: "all day next day", window: allDay, t: at(26, "00:00"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scheduleWindowMatches(tt.window, tt.t); got != tt.want {
+				t.Fatalf("scheduleWindowMatches(%s) = %v, want %v", tt.t.Format(time.RFC3339), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleLocationCached(t *testing.T) {
+	tests := []struct {
+		timezone string
+		want     string
+	}{
+		{timezone: "", want: time.Local.String()},
+		{timezone: "Asia/Shanghai", want: "Asia/Shanghai"},
+		{timezone: "Invalid/Zone", want: time.Local.String()},
+	}
+	for _, tt := range tests {
+		loc := scheduleLocation(tt.timezone)
+		if loc.String() != tt.want {
+			t.Fatalf("scheduleLocation(%q) = %s, want %s", tt.timezone, loc, tt.want)
+		}
+		if scheduleLocation(tt.timezone) != loc {
+			t.Fatalf("scheduleLocation(%q) is not cached", tt.timezone)
+		}
+	}
+}
+
+func newScheduledChannel(t *testing.T, schedule *dto.ChannelSchedule) *Channel {
+	t.Helper()
+	data, err := common.Marshal(dto.ChannelSettings{Schedule: schedule})
+	if err != nil {
+		t.Fatal(err)
+	}
+	setting := string(data)
+	return &Channel{Name: "scheduled", Key: "sk-scheduled", Models: "gpt-4o", Group: "default", Setting: &setting}
+}
+
+// minuteScanTransitions 逐分钟推算状态变化，作为 GetChannelScheduleTransitions 的参照
+func minuteScanTransitions(schedule *dto.ChannelSchedule, from time.Time, hours int) []ChannelScheduleTransition {
+	from = from.Truncate(time.Minute)
+	current := evaluateChannelSchedule(schedule, from)
+	transitions := []ChannelScheduleTransition{newChannelScheduleTransition(from, current)}
+	for t := from.Add(time.Minute); t.Before(from.Add(time.Duration(hours) * time.Hour)); t = t.Add(time.Minute) {
+		if state := evaluateChannelSchedule(schedule, t); !state.equal(current) {
+			transitions = append(transitions, newChannelScheduleTransition(t, state))
+			current = state
+		}
+	}
+	return transitions
+}
+
+func TestGetChannelScheduleTransitions(t *testing.T) {
+	priority := int64(5)
+	tests := []struct {
+		name     string
+		schedule *dto.ChannelSchedule
+		from     time.Time
+	}{
+		{
+			name: "maintenance and override",
+			schedule: &dto.ChannelSchedule{Timezone: "Asia/Shanghai", Windows: []dto.ChannelScheduleWindow{
+				{Action: dto.ChannelScheduleActionDisable, Start: "02:00", End: "03:30", Weekdays: []int{2, 4}},
+				{Action: dto.ChannelScheduleActionOverride, Start: "09:00", End: "18:00", Priority: &priority},
+			}},
+			from: time.Date(2026, 10, 19, 7, 13, 42, 0, time.UTC),
+		},
+		{
+			name: "overnight enable window across dst change",
+			schedule: &dto.ChannelSchedule{Timezone: "America/New_York", Windows: []dto.ChannelScheduleWindow{
+				{Action: dto.ChannelScheduleActionEnable, Start: "22:00", End: "06:00"},
+				{Action: dto.ChannelScheduleActionDisable, Start: "01:30", End: "02:30", Weekdays: []int{0}},
+			}},
+			from: time.Date(2026, 10, 29, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "window inside skipped hour",
+			schedule: &dto.ChannelSchedule{Timezone: "America/New_York", Windows: []dto.ChannelScheduleWindow{
+				{Action: dto.ChannelScheduleActionDisable, Start: "02:15", End: "03:15"},
+			}},
+			from: time.Date(2027, 3, 10, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "all day window",
+			schedule: &dto.ChannelSchedule{Windows: []dto.ChannelScheduleWindow{
+				{Action: dto.ChannelScheduleActionDisable, Start: "00:00", End: "00:00", Weekdays: []int{6}},
+			}},
+			from: time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel := newScheduledChannel(t, tt.schedule)
+			got := GetChannelScheduleTransitions(channel, tt.from, channelScheduleMaxHours)
+			want := minuteScanTransitions(tt.schedule, tt.from, channelScheduleMaxHours)
+			if len(got) != len(want) || len(got) < 2 {
+				t.Fatalf("transitions = %+v, want %+v", got, want)
+			}
+			for i := range got {
+				if got[i].Time != want[i].Time || got[i].Disabled != want[i].Disabled || !equalPtr(got[i].Priority, want[i].Priority) {
+					t.Fatalf("transition %d = %+v, want %+v", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGetRandomSatisfiedChannelScheduleWithoutMemoryCache(t *testing.T) {
+	setupTestDB(t, &Channel{}, &Ability{})
+	memoryCacheEnabled := common.MemoryCacheEnabled
+	common.MemoryCacheEnabled = false
+	t.Cleanup(func() { common.MemoryCacheEnabled = memoryCacheEnabled })
+
+	high := int64(10)
+	maintenance := newScheduledChannel(t, &dto.ChannelSchedule{Windows: []dto.ChannelScheduleWindow{
+		{Action: dto.ChannelScheduleActionDisable, Start: "00:00", End: "00:00"},
+	}})
+	promoted := newScheduledChannel(t, &dto.ChannelSchedule{Windows: []dto.ChannelScheduleWindow{
+		{Action: dto.ChannelScheduleActionOverride, Start: "00:00", End: "00:00", Priority: &high},
+	}})
+	normal := &Channel{Name: "normal", Key: "sk-normal", Models: "gpt-4o", Group: "default"}
+	for _, channel := range []*Channel{maintenance, promoted, normal} {
+		if err := channel.Insert(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		retry int
+		want  int
+	}{
+		{name: "override priority first", retry: 0, want: promoted.Id},
+		{name: "maintenance channel skipped", retry: 1, want: normal.Id},
+		{name: "retry beyond priorities", retry: 5, want: normal.Id},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				channel, err := GetRandomSatisfiedChannel("default", "gpt-4o", tt.retry, "", nil)
+				if err != nil || channel == nil {
+					t.Fatalf("GetRandomSatisfiedChannel: channel = %v, err = %v", channel, err)
+				}
+				if channel.Id != tt.want {
+					t.Fatalf("selected channel %d, want %d", channel.Id, tt.want)
+				}
+			}
+		})
+	}
+}
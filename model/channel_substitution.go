@@ -0,0 +1,71 @@
+package model
+
+import "github.com/QuantumNous/new-api/common"
+
+// channelSubstitutionFlagKey 渠道 other_info 中保存模型替换标记的键
+const channelSubstitutionFlagKey = "substitution_flag"
+
+// ChannelSubstitutionFlag 渠道被怀疑替换上游模型的标记
+type ChannelSubstitutionFlag struct {
+	Model   string   `json:"model"`
+	Reasons []string `json:"reasons"`
+	Time    int64    `json:"time"`
+}
+
+// updateChannelOtherInfo 只更新 other_info 字段，不影响渠道的其他配置
+func updateChannelOtherInfo(channelId int, update func(info map[string]interface{})) error {
+	channel, err := GetChannelById(channelId, false)
+	if err != nil {
+		return err
+	}
+	info := channel.GetOtherInfo()
+	update(info)
+	channel.SetOtherInfo(info)
+	if err := DB.Model(&Channel{}).Where("id = ?", channelId).Update("other_info", channel.OtherInfo).Error; err != nil {
+		return err
+	}
+	if common.MemoryCacheEnabled {
+		channelSyncLock.Lock()
+		if cached, ok := channelsIDM[channelId]; ok {
+			cached.OtherInfo = channel.OtherInfo
+		}
+		channelSyncLock.Unlock()
+	}
+	return nil
+}
+
+func SetChannelSubstitutionFlag(channelId int, flag *ChannelSubstitutionFlag) error {
+	return updateChannelOtherInfo(channelId, func(info map[string]interface{}) {
+		info[channelSubstitutionFlagKey] = flag
+	})
+}
+
+func ClearChannelSubstitutionFlag(channelId int) error {
+	return updateChannelOtherInfo(channelId, func(info map[string]interface{}) {
+		delete(info, channelSubstitutionFlagKey)
+	})
+}
+
+// GetSubstitutionFlag 渠道当前的模型替换标记，未标记时返回 nil
+func (channel *Channel) GetSubstitutionFlag() *ChannelSubstitutionFlag {
+	raw, ok := channel.GetOtherInfo()[channelSubstitutionFlagKey]
+	if !ok || raw == nil {
+		return nil
+	}
+	data, err := common.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	flag := &ChannelSubstitutionFlag{}
+	if err := common.Unmarshal(data, flag); err != nil {
+		return nil
+	}
+	return flag
+}
+
+// GetSubstitutionFlaggedChannels 被标记为疑似模型替换的渠道（不含密钥）
+func GetSubstitutionFlaggedChannels() ([]*Channel, error) {
+	var channels []*Channel
+	err := DB.Omit("key").Where("other_info LIKE ?", "%\""+channelSubstitutionFlagKey+"\"%").Order("id desc").Find(&channels).Error
+	return channels, err
+}
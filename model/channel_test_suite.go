@@ -0,0 +1,206 @@
+package model
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// channelTestRunRetentionDays 金丝雀测试记录的保留天数
+const channelTestRunRetentionDays = 30
+
+// ChannelTestAssertions 对测试响应的断言，未设置的项不检查
+type ChannelTestAssertions struct {
+	Regex           string         `json:"regex,omitempty"`            // 回复内容需匹配的正则
+	JSONSchema      map[string]any `json:"json_schema,omitempty"`      // 回复内容需为符合该 Schema 的 JSON（支持常用子集）
+	ToolCall        string         `json:"tool_call,omitempty"`        // 需调用的工具名称，"*" 表示任意工具
+	MinTokens       int            `json:"min_tokens,omitempty"`       // 最少输出 token 数
+	MaxLatencyMs    int64          `json:"max_latency_ms,omitempty"`   // 最大耗时
+	StreamIntegrity bool           `json:"stream_integrity,omitempty"` // 流式响应需包含结束原因与 [DONE]
+	ModelMatch      bool           `json:"model_match,omitempty"`      // 响应中的模型名需与请求的上游模型一致
+}
+
+// ChannelTestCase 一条测试用例
+type ChannelTestCase struct {
+	Name       string                `json:"name"`
+	System     string                `json:"system,omitempty"`
+	Prompt     string                `json:"prompt"`
+	Stream     bool                  `json:"stream,omitempty"`
+	MaxTokens  uint                  `json:"max_tokens,omitempty"`
+	Tools      []dto.ToolCallRequest `json:"tools,omitempty"`
+	Assertions ChannelTestAssertions `json:"assertions"`
+}
+
+type ChannelTestCases []ChannelTestCase
+
+// Value implements driver.Valuer interface
+func (c ChannelTestCases) Value() (driver.Value, error) {
+	return common.Marshal(c)
+}
+
+// Scan implements sql.Scanner interface
+func (c *ChannelTestCases) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return common.Unmarshal(v, c)
+	case string:
+		return common.Unmarshal([]byte(v), c)
+	}
+	*c = nil
+	return nil
+}
+
+// ChannelTestSuite 管理员定义的金丝雀测试套件：对支持 Models 中模型的渠道依次执行 Cases，
+// AutoDisable 开启时断言失败也会自动禁用渠道（仍受渠道自动禁用开关约束）
+type ChannelTestSuite struct {
+	Id          int              `json:"id"`
+	Name        string           `json:"name" gorm:"size:64;not null"`
+	Models      string           `json:"models" gorm:"type:text"` // 逗号分隔
+	Enabled     bool             `json:"enabled"`
+	AutoDisable bool             `json:"auto_disable"`
+	Cases       ChannelTestCases `json:"cases" gorm:"type:text"`
+	CreatedTime int64            `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64            `json:"updated_time" gorm:"bigint"`
+}
+
+// ChannelTestRun 一次测试用例的执行结果
+type ChannelTestRun struct {
+	Id               int    `json:"id"`
+	ChannelId        int    `json:"channel_id" gorm:"index"`
+	SuiteId          int    `json:"suite_id" gorm:"index"`
+	SuiteName        string `json:"suite_name" gorm:"size:64"`
+	CaseName         string `json:"case_name" gorm:"size:128"`
+	Model            string `json:"model" gorm:"size:255"`
+	Passed           bool   `json:"passed"`
+	Failures         string `json:"failures" gorm:"type:text"` // 未通过的断言，以换行分隔
+	LatencyMs        int64  `json:"latency_ms"`
+	CompletionTokens int    `json:"completion_tokens"`
+	CreatedAt        int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+func (s *ChannelTestSuite) GetModels() []string {
+	models := make([]string, 0)
+	for _, m := range strings.Split(s.Models, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			models = append(models, m)
+		}
+	}
+	return models
+}
+
+// Validate 校验套件配置
+func (s *ChannelTestSuite) Validate() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return errors.New("套件名称不能为空")
+	}
+	if len(s.GetModels()) == 0 {
+		return errors.New("至少需要指定一个模型")
+	}
+	if len(s.Cases) == 0 {
+		return errors.New("至少需要一条测试用例")
+	}
+	for i, testCase := range s.Cases {
+		if strings.TrimSpace(testCase.Prompt) == "" {
+			return fmt.Errorf("第 %d 条用例的提示词不能为空", i+1)
+		}
+		if testCase.Assertions.Regex != "" {
+			if _, err := regexp.Compile(testCase.Assertions.Regex); err != nil {
+				return fmt.Errorf("第 %d 条用例的正则无效：%s", i+1, err.Error())
+			}
+		}
+		if testCase.Assertions.ToolCall != "" && testCase.Assertions.ToolCall != "*" && len(testCase.Tools) == 0 {
+			return fmt.Errorf("第 %d 条用例断言了工具调用，但未提供工具定义", i+1)
+		}
+	}
+	return nil
+}
+
+func (s *ChannelTestSuite) Insert() error {
+	now := common.GetTimestamp()
+	s.CreatedTime = now
+	s.UpdatedTime = now
+	return DB.Create(s).Error
+}
+
+func (s *ChannelTestSuite) Update() error {
+	s.UpdatedTime = common.GetTimestamp()
+	return DB.Model(&ChannelTestSuite{}).Where("id = ?", s.Id).Select("*").Omit("created_time").Updates(s).Error
+}
+
+func DeleteChannelTestSuiteById(id int) error {
+	return DB.Delete(&ChannelTestSuite{}, id).Error
+}
+
+func GetChannelTestSuiteById(id int) (*ChannelTestSuite, error) {
+	suite := &ChannelTestSuite{}
+	err := DB.First(suite, id).Error
+	return suite, err
+}
+
+func GetAllChannelTestSuites() ([]*ChannelTestSuite, error) {
+	var suites []*ChannelTestSuite
+	err := DB.Order("id desc").Find(&suites).Error
+	return suites, err
+}
+
+// ChannelTestSuiteTarget 渠道需要执行的一个套件及模型
+type ChannelTestSuiteTarget struct {
+	Suite *ChannelTestSuite
+	Model string
+}
+
+// GetChannelTestSuiteTargets 渠道支持的模型中被启用的套件覆盖的部分
+func GetChannelTestSuiteTargets(channel *Channel) ([]ChannelTestSuiteTarget, error) {
+	var suites []*ChannelTestSuite
+	if err := DB.Where("enabled = ?", true).Order("id asc").Find(&suites).Error; err != nil {
+		return nil, err
+	}
+	channelModels := make(map[string]bool)
+	for _, m := range channel.GetModels() {
+		channelModels[strings.TrimSpace(m)] = true
+	}
+	targets := make([]ChannelTestSuiteTarget, 0)
+	for _, suite := range suites {
+		for _, m := range suite.GetModels() {
+			if channelModels[m] {
+				targets = append(targets, ChannelTestSuiteTarget{Suite: suite, Model: m})
+			}
+		}
+	}
+	return targets, nil
+}
+
+func RecordChannelTestRuns(runs []*ChannelTestRun) error {
+	if len(runs) == 0 {
+		return nil
+	}
+	return DB.Create(&runs).Error
+}
+
+// GetChannelTestRuns 渠道的金丝雀测试记录，suiteId 为 0 时不过滤套件
+func GetChannelTestRuns(channelId int, suiteId int, startIdx int, num int) ([]*ChannelTestRun, int64, error) {
+	var runs []*ChannelTestRun
+	var total int64
+	query := DB.Model(&ChannelTestRun{}).Where("channel_id = ?", channelId)
+	if suiteId != 0 {
+		query = query.Where("suite_id = ?", suiteId)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("id desc").Limit(num).Offset(startIdx).Find(&runs).Error; err != nil {
+		return nil, 0, err
+	}
+	return runs, total, nil
+}
+
+// DeleteExpiredChannelTestRuns 清理超过保留期的测试记录
+func DeleteExpiredChannelTestRuns() error {
+	before := common.GetTimestamp() - channelTestRunRetentionDays*24*3600
+	return DB.Where("created_at < ?", before).Delete(&ChannelTestRun{}).Error
+}
@@ -0,0 +1,415 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+)
+
+// 渠道版本的变更来源
+const (
+	ChannelVersionActionBaseline = "baseline" // 首次修改前的原始配置
+	ChannelVersionActionCreate   = "create"
+	ChannelVersionActionUpdate   = "update"
+	ChannelVersionActionTagEdit  = "tag_edit"
+	ChannelVersionActionBatchTag = "batch_tag"
+	ChannelVersionActionFallback = "fallback"
+	ChannelVersionActionRollback = "rollback"
+	ChannelVersionActionImport   = "import" // 通过配置包导入
+)
+
+const channelVersionRedacted = "******"
+
+// channelVersionMaxAttempts 并发修改同一渠道时版本号冲突的最大重试次数
+const channelVersionMaxAttempts = 3
+
+// ChannelVersion 渠道配置的历史版本，每次修改保存完整快照与相对上一版本的差异
+type ChannelVersion struct {
+	Id         int                  `json:"id"`
+	ChannelId  int                  `json:"channel_id" gorm:"uniqueIndex:idx_channel_version,priority:1"`
+	Version    int                  `json:"version" gorm:"uniqueIndex:idx_channel_version,priority:2"`
+	Action     string               `json:"action" gorm:"type:varchar(32)"`
+	Tag        string               `json:"tag" gorm:"type:varchar(255);index"`
+	Snapshot   string               `json:"-" gorm:"type:text"`
+	Diff       string               `json:"-" gorm:"type:text"`
+	Changes    []ChannelFieldChange `json:"changes" gorm:"-"`
+	OperatorId int                  `json:"operator_id"`
+	Operator   string               `json:"operator" gorm:"type:varchar(64)"`
+	CreatedAt  int64                `json:"created_at" gorm:"bigint;index"`
+}
+
+// ChannelConfigSnapshot 渠道的可配置字段；密钥只保存摘要，回滚不会恢复密钥，余额、状态等运行数据不纳入版本
+type ChannelConfigSnapshot struct {
+	Type               int     `json:"type"`
+	Name               string  `json:"name"`
+	KeyDigest          string  `json:"key"`
+	OpenAIOrganization *string `json:"openai_organization"`
+	TestModel          *string `json:"test_model"`
+	Weight             *uint   `json:"weight"`
+	BaseURL            *string `json:"base_url"`
+	Other              string  `json:"other"`
+	Models             string  `json:"models"`
+	Group              string  `json:"group"`
+	ModelMapping       *string `json:"model_mapping"`
+	StatusCodeMapping  *string `json:"status_code_mapping"`
+	Priority           *int64  `json:"priority"`
+	AutoBan            *int    `json:"auto_ban"`
+	Tag                *string `json:"tag"`
+	Setting            *string `json:"setting"`
+	ParamOverride      *string `json:"param_override"`
+	HeaderOverride     *string `json:"header_override"`
+	Remark             *string `json:"remark"`
+	FallbackChannelId  *int    `json:"fallback_channel_id"`
+	OtherSettings      string  `json:"settings"`
+	MultiKeyMode       string  `json:"multi_key_mode"`
+}
+
+// ChannelFieldChange 两个版本间一个字段的差异，密钥的值已脱敏
+type ChannelFieldChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+// channelVersionColumns 回滚时写回的字段（包含零值）
+var channelVersionColumns = []string{
+	"Type", "Name", "OpenAIOrganization", "TestModel", "Weight", "BaseURL", "Other", "Models", "Group",
+	"ModelMapping", "StatusCodeMapping", "Priority", "AutoBan", "Tag", "Setting", "ParamOverride",
+	"HeaderOverride", "Remark", "FallbackChannelId", "OtherSettings", "ChannelInfo",
+}
+
+func channelKeyDigest(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+func newChannelConfigSnapshot(channel *Channel) ChannelConfigSnapshot {
+	return ChannelConfigSnapshot{
+		Type:               channel.Type,
+		Name:               channel.Name,
+		KeyDigest:          channelKeyDigest(channel.Key),
+		OpenAIOrganization: channel.OpenAIOrganization,
+		TestModel:          channel.TestModel,
+		Weight:             channel.Weight,
+		BaseURL:            channel.BaseURL,
+		Other:              channel.Other,
+		Models:             channel.Models,
+		Group:              channel.Group,
+		ModelMapping:       channel.ModelMapping,
+		StatusCodeMapping:  channel.StatusCodeMapping,
+		Priority:           channel.Priority,
+		AutoBan:            channel.AutoBan,
+		Tag:                channel.Tag,
+		Setting:            channel.Setting,
+		ParamOverride:      channel.ParamOverride,
+		HeaderOverride:     channel.HeaderOverride,
+		Remark:             channel.Remark,
+		FallbackChannelId:  channel.FallbackChannelId,
+		OtherSettings:      channel.OtherSettings,
+		MultiKeyMode:       string(channel.ChannelInfo.MultiKeyMode),
+	}
+}
+
+// applyTo 将快照中的配置写入渠道对象（不含密钥）
+func (s *ChannelConfigSnapshot) applyTo(channel *Channel) {
+	channel.Type = s.Type
+	channel.Name = s.Name
+	channel.OpenAIOrganization = s.OpenAIOrganization
+	channel.TestModel = s.TestModel
+	channel.Weight = s.Weight
+	channel.BaseURL = s.BaseURL
+	channel.Other = s.Other
+	channel.Models = s.Models
+	channel.Group = s.Group
+	channel.ModelMapping = s.ModelMapping
+	channel.StatusCodeMapping = s.StatusCodeMapping
+	channel.Priority = s.Priority
+	channel.AutoBan = s.AutoBan
+	channel.Tag = s.Tag
+	channel.Setting = s.Setting
+	channel.ParamOverride = s.ParamOverride
+	channel.HeaderOverride = s.HeaderOverride
+	channel.Remark = s.Remark
+	channel.FallbackChannelId = s.FallbackChannelId
+	channel.OtherSettings = s.OtherSettings
+	if s.MultiKeyMode != "" {
+		channel.ChannelInfo.MultiKeyMode = constant.MultiKeyMode(s.MultiKeyMode)
+	}
+}
+
+func snapshotFields(snapshot *ChannelConfigSnapshot) map[string]any {
+	fields := make(map[string]any)
+	if snapshot == nil {
+		return fields
+	}
+	data, err := common.Marshal(snapshot)
+	if err != nil {
+		return fields
+	}
+	_ = common.Unmarshal(data, &fields)
+	return fields
+}
+
+// diffChannelSnapshots 比较两个快照，from 为空时列出 to 中所有非空字段
+func diffChannelSnapshots(from *ChannelConfigSnapshot, to *ChannelConfigSnapshot) []ChannelFieldChange {
+	oldFields, newFields := snapshotFields(from), snapshotFields(to)
+	names := make([]string, 0, len(newFields))
+	for name := range newFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	changes := make([]ChannelFieldChange, 0)
+	for _, name := range names {
+		oldValue, newValue := oldFields[name], newFields[name]
+		if reflect.DeepEqual(oldValue, newValue) || (from == nil && (newValue == nil || newValue == "")) {
+			continue
+		}
+		if name == "key" {
+			// 密钥只展示是否修改
+			if oldValue != nil && oldValue != "" {
+				oldValue = channelVersionRedacted
+			}
+			if newValue != nil && newValue != "" {
+				newValue = channelVersionRedacted
+			}
+		}
+		changes = append(changes, ChannelFieldChange{Field: name, Old: oldValue, New: newValue})
+	}
+	return changes
+}
+
+func (v *ChannelVersion) snapshot() (*ChannelConfigSnapshot, error) {
+	snapshot := &ChannelConfigSnapshot{}
+	if err := common.Unmarshal([]byte(v.Snapshot), snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func (v *ChannelVersion) loadChanges() {
+	v.Changes = make([]ChannelFieldChange, 0)
+	if v.Diff != "" {
+		_ = common.Unmarshal([]byte(v.Diff), &v.Changes)
+	}
+}
+
+func getLatestChannelVersion(channelId int) (*ChannelVersion, error) {
+	var versions []*ChannelVersion
+	err := DB.Where("channel_id = ?", channelId).Order("version desc").Limit(1).Find(&versions).Error
+	if err != nil || len(versions) == 0 {
+		return nil, err
+	}
+	return versions[0], nil
+}
+
+// RecordChannelVersion 按渠道当前配置记录一个新版本，与上一版本相同时不记录；
+// (channel_id, version) 唯一，并发写入同一版本号时重新读取最新版本后重试
+func RecordChannelVersion(channelId int, action string, operatorId int, operator string) error {
+	var err error
+	for attempt := 0; attempt < channelVersionMaxAttempts; attempt++ {
+		var conflict bool
+		conflict, err = recordChannelVersion(channelId, action, operatorId, operator)
+		if !conflict {
+			return err
+		}
+	}
+	return err
+}
+
+// recordChannelVersion 写入一个新版本，返回是否因版本号已被占用而失败
+func recordChannelVersion(channelId int, action string, operatorId int, operator string) (bool, error) {
+	channel, err := GetChannelById(channelId, true)
+	if err != nil {
+		return false, err
+	}
+	latest, err := getLatestChannelVersion(channelId)
+	if err != nil {
+		return false, err
+	}
+	snapshot := newChannelConfigSnapshot(channel)
+	var previous *ChannelConfigSnapshot
+	nextVersion := 1
+	if latest != nil {
+		nextVersion = latest.Version + 1
+		if previous, err = latest.snapshot(); err != nil {
+			previous = nil
+		}
+	}
+	changes := diffChannelSnapshots(previous, &snapshot)
+	if latest != nil && len(changes) == 0 {
+		return false, nil
+	}
+	snapshotData, err := common.Marshal(snapshot)
+	if err != nil {
+		return false, err
+	}
+	diffData, err := common.Marshal(changes)
+	if err != nil {
+		return false, err
+	}
+	tag := ""
+	if channel.Tag != nil {
+		tag = *channel.Tag
+	}
+	err = DB.Create(&ChannelVersion{
+		ChannelId:  channelId,
+		Version:    nextVersion,
+		Action:     action,
+		Tag:        tag,
+		Snapshot:   string(snapshotData),
+		Diff:       string(diffData),
+		OperatorId: operatorId,
+		Operator:   operator,
+		CreatedAt:  common.GetTimestamp(),
+	}).Error
+	if err == nil {
+		return false, nil
+	}
+	var exists int64
+	if DB.Model(&ChannelVersion{}).Where("channel_id = ? AND version = ?", channelId, nextVersion).Count(&exists).Error == nil && exists > 0 {
+		return true, err
+	}
+	return false, err
+}
+
+// EnsureChannelBaseline 渠道还没有任何版本时先记录修改前的配置，保证第一次修改也能回滚
+func EnsureChannelBaseline(channelId int) error {
+	latest, err := getLatestChannelVersion(channelId)
+	if err != nil || latest != nil {
+		return err
+	}
+	return RecordChannelVersion(channelId, ChannelVersionActionBaseline, 0, "")
+}
+
+func GetChannelVersions(channelId int, startIdx int, num int) ([]*ChannelVersion, int64, error) {
+	var versions []*ChannelVersion
+	var total int64
+	query := DB.Model(&ChannelVersion{}).Where("channel_id = ?", channelId)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("version desc").Limit(num).Offset(startIdx).Find(&versions).Error; err != nil {
+		return nil, 0, err
+	}
+	for _, version := range versions {
+		version.loadChanges()
+	}
+	return versions, total, nil
+}
+
+func GetChannelVersion(channelId int, version int) (*ChannelVersion, error) {
+	channelVersion := &ChannelVersion{}
+	err := DB.Where("channel_id = ? AND version = ?", channelId, version).First(channelVersion).Error
+	if err != nil {
+		return nil, fmt.Errorf("渠道 #%d 的版本 %d 不存在", channelId, version)
+	}
+	return channelVersion, nil
+}
+
+// DiffChannelVersions 比较渠道任意两个版本的配置
+func DiffChannelVersions(channelId int, from int, to int) ([]ChannelFieldChange, error) {
+	fromVersion, err := GetChannelVersion(channelId, from)
+	if err != nil {
+		return nil, err
+	}
+	toVersion, err := GetChannelVersion(channelId, to)
+	if err != nil {
+		return nil, err
+	}
+	fromSnapshot, err := fromVersion.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	toSnapshot, err := toVersion.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return diffChannelSnapshots(fromSnapshot, toSnapshot), nil
+}
+
+// RollbackChannel 将渠道配置恢复到指定版本（密钥保持不变），并记录为新版本
+func RollbackChannel(channelId int, version int, operatorId int, operator string) error {
+	target, err := GetChannelVersion(channelId, version)
+	if err != nil {
+		return err
+	}
+	snapshot, err := target.snapshot()
+	if err != nil {
+		return err
+	}
+	if err := EnsureChannelBaseline(channelId); err != nil {
+		return err
+	}
+	channel, err := GetChannelById(channelId, false)
+	if err != nil {
+		return err
+	}
+	snapshot.applyTo(channel)
+	err = DB.Model(&Channel{}).Where("id = ?", channelId).Select(channelVersionColumns).Updates(channel).Error
+	if err != nil {
+		return err
+	}
+	if err := channel.UpdateAbilities(nil); err != nil {
+		return err
+	}
+	return RecordChannelVersion(channelId, ChannelVersionActionRollback, operatorId, operator)
+}
+
+// RollbackChannelsByTag 将 before 时刻或当前带有 tag 的渠道分别恢复到 before 时刻（含）之前的最新版本，
+// 按版本记录的 tag 查找，标签被修改或移除的渠道也会被恢复；返回回滚的渠道数，
+// 当前带有 tag 但在该时刻之前没有版本记录的渠道会被跳过
+func RollbackChannelsByTag(tag string, before int64, operatorId int, operator string) (int, []int, error) {
+	var channelIds []int
+	err := DB.Model(&ChannelVersion{}).Where("tag = ? AND channel_id IN (?)", tag, DB.Model(&Channel{}).Select("id")).
+		Distinct("channel_id").Order("channel_id").Pluck("channel_id", &channelIds).Error
+	if err != nil {
+		return 0, nil, err
+	}
+	channels, err := GetChannelsByTag(tag, false, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	currentTagged := make(map[int]bool, len(channels))
+	for _, channel := range channels {
+		currentTagged[channel.Id] = true
+	}
+	seen := make(map[int]bool, len(channelIds))
+	for _, channelId := range channelIds {
+		seen[channelId] = true
+	}
+	for _, channel := range channels {
+		if !seen[channel.Id] {
+			channelIds = append(channelIds, channel.Id)
+		}
+	}
+
+	rolledBack := 0
+	skipped := make([]int, 0)
+	for _, channelId := range channelIds {
+		target := &ChannelVersion{}
+		err := DB.Where("channel_id = ? AND created_at <= ?", channelId, before).Order("version desc").First(target).Error
+		if err != nil {
+			if currentTagged[channelId] {
+				skipped = append(skipped, channelId)
+			}
+			continue
+		}
+		// 仅在 before 之后短暂带过该标签的渠道不属于该时刻的标签
+		if target.Tag != tag && !currentTagged[channelId] {
+			continue
+		}
+		if err := RollbackChannel(channelId, target.Version, operatorId, operator); err != nil {
+			return rolledBack, skipped, fmt.Errorf("渠道 #%d 回滚失败：%s", channelId, err.Error())
+		}
+		rolledBack++
+	}
+	return rolledBack, skipped, nil
+}
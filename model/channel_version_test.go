@@ -0,0 +1,191 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+func TestDiffChannelSnapshots(t *testing.T) {
+	base := ChannelConfigSnapshot{Type: 1, Name: "primary", KeyDigest: channelKeyDigest("sk-a"), Models: "gpt-4o", Group: "default"}
+	tests := []struct {
+		name string
+		from *ChannelConfigSnapshot
+		edit func(s *ChannelConfigSnapshot)
+		want []ChannelFieldChange
+	}{
+		{
+			name: "baseline lists non-empty fields with key redacted",
+			from: nil,
+			edit: func(s *ChannelConfigSnapshot) {},
+			want: []ChannelFieldChange{
+				{Field: "group", Old: nil, New: "default"},
+				{Field: "key", Old: nil, New: channelVersionRedacted},
+				{Field: "models", Old: nil, New: "gpt-4o"},
+				{Field: "name", Old: nil, New: "primary"},
+				{Field: "type", Old: nil, New: float64(1)},
+			},
+		},
+		{name: "unchanged", from: &base, edit: func(s *ChannelConfigSnapshot) {}, want: []ChannelFieldChange{}},
+		{
+			name: "key change is redacted",
+			from: &base,
+			edit: func(s *ChannelConfigSnapshot) { s.KeyDigest = channelKeyDigest("sk-b") },
+			want: []ChannelFieldChange{{Field: "key", Old: channelVersionRedacted, New: channelVersionRedacted}},
+		},
+		{
+			name: "key removed",
+			from: &base,
+			edit: func(s *ChannelConfigSnapshot) { s.KeyDigest = "" },
+			want: []ChannelFieldChange{{Field: "key", Old: channelVersionRedacted, New: ""}},
+		},
+		{
+			name: "field changes",
+			from: &base,
+			edit: func(s *ChannelConfigSnapshot) {
+				s.Models = "gpt-4o,gpt-4o-mini"
+				s.Tag = common.GetPointer("prod")
+			},
+			want: []ChannelFieldChange{
+				{Field: "models", Old: "gpt-4o", New: "gpt-4o,gpt-4o-mini"},
+				{Field: "tag", Old: nil, New: "prod"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			to := base
+			tt.edit(&to)
+			got := diffChannelSnapshots(tt.from, &to)
+			if len(got) != len(tt.want) {
+				t.Fatalf("changes = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("change %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestChannelSnapshotDoesNotStoreKey(t *testing.T) {
+	snapshot := newChannelConfigSnapshot(&Channel{Name: "primary", Key: "sk-secret-key"})
+	data, err := common.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err := common.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields["key"] != channelKeyDigest("sk-secret-key") || fields["key"] == "sk-secret-key" {
+		t.Fatalf("snapshot key = %v, want digest", fields["key"])
+	}
+}
+
+func setupChannelVersionTestDB(t *testing.T) {
+	t.Helper()
+	setupTestDB(t, &Channel{}, &Ability{}, &ChannelVersion{})
+	memoryCacheEnabled := common.MemoryCacheEnabled
+	common.MemoryCacheEnabled = false
+	t.Cleanup(func() { common.MemoryCacheEnabled = memoryCacheEnabled })
+}
+
+func TestRecordChannelVersionRetriesOnConflict(t *testing.T) {
+	setupChannelVersionTestDB(t)
+	channel := &Channel{Name: "primary", Key: "sk-a", Models: "gpt-4o", Group: "default"}
+	if err := channel.Insert(); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟另一个请求在本次读取最新版本后抢先写入了同一版本号
+	conflicted := false
+	err := DB.Callback().Query().After("gorm:query").Register("test:channel_version_conflict", func(tx *gorm.DB) {
+		if _, ok := tx.Statement.Dest.(*[]*ChannelVersion); !ok || conflicted {
+			return
+		}
+		conflicted = true
+		tx.Session(&gorm.Session{NewDB: true}).Exec(
+			"INSERT INTO channel_versions (channel_id, version, action, snapshot, diff, created_at) VALUES (?, 1, ?, '{}', '[]', ?)",
+			channel.Id, ChannelVersionActionBaseline, common.GetTimestamp())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordChannelVersion(channel.Id, ChannelVersionActionUpdate, 1, "root"); err != nil {
+		t.Fatalf("RecordChannelVersion: %v", err)
+	}
+	versions, total, err := GetChannelVersions(channel.Id, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !conflicted || total != 2 || versions[0].Version != 2 || versions[0].Action != ChannelVersionActionUpdate {
+		t.Fatalf("versions = %d, latest = %+v, want update recorded as version 2", total, versions[0])
+	}
+}
+
+func TestRollbackChannelsByTag(t *testing.T) {
+	setupChannelVersionTestDB(t)
+	now := common.GetTimestamp()
+	newChannel := func(name string, tag string) *Channel {
+		channel := &Channel{Name: name, Key: "sk-" + name, Models: "gpt-4o", Group: "default", Tag: common.GetPointer(tag)}
+		if err := channel.Insert(); err != nil {
+			t.Fatal(err)
+		}
+		return channel
+	}
+	setTag := func(channel *Channel, tag string, createdAt int64) {
+		DB.Model(&Channel{}).Where("id = ?", channel.Id).Update("tag", tag)
+		if err := RecordChannelVersion(channel.Id, ChannelVersionActionTagEdit, 1, "root"); err != nil {
+			t.Fatal(err)
+		}
+		DB.Model(&ChannelVersion{}).Where("channel_id = ? AND created_at >= ?", channel.Id, now).Update("created_at", createdAt)
+	}
+
+	// moved: before 时带有 prod，之后被改为 staging
+	moved := newChannel("moved", "prod")
+	if err := RecordChannelVersion(moved.Id, ChannelVersionActionCreate, 1, "root"); err != nil {
+		t.Fatal(err)
+	}
+	DB.Model(&ChannelVersion{}).Where("channel_id = ?", moved.Id).Update("created_at", now-100)
+	setTag(moved, "staging", now+100)
+
+	// added: before 时没有版本记录，之后才加入 prod
+	added := newChannel("added", "prod")
+
+	// passing: before 时为 dev，之后短暂加入 prod 又改为 staging
+	passing := newChannel("passing", "dev")
+	if err := RecordChannelVersion(passing.Id, ChannelVersionActionCreate, 1, "root"); err != nil {
+		t.Fatal(err)
+	}
+	DB.Model(&ChannelVersion{}).Where("channel_id = ?", passing.Id).Update("created_at", now-100)
+	setTag(passing, "prod", now+100)
+	setTag(passing, "staging", now+200)
+
+	rolledBack, skipped, err := RollbackChannelsByTag("prod", now, 1, "root")
+	if err != nil {
+		t.Fatalf("RollbackChannelsByTag: %v", err)
+	}
+	if rolledBack != 1 || len(skipped) != 1 || skipped[0] != added.Id {
+		t.Fatalf("rolled back %d, skipped %v, want 1 and [%d]", rolledBack, skipped, added.Id)
+	}
+	tests := []struct {
+		channel *Channel
+		wantTag string
+	}{
+		{channel: moved, wantTag: "prod"},
+		{channel: added, wantTag: "prod"},
+		{channel: passing, wantTag: "staging"},
+	}
+	for _, tt := range tests {
+		latest, err := GetChannelById(tt.channel.Id, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if latest.GetTag() != tt.wantTag {
+			t.Fatalf("channel %s tag = %s, want %s", tt.channel.Name, latest.GetTag(), tt.wantTag)
+		}
+	}
+}
@@ -0,0 +1,870 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/config"
+
+	"gorm.io/gorm"
+)
+
+// ConfigBundleVersion 配置包格式版本，格式不兼容时递增
+const ConfigBundleVersion = 1
+
+// 配置计划中的对象类型与操作
+const (
+	ConfigPlanKindChannel = "channel"
+	ConfigPlanKindVendor  = "vendor"
+	ConfigPlanKindModel   = "model"
+	ConfigPlanKindOption  = "option"
+
+	ConfigPlanActionCreate = "create"
+	ConfigPlanActionUpdate = "update"
+)
+
+// configBundleRatioOptions 随配置包导出的倍率类选项，其余选项只导出 config.GlobalConfig 中注册的配置
+var configBundleRatioOptions = []string{
+	"ModelRatio", "ModelPrice", "ModelTieredPrice", "CompletionRatio", "CacheRatio",
+	"GroupRatio", "GroupGroupRatio", "UserUsableGroups", "TopupGroupRatio",
+	"ImageRatio", "ImageSizeQualityRatio", "VideoPriceRatio", "AudioPriceRatio", "AudioRatio", "AudioCompletionRatio",
+}
+
+// ConfigBundle 声明式的配置包：渠道按名称、供应商与模型按名称、选项按键名匹配。
+// 某一部分为空时导入不会修改对应数据，导入只新增或更新，不删除配置包之外的数据
+type ConfigBundle struct {
+	Version    int                   `json:"version"`
+	ExportedAt int64                 `json:"exported_at,omitempty"`
+	Channels   []ConfigBundleChannel `json:"channels,omitempty"`
+	Abilities  []ConfigBundleAbility `json:"abilities,omitempty"` // 仅用于查看，导入时由渠道配置重新生成
+	Vendors    []ConfigBundleVendor  `json:"vendors,omitempty"`
+	Models     []ConfigBundleModel   `json:"models,omitempty"`
+	Options    map[string]string     `json:"options,omitempty"`
+}
+
+// ConfigBundleChannel 渠道配置，key 为空表示不导出或不修改密钥
+type ConfigBundleChannel struct {
+	Name               string  `json:"name"`
+	Type               int     `json:"type"`
+	Key                string  `json:"key,omitempty"`
+	Status             int     `json:"status"`
+	OpenAIOrganization *string `json:"openai_organization,omitempty"`
+	TestModel          *string `json:"test_model,omitempty"`
+	Weight             *uint   `json:"weight,omitempty"`
+	BaseURL            *string `json:"base_url,omitempty"`
+	Other              string  `json:"other,omitempty"`
+	Models             string  `json:"models"`
+	Group              string  `json:"group"`
+	ModelMapping       *string `json:"model_mapping,omitempty"`
+	StatusCodeMapping  *string `json:"status_code_mapping,omitempty"`
+	Priority           *int64  `json:"priority,omitempty"`
+	AutoBan            *int    `json:"auto_ban,omitempty"`
+	Tag                *string `json:"tag,omitempty"`
+	Setting            *string `json:"setting,omitempty"`
+	ParamOverride      *string `json:"param_override,omitempty"`
+	HeaderOverride     *string `json:"header_override,omitempty"`
+	Remark             *string `json:"remark,omitempty"`
+	FallbackChannel    string  `json:"fallback_channel,omitempty"` // 兜底渠道的名称
+	OtherSettings      string  `json:"settings,omitempty"`
+	MultiKey           bool    `json:"multi_key,omitempty"`
+	MultiKeyMode       string  `json:"multi_key_mode,omitempty"`
+}
+
+type ConfigBundleAbility struct {
+	Group    string  `json:"group"`
+	Model    string  `json:"model"`
+	Channel  string  `json:"channel"`
+	Enabled  bool    `json:"enabled"`
+	Priority *int64  `json:"priority,omitempty"`
+	Weight   uint    `json:"weight"`
+	Tag      *string `json:"tag,omitempty"`
+}
+
+type ConfigBundleVendor struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Icon        string `json:"icon,omitempty"`
+	Status      int    `json:"status"`
+}
+
+type ConfigBundleModel struct {
+	ModelName    string `json:"model_name"`
+	Description  string `json:"description,omitempty"`
+	Icon         string `json:"icon,omitempty"`
+	Tags         string `json:"tags,omitempty"`
+	Vendor       string `json:"vendor,omitempty"` // 供应商名称
+	Endpoints    string `json:"endpoints,omitempty"`
+	Status       int    `json:"status"`
+	SyncOfficial int    `json:"sync_official"`
+	NameRule     int    `json:"name_rule"`
+}
+
+// ConfigPlanChange 导入时对一个对象的新增或修改
+type ConfigPlanChange struct {
+	Kind    string               `json:"kind"`
+	Name    string               `json:"name"`
+	Action  string               `json:"action"`
+	Changes []ChannelFieldChange `json:"changes,omitempty"`
+
+	apply func(state *configApplyState) error
+}
+
+// ConfigPlan 导入计划：Hash 由计划内容计算，执行时用于确认计划生成后数据没有变化
+type ConfigPlan struct {
+	Hash      string             `json:"hash"`
+	Changes   []ConfigPlanChange `json:"changes"`
+	Unchanged int                `json:"unchanged"`
+	Errors    []string           `json:"errors,omitempty"`
+}
+
+type configApplyState struct {
+	tx               *gorm.DB
+	options          map[string]string // 事务提交后再更新内存中的选项
+	channelIds       map[string]int    // 渠道名称 -> id
+	vendorIds        map[string]int    // 供应商名称 -> id
+	pendingFallbacks map[int]string    // 渠道 id -> 兜底渠道名称
+	channelIdsSaved  []int
+}
+
+// secretOptionWords 选项名的最后一个单词为这些词时视为密钥类选项
+var secretOptionWords = map[string]bool{
+	"secret":   true,
+	"key":      true,
+	"token":    true,
+	"password": true,
+}
+
+// isSecretOptionKey 是否为密钥类选项，同时支持 PascalCase（TurnstileSecretKey）与点分 snake_case（oidc.client_secret）的键名
+func isSecretOptionKey(key string) bool {
+	words := splitOptionKeyWords(key)
+	return len(words) > 0 && secretOptionWords[words[len(words)-1]]
+}
+
+// splitOptionKeyWords 按 .、_、- 与大小写边界拆分选项名，返回小写的单词
+func splitOptionKeyWords(key string) []string {
+	words := make([]string, 0)
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	runes := []rune(key)
+	for i, r := range runes {
+		switch {
+		case r == '.' || r == '_' || r == '-':
+			flush()
+			continue
+		case unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))):
+			flush()
+		}
+		word = append(word, r)
+	}
+	flush()
+	return words
+}
+
+// configChannelStatus 自动禁用属于运行时状态，不作为配置比较与导入，视同启用
+func configChannelStatus(status int) int {
+	if status == common.ChannelStatusAutoDisabled {
+		return common.ChannelStatusEnabled
+	}
+	return status
+}
+
+// isBundleOptionKey 是否为配置包可以管理的选项
+func isBundleOptionKey(key string, globalConfigs map[string]string) bool {
+	if _, ok := globalConfigs[key]; ok {
+		return true
+	}
+	for _, ratioKey := range configBundleRatioOptions {
+		if key == ratioKey {
+			return true
+		}
+	}
+	return false
+}
+
+func newConfigBundleChannel(channel *Channel, fallbackName string, includeKey bool) ConfigBundleChannel {
+	bundleChannel := ConfigBundleChannel{
+		Name:               channel.Name,
+		Type:               channel.Type,
+		Status:             channel.Status,
+		OpenAIOrganization: channel.OpenAIOrganization,
+		TestModel:          channel.TestModel,
+		Weight:             channel.Weight,
+		BaseURL:            channel.BaseURL,
+		Other:              channel.Other,
+		Models:             channel.Models,
+		Group:              channel.Group,
+		ModelMapping:       channel.ModelMapping,
+		StatusCodeMapping:  channel.StatusCodeMapping,
+		Priority:           channel.Priority,
+		AutoBan:            channel.AutoBan,
+		Tag:                channel.Tag,
+		Setting:            channel.Setting,
+		ParamOverride:      channel.ParamOverride,
+		HeaderOverride:     channel.HeaderOverride,
+		Remark:             channel.Remark,
+		FallbackChannel:    fallbackName,
+		OtherSettings:      channel.OtherSettings,
+		MultiKey:           channel.ChannelInfo.IsMultiKey,
+		MultiKeyMode:       string(channel.ChannelInfo.MultiKeyMode),
+	}
+	if includeKey {
+		bundleChannel.Key = channel.Key
+	}
+	return bundleChannel
+}
+
+// normalize 将未填写的字段设为数据库默认值，避免手写的配置包在导入后仍被识别为有差异
+func (c *ConfigBundleChannel) normalize() {
+	if c.Status == 0 {
+		c.Status = common.ChannelStatusEnabled
+	}
+	c.Status = configChannelStatus(c.Status)
+	if c.Weight == nil {
+		c.Weight = common.GetPointer[uint](0)
+	}
+	if c.BaseURL == nil {
+		c.BaseURL = common.GetPointer("")
+	}
+	if c.StatusCodeMapping == nil {
+		c.StatusCodeMapping = common.GetPointer("")
+	}
+	if c.Priority == nil {
+		c.Priority = common.GetPointer[int64](0)
+	}
+	if c.AutoBan == nil {
+		c.AutoBan = common.GetPointer(1)
+	}
+	if c.Group == "" {
+		c.Group = "default"
+	}
+}
+
+// ToChannel 转换为渠道对象（不含兜底渠道），供校验与写入使用
+func (c *ConfigBundleChannel) ToChannel() *Channel {
+	channel := &Channel{
+		Type:               c.Type,
+		Key:                c.Key,
+		Status:             c.Status,
+		Name:               c.Name,
+		OpenAIOrganization: c.OpenAIOrganization,
+		TestModel:          c.TestModel,
+		Weight:             c.Weight,
+		BaseURL:            c.BaseURL,
+		Other:              c.Other,
+		Models:             c.Models,
+		Group:              c.Group,
+		ModelMapping:       c.ModelMapping,
+		StatusCodeMapping:  c.StatusCodeMapping,
+		Priority:           c.Priority,
+		AutoBan:            c.AutoBan,
+		Tag:                c.Tag,
+		Setting:            c.Setting,
+		ParamOverride:      c.ParamOverride,
+		HeaderOverride:     c.HeaderOverride,
+		Remark:             c.Remark,
+		OtherSettings:      c.OtherSettings,
+	}
+	channel.ChannelInfo.IsMultiKey = c.MultiKey
+	channel.ChannelInfo.MultiKeyMode = constant.MultiKeyMode(c.MultiKeyMode)
+	return channel
+}
+
+// ExportConfigBundle 导出当前的渠道、能力、供应商、模型元数据与配置选项；includeKeys 为 false 时不导出渠道密钥与密钥类选项
+func ExportConfigBundle(includeKeys bool) (*ConfigBundle, error) {
+	bundle := &ConfigBundle{
+		Version:    ConfigBundleVersion,
+		ExportedAt: common.GetTimestamp(),
+		Options:    make(map[string]string),
+	}
+
+	var channels []*Channel
+	query := DB.Order("id asc")
+	if !includeKeys {
+		query = query.Omit("key")
+	}
+	if err := query.Find(&channels).Error; err != nil {
+		return nil, err
+	}
+	channelNames := make(map[int]string, len(channels))
+	for _, channel := range channels {
+		channelNames[channel.Id] = channel.Name
+	}
+	for _, channel := range channels {
+		fallbackName := ""
+		if channel.FallbackChannelId != nil {
+			fallbackName = channelNames[*channel.FallbackChannelId]
+		}
+		bundle.Channels = append(bundle.Channels, newConfigBundleChannel(channel, fallbackName, includeKeys))
+	}
+
+	var abilities []*Ability
+	if err := DB.Order("channel_id asc, " + commonGroupCol + " asc, model asc").Find(&abilities).Error; err != nil {
+		return nil, err
+	}
+	for _, ability := range abilities {
+		bundle.Abilities = append(bundle.Abilities, ConfigBundleAbility{
+			Group:    ability.Group,
+			Model:    ability.Model,
+			Channel:  channelNames[ability.ChannelId],
+			Enabled:  ability.Enabled,
+			Priority: ability.Priority,
+			Weight:   ability.Weight,
+			Tag:      ability.Tag,
+		})
+	}
+
+	var vendors []*Vendor
+	if err := DB.Order("id asc").Find(&vendors).Error; err != nil {
+		return nil, err
+	}
+	vendorNames := make(map[int]string, len(vendors))
+	for _, vendor := range vendors {
+		vendorNames[vendor.Id] = vendor.Name
+		bundle.Vendors = append(bundle.Vendors, ConfigBundleVendor{
+			Name:        vendor.Name,
+			Description: vendor.Description,
+			Icon:        vendor.Icon,
+			Status:      vendor.Status,
+		})
+	}
+
+	var models []*Model
+	if err := DB.Order("id asc").Find(&models).Error; err != nil {
+		return nil, err
+	}
+	for _, m := range models {
+		bundle.Models = append(bundle.Models, newConfigBundleModel(m, vendorNames[m.VendorID]))
+	}
+
+	globalConfigs := config.GlobalConfig.ExportAllConfigs()
+	common.OptionMapRWMutex.RLock()
+	for key, value := range common.OptionMap {
+		if !isBundleOptionKey(key, globalConfigs) || (!includeKeys && isSecretOptionKey(key)) {
+			continue
+		}
+		bundle.Options[key] = value
+	}
+	common.OptionMapRWMutex.RUnlock()
+	return bundle, nil
+}
+
+func newConfigBundleModel(m *Model, vendorName string) ConfigBundleModel {
+	return ConfigBundleModel{
+		ModelName:    m.ModelName,
+		Description:  m.Description,
+		Icon:         m.Icon,
+		Tags:         m.Tags,
+		Vendor:       vendorName,
+		Endpoints:    m.Endpoints,
+		Status:       m.Status,
+		SyncOfficial: m.SyncOfficial,
+		NameRule:     m.NameRule,
+	}
+}
+
+// diffConfigFields 比较两个对象按 JSON 展开后的字段
+func diffConfigFields(current any, desired any) []ChannelFieldChange {
+	toFields := func(v any) map[string]any {
+		fields := make(map[string]any)
+		if data, err := common.Marshal(v); err == nil {
+			_ = common.Unmarshal(data, &fields)
+		}
+		return fields
+	}
+	oldFields, newFields := toFields(current), toFields(desired)
+	names := make(map[string]bool)
+	for name := range oldFields {
+		names[name] = true
+	}
+	for name := range newFields {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	changes := make([]ChannelFieldChange, 0)
+	for _, name := range sorted {
+		if !reflect.DeepEqual(oldFields[name], newFields[name]) {
+			changes = append(changes, ChannelFieldChange{Field: name, Old: oldFields[name], New: newFields[name]})
+		}
+	}
+	return changes
+}
+
+// PlanConfigBundle 计算导入配置包需要的修改，不写入任何数据
+func PlanConfigBundle(bundle *ConfigBundle) (*ConfigPlan, error) {
+	plan := &ConfigPlan{Changes: make([]ConfigPlanChange, 0)}
+	if bundle.Version != ConfigBundleVersion {
+		plan.Errors = append(plan.Errors, fmt.Sprintf("不支持的配置包版本 %d，当前版本为 %d", bundle.Version, ConfigBundleVersion))
+		return plan, nil
+	}
+	if err := planConfigVendors(bundle, plan); err != nil {
+		return nil, err
+	}
+	if err := planConfigModels(bundle, plan); err != nil {
+		return nil, err
+	}
+	planConfigOptions(bundle, plan)
+	if err := planConfigChannels(bundle, plan); err != nil {
+		return nil, err
+	}
+	data, err := common.Marshal(plan.Changes)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	plan.Hash = hex.EncodeToString(sum[:])
+	return plan, nil
+}
+
+func planConfigVendors(bundle *ConfigBundle, plan *ConfigPlan) error {
+	var vendors []*Vendor
+	if err := DB.Find(&vendors).Error; err != nil {
+		return err
+	}
+	existing := make(map[string]*Vendor, len(vendors))
+	for _, vendor := range vendors {
+		existing[vendor.Name] = vendor
+	}
+	seen := make(map[string]bool)
+	for _, desired := range bundle.Vendors {
+		desired := desired
+		if desired.Name == "" || seen[desired.Name] {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("供应商名称 %q 为空或重复", desired.Name))
+			continue
+		}
+		seen[desired.Name] = true
+		current, ok := existing[desired.Name]
+		if !ok {
+			plan.Changes = append(plan.Changes, ConfigPlanChange{
+				Kind:    ConfigPlanKindVendor,
+				Name:    desired.Name,
+				Action:  ConfigPlanActionCreate,
+				Changes: diffConfigFields(ConfigBundleVendor{}, desired),
+				apply: func(state *configApplyState) error {
+					now := common.GetTimestamp()
+					vendor := &Vendor{Name: desired.Name, Description: desired.Description, Icon: desired.Icon, Status: desired.Status, CreatedTime: now, UpdatedTime: now}
+					if err := state.tx.Create(vendor).Error; err != nil {
+						return err
+					}
+					// 零值字段在新建时会被数据库默认值替代
+					vendor.Status = desired.Status
+					if err := state.tx.Model(vendor).Select("status").Updates(vendor).Error; err != nil {
+						return err
+					}
+					state.vendorIds[vendor.Name] = vendor.Id
+					return nil
+				},
+			})
+			continue
+		}
+		changes := diffConfigFields(ConfigBundleVendor{Name: current.Name, Description: current.Description, Icon: current.Icon, Status: current.Status}, desired)
+		if len(changes) == 0 {
+			plan.Unchanged++
+			continue
+		}
+		vendorId := current.Id
+		plan.Changes = append(plan.Changes, ConfigPlanChange{
+			Kind:    ConfigPlanKindVendor,
+			Name:    desired.Name,
+			Action:  ConfigPlanActionUpdate,
+			Changes: changes,
+			apply: func(state *configApplyState) error {
+				return state.tx.Model(&Vendor{}).Where("id = ?", vendorId).Select("description", "icon", "status", "updated_time").Updates(&Vendor{
+					Description: desired.Description,
+					Icon:        desired.Icon,
+					Status:      desired.Status,
+					UpdatedTime: common.GetTimestamp(),
+				}).Error
+			},
+		})
+	}
+	return nil
+}
+
+func planConfigModels(bundle *ConfigBundle, plan *ConfigPlan) error {
+	var vendors []*Vendor
+	if err := DB.Find(&vendors).Error; err != nil {
+		return err
+	}
+	vendorNames := make(map[int]string, len(vendors))
+	knownVendors := make(map[string]bool, len(vendors)+len(bundle.Vendors))
+	for _, vendor := range vendors {
+		vendorNames[vendor.Id] = vendor.Name
+		knownVendors[vendor.Name] = true
+	}
+	for _, vendor := range bundle.Vendors {
+		knownVendors[vendor.Name] = true
+	}
+	var models []*Model
+	if err := DB.Find(&models).Error; err != nil {
+		return err
+	}
+	existing := make(map[string]*Model, len(models))
+	for _, m := range models {
+		existing[m.ModelName] = m
+	}
+	seen := make(map[string]bool)
+	for _, desired := range bundle.Models {
+		desired := desired
+		if desired.ModelName == "" || seen[desired.ModelName] {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("模型名称 %q 为空或重复", desired.ModelName))
+			continue
+		}
+		seen[desired.ModelName] = true
+		if desired.Vendor != "" && !knownVendors[desired.Vendor] {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("模型 %s 的供应商 %s 不存在", desired.ModelName, desired.Vendor))
+			continue
+		}
+		toModel := func(state *configApplyState, m *Model) {
+			m.ModelName = desired.ModelName
+			m.Description = desired.Description
+			m.Icon = desired.Icon
+			m.Tags = desired.Tags
+			m.VendorID = state.vendorIds[desired.Vendor]
+			m.Endpoints = desired.Endpoints
+			m.Status = desired.Status
+			m.SyncOfficial = desired.SyncOfficial
+			m.NameRule = desired.NameRule
+		}
+		current, ok := existing[desired.ModelName]
+		if !ok {
+			plan.Changes = append(plan.Changes, ConfigPlanChange{
+				Kind:    ConfigPlanKindModel,
+				Name:    desired.ModelName,
+				Action:  ConfigPlanActionCreate,
+				Changes: diffConfigFields(ConfigBundleModel{}, desired),
+				apply: func(state *configApplyState) error {
+					m := &Model{}
+					toModel(state, m)
+					m.CreatedTime = common.GetTimestamp()
+					m.UpdatedTime = m.CreatedTime
+					if err := state.tx.Create(m).Error; err != nil {
+						return err
+					}
+					toModel(state, m)
+					// 零值字段在新建时会被数据库默认值替代
+					return state.tx.Model(m).Select("status", "sync_official", "name_rule").Updates(m).Error
+				},
+			})
+			continue
+		}
+		changes := diffConfigFields(newConfigBundleModel(current, vendorNames[current.VendorID]), desired)
+		if len(changes) == 0 {
+			plan.Unchanged++
+			continue
+		}
+		plan.Changes = append(plan.Changes, ConfigPlanChange{
+			Kind:    ConfigPlanKindModel,
+			Name:    desired.ModelName,
+			Action:  ConfigPlanActionUpdate,
+			Changes: changes,
+			apply: func(state *configApplyState) error {
+				m := *current
+				toModel(state, &m)
+				m.UpdatedTime = common.GetTimestamp()
+				return state.tx.Model(&Model{}).Where("id = ?", m.Id).Omit("created_time").Select("*").Updates(&m).Error
+			},
+		})
+	}
+	return nil
+}
+
+func planConfigOptions(bundle *ConfigBundle, plan *ConfigPlan) {
+	globalConfigs := config.GlobalConfig.ExportAllConfigs()
+	keys := make([]string, 0, len(bundle.Options))
+	for key := range bundle.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	common.OptionMapRWMutex.RLock()
+	defer common.OptionMapRWMutex.RUnlock()
+	for _, key := range keys {
+		value := bundle.Options[key]
+		if !isBundleOptionKey(key, globalConfigs) {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("选项 %s 不支持通过配置包导入", key))
+			continue
+		}
+		if !strings.Contains(key, ".") && value != "" {
+			// 倍率类选项均为 JSON
+			var parsed any
+			if err := common.Unmarshal([]byte(value), &parsed); err != nil {
+				plan.Errors = append(plan.Errors, fmt.Sprintf("选项 %s 不是合法的 JSON：%s", key, err.Error()))
+				continue
+			}
+		}
+		current := common.OptionMap[key]
+		if current == value {
+			plan.Unchanged++
+			continue
+		}
+		change := ChannelFieldChange{Field: key, Old: current, New: value}
+		if isSecretOptionKey(key) {
+			change.Old, change.New = channelVersionRedacted, channelVersionRedacted
+		}
+		key := key
+		plan.Changes = append(plan.Changes, ConfigPlanChange{
+			Kind:    ConfigPlanKindOption,
+			Name:    key,
+			Action:  ConfigPlanActionUpdate,
+			Changes: []ChannelFieldChange{change},
+			apply: func(state *configApplyState) error {
+				option := Option{Key: key}
+				if err := state.tx.FirstOrCreate(&option, Option{Key: key}).Error; err != nil {
+					return err
+				}
+				option.Value = value
+				if err := state.tx.Save(&option).Error; err != nil {
+					return err
+				}
+				state.options[key] = value
+				return nil
+			},
+		})
+	}
+}
+
+func planConfigChannels(bundle *ConfigBundle, plan *ConfigPlan) error {
+	var channels []*Channel
+	if err := DB.Find(&channels).Error; err != nil {
+		return err
+	}
+	existing := make(map[string]*Channel, len(channels))
+	duplicated := make(map[string]bool)
+	channelNames := make(map[int]string, len(channels))
+	for _, channel := range channels {
+		if _, ok := existing[channel.Name]; ok {
+			duplicated[channel.Name] = true
+		}
+		existing[channel.Name] = channel
+		channelNames[channel.Id] = channel.Name
+	}
+	bundleNames := make(map[string]bool, len(bundle.Channels))
+	for _, desired := range bundle.Channels {
+		bundleNames[desired.Name] = true
+	}
+
+	seen := make(map[string]bool)
+	for _, desired := range bundle.Channels {
+		desired := desired
+		desired.normalize()
+		if desired.Name == "" || seen[desired.Name] {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("渠道名称 %q 为空或重复", desired.Name))
+			continue
+		}
+		seen[desired.Name] = true
+		if duplicated[desired.Name] {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("当前存在多个名为 %s 的渠道，无法匹配", desired.Name))
+			continue
+		}
+		if desired.FallbackChannel != "" {
+			if desired.FallbackChannel == desired.Name {
+				plan.Errors = append(plan.Errors, fmt.Sprintf("渠道 %s 不能以自身作为兜底渠道", desired.Name))
+				continue
+			}
+			if _, ok := existing[desired.FallbackChannel]; !ok && !bundleNames[desired.FallbackChannel] {
+				plan.Errors = append(plan.Errors, fmt.Sprintf("渠道 %s 的兜底渠道 %s 不存在", desired.Name, desired.FallbackChannel))
+				continue
+			}
+		}
+		if err := desired.ToChannel().ValidateSettings(); err != nil {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("渠道 %s 的额外设置格式错误：%s", desired.Name, err.Error()))
+			continue
+		}
+
+		current, ok := existing[desired.Name]
+		if !ok {
+			if desired.Key == "" {
+				plan.Errors = append(plan.Errors, fmt.Sprintf("新建渠道 %s 缺少密钥", desired.Name))
+				continue
+			}
+			withoutKey := desired
+			withoutKey.Key = ""
+			changes := diffConfigFields(ConfigBundleChannel{}, withoutKey)
+			changes = append(changes, ChannelFieldChange{Field: "key", Old: nil, New: channelVersionRedacted})
+			plan.Changes = append(plan.Changes, ConfigPlanChange{
+				Kind:    ConfigPlanKindChannel,
+				Name:    desired.Name,
+				Action:  ConfigPlanActionCreate,
+				Changes: changes,
+				apply: func(state *configApplyState) error {
+					channel := desired.ToChannel()
+					channel.CreatedTime = common.GetTimestamp()
+					if channel.ChannelInfo.IsMultiKey {
+						channel.ChannelInfo.MultiKeySize = len(channel.GetKeys())
+					}
+					if err := state.tx.Create(channel).Error; err != nil {
+						return err
+					}
+					if err := channel.AddAbilities(state.tx); err != nil {
+						return err
+					}
+					state.channelIds[channel.Name] = channel.Id
+					state.pendingFallbacks[channel.Id] = desired.FallbackChannel
+					state.channelIdsSaved = append(state.channelIdsSaved, channel.Id)
+					return nil
+				},
+			})
+			continue
+		}
+
+		fallbackName := ""
+		if current.FallbackChannelId != nil {
+			fallbackName = channelNames[*current.FallbackChannelId]
+		}
+		withoutKey := desired
+		withoutKey.Key = ""
+		currentConfig := newConfigBundleChannel(current, fallbackName, false)
+		currentConfig.Status = configChannelStatus(currentConfig.Status)
+		changes := diffConfigFields(currentConfig, withoutKey)
+		updateKey := desired.Key != "" && desired.Key != current.Key
+		if updateKey {
+			changes = append(changes, ChannelFieldChange{Field: "key", Old: channelVersionRedacted, New: channelVersionRedacted})
+		}
+		if len(changes) == 0 {
+			plan.Unchanged++
+			continue
+		}
+		channelId := current.Id
+		fallbackChanged := desired.FallbackChannel != fallbackName
+		// 未修改状态时保留运行时状态，避免重新启用被自动禁用的渠道
+		statusChanged := currentConfig.Status != desired.Status
+		plan.Changes = append(plan.Changes, ConfigPlanChange{
+			Kind:    ConfigPlanKindChannel,
+			Name:    desired.Name,
+			Action:  ConfigPlanActionUpdate,
+			Changes: changes,
+			apply: func(state *configApplyState) error {
+				channel := &Channel{}
+				if err := state.tx.Where("id = ?", channelId).First(channel).Error; err != nil {
+					return err
+				}
+				columns := append([]string{}, channelVersionColumns...)
+				if statusChanged {
+					columns = append(columns, "Status")
+				}
+				updated := desired.ToChannel()
+				updated.Id = channelId
+				updated.FallbackChannelId = channel.FallbackChannelId
+				updated.ChannelInfo = channel.ChannelInfo
+				updated.ChannelInfo.IsMultiKey = desired.MultiKey
+				updated.ChannelInfo.MultiKeyMode = constant.MultiKeyMode(desired.MultiKeyMode)
+				if updateKey {
+					columns = append(columns, "Key")
+				} else {
+					updated.Key = channel.Key
+				}
+				if updated.ChannelInfo.IsMultiKey {
+					updated.ChannelInfo.MultiKeySize = len(updated.GetKeys())
+				}
+				if !statusChanged {
+					updated.Status = channel.Status
+				}
+				if err := state.tx.Model(&Channel{}).Where("id = ?", channelId).Select(columns).Updates(updated).Error; err != nil {
+					return err
+				}
+				if err := updated.UpdateAbilities(state.tx); err != nil {
+					return err
+				}
+				if fallbackChanged {
+					state.pendingFallbacks[channelId] = desired.FallbackChannel
+				}
+				state.channelIdsSaved = append(state.channelIdsSaved, channelId)
+				return nil
+			},
+		})
+	}
+	return nil
+}
+
+// ApplyConfigBundle 按计划导入配置包；expectedHash 非空时要求与当前计算出的计划一致，避免执行过期的计划。
+// 所有修改在同一个事务中写入，出错时全部回滚并返回已生成的计划
+func ApplyConfigBundle(bundle *ConfigBundle, expectedHash string, operatorId int, operator string) (*ConfigPlan, error) {
+	plan, err := PlanConfigBundle(bundle)
+	if err != nil {
+		return nil, err
+	}
+	if len(plan.Errors) > 0 {
+		return plan, fmt.Errorf("配置包校验失败：%s", strings.Join(plan.Errors, "；"))
+	}
+	if expectedHash != "" && expectedHash != plan.Hash {
+		return plan, fmt.Errorf("当前数据与计划生成时不一致，请重新生成计划")
+	}
+
+	state := &configApplyState{
+		options:          make(map[string]string),
+		channelIds:       make(map[string]int),
+		vendorIds:        make(map[string]int),
+		pendingFallbacks: make(map[int]string),
+	}
+	var vendors []*Vendor
+	if err := DB.Find(&vendors).Error; err != nil {
+		return plan, err
+	}
+	for _, vendor := range vendors {
+		state.vendorIds[vendor.Name] = vendor.Id
+	}
+	var channels []*Channel
+	if err := DB.Select("id", "name").Find(&channels).Error; err != nil {
+		return plan, err
+	}
+	for _, channel := range channels {
+		state.channelIds[channel.Name] = channel.Id
+	}
+	// 修改前的版本记录在事务之外写入，回滚后仍然是当前配置
+	for _, change := range plan.Changes {
+		if change.Kind == ConfigPlanKindChannel && change.Action == ConfigPlanActionUpdate {
+			if err := EnsureChannelBaseline(state.channelIds[change.Name]); err != nil {
+				return plan, err
+			}
+		}
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		state.tx = tx
+		for _, change := range plan.Changes {
+			if err := change.apply(state); err != nil {
+				return fmt.Errorf("%s %s 导入失败：%s", change.Kind, change.Name, err.Error())
+			}
+		}
+		for channelId, fallbackName := range state.pendingFallbacks {
+			var fallbackId *int
+			if fallbackName != "" {
+				id := state.channelIds[fallbackName]
+				fallbackId = &id
+			}
+			if err := tx.Model(&Channel{}).Where("id = ?", channelId).Update("fallback_channel_id", fallbackId).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return plan, err
+	}
+	for key, value := range state.options {
+		if err := updateOptionMap(key, value); err != nil {
+			common.SysLog(fmt.Sprintf("failed to update option map: key=%s, error=%v", key, err))
+		}
+	}
+	for _, channelId := range state.channelIdsSaved {
+		if err := RecordChannelVersion(channelId, ChannelVersionActionImport, operatorId, operator); err != nil {
+			common.SysLog(fmt.Sprintf("failed to record channel version: channel_id=%d, error=%v", channelId, err))
+		}
+	}
+	return plan, nil
+}
@@ -0,0 +1,138 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+func TestIsSecretOptionKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"GitHubClientSecret", true},
+		{"StripeApiSecret", true},
+		{"TurnstileSecretKey", true},
+		{"SMTPToken", true},
+		{"EpayKey", true},
+		{"oidc.client_secret", true},
+		{"discord.client_secret", true},
+		{"media_storage_setting.s3_secret_key", true},
+		{"media_storage_setting.s3_access_key", true},
+		{"media_storage_setting.signing_secret", true},
+		{"some_setting.admin_password", true},
+		{"ModelRatio", false},
+		{"AutomaticDisableKeywords", false},
+		{"DisplayTokenStatEnabled", false},
+		{"PasswordLoginEnabled", false},
+		{"oidc.token_endpoint", false},
+		{"passkey.enabled", false},
+		{"model_substitution_setting.token_deviation", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := isSecretOptionKey(tt.key); got != tt.want {
+				t.Errorf("isSecretOptionKey(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanConfigBundleChannels(t *testing.T) {
+	setupTestDB(t, &Channel{}, &Ability{}, &Vendor{}, &Model{}, &Option{}, &ChannelVersion{})
+	oldMemoryCache := common.MemoryCacheEnabled
+	common.MemoryCacheEnabled = false
+	t.Cleanup(func() { common.MemoryCacheEnabled = oldMemoryCache })
+
+	channel := &Channel{Name: "primary", Type: 1, Key: "sk-a", Models: "gpt-4o", Group: "default", Status: common.ChannelStatusAutoDisabled}
+	if err := channel.Insert(); err != nil {
+		t.Fatal(err)
+	}
+	exported, err := ExportConfigBundle(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exported.Channels) != 1 || exported.Channels[0].Key != "" {
+		t.Fatalf("unexpected exported channels: %+v", exported.Channels)
+	}
+
+	tests := []struct {
+		name        string
+		edit        func(c *ConfigBundleChannel)
+		wantChanges int
+		wantFields  []string
+	}{
+		{"export round trip", func(c *ConfigBundleChannel) {}, 0, nil},
+		{"enabled status keeps auto disabled", func(c *ConfigBundleChannel) { c.Status = common.ChannelStatusEnabled }, 0, nil},
+		{"manual disable", func(c *ConfigBundleChannel) { c.Status = common.ChannelStatusManuallyDisabled }, 1, []string{"status"}},
+		{"models changed", func(c *ConfigBundleChannel) { c.Models = "gpt-4o,gpt-4o-mini" }, 1, []string{"models"}},
+		{"key changed", func(c *ConfigBundleChannel) { c.Key = "sk-b" }, 1, []string{"key"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundle := &ConfigBundle{Version: ConfigBundleVersion, Channels: []ConfigBundleChannel{exported.Channels[0]}}
+			tt.edit(&bundle.Channels[0])
+			plan, err := PlanConfigBundle(bundle)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(plan.Errors) > 0 {
+				t.Fatalf("unexpected plan errors: %v", plan.Errors)
+			}
+			if len(plan.Changes) != tt.wantChanges {
+				t.Fatalf("got %d changes, want %d: %+v", len(plan.Changes), tt.wantChanges, plan.Changes)
+			}
+			if tt.wantChanges == 0 {
+				return
+			}
+			fields := make([]string, 0)
+			for _, change := range plan.Changes[0].Changes {
+				fields = append(fields, change.Field)
+			}
+			if len(fields) != len(tt.wantFields) || fields[0] != tt.wantFields[0] {
+				t.Errorf("changed fields = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+
+	t.Run("apply keeps runtime status", func(t *testing.T) {
+		bundle := &ConfigBundle{Version: ConfigBundleVersion, Channels: []ConfigBundleChannel{exported.Channels[0]}}
+		bundle.Channels[0].Status = common.ChannelStatusEnabled
+		bundle.Channels[0].Models = "gpt-4o,gpt-4o-mini"
+		if _, err := ApplyConfigBundle(bundle, "", 1, "root"); err != nil {
+			t.Fatal(err)
+		}
+		updated, err := GetChannelById(channel.Id, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated.Status != common.ChannelStatusAutoDisabled || updated.Models != "gpt-4o,gpt-4o-mini" {
+			t.Errorf("got status %d models %q", updated.Status, updated.Models)
+		}
+	})
+
+	t.Run("failed apply rolls back", func(t *testing.T) {
+		bundle := &ConfigBundle{
+			Version: ConfigBundleVersion,
+			Vendors: []ConfigBundleVendor{{Name: "vendor-a", Status: 1}},
+			Channels: []ConfigBundleChannel{
+				{Name: "created", Type: 1, Key: "sk-c", Models: "gpt-4o", Group: "default"},
+			},
+		}
+		// 使渠道写入失败
+		if err := DB.Migrator().DropTable(&Ability{}); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = DB.AutoMigrate(&Ability{}) })
+		if _, err := ApplyConfigBundle(bundle, "", 1, "root"); err == nil {
+			t.Fatal("expected apply to fail")
+		}
+		var vendors, channels int64
+		DB.Model(&Vendor{}).Count(&vendors)
+		DB.Model(&Channel{}).Where("name = ?", "created").Count(&channels)
+		if vendors != 0 || channels != 0 {
+			t.Errorf("partial import left %d vendors and %d channels", vendors, channels)
+		}
+	})
+}
@@ -37,6 +37,9 @@ type Log struct {
 	Group            string `json:"group" gorm:"index"`
 	Ip               string `json:"ip" gorm:"index;default:''"`
 	Other            string `json:"other"`
+	// 以下字段仅用于接口返回，按用户展示币种换算
+	Amount   float64 `json:"amount,omitempty" gorm:"-:all"`
+	Currency string  `json:"currency,omitempty" gorm:"-:all"`
 }
 
 // don't use iota, avoid change log type value
@@ -151,9 +154,18 @@ type RecordConsumeLogParams struct {
 	IsStream         bool                   `json:"is_stream"`
 	Group            string                 `json:"group"`
 	Other            map[string]interface{} `json:"other"`
+	// 渠道测试等不向用户计费的记录，不计入用户用量
+	NotBilled bool `json:"-"`
 }
 
 func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams) {
+	if !params.NotBilled {
+		RecordUserUsage(userId, params)
+		// 折扣活动在结算时计入使用次数
+		if campaignId, ok := params.Other["campaign_id"].(int); ok {
+			RecordCampaignHit(campaignId)
+		}
+	}
 	if !common.LogConsumeEnabled {
 		return
 	}
@@ -320,6 +332,31 @@ type Stat struct {
 	Tpm   int `json:"tpm"`
 }
 
+// GetConsumeLogsAfterId 按 id 升序分批读取消费日志，用于离线重算等统计场景
+func GetConsumeLogsAfterId(afterId int, batchSize int, startTimestamp int64, endTimestamp int64, modelName string, username string, channel int, group string) (logs []*Log, err error) {
+	tx := LOG_DB.Where("logs.type = ? and logs.id > ?", LogTypeConsume, afterId)
+	if modelName != "" {
+		tx = tx.Where("logs.model_name like ?", modelName)
+	}
+	if username != "" {
+		tx = tx.Where("logs.username = ?", username)
+	}
+	if startTimestamp != 0 {
+		tx = tx.Where("logs.created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("logs.created_at <= ?", endTimestamp)
+	}
+	if channel != 0 {
+		tx = tx.Where("logs.channel_id = ?", channel)
+	}
+	if group != "" {
+		tx = tx.Where("logs."+logGroupCol+" = ?", group)
+	}
+	err = tx.Order("logs.id asc").Limit(batchSize).Find(&logs).Error
+	return logs, err
+}
+
 func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, channel int, group string) (stat Stat) {
 	tx := LOG_DB.Table("logs").Select("sum(quota) quota")
 
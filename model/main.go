@@ -268,6 +268,16 @@ func migrateDB() error {
 		&Setup{},
 		&TwoFA{},
 		&TwoFABackupCode{},
+		&Statement{},
+		&UserUsage{},
+		&UserMonthlyQuota{},
+		&Campaign{},
+		&CampaignUsage{},
+		&TaskCallbackDelivery{},
+		&MediaObject{},
+		&ChannelVersion{},
+		&ChannelTestSuite{},
+		&ChannelTestRun{},
 	)
 	if err != nil {
 		return err
@@ -302,6 +312,16 @@ func migrateDBFast() error {
 		{&Setup{}, "Setup"},
 		{&TwoFA{}, "TwoFA"},
 		{&TwoFABackupCode{}, "TwoFABackupCode"},
+		{&Statement{}, "Statement"},
+		{&UserUsage{}, "UserUsage"},
+		{&UserMonthlyQuota{}, "UserMonthlyQuota"},
+		{&Campaign{}, "Campaign"},
+		{&CampaignUsage{}, "CampaignUsage"},
+		{&TaskCallbackDelivery{}, "TaskCallbackDelivery"},
+		{&MediaObject{}, "MediaObject"},
+		{&ChannelVersion{}, "ChannelVersion"},
+		{&ChannelTestSuite{}, "ChannelTestSuite"},
+		{&ChannelTestRun{}, "ChannelTestRun"},
 	}
 	// 动态计算migration数量，确保errChan缓冲区足够大
 	errChan := make(chan error, len(migrations))
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupTestDB 使用内存 SQLite 初始化 DB 与 LOG_DB，并迁移测试需要的表
+func setupTestDB(t *testing.T, models ...interface{}) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to get test database: %v", err)
+	}
+	// 内存数据库每个连接相互独立
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(append([]interface{}{&User{}, &Log{}}, models...)...); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	oldDB, oldLogDB := DB, LOG_DB
+	oldSQLite, oldRedis := common.UsingSQLite, common.RedisEnabled
+	DB, LOG_DB = db, db
+	common.UsingSQLite, common.RedisEnabled = true, false
+	initCol()
+	t.Cleanup(func() {
+		DB, LOG_DB = oldDB, oldLogDB
+		common.UsingSQLite, common.RedisEnabled = oldSQLite, oldRedis
+		_ = sqlDB.Close()
+	})
+}
@@ -0,0 +1,107 @@
+package model
+
+import (
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	MediaSourceVideo      = "video"
+	MediaSourceMidjourney = "midjourney"
+	MediaSourceImage      = "image"
+	MediaSourceAudio      = "audio"
+)
+
+// MediaObject 已归档的生成结果
+type MediaObject struct {
+	Id          int    `json:"id"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	Source      string `json:"source" gorm:"type:varchar(20);index:idx_media_source_task,priority:1"`
+	TaskId      string `json:"task_id" gorm:"type:varchar(191);index:idx_media_source_task,priority:2"`
+	Backend     string `json:"backend" gorm:"type:varchar(16)"`
+	StorageKey  string `json:"storage_key" gorm:"type:varchar(512)"`
+	ContentType string `json:"content_type" gorm:"type:varchar(128)"`
+	Size        int64  `json:"size" gorm:"bigint;default:0"`
+	OriginUrl   string `json:"origin_url" gorm:"type:text"`
+	CreatedAt   int64  `json:"created_at" gorm:"bigint;index"`
+	ExpiresAt   int64  `json:"expires_at" gorm:"bigint;index"` // 0 表示永久保留
+}
+
+func (object *MediaObject) Insert() error {
+	object.CreatedAt = common.GetTimestamp()
+	return DB.Create(object).Error
+}
+
+// UpdateStorage 预留记录写入文件后更新存储信息
+func (object *MediaObject) UpdateStorage() error {
+	result := DB.Model(object).Select("storage_key", "content_type", "size").Updates(object)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// 记录已被清理，由调用方删除已写入的文件
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// IsPending 预留的归档记录尚未写入文件
+func (object *MediaObject) IsPending() bool {
+	return object.StorageKey == ""
+}
+
+func (object *MediaObject) Delete() error {
+	return DB.Delete(object).Error
+}
+
+func GetMediaObjectById(id int) (*MediaObject, error) {
+	object := &MediaObject{}
+	err := DB.First(object, "id = ?", id).Error
+	return object, err
+}
+
+// GetMediaObjectByOrigin 查询同一任务同一来源地址的归档，避免重复归档
+func GetMediaObjectByOrigin(source string, taskId string, originUrl string) *MediaObject {
+	object := &MediaObject{}
+	err := DB.Where("source = ? AND task_id = ? AND origin_url = ?", source, taskId, originUrl).First(object).Error
+	if err != nil {
+		return nil
+	}
+	return object
+}
+
+// GetExpiredMediaObjects 获取已过保留期的归档
+func GetExpiredMediaObjects(now int64, limit int) ([]*MediaObject, error) {
+	var objects []*MediaObject
+	err := DB.Where("expires_at > 0 AND expires_at <= ?", now).Order("expires_at asc").Limit(limit).Find(&objects).Error
+	return objects, err
+}
+
+// GetUserMediaUsage 返回用户已占用的存储字节数
+func GetUserMediaUsage(userId int) (int64, error) {
+	var total int64
+	err := DB.Model(&MediaObject{}).Where("user_id = ?", userId).Select("coalesce(sum(size), 0)").Scan(&total).Error
+	return total, err
+}
+
+// GetUserOldestMediaObjects 按创建时间升序获取用户的归档
+func GetUserOldestMediaObjects(userId int, limit int) ([]*MediaObject, error) {
+	var objects []*MediaObject
+	err := DB.Where("user_id = ?", userId).Order("id asc").Limit(limit).Find(&objects).Error
+	return objects, err
+}
+
+func GetUserMediaObjects(userId int, startIdx int, num int) ([]*MediaObject, int64, error) {
+	var objects []*MediaObject
+	var total int64
+	query := DB.Model(&MediaObject{})
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id desc").Limit(num).Offset(startIdx).Find(&objects).Error
+	return objects, total, err
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestMediaObjectUpdateStorage(t *testing.T) {
+	setupTestDB(t, &MediaObject{})
+	object := &MediaObject{UserId: 1, Source: MediaSourceImage, TaskId: "req_1", Backend: "local", OriginUrl: "https://example.com/a.png"}
+	if err := object.Insert(); err != nil {
+		t.Fatalf("insert media object: %v", err)
+	}
+	if !object.IsPending() {
+		t.Fatal("reserved object should be pending")
+	}
+
+	object.StorageKey = "image/1/a.png"
+	object.ContentType = "image/png"
+	object.Size = 3
+	if err := object.UpdateStorage(); err != nil {
+		t.Fatalf("UpdateStorage: %v", err)
+	}
+	latest, err := GetMediaObjectById(object.Id)
+	if err != nil {
+		t.Fatalf("get media object: %v", err)
+	}
+	if latest.IsPending() || latest.StorageKey != "image/1/a.png" || latest.Size != 3 || latest.OriginUrl != "https://example.com/a.png" {
+		t.Fatalf("media object = %+v", latest)
+	}
+
+	// 写入期间记录被清理时返回错误，调用方需删除已写入的文件
+	if err := latest.Delete(); err != nil {
+		t.Fatalf("delete media object: %v", err)
+	}
+	if err := object.UpdateStorage(); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("UpdateStorage after delete = %v, want ErrRecordNotFound", err)
+	}
+}
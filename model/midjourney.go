@@ -1,5 +1,14 @@
 package model
 
+import (
+	"sort"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/constant"
+
+	"gorm.io/gorm"
+)
+
 type Midjourney struct {
 	Id          int    `json:"id"`
 	Code        int    `json:"code"`
@@ -23,6 +32,11 @@ type Midjourney struct {
 	Quota       int    `json:"quota"`
 	Buttons     string `json:"buttons"`
 	Properties  string `json:"properties"`
+	// 禁止返回给用户
+	CallbackUrl    string `json:"-" gorm:"type:varchar(512);default:''"`
+	CallbackSecret string `json:"-" gorm:"type:varchar(128);default:''"`
+	MediaObjectId  int    `json:"-" gorm:"default:0"`           // 已归档的图片
+	TaskMigrated   bool   `json:"-" gorm:"default:false;index"` // 已迁移到 tasks 表，由统一任务框架更新
 }
 
 // TaskQueryParams 用于包含所有搜索条件的结构体，可以根据需求添加更多字段
@@ -34,67 +48,87 @@ type TaskQueryParams struct {
 }
 
 func GetAllUserTask(userId int, startIdx int, num int, queryParams TaskQueryParams) []*Midjourney {
-	var tasks []*Midjourney
-	var err error
+	return getMidjourneyPage(userId, startIdx, num, queryParams)
+}
 
-	// 初始化查询构建器
-	query := DB.Where("user_id = ?", userId)
+func GetAllTasks(startIdx int, num int, queryParams TaskQueryParams) []*Midjourney {
+	return getMidjourneyPage(0, startIdx, num, queryParams)
+}
 
+// midjourneyLegacyQuery 查询 midjourneys 表中尚未迁移的旧任务，userId 为 0 时查询全部用户
+func midjourneyLegacyQuery(userId int, queryParams TaskQueryParams) *gorm.DB {
+	query := DB.Model(&Midjourney{}).Where("task_migrated = ?", false)
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if queryParams.ChannelID != "" {
+		query = query.Where("channel_id = ?", queryParams.ChannelID)
+	}
 	if queryParams.MjID != "" {
 		query = query.Where("mj_id = ?", queryParams.MjID)
 	}
 	if queryParams.StartTimestamp != "" {
-		// 假设您已将前端传来的时间戳转换为数据库所需的时间格式，并处理了时间戳的验证和解析
 		query = query.Where("submit_time >= ?", queryParams.StartTimestamp)
 	}
 	if queryParams.EndTimestamp != "" {
 		query = query.Where("submit_time <= ?", queryParams.EndTimestamp)
 	}
-
-	// 获取数据
-	err = query.Order("id desc").Limit(num).Offset(startIdx).Find(&tasks).Error
-	if err != nil {
-		return nil
-	}
-
-	return tasks
+	return query
 }
 
-func GetAllTasks(startIdx int, num int, queryParams TaskQueryParams) []*Midjourney {
-	var tasks []*Midjourney
-	var err error
-
-	// 初始化查询构建器
-	query := DB
-
-	// 添加过滤条件
+// midjourneyTaskQuery 查询 tasks 表中的 Midjourney 任务，查询参数中的时间为毫秒，tasks 表中为秒
+func midjourneyTaskQuery(userId int, queryParams TaskQueryParams) *gorm.DB {
+	query := DB.Model(&Task{}).Where("platform = ?", constant.TaskPlatformMidjourney)
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
 	if queryParams.ChannelID != "" {
 		query = query.Where("channel_id = ?", queryParams.ChannelID)
 	}
 	if queryParams.MjID != "" {
-		query = query.Where("mj_id = ?", queryParams.MjID)
+		query = query.Where("task_id = ?", queryParams.MjID)
 	}
-	if queryParams.StartTimestamp != "" {
-		query = query.Where("submit_time >= ?", queryParams.StartTimestamp)
+	if ts, err := strconv.ParseInt(queryParams.StartTimestamp, 10, 64); err == nil {
+		query = query.Where("submit_time >= ?", ts/1000)
 	}
-	if queryParams.EndTimestamp != "" {
-		query = query.Where("submit_time <= ?", queryParams.EndTimestamp)
+	if ts, err := strconv.ParseInt(queryParams.EndTimestamp, 10, 64); err == nil {
+		query = query.Where("submit_time <= ?", ts/1000)
 	}
+	return query
+}
 
-	// 获取数据
-	err = query.Order("id desc").Limit(num).Offset(startIdx).Find(&tasks).Error
+// getMidjourneyPage 合并旧表与 tasks 表中的 Midjourney 任务，按提交时间倒序分页
+func getMidjourneyPage(userId int, startIdx int, num int, queryParams TaskQueryParams) []*Midjourney {
+	limit := startIdx + num
+	var legacy []*Midjourney
+	err := midjourneyLegacyQuery(userId, queryParams).Order("submit_time desc").Order("id desc").Limit(limit).Find(&legacy).Error
 	if err != nil {
 		return nil
 	}
-
-	return tasks
+	var tasks []*Task
+	err = midjourneyTaskQuery(userId, queryParams).Order("submit_time desc").Order("id desc").Limit(limit).Find(&tasks).Error
+	if err != nil {
+		return nil
+	}
+	items := make([]*Midjourney, 0, len(legacy)+len(tasks))
+	items = append(items, legacy...)
+	for _, task := range tasks {
+		items = append(items, taskToMidjourney(task))
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].SubmitTime > items[j].SubmitTime
+	})
+	if startIdx >= len(items) {
+		return []*Midjourney{}
+	}
+	return items[startIdx:min(limit, len(items))]
 }
 
 func GetAllUnFinishTasks() []*Midjourney {
 	var tasks []*Midjourney
 	var err error
 	// get all tasks progress is not 100%
-	err = DB.Where("progress != ?", "100%").Find(&tasks).Error
+	err = DB.Where("progress != ? and task_migrated = ?", "100%", false).Find(&tasks).Error
 	if err != nil {
 		return nil
 	}
@@ -157,6 +191,21 @@ func (midjourney *Midjourney) Update() error {
 	return err
 }
 
+// UpdateIfNotMigrated 写回旧轮询与回调得到的任务状态，不覆盖 task_migrated；
+// 任务已迁移到 tasks 表时由统一任务框架负责，不更新并返回 false
+func (midjourney *Midjourney) UpdateIfNotMigrated() (bool, error) {
+	result := DB.Model(midjourney).Where("task_migrated = ?", false).Select("*").Omit("id", "task_migrated").Updates(midjourney)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// SetMidjourneyMediaObjectId 记录已归档的图片，只更新 media_object_id 字段
+func SetMidjourneyMediaObjectId(id int, mediaObjectId int) error {
+	return DB.Model(&Midjourney{}).Where("id = ?", id).Update("media_object_id", mediaObjectId).Error
+}
+
 func MjBulkUpdate(mjIds []string, params map[string]any) error {
 	return DB.Model(&Midjourney{}).
 		Where("mj_id in (?)", mjIds).
@@ -171,37 +220,17 @@ func MjBulkUpdateByTaskIds(taskIDs []int, params map[string]any) error {
 
 // CountAllTasks returns total midjourney tasks for admin query
 func CountAllTasks(queryParams TaskQueryParams) int64 {
-	var total int64
-	query := DB.Model(&Midjourney{})
-	if queryParams.ChannelID != "" {
-		query = query.Where("channel_id = ?", queryParams.ChannelID)
-	}
-	if queryParams.MjID != "" {
-		query = query.Where("mj_id = ?", queryParams.MjID)
-	}
-	if queryParams.StartTimestamp != "" {
-		query = query.Where("submit_time >= ?", queryParams.StartTimestamp)
-	}
-	if queryParams.EndTimestamp != "" {
-		query = query.Where("submit_time <= ?", queryParams.EndTimestamp)
-	}
-	_ = query.Count(&total).Error
-	return total
+	return countMidjourneyTasks(0, queryParams)
 }
 
 // CountAllUserTask returns total midjourney tasks for user
 func CountAllUserTask(userId int, queryParams TaskQueryParams) int64 {
-	var total int64
-	query := DB.Model(&Midjourney{}).Where("user_id = ?", userId)
-	if queryParams.MjID != "" {
-		query = query.Where("mj_id = ?", queryParams.MjID)
-	}
-	if queryParams.StartTimestamp != "" {
-		query = query.Where("submit_time >= ?", queryParams.StartTimestamp)
-	}
-	if queryParams.EndTimestamp != "" {
-		query = query.Where("submit_time <= ?", queryParams.EndTimestamp)
-	}
-	_ = query.Count(&total).Error
-	return total
+	return countMidjourneyTasks(userId, queryParams)
+}
+
+func countMidjourneyTasks(userId int, queryParams TaskQueryParams) int64 {
+	var legacy, tasks int64
+	_ = midjourneyLegacyQuery(userId, queryParams).Count(&legacy).Error
+	_ = midjourneyTaskQuery(userId, queryParams).Count(&tasks).Error
+	return legacy + tasks
 }
@@ -0,0 +1,195 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+
+	"gorm.io/gorm"
+)
+
+// midjourneyStatusToTaskStatus 将 Midjourney Proxy 的状态转换为统一任务状态
+func midjourneyStatusToTaskStatus(mj *Midjourney) TaskStatus {
+	switch mj.Status {
+	case "SUCCESS":
+		return TaskStatusSuccess
+	case "FAILURE":
+		return TaskStatusFailure
+	case "IN_PROGRESS", "MODAL":
+		return TaskStatusInProgress
+	case "SUBMITTED":
+		return TaskStatusSubmitted
+	case "NOT_START":
+		return TaskStatusNotStart
+	}
+	if mj.FailReason != "" {
+		return TaskStatusFailure
+	}
+	return TaskStatusNotStart
+}
+
+func midjourneyToTask(mj *Midjourney) *Task {
+	mjDto := dto.MidjourneyDto{
+		MjId:        mj.MjId,
+		Action:      mj.Action,
+		Prompt:      mj.Prompt,
+		PromptEn:    mj.PromptEn,
+		Description: mj.Description,
+		State:       mj.State,
+		SubmitTime:  mj.SubmitTime,
+		StartTime:   mj.StartTime,
+		FinishTime:  mj.FinishTime,
+		ImageUrl:    mj.ImageUrl,
+		VideoUrl:    mj.VideoUrl,
+		Status:      mj.Status,
+		Progress:    mj.Progress,
+		FailReason:  mj.FailReason,
+	}
+	if mj.Buttons != "" {
+		var buttons []dto.ActionButton
+		if err := common.Unmarshal([]byte(mj.Buttons), &buttons); err == nil {
+			mjDto.Buttons = buttons
+		}
+	}
+	if mj.VideoUrls != "" {
+		var videoUrls []dto.ImgUrls
+		if err := common.Unmarshal([]byte(mj.VideoUrls), &videoUrls); err == nil {
+			mjDto.VideoUrls = videoUrls
+		}
+	}
+	if mj.Properties != "" {
+		var properties dto.Properties
+		if err := common.Unmarshal([]byte(mj.Properties), &properties); err == nil {
+			mjDto.Properties = &properties
+		}
+	}
+	data, _ := common.Marshal(mjDto)
+
+	status := midjourneyStatusToTaskStatus(mj)
+	task := &Task{
+		CreatedAt:  mj.SubmitTime / 1000,
+		UpdatedAt:  time.Now().Unix(),
+		TaskID:     mj.MjId,
+		Platform:   constant.TaskPlatformMidjourney,
+		UserId:     mj.UserId,
+		ChannelId:  mj.ChannelId,
+		Quota:      mj.Quota,
+		Action:     mj.Action,
+		Status:     status,
+		FailReason: mj.FailReason,
+		SubmitTime: mj.SubmitTime / 1000,
+		StartTime:  mj.StartTime / 1000,
+		FinishTime: mj.FinishTime / 1000,
+		Progress:   mj.Progress,
+		PrivateData: TaskPrivateData{
+			CallbackUrl:    mj.CallbackUrl,
+			CallbackSecret: mj.CallbackSecret,
+			MediaObjectId:  mj.MediaObjectId,
+		},
+		Data: data,
+	}
+	// 成功时 FailReason 字段保存的是结果地址
+	if status == TaskStatusSuccess {
+		task.FailReason = mj.ImageUrl
+		if mj.VideoUrl != "" {
+			task.FailReason = mj.VideoUrl
+		}
+	}
+	if status != TaskStatusSuccess && status != TaskStatusFailure {
+		// 未完成的任务交由统一轮询立即接管
+		task.NextPollAt = time.Now().Unix()
+	}
+	if strings.TrimSpace(task.Progress) == "" {
+		task.Progress = "0%"
+	}
+	return task
+}
+
+// taskToMidjourney 将 tasks 表中的 Midjourney 任务转换为旧表结构，用于任务列表展示
+func taskToMidjourney(task *Task) *Midjourney {
+	var mjDto dto.MidjourneyDto
+	_ = common.Unmarshal(task.Data, &mjDto)
+	mj := &Midjourney{
+		Id:            int(task.ID),
+		Code:          1,
+		UserId:        task.UserId,
+		Action:        task.Action,
+		MjId:          task.TaskID,
+		Prompt:        mjDto.Prompt,
+		PromptEn:      mjDto.PromptEn,
+		Description:   mjDto.Description,
+		State:         mjDto.State,
+		SubmitTime:    task.SubmitTime * 1000,
+		StartTime:     task.StartTime * 1000,
+		FinishTime:    task.FinishTime * 1000,
+		ImageUrl:      mjDto.ImageUrl,
+		VideoUrl:      mjDto.VideoUrl,
+		Status:        mjDto.Status,
+		Progress:      task.Progress,
+		ChannelId:     task.ChannelId,
+		Quota:         task.Quota,
+		MediaObjectId: task.PrivateData.MediaObjectId,
+		TaskMigrated:  true,
+	}
+	switch task.Status {
+	case TaskStatusSuccess:
+		mj.Status = string(task.Status)
+		// 成功时 FailReason 字段保存的是结果地址，视频任务的图片取上游返回的封面
+		if mjDto.VideoUrl == "" && task.FailReason != "" {
+			mj.ImageUrl = task.FailReason
+		}
+	case TaskStatusFailure:
+		mj.Status = string(task.Status)
+		mj.FailReason = task.FailReason
+	default:
+		if mj.Status == "" {
+			mj.Status = string(TaskStatusNotStart)
+		}
+	}
+	if mjDto.Buttons != nil {
+		buttons, _ := common.Marshal(mjDto.Buttons)
+		mj.Buttons = string(buttons)
+	}
+	if len(mjDto.VideoUrls) > 0 {
+		videoUrls, _ := common.Marshal(mjDto.VideoUrls)
+		mj.VideoUrls = string(videoUrls)
+	}
+	if mjDto.Properties != nil {
+		properties, _ := common.Marshal(mjDto.Properties)
+		mj.Properties = string(properties)
+	}
+	return mj
+}
+
+// MigrateMidjourneyToTasks 将一批未迁移的 Midjourney 任务复制到 tasks 表，返回本批处理的数量
+func MigrateMidjourneyToTasks(batchSize int) (int, error) {
+	var mjs []*Midjourney
+	err := DB.Where("task_migrated = ?", false).Order("id asc").Limit(batchSize).Find(&mjs).Error
+	if err != nil {
+		return 0, err
+	}
+	for i, mj := range mjs {
+		err = DB.Transaction(func(tx *gorm.DB) error {
+			// 提交失败的记录没有任务 ID，仅标记为已迁移
+			if mj.MjId != "" {
+				var count int64
+				if err := tx.Model(&Task{}).Where("platform = ? and task_id = ?", constant.TaskPlatformMidjourney, mj.MjId).Count(&count).Error; err != nil {
+					return err
+				}
+				if count == 0 {
+					if err := tx.Create(midjourneyToTask(mj)).Error; err != nil {
+						return err
+					}
+				}
+			}
+			return tx.Model(&Midjourney{}).Where("id = ?", mj.Id).Update("task_migrated", true).Error
+		})
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(mjs), nil
+}
@@ -0,0 +1,168 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+func TestMidjourneyListIncludesTasks(t *testing.T) {
+	setupTestDB(t, &Midjourney{}, &Task{})
+
+	// 旧表：一条未迁移、一条已迁移（已迁移的以 tasks 表为准）
+	legacy := []*Midjourney{
+		{UserId: 1, MjId: "legacy", Status: "SUCCESS", Progress: "100%", SubmitTime: 1000_000},
+		{UserId: 1, MjId: "migrated", Status: "SUCCESS", Progress: "100%", SubmitTime: 2000_000, TaskMigrated: true},
+	}
+	for _, mj := range legacy {
+		if err := mj.Insert(); err != nil {
+			t.Fatalf("insert midjourney: %v", err)
+		}
+	}
+	data, _ := common.Marshal(dto.MidjourneyDto{Prompt: "cat", Status: "IN_PROGRESS"})
+	tasks := []*Task{
+		{TaskID: "migrated", UserId: 1, Platform: constant.TaskPlatformMidjourney, Status: TaskStatusSuccess, Progress: "100%", SubmitTime: 2000, FailReason: "https://example.com/migrated.png", Data: data},
+		{TaskID: "new", UserId: 1, Platform: constant.TaskPlatformMidjourney, Status: TaskStatusInProgress, Progress: "50%", SubmitTime: 3000, Data: data},
+		{TaskID: "other_user", UserId: 2, Platform: constant.TaskPlatformMidjourney, Status: TaskStatusFailure, Progress: "100%", SubmitTime: 4000, FailReason: "banned"},
+		{TaskID: "video", UserId: 1, Platform: constant.TaskPlatformSuno, Status: TaskStatusSuccess, Progress: "100%", SubmitTime: 5000},
+	}
+	for _, task := range tasks {
+		if err := task.Insert(); err != nil {
+			t.Fatalf("insert task: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		userId   int
+		params   TaskQueryParams
+		startIdx int
+		num      int
+		want     []string
+		total    int64
+	}{
+		{name: "user", userId: 1, num: 10, want: []string{"new", "migrated", "legacy"}, total: 3},
+		{name: "user second page", userId: 1, startIdx: 2, num: 2, want: []string{"legacy"}, total: 3},
+		{name: "admin", num: 10, want: []string{"other_user", "new", "migrated", "legacy"}, total: 4},
+		{name: "by mj id", num: 10, params: TaskQueryParams{MjID: "migrated"}, want: []string{"migrated"}, total: 1},
+		{name: "by time in ms", num: 10, params: TaskQueryParams{StartTimestamp: "2500000", EndTimestamp: "3500000"}, want: []string{"new"}, total: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var items []*Midjourney
+			var total int64
+			if tt.userId != 0 {
+				items = GetAllUserTask(tt.userId, tt.startIdx, tt.num, tt.params)
+				total = CountAllUserTask(tt.userId, tt.params)
+			} else {
+				items = GetAllTasks(tt.startIdx, tt.num, tt.params)
+				total = CountAllTasks(tt.params)
+			}
+			got := make([]string, 0, len(items))
+			for _, item := range items {
+				got = append(got, item.MjId)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("items = %v, want %v", got, tt.want)
+			}
+			if total != tt.total {
+				t.Fatalf("total = %d, want %d", total, tt.total)
+			}
+		})
+	}
+}
+
+func TestTaskToMidjourney(t *testing.T) {
+	data, _ := common.Marshal(dto.MidjourneyDto{Prompt: "cat", Status: "IN_PROGRESS", ImageUrl: "https://example.com/preview.png"})
+	tests := []struct {
+		name       string
+		task       *Task
+		status     string
+		imageUrl   string
+		failReason string
+	}{
+		{
+			name:     "in progress keeps upstream status",
+			task:     &Task{Status: TaskStatusInProgress, Data: data},
+			status:   "IN_PROGRESS",
+			imageUrl: "https://example.com/preview.png",
+		},
+		{
+			name:     "success uses result url",
+			task:     &Task{Status: TaskStatusSuccess, FailReason: "https://example.com/result.png", Data: data},
+			status:   "SUCCESS",
+			imageUrl: "https://example.com/result.png",
+		},
+		{
+			name:       "failure",
+			task:       &Task{Status: TaskStatusFailure, FailReason: "banned", Data: data},
+			status:     "FAILURE",
+			imageUrl:   "https://example.com/preview.png",
+			failReason: "banned",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.task.SubmitTime = 10
+			mj := taskToMidjourney(tt.task)
+			if mj.Status != tt.status || mj.ImageUrl != tt.imageUrl || mj.FailReason != tt.failReason {
+				t.Fatalf("got status=%s image=%s fail=%q", mj.Status, mj.ImageUrl, mj.FailReason)
+			}
+			if mj.Prompt != "cat" || mj.SubmitTime != 10000 {
+				t.Fatalf("got prompt=%s submit_time=%d", mj.Prompt, mj.SubmitTime)
+			}
+		})
+	}
+}
+
+func TestMidjourneyUpdateIfNotMigrated(t *testing.T) {
+	tests := []struct {
+		name        string
+		migrate     bool
+		wantUpdated bool
+		wantStatus  string
+	}{
+		{name: "legacy row", wantUpdated: true, wantStatus: "FAILURE"},
+		{name: "migrated while polling", migrate: true, wantUpdated: false, wantStatus: "IN_PROGRESS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t, &Midjourney{}, &Task{})
+			mj := &Midjourney{UserId: 1, MjId: "mj_1", Status: "IN_PROGRESS", Progress: "50%", FailReason: "old", Quota: 100}
+			if err := mj.Insert(); err != nil {
+				t.Fatalf("insert midjourney: %v", err)
+			}
+			// 旧轮询在迁移前读取的行
+			stale := GetByOnlyMJId("mj_1")
+			if tt.migrate {
+				if _, err := MigrateMidjourneyToTasks(10); err != nil {
+					t.Fatalf("migrate: %v", err)
+				}
+			}
+
+			stale.Status = "FAILURE"
+			stale.Progress = "100%"
+			stale.FailReason = ""
+			updated, err := stale.UpdateIfNotMigrated()
+			if err != nil {
+				t.Fatalf("update: %v", err)
+			}
+			if updated != tt.wantUpdated {
+				t.Fatalf("updated = %v, want %v", updated, tt.wantUpdated)
+			}
+			got := GetByOnlyMJId("mj_1")
+			if got.Status != tt.wantStatus {
+				t.Fatalf("status = %q, want %q", got.Status, tt.wantStatus)
+			}
+			if got.TaskMigrated != tt.migrate {
+				t.Fatalf("task_migrated = %v, want %v", got.TaskMigrated, tt.migrate)
+			}
+			if tt.wantUpdated && got.FailReason != "" {
+				t.Fatalf("fail_reason = %q, want cleared", got.FailReason)
+			}
+		})
+	}
+}
@@ -15,18 +15,19 @@ import (
 )
 
 type Pricing struct {
-	ModelName              string                  `json:"model_name"`
-	Description            string                  `json:"description,omitempty"`
-	Icon                   string                  `json:"icon,omitempty"`
-	Tags                   string                  `json:"tags,omitempty"`
-	VendorID               int                     `json:"vendor_id,omitempty"`
-	QuotaType              int                     `json:"quota_type"`
-	ModelRatio             float64                 `json:"model_ratio"`
-	ModelPrice             float64                 `json:"model_price"`
-	OwnerBy                string                  `json:"owner_by"`
-	CompletionRatio        float64                 `json:"completion_ratio"`
-	EnableGroup            []string                `json:"enable_groups"`
-	SupportedEndpointTypes []constant.EndpointType `json:"supported_endpoint_types"`
+	ModelName              string                     `json:"model_name"`
+	Description            string                     `json:"description,omitempty"`
+	Icon                   string                     `json:"icon,omitempty"`
+	Tags                   string                     `json:"tags,omitempty"`
+	VendorID               int                        `json:"vendor_id,omitempty"`
+	QuotaType              int                        `json:"quota_type"`
+	ModelRatio             float64                    `json:"model_ratio"`
+	ModelPrice             float64                    `json:"model_price"`
+	OwnerBy                string                     `json:"owner_by"`
+	CompletionRatio        float64                    `json:"completion_ratio"`
+	EnableGroup            []string                   `json:"enable_groups"`
+	SupportedEndpointTypes []constant.EndpointType    `json:"supported_endpoint_types"`
+	TieredPrice            *ratio_setting.TieredPrice `json:"tiered_price,omitempty"`
 }
 
 type PricingVendor struct {
@@ -290,6 +291,9 @@ func updatePricing() {
 			pricing.ModelRatio = modelRatio
 			pricing.CompletionRatio = ratio_setting.GetCompletionRatio(model)
 			pricing.QuotaType = 0
+			if tieredPrice, ok := ratio_setting.GetTieredPrice(model); ok {
+				pricing.TieredPrice = &tieredPrice
+			}
 		}
 		pricingMap = append(pricingMap, pricing)
 	}
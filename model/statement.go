@@ -0,0 +1,189 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+
+	"gorm.io/gorm"
+)
+
+// Statement 后付费用户月度账单
+type Statement struct {
+	Id          int     `json:"id"`
+	UserId      int     `json:"user_id" gorm:"index;uniqueIndex:idx_statement_user_period,priority:1"`
+	Username    string  `json:"username" gorm:"type:varchar(64);default:''"`
+	PeriodStart int64   `json:"period_start" gorm:"bigint;uniqueIndex:idx_statement_user_period,priority:2"` // 账期开始时间（含）
+	PeriodEnd   int64   `json:"period_end" gorm:"bigint"`                                                    // 账期结束时间（不含）
+	Quota       int     `json:"quota" gorm:"default:0"`                                                      // 账期内消耗额度
+	Amount      float64 `json:"amount" gorm:"type:double;default:0"`                                         // 账单金额（美元）
+	Status      string  `json:"status" gorm:"type:varchar(20);default:'pending';index"`
+	DueTime     int64   `json:"due_time" gorm:"bigint;index"` // 最后付款时间
+	PaidTime    int64   `json:"paid_time" gorm:"bigint;default:0"`
+	CreateTime  int64   `json:"create_time" gorm:"bigint"`
+	LineItems   string  `json:"line_items" gorm:"type:text"` // 账单明细（JSON）
+}
+
+// StatementLineItem 账单明细，按模型与令牌聚合
+type StatementLineItem struct {
+	ModelName        string `json:"model_name"`
+	TokenName        string `json:"token_name"`
+	RequestCount     int    `json:"request_count"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	Quota            int    `json:"quota"`
+}
+
+func (statement *Statement) Insert() error {
+	return DB.Create(statement).Error
+}
+
+func (statement *Statement) GetLineItems() []StatementLineItem {
+	items := make([]StatementLineItem, 0)
+	if statement.LineItems == "" {
+		return items
+	}
+	if err := common.UnmarshalJsonStr(statement.LineItems, &items); err != nil {
+		common.SysLog(fmt.Sprintf("failed to unmarshal statement %d line items: %s", statement.Id, err.Error()))
+	}
+	return items
+}
+
+func (statement *Statement) SetLineItems(items []StatementLineItem) {
+	statement.LineItems = common.GetJsonString(items)
+}
+
+func GetStatementById(id int) *Statement {
+	var statement Statement
+	if err := DB.Where("id = ?", id).First(&statement).Error; err != nil {
+		return nil
+	}
+	return &statement
+}
+
+func GetStatementByUserPeriod(userId int, periodStart int64) *Statement {
+	var statement Statement
+	if err := DB.Where("user_id = ? AND period_start = ?", userId, periodStart).First(&statement).Error; err != nil {
+		return nil
+	}
+	return &statement
+}
+
+// GetStatements 分页获取账单，userId 为 0 时返回所有用户的账单
+func GetStatements(userId int, status string, pageInfo *common.PageInfo) (statements []*Statement, total int64, err error) {
+	query := DB.Model(&Statement{})
+	if userId > 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err = query.Omit("line_items").Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&statements).Error; err != nil {
+		return nil, 0, err
+	}
+	return statements, total, nil
+}
+
+// GetStatementLineItems 从用户用量中获取指定账期按模型和令牌聚合的明细
+func GetStatementLineItems(userId int, periodStart int64) (items []StatementLineItem, err error) {
+	usages, err := GetUserUsageItems(userId, periodStart)
+	if err != nil {
+		return nil, err
+	}
+	items = make([]StatementLineItem, 0, len(usages))
+	for _, usage := range usages {
+		items = append(items, StatementLineItem{
+			ModelName:        usage.ModelName,
+			TokenName:        usage.TokenName,
+			RequestCount:     usage.RequestCount,
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			Quota:            usage.Quota,
+		})
+	}
+	return items, nil
+}
+
+// GetCreditUserIds 获取所有开通了信用额度（后付费）的用户
+func GetCreditUserIds() (userIds []int, err error) {
+	err = DB.Model(&User{}).Where("credit_limit > ?", 0).Pluck("id", &userIds).Error
+	return userIds, err
+}
+
+// MarkOverdueStatements 将超过付款期限的待付款账单标记为逾期，返回涉及的用户
+func MarkOverdueStatements(now int64) (userIds []int, err error) {
+	err = DB.Model(&Statement{}).
+		Where("status = ? AND due_time < ?", common.StatementStatusPending, now).
+		Distinct().Pluck("user_id", &userIds).Error
+	if err != nil || len(userIds) == 0 {
+		return userIds, err
+	}
+	err = DB.Model(&Statement{}).
+		Where("status = ? AND due_time < ?", common.StatementStatusPending, now).
+		Update("status", common.StatementStatusOverdue).Error
+	return userIds, err
+}
+
+func CountOverdueStatements(userId int) (count int64, err error) {
+	err = DB.Model(&Statement{}).Where("user_id = ? AND status = ?", userId, common.StatementStatusOverdue).Count(&count).Error
+	return count, err
+}
+
+// PayStatement 将账单标记为已付款，并以账单金额为限抵扣用户的透支欠款（余额回到不低于 0）
+func PayStatement(id int) (*Statement, error) {
+	statement := &Statement{}
+	settledQuota := 0
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", id).First(statement).Error
+		if err != nil {
+			return errors.New("账单不存在")
+		}
+		if statement.Status == common.StatementStatusPaid {
+			return errors.New("账单已结清")
+		}
+		statement.Status = common.StatementStatusPaid
+		statement.PaidTime = common.GetTimestamp()
+		if err := tx.Save(statement).Error; err != nil {
+			return err
+		}
+		user := &User{}
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").Select("id", "quota").Where("id = ?", statement.UserId).First(user).Error; err != nil {
+			return err
+		}
+		settledQuota = GetStatementSettleQuota(user.Quota, statement.Quota)
+		if settledQuota == 0 {
+			return nil
+		}
+		return tx.Model(&User{}).Where("id = ?", statement.UserId).Update("quota", gorm.Expr("quota + ?", settledQuota)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := invalidateUserCache(statement.UserId); err != nil {
+		common.SysLog("failed to invalidate user cache: " + err.Error())
+	}
+	RecordLog(statement.UserId, LogTypeTopup, fmt.Sprintf("账单 #%d 已结清，抵扣透支额度 %s", statement.Id, logger.LogQuota(settledQuota)))
+	return statement, nil
+}
+
+// GetStatementSettleQuota 结清账单时应返还的额度：只抵扣透支部分，且不超过账单额度
+func GetStatementSettleQuota(userQuota int, statementQuota int) int {
+	if userQuota >= 0 || statementQuota <= 0 {
+		return 0
+	}
+	return min(-userQuota, statementQuota)
+}
+
+// SetUserCreditSuspended 暂停或恢复用户信用额度
+func SetUserCreditSuspended(userId int, suspended bool) error {
+	err := DB.Model(&User{}).Where("id = ?", userId).Update("credit_suspended", suspended).Error
+	if err != nil {
+		return err
+	}
+	return invalidateUserCache(userId)
+}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+func TestGetStatementSettleQuota(t *testing.T) {
+	tests := []struct {
+		name           string
+		userQuota      int
+		statementQuota int
+		want           int
+	}{
+		{"positive balance", 100, 500, 0},
+		{"zero balance", 0, 500, 0},
+		{"debt below statement", -200, 500, 200},
+		{"debt equals statement", -500, 500, 500},
+		{"debt above statement", -800, 500, 500},
+		{"empty statement", -800, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetStatementSettleQuota(tt.userQuota, tt.statementQuota); got != tt.want {
+				t.Errorf("GetStatementSettleQuota(%d, %d) = %d, want %d", tt.userQuota, tt.statementQuota, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPayStatement(t *testing.T) {
+	tests := []struct {
+		name      string
+		userQuota int
+		quota     int
+		wantQuota int
+	}{
+		{"settles only the debt", -300, 1000, 0},
+		{"keeps prepaid balance", 200, 1000, 200},
+		{"debt above statement", -1500, 1000, -500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t, &Statement{})
+			user := &User{Username: "credit", Quota: tt.userQuota, CreditLimit: 2000}
+			if err := DB.Create(user).Error; err != nil {
+				t.Fatal(err)
+			}
+			statement := &Statement{UserId: user.Id, PeriodStart: 1, Quota: tt.quota, Status: common.StatementStatusPending}
+			if err := statement.Insert(); err != nil {
+				t.Fatal(err)
+			}
+			paid, err := PayStatement(statement.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if paid.Status != common.StatementStatusPaid || paid.PaidTime == 0 {
+				t.Errorf("statement not marked paid: %+v", paid)
+			}
+			var quota int
+			DB.Model(&User{}).Where("id = ?", user.Id).Select("quota").Scan(&quota)
+			if quota != tt.wantQuota {
+				t.Errorf("user quota = %d, want %d", quota, tt.wantQuota)
+			}
+			if _, err := PayStatement(statement.Id); err == nil {
+				t.Error("paying a paid statement should fail")
+			}
+		})
+	}
+}
+
+func TestStatementLineItemsWithoutConsumeLog(t *testing.T) {
+	setupTestDB(t, &UserUsage{}, &UserMonthlyQuota{})
+	enableUserUsageBatch(t)
+	oldLogConsume := common.LogConsumeEnabled
+	common.LogConsumeEnabled = false
+	t.Cleanup(func() { common.LogConsumeEnabled = oldLogConsume })
+
+	RecordUserUsage(1, RecordConsumeLogParams{ModelName: "gpt-4o", TokenName: "a", PromptTokens: 10, CompletionTokens: 5, Quota: 100})
+	RecordUserUsage(1, RecordConsumeLogParams{ModelName: "gpt-4o", TokenName: "a", PromptTokens: 20, CompletionTokens: 5, Quota: 200})
+	RecordUserUsage(1, RecordConsumeLogParams{ModelName: "gpt-4o-mini", TokenName: "a", PromptTokens: 1, CompletionTokens: 1, Quota: 50})
+	RecordUserUsage(2, RecordConsumeLogParams{ModelName: "gpt-4o", TokenName: "b", Quota: 999})
+	batchUpdateUserUsage()
+
+	items, err := GetStatementLineItems(1, GetUsagePeriodStart(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("got %d line items, want 2: %+v", len(items), items)
+	}
+	want := StatementLineItem{ModelName: "gpt-4o", TokenName: "a", RequestCount: 2, PromptTokens: 30, CompletionTokens: 10, Quota: 300}
+	if items[0] != want {
+		t.Errorf("items[0] = %+v, want %+v", items[0], want)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	commonRelay "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 )
 
 type TaskStatus string
@@ -57,6 +58,11 @@ type Task struct {
 	FinishTime int64                 `json:"finish_time" gorm:"index"`
 	Progress   string                `json:"progress" gorm:"type:varchar(20);index"`
 	Properties Properties            `json:"properties" gorm:"type:json"`
+	// 轮询调度
+	NextPollAt   int64  `json:"next_poll_at" gorm:"bigint;index;default:0"`
+	PollAttempts int    `json:"-" gorm:"default:0"` // 连续无进展的轮询次数，用于退避
+	LeaseOwner   string `json:"-" gorm:"type:varchar(64);default:''"`
+	LeaseUntil   int64  `json:"-" gorm:"bigint;default:0"`
 	// 禁止返回给用户，内部可能包含key等隐私信息
 	PrivateData TaskPrivateData `json:"-" gorm:"column:private_data;type:json"`
 	Data        json.RawMessage `json:"data" gorm:"type:json"`
@@ -76,6 +82,8 @@ type Properties struct {
 	Input             string `json:"input"`
 	UpstreamModelName string `json:"upstream_model_name,omitempty"`
 	OriginModelName   string `json:"origin_model_name,omitempty"`
+	// 按时长计费的任务预扣费时使用的时长（秒），完成后按实际时长结算
+	BillingSeconds float64 `json:"billing_seconds,omitempty"`
 }
 
 func (m *Properties) Scan(val interface{}) error {
@@ -95,7 +103,10 @@ func (m Properties) Value() (driver.Value, error) {
 }
 
 type TaskPrivateData struct {
-	Key string `json:"key,omitempty"`
+	Key            string `json:"key,omitempty"`
+	CallbackUrl    string `json:"callback_url,omitempty"`    // 任务状态回调地址
+	CallbackSecret string `json:"callback_secret,omitempty"` // 回调签名密钥
+	MediaObjectId  int    `json:"media_object_id,omitempty"` // 已归档的结果文件
 }
 
 func (p *TaskPrivateData) Scan(val interface{}) error {
@@ -145,6 +156,7 @@ func InitTask(platform constant.TaskPlatform, relayInfo *commonRelay.RelayInfo)
 		UserId:      relayInfo.UserId,
 		Group:       relayInfo.UsingGroup,
 		SubmitTime:  time.Now().Unix(),
+		NextPollAt:  time.Now().Unix() + operation_setting.GetTaskPollInterval(0),
 		Status:      TaskStatusNotStart,
 		Progress:    "0%",
 		ChannelId:   relayInfo.ChannelId,
@@ -294,6 +306,16 @@ func TaskUpdateProgress(id int64, progress string) error {
 	return DB.Model(&Task{}).Where("id = ?", id).Update("progress", progress).Error
 }
 
+// SetTaskMediaObjectId 记录任务结果的归档对象，只更新 private_data 字段
+func SetTaskMediaObjectId(id int64, mediaObjectId int) error {
+	task := &Task{}
+	if err := DB.Select("id", "private_data").First(task, "id = ?", id).Error; err != nil {
+		return err
+	}
+	task.PrivateData.MediaObjectId = mediaObjectId
+	return DB.Model(&Task{}).Where("id = ?", id).Update("private_data", task.PrivateData).Error
+}
+
 func (Task *Task) Insert() error {
 	var err error
 	err = DB.Create(Task).Error
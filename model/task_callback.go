@@ -0,0 +1,94 @@
+package model
+
+import (
+	"github.com/QuantumNous/new-api/common"
+)
+
+// TaskCallbackDelivery 异步任务回调投递记录
+type TaskCallbackDelivery struct {
+	Id           int    `json:"id"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	TaskId       string `json:"task_id" gorm:"type:varchar(191);index"`
+	Platform     string `json:"platform" gorm:"type:varchar(30)"`
+	Event        string `json:"event" gorm:"type:varchar(32)"` // 任务状态，如 SUCCESS、FAILURE
+	Url          string `json:"url" gorm:"type:varchar(512)"`
+	Secret       string `json:"-" gorm:"type:varchar(128)"`
+	Payload      string `json:"payload" gorm:"type:text"`
+	Status       string `json:"status" gorm:"type:varchar(16);index"`
+	Attempts     int    `json:"attempts" gorm:"default:0"`
+	ResponseCode int    `json:"response_code" gorm:"default:0"`
+	LastError    string `json:"last_error" gorm:"type:text"`
+	NextRetryAt  int64  `json:"next_retry_at" gorm:"bigint;index"`
+	CreatedAt    int64  `json:"created_at" gorm:"bigint;index"`
+	UpdatedAt    int64  `json:"updated_at" gorm:"bigint"`
+}
+
+func (delivery *TaskCallbackDelivery) Insert() error {
+	now := common.GetTimestamp()
+	delivery.CreatedAt = now
+	delivery.UpdatedAt = now
+	return DB.Create(delivery).Error
+}
+
+func (delivery *TaskCallbackDelivery) Update() error {
+	delivery.UpdatedAt = common.GetTimestamp()
+	return DB.Model(delivery).Select("status", "attempts", "response_code", "last_error", "next_retry_at", "updated_at").Updates(delivery).Error
+}
+
+// ClaimTaskCallbackDelivery 以读取时的状态和下次重试时间为条件领取投递记录，并将下次重试时间推迟到 leaseUntil，
+// 投递中的记录不会被重试任务或其他节点重复领取；返回是否领取成功
+func ClaimTaskCallbackDelivery(delivery *TaskCallbackDelivery, leaseUntil int64) (bool, error) {
+	result := DB.Model(&TaskCallbackDelivery{}).
+		Where("id = ? AND status = ? AND next_retry_at = ?", delivery.Id, delivery.Status, delivery.NextRetryAt).
+		Updates(map[string]any{
+			"status":        common.CallbackDeliveryStatusPending,
+			"next_retry_at": leaseUntil,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	delivery.Status = common.CallbackDeliveryStatusPending
+	delivery.NextRetryAt = leaseUntil
+	return true, nil
+}
+
+func GetTaskCallbackDeliveryById(id int) (*TaskCallbackDelivery, error) {
+	delivery := &TaskCallbackDelivery{}
+	err := DB.First(delivery, "id = ?", id).Error
+	return delivery, err
+}
+
+// GetDueTaskCallbackDeliveries 获取到期待重试的投递记录
+func GetDueTaskCallbackDeliveries(now int64, limit int) ([]*TaskCallbackDelivery, error) {
+	var deliveries []*TaskCallbackDelivery
+	err := DB.Where("status = ? AND next_retry_at > 0 AND next_retry_at <= ?", common.CallbackDeliveryStatusPending, now).
+		Order("next_retry_at asc").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// GetTaskCallbackDeliveries 分页查询投递记录，userId 为 0 时查询全部
+func GetTaskCallbackDeliveries(userId int, taskId string, status string, startIdx int, num int) ([]*TaskCallbackDelivery, int64, error) {
+	var deliveries []*TaskCallbackDelivery
+	var total int64
+	query := DB.Model(&TaskCallbackDelivery{})
+	if userId != 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if taskId != "" {
+		query = query.Where("task_id = ?", taskId)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id desc").Limit(num).Offset(startIdx).Find(&deliveries).Error
+	return deliveries, total, err
+}
+
+// DeleteTaskCallbackDeliveriesBefore 清理指定时间之前的投递记录
+func DeleteTaskCallbackDeliveriesBefore(timestamp int64) (int64, error) {
+	result := DB.Where("created_at < ? AND status <> ?", timestamp, common.CallbackDeliveryStatusPending).Delete(&TaskCallbackDelivery{})
+	return result.RowsAffected, result.Error
+}
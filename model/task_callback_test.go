@@ -0,0 +1,44 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+func TestClaimTaskCallbackDelivery(t *testing.T) {
+	setupTestDB(t, &TaskCallbackDelivery{})
+	delivery := &TaskCallbackDelivery{UserId: 1, TaskId: "task_1", Status: common.CallbackDeliveryStatusPending, NextRetryAt: 100}
+	if err := delivery.Insert(); err != nil {
+		t.Fatalf("insert delivery: %v", err)
+	}
+
+	// 重试任务读取到同一条记录，只有先领取者能投递
+	swept, err := GetDueTaskCallbackDeliveries(100, 10)
+	if err != nil || len(swept) != 1 {
+		t.Fatalf("GetDueTaskCallbackDeliveries = %d, %v, want 1", len(swept), err)
+	}
+	ok, err := ClaimTaskCallbackDelivery(delivery, 400)
+	if err != nil || !ok {
+		t.Fatalf("first claim = %v, %v, want true", ok, err)
+	}
+	ok, err = ClaimTaskCallbackDelivery(swept[0], 400)
+	if err != nil || ok {
+		t.Fatalf("second claim = %v, %v, want false", ok, err)
+	}
+	due, _ := GetDueTaskCallbackDeliveries(399, 10)
+	if len(due) != 0 {
+		t.Fatalf("claimed delivery is due before lease expires: %d", len(due))
+	}
+
+	// 失败记录可以被重放领取
+	delivery.Status = common.CallbackDeliveryStatusFailed
+	delivery.NextRetryAt = 0
+	if err := delivery.Update(); err != nil {
+		t.Fatalf("update delivery: %v", err)
+	}
+	ok, err = ClaimTaskCallbackDelivery(delivery, 500)
+	if err != nil || !ok || delivery.Status != common.CallbackDeliveryStatusPending {
+		t.Fatalf("replay claim = %v, %v, status %s", ok, err, delivery.Status)
+	}
+}
@@ -0,0 +1,92 @@
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+func unfinishedTaskQuery() *gorm.DB {
+	return DB.Model(&Task{}).Where("progress != ?", "100%").Where("status != ?", TaskStatusFailure).Where("status != ?", TaskStatusSuccess)
+}
+
+// GetDueTasks 获取到达轮询时间且未被其他节点持有租约的未完成任务，仅包含调度所需字段
+func GetDueTasks(now int64, limit int) ([]*Task, error) {
+	var tasks []*Task
+	err := unfinishedTaskQuery().
+		Select("id", "task_id", "platform", "channel_id").
+		Where("next_poll_at <= ?", now).
+		Where("lease_until < ?", now).
+		Order("next_poll_at asc").
+		Limit(limit).
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// ClaimTasksByDB 通过条件更新领取任务租约，多个节点同时领取时每个任务只会被一个节点获得
+func ClaimTasksByDB(ids []int64, owner string, now int64, leaseUntil int64) ([]*Task, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	err := DB.Model(&Task{}).
+		Where("id in (?)", ids).
+		Where("lease_until < ?", now).
+		Updates(map[string]any{"lease_owner": owner, "lease_until": leaseUntil}).Error
+	if err != nil {
+		return nil, err
+	}
+	var tasks []*Task
+	err = DB.Where("id in (?)", ids).Where("lease_owner = ? AND lease_until = ?", owner, leaseUntil).Find(&tasks).Error
+	return tasks, err
+}
+
+func GetTasksByIds(ids []int64) ([]*Task, error) {
+	var tasks []*Task
+	if len(ids) == 0 {
+		return tasks, nil
+	}
+	err := DB.Where("id in (?)", ids).Find(&tasks).Error
+	return tasks, err
+}
+
+// ReleaseTaskLease 释放租约并记录下次轮询时间，owner 为空时不校验持有者（Redis 租约）
+func ReleaseTaskLease(id int64, owner string, nextPollAt int64, pollAttempts int) error {
+	query := DB.Model(&Task{}).Where("id = ?", id)
+	if owner != "" {
+		query = query.Where("lease_owner = ?", owner)
+	}
+	return query.Updates(map[string]any{
+		"next_poll_at":  nextPollAt,
+		"poll_attempts": pollAttempts,
+		"lease_owner":   "",
+		"lease_until":   0,
+	}).Error
+}
+
+// GetTimeoutTasks 获取提交时间早于 before 的未完成任务
+func GetTimeoutTasks(before int64, limit int) ([]*Task, error) {
+	var tasks []*Task
+	err := unfinishedTaskQuery().Where("submit_time > 0 AND submit_time < ?", before).Order("id asc").Limit(limit).Find(&tasks).Error
+	return tasks, err
+}
+
+// FailTaskIfUnfinished 仅在任务仍未完成时将其置为失败，返回是否更新成功，用于避免重复退款
+func FailTaskIfUnfinished(id int64, reason string, now int64) (bool, error) {
+	result := unfinishedTaskQuery().
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"status":      TaskStatusFailure,
+			"progress":    "100%",
+			"fail_reason": reason,
+			"finish_time": now,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// UpdateIfUnchanged 仅在任务状态和进度仍为 preStatus、preProgress 时保存整行，返回是否更新成功。
+// 轮询结果与超时失败、取消等并发写入时，以先写入者为准，避免已失败退款的任务被覆盖为成功
+func (Task *Task) UpdateIfUnchanged(preStatus TaskStatus, preProgress string) (bool, error) {
+	result := DB.Model(Task).
+		Where("status = ? AND progress = ?", preStatus, preProgress).
+		Select("*").
+		Updates(Task)
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestUpdateIfUnchangedAfterTimeoutFailure(t *testing.T) {
+	setupTestDB(t, &Task{})
+	task := &Task{TaskID: "task_1", UserId: 1, Status: TaskStatusInProgress, Progress: "30%", SubmitTime: 1}
+	if err := task.Insert(); err != nil {
+		t.Fatalf("insert task: %v", err)
+	}
+
+	// 轮询读取任务后，超时流程先将任务置为失败
+	polled, _, err := GetByOnlyTaskId("task_1")
+	if err != nil {
+		t.Fatalf("get task: %v", err)
+	}
+	ok, err := FailTaskIfUnfinished(task.ID, "timeout", 100)
+	if err != nil || !ok {
+		t.Fatalf("FailTaskIfUnfinished = %v, %v, want true", ok, err)
+	}
+
+	// 轮询结果不能覆盖已失败的任务
+	preStatus, preProgress := polled.Status, polled.Progress
+	polled.Status = TaskStatusSuccess
+	polled.Progress = "100%"
+	updated, err := polled.UpdateIfUnchanged(preStatus, preProgress)
+	if err != nil {
+		t.Fatalf("UpdateIfUnchanged: %v", err)
+	}
+	if updated {
+		t.Fatal("UpdateIfUnchanged updated a task finished concurrently")
+	}
+	latest, _, _ := GetByOnlyTaskId("task_1")
+	if latest.Status != TaskStatusFailure || latest.FailReason != "timeout" {
+		t.Fatalf("task = %s %q, want FAILURE timeout", latest.Status, latest.FailReason)
+	}
+
+	// 超时流程也不会再次处理已结束的任务
+	ok, err = FailTaskIfUnfinished(task.ID, "timeout", 200)
+	if err != nil || ok {
+		t.Fatalf("second FailTaskIfUnfinished = %v, %v, want false", ok, err)
+	}
+}
+
+func TestUpdateIfUnchanged(t *testing.T) {
+	setupTestDB(t, &Task{})
+	task := &Task{TaskID: "task_2", UserId: 1, Status: TaskStatusInProgress, Progress: "30%"}
+	if err := task.Insert(); err != nil {
+		t.Fatalf("insert task: %v", err)
+	}
+	task.Status = TaskStatusSuccess
+	task.Progress = "100%"
+	task.FailReason = "https://example.com/video.mp4"
+	updated, err := task.UpdateIfUnchanged(TaskStatusInProgress, "30%")
+	if err != nil || !updated {
+		t.Fatalf("UpdateIfUnchanged = %v, %v, want true", updated, err)
+	}
+	latest, _, _ := GetByOnlyTaskId("task_2")
+	if latest.Status != TaskStatusSuccess || latest.Progress != "100%" || latest.FailReason != task.FailReason {
+		t.Fatalf("task = %+v, want saved success", latest)
+	}
+	ok, err := FailTaskIfUnfinished(task.ID, "timeout", 100)
+	if err != nil || ok {
+		t.Fatalf("FailTaskIfUnfinished on success task = %v, %v, want false", ok, err)
+	}
+}
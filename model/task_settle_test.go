@@ -0,0 +1,51 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestUpdateUserUsedQuotaSymmetric(t *testing.T) {
+	setupTestDB(t)
+	user := &User{Username: "settle", UsedQuota: 1000, RequestCount: 1}
+	if err := DB.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	// 按实际时长结算时先补扣再退还，已用额度应回到原值且请求次数不变
+	UpdateUserUsedQuota(user.Id, 300)
+	UpdateUserUsedQuota(user.Id, -300)
+	UpdateUserUsedQuota(user.Id, -200)
+
+	latest := &User{}
+	if err := DB.First(latest, user.Id).Error; err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if latest.UsedQuota != 800 {
+		t.Fatalf("used_quota = %d, want 800", latest.UsedQuota)
+	}
+	if latest.RequestCount != 1 {
+		t.Fatalf("request_count = %d, want 1", latest.RequestCount)
+	}
+}
+
+func TestSetTaskMediaObjectId(t *testing.T) {
+	setupTestDB(t, &Task{})
+	task := &Task{TaskID: "task_media", UserId: 1, Status: TaskStatusSuccess, Progress: "100%"}
+	task.PrivateData.CallbackUrl = "https://example.com/callback"
+	if err := task.Insert(); err != nil {
+		t.Fatalf("insert task: %v", err)
+	}
+	if err := SetTaskMediaObjectId(task.ID, 42); err != nil {
+		t.Fatalf("SetTaskMediaObjectId: %v", err)
+	}
+	latest, _, _ := GetByOnlyTaskId("task_media")
+	if latest.PrivateData.MediaObjectId != 42 {
+		t.Fatalf("media_object_id = %d, want 42", latest.PrivateData.MediaObjectId)
+	}
+	if latest.PrivateData.CallbackUrl != "https://example.com/callback" {
+		t.Fatalf("callback_url = %q, private data was overwritten", latest.PrivateData.CallbackUrl)
+	}
+	if latest.Status != TaskStatusSuccess || latest.Progress != "100%" {
+		t.Fatalf("task = %s %s, other columns changed", latest.Status, latest.Progress)
+	}
+}
@@ -26,7 +26,10 @@ type Token struct {
 	AllowIps           *string        `json:"allow_ips" gorm:"default:''"`
 	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
 	Group              string         `json:"group" gorm:"default:''"`
-	CrossGroupRetry    bool           `json:"cross_group_retry" gorm:"default:false"` // 跨分组重试，仅auto分组有效
+	CrossGroupRetry    bool           `json:"cross_group_retry" gorm:"default:false"`           // 跨分组重试，仅auto分组有效
+	CallbackUrl        string         `json:"callback_url" gorm:"type:varchar(512);default:''"` // 异步任务状态回调地址
+	CallbackSecret     string         `json:"-" gorm:"type:varchar(128);default:''"`            // 回调签名密钥，仅在创建和轮换时返回
+	HedgeEnabled       bool           `json:"hedge_enabled" gorm:"default:false"`               // 对冲请求，需开启对冲总开关
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
@@ -185,7 +188,7 @@ func (token *Token) Update() (err error) {
 		}
 	}()
 	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry").Updates(token).Error
+		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry", "callback_url", "callback_secret", "hedge_enabled").Updates(token).Error
 	return err
 }
 
@@ -239,6 +242,28 @@ func (token *Token) GetModelLimitsMap() map[string]bool {
 	return limitsMap
 }
 
+// GenerateTokenCallbackSecret 生成回调签名密钥
+func GenerateTokenCallbackSecret() (string, error) {
+	return common.GenerateRandomCharsKey(32)
+}
+
+// RotateTokenCallbackSecret 为令牌生成新的回调签名密钥并返回
+func RotateTokenCallbackSecret(id int, userId int) (string, error) {
+	token, err := GetTokenByIds(id, userId)
+	if err != nil {
+		return "", err
+	}
+	secret, err := GenerateTokenCallbackSecret()
+	if err != nil {
+		return "", err
+	}
+	token.CallbackSecret = secret
+	if err := token.Update(); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
 func DisableModelLimits(tokenId int) error {
 	token, err := GetTokenById(tokenId)
 	if err != nil {
@@ -25,6 +25,8 @@ type TopUp struct {
 	Status        string  `json:"status"`
 	InvoiceStatus string  `json:"invoice_status" gorm:"type:varchar(20);default:'未申请'"` // 发票状态：未申请、待开、已发送
 	InvoiceId     int     `json:"invoice_id" gorm:"default:0;index"`                    // 关联的发票ID
+	CampaignId    int     `json:"campaign_id" gorm:"default:0"`                         // 下单时匹配的充值活动
+	Currency      string  `json:"currency" gorm:"type:varchar(8);default:''"`           // 实付币种，为空表示人民币
 }
 
 func (topUp *TopUp) Insert() error {
@@ -107,6 +109,11 @@ func Recharge(referenceId string, customerId string) (err error) {
 			bonusAmount = actualAmount - topUp.Money
 		}
 
+		// 充值活动赠送
+		campaignBonus := settleTopUpCampaign(tx, topUp, actualAmount)
+		actualAmount += campaignBonus
+		bonusAmount += campaignBonus
+
 		// 计算额度：使用实际到账金额（美元）转换为额度
 		quota = actualAmount * common.QuotaPerUnit
 
@@ -320,6 +327,11 @@ func ManualCompleteTopUp(tradeNo string) error {
 			}
 		}
 
+		// 充值活动赠送
+		campaignBonus := settleTopUpCampaign(tx, topUp, actualAmount)
+		actualAmount += campaignBonus
+		bonusAmount += campaignBonus
+
 		// 计算应充值额度：使用实际到账金额（美元）转换为额度
 		dActualAmount := decimal.NewFromFloat(actualAmount)
 			dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
@@ -407,6 +419,11 @@ func RechargeCreem(referenceId string, customerEmail string, customerName string
 			bonusAmount = actualAmount - topUp.Money
 		}
 
+		// 充值活动赠送
+		campaignBonus := settleTopUpCampaign(tx, topUp, actualAmount)
+		actualAmount += campaignBonus
+		bonusAmount += campaignBonus
+
 		// Creem 使用实际到账金额转换为额度
 		quota = int64(actualAmount * common.QuotaPerUnit)
 
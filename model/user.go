@@ -51,17 +51,21 @@ type User struct {
 	TotalBonusAmount float64        `json:"total_bonus_amount" gorm:"type:decimal(10,2);default:0"` // 累计赠送金额
 	CompanyName      string         `json:"company_name" gorm:"type:varchar(255)"`                  // 公司抬头（用于发票）
 	TaxNumber        string         `json:"tax_number" gorm:"type:varchar(50)"`                     // 税号（用于发票）
+	CreditLimit      int            `json:"credit_limit" gorm:"type:int;default:0"`                 // 信用额度（后付费），大于0时允许透支至 -CreditLimit
+	CreditSuspended  bool           `json:"credit_suspended" gorm:"default:false"`                  // 账单逾期后暂停信用额度
 }
 
 func (user *User) ToBaseUser() *UserBase {
 	cache := &UserBase{
-		Id:       user.Id,
-		Group:    user.Group,
-		Quota:    user.Quota,
-		Status:   user.Status,
-		Username: user.Username,
-		Setting:  user.Setting,
-		Email:    user.Email,
+		Id:              user.Id,
+		Group:           user.Group,
+		Quota:           user.Quota,
+		Status:          user.Status,
+		Username:        user.Username,
+		Setting:         user.Setting,
+		Email:           user.Email,
+		CreditLimit:     user.CreditLimit,
+		CreditSuspended: user.CreditSuspended,
 	}
 	return cache
 }
@@ -477,6 +481,7 @@ func (user *User) Edit(updatePassword bool) error {
 		"quota":            newUser.Quota,
 		"remark":           newUser.Remark,
 		"topup_multiplier": newUser.TopupMultiplier,
+		"credit_limit":     newUser.CreditLimit,
 	}
 	if updatePassword {
 		updates["password"] = newUser.Password
@@ -857,6 +862,15 @@ func UpdateUserUsedQuotaAndRequestCount(id int, quota int) {
 	updateUserUsedQuotaAndRequestCount(id, quota, 1)
 }
 
+// UpdateUserUsedQuota 只调整用户已用额度，不计入请求次数，用于任务结算时的多退少补
+func UpdateUserUsedQuota(id int, quota int) {
+	if common.BatchUpdateEnabled {
+		addNewRecord(BatchUpdateTypeUsedQuota, id, quota)
+		return
+	}
+	updateUserUsedQuota(id, quota)
+}
+
 func updateUserUsedQuotaAndRequestCount(id int, quota int, count int) {
 	err := DB.Model(&User{}).Where("id = ?", id).Updates(
 		map[string]interface{}{
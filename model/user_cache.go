@@ -22,6 +22,9 @@ type UserBase struct {
 	Status   int    `json:"status"`
 	Username string `json:"username"`
 	Setting  string `json:"setting"`
+
+	CreditLimit     int  `json:"credit_limit"`
+	CreditSuspended bool `json:"credit_suspended"`
 }
 
 func (user *UserBase) WriteContext(c *gin.Context) {
@@ -31,6 +34,15 @@ func (user *UserBase) WriteContext(c *gin.Context) {
 	common.SetContextKey(c, constant.ContextKeyUserEmail, user.Email)
 	common.SetContextKey(c, constant.ContextKeyUserName, user.Username)
 	common.SetContextKey(c, constant.ContextKeyUserSetting, user.GetSetting())
+	common.SetContextKey(c, constant.ContextKeyUserCreditLimit, user.GetCreditLimit())
+}
+
+// GetCreditLimit returns the usable credit line, which is zero while the credit is suspended
+func (user *UserBase) GetCreditLimit() int {
+	if user.CreditSuspended || user.CreditLimit < 0 {
+		return 0
+	}
+	return user.CreditLimit
 }
 
 func (user *UserBase) GetSetting() dto.UserSetting {
@@ -99,15 +111,7 @@ func GetUserCache(userId int) (userCache *UserBase, err error) {
 	}
 
 	// Create cache object from user data
-	userCache = &UserBase{
-		Id:       user.Id,
-		Group:    user.Group,
-		Quota:    user.Quota,
-		Status:   user.Status,
-		Username: user.Username,
-		Setting:  user.Setting,
-		Email:    user.Email,
-	}
+	userCache = user.ToBaseUser()
 
 	return userCache, nil
 }
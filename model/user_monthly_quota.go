@@ -0,0 +1,67 @@
+package model
+
+import (
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// UserMonthlyQuota 用户按自然月累计的已消耗额度，结算时与 UserUsage 一起累加，用于阶梯（用量）计价
+type UserMonthlyQuota struct {
+	Id          int   `json:"id"`
+	UserId      int   `json:"user_id" gorm:"uniqueIndex:idx_user_monthly_quota,priority:1"`
+	PeriodStart int64 `json:"period_start" gorm:"bigint;uniqueIndex:idx_user_monthly_quota,priority:2"`
+	Quota       int   `json:"quota" gorm:"default:0"`
+}
+
+// GetUserMonthlyUsedQuota 返回用户本自然月的已消耗额度，不受消费日志开关影响
+func GetUserMonthlyUsedQuota(userId int) int {
+	periodStart := GetUsagePeriodStart(time.Now())
+	var counters []UserMonthlyQuota
+	err := DB.Where("user_id = ? AND period_start = ?", userId, periodStart).Limit(1).Find(&counters).Error
+	if err != nil {
+		common.SysLog("failed to get user monthly quota: " + err.Error())
+		return 0
+	}
+	if len(counters) > 0 {
+		return counters[0].Quota
+	}
+	// 本月还没有计数（如刚升级），使用按模型聚合的用量
+	quota, err := sumUserUsageQuota(DB, userId, periodStart)
+	if err != nil {
+		common.SysLog("failed to get user monthly quota: " + err.Error())
+	}
+	return quota
+}
+
+func sumUserUsageQuota(db *gorm.DB, userId int, periodStart int64) (int, error) {
+	var quota int
+	err := db.Model(&UserUsage{}).Select("coalesce(sum(quota),0)").
+		Where("user_id = ? AND period_start = ?", userId, periodStart).Scan(&quota).Error
+	return quota, err
+}
+
+// upsertUserMonthlyQuota 累加用户当月额度，需在 upsertUserUsage 之后调用：
+// 本月第一次写入时按已有的 UserUsage 初始化，避免升级后当月用量从 0 开始
+func upsertUserMonthlyQuota(db *gorm.DB, userId int, periodStart int64, quota int) error {
+	result := db.Model(&UserMonthlyQuota{}).Where("user_id = ? AND period_start = ?", userId, periodStart).
+		Update("quota", gorm.Expr("quota + ?", quota))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	total, err := sumUserUsageQuota(db, userId, periodStart)
+	if err != nil {
+		return err
+	}
+	if err := db.Create(&UserMonthlyQuota{UserId: userId, PeriodStart: periodStart, Quota: total}).Error; err != nil {
+		// 并发插入同一行时唯一索引冲突，改为累加
+		return db.Model(&UserMonthlyQuota{}).Where("user_id = ? AND period_start = ?", userId, periodStart).
+			Update("quota", gorm.Expr("quota + ?", quota)).Error
+	}
+	return nil
+}
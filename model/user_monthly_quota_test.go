@@ -0,0 +1,53 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+func TestUserMonthlyUsedQuota(t *testing.T) {
+	setupTestDB(t, &UserUsage{}, &UserMonthlyQuota{})
+	enableUserUsageBatch(t)
+	oldLogConsume := common.LogConsumeEnabled
+	common.LogConsumeEnabled = false
+	t.Cleanup(func() { common.LogConsumeEnabled = oldLogConsume })
+
+	periodStart := GetUsagePeriodStart(time.Now())
+	lastPeriodStart := GetUsagePeriodStart(time.Unix(periodStart-1, 0))
+	// 升级前已有的本月用量与上月用量
+	DB.Create(&UserUsage{UserId: 1, PeriodStart: periodStart, ModelName: "gpt-4o", TokenName: "a", Quota: 400})
+	DB.Create(&UserUsage{UserId: 1, PeriodStart: lastPeriodStart, ModelName: "gpt-4o", TokenName: "a", Quota: 10000})
+
+	tests := []struct {
+		name   string
+		record func()
+		userId int
+		want   int
+	}{
+		{name: "falls back to usage before first settle", record: func() {}, userId: 1, want: 400},
+		{name: "initialized from usage on first settle", record: func() {
+			RecordConsumeLog(nil, 1, RecordConsumeLogParams{ModelName: "gpt-4o", TokenName: "a", Quota: 100})
+		}, userId: 1, want: 500},
+		{name: "incremented on settle", record: func() {
+			RecordConsumeLog(nil, 1, RecordConsumeLogParams{ModelName: "gpt-4o-mini", TokenName: "b", Quota: 50})
+		}, userId: 1, want: 550},
+		{name: "not billed is ignored", record: func() {
+			RecordConsumeLog(nil, 1, RecordConsumeLogParams{ModelName: "gpt-4o", Quota: 1000, NotBilled: true})
+		}, userId: 1, want: 550},
+		{name: "other user", record: func() {
+			RecordConsumeLog(nil, 2, RecordConsumeLogParams{ModelName: "gpt-4o", Quota: 70})
+		}, userId: 2, want: 70},
+		{name: "user without usage", record: func() {}, userId: 3, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.record()
+			batchUpdateUserUsage()
+			if got := GetUserMonthlyUsedQuota(tt.userId); got != tt.want {
+				t.Fatalf("GetUserMonthlyUsedQuota(%d) = %d, want %d", tt.userId, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package model
+
+import (
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"gorm.io/gorm"
+)
+
+// UserUsage 用户按自然月、模型和令牌聚合的计费用量，结算时写入，不受消费日志开关影响，用于生成账单
+type UserUsage struct {
+	Id               int    `json:"id"`
+	UserId           int    `json:"user_id" gorm:"uniqueIndex:idx_user_usage_key,priority:1"`
+	PeriodStart      int64  `json:"period_start" gorm:"bigint;uniqueIndex:idx_user_usage_key,priority:2"`
+	ModelName        string `json:"model_name" gorm:"type:varchar(191);default:'';uniqueIndex:idx_user_usage_key,priority:3"`
+	TokenName        string `json:"token_name" gorm:"type:varchar(191);default:'';uniqueIndex:idx_user_usage_key,priority:4"`
+	RequestCount     int    `json:"request_count" gorm:"default:0"`
+	PromptTokens     int    `json:"prompt_tokens" gorm:"default:0"`
+	CompletionTokens int    `json:"completion_tokens" gorm:"default:0"`
+	Quota            int    `json:"quota" gorm:"default:0"`
+}
+
+// GetUsagePeriodStart 返回 t 所在自然月的开始时间，与账单账期一致
+func GetUsagePeriodStart(t time.Time) int64 {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()).Unix()
+}
+
+type userUsageKey struct {
+	UserId      int
+	PeriodStart int64
+	ModelName   string
+	TokenName   string
+}
+
+type userUsageDelta struct {
+	RequestCount     int
+	PromptTokens     int
+	CompletionTokens int
+	Quota            int
+}
+
+var userUsageBatchStore = make(map[userUsageKey]*userUsageDelta)
+var userUsageBatchLock sync.Mutex
+
+// RecordUserUsage 将一次结算计入用户当月用量，开启批量更新时合并写入，否则异步写入，不阻塞结算
+func RecordUserUsage(userId int, params RecordConsumeLogParams) {
+	if userId == 0 {
+		return
+	}
+	key := userUsageKey{
+		UserId:      userId,
+		PeriodStart: GetUsagePeriodStart(time.Now()),
+		ModelName:   params.ModelName,
+		TokenName:   params.TokenName,
+	}
+	delta := userUsageDelta{
+		RequestCount:     1,
+		PromptTokens:     params.PromptTokens,
+		CompletionTokens: params.CompletionTokens,
+		Quota:            params.Quota,
+	}
+	if common.BatchUpdateEnabled {
+		addUserUsageRecord(key, delta)
+		return
+	}
+	gopool.Go(func() {
+		saveUserUsage(key, delta)
+	})
+}
+
+func addUserUsageRecord(key userUsageKey, delta userUsageDelta) {
+	userUsageBatchLock.Lock()
+	defer userUsageBatchLock.Unlock()
+	if record, ok := userUsageBatchStore[key]; ok {
+		record.RequestCount += delta.RequestCount
+		record.PromptTokens += delta.PromptTokens
+		record.CompletionTokens += delta.CompletionTokens
+		record.Quota += delta.Quota
+		return
+	}
+	userUsageBatchStore[key] = &delta
+}
+
+// batchUpdateUserUsage 写入批量累计的用户用量
+func batchUpdateUserUsage() {
+	userUsageBatchLock.Lock()
+	store := userUsageBatchStore
+	userUsageBatchStore = make(map[userUsageKey]*userUsageDelta)
+	userUsageBatchLock.Unlock()
+	for key, delta := range store {
+		saveUserUsage(key, *delta)
+	}
+}
+
+func saveUserUsage(key userUsageKey, delta userUsageDelta) {
+	if err := upsertUserUsage(DB, key, delta); err != nil {
+		common.SysLog("failed to record user usage: " + err.Error())
+		return
+	}
+	if delta.Quota == 0 {
+		return
+	}
+	if err := upsertUserMonthlyQuota(DB, key.UserId, key.PeriodStart, delta.Quota); err != nil {
+		common.SysLog("failed to record user monthly quota: " + err.Error())
+	}
+}
+
+func upsertUserUsage(db *gorm.DB, key userUsageKey, delta userUsageDelta) error {
+	updates := map[string]interface{}{
+		"request_count":     gorm.Expr("request_count + ?", delta.RequestCount),
+		"prompt_tokens":     gorm.Expr("prompt_tokens + ?", delta.PromptTokens),
+		"completion_tokens": gorm.Expr("completion_tokens + ?", delta.CompletionTokens),
+		"quota":             gorm.Expr("quota + ?", delta.Quota),
+	}
+	result := db.Model(&UserUsage{}).Where("user_id = ? AND period_start = ? AND model_name = ? AND token_name = ?",
+		key.UserId, key.PeriodStart, key.ModelName, key.TokenName).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	usage := &UserUsage{
+		UserId:           key.UserId,
+		PeriodStart:      key.PeriodStart,
+		ModelName:        key.ModelName,
+		TokenName:        key.TokenName,
+		RequestCount:     delta.RequestCount,
+		PromptTokens:     delta.PromptTokens,
+		CompletionTokens: delta.CompletionTokens,
+		Quota:            delta.Quota,
+	}
+	if err := db.Create(usage).Error; err != nil {
+		// 并发插入同一行时唯一索引冲突，改为累加
+		return db.Model(&UserUsage{}).Where("user_id = ? AND period_start = ? AND model_name = ? AND token_name = ?",
+			key.UserId, key.PeriodStart, key.ModelName, key.TokenName).Updates(updates).Error
+	}
+	return nil
+}
+
+// GetUserUsageItems 获取用户指定账期按模型和令牌聚合的用量
+func GetUserUsageItems(userId int, periodStart int64) (usages []*UserUsage, err error) {
+	err = DB.Where("user_id = ? AND period_start = ?", userId, periodStart).Order("quota desc").Find(&usages).Error
+	return usages, err
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// enableUserUsageBatch 用户用量改为批量写入，避免异步写入在测试结束后访问已关闭的数据库，需要时调用 batchUpdateUserUsage 刷新
+func enableUserUsageBatch(t *testing.T) {
+	t.Helper()
+	oldBatch := common.BatchUpdateEnabled
+	common.BatchUpdateEnabled = true
+	t.Cleanup(func() {
+		common.BatchUpdateEnabled = oldBatch
+		userUsageBatchLock.Lock()
+		userUsageBatchStore = make(map[userUsageKey]*userUsageDelta)
+		userUsageBatchLock.Unlock()
+	})
+}
+
+func TestRecordUserUsageBatch(t *testing.T) {
+	setupTestDB(t, &UserUsage{}, &UserMonthlyQuota{})
+	enableUserUsageBatch(t)
+	periodStart := GetUsagePeriodStart(time.Now())
+
+	tests := []struct {
+		name            string
+		records         []RecordConsumeLogParams
+		rowsBeforeFlush int64
+		wantUsage       UserUsage
+		wantMonthly     int
+	}{
+		{
+			name:        "merged before flush",
+			records:     []RecordConsumeLogParams{{ModelName: "gpt-4o", TokenName: "a", PromptTokens: 10, CompletionTokens: 2, Quota: 100}, {ModelName: "gpt-4o", TokenName: "a", PromptTokens: 5, CompletionTokens: 1, Quota: 50}},
+			wantUsage:   UserUsage{RequestCount: 2, PromptTokens: 15, CompletionTokens: 3, Quota: 150},
+			wantMonthly: 150,
+		},
+		{
+			name:            "accumulated across flushes",
+			records:         []RecordConsumeLogParams{{ModelName: "gpt-4o", TokenName: "a", PromptTokens: 1, Quota: 10}},
+			rowsBeforeFlush: 1,
+			wantUsage:       UserUsage{RequestCount: 3, PromptTokens: 16, CompletionTokens: 3, Quota: 160},
+			wantMonthly:     160,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, record := range tt.records {
+				RecordUserUsage(1, record)
+			}
+			var count int64
+			DB.Model(&UserUsage{}).Count(&count)
+			if count != tt.rowsBeforeFlush {
+				t.Fatalf("rows before flush = %d, want %d", count, tt.rowsBeforeFlush)
+			}
+			batchUpdateUserUsage()
+			var usage UserUsage
+			if err := DB.Where("user_id = ? AND period_start = ? AND model_name = ? AND token_name = ?", 1, periodStart, "gpt-4o", "a").First(&usage).Error; err != nil {
+				t.Fatalf("load usage: %v", err)
+			}
+			if usage.RequestCount != tt.wantUsage.RequestCount || usage.PromptTokens != tt.wantUsage.PromptTokens ||
+				usage.CompletionTokens != tt.wantUsage.CompletionTokens || usage.Quota != tt.wantUsage.Quota {
+				t.Errorf("usage = %+v, want %+v", usage, tt.wantUsage)
+			}
+			if got := GetUserMonthlyUsedQuota(1); got != tt.wantMonthly {
+				t.Errorf("monthly quota = %d, want %d", got, tt.wantMonthly)
+			}
+		})
+	}
+}
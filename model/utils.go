@@ -50,6 +50,9 @@ func addNewRecord(type_ int, id int, value int) {
 }
 
 func batchUpdate() {
+	// 用户用量按复合键累计，单独写入
+	batchUpdateUserUsage()
+
 	// check if there's any data to update
 	hasData := false
 	for i := 0; i < BatchUpdateTypeCount; i++ {
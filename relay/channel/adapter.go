@@ -1,6 +1,7 @@
 package channel
 
 import (
+	"errors"
 	"io"
 	"net/http"
 
@@ -52,6 +53,39 @@ type TaskAdaptor interface {
 	ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, error)
 }
 
+// VideoCapabilityProvider 视频任务适配器可选实现：声明模型支持的标准视频参数，
+// 提交前据此拒绝不支持的选项，并提供按时长×分辨率计费的默认值，未知模型返回 nil
+type VideoCapabilityProvider interface {
+	GetVideoCapability(modelName string) *relaycommon.VideoCapability
+}
+
+// AudioCapabilityProvider 音频任务适配器实现：声明模型支持的生成类型与时长，
+// 提交前据此拒绝不支持的请求，并提供按时长计费的默认值，不支持的模型返回 nil
+type AudioCapabilityProvider interface {
+	GetAudioCapability(modelName string) *relaycommon.AudioCapability
+}
+
+// SyncTaskResultProvider 可选实现：上游在提交时同步返回结果（如 ElevenLabs、MiniMax 音乐），
+// DoResponse 之后返回已完成的任务结果，任务直接结束而不进入轮询；异步提交时返回 nil
+type SyncTaskResultProvider interface {
+	SyncTaskResult() *relaycommon.TaskInfo
+}
+
+// InlineTaskResultProvider 可选实现：结果以 data URL 内联返回（如 ElevenLabs），
+// 内联结果不保存在任务记录中，需开启对应的媒体归档才能提交
+type InlineTaskResultProvider interface {
+	InlineTaskResult() bool
+}
+
+// ErrTaskCancelUnsupported 上游不支持取消任务
+var ErrTaskCancelUnsupported = errors.New("task cancellation is not supported by the upstream")
+
+// TaskCanceler 可选实现：取消未完成的上游任务，返回按上游计费策略应退还的预扣费比例（0-1）。
+// 上游接口不支持取消时不应实现该接口；仅部分密钥支持时对其余密钥返回 ErrTaskCancelUnsupported
+type TaskCanceler interface {
+	CancelTask(baseUrl, key string, task *model.Task, proxy string) (refundRatio float64, err error)
+}
+
 type OpenAIVideoConverter interface {
 	ConvertToOpenAIVideo(originTask *model.Task) ([]byte, error)
 }
@@ -118,6 +118,13 @@ type WanImageInput struct {
 	NegativePrompt string   `json:"negative_prompt,omitempty"` // 可选：反向提示词，描述不希望在画面中看到的内容
 }
 
+type WanImageEditInput struct {
+	Function     string `json:"function"`                 // 必需：编辑功能，如 description_edit、description_edit_with_mask、stylization_all
+	Prompt       string `json:"prompt"`                   // 必需：提示词
+	BaseImageUrl string `json:"base_image_url"`           // 必需：待编辑图像，支持HTTP/HTTPS URL或Base64编码
+	MaskImageUrl string `json:"mask_image_url,omitempty"` // 可选：局部重绘时的蒙版图像，白色为编辑区域
+}
+
 type WanImageParameters struct {
 	N         int     `json:"n,omitempty"`         // 生成图片数量，取值范围1-4，默认4
 	Watermark *bool   `json:"watermark,omitempty"` // 是否添加水印标识，默认false
@@ -2,11 +2,9 @@ package ali
 
 import (
 	"context"
-	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"net/http"
 	"strings"
 	"time"
@@ -15,6 +13,7 @@ import (
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/types"
 
@@ -58,92 +57,27 @@ func oaiImage2Ali(request dto.ImageRequest) (*AliImageRequest, error) {
 	return &imageRequest, nil
 }
 
-func getImageBase64sFromForm(c *gin.Context, fieldName string) ([]string, error) {
-	mf := c.Request.MultipartForm
-	if mf == nil {
-		if _, err := c.MultipartForm(); err != nil {
-			return nil, fmt.Errorf("failed to parse image edit form request: %w", err)
-		}
-		mf = c.Request.MultipartForm
-	}
-
-	var imageFiles []*multipart.FileHeader
-	var exists bool
-
-	// First check for standard "image" field
-	if imageFiles, exists = mf.File["image"]; !exists || len(imageFiles) == 0 {
-		// If not found, check for "image[]" field
-		if imageFiles, exists = mf.File["image[]"]; !exists || len(imageFiles) == 0 {
-			// If still not found, iterate through all fields to find any that start with "image["
-			foundArrayImages := false
-			for fieldName, files := range mf.File {
-				if strings.HasPrefix(fieldName, "image[") && len(files) > 0 {
-					foundArrayImages = true
-					imageFiles = append(imageFiles, files...)
-				}
-			}
-
-			// If no image fields found at all
-			if !foundArrayImages && (len(imageFiles) == 0) {
-				return nil, errors.New("image is required")
-			}
-		}
-	}
-
-	if len(imageFiles) == 0 {
-		return nil, errors.New("image is required")
-	}
-
-	//if len(imageFiles) > 1 {
-	//	return nil, errors.New("only one image is supported for qwen edit")
-	//}
-
-	// 获取base64编码的图片
-	var imageBase64s []string
-	for _, file := range imageFiles {
-		image, err := file.Open()
-		if err != nil {
-			return nil, errors.New("failed to open image file")
-		}
-
-		// 读取文件内容
-		imageData, err := io.ReadAll(image)
-		if err != nil {
-			return nil, errors.New("failed to read image file")
-		}
-
-		// 获取MIME类型
-		mimeType := http.DetectContentType(imageData)
-
-		// 编码为base64
-		base64Data := base64.StdEncoding.EncodeToString(imageData)
-
-		// 构造data URL格式
-		dataURL := fmt.Sprintf("data:%s;base64,%s", mimeType, base64Data)
-		imageBase64s = append(imageBase64s, dataURL)
-		image.Close()
-	}
-	return imageBase64s, nil
-}
-
 func oaiFormEdit2AliImageEdit(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (*AliImageRequest, error) {
 	var imageRequest AliImageRequest
 	imageRequest.Model = request.Model
 	imageRequest.ResponseFormat = request.ResponseFormat
 
-	imageBase64s, err := getImageBase64sFromForm(c, "image")
+	inputs, err := helper.GetImageEditInputs(c, request)
 	if err != nil {
-		return nil, fmt.Errorf("get image base64s from form failed: %w", err)
+		return nil, fmt.Errorf("get edit images failed: %w", err)
 	}
-	//dto.MediaContent{}
-	mediaContents := make([]AliMediaContent, len(imageBase64s))
-	for i, b64 := range imageBase64s {
-		mediaContents[i] = AliMediaContent{
-			Image: b64,
-		}
+	// 通义千问图像编辑不支持蒙版参数
+	if err := helper.RejectImageMask(inputs); err != nil {
+		return nil, err
+	}
+	mediaContents := make([]AliMediaContent, 0, len(inputs.Images)+1)
+	for _, image := range inputs.Images {
+		mediaContents = append(mediaContents, AliMediaContent{
+			Image: image,
+		})
 	}
 	mediaContents = append(mediaContents, AliMediaContent{
-		Text: request.Prompt,
+		Text: helper.BuildImageEditPrompt(request.Prompt, info.RelayMode),
 	})
 	imageRequest.Input = AliImageInput{
 		Messages: []AliMessage{
@@ -154,6 +88,7 @@ func oaiFormEdit2AliImageEdit(c *gin.Context, info *relaycommon.RelayInfo, reque
 		},
 	}
 	imageRequest.Parameters = AliImageParameters{
+		N:         int(request.N),
 		Watermark: request.Watermark,
 	}
 	return &imageRequest, nil
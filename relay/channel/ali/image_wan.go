@@ -7,33 +7,59 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/dto"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
 
 	"github.com/gin-gonic/gin"
 )
 
 func oaiFormEdit2WanxImageEdit(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (*AliImageRequest, error) {
-	var err error
 	var imageRequest AliImageRequest
 	imageRequest.Model = request.Model
 	imageRequest.ResponseFormat = request.ResponseFormat
-	wanInput := WanImageInput{
-		Prompt: request.Prompt,
+
+	inputs, err := helper.GetImageEditInputs(c, request)
+	if err != nil {
+		return nil, fmt.Errorf("get edit images failed: %w", err)
+	}
+	wanParams := WanImageParameters{
+		N:         int(request.N),
+		Watermark: request.Watermark,
+	}
+	imageRequest.Parameters = wanParams
+
+	// 通用图像编辑（wanx2.1-imageedit）通过 function 指定编辑功能，带蒙版时使用局部重绘
+	if isWanImageEditModel(request.Model) || inputs.Mask != "" {
+		editInput := WanImageEditInput{}
+		if err := common.UnmarshalBodyReusable(c, &editInput); err != nil {
+			return nil, err
+		}
+		editInput.Prompt = helper.BuildImageEditPrompt(request.Prompt, info.RelayMode)
+		editInput.BaseImageUrl = inputs.Images[0]
+		editInput.MaskImageUrl = inputs.Mask
+		if editInput.Function == "" {
+			editInput.Function = "description_edit"
+			if inputs.Mask != "" {
+				editInput.Function = "description_edit_with_mask"
+			}
+		}
+		imageRequest.Input = editInput
+		return &imageRequest, nil
 	}
 
+	wanInput := WanImageInput{}
 	if err := common.UnmarshalBodyReusable(c, &wanInput); err != nil {
 		return nil, err
 	}
-	if wanInput.Images, err = getImageBase64sFromForm(c, "image"); err != nil {
-		return nil, fmt.Errorf("get image base64s from form failed: %w", err)
-	}
-	wanParams := WanImageParameters{
-		N: int(request.N),
-	}
+	wanInput.Prompt = helper.BuildImageEditPrompt(request.Prompt, info.RelayMode)
+	wanInput.Images = inputs.Images
 	imageRequest.Input = wanInput
-	imageRequest.Parameters = wanParams
 	return &imageRequest, nil
 }
 
 func isWanModel(modelName string) bool {
 	return strings.Contains(modelName, "wan")
 }
+
+func isWanImageEditModel(modelName string) bool {
+	return strings.Contains(modelName, "imageedit")
+}
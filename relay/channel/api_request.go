@@ -30,7 +30,11 @@ func SetupApiRequestHeader(info *common.RelayInfo, c *gin.Context, req *http.Hea
 	} else if info.RelayMode == constant.RelayModeRealtime {
 		// websocket
 	} else {
-		req.Set("Content-Type", c.Request.Header.Get("Content-Type"))
+		contentType := c.Request.Header.Get("Content-Type")
+		if info.UpstreamContentType != "" {
+			contentType = info.UpstreamContentType
+		}
+		req.Set("Content-Type", contentType)
 		req.Set("Accept", c.Request.Header.Get("Accept"))
 		if info.IsStream && c.Request.Header.Get("Accept") == "" {
 			req.Set("Accept", "text/event-stream")
@@ -284,6 +288,10 @@ func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 		}
 	}
 
+	if info.Hedge != nil {
+		// 对冲请求落败时取消上游请求
+		req = req.WithContext(info.Hedge.Context())
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		logger.LogError(c, "do request failed: "+err.Error())
@@ -321,3 +329,21 @@ func DoTaskApiRequest(a TaskAdaptor, c *gin.Context, info *common.RelayInfo, req
 	}
 	return resp, nil
 }
+
+// DoTaskCancelRequest 发送取消任务请求，非 2xx 响应视为失败
+func DoTaskCancelRequest(req *http.Request, proxy string) error {
+	client, err := service.GetHttpClientWithProxy(proxy)
+	if err != nil {
+		return fmt.Errorf("new proxy http client failed: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("upstream returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
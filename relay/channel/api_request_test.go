@@ -0,0 +1,43 @@
+package channel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/constant"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSetupApiRequestHeaderContentType(t *testing.T) {
+	const multipartType = "multipart/form-data; boundary=abc"
+	tests := []struct {
+		name                string
+		clientContentType   string
+		upstreamContentType string
+		want                string
+	}{
+		{"client content type", gin.MIMEJSON, "", gin.MIMEJSON},
+		{"multipart passthrough", multipartType, "", multipartType},
+		{"multipart converted to json", multipartType, gin.MIMEJSON, gin.MIMEJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/images/edits", nil)
+			c.Request.Header.Set("Content-Type", tt.clientContentType)
+			info := &relaycommon.RelayInfo{RelayMode: constant.RelayModeImagesEdits, UpstreamContentType: tt.upstreamContentType}
+			header := http.Header{}
+			SetupApiRequestHeader(info, c, &header)
+			if got := header.Get("Content-Type"); got != tt.want {
+				t.Errorf("Content-Type = %q, want %q", got, tt.want)
+			}
+			// 客户端请求头保持不变，重试时仍可重新解析表单
+			if got := c.Request.Header.Get("Content-Type"); got != tt.clientContentType {
+				t.Errorf("client Content-Type = %q, want %q", got, tt.clientContentType)
+			}
+		})
+	}
+}
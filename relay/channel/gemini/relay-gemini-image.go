@@ -0,0 +1,166 @@
+package gemini
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// imageSizeToAspectRatio 将 OpenAI 的 size 转换为宽高比，也允许直接传入 "16:9" 形式
+func imageSizeToAspectRatio(size string) string {
+	size = strings.TrimSpace(size)
+	if strings.Contains(size, ":") {
+		return size
+	}
+	switch size {
+	case "1536x1024":
+		return "3:2"
+	case "1024x1536":
+		return "2:3"
+	case "1024x1792":
+		return "9:16"
+	case "1792x1024":
+		return "16:9"
+	}
+	return "1:1"
+}
+
+// convertImageRequest2GeminiChat Gemini 原生图像模型（如 gemini-2.5-flash-image）通过 generateContent 生成和编辑图片
+func convertImageRequest2GeminiChat(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (*dto.GeminiChatRequest, error) {
+	var parts []dto.GeminiPart
+	if helper.IsImageEditMode(info.RelayMode) {
+		inputs, err := helper.GetImageEditInputs(c, request)
+		if err != nil {
+			return nil, err
+		}
+		// Gemini 不支持蒙版参数
+		if err := helper.RejectImageMask(inputs); err != nil {
+			return nil, err
+		}
+		for _, image := range inputs.Images {
+			var mimeType, data string
+			if strings.HasPrefix(image, "data:") {
+				mimeType, data, err = service.DecodeBase64FileData(image)
+			} else {
+				mimeType, data, err = service.GetImageFromUrl(image)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("load image failed: %w", err)
+			}
+			parts = append(parts, dto.GeminiPart{
+				InlineData: &dto.GeminiInlineData{
+					MimeType: mimeType,
+					Data:     data,
+				},
+			})
+		}
+	}
+	prompt := helper.BuildImageEditPrompt(request.Prompt, info.RelayMode)
+	if prompt == "" {
+		return nil, errors.New("prompt is required")
+	}
+	parts = append(parts, dto.GeminiPart{Text: prompt})
+
+	imageConfig := map[string]string{}
+	if request.Size != "" {
+		imageConfig["aspectRatio"] = imageSizeToAspectRatio(request.Size)
+	}
+	// imageSize 仅部分模型支持（如 gemini-3-pro-image-preview），取值 1K、2K、4K
+	switch request.Quality {
+	case "hd", "high", "2K":
+		imageConfig["imageSize"] = "2K"
+	case "4K":
+		imageConfig["imageSize"] = "4K"
+	}
+
+	geminiRequest := &dto.GeminiChatRequest{
+		Contents: []dto.GeminiChatContent{
+			{
+				Role:  "user",
+				Parts: parts,
+			},
+		},
+		GenerationConfig: dto.GeminiChatGenerationConfig{
+			ResponseModalities: []string{"TEXT", "IMAGE"},
+		},
+	}
+	if len(imageConfig) > 0 {
+		configData, err := common.Marshal(imageConfig)
+		if err != nil {
+			return nil, err
+		}
+		geminiRequest.GenerationConfig.ImageConfig = configData
+	}
+	return geminiRequest, nil
+}
+
+// GeminiImageChatHandler 将 generateContent 返回的图片转换为 OpenAI 图像响应，文本部分作为 revised_prompt
+func GeminiImageChatHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	service.CloseResponseBodyGracefully(resp)
+
+	var geminiResponse dto.GeminiChatResponse
+	if err := common.Unmarshal(responseBody, &geminiResponse); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+
+	openAIResponse := dto.ImageResponse{
+		Created: common.GetTimestamp(),
+		Data:    make([]dto.ImageData, 0),
+	}
+	var texts []string
+	for _, candidate := range geminiResponse.Candidates {
+		for _, part := range candidate.Content.Parts {
+			if part.InlineData != nil && strings.HasPrefix(part.InlineData.MimeType, "image/") {
+				openAIResponse.Data = append(openAIResponse.Data, dto.ImageData{
+					B64Json: part.InlineData.Data,
+				})
+			} else if part.Text != "" && !part.Thought {
+				texts = append(texts, part.Text)
+			}
+		}
+	}
+	if len(openAIResponse.Data) == 0 {
+		if geminiResponse.PromptFeedback != nil && geminiResponse.PromptFeedback.BlockReason != nil {
+			return nil, types.NewOpenAIError(errors.New("request blocked by Gemini API: "+*geminiResponse.PromptFeedback.BlockReason), types.ErrorCodePromptBlocked, http.StatusBadRequest)
+		}
+		message := "no images generated"
+		if len(texts) > 0 {
+			message = message + ": " + strings.Join(texts, "\n")
+		}
+		return nil, types.NewOpenAIError(errors.New(message), types.ErrorCodeEmptyResponse, http.StatusInternalServerError)
+	}
+	revisedPrompt := strings.Join(texts, "\n")
+	for i := range openAIResponse.Data {
+		openAIResponse.Data[i].RevisedPrompt = revisedPrompt
+	}
+
+	jsonResponse, err := common.Marshal(openAIResponse)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(resp.StatusCode)
+	_, _ = c.Writer.Write(jsonResponse)
+
+	usage := &dto.Usage{
+		PromptTokens: geminiResponse.UsageMetadata.PromptTokenCount,
+		TotalTokens:  geminiResponse.UsageMetadata.TotalTokenCount,
+	}
+	usage.CompletionTokens = usage.TotalTokens - usage.PromptTokens
+	return usage, nil
+}
@@ -6,12 +6,15 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/relay/channel"
 	"github.com/QuantumNous/new-api/relay/channel/openai"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -78,6 +81,30 @@ func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInf
 		payload.ReturnURL = true // Default to returning image URLs
 	}
 
+	// 图生图：链接放入 image_urls，上传的图片放入 binary_data_base64
+	if helper.IsImageEditMode(info.RelayMode) {
+		inputs, err := helper.GetImageEditInputs(c, request)
+		if err != nil {
+			return nil, fmt.Errorf("get edit images failed: %w", err)
+		}
+		// 即梦不支持蒙版参数
+		if err := helper.RejectImageMask(inputs); err != nil {
+			return nil, err
+		}
+		for _, image := range inputs.Images {
+			if strings.HasPrefix(image, "data:") {
+				_, data, err := service.DecodeBase64FileData(image)
+				if err != nil {
+					return nil, fmt.Errorf("decode image failed: %w", err)
+				}
+				payload.BinaryData = append(payload.BinaryData, data)
+			} else {
+				payload.ImageUrls = append(payload.ImageUrls, image)
+			}
+		}
+		payload.Prompt = helper.BuildImageEditPrompt(request.Prompt, info.RelayMode)
+	}
+
 	if len(request.ExtraFields) > 0 {
 		if err := json.Unmarshal(request.ExtraFields, &payload); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal extra fields: %w", err)
@@ -124,7 +151,7 @@ func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, request
 }
 
 func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
-	if info.RelayMode == relayconstant.RelayModeImagesGenerations {
+	if info.RelayMode == relayconstant.RelayModeImagesGenerations || helper.IsImageEditMode(info.RelayMode) {
 		usage, err = jimengImageHandler(c, resp, info)
 	} else if info.IsStream {
 		usage, err = openai.OaiStreamHandler(c, info, resp)
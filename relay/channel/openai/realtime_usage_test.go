@@ -0,0 +1,44 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+func TestRealtimeUsageReconcile(t *testing.T) {
+	tests := []struct {
+		name     string
+		upstream dto.RealtimeUsage
+		settled  dto.RealtimeUsage
+		want     dto.RealtimeUsage
+	}{
+		{
+			name:     "nothing settled",
+			upstream: dto.RealtimeUsage{TotalTokens: 30, InputTokens: 10, OutputTokens: 20},
+			want:     dto.RealtimeUsage{TotalTokens: 30, InputTokens: 10, OutputTokens: 20},
+		},
+		{
+			name:     "periodic settlement deducted",
+			upstream: dto.RealtimeUsage{TotalTokens: 30, InputTokens: 10, OutputTokens: 20},
+			settled:  dto.RealtimeUsage{TotalTokens: 8, InputTokens: 8},
+			want:     dto.RealtimeUsage{TotalTokens: 22, InputTokens: 2, OutputTokens: 20},
+		},
+		{
+			name:     "estimate above upstream never goes negative",
+			upstream: dto.RealtimeUsage{TotalTokens: 5, InputTokens: 5},
+			settled:  dto.RealtimeUsage{TotalTokens: 12, InputTokens: 12},
+			want:     dto.RealtimeUsage{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage := &dto.RealtimeUsage{}
+			addRealtimeUsage(usage, &tt.upstream)
+			subtractRealtimeUsage(usage, &tt.settled)
+			if *usage != tt.want {
+				t.Errorf("got %+v, want %+v", *usage, tt.want)
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
@@ -14,6 +16,7 @@ import (
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 
 	"github.com/QuantumNous/new-api/types"
 
@@ -334,16 +337,75 @@ func OpenaiRealtimeHandler(c *gin.Context, info *relaycommon.RelayInfo) (*types.
 	info.IsStream = true
 	clientConn := info.ClientWs
 	targetConn := info.TargetWs
+	realtimeSetting := operation_setting.GetRealtimeSetting()
+	sessionStart := time.Now()
 
 	clientClosed := make(chan struct{})
 	targetClosed := make(chan struct{})
 	sendChan := make(chan []byte, 100)
 	receiveChan := make(chan []byte, 100)
 	errChan := make(chan error, 2)
+	terminated := make(chan struct{})
+	sessionDone := make(chan struct{})
+	defer close(sessionDone)
 
 	usage := &dto.RealtimeUsage{}
 	localUsage := &dto.RealtimeUsage{}
 	sumUsage := &dto.RealtimeUsage{}
+	// 周期结算时按本地估算提前扣除的用量，收到上游用量时从中抵扣，避免重复计费
+	settledUsage := &dto.RealtimeUsage{}
+	// 收到过上游用量后，本地估算只用于周期结算，结束时不再补扣
+	upstreamUsageSeen := false
+	sessionQuota := 0
+	// 两个读协程与结算协程都会修改用量
+	var usageMu sync.Mutex
+	// 两个读协程都会向客户端写入，websocket 连接不支持并发写
+	var clientWriteMu sync.Mutex
+	var terminateOnce sync.Once
+
+	recorder := service.NewRealtimeRecorder(c, info)
+	defer func() {
+		if path := recorder.Close(); path != "" {
+			logger.LogInfo(c, "realtime session events recorded to "+path)
+		}
+	}()
+
+	writeClient := func(message []byte) error {
+		clientWriteMu.Lock()
+		defer clientWriteMu.Unlock()
+		return helper.WssString(c, clientConn, string(message))
+	}
+	sendClientError := func(code string, message string) {
+		clientWriteMu.Lock()
+		defer clientWriteMu.Unlock()
+		helper.WssError(c, clientConn, types.OpenAIError{
+			Message: message,
+			Type:    "invalid_request_error",
+			Code:    code,
+		})
+	}
+	// terminate 通过 error 事件告知客户端原因后结束会话
+	terminate := func(code string, message string) {
+		terminateOnce.Do(func() {
+			logger.LogWarn(c, fmt.Sprintf("realtime session terminated, code: %s, message: %s", code, message))
+			sendClientError(code, message)
+			close(terminated)
+		})
+	}
+	// settle 结算一次用量并检查会话额度上限，调用方需持有 usageMu
+	settle := func(u *dto.RealtimeUsage) bool {
+		quota, err := preConsumeUsage(c, info, u, sumUsage)
+		if err != nil {
+			terminate("insufficient_user_quota", err.Error())
+			return false
+		}
+		sessionQuota += quota
+		if realtimeSetting.MaxSessionQuota > 0 && sessionQuota >= realtimeSetting.MaxSessionQuota {
+			terminate("session_max_cost_exceeded", fmt.Sprintf("realtime session reached the max cost of %s", logger.FormatQuota(realtimeSetting.MaxSessionQuota)))
+			return false
+		}
+		return true
+	}
 
 	gopool.Go(func() {
 		defer func() {
@@ -364,6 +426,7 @@ func OpenaiRealtimeHandler(c *gin.Context, info *relaycommon.RelayInfo) (*types.
 					close(clientClosed)
 					return
 				}
+				recorder.Record(service.RealtimeDirectionClient, message)
 
 				realtimeEvent := &dto.RealtimeEvent{}
 				err = common.Unmarshal(message, realtimeEvent)
@@ -372,6 +435,13 @@ func OpenaiRealtimeHandler(c *gin.Context, info *relaycommon.RelayInfo) (*types.
 					return
 				}
 
+				// 含敏感词的会话条目不转发给上游，会话继续
+				if contains, words := service.CheckRealtimeEventSensitive(realtimeEvent); contains {
+					logger.LogWarn(c, fmt.Sprintf("realtime user sensitive words detected: %s", strings.Join(words, ", ")))
+					sendClientError("sensitive_words_detected", "sensitive words detected, the event was rejected")
+					continue
+				}
+
 				if realtimeEvent.Type == dto.RealtimeEventTypeSessionUpdate {
 					if realtimeEvent.Session != nil {
 						if realtimeEvent.Session.Tools != nil {
@@ -386,10 +456,12 @@ func OpenaiRealtimeHandler(c *gin.Context, info *relaycommon.RelayInfo) (*types.
 					return
 				}
 				logger.LogInfo(c, fmt.Sprintf("type: %s, textToken: %d, audioToken: %d", realtimeEvent.Type, textToken, audioToken))
+				usageMu.Lock()
 				localUsage.TotalTokens += textToken + audioToken
 				localUsage.InputTokens += textToken + audioToken
 				localUsage.InputTokenDetails.TextTokens += textToken
 				localUsage.InputTokenDetails.AudioTokens += audioToken
+				usageMu.Unlock()
 
 				err = helper.WssString(c, targetConn, string(message))
 				if err != nil {
@@ -425,6 +497,7 @@ func OpenaiRealtimeHandler(c *gin.Context, info *relaycommon.RelayInfo) (*types.
 					return
 				}
 				info.SetFirstResponseTime()
+				recorder.Record(service.RealtimeDirectionServer, message)
 				realtimeEvent := &dto.RealtimeEvent{}
 				err = common.Unmarshal(message, realtimeEvent)
 				if err != nil {
@@ -432,29 +505,29 @@ func OpenaiRealtimeHandler(c *gin.Context, info *relaycommon.RelayInfo) (*types.
 					return
 				}
 
+				// 转写与输出文本已无法撤回，命中敏感词时结束会话
+				if contains, words := service.CheckRealtimeEventSensitive(realtimeEvent); contains {
+					logger.LogWarn(c, fmt.Sprintf("realtime transcript sensitive words detected: %s", strings.Join(words, ", ")))
+					terminate("sensitive_words_detected", "sensitive words detected, the session was terminated")
+					return
+				}
+
 				if realtimeEvent.Type == dto.RealtimeEventTypeResponseDone {
+					usageMu.Lock()
+					var ok bool
 					realtimeUsage := realtimeEvent.Response.Usage
 					if realtimeUsage != nil {
-						usage.TotalTokens += realtimeUsage.TotalTokens
-						usage.InputTokens += realtimeUsage.InputTokens
-						usage.OutputTokens += realtimeUsage.OutputTokens
-						usage.InputTokenDetails.AudioTokens += realtimeUsage.InputTokenDetails.AudioTokens
-						usage.InputTokenDetails.CachedTokens += realtimeUsage.InputTokenDetails.CachedTokens
-						usage.InputTokenDetails.TextTokens += realtimeUsage.InputTokenDetails.TextTokens
-						usage.OutputTokenDetails.AudioTokens += realtimeUsage.OutputTokenDetails.AudioTokens
-						usage.OutputTokenDetails.TextTokens += realtimeUsage.OutputTokenDetails.TextTokens
-						err := preConsumeUsage(c, info, usage, sumUsage)
-						if err != nil {
-							errChan <- fmt.Errorf("error consume usage: %v", err)
-							return
-						}
-						// 本次计费完成，清除
+						addRealtimeUsage(usage, realtimeUsage)
+						subtractRealtimeUsage(usage, settledUsage)
+						ok = settle(usage)
+						// 本次计费完成，清除；上游用量已包含本地估算的部分
 						usage = &dto.RealtimeUsage{}
-
 						localUsage = &dto.RealtimeUsage{}
+						upstreamUsageSeen = true
 					} else {
 						textToken, audioToken, err := service.CountTokenRealtime(info, *realtimeEvent, info.UpstreamModelName)
 						if err != nil {
+							usageMu.Unlock()
 							errChan <- fmt.Errorf("error counting text token: %v", err)
 							return
 						}
@@ -464,18 +537,17 @@ func OpenaiRealtimeHandler(c *gin.Context, info *relaycommon.RelayInfo) (*types.
 						localUsage.InputTokens += textToken + audioToken
 						localUsage.InputTokenDetails.TextTokens += textToken
 						localUsage.InputTokenDetails.AudioTokens += audioToken
-						err = preConsumeUsage(c, info, localUsage, sumUsage)
-						if err != nil {
-							errChan <- fmt.Errorf("error consume usage: %v", err)
-							return
-						}
+						ok = settle(localUsage)
 						// 本次计费完成，清除
 						localUsage = &dto.RealtimeUsage{}
-						// print now usage
 					}
+					settledUsage = &dto.RealtimeUsage{}
 					logger.LogInfo(c, fmt.Sprintf("realtime streaming sumUsage: %v", sumUsage))
 					logger.LogInfo(c, fmt.Sprintf("realtime streaming localUsage: %v", localUsage))
-					logger.LogInfo(c, fmt.Sprintf("realtime streaming localUsage: %v", localUsage))
+					usageMu.Unlock()
+					if !ok {
+						return
+					}
 
 				} else if realtimeEvent.Type == dto.RealtimeEventTypeSessionUpdated || realtimeEvent.Type == dto.RealtimeEventTypeSessionCreated {
 					realtimeSession := realtimeEvent.Session
@@ -491,13 +563,15 @@ func OpenaiRealtimeHandler(c *gin.Context, info *relaycommon.RelayInfo) (*types.
 						return
 					}
 					logger.LogInfo(c, fmt.Sprintf("type: %s, textToken: %d, audioToken: %d", realtimeEvent.Type, textToken, audioToken))
+					usageMu.Lock()
 					localUsage.TotalTokens += textToken + audioToken
 					localUsage.OutputTokens += textToken + audioToken
 					localUsage.OutputTokenDetails.TextTokens += textToken
 					localUsage.OutputTokenDetails.AudioTokens += audioToken
+					usageMu.Unlock()
 				}
 
-				err = helper.WssString(c, clientConn, string(message))
+				err = writeClient(message)
 				if err != nil {
 					errChan <- fmt.Errorf("error writing to client: %v", err)
 					return
@@ -511,21 +585,68 @@ func OpenaiRealtimeHandler(c *gin.Context, info *relaycommon.RelayInfo) (*types.
 		}
 	})
 
+	// 周期结算本地估算的用量，长时间语音输入不必等到响应完成才扣费；同时负责会话时长上限
+	if realtimeSetting.SettleIntervalSeconds > 0 || realtimeSetting.MaxSessionSeconds > 0 {
+		gopool.Go(func() {
+			var settleTick <-chan time.Time
+			if realtimeSetting.SettleIntervalSeconds > 0 {
+				ticker := time.NewTicker(time.Duration(realtimeSetting.SettleIntervalSeconds) * time.Second)
+				defer ticker.Stop()
+				settleTick = ticker.C
+			}
+			var deadline <-chan time.Time
+			if realtimeSetting.MaxSessionSeconds > 0 {
+				timer := time.NewTimer(time.Until(sessionStart.Add(time.Duration(realtimeSetting.MaxSessionSeconds) * time.Second)))
+				defer timer.Stop()
+				deadline = timer.C
+			}
+			for {
+				select {
+				case <-settleTick:
+					usageMu.Lock()
+					ok := true
+					if localUsage.TotalTokens != 0 {
+						addRealtimeUsage(settledUsage, localUsage)
+						ok = settle(localUsage)
+						localUsage = &dto.RealtimeUsage{}
+					}
+					usageMu.Unlock()
+					if !ok {
+						return
+					}
+				case <-deadline:
+					terminate("session_max_duration_exceeded", fmt.Sprintf("realtime session reached the max duration of %d seconds", realtimeSetting.MaxSessionSeconds))
+					return
+				case <-terminated:
+					return
+				case <-sessionDone:
+					return
+				}
+			}
+		})
+	}
+
 	select {
 	case <-clientClosed:
 	case <-targetClosed:
+	case <-terminated:
+		_ = clientConn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session terminated"), time.Now().Add(time.Second))
 	case err := <-errChan:
 		//return service.OpenAIErrorWrapper(err, "realtime_error", http.StatusInternalServerError), nil
 		logger.LogError(c, "realtime error: "+err.Error())
 	case <-c.Done():
 	}
 
+	usageMu.Lock()
+	defer usageMu.Unlock()
 	if usage.TotalTokens != 0 {
-		_ = preConsumeUsage(c, info, usage, sumUsage)
+		subtractRealtimeUsage(usage, settledUsage)
+		_, _ = preConsumeUsage(c, info, usage, sumUsage)
 	}
 
-	if localUsage.TotalTokens != 0 {
-		_ = preConsumeUsage(c, info, localUsage, sumUsage)
+	if localUsage.TotalTokens != 0 && !upstreamUsageSeen {
+		_, _ = preConsumeUsage(c, info, localUsage, sumUsage)
 	}
 
 	// check usage total tokens, if 0, use local usage
@@ -533,22 +654,40 @@ func OpenaiRealtimeHandler(c *gin.Context, info *relaycommon.RelayInfo) (*types.
 	return nil, sumUsage
 }
 
-func preConsumeUsage(ctx *gin.Context, info *relaycommon.RelayInfo, usage *dto.RealtimeUsage, totalUsage *dto.RealtimeUsage) error {
+func preConsumeUsage(ctx *gin.Context, info *relaycommon.RelayInfo, usage *dto.RealtimeUsage, totalUsage *dto.RealtimeUsage) (int, error) {
 	if usage == nil || totalUsage == nil {
-		return fmt.Errorf("invalid usage pointer")
+		return 0, fmt.Errorf("invalid usage pointer")
 	}
 
-	totalUsage.TotalTokens += usage.TotalTokens
-	totalUsage.InputTokens += usage.InputTokens
-	totalUsage.OutputTokens += usage.OutputTokens
-	totalUsage.InputTokenDetails.CachedTokens += usage.InputTokenDetails.CachedTokens
-	totalUsage.InputTokenDetails.TextTokens += usage.InputTokenDetails.TextTokens
-	totalUsage.InputTokenDetails.AudioTokens += usage.InputTokenDetails.AudioTokens
-	totalUsage.OutputTokenDetails.TextTokens += usage.OutputTokenDetails.TextTokens
-	totalUsage.OutputTokenDetails.AudioTokens += usage.OutputTokenDetails.AudioTokens
+	addRealtimeUsage(totalUsage, usage)
 	// clear usage
-	err := service.PreWssConsumeQuota(ctx, info, usage)
-	return err
+	return service.PreWssConsumeQuota(ctx, info, usage)
+}
+
+func addRealtimeUsage(dst *dto.RealtimeUsage, src *dto.RealtimeUsage) {
+	dst.TotalTokens += src.TotalTokens
+	dst.InputTokens += src.InputTokens
+	dst.OutputTokens += src.OutputTokens
+	dst.InputTokenDetails.CachedTokens += src.InputTokenDetails.CachedTokens
+	dst.InputTokenDetails.TextTokens += src.InputTokenDetails.TextTokens
+	dst.InputTokenDetails.AudioTokens += src.InputTokenDetails.AudioTokens
+	dst.OutputTokenDetails.TextTokens += src.OutputTokenDetails.TextTokens
+	dst.OutputTokenDetails.AudioTokens += src.OutputTokenDetails.AudioTokens
+}
+
+// subtractRealtimeUsage 从上游用量中扣除已提前结算的部分，各项最小为 0
+func subtractRealtimeUsage(dst *dto.RealtimeUsage, settled *dto.RealtimeUsage) {
+	sub := func(v *int, s int) {
+		*v = max(*v-s, 0)
+	}
+	sub(&dst.TotalTokens, settled.TotalTokens)
+	sub(&dst.InputTokens, settled.InputTokens)
+	sub(&dst.OutputTokens, settled.OutputTokens)
+	sub(&dst.InputTokenDetails.CachedTokens, settled.InputTokenDetails.CachedTokens)
+	sub(&dst.InputTokenDetails.TextTokens, settled.InputTokenDetails.TextTokens)
+	sub(&dst.InputTokenDetails.AudioTokens, settled.InputTokenDetails.AudioTokens)
+	sub(&dst.OutputTokenDetails.TextTokens, settled.OutputTokenDetails.TextTokens)
+	sub(&dst.OutputTokenDetails.AudioTokens, settled.OutputTokenDetails.AudioTokens)
 }
 
 func OpenaiHandlerWithUsage(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
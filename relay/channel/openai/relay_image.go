@@ -0,0 +1,81 @@
+package openai
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenaiImageStreamHandler 透传 gpt-image 系列的流式事件，并从 completed 事件中读取用量；
+// 上游未返回用量（如流中断、未收到 completed 事件）时按请求估算
+func OpenaiImageStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	if resp == nil || resp.Body == nil {
+		logger.LogError(c, "invalid response or response body")
+		return nil, types.NewError(fmt.Errorf("invalid response"), types.ErrorCodeBadResponse)
+	}
+
+	defer service.CloseResponseBodyGracefully(resp)
+
+	usage := &dto.Usage{}
+	partialImages := 0
+	completedImages := 0
+	helper.StreamScannerHandler(c, resp, info, func(data string) bool {
+		var event dto.ImageStreamEvent
+		if err := common.UnmarshalJsonStr(data, &event); err != nil {
+			logger.LogError(c, "failed to unmarshal image stream event: "+err.Error())
+			return true
+		}
+		helper.EventChunkData(c, event.Type, data)
+		if event.IsPartialImage() {
+			partialImages++
+		} else if event.IsCompleted() {
+			completedImages++
+			if event.Usage == nil {
+				return true
+			}
+			// 多张图片时每个 completed 事件各自携带用量
+			usage.PromptTokens += event.Usage.InputTokens
+			usage.CompletionTokens += event.Usage.OutputTokens
+			usage.TotalTokens += event.Usage.TotalTokens
+			if event.Usage.InputTokensDetails != nil {
+				usage.PromptTokensDetails.ImageTokens += event.Usage.InputTokensDetails.ImageTokens
+				usage.PromptTokensDetails.TextTokens += event.Usage.InputTokensDetails.TextTokens
+			}
+		}
+		return true
+	})
+	if partialImages > 0 {
+		c.Set("image_partial_images", partialImages)
+	}
+	if !service.ValidUsage(usage) {
+		usage = estimateImageStreamUsage(c, info, completedImages)
+	}
+	return usage, nil
+}
+
+// estimateImageStreamUsage 按预估的提示 token 与每张图片的图像输出 token 估算用量，
+// 未收到 completed 事件时按请求的张数计
+func estimateImageStreamUsage(c *gin.Context, info *relaycommon.RelayInfo, completedImages int) *dto.Usage {
+	common.SetContextKey(c, constant.ContextKeyLocalCountTokens, true)
+	usage := &dto.Usage{PromptTokens: info.GetEstimatePromptTokens()}
+	if imageReq, ok := info.Request.(*dto.ImageRequest); ok {
+		images := completedImages
+		if images == 0 {
+			images = int(max(imageReq.N, 1))
+		}
+		usage.CompletionTokens = images * operation_setting.GetGPTImage1OutputTokens(imageReq.Quality, imageReq.Size)
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
@@ -0,0 +1,37 @@
+package openai
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEstimateImageStreamUsage(t *testing.T) {
+	tests := []struct {
+		name            string
+		request         dto.Request
+		completedImages int
+		wantCompletion  int
+	}{
+		{name: "no completed event uses n", request: &dto.ImageRequest{N: 2, Quality: "low", Size: "1024x1024"}, wantCompletion: 2 * 272},
+		{name: "n defaults to one", request: &dto.ImageRequest{Quality: "medium", Size: "1024x1536"}, wantCompletion: 1584},
+		{name: "completed without usage", request: &dto.ImageRequest{N: 3, Quality: "high", Size: "1536x1024"}, completedImages: 1, wantCompletion: 6208},
+		{name: "unknown quality", request: &dto.ImageRequest{Quality: "auto"}, wantCompletion: 4160},
+		{name: "non image request", request: &dto.GeneralOpenAIRequest{}, wantCompletion: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			info := &relaycommon.RelayInfo{Request: tt.request}
+			info.SetEstimatePromptTokens(50)
+			usage := estimateImageStreamUsage(c, info, tt.completedImages)
+			if usage.PromptTokens != 50 || usage.CompletionTokens != tt.wantCompletion || usage.TotalTokens != 50+tt.wantCompletion {
+				t.Fatalf("usage = %+v, want prompt 50 completion %d", usage, tt.wantCompletion)
+			}
+		})
+	}
+}
@@ -108,6 +108,9 @@ func OaiResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 						c.Set("image_generation_call_size", streamResponse.Response.GetSize())
 					}
 				}
+			case dto.ResponsesOutputTypeImageGenerationPartialImage:
+				// image_generation 工具的流式预览图单独计费
+				c.Set("image_generation_call_partial_images", c.GetInt("image_generation_call_partial_images")+1)
 			case "response.output_text.delta":
 				// 处理输出文本
 				responseTextBuilder.WriteString(streamResponse.Delta)
@@ -18,6 +18,7 @@ import (
 	"github.com/QuantumNous/new-api/relay/channel"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/types"
 
@@ -72,6 +73,9 @@ func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInf
 			request.Prompt = v
 		}
 	}
+	if info.RelayMode == relayconstant.RelayModeImagesVariations {
+		request.Prompt = helper.BuildImageEditPrompt(request.Prompt, info.RelayMode)
+	}
 	if strings.TrimSpace(request.Prompt) == "" {
 		return nil, errors.New("replicate adaptor: prompt is required")
 	}
@@ -123,15 +127,21 @@ func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInf
 		inputPayload["prompt_upsampling"] = true
 	}
 
-	if info.RelayMode == relayconstant.RelayModeImagesEdits {
-		imageURL, err := uploadFileFromForm(c, info, "image", "image[]", "image_prompt")
+	if helper.IsImageEditMode(info.RelayMode) {
+		imageURL, maskURL, err := getEditImageURLs(c, info, request)
 		if err != nil {
 			return nil, err
 		}
 		if imageURL == "" {
 			return nil, errors.New("replicate adaptor: image file is required for edits")
 		}
-		inputPayload["image_prompt"] = imageURL
+		// 带蒙版时按 flux-fill 系列模型的 image/mask 参数传入，否则作为参考图
+		if maskURL != "" {
+			inputPayload["image"] = imageURL
+			inputPayload["mask"] = maskURL
+		} else {
+			inputPayload["image_prompt"] = imageURL
+		}
 	}
 
 	if len(request.ExtraFields) > 0 {
@@ -396,6 +406,32 @@ func normalizeFluxDimension(value int) int {
 	return value
 }
 
+// getEditImageURLs 表单上传的图片与蒙版先上传到 Replicate 文件服务，JSON 请求中的链接或 data URL 直接使用
+func getEditImageURLs(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (imageURL string, maskURL string, err error) {
+	if !helper.IsMultipartRequest(c) {
+		inputs, err := helper.GetImageEditInputs(c, request)
+		if err != nil {
+			return "", "", fmt.Errorf("replicate adaptor: %w", err)
+		}
+		return inputs.Images[0], inputs.Mask, nil
+	}
+	imageURL, err = uploadFileFromForm(c, info, "image", "image[]", "image_prompt")
+	if err != nil {
+		return "", "", err
+	}
+	maskFiles, err := helper.GetFormImageFiles(c, "mask")
+	if err != nil {
+		return "", "", fmt.Errorf("replicate adaptor: %w", err)
+	}
+	if len(maskFiles) > 0 {
+		maskURL, err = uploadFileFromForm(c, info, "mask")
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return imageURL, maskURL, nil
+}
+
 func uploadFileFromForm(c *gin.Context, info *relaycommon.RelayInfo, fieldCandidates ...string) (string, error) {
 	if info == nil {
 		return "", errors.New("replicate adaptor: relay info is nil")
@@ -126,6 +126,12 @@ func (a *TaskAdaptor) ValidateRequestAndSetAction(c *gin.Context, info *relaycom
 	if err := common.UnmarshalBodyReusable(c, &taskReq); err != nil {
 		return service.TaskErrorWrapper(err, "unmarshal_task_request_failed", http.StatusBadRequest)
 	}
+	taskReq.NormalizeVideoRequest()
+	if capability := a.GetVideoCapability(taskReq.Model); capability != nil {
+		if err := capability.Validate(&taskReq); err != nil {
+			return service.TaskErrorWrapperLocal(err, "unsupported_video_option", http.StatusBadRequest)
+		}
+	}
 	aliReq, err := a.convertToAliRequest(info, taskReq)
 	if err != nil {
 		return service.TaskErrorWrapper(err, "convert_to_ali_request_failed", http.StatusInternalServerError)
@@ -178,6 +184,30 @@ var (
 	}
 )
 
+// resolutionToSize 文生视频需要传具体尺寸，按分辨率和宽高比选取对应档位中最接近的尺寸
+func resolutionToSize(resolution string, aspectRatio string) string {
+	var sizes []string
+	switch strings.ToUpper(resolution) {
+	case "480P":
+		sizes = size480p
+	case "720P":
+		sizes = size720p
+	case "1080P":
+		sizes = size1080p
+	default:
+		return ""
+	}
+	if aspectRatio == "" {
+		return sizes[0]
+	}
+	for _, size := range sizes {
+		if relaycommon.AspectRatioFromSize(size) == aspectRatio {
+			return size
+		}
+	}
+	return sizes[0]
+}
+
 func sizeToResolution(size string) (string, error) {
 	if lo.Contains(size480p, size) {
 		return "480P", nil
@@ -191,39 +221,6 @@ func sizeToResolution(size string) (string, error) {
 
 func ProcessAliOtherRatios(aliReq *AliVideoRequest) (map[string]float64, error) {
 	otherRatios := make(map[string]float64)
-	aliRatios := map[string]map[string]float64{
-		"wan2.5-t2v-preview": {
-			"480P":  1,
-			"720P":  2,
-			"1080P": 1 / 0.3,
-		},
-		"wan2.2-t2v-plus": {
-			"480P":  1,
-			"1080P": 0.7 / 0.14,
-		},
-		"wan2.5-i2v-preview": {
-			"480P":  1,
-			"720P":  2,
-			"1080P": 1 / 0.3,
-		},
-		"wan2.2-i2v-plus": {
-			"480P":  1,
-			"1080P": 0.7 / 0.14,
-		},
-		"wan2.2-kf2v-flash": {
-			"480P":  1,
-			"720P":  2,
-			"1080P": 4.8,
-		},
-		"wan2.2-i2v-flash": {
-			"480P": 1,
-			"720P": 2,
-		},
-		"wan2.2-s2v": {
-			"480P": 1,
-			"720P": 0.9 / 0.5,
-		},
-	}
 	var resolution string
 
 	// size match
@@ -239,7 +236,7 @@ func ProcessAliOtherRatios(aliReq *AliVideoRequest) (map[string]float64, error)
 			resolution = resolution + "P"
 		}
 	}
-	if otherRatio, ok := aliRatios[aliReq.Model]; ok {
+	if otherRatio, ok := aliResolutionRatios[aliReq.Model]; ok {
 		if ratio, ok := otherRatio[resolution]; ok {
 			otherRatios[fmt.Sprintf("resolution-%s", resolution)] = ratio
 		}
@@ -247,6 +244,78 @@ func ProcessAliOtherRatios(aliReq *AliVideoRequest) (map[string]float64, error)
 	return otherRatios, nil
 }
 
+// aliResolutionRatios 各模型相对 480P 的分辨率价格倍率，同时决定模型可选的分辨率
+var aliResolutionRatios = map[string]map[string]float64{
+	"wan2.5-t2v-preview": {
+		"480P":  1,
+		"720P":  2,
+		"1080P": 1 / 0.3,
+	},
+	"wan2.2-t2v-plus": {
+		"480P":  1,
+		"1080P": 0.7 / 0.14,
+	},
+	"wan2.5-i2v-preview": {
+		"480P":  1,
+		"720P":  2,
+		"1080P": 1 / 0.3,
+	},
+	"wan2.2-i2v-plus": {
+		"480P":  1,
+		"1080P": 0.7 / 0.14,
+	},
+	"wan2.2-kf2v-flash": {
+		"480P":  1,
+		"720P":  2,
+		"1080P": 4.8,
+	},
+	"wan2.2-i2v-flash": {
+		"480P": 1,
+		"720P": 2,
+	},
+	"wan2.2-s2v": {
+		"480P": 1,
+		"720P": 0.9 / 0.5,
+	},
+}
+
+// GetVideoCapability 通义万相 wan2.5 支持 5/10 秒与音频，wan2.2 固定 5 秒；kf2v 为首尾帧，t2v 不支持图片
+func (a *TaskAdaptor) GetVideoCapability(modelName string) *relaycommon.VideoCapability {
+	capability := &relaycommon.VideoCapability{
+		Durations:         []int{5},
+		Resolutions:       []string{"480p", "720p", "1080p"},
+		MaxImages:         1,
+		Seed:              true,
+		DefaultDuration:   5,
+		DefaultResolution: "720p",
+	}
+	if strings.HasPrefix(modelName, "wan2.5") {
+		capability.Durations = []int{5, 10}
+		capability.Audio = true
+		capability.DefaultResolution = "1080p"
+	}
+	switch {
+	case strings.Contains(modelName, "t2v"):
+		capability.MaxImages = 0
+		if strings.HasPrefix(modelName, "wan2.2") {
+			capability.DefaultResolution = "1080p"
+		}
+	case strings.Contains(modelName, "kf2v"):
+		capability.MaxImages = 2
+	case strings.HasPrefix(modelName, "wan2.2-i2v-plus"):
+		capability.DefaultResolution = "1080p"
+	}
+	if ratios, ok := aliResolutionRatios[modelName]; ok {
+		capability.Resolutions = capability.Resolutions[:0]
+		for _, resolution := range []string{"480P", "720P", "1080P"} {
+			if _, ok := ratios[resolution]; ok {
+				capability.Resolutions = append(capability.Resolutions, strings.ToLower(resolution))
+			}
+		}
+	}
+	return capability
+}
+
 func (a *TaskAdaptor) convertToAliRequest(info *relaycommon.RelayInfo, req relaycommon.TaskSubmitReq) (*AliVideoRequest, error) {
 	aliReq := &AliVideoRequest{
 		Model: req.Model,
@@ -260,8 +329,30 @@ func (a *TaskAdaptor) convertToAliRequest(info *relaycommon.RelayInfo, req relay
 		},
 	}
 
+	// 标准字段：首尾帧生视频使用两张图，其余图生视频使用首张图
+	if len(req.Images) > 1 && strings.Contains(req.Model, "kf2v") {
+		aliReq.Input.FirstFrameURL = req.Images[0]
+		aliReq.Input.LastFrameURL = req.Images[1]
+	} else if len(req.Images) > 0 {
+		if strings.Contains(req.Model, "kf2v") {
+			aliReq.Input.FirstFrameURL = req.Images[0]
+		} else {
+			aliReq.Input.ImgURL = req.Images[0]
+		}
+	}
+	if req.Seed != nil {
+		aliReq.Parameters.Seed = int(*req.Seed)
+	}
+	aliReq.Parameters.Audio = req.Audio
+
 	// 处理分辨率映射
-	if req.Size != "" {
+	if req.Size == "" && req.Resolution != "" {
+		if strings.Contains(req.Model, "t2v") {
+			aliReq.Parameters.Size = resolutionToSize(req.Resolution, req.AspectRatio)
+		} else {
+			aliReq.Parameters.Resolution = strings.ToUpper(req.Resolution)
+		}
+	} else if req.Size != "" {
 		// text to video size must be contained *
 		if strings.Contains(req.Model, "t2v") && !strings.Contains(req.Size, "*") {
 			return nil, fmt.Errorf("invalid size: %s, example: %s", req.Size, "1920*1080")
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
@@ -16,6 +17,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
 )
 
 // ============================
@@ -26,6 +28,7 @@ type ContentItem struct {
 	Type     string    `json:"type"`                // "text" or "image_url"
 	Text     string    `json:"text,omitempty"`      // for text type
 	ImageURL *ImageURL `json:"image_url,omitempty"` // for image_url type
+	Role     string    `json:"role,omitempty"`      // first_frame, last_frame or reference_image
 }
 
 type ImageURL struct {
@@ -33,8 +36,15 @@ type ImageURL struct {
 }
 
 type requestPayload struct {
-	Model   string        `json:"model"`
-	Content []ContentItem `json:"content"`
+	Model         string        `json:"model"`
+	Content       []ContentItem `json:"content"`
+	Resolution    string        `json:"resolution,omitempty"`
+	Ratio         string        `json:"ratio,omitempty"`
+	Duration      int           `json:"duration,omitempty"`
+	Seed          *int64        `json:"seed,omitempty"`
+	CameraFixed   *bool         `json:"camera_fixed,omitempty"`
+	Watermark     *bool         `json:"watermark,omitempty"`
+	GenerateAudio *bool         `json:"generate_audio,omitempty"`
 }
 
 type responsePayload struct {
@@ -170,6 +180,20 @@ func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any, proxy
 	return client.Do(req)
 }
 
+// CancelTask 取消排队中的任务，上游取消后不计费，全额退还
+func (a *TaskAdaptor) CancelTask(baseUrl, key string, task *model.Task, proxy string) (float64, error) {
+	uri := fmt.Sprintf("%s/api/v3/contents/generations/tasks/%s", baseUrl, task.TaskID)
+	req, err := http.NewRequest(http.MethodDelete, uri, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	if err := channel.DoTaskCancelRequest(req, proxy); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
 func (a *TaskAdaptor) GetModelList() []string {
 	return ModelList
 }
@@ -194,26 +218,67 @@ func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq) (*
 
 	// Add images if present
 	if req.HasImage() {
-		for _, imgURL := range req.Images {
-			r.Content = append(r.Content, ContentItem{
+		for i, imgURL := range req.Images {
+			item := ContentItem{
 				Type: "image_url",
 				ImageURL: &ImageURL{
 					URL: imgURL,
 				},
-			})
+			}
+			// 两张图为首尾帧，lite-i2v 多张图为参考图
+			if len(req.Images) > 2 {
+				item.Role = "reference_image"
+			} else if len(req.Images) == 2 {
+				item.Role = lo.Ternary(i == 0, "first_frame", "last_frame")
+			}
+			r.Content = append(r.Content, item)
 		}
 	}
 
-	// TODO: Add support for additional parameters from metadata
-	// such as ratio, duration, seed, etc.
-	// metadata := req.Metadata
-	// if metadata != nil {
-	//     // Parse and apply metadata parameters
-	// }
+	r.Resolution = req.Resolution
+	r.Ratio = req.AspectRatio
+	r.Duration = req.Duration
+	r.Seed = req.Seed
+	r.GenerateAudio = req.Audio
+
+	if req.Metadata != nil {
+		metadataBytes, err := json.Marshal(req.Metadata)
+		if err != nil {
+			return nil, errors.Wrap(err, "metadata marshal metadata failed")
+		}
+		if err := json.Unmarshal(metadataBytes, &r); err != nil {
+			return nil, errors.Wrap(err, "unmarshal metadata failed")
+		}
+	}
 
 	return &r, nil
 }
 
+// GetVideoCapability 即梦 Seedance 支持 2-12 秒、480p-1080p，两张图为首尾帧，lite-i2v 最多 4 张参考图，1.5 起支持生成音频
+func (a *TaskAdaptor) GetVideoCapability(modelName string) *relaycommon.VideoCapability {
+	capability := &relaycommon.VideoCapability{
+		MinDuration:       2,
+		MaxDuration:       12,
+		Resolutions:       []string{"480p", "720p", "1080p"},
+		AspectRatios:      []string{"16:9", "4:3", "1:1", "3:4", "9:16", "21:9", "adaptive"},
+		MaxImages:         2,
+		Seed:              true,
+		Audio:             strings.Contains(modelName, "seedance-1-5"),
+		DefaultDuration:   5,
+		DefaultResolution: "1080p",
+	}
+	if strings.Contains(modelName, "lite") {
+		capability.DefaultResolution = "720p"
+	}
+	if strings.Contains(modelName, "lite-i2v") {
+		capability.MaxImages = 4
+	}
+	if strings.Contains(modelName, "t2v") {
+		capability.MaxImages = 0
+	}
+	return capability
+}
+
 func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, error) {
 	resTask := responseTask{}
 	if err := json.Unmarshal(respBody, &resTask); err != nil {
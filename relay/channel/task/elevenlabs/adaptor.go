@@ -0,0 +1,230 @@
+package elevenlabs
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TaskAdaptor ElevenLabs 音效、音乐与语音合成，上游均同步返回音频文件，任务在提交时直接完成
+// https://elevenlabs.io/docs/api-reference/text-to-sound-effects/convert
+// https://elevenlabs.io/docs/api-reference/music/compose
+// https://elevenlabs.io/docs/api-reference/text-to-speech/convert
+type TaskAdaptor struct {
+	ChannelType int
+	apiKey      string
+	baseURL     string
+	format      string
+	voice       string
+	syncResult  *relaycommon.TaskInfo
+}
+
+type SoundGenerationRequest struct {
+	Text            string   `json:"text"`
+	ModelID         string   `json:"model_id,omitempty"`
+	DurationSeconds *float64 `json:"duration_seconds,omitempty"`
+}
+
+type MusicRequest struct {
+	Prompt            string `json:"prompt"`
+	ModelID           string `json:"model_id,omitempty"`
+	MusicLengthMs     int    `json:"music_length_ms,omitempty"`
+	ForceInstrumental bool   `json:"force_instrumental,omitempty"`
+}
+
+type SpeechRequest struct {
+	Text    string `json:"text"`
+	ModelID string `json:"model_id"`
+}
+
+func (a *TaskAdaptor) Init(info *relaycommon.RelayInfo) {
+	a.ChannelType = info.ChannelType
+	a.baseURL = info.ChannelBaseUrl
+	a.apiKey = info.ApiKey
+}
+
+func (a *TaskAdaptor) GetAudioCapability(modelName string) *relaycommon.AudioCapability {
+	formats := []string{"mp3", "opus", "pcm"}
+	switch {
+	case modelName == ModelSoundEffect:
+		return &relaycommon.AudioCapability{
+			Types:           []string{constant.TaskActionSoundEffect},
+			Duration:        true,
+			MinDuration:     0.5,
+			MaxDuration:     30,
+			DefaultDuration: DefaultSoundEffectDuration,
+			Formats:         formats,
+		}
+	case strings.HasPrefix(modelName, "music_"):
+		return &relaycommon.AudioCapability{
+			Types:           []string{constant.TaskActionSong},
+			Duration:        true,
+			MinDuration:     10,
+			MaxDuration:     300,
+			DefaultDuration: DefaultMusicDuration,
+			Formats:         formats,
+		}
+	case strings.HasPrefix(modelName, "eleven_"):
+		return &relaycommon.AudioCapability{
+			Types:          []string{constant.TaskActionSpeech},
+			MaxInputLength: speechMaxInputLength[modelName],
+			Formats:        formats,
+		}
+	}
+	return nil
+}
+
+func (a *TaskAdaptor) ValidateRequestAndSetAction(c *gin.Context, info *relaycommon.RelayInfo) *dto.TaskError {
+	return relaycommon.ValidateAudioGenerationRequest(c, info, a.GetAudioCapability)
+}
+
+// BuildRequestURL 在 BuildRequestBody 之后调用，输出格式与音色取自请求
+func (a *TaskAdaptor) BuildRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	endpoint := SoundGenerationEndpoint
+	switch info.Action {
+	case constant.TaskActionSong:
+		endpoint = MusicEndpoint
+	case constant.TaskActionSpeech:
+		endpoint = fmt.Sprintf(TextToSpeechEndpoint, url.PathEscape(a.voice))
+	}
+	return fmt.Sprintf("%s%s?output_format=%s", a.baseURL, endpoint, outputFormats[a.format].Format), nil
+}
+
+func (a *TaskAdaptor) BuildRequestHeader(c *gin.Context, req *http.Request, info *relaycommon.RelayInfo) error {
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", outputFormats[a.format].ContentType)
+	req.Header.Set("xi-api-key", a.apiKey)
+	return nil
+}
+
+func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayInfo) (io.Reader, error) {
+	req, err := relaycommon.GetAudioGenerationRequest(c)
+	if err != nil {
+		return nil, err
+	}
+	a.format = req.ResponseFormat
+	if _, ok := outputFormats[a.format]; !ok {
+		a.format = "mp3"
+	}
+	a.voice = req.Voice
+	if a.voice == "" {
+		a.voice = DefaultVoice
+	}
+	var payload any
+	switch info.Action {
+	case constant.TaskActionSong:
+		prompt := req.Prompt
+		if req.Style != "" {
+			prompt = strings.Trim(req.Style+", "+prompt, ", ")
+		}
+		if req.Lyrics != "" {
+			prompt = fmt.Sprintf("%s\n\nLyrics:\n%s", prompt, req.Lyrics)
+		}
+		payload = MusicRequest{
+			Prompt:            strings.TrimSpace(prompt),
+			ModelID:           info.UpstreamModelName,
+			MusicLengthMs:     int(req.Duration * 1000),
+			ForceInstrumental: req.Instrumental,
+		}
+	case constant.TaskActionSpeech:
+		payload = SpeechRequest{
+			Text:    req.Input,
+			ModelID: info.UpstreamModelName,
+		}
+	default:
+		soundReq := SoundGenerationRequest{
+			Text:    req.Prompt,
+			ModelID: info.UpstreamModelName,
+		}
+		if req.Duration > 0 {
+			soundReq.DurationSeconds = &req.Duration
+		}
+		payload = soundReq
+	}
+	data, err := req.BuildPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+func (a *TaskAdaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (*http.Response, error) {
+	return channel.DoTaskApiRequest(a, c, info, requestBody)
+}
+
+// DoResponse 上游直接返回音频文件，结果以 data URL 交给网关归档，响应由网关统一返回
+func (a *TaskAdaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (taskID string, taskData []byte, taskErr *dto.TaskError) {
+	audio, err := io.ReadAll(resp.Body)
+	if err != nil {
+		taskErr = service.TaskErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+		return
+	}
+	_ = resp.Body.Close()
+	if len(audio) == 0 {
+		taskErr = service.TaskErrorWrapper(fmt.Errorf("elevenlabs returned empty audio"), "empty_audio", http.StatusInternalServerError)
+		return
+	}
+	output := outputFormats[a.format]
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" || strings.HasPrefix(contentType, "application/") {
+		contentType = output.ContentType
+	}
+	taskID = resp.Header.Get("request-id")
+	if taskID == "" {
+		taskID = "elevenlabs-" + common.GetUUID()
+	}
+	a.syncResult = &relaycommon.TaskInfo{
+		TaskID:   taskID,
+		Status:   model.TaskStatusSuccess,
+		Url:      fmt.Sprintf("data:%s;base64,%s", contentType, base64.StdEncoding.EncodeToString(audio)),
+		Progress: "100%",
+		// 固定码率的输出可由文件大小得到实际时长
+		Duration: float64(len(audio)) / output.BytesPerSec,
+	}
+	taskData, _ = common.Marshal(map[string]any{
+		"content_type":  contentType,
+		"bytes":         len(audio),
+		"output_format": output.Format,
+	})
+	return taskID, taskData, nil
+}
+
+func (a *TaskAdaptor) SyncTaskResult() *relaycommon.TaskInfo {
+	return a.syncResult
+}
+
+// InlineTaskResult 上游直接返回音频文件，结果只能保存到媒体存储
+func (a *TaskAdaptor) InlineTaskResult() bool {
+	return true
+}
+
+// FetchTask 任务在提交时已完成，无需查询
+func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any, proxy string) (*http.Response, error) {
+	return nil, fmt.Errorf("elevenlabs tasks are completed on submit")
+}
+
+func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, error) {
+	return nil, fmt.Errorf("elevenlabs tasks are completed on submit")
+}
+
+func (a *TaskAdaptor) GetModelList() []string {
+	return ModelList
+}
+
+func (a *TaskAdaptor) GetChannelName() string {
+	return ChannelName
+}
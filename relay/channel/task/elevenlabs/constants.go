@@ -0,0 +1,51 @@
+package elevenlabs
+
+const (
+	ChannelName = "elevenlabs"
+)
+
+const (
+	ModelSoundEffect = "eleven_text_to_sound_v2"
+	ModelMusic       = "music_v1"
+)
+
+var ModelList = []string{
+	ModelSoundEffect,
+	ModelMusic,
+	"eleven_v3",
+	"eleven_multilingual_v2",
+	"eleven_turbo_v2_5",
+	"eleven_flash_v2_5",
+}
+
+const (
+	SoundGenerationEndpoint = "/v1/sound-generation"
+	MusicEndpoint           = "/v1/music"
+	TextToSpeechEndpoint    = "/v1/text-to-speech/%s"
+)
+
+const (
+	DefaultVoice = "21m00Tcm4TlvDq8ikWAM"
+	// 未指定时长时由模型决定，预扣费按估算值，完成后按实际时长结算
+	DefaultSoundEffectDuration = 5
+	DefaultMusicDuration       = 60
+)
+
+// speechMaxInputLength 各语音模型单次请求的文本长度上限
+var speechMaxInputLength = map[string]int{
+	"eleven_v3":              3000,
+	"eleven_multilingual_v2": 10000,
+	"eleven_turbo_v2_5":      40000,
+	"eleven_flash_v2_5":      40000,
+}
+
+// outputFormats 标准输出格式到上游 output_format 的映射，以及每秒字节数（用于由文件大小计算时长）
+var outputFormats = map[string]struct {
+	Format      string
+	ContentType string
+	BytesPerSec float64
+}{
+	"mp3":  {Format: "mp3_44100_128", ContentType: "audio/mpeg", BytesPerSec: 128000 / 8},
+	"opus": {Format: "opus_48000_128", ContentType: "audio/ogg", BytesPerSec: 128000 / 8},
+	"pcm":  {Format: "pcm_44100", ContentType: "audio/L16", BytesPerSec: 44100 * 2},
+}
@@ -40,7 +40,14 @@ type GeminiVideoGenerationConfig struct {
 
 // GeminiVideoRequest represents a single video generation instance
 type GeminiVideoRequest struct {
-	Prompt string `json:"prompt"`
+	Prompt string            `json:"prompt"`
+	Image  *GeminiVideoImage `json:"image,omitempty"` // first frame for image-to-video
+}
+
+// GeminiVideoImage represents an inline image input
+type GeminiVideoImage struct {
+	BytesBase64Encoded string `json:"bytesBase64Encoded"`
+	MimeType           string `json:"mimeType"`
 }
 
 // GeminiVideoPayload represents the complete video generation request payload
@@ -141,7 +148,21 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayIn
 		Instances: []GeminiVideoRequest{
 			{Prompt: req.Prompt},
 		},
-		Parameters: GeminiVideoGenerationConfig{},
+		Parameters: GeminiVideoGenerationConfig{
+			AspectRatio:     req.AspectRatio,
+			DurationSeconds: float64(req.Duration),
+			Resolution:      req.Resolution,
+		},
+	}
+	if len(req.Images) > 0 {
+		mimeType, data, err := service.GetImageBase64Data(req.Images[0])
+		if err != nil {
+			return nil, errors.Wrap(err, "load image failed")
+		}
+		body.Instances[0].Image = &GeminiVideoImage{
+			BytesBase64Encoded: data,
+			MimeType:           mimeType,
+		}
 	}
 
 	metadata := req.Metadata
@@ -161,6 +182,33 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayIn
 	return bytes.NewReader(data), nil
 }
 
+// GetVideoCapability Veo 3 支持 4/6/8 秒、720p/1080p 并自带音频，Veo 2 为 5-8 秒 720p
+func (a *TaskAdaptor) GetVideoCapability(modelName string) *relaycommon.VideoCapability {
+	return GetVeoCapability(modelName, false)
+}
+
+// GetVeoCapability Gemini API 与 Vertex 共用的 Veo 能力，Vertex 额外支持 seed
+func GetVeoCapability(modelName string, seed bool) *relaycommon.VideoCapability {
+	capability := &relaycommon.VideoCapability{
+		Durations:         []int{4, 6, 8},
+		Resolutions:       []string{"720p", "1080p"},
+		AspectRatios:      []string{"16:9", "9:16"},
+		MaxImages:         1,
+		Seed:              seed,
+		Audio:             true,
+		DefaultDuration:   8,
+		DefaultResolution: "720p",
+	}
+	if strings.HasPrefix(modelName, "veo-2") {
+		capability.Durations = nil
+		capability.MinDuration = 5
+		capability.MaxDuration = 8
+		capability.Resolutions = []string{"720p"}
+		capability.Audio = false
+	}
+	return capability
+}
+
 // DoRequest delegates to common helper.
 func (a *TaskAdaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (*http.Response, error) {
 	return channel.DoTaskApiRequest(a, c, info, requestBody)
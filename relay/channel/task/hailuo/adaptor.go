@@ -148,7 +148,9 @@ func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq) (*
 		duration = req.Duration
 	}
 	resolution := modelConfig.DefaultResolution
-	if req.Size != "" {
+	if req.Resolution != "" {
+		resolution = strings.ToUpper(req.Resolution)
+	} else if req.Size != "" {
 		resolution = a.parseResolutionFromSize(req.Size, modelConfig)
 	}
 
@@ -158,6 +160,19 @@ func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq) (*
 		Duration:   &duration,
 		Resolution: resolution,
 	}
+	// S2V 模型的图片为主体参考图，其余模型依次为首帧、尾帧
+	if strings.HasPrefix(req.Model, "S2V") {
+		if len(req.Images) > 0 {
+			videoRequest.SubjectReference = []SubjectReference{{Type: "character", Image: req.Images[:1]}}
+		}
+	} else {
+		if len(req.Images) > 0 {
+			videoRequest.FirstFrameImage = req.Images[0]
+		}
+		if len(req.Images) > 1 {
+			videoRequest.LastFrameImage = req.Images[1]
+		}
+	}
 	if err := req.UnmarshalMetadata(&videoRequest); err != nil {
 		return nil, errors.Wrap(err, "unmarshal metadata to video request failed")
 	}
@@ -165,6 +180,26 @@ func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq) (*
 	return videoRequest, nil
 }
 
+// GetVideoCapability 时长与分辨率取自模型配置，文生视频模型不支持图片，Hailuo-02 支持首尾帧
+func (a *TaskAdaptor) GetVideoCapability(modelName string) *relaycommon.VideoCapability {
+	modelConfig := GetModelConfig(modelName)
+	capability := &relaycommon.VideoCapability{
+		Durations:         modelConfig.SupportedDurations,
+		MaxImages:         1,
+		DefaultDuration:   DefaultDuration,
+		DefaultResolution: relaycommon.NormalizeVideoResolution(modelConfig.DefaultResolution),
+	}
+	for _, resolution := range modelConfig.SupportedResolutions {
+		capability.Resolutions = append(capability.Resolutions, relaycommon.NormalizeVideoResolution(resolution))
+	}
+	if strings.HasPrefix(modelName, "T2V") {
+		capability.MaxImages = 0
+	} else if modelName == "MiniMax-Hailuo-02" {
+		capability.MaxImages = 2
+	}
+	return capability
+}
+
 func (a *TaskAdaptor) parseResolutionFromSize(size string, modelConfig ModelConfig) string {
 	switch {
 	case strings.Contains(size, "1080"):
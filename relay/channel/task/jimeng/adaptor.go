@@ -258,6 +258,23 @@ func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any, proxy
 	return client.Do(req)
 }
 
+// CancelTask 即梦（火山引擎视觉智能）接口不支持取消任务；上游为 new-api 中转时转发取消请求，
+// 上游只有在真正取消任务后才会成功返回并全额退款，因此同样全额退还
+func (a *TaskAdaptor) CancelTask(baseUrl, key string, task *model.Task, proxy string) (float64, error) {
+	if !isNewAPIRelay(key) {
+		return 0, channel.ErrTaskCancelUnsupported
+	}
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/jimeng/tasks/%s", baseUrl, task.TaskID), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	if err := channel.DoTaskCancelRequest(req, proxy); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
 func (a *TaskAdaptor) GetModelList() []string {
 	return []string{"jimeng_vgfm_t2v_l20"}
 }
@@ -376,10 +393,31 @@ func hmacSHA256(key []byte, data []byte) []byte {
 	return h.Sum(nil)
 }
 
+// GetVideoCapability 即梦视频支持 5/10 秒，分辨率由模型决定（如 jimeng_v30_1080p），图生视频最多首尾两帧
+func (a *TaskAdaptor) GetVideoCapability(modelName string) *relaycommon.VideoCapability {
+	resolution := "720p"
+	if strings.Contains(modelName, "1080") || strings.HasSuffix(modelName, "_pro") {
+		resolution = "1080p"
+	}
+	return &relaycommon.VideoCapability{
+		Durations:         []int{5, 10},
+		Resolutions:       []string{resolution},
+		AspectRatios:      []string{"16:9", "4:3", "1:1", "3:4", "9:16", "21:9"},
+		MaxImages:         2,
+		Seed:              true,
+		DefaultDuration:   5,
+		DefaultResolution: resolution,
+	}
+}
+
 func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq) (*requestPayload, error) {
 	r := requestPayload{
-		ReqKey: req.Model,
-		Prompt: req.Prompt,
+		ReqKey:      req.Model,
+		Prompt:      req.Prompt,
+		AspectRatio: req.AspectRatio,
+	}
+	if req.Seed != nil {
+		r.Seed = *req.Seed
 	}
 
 	switch req.Duration {
@@ -235,6 +235,24 @@ func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any, proxy
 	return client.Do(req)
 }
 
+// CancelTask 可灵官方接口不支持取消任务；上游为 new-api 中转时转发取消请求，
+// 上游只有在真正取消任务后才会成功返回并全额退款，因此同样全额退还
+func (a *TaskAdaptor) CancelTask(baseUrl, key string, task *model.Task, proxy string) (float64, error) {
+	if !isNewAPIRelay(key) {
+		return 0, channel.ErrTaskCancelUnsupported
+	}
+	path := lo.Ternary(task.Action == constant.TaskActionGenerate, "/v1/videos/image2video", "/v1/videos/text2video")
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/kling%s/%s", baseUrl, path, task.TaskID), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	if err := channel.DoTaskCancelRequest(req, proxy); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
 func (a *TaskAdaptor) GetModelList() []string {
 	return []string{"kling-v1", "kling-v1-6", "kling-v2-master"}
 }
@@ -247,13 +265,34 @@ func (a *TaskAdaptor) GetChannelName() string {
 // helpers
 // ============================
 
+// GetVideoCapability 可灵支持 5/10 秒，std 模式输出 720p、pro 模式输出 1080p，图生视频可传首帧与尾帧
+func (a *TaskAdaptor) GetVideoCapability(modelName string) *relaycommon.VideoCapability {
+	return &relaycommon.VideoCapability{
+		Durations:         []int{5, 10},
+		Resolutions:       []string{"720p", "1080p"},
+		AspectRatios:      []string{"16:9", "9:16", "1:1"},
+		MaxImages:         2,
+		DefaultDuration:   5,
+		DefaultResolution: "720p",
+		ModeResolutions:   map[string]string{"std": "720p", "pro": "1080p"},
+	}
+}
+
 func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq) (*requestPayload, error) {
+	mode := req.Mode
+	if mode == "" && req.Resolution == "1080p" {
+		mode = "pro"
+	}
+	aspectRatio := req.AspectRatio
+	if aspectRatio == "" {
+		aspectRatio = a.getAspectRatio(req.Size)
+	}
 	r := requestPayload{
 		Prompt:         req.Prompt,
 		Image:          req.Image,
-		Mode:           defaultString(req.Mode, "std"),
+		Mode:           defaultString(mode, "std"),
 		Duration:       fmt.Sprintf("%d", defaultInt(req.Duration, 5)),
-		AspectRatio:    a.getAspectRatio(req.Size),
+		AspectRatio:    aspectRatio,
 		ModelName:      req.Model,
 		Model:          req.Model, // Keep consistent with model_name, double writing improves compatibility
 		CfgScale:       0.5,
@@ -266,6 +305,13 @@ func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq) (*
 	if r.ModelName == "" {
 		r.ModelName = "kling-v1"
 	}
+	// 第一张图为首帧，第二张图为尾帧
+	if len(req.Images) > 0 {
+		r.Image = req.Images[0]
+	}
+	if len(req.Images) > 1 {
+		r.ImageTail = req.Images[1]
+	}
 	metadata := req.Metadata
 	medaBytes, err := json.Marshal(metadata)
 	if err != nil {
@@ -0,0 +1,334 @@
+package midjourney
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// 文档：https://github.com/novicezk/midjourney-proxy/blob/main/docs/api.md
+// 1-提交成功，21-任务已存在，22-排队中，3-无可用账号实例，23-队列已满，其他为提交错误
+
+type TaskAdaptor struct {
+	ChannelType int
+	apiKey      string
+	baseURL     string
+	prompt      string
+}
+
+func (a *TaskAdaptor) Init(info *relaycommon.RelayInfo) {
+	a.ChannelType = info.ChannelType
+	a.baseURL = info.ChannelBaseUrl
+	a.apiKey = strings.TrimPrefix(info.ApiKey, "Bearer ")
+}
+
+func mjTaskError(code int, description string, statusCode int) *dto.TaskError {
+	return service.TaskErrorWrapperLocal(errors.New(description), strconv.Itoa(code), statusCode)
+}
+
+// resolveAction 根据请求路径确定任务操作，与 RelayMidjourneySubmit 保持一致
+func resolveAction(relayMode int, req *dto.MidjourneyRequest) (string, *dto.TaskError) {
+	switch relayMode {
+	case relayconstant.RelayModeMidjourneyAction:
+		// midjourney plus，需要从customId中获取任务信息
+		if mjErr := service.CoverPlusActionToNormalAction(req); mjErr != nil {
+			return "", mjTaskError(mjErr.Code, mjErr.Description, http.StatusBadRequest)
+		}
+		return req.Action, nil
+	case relayconstant.RelayModeMidjourneyImagine:
+		if req.Prompt == "" {
+			return "", mjTaskError(constant.MjRequestError, "prompt_is_required", http.StatusBadRequest)
+		}
+		return constant.MjActionImagine, nil
+	case relayconstant.RelayModeMidjourneyDescribe:
+		return constant.MjActionDescribe, nil
+	case relayconstant.RelayModeMidjourneyEdits:
+		return constant.MjActionEdits, nil
+	case relayconstant.RelayModeMidjourneyShorten:
+		return constant.MjActionShorten, nil
+	case relayconstant.RelayModeMidjourneyBlend:
+		return constant.MjActionBlend, nil
+	case relayconstant.RelayModeMidjourneyVideo:
+		return constant.MjActionVideo, nil
+	case relayconstant.RelayModeMidjourneyModal:
+		if req.TaskId == "" {
+			return "", mjTaskError(constant.MjRequestError, "task_id_is_required", http.StatusBadRequest)
+		}
+		return constant.MjActionModal, nil
+	case relayconstant.RelayModeSwapFace:
+		return constant.MjActionSwapFace, nil
+	case relayconstant.RelayModeMidjourneyChange:
+		if req.TaskId == "" {
+			return "", mjTaskError(constant.MjRequestError, "task_id_is_required", http.StatusBadRequest)
+		} else if req.Action == "" {
+			return "", mjTaskError(constant.MjRequestError, "action_is_required", http.StatusBadRequest)
+		} else if req.Index == 0 {
+			return "", mjTaskError(constant.MjRequestError, "index_is_required", http.StatusBadRequest)
+		}
+		return req.Action, nil
+	case relayconstant.RelayModeMidjourneySimpleChange:
+		if req.Content == "" {
+			return "", mjTaskError(constant.MjRequestError, "content_is_required", http.StatusBadRequest)
+		}
+		params := service.ConvertSimpleChangeParams(req.Content)
+		if params == nil {
+			return "", mjTaskError(constant.MjRequestError, "content_parse_failed", http.StatusBadRequest)
+		}
+		req.TaskId = params.TaskId
+		return params.Action, nil
+	default:
+		return "", mjTaskError(constant.MjRequestError, "unknown_relay_action", http.StatusBadRequest)
+	}
+}
+
+// GetOriginTaskId 返回放大、变换、重绘等操作所依赖的原任务 ID，新建类任务返回空
+func GetOriginTaskId(c *gin.Context, relayMode int) string {
+	var req dto.MidjourneyRequest
+	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
+		return ""
+	}
+	if _, taskErr := resolveAction(relayMode, &req); taskErr != nil {
+		return ""
+	}
+	switch relayMode {
+	case relayconstant.RelayModeMidjourneyAction, relayconstant.RelayModeMidjourneyChange,
+		relayconstant.RelayModeMidjourneySimpleChange, relayconstant.RelayModeMidjourneyModal,
+		relayconstant.RelayModeMidjourneyVideo:
+		return req.TaskId
+	}
+	return ""
+}
+
+func (a *TaskAdaptor) ValidateRequestAndSetAction(c *gin.Context, info *relaycommon.RelayInfo) *dto.TaskError {
+	var req dto.MidjourneyRequest
+	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
+		return mjTaskError(constant.MjRequestError, "bind_request_body_failed", http.StatusBadRequest)
+	}
+	action, taskErr := resolveAction(info.RelayMode, &req)
+	if taskErr != nil {
+		return taskErr
+	}
+	if action == constant.MjActionSwapFace {
+		var swapFaceRequest dto.SwapFaceRequest
+		if err := common.UnmarshalBodyReusable(c, &swapFaceRequest); err != nil {
+			return mjTaskError(constant.MjRequestError, "bind_request_body_failed", http.StatusBadRequest)
+		}
+		if swapFaceRequest.SourceBase64 == "" || swapFaceRequest.TargetBase64 == "" {
+			return mjTaskError(constant.MjRequestError, "sour_base64_and_target_base64_is_required", http.StatusBadRequest)
+		}
+	}
+
+	a.prompt = req.Prompt
+	if info.OriginTaskID != "" {
+		// 原任务已在 RelayTaskSubmit 中校验存在并切换到原渠道
+		originTask, exist, err := model.GetByTaskId(info.UserId, info.OriginTaskID)
+		if err != nil || !exist {
+			return mjTaskError(constant.MjRequestError, "task_not_found", http.StatusBadRequest)
+		}
+		if setting.MjActionCheckSuccessEnabled && originTask.Status != model.TaskStatusSuccess && action != constant.MjActionModal {
+			return mjTaskError(constant.MjRequestError, "task_status_not_success", http.StatusBadRequest)
+		}
+		var originDto dto.MidjourneyDto
+		if err := common.Unmarshal(originTask.Data, &originDto); err == nil {
+			a.prompt = originDto.Prompt
+		}
+	}
+
+	// 局部重绘、自定义缩放按各自模型价格计费（默认为 0），modal 提交时按 mj_modal 计费
+	info.Action = action
+	return nil
+}
+
+func getMjRequestPath(path string) string {
+	requestURL := path
+	if strings.Contains(requestURL, "/mj-") {
+		urls := strings.Split(requestURL, "/mj/")
+		if len(urls) < 2 {
+			return requestURL
+		}
+		requestURL = "/mj/" + urls[1]
+	}
+	return requestURL
+}
+
+func (a *TaskAdaptor) BuildRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	return fmt.Sprintf("%s%s", a.baseURL, getMjRequestPath(info.RequestURLPath)), nil
+}
+
+func (a *TaskAdaptor) BuildRequestHeader(c *gin.Context, req *http.Request, info *relaycommon.RelayInfo) error {
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("mj-api-secret", a.apiKey)
+	return nil
+}
+
+func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayInfo) (io.Reader, error) {
+	cachedBody, err := common.GetRequestBody(c)
+	if err != nil {
+		return nil, errors.Wrap(err, "get_request_body_failed")
+	}
+	var mapResult map[string]interface{}
+	if err := common.Unmarshal(cachedBody, &mapResult); err != nil {
+		return nil, errors.Wrap(err, "unmarshal_request_body_failed")
+	}
+	service.FilterMidjourneyRequestBody(mapResult)
+	body, err := common.Marshal(mapResult)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal_request_body_failed")
+	}
+	return bytes.NewReader(body), nil
+}
+
+func (a *TaskAdaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (*http.Response, error) {
+	return channel.DoTaskApiRequest(a, c, info, requestBody)
+}
+
+func (a *TaskAdaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (taskID string, taskData []byte, taskErr *dto.TaskError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		taskErr = service.TaskErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+		return
+	}
+	_ = resp.Body.Close()
+
+	var mjResp dto.MidjourneyResponse
+	if err := common.Unmarshal(responseBody, &mjResp); err != nil {
+		taskErr = service.TaskErrorWrapper(errors.Wrapf(err, "body: %s", responseBody), "unmarshal_response_body_failed", http.StatusInternalServerError)
+		return
+	}
+
+	if mjResp.Code == 3 {
+		//无实例账号自动禁用渠道（No available account instance）
+		ch, err := model.GetChannelById(info.ChannelId, true)
+		if err != nil {
+			common.SysLog("get_channel_null: " + err.Error())
+		} else if ch.GetAutoBan() && common.AutomaticDisableChannelEnabled {
+			model.UpdateChannelStatus(info.ChannelId, "", common.ChannelStatusAutoDisabled, "No available account instance")
+		}
+	}
+	if mjResp.Code != 1 && mjResp.Code != 21 && mjResp.Code != 22 {
+		statusCode := http.StatusBadRequest
+		if mjResp.Code == 3 || mjResp.Code == 23 {
+			// 无可用账号或队列已满时允许重试其他渠道
+			statusCode = http.StatusServiceUnavailable
+		}
+		taskErr = service.TaskErrorWrapper(fmt.Errorf("%s", mjResp.Description), strconv.Itoa(mjResp.Code), statusCode)
+		return
+	}
+	if mjResp.Result == "" {
+		taskErr = service.TaskErrorWrapper(fmt.Errorf("task_id is empty"), "invalid_response", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now().UnixMilli()
+	mjDto := dto.MidjourneyDto{
+		MjId:        mjResp.Result,
+		Action:      info.Action,
+		Prompt:      a.prompt,
+		Description: mjResp.Description,
+		SubmitTime:  now,
+		Progress:    "0%",
+	}
+	if mjResp.Code == 21 { //21-任务已存在（处理中或者有结果了）
+		if properties, ok := mjResp.Properties.(map[string]interface{}); ok {
+			imageUrl, ok1 := properties["imageUrl"].(string)
+			status, ok2 := properties["status"].(string)
+			if ok1 && ok2 {
+				mjDto.ImageUrl = imageUrl
+				mjDto.Status = status
+			}
+		}
+		//修改返回值
+		if info.Action != constant.MjActionInPaint && info.Action != constant.MjActionCustomZoom {
+			responseBody = bytes.Replace(responseBody, []byte(`"code":21`), []byte(`"code":1`), -1)
+		}
+	}
+	if mjResp.Code == 22 { //22-排队中，说明任务已存在
+		responseBody = bytes.Replace(responseBody, []byte(`"code":22`), []byte(`"code":1`), -1)
+	}
+
+	c.Data(http.StatusOK, "application/json", responseBody)
+	taskData, _ = common.Marshal(mjDto)
+	return mjResp.Result, taskData, nil
+}
+
+// FetchTask fetch task status
+func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any, proxy string) (*http.Response, error) {
+	taskID, ok := body["task_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid task_id")
+	}
+	uri := fmt.Sprintf("%s/mj/task/%s/fetch", baseUrl, taskID)
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("mj-api-secret", strings.TrimPrefix(key, "Bearer "))
+	client, err := service.GetHttpClientWithProxy(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("new proxy http client failed: %w", err)
+	}
+	return client.Do(req)
+}
+
+func (a *TaskAdaptor) GetModelList() []string {
+	return ModelList
+}
+
+func (a *TaskAdaptor) GetChannelName() string {
+	return ChannelName
+}
+
+func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, error) {
+	var mjDto dto.MidjourneyDto
+	if err := common.Unmarshal(respBody, &mjDto); err != nil {
+		return nil, errors.Wrap(err, "unmarshal task result failed")
+	}
+	taskResult := relaycommon.TaskInfo{
+		Code:     0,
+		TaskID:   mjDto.MjId,
+		Progress: mjDto.Progress,
+	}
+	switch mjDto.Status {
+	case "SUCCESS":
+		taskResult.Status = model.TaskStatusSuccess
+		taskResult.Url = mjDto.ImageUrl
+		if mjDto.VideoUrl != "" {
+			taskResult.Url = mjDto.VideoUrl
+		}
+		taskResult.Progress = "100%"
+	case "FAILURE":
+		taskResult.Status = model.TaskStatusFailure
+		taskResult.Reason = mjDto.FailReason
+		if taskResult.Reason == "" {
+			taskResult.Reason = "task failed"
+		}
+	case "IN_PROGRESS", "MODAL":
+		taskResult.Status = model.TaskStatusInProgress
+	case "SUBMITTED":
+		taskResult.Status = model.TaskStatusSubmitted
+	case "NOT_START", "":
+		taskResult.Status = model.TaskStatusQueued
+	default:
+		taskResult.Status = model.TaskStatusUnknown
+	}
+	return &taskResult, nil
+}
@@ -0,0 +1,9 @@
+package midjourney
+
+var ModelList = []string{
+	"mj_imagine", "mj_describe", "mj_blend", "mj_upscale", "mj_variation", "mj_reroll",
+	"mj_modal", "mj_inpaint", "mj_zoom", "mj_custom_zoom", "mj_shorten", "mj_high_variation",
+	"mj_low_variation", "mj_pan", "swap_face", "mj_video", "mj_edits",
+}
+
+var ChannelName = "midjourney"
@@ -0,0 +1,281 @@
+package minimax
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel"
+	"github.com/QuantumNous/new-api/relay/channel/task/hailuo"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// TaskAdaptor MiniMax 音频生成：音乐生成同步返回结果，长文本语音合成为异步任务
+// https://platform.minimaxi.com/docs/api-reference/music-generation
+// https://platform.minimaxi.com/docs/api-reference/speech-t2a-async-create
+type TaskAdaptor struct {
+	ChannelType int
+	apiKey      string
+	baseURL     string
+	syncResult  *relaycommon.TaskInfo
+}
+
+func isMusicModel(modelName string) bool {
+	return strings.HasPrefix(modelName, "music-")
+}
+
+func (a *TaskAdaptor) Init(info *relaycommon.RelayInfo) {
+	a.ChannelType = info.ChannelType
+	a.baseURL = info.ChannelBaseUrl
+	a.apiKey = info.ApiKey
+}
+
+func (a *TaskAdaptor) GetAudioCapability(modelName string) *relaycommon.AudioCapability {
+	switch {
+	case isMusicModel(modelName):
+		return &relaycommon.AudioCapability{
+			Types:           []string{constant.TaskActionSong},
+			DefaultDuration: DefaultSongDuration,
+			Formats:         []string{"mp3", "wav", "pcm"},
+		}
+	case strings.HasPrefix(modelName, "speech-"):
+		return &relaycommon.AudioCapability{
+			Types:          []string{constant.TaskActionSpeech},
+			MaxInputLength: MaxSpeechInputLength,
+			Formats:        []string{"mp3", "pcm", "flac"},
+		}
+	}
+	return nil
+}
+
+func (a *TaskAdaptor) ValidateRequestAndSetAction(c *gin.Context, info *relaycommon.RelayInfo) *dto.TaskError {
+	return relaycommon.ValidateAudioGenerationRequest(c, info, a.GetAudioCapability)
+}
+
+func (a *TaskAdaptor) BuildRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	if info.Action == constant.TaskActionSpeech {
+		return fmt.Sprintf("%s%s", a.baseURL, SpeechAsyncEndpoint), nil
+	}
+	return fmt.Sprintf("%s%s", a.baseURL, MusicGenerationEndpoint), nil
+}
+
+func (a *TaskAdaptor) BuildRequestHeader(c *gin.Context, req *http.Request, info *relaycommon.RelayInfo) error {
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+a.apiKey)
+	return nil
+}
+
+func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayInfo) (io.Reader, error) {
+	req, err := relaycommon.GetAudioGenerationRequest(c)
+	if err != nil {
+		return nil, err
+	}
+	format := req.ResponseFormat
+	if format == "" {
+		format = DefaultAudioFormat
+	}
+	var payload any
+	if info.Action == constant.TaskActionSpeech {
+		voice := req.Voice
+		if voice == "" {
+			voice = DefaultVoice
+		}
+		payload = SpeechRequest{
+			Model:        info.UpstreamModelName,
+			Text:         req.Input,
+			VoiceSetting: VoiceSetting{VoiceID: voice},
+			AudioSetting: AudioSetting{
+				SampleRate: DefaultSpeechSampleRate,
+				Bitrate:    DefaultSpeechBitrate,
+				Format:     format,
+				Channel:    1,
+			},
+		}
+	} else {
+		prompt := req.Prompt
+		if req.Style != "" {
+			prompt = strings.TrimSpace(req.Style + ", " + prompt)
+		}
+		lyrics := req.Lyrics
+		if req.Instrumental {
+			// 纯音乐：以仅含段落标记的歌词生成
+			lyrics = "[Instrumental]"
+		}
+		payload = MusicRequest{
+			Model:  info.UpstreamModelName,
+			Prompt: strings.Trim(prompt, ", "),
+			Lyrics: lyrics,
+			AudioSetting: AudioSetting{
+				SampleRate: DefaultMusicSampleRate,
+				Bitrate:    DefaultMusicBitrate,
+				Format:     format,
+			},
+			OutputFormat: "url",
+		}
+	}
+	data, err := req.BuildPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+func (a *TaskAdaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (*http.Response, error) {
+	return channel.DoTaskApiRequest(a, c, info, requestBody)
+}
+
+// DoResponse 只解析任务 ID，响应由网关统一返回；音乐生成同步完成，以 trace_id 作为任务 ID
+func (a *TaskAdaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (taskID string, taskData []byte, taskErr *dto.TaskError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		taskErr = service.TaskErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+		return
+	}
+	_ = resp.Body.Close()
+
+	if info.Action == constant.TaskActionSpeech {
+		var sResp SpeechResponse
+		if err := common.Unmarshal(responseBody, &sResp); err != nil {
+			taskErr = service.TaskErrorWrapper(errors.Wrapf(err, "body: %s", responseBody), "unmarshal_response_body_failed", http.StatusInternalServerError)
+			return
+		}
+		if sResp.BaseResp.StatusCode != hailuo.StatusSuccess {
+			taskErr = service.TaskErrorWrapper(fmt.Errorf("minimax api error: %s", sResp.BaseResp.StatusMsg), strconv.Itoa(sResp.BaseResp.StatusCode), http.StatusBadRequest)
+			return
+		}
+		return sResp.TaskID.String(), responseBody, nil
+	}
+
+	var mResp MusicResponse
+	if err := common.Unmarshal(responseBody, &mResp); err != nil {
+		taskErr = service.TaskErrorWrapper(errors.Wrapf(err, "body: %s", responseBody), "unmarshal_response_body_failed", http.StatusInternalServerError)
+		return
+	}
+	if mResp.BaseResp.StatusCode != hailuo.StatusSuccess {
+		taskErr = service.TaskErrorWrapper(fmt.Errorf("minimax api error: %s", mResp.BaseResp.StatusMsg), strconv.Itoa(mResp.BaseResp.StatusCode), http.StatusBadRequest)
+		return
+	}
+	if mResp.Data.Audio == "" {
+		taskErr = service.TaskErrorWrapper(fmt.Errorf("minimax returned no audio"), "empty_audio", http.StatusInternalServerError)
+		return
+	}
+	taskID = mResp.TraceID
+	if taskID == "" {
+		taskID = "minimax-" + common.GetUUID()
+	}
+	a.syncResult = &relaycommon.TaskInfo{
+		TaskID:   taskID,
+		Status:   model.TaskStatusSuccess,
+		Url:      mResp.Data.Audio,
+		Progress: "100%",
+		Duration: float64(mResp.ExtraInfo.MusicDuration) / 1000,
+	}
+	// 结果地址不保存在任务数据中，避免泄露上游临时链接
+	mResp.Data.Audio = ""
+	taskData, _ = common.Marshal(mResp)
+	return taskID, taskData, nil
+}
+
+func (a *TaskAdaptor) SyncTaskResult() *relaycommon.TaskInfo {
+	return a.syncResult
+}
+
+func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any, proxy string) (*http.Response, error) {
+	taskID, ok := body["task_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid task_id")
+	}
+	uri := fmt.Sprintf("%s%s?task_id=%s", baseUrl, SpeechQueryEndpoint, taskID)
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+
+	client, err := service.GetHttpClientWithProxy(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("new proxy http client failed: %w", err)
+	}
+	return client.Do(req)
+}
+
+func (a *TaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, error) {
+	var resTask SpeechQueryResponse
+	if err := common.Unmarshal(respBody, &resTask); err != nil {
+		return nil, errors.Wrap(err, "unmarshal task result failed")
+	}
+	if resTask.BaseResp.StatusCode != hailuo.StatusSuccess {
+		return relaycommon.FailTaskInfo(resTask.BaseResp.StatusMsg), nil
+	}
+
+	taskResult := &relaycommon.TaskInfo{TaskID: resTask.TaskID.String()}
+	switch resTask.Status {
+	case SpeechStatusSuccess:
+		taskResult.Status = model.TaskStatusSuccess
+		taskResult.Progress = "100%"
+		taskResult.Url = a.retrieveFileURL(resTask.FileID.String())
+		if taskResult.Url == "" {
+			return nil, fmt.Errorf("retrieve file %s failed", resTask.FileID.String())
+		}
+	case SpeechStatusFailed:
+		return relaycommon.FailTaskInfo("task failed"), nil
+	case SpeechStatusExpired:
+		return relaycommon.FailTaskInfo("task expired"), nil
+	default:
+		taskResult.Status = model.TaskStatusInProgress
+		taskResult.Progress = "50%"
+	}
+	return taskResult, nil
+}
+
+// retrieveFileURL 通过文件接口获取下载地址
+func (a *TaskAdaptor) retrieveFileURL(fileID string) string {
+	if a.apiKey == "" || a.baseURL == "" || fileID == "" {
+		return ""
+	}
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s%s?file_id=%s", a.baseURL, FileRetrieveEndpoint, fileID), nil)
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+a.apiKey)
+
+	resp, err := service.GetHttpClient().Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ""
+	}
+	var retrieveResp hailuo.RetrieveFileResponse
+	if err := common.Unmarshal(responseBody, &retrieveResp); err != nil {
+		return ""
+	}
+	if retrieveResp.BaseResp.StatusCode != hailuo.StatusSuccess {
+		return ""
+	}
+	return retrieveResp.File.DownloadURL
+}
+
+func (a *TaskAdaptor) GetModelList() []string {
+	return ModelList
+}
+
+func (a *TaskAdaptor) GetChannelName() string {
+	return ChannelName
+}
@@ -0,0 +1,41 @@
+package minimax
+
+const (
+	ChannelName = "minimax-audio"
+)
+
+var ModelList = []string{
+	"music-2.0",
+	"music-1.5",
+	"speech-2.6-hd",
+	"speech-2.6-turbo",
+	"speech-2.5-hd-preview",
+	"speech-2.5-turbo-preview",
+	"speech-02-hd",
+	"speech-02-turbo",
+}
+
+const (
+	MusicGenerationEndpoint = "/v1/music_generation"
+	SpeechAsyncEndpoint     = "/v1/t2a_async_v2"
+	SpeechQueryEndpoint     = "/v1/query/t2a_async_query_v2"
+	FileRetrieveEndpoint    = "/v1/files/retrieve"
+)
+
+const (
+	SpeechStatusProcessing = "Processing"
+	SpeechStatusSuccess    = "Success"
+	SpeechStatusFailed     = "Failed"
+	SpeechStatusExpired    = "Expired"
+)
+
+const (
+	DefaultVoice            = "male-qn-qingse"
+	DefaultAudioFormat      = "mp3"
+	DefaultSongDuration     = 60
+	MaxSpeechInputLength    = 50000
+	DefaultMusicSampleRate  = 44100
+	DefaultMusicBitrate     = 256000
+	DefaultSpeechSampleRate = 32000
+	DefaultSpeechBitrate    = 128000
+)
@@ -0,0 +1,60 @@
+package minimax
+
+import (
+	"encoding/json"
+
+	"github.com/QuantumNous/new-api/relay/channel/task/hailuo"
+)
+
+type AudioSetting struct {
+	SampleRate int    `json:"sample_rate,omitempty"`
+	Bitrate    int    `json:"bitrate,omitempty"`
+	Format     string `json:"format,omitempty"`
+	Channel    int    `json:"channel,omitempty"`
+}
+
+type MusicRequest struct {
+	Model        string       `json:"model"`
+	Prompt       string       `json:"prompt,omitempty"`
+	Lyrics       string       `json:"lyrics,omitempty"`
+	AudioSetting AudioSetting `json:"audio_setting"`
+	OutputFormat string       `json:"output_format"`
+}
+
+type MusicResponse struct {
+	Data struct {
+		Audio  string `json:"audio"`
+		Status int    `json:"status"`
+	} `json:"data"`
+	ExtraInfo struct {
+		MusicDuration int64 `json:"music_duration"` // 毫秒
+	} `json:"extra_info"`
+	TraceID  string          `json:"trace_id"`
+	BaseResp hailuo.BaseResp `json:"base_resp"`
+}
+
+type VoiceSetting struct {
+	VoiceID string  `json:"voice_id"`
+	Speed   float64 `json:"speed,omitempty"`
+}
+
+type SpeechRequest struct {
+	Model        string       `json:"model"`
+	Text         string       `json:"text"`
+	VoiceSetting VoiceSetting `json:"voice_setting"`
+	AudioSetting AudioSetting `json:"audio_setting"`
+}
+
+type SpeechResponse struct {
+	TaskID          json.Number     `json:"task_id"`
+	FileID          json.Number     `json:"file_id"`
+	UsageCharacters int             `json:"usage_characters"`
+	BaseResp        hailuo.BaseResp `json:"base_resp"`
+}
+
+type SpeechQueryResponse struct {
+	TaskID   json.Number     `json:"task_id"`
+	Status   string          `json:"status"`
+	FileID   json.Number     `json:"file_id"`
+	BaseResp hailuo.BaseResp `json:"base_resp"`
+}
@@ -134,7 +134,7 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayIn
 	}
 
 	// 如果是 JSON 格式，需要转换为 multipart/form-data
-	// 从原始 JSON 请求体中解析所有字段（包括 aspect_ratio 等）
+	// 从原始 JSON 请求体中解析所有字段
 	cachedBody, err := common.GetRequestBody(c)
 	if err != nil {
 		return nil, errors.Wrap(err, "get_request_body_failed")
@@ -144,15 +144,32 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayIn
 	if err := common.Unmarshal(cachedBody, &jsonReq); err != nil {
 		return nil, errors.Wrap(err, "unmarshal_json_request_failed")
 	}
+	// 标准字段 duration、resolution、aspect_ratio 转换为 Sora 的 seconds 与 size
+	if req, err := relaycommon.GetTaskRequest(c); err == nil {
+		if _, ok := jsonReq["seconds"]; !ok && req.Duration > 0 {
+			jsonReq["seconds"] = fmt.Sprintf("%d", req.Duration)
+		}
+		if _, ok := jsonReq["size"]; !ok && req.Size != "" {
+			jsonReq["size"] = req.Size
+		}
+	}
 
 	// 创建 multipart/form-data 请求体
 	var requestBody bytes.Buffer
 	writer := multipart.NewWriter(&requestBody)
 
-	// 写入所有字段（包括 model, prompt, seconds, size, mode, aspect_ratio 等）
+	// 写入所有字段（包括 model, prompt, seconds, size, mode 等）
 	// 排除需要特殊处理的字段
 	skipFields := map[string]bool{
 		"input_reference": true, // 文件字段，需要特殊处理
+		"callback_url":    true, // 网关回调地址，不透传上游
+		"duration":        true, // 以下为统一视频请求的标准字段，已转换或 Sora 不支持
+		"resolution":      true,
+		"aspect_ratio":    true,
+		"images":          true,
+		"image":           true,
+		"seed":            true,
+		"audio":           true,
 	}
 
 	for key, value := range jsonReq {
@@ -280,6 +297,37 @@ func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any, proxy
 	return client.Do(req)
 }
 
+// CancelTask 删除未完成的上游视频任务，上游不计费，全额退还
+func (a *TaskAdaptor) CancelTask(baseUrl, key string, task *model.Task, proxy string) (float64, error) {
+	uri := fmt.Sprintf("%s/v1/videos/%s", baseUrl, task.TaskID)
+	req, err := http.NewRequest(http.MethodDelete, uri, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	if err := channel.DoTaskCancelRequest(req, proxy); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// GetVideoCapability Sora 支持 4/8/12 秒，sora-2 仅 720p，sora-2-pro 另支持 1792x1024（按 1080p 档位），音频随视频生成
+func (a *TaskAdaptor) GetVideoCapability(modelName string) *relaycommon.VideoCapability {
+	capability := &relaycommon.VideoCapability{
+		Durations:         []int{4, 8, 12},
+		Resolutions:       []string{"720p"},
+		AspectRatios:      []string{"16:9", "9:16"},
+		MaxImages:         1,
+		Audio:             true,
+		DefaultDuration:   4,
+		DefaultResolution: "720p",
+	}
+	if modelName == "sora-2-pro" {
+		capability.Resolutions = []string{"720p", "1080p"}
+	}
+	return capability
+}
+
 func (a *TaskAdaptor) GetModelList() []string {
 	return ModelList
 }
@@ -0,0 +1,159 @@
+package suno
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AudioTaskAdaptor 统一音频生成接口（/v1/audio/generations）的 Suno 适配器，
+// 提交到 /suno/submit/music，任务进入通用轮询，结果取第一首歌曲
+type AudioTaskAdaptor struct {
+	TaskAdaptor
+}
+
+// defaultSongDuration Suno 不支持指定时长，预扣费按常见歌曲时长估算，完成后按实际时长结算
+const defaultSongDuration = 120
+
+func (a *AudioTaskAdaptor) GetAudioCapability(modelName string) *relaycommon.AudioCapability {
+	return &relaycommon.AudioCapability{
+		Types:           []string{constant.TaskActionSong},
+		DefaultDuration: defaultSongDuration,
+	}
+}
+
+func (a *AudioTaskAdaptor) ValidateRequestAndSetAction(c *gin.Context, info *relaycommon.RelayInfo) *dto.TaskError {
+	return relaycommon.ValidateAudioGenerationRequest(c, info, a.GetAudioCapability)
+}
+
+func (a *AudioTaskAdaptor) BuildRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	return fmt.Sprintf("%s/suno/submit/%s", info.ChannelBaseUrl, strings.ToLower(constant.SunoActionMusic)), nil
+}
+
+func (a *AudioTaskAdaptor) BuildRequestHeader(c *gin.Context, req *http.Request, info *relaycommon.RelayInfo) error {
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+info.ApiKey)
+	return nil
+}
+
+func (a *AudioTaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayInfo) (io.Reader, error) {
+	req, err := relaycommon.GetAudioGenerationRequest(c)
+	if err != nil {
+		return nil, err
+	}
+	sunoRequest := dto.SunoSubmitReq{
+		Title:            req.Title,
+		Tags:             req.Style,
+		MakeInstrumental: req.Instrumental,
+		Mv:               "chirp-v3-0",
+	}
+	// 模型名即 Suno 版本时直接透传，如 chirp-v4
+	if strings.HasPrefix(info.UpstreamModelName, "chirp-") {
+		sunoRequest.Mv = info.UpstreamModelName
+	}
+	if req.Lyrics != "" {
+		// 自定义模式：prompt 为歌词
+		sunoRequest.Prompt = req.Lyrics
+	} else {
+		// 灵感模式：按描述生成歌词与曲风
+		sunoRequest.GptDescriptionPrompt = req.Prompt
+	}
+	data, err := req.BuildPayload(sunoRequest)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+func (a *AudioTaskAdaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (*http.Response, error) {
+	return channel.DoTaskApiRequest(a, c, info, requestBody)
+}
+
+// DoResponse 只解析任务 ID，响应由网关统一返回
+func (a *AudioTaskAdaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (taskID string, taskData []byte, taskErr *dto.TaskError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		taskErr = service.TaskErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+		return
+	}
+	_ = resp.Body.Close()
+	var sunoResponse dto.TaskResponse[string]
+	if err = json.Unmarshal(responseBody, &sunoResponse); err != nil {
+		taskErr = service.TaskErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+		return
+	}
+	if !sunoResponse.IsSuccess() || sunoResponse.Data == "" {
+		taskErr = service.TaskErrorWrapper(fmt.Errorf("%s", sunoResponse.Message), sunoResponse.Code, http.StatusInternalServerError)
+		return
+	}
+	return sunoResponse.Data, nil, nil
+}
+
+// FetchTask 按 ID 批量查询接口查询单个任务，响应 data 为数组
+func (a *AudioTaskAdaptor) FetchTask(baseUrl, key string, body map[string]any, proxy string) (*http.Response, error) {
+	taskID, ok := body["task_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid task_id")
+	}
+	return a.TaskAdaptor.FetchTask(baseUrl, key, map[string]any{
+		"ids": []string{taskID},
+	}, proxy)
+}
+
+func (a *AudioTaskAdaptor) ParseTaskResult(respBody []byte) (*relaycommon.TaskInfo, error) {
+	var sunoResponse dto.TaskResponse[[]dto.SunoDataResponse]
+	if err := common.Unmarshal(respBody, &sunoResponse); err != nil {
+		return nil, fmt.Errorf("unmarshal suno task result failed: %w", err)
+	}
+	if !sunoResponse.IsSuccess() {
+		return nil, fmt.Errorf("suno fetch task failed: %s", sunoResponse.Message)
+	}
+	if len(sunoResponse.Data) == 0 {
+		return nil, fmt.Errorf("suno task not found")
+	}
+	item := sunoResponse.Data[0]
+	taskResult := &relaycommon.TaskInfo{
+		TaskID: item.TaskID,
+		Status: item.Status,
+		Reason: item.FailReason,
+	}
+	switch model.TaskStatus(item.Status) {
+	case model.TaskStatusSuccess:
+		var songs []dto.SunoSong
+		_ = common.Unmarshal(item.Data, &songs)
+		for _, song := range songs {
+			if song.AudioURL == "" {
+				continue
+			}
+			taskResult.Url = song.AudioURL
+			if duration, ok := song.Metadata.Duration.(float64); ok {
+				taskResult.Duration = duration
+			}
+			break
+		}
+		if taskResult.Url == "" {
+			return relaycommon.FailTaskInfo("suno returned no audio"), nil
+		}
+	case model.TaskStatusFailure:
+		if taskResult.Reason == "" {
+			taskResult.Reason = "task failed"
+		}
+	case model.TaskStatusNotStart:
+		taskResult.Status = model.TaskStatusSubmitted
+	}
+	return taskResult, nil
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/relay/channel"
+	taskgemini "github.com/QuantumNous/new-api/relay/channel/task/gemini"
 	vertexcore "github.com/QuantumNous/new-api/relay/channel/vertex"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/service"
@@ -145,6 +146,31 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayIn
 		Instances:  []map[string]any{{"prompt": req.Prompt}},
 		Parameters: map[string]any{},
 	}
+	if len(req.Images) > 0 {
+		mimeType, data, err := service.GetImageBase64Data(req.Images[0])
+		if err != nil {
+			return nil, fmt.Errorf("load image failed: %w", err)
+		}
+		body.Instances[0]["image"] = map[string]any{
+			"bytesBase64Encoded": data,
+			"mimeType":           mimeType,
+		}
+	}
+	if req.AspectRatio != "" {
+		body.Parameters["aspectRatio"] = req.AspectRatio
+	}
+	if req.Resolution != "" {
+		body.Parameters["resolution"] = req.Resolution
+	}
+	if req.Duration > 0 {
+		body.Parameters["durationSeconds"] = req.Duration
+	}
+	if req.Seed != nil {
+		body.Parameters["seed"] = *req.Seed
+	}
+	if req.Audio != nil {
+		body.Parameters["generateAudio"] = *req.Audio
+	}
 	if req.Metadata != nil {
 		if v, ok := req.Metadata["storageUri"]; ok {
 			body.Parameters["storageUri"] = v
@@ -166,23 +192,10 @@ func (a *TaskAdaptor) BuildRequestBody(c *gin.Context, info *relaycommon.RelayIn
 		return nil, fmt.Errorf("sampleCount must be greater than 0")
 	}
 
-	// if req.Duration > 0 {
-	// 	body.Parameters["durationSeconds"] = req.Duration
-	// } else if req.Seconds != "" {
-	// 	seconds, err := strconv.Atoi(req.Seconds)
-	// 	if err != nil {
-	// 		return nil, errors.Wrap(err, "convert seconds to int failed")
-	// 	}
-	// 	body.Parameters["durationSeconds"] = seconds
-	// }
-
-	info.PriceData.OtherRatios = map[string]float64{
-		"sampleCount": float64(body.Parameters["sampleCount"].(int)),
+	if info.PriceData.OtherRatios == nil {
+		info.PriceData.OtherRatios = map[string]float64{}
 	}
-
-	// if v, ok := body.Parameters["durationSeconds"]; ok {
-	// 	info.PriceData.OtherRatios["durationSeconds"] = float64(v.(int))
-	// }
+	info.PriceData.OtherRatios["sampleCount"] = float64(body.Parameters["sampleCount"].(int))
 
 	data, err := json.Marshal(body)
 	if err != nil {
@@ -216,6 +229,11 @@ func (a *TaskAdaptor) DoResponse(c *gin.Context, resp *http.Response, info *rela
 	return localID, responseBody, nil
 }
 
+// GetVideoCapability Vertex 上的 Veo 与 Gemini API 一致，另支持 seed
+func (a *TaskAdaptor) GetVideoCapability(modelName string) *relaycommon.VideoCapability {
+	return taskgemini.GetVeoCapability(modelName, true)
+}
+
 func (a *TaskAdaptor) GetModelList() []string { return []string{"veo-3.0-generate-001"} }
 func (a *TaskAdaptor) GetChannelName() string { return "vertex" }
 
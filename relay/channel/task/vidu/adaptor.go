@@ -33,6 +33,7 @@ type requestPayload struct {
 	Duration          int      `json:"duration,omitempty"`
 	Seed              int      `json:"seed,omitempty"`
 	Resolution        string   `json:"resolution,omitempty"`
+	AspectRatio       string   `json:"aspect_ratio,omitempty"`
 	MovementAmplitude string   `json:"movement_amplitude,omitempty"`
 	Bgm               bool     `json:"bgm,omitempty"`
 	Payload           string   `json:"payload,omitempty"`
@@ -211,6 +212,22 @@ func (a *TaskAdaptor) FetchTask(baseUrl, key string, body map[string]any, proxy
 	return client.Do(req)
 }
 
+// CancelTask 取消排队中的任务，上游取消成功后积分全额返还
+func (a *TaskAdaptor) CancelTask(baseUrl, key string, task *model.Task, proxy string) (float64, error) {
+	body, _ := json.Marshal(map[string]string{"id": task.TaskID})
+	url := fmt.Sprintf("%s/ent/v2/tasks/%s/cancel", baseUrl, task.TaskID)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Token "+key)
+	if err := channel.DoTaskCancelRequest(req, proxy); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
 func (a *TaskAdaptor) GetModelList() []string {
 	return []string{"viduq2", "viduq1", "vidu2.0", "vidu1.5"}
 }
@@ -223,15 +240,53 @@ func (a *TaskAdaptor) GetChannelName() string {
 // helpers
 // ============================
 
+// GetVideoCapability 各代模型的时长与分辨率不同，参考图生视频最多 7 张图，bgm 对应 audio
+func (a *TaskAdaptor) GetVideoCapability(modelName string) *relaycommon.VideoCapability {
+	capability := &relaycommon.VideoCapability{
+		Durations:         []int{5},
+		Resolutions:       []string{"1080p"},
+		AspectRatios:      []string{"16:9", "9:16", "1:1"},
+		MaxImages:         7,
+		Seed:              true,
+		Audio:             true,
+		DefaultDuration:   5,
+		DefaultResolution: "1080p",
+	}
+	switch {
+	case strings.HasPrefix(modelName, "viduq2"):
+		capability.Durations = nil
+		capability.MinDuration = 1
+		capability.MaxDuration = 10
+		capability.Resolutions = []string{"720p", "1080p"}
+		capability.DefaultResolution = "720p"
+	case strings.HasPrefix(modelName, "vidu2.0"):
+		capability.Durations = []int{4, 8}
+		capability.Resolutions = []string{"360p", "720p"}
+		capability.DefaultDuration = 4
+		capability.DefaultResolution = "360p"
+	case strings.HasPrefix(modelName, "vidu1.5"):
+		capability.Durations = []int{4, 8}
+		capability.Resolutions = []string{"360p", "720p", "1080p"}
+		capability.DefaultDuration = 4
+		capability.DefaultResolution = "360p"
+	}
+	return capability
+}
+
 func (a *TaskAdaptor) convertToRequestPayload(req *relaycommon.TaskSubmitReq) (*requestPayload, error) {
+	capability := a.GetVideoCapability(defaultString(req.Model, "viduq1"))
 	r := requestPayload{
 		Model:             defaultString(req.Model, "viduq1"),
 		Images:            req.Images,
 		Prompt:            req.Prompt,
-		Duration:          defaultInt(req.Duration, 5),
-		Resolution:        defaultString(req.Size, "1080p"),
+		Duration:          defaultInt(req.Duration, capability.DefaultDuration),
+		Resolution:        defaultString(req.Resolution, capability.DefaultResolution),
+		AspectRatio:       req.AspectRatio,
 		MovementAmplitude: "auto",
-		Bgm:               false,
+		Bgm:               req.Audio != nil && *req.Audio,
+	}
+	if req.Seed != nil {
+		r.Seed = int(*req.Seed)
 	}
 	metadata := req.Metadata
 	medaBytes, err := json.Marshal(metadata)
@@ -0,0 +1,186 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+// AudioGenerationRequest 统一音频生成请求（/v1/audio/generations），覆盖歌曲、音效与长文本语音合成:
+//
+//	type             song、sound_effect、speech，未传时取模型默认类型
+//	prompt           歌曲或音效描述
+//	lyrics           歌词，仅歌曲
+//	style            曲风标签，仅歌曲
+//	title            歌曲标题
+//	instrumental     是否纯音乐
+//	input            语音合成文本，仅语音
+//	voice            音色，仅语音
+//	duration         时长（秒）
+//	response_format  输出格式，如 mp3、wav
+//
+// 各音频 TaskAdaptor 负责将标准字段映射为上游参数，metadata 中的字段仍会原样覆盖到上游请求
+type AudioGenerationRequest struct {
+	Model          string         `json:"model"`
+	Type           string         `json:"type,omitempty"`
+	Prompt         string         `json:"prompt,omitempty"`
+	Lyrics         string         `json:"lyrics,omitempty"`
+	Style          string         `json:"style,omitempty"`
+	Title          string         `json:"title,omitempty"`
+	Instrumental   bool           `json:"instrumental,omitempty"`
+	Input          string         `json:"input,omitempty"`
+	Voice          string         `json:"voice,omitempty"`
+	Duration       float64        `json:"duration,omitempty"`
+	ResponseFormat string         `json:"response_format,omitempty"`
+	Metadata       map[string]any `json:"metadata,omitempty"`
+}
+
+// AudioCapability 音频模型支持的参数，用于提交前校验请求与计费默认值
+type AudioCapability struct {
+	Types           []string // 支持的生成类型，第一个为默认类型
+	Duration        bool     // 是否支持指定时长
+	MinDuration     float64  // 时长下限（秒），0 表示不限
+	MaxDuration     float64  // 时长上限（秒），0 表示不限
+	DefaultDuration float64  // 未传时长时上游的默认时长，用于预扣费
+	MaxInputLength  int      // 语音合成文本长度上限（字符），0 表示不限
+	Formats         []string // 支持的输出格式，为空表示不限制
+}
+
+// Validate 校验请求中的标准字段是否被模型支持，未传类型时补全为默认类型
+func (a *AudioCapability) Validate(req *AudioGenerationRequest) error {
+	if req.Type == "" && len(a.Types) > 0 {
+		req.Type = a.Types[0]
+	}
+	if !lo.Contains(a.Types, req.Type) {
+		return fmt.Errorf("type %s is not supported by model %s, supported: %s", req.Type, req.Model, strings.Join(a.Types, ", "))
+	}
+	switch req.Type {
+	case constant.TaskActionSong:
+		if req.Prompt == "" && req.Lyrics == "" {
+			return fmt.Errorf("prompt or lyrics is required")
+		}
+	case constant.TaskActionSoundEffect:
+		if req.Prompt == "" {
+			return fmt.Errorf("prompt is required")
+		}
+	case constant.TaskActionSpeech:
+		if req.Input == "" {
+			return fmt.Errorf("input is required")
+		}
+		if a.MaxInputLength > 0 && utf8.RuneCountInString(req.Input) > a.MaxInputLength {
+			return fmt.Errorf("input exceeds %d characters", a.MaxInputLength)
+		}
+	}
+	if req.Duration < 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+	if req.Duration > 0 && !a.Duration {
+		return fmt.Errorf("model %s does not support duration", req.Model)
+	}
+	if req.Duration > 0 {
+		if (a.MinDuration > 0 && req.Duration < a.MinDuration) || (a.MaxDuration > 0 && req.Duration > a.MaxDuration) {
+			return fmt.Errorf("duration %g is not supported by model %s, supported: %g-%g", req.Duration, req.Model, a.MinDuration, a.MaxDuration)
+		}
+	}
+	if req.ResponseFormat != "" && len(a.Formats) > 0 && !lo.Contains(a.Formats, req.ResponseFormat) {
+		return fmt.Errorf("response_format %s is not supported by model %s, supported: %s", req.ResponseFormat, req.Model, strings.Join(a.Formats, ", "))
+	}
+	return nil
+}
+
+// BillingDuration 预扣费使用的时长：优先取请求时长，语音合成按文本长度估算，否则取模型默认值
+func (a *AudioCapability) BillingDuration(req *AudioGenerationRequest) float64 {
+	if req.Duration > 0 {
+		return req.Duration
+	}
+	if req.Type == constant.TaskActionSpeech && req.Input != "" {
+		return EstimateSpeechDuration(req.Input)
+	}
+	if a != nil {
+		return a.DefaultDuration
+	}
+	return 0
+}
+
+// EstimateSpeechDuration 按常见语速估算朗读时长：中日韩文字约每秒 4 字，其余约每秒 15 个字符
+func EstimateSpeechDuration(input string) float64 {
+	var cjk, others int
+	for _, r := range input {
+		switch {
+		case unicode.Is(unicode.Han, r), unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r), unicode.Is(unicode.Hangul, r):
+			cjk++
+		case unicode.IsSpace(r):
+		default:
+			others++
+		}
+	}
+	seconds := float64(cjk)/4 + float64(others)/15
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// ValidateAudioGenerationRequest 解析并校验统一音频请求，capability 返回 nil 表示渠道不支持该模型
+func ValidateAudioGenerationRequest(c *gin.Context, info *RelayInfo, capability func(modelName string) *AudioCapability) *dto.TaskError {
+	var req AudioGenerationRequest
+	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
+		return createTaskError(err, "invalid_request", http.StatusBadRequest, true)
+	}
+	req.Model = strings.TrimSpace(req.Model)
+	if req.Model == "" {
+		req.Model = info.OriginModelName
+	}
+	req.Type = strings.ToLower(strings.TrimSpace(req.Type))
+	req.Prompt = strings.TrimSpace(req.Prompt)
+	req.Input = strings.TrimSpace(req.Input)
+	req.ResponseFormat = strings.ToLower(strings.TrimSpace(req.ResponseFormat))
+
+	audioCapability := capability(req.Model)
+	if audioCapability == nil {
+		return createTaskError(fmt.Errorf("model %s does not support audio generation", req.Model), "unsupported_audio_model", http.StatusBadRequest, true)
+	}
+	if err := audioCapability.Validate(&req); err != nil {
+		return createTaskError(err, "unsupported_audio_option", http.StatusBadRequest, true)
+	}
+	info.Action = req.Type
+	c.Set("audio_task_request", req)
+	return nil
+}
+
+func GetAudioGenerationRequest(c *gin.Context) (AudioGenerationRequest, error) {
+	v, exists := c.Get("audio_task_request")
+	if !exists {
+		return AudioGenerationRequest{}, fmt.Errorf("request not found in context")
+	}
+	req, ok := v.(AudioGenerationRequest)
+	if !ok {
+		return AudioGenerationRequest{}, fmt.Errorf("invalid audio request type")
+	}
+	return req, nil
+}
+
+// BuildPayload 序列化上游请求体，metadata 中的字段覆盖同名字段
+func (r *AudioGenerationRequest) BuildPayload(payload any) ([]byte, error) {
+	data, err := common.Marshal(payload)
+	if err != nil || len(r.Metadata) == 0 {
+		return data, err
+	}
+	var merged map[string]any
+	if err := common.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	for key, value := range r.Metadata {
+		merged[key] = value
+	}
+	return common.Marshal(merged)
+}
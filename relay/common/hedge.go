@@ -0,0 +1,110 @@
+package common
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	HedgeRolePrimary = "primary"
+	HedgeRoleHedge   = "hedge"
+)
+
+// HedgeRace 对冲请求的竞速状态：多个尝试中第一个产出首个 token（或完整响应）的一方胜出，其余尝试被取消
+type HedgeRace struct {
+	mu       sync.Mutex
+	winner   *HedgeAttempt
+	attempts []*HedgeAttempt
+	claimed  chan struct{}
+}
+
+// HedgeAttempt 对冲竞速中的单个尝试，随 RelayInfo 传递给中继流程
+type HedgeAttempt struct {
+	race   *HedgeRace
+	Role   string
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewHedgeRace() *HedgeRace {
+	return &HedgeRace{claimed: make(chan struct{})}
+}
+
+// NewAttempt 创建一个尝试，parent 取消时（如客户端断开）尝试同样被取消
+func (r *HedgeRace) NewAttempt(parent context.Context, role string) *HedgeAttempt {
+	ctx, cancel := context.WithCancel(parent)
+	attempt := &HedgeAttempt{race: r, Role: role, ctx: ctx, cancel: cancel}
+	r.mu.Lock()
+	r.attempts = append(r.attempts, attempt)
+	r.mu.Unlock()
+	return attempt
+}
+
+// Claimed 有尝试胜出后关闭
+func (r *HedgeRace) Claimed() <-chan struct{} {
+	return r.claimed
+}
+
+func (r *HedgeRace) Winner() *HedgeAttempt {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.winner
+}
+
+// Context 尝试的上游请求上下文，落败时被取消
+func (a *HedgeAttempt) Context() context.Context {
+	return a.ctx
+}
+
+// Claim 尝试输出响应前调用，第一个调用的尝试胜出并取消其余尝试；返回当前尝试是否为胜者
+func (a *HedgeAttempt) Claim() bool {
+	if a == nil {
+		return true
+	}
+	r := a.race
+	r.mu.Lock()
+	if r.winner != nil {
+		won := r.winner == a
+		r.mu.Unlock()
+		return won
+	}
+	r.winner = a
+	losers := make([]*HedgeAttempt, 0, len(r.attempts))
+	for _, attempt := range r.attempts {
+		if attempt != a {
+			losers = append(losers, attempt)
+		}
+	}
+	close(r.claimed)
+	r.mu.Unlock()
+	for _, loser := range losers {
+		loser.cancel()
+	}
+	return true
+}
+
+// Lost 其他尝试已胜出，落败的尝试不再输出响应也不按用量计费
+func (a *HedgeAttempt) Lost() bool {
+	if a == nil {
+		return false
+	}
+	winner := a.race.Winner()
+	return winner != nil && winner != a
+}
+
+// Fired 是否实际发起了对冲请求
+func (a *HedgeAttempt) Fired() bool {
+	if a == nil {
+		return false
+	}
+	a.race.mu.Lock()
+	defer a.race.mu.Unlock()
+	return len(a.race.attempts) > 1
+}
+
+// Release 尝试结束后释放上下文
+func (a *HedgeAttempt) Release() {
+	if a != nil {
+		a.cancel()
+	}
+}
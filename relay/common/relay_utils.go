@@ -99,6 +99,27 @@ func validateMultipartTaskRequest(c *gin.Context, info *RelayInfo, action string
 			req.Duration = duration
 		}
 	}
+	if durationStr := formData.Get("duration"); durationStr != "" {
+		if duration, err := strconv.Atoi(durationStr); err == nil {
+			req.Duration = duration
+		}
+	}
+	req.Resolution = formData.Get("resolution")
+	req.AspectRatio = formData.Get("aspect_ratio")
+	if seedStr := formData.Get("seed"); seedStr != "" {
+		seed, err := strconv.ParseInt(seedStr, 10, 64)
+		if err != nil {
+			return req, fmt.Errorf("invalid seed: %s", seedStr)
+		}
+		req.Seed = &seed
+	}
+	if audioStr := formData.Get("audio"); audioStr != "" {
+		audio, err := strconv.ParseBool(audioStr)
+		if err != nil {
+			return req, fmt.Errorf("invalid audio: %s", audioStr)
+		}
+		req.Audio = &audio
+	}
 
 	if images := formData["images"]; len(images) > 0 {
 		req.Images = images
@@ -133,13 +154,11 @@ func ValidateMultipartDirect(c *gin.Context, info *RelayInfo) *dto.TaskError {
 	prompt = req.Prompt
 	model = req.Model
 	size = req.Size
-	seconds, _ = strconv.Atoi(req.Seconds)
-	if seconds == 0 {
-		seconds = req.Duration
-	}
 	if req.InputReference != "" {
 		req.Images = []string{req.InputReference}
 	}
+	req.NormalizeVideoRequest()
+	seconds = req.Duration
 
 	if strings.TrimSpace(req.Model) == "" {
 		return createTaskError(fmt.Errorf("model field is required"), "missing_model", http.StatusBadRequest, true)
@@ -160,7 +179,7 @@ func ValidateMultipartDirect(c *gin.Context, info *RelayInfo) *dto.TaskError {
 	if strings.HasPrefix(model, "sora-2") {
 
 		if size == "" {
-			size = "720x1280"
+			size = soraSizeFromResolution(req.Resolution, req.AspectRatio)
 		}
 
 		if seconds <= 0 {
@@ -182,11 +201,28 @@ func ValidateMultipartDirect(c *gin.Context, info *RelayInfo) *dto.TaskError {
 		}
 	}
 
-	info.Action = action
+	req.Size = size
+	req.Duration = seconds
+	if req.Resolution == "" {
+		req.Resolution = ResolutionFromSize(size, nil)
+	}
+	if req.AspectRatio == "" {
+		req.AspectRatio = AspectRatioFromSize(size)
+	}
+	storeTaskRequest(c, info, action, req)
 
 	return nil
 }
 
+// soraSizeFromResolution 将标准字段中的分辨率、宽高比转换为 Sora 的 size，1080p 对应 sora-2-pro 的 1792x1024
+func soraSizeFromResolution(resolution string, aspectRatio string) string {
+	portrait := aspectRatio == "" || aspectRatio == "9:16"
+	if resolution == "1080p" {
+		return lo.Ternary(portrait, "1024x1792", "1792x1024")
+	}
+	return lo.Ternary(portrait, "720x1280", "1280x720")
+}
+
 func isKnownTaskField(field string) bool {
 	knownFields := map[string]bool{
 		"prompt":          true,
@@ -196,6 +232,11 @@ func isKnownTaskField(field string) bool {
 		"images":          true,
 		"size":            true,
 		"duration":        true,
+		"seconds":         true,
+		"resolution":      true,
+		"aspect_ratio":    true,
+		"seed":            true,
+		"audio":           true,
 		"input_reference": true, // Sora 特有字段
 	}
 	return knownFields[field]
@@ -218,10 +259,8 @@ func ValidateBasicTaskRequest(c *gin.Context, info *RelayInfo, action string) *d
 		return taskErr
 	}
 
-	if len(req.Images) == 0 && strings.TrimSpace(req.Image) != "" {
-		// 兼容单图上传
-		req.Images = []string{req.Image}
-	}
+	// 兼容单图上传、seconds 等写法，并由 size 推导分辨率和宽高比
+	req.NormalizeVideoRequest()
 
 	storeTaskRequest(c, info, action, req)
 	return nil
@@ -0,0 +1,273 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// 统一视频请求（/v1/videos、/v1/video/generations）的标准字段:
+//
+//	prompt        提示词
+//	images        参考图（首帧、尾帧或参考图），兼容 image、input_reference
+//	duration      时长（秒），兼容 seconds
+//	resolution    分辨率，如 480p、720p、1080p，未传时由 size 推导
+//	aspect_ratio  宽高比，如 16:9、9:16、1:1，未传时由 size 推导
+//	seed          随机种子
+//	audio         是否生成音频
+//
+// 各视频 TaskAdaptor 负责将标准字段映射为上游参数，metadata 中的字段仍会原样覆盖到上游请求
+
+// standardVideoResolutions size 推导分辨率时的候选档位
+var standardVideoResolutions = []string{"360p", "480p", "720p", "1080p", "1440p", "2160p"}
+
+// standardAspectRatios size 推导宽高比时的候选比例
+var standardAspectRatios = []string{"16:9", "9:16", "1:1", "4:3", "3:4", "3:2", "2:3", "21:9", "9:21"}
+
+// NormalizeVideoRequest 统一兼容字段：image/input_reference 归入 images，seconds 归入 duration，并由 size 推导分辨率和宽高比
+func (t *TaskSubmitReq) NormalizeVideoRequest() {
+	if len(t.Images) == 0 {
+		if image := strings.TrimSpace(t.Image); image != "" {
+			t.Images = []string{image}
+		} else if reference := strings.TrimSpace(t.InputReference); reference != "" {
+			t.Images = []string{reference}
+		}
+	}
+	if t.Duration == 0 && t.Seconds != "" {
+		if seconds, err := strconv.Atoi(strings.TrimSpace(t.Seconds)); err == nil {
+			t.Duration = seconds
+		}
+	}
+	t.Resolution = NormalizeVideoResolution(t.Resolution)
+	t.AspectRatio = strings.TrimSpace(t.AspectRatio)
+
+	size := strings.TrimSpace(t.Size)
+	if size == "" {
+		return
+	}
+	if _, _, ok := ParseVideoSize(size); ok {
+		if t.Resolution == "" {
+			t.Resolution = ResolutionFromSize(size, nil)
+		}
+		if t.AspectRatio == "" {
+			t.AspectRatio = AspectRatioFromSize(size)
+		}
+		return
+	}
+	// size 也可能直接传分辨率或宽高比，如 720p、16:9
+	if strings.Contains(size, ":") {
+		if t.AspectRatio == "" {
+			t.AspectRatio = size
+		}
+	} else if t.Resolution == "" && strings.HasSuffix(strings.ToLower(size), "p") {
+		t.Resolution = NormalizeVideoResolution(size)
+	}
+}
+
+// NormalizeVideoResolution 分辨率统一为小写形式，如 1080P -> 1080p，720 -> 720p
+func NormalizeVideoResolution(resolution string) string {
+	resolution = strings.ToLower(strings.TrimSpace(resolution))
+	if resolution == "" {
+		return ""
+	}
+	if _, err := strconv.Atoi(resolution); err == nil {
+		return resolution + "p"
+	}
+	return resolution
+}
+
+// ParseVideoSize 解析 1280x720、1280*720 形式的尺寸
+func ParseVideoSize(size string) (width int, height int, ok bool) {
+	size = strings.ToLower(strings.TrimSpace(size))
+	parts := strings.FieldsFunc(size, func(r rune) bool {
+		return r == 'x' || r == '*'
+	})
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	width, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || width <= 0 {
+		return 0, 0, false
+	}
+	height, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil || height <= 0 {
+		return 0, 0, false
+	}
+	return width, height, true
+}
+
+// resolutionShortSide 返回分辨率档位的短边像素，如 720p -> 720
+func resolutionShortSide(resolution string) int {
+	side, err := strconv.Atoi(strings.TrimSuffix(NormalizeVideoResolution(resolution), "p"))
+	if err != nil {
+		return 0
+	}
+	return side
+}
+
+// ResolutionFromSize 按像素面积将尺寸归入最接近的分辨率档位，candidates 为空时使用常见档位
+func ResolutionFromSize(size string, candidates []string) string {
+	width, height, ok := ParseVideoSize(size)
+	if !ok {
+		return ""
+	}
+	if len(candidates) == 0 {
+		candidates = standardVideoResolutions
+	}
+	area := float64(width * height)
+	best := ""
+	bestDiff := math.MaxFloat64
+	for _, candidate := range candidates {
+		side := resolutionShortSide(candidate)
+		if side <= 0 {
+			continue
+		}
+		// 档位面积按 16:9 估算，比较对数差以避免偏向大档位
+		diff := math.Abs(math.Log(area / (float64(side) * float64(side) * 16 / 9)))
+		if diff < bestDiff {
+			best = NormalizeVideoResolution(candidate)
+			bestDiff = diff
+		}
+	}
+	return best
+}
+
+func parseAspectRatio(aspectRatio string) (float64, bool) {
+	parts := strings.Split(strings.TrimSpace(aspectRatio), ":")
+	if len(parts) != 2 {
+		return 0, false
+	}
+	w, err1 := strconv.ParseFloat(parts[0], 64)
+	h, err2 := strconv.ParseFloat(parts[1], 64)
+	if err1 != nil || err2 != nil || w <= 0 || h <= 0 {
+		return 0, false
+	}
+	return w / h, true
+}
+
+// AspectRatioFromSize 将尺寸归入最接近的常见宽高比，偏差过大时返回约分后的比例
+func AspectRatioFromSize(size string) string {
+	width, height, ok := ParseVideoSize(size)
+	if !ok {
+		return ""
+	}
+	ratio := float64(width) / float64(height)
+	for _, candidate := range standardAspectRatios {
+		if value, ok := parseAspectRatio(candidate); ok && math.Abs(ratio-value)/value < 0.05 {
+			return candidate
+		}
+	}
+	a, b := width, height
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return fmt.Sprintf("%d:%d", width/a, height/a)
+}
+
+// VideoSizeFromResolution 由分辨率和宽高比计算尺寸，短边取分辨率，长边按比例取偶数
+func VideoSizeFromResolution(resolution string, aspectRatio string, separator string) string {
+	side := resolutionShortSide(resolution)
+	if side <= 0 {
+		return ""
+	}
+	ratio, ok := parseAspectRatio(aspectRatio)
+	if !ok {
+		ratio = 16.0 / 9
+	}
+	width, height := side, side
+	if ratio >= 1 {
+		width = int(math.Round(float64(side)*ratio/2)) * 2
+	} else {
+		height = int(math.Round(float64(side)/ratio/2)) * 2
+	}
+	return fmt.Sprintf("%d%s%d", width, separator, height)
+}
+
+// VideoCapability 视频模型支持的参数，用于提交前校验请求与计费默认值，列表为空表示不限制或该参数由模型固定
+type VideoCapability struct {
+	Durations         []int    // 可选时长（秒）
+	MinDuration       int      // 时长范围下限，与 Durations 二选一
+	MaxDuration       int      // 时长范围上限
+	Resolutions       []string // 可选分辨率，小写，如 720p
+	AspectRatios      []string // 可选宽高比
+	MaxImages         int      // 最多可传入的参考图数量，0 表示不支持图片输入
+	Seed              bool     // 是否支持随机种子
+	Audio             bool     // 是否支持生成音频
+	DefaultDuration   int      // 未传时长时上游的默认时长
+	DefaultResolution string   // 未传分辨率时上游的默认分辨率
+	// ModeResolutions 输出分辨率由 mode 决定的模型（如可灵 std/pro），mode 对应的实际输出分辨率
+	ModeResolutions map[string]string
+}
+
+// Validate 校验请求中的标准字段是否被模型支持，由 size 推导出的分辨率会先对齐到模型支持的档位
+func (v *VideoCapability) Validate(req *TaskSubmitReq) error {
+	// 上游按 mode 决定输出分辨率时以 mode 为准，保证计费与实际输出一致
+	if resolution, ok := v.ModeResolutions[requestMode(req)]; ok {
+		req.Resolution = resolution
+	}
+	if len(v.Resolutions) > 0 && !lo.Contains(v.Resolutions, req.Resolution) {
+		if _, _, ok := ParseVideoSize(req.Size); ok {
+			req.Resolution = ResolutionFromSize(req.Size, v.Resolutions)
+		}
+	}
+	if req.Duration != 0 {
+		if len(v.Durations) > 0 && !lo.Contains(v.Durations, req.Duration) {
+			return fmt.Errorf("duration %d is not supported by model %s, supported: %v", req.Duration, req.Model, v.Durations)
+		}
+		if (v.MinDuration > 0 && req.Duration < v.MinDuration) || (v.MaxDuration > 0 && req.Duration > v.MaxDuration) {
+			return fmt.Errorf("duration %d is not supported by model %s, supported: %d-%d", req.Duration, req.Model, v.MinDuration, v.MaxDuration)
+		}
+	}
+	if req.Resolution != "" && len(v.Resolutions) > 0 && !lo.Contains(v.Resolutions, req.Resolution) {
+		return fmt.Errorf("resolution %s is not supported by model %s, supported: %s", req.Resolution, req.Model, strings.Join(v.Resolutions, ", "))
+	}
+	if req.AspectRatio != "" && len(v.AspectRatios) > 0 && !lo.Contains(v.AspectRatios, req.AspectRatio) {
+		return fmt.Errorf("aspect_ratio %s is not supported by model %s, supported: %s", req.AspectRatio, req.Model, strings.Join(v.AspectRatios, ", "))
+	}
+	if len(req.Images) > v.MaxImages {
+		if v.MaxImages == 0 {
+			return fmt.Errorf("model %s does not support image input", req.Model)
+		}
+		return fmt.Errorf("model %s supports at most %d images", req.Model, v.MaxImages)
+	}
+	if req.Seed != nil && !v.Seed {
+		return fmt.Errorf("model %s does not support seed", req.Model)
+	}
+	if req.Audio != nil && *req.Audio && !v.Audio {
+		return fmt.Errorf("model %s does not support audio generation", req.Model)
+	}
+	return nil
+}
+
+// requestMode 请求的 mode，metadata 中的透传参数会覆盖标准字段，因此优先使用
+func requestMode(req *TaskSubmitReq) string {
+	if mode, ok := req.Metadata["mode"].(string); ok && mode != "" {
+		return mode
+	}
+	return req.Mode
+}
+
+// BillingDuration 计费使用的时长，未传时取模型默认值
+func (v *VideoCapability) BillingDuration(req *TaskSubmitReq) int {
+	if req.Duration > 0 {
+		return req.Duration
+	}
+	if v != nil {
+		return v.DefaultDuration
+	}
+	return 0
+}
+
+// BillingResolution 计费使用的分辨率，未传时取模型默认值
+func (v *VideoCapability) BillingResolution(req *TaskSubmitReq) string {
+	if req.Resolution != "" {
+		return req.Resolution
+	}
+	if v != nil {
+		return v.DefaultResolution
+	}
+	return ""
+}
@@ -13,6 +13,7 @@ const (
 	RelayModeModerations
 	RelayModeImagesGenerations
 	RelayModeImagesEdits
+	RelayModeImagesVariations
 	RelayModeEdits
 
 	RelayModeMidjourneyImagine
@@ -43,6 +44,9 @@ const (
 	RelayModeVideoFetchByID
 	RelayModeVideoSubmit
 
+	RelayModeAudioGenerationFetchByID
+	RelayModeAudioGenerationSubmit
+
 	RelayModeRerank
 
 	RelayModeResponses
@@ -68,6 +72,8 @@ func Path2RelayMode(path string) int {
 		relayMode = RelayModeImagesGenerations
 	} else if strings.HasPrefix(path, "/v1/images/edits") {
 		relayMode = RelayModeImagesEdits
+	} else if strings.HasPrefix(path, "/v1/images/variations") {
+		relayMode = RelayModeImagesVariations
 	} else if strings.HasPrefix(path, "/v1/edits") {
 		relayMode = RelayModeEdits
 	} else if strings.HasPrefix(path, "/v1/responses") {
@@ -0,0 +1,25 @@
+package relay
+
+import (
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PostHedgeLoserQuota 对冲请求落败的一方已将提示词发往上游，按预估提示词 token 计费；预扣费由胜出的一方结算
+func PostHedgeLoserQuota(c *gin.Context, info *relaycommon.RelayInfo) {
+	promptTokens := info.GetEstimatePromptTokens()
+	if info.PriceData.UsePrice || promptTokens <= 0 {
+		return
+	}
+	loserInfo := *info
+	loserInfo.Hedge = nil
+	loserInfo.FinalPreConsumedQuota = 0
+	postConsumeQuota(c, &loserInfo, &dto.Usage{
+		PromptTokens: promptTokens,
+		TotalTokens:  promptTokens,
+	}, "对冲请求未胜出，仅按提示词计费")
+	service.RecordHedgeLoserBilled()
+}
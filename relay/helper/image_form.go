@@ -0,0 +1,190 @@
+package helper
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ImageVariationPrompt 变体接口没有提示词，转换到需要提示词的上游时使用
+	ImageVariationPrompt = "Create a variation of this image. Keep the main subject, composition and style, but vary the details."
+)
+
+// ErrImageMaskUnsupported 上游不支持蒙版参数，局部重绘请求需要路由到支持蒙版的渠道
+var ErrImageMaskUnsupported = errors.New("mask is not supported by this channel")
+
+// ImageEditInputs 图片编辑/变体请求中的输入图片与蒙版，均为 http(s) 链接或 data URL
+type ImageEditInputs struct {
+	Images []string
+	Mask   string
+}
+
+// IsMultipartRequest 判断请求是否为 multipart 表单
+func IsMultipartRequest(c *gin.Context) bool {
+	return strings.Contains(c.Request.Header.Get("Content-Type"), gin.MIMEMultipartPOSTForm)
+}
+
+// GetFormImageFiles 读取表单中的图片文件，兼容 image、image[] 与 image[0] 等写法
+func GetFormImageFiles(c *gin.Context, field string) ([]*multipart.FileHeader, error) {
+	mf := c.Request.MultipartForm
+	if mf == nil {
+		if _, err := c.MultipartForm(); err != nil {
+			return nil, fmt.Errorf("failed to parse multipart form: %w", err)
+		}
+		mf = c.Request.MultipartForm
+	}
+	if mf == nil || mf.File == nil {
+		return nil, nil
+	}
+	if files := mf.File[field]; len(files) > 0 {
+		return files, nil
+	}
+	if files := mf.File[field+"[]"]; len(files) > 0 {
+		return files, nil
+	}
+	var files []*multipart.FileHeader
+	for name, headers := range mf.File {
+		if strings.HasPrefix(name, field+"[") {
+			files = append(files, headers...)
+		}
+	}
+	return files, nil
+}
+
+// FormFileToDataURL 将表单文件编码为 data URL
+func FormFileToDataURL(fileHeader *multipart.FileHeader) (string, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open file %s: %w", fileHeader.Filename, err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file %s: %w", fileHeader.Filename, err)
+	}
+	mimeType := http.DetectContentType(data)
+	return fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// GetImageEditInputs 解析编辑/变体请求的输入图片和蒙版，表单上传的文件转为 data URL，JSON 请求读取 image、mask 字段
+func GetImageEditInputs(c *gin.Context, request dto.ImageRequest) (*ImageEditInputs, error) {
+	inputs := &ImageEditInputs{}
+	if IsMultipartRequest(c) {
+		files, err := GetFormImageFiles(c, "image")
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			dataURL, err := FormFileToDataURL(file)
+			if err != nil {
+				return nil, err
+			}
+			inputs.Images = append(inputs.Images, dataURL)
+		}
+		maskFiles, err := GetFormImageFiles(c, "mask")
+		if err != nil {
+			return nil, err
+		}
+		if len(maskFiles) > 0 {
+			if inputs.Mask, err = FormFileToDataURL(maskFiles[0]); err != nil {
+				return nil, err
+			}
+		}
+		// 表单中也可能以文本字段传图片链接
+		if len(inputs.Images) == 0 {
+			if value := strings.TrimSpace(c.Request.PostForm.Get("image")); value != "" {
+				inputs.Images = append(inputs.Images, value)
+			}
+		}
+	} else {
+		images, err := parseImageField(request.Image)
+		if err != nil {
+			return nil, fmt.Errorf("invalid image field: %w", err)
+		}
+		inputs.Images = images
+		if raw, ok := request.Extra["mask"]; ok {
+			masks, err := parseImageField(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid mask field: %w", err)
+			}
+			if len(masks) > 0 {
+				inputs.Mask = masks[0]
+			}
+		}
+	}
+	if len(inputs.Images) == 0 {
+		return nil, errors.New("image is required")
+	}
+	return inputs, nil
+}
+
+// parseImageField 兼容字符串、字符串数组以及 {"image_url": "..."} 形式
+func parseImageField(raw []byte) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var single string
+	if err := common.Unmarshal(raw, &single); err == nil {
+		if single == "" {
+			return nil, nil
+		}
+		return []string{single}, nil
+	}
+	var items []any
+	if err := common.Unmarshal(raw, &items); err != nil {
+		var object map[string]any
+		if err := common.Unmarshal(raw, &object); err != nil {
+			return nil, err
+		}
+		items = []any{object}
+	}
+	images := make([]string, 0, len(items))
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			if v != "" {
+				images = append(images, v)
+			}
+		case map[string]any:
+			if url, ok := v["image_url"].(string); ok && url != "" {
+				images = append(images, url)
+			} else if url, ok := v["url"].(string); ok && url != "" {
+				images = append(images, url)
+			}
+		}
+	}
+	return images, nil
+}
+
+// BuildImageEditPrompt 生成转换到上游时使用的提示词，变体请求缺省时使用默认提示词
+func BuildImageEditPrompt(prompt string, relayMode int) string {
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" && relayMode == relayconstant.RelayModeImagesVariations {
+		prompt = ImageVariationPrompt
+	}
+	return prompt
+}
+
+// RejectImageMask 上游不支持蒙版参数时拒绝带蒙版的请求，不再把蒙版当作参考图传入
+func RejectImageMask(inputs *ImageEditInputs) error {
+	if inputs != nil && inputs.Mask != "" {
+		return ErrImageMaskUnsupported
+	}
+	return nil
+}
+
+// IsImageEditMode 编辑与变体请求都需要输入图片
+func IsImageEditMode(relayMode int) bool {
+	return relayMode == relayconstant.RelayModeImagesEdits || relayMode == relayconstant.RelayModeImagesVariations
+}
@@ -0,0 +1,48 @@
+package helper
+
+import (
+	"errors"
+	"testing"
+
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+)
+
+func TestBuildImageEditPrompt(t *testing.T) {
+	tests := []struct {
+		name      string
+		prompt    string
+		relayMode int
+		want      string
+	}{
+		{"edit keeps prompt", " make it blue ", relayconstant.RelayModeImagesEdits, "make it blue"},
+		{"edit without prompt", "", relayconstant.RelayModeImagesEdits, ""},
+		{"variation default prompt", "", relayconstant.RelayModeImagesVariations, ImageVariationPrompt},
+		{"variation keeps prompt", "sunset", relayconstant.RelayModeImagesVariations, "sunset"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BuildImageEditPrompt(tt.prompt, tt.relayMode); got != tt.want {
+				t.Errorf("BuildImageEditPrompt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRejectImageMask(t *testing.T) {
+	tests := []struct {
+		name    string
+		inputs  *ImageEditInputs
+		wantErr error
+	}{
+		{"nil inputs", nil, nil},
+		{"images only", &ImageEditInputs{Images: []string{"https://example.com/a.png"}}, nil},
+		{"with mask", &ImageEditInputs{Images: []string{"https://example.com/a.png"}, Mask: "data:image/png;base64,AA=="}, ErrImageMaskUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := RejectImageMask(tt.inputs); !errors.Is(err, tt.wantErr) {
+				t.Errorf("RejectImageMask() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
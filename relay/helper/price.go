@@ -4,7 +4,9 @@ import (
 	"fmt"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
@@ -42,9 +44,32 @@ func HandleGroupRatio(ctx *gin.Context, relayInfo *relaycommon.RelayInfo) types.
 		groupRatioInfo.GroupRatio = ratio_setting.GetGroupRatio(relayInfo.UsingGroup)
 	}
 
+	// 限时模型/分组折扣活动
+	if campaign, ok := model.GetModelDiscountCampaign(relayInfo.OriginModelName, relayInfo.UsingGroup); ok && claimDiscountCampaign(ctx, campaign) {
+		groupRatioInfo.GroupRatio = groupRatioInfo.GroupRatio * campaign.Discount
+		groupRatioInfo.CampaignId = campaign.Id
+		groupRatioInfo.CampaignDiscount = campaign.Discount
+	}
+
 	return groupRatioInfo
 }
 
+// claimDiscountCampaign 有次数上限的折扣活动在计价时占用使用次数，占用失败时按原价计费。
+// 同一请求重试或兜底时重复计价不重复占用，不扣费的计价不占用
+func claimDiscountCampaign(ctx *gin.Context, campaign *model.Campaign) bool {
+	if campaign.MaxUses <= 0 || common.GetContextKeyBool(ctx, constant.ContextKeyPriceDryRun) {
+		return true
+	}
+	if common.GetContextKeyInt(ctx, constant.ContextKeyCampaignClaimed) == campaign.Id {
+		return true
+	}
+	if !model.ClaimCampaignUse(campaign.Id) {
+		return false
+	}
+	common.SetContextKey(ctx, constant.ContextKeyCampaignClaimed, campaign.Id)
+	return true
+}
+
 func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens int, meta *types.TokenCountMeta) (types.PriceData, error) {
 	modelPrice, usePrice := ratio_setting.GetModelPrice(info.OriginModelName, false)
 
@@ -61,6 +86,7 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 	var audioRatio float64
 	var audioCompletionRatio float64
 	var freeModel bool
+	var tierInfo *types.PriceTierInfo
 	if !usePrice {
 		preConsumedTokens := common.Max(promptTokens, common.PreConsumedQuota)
 		if meta.MaxTokens != 0 {
@@ -87,6 +113,10 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		imageRatio, _ = ratio_setting.GetImageRatio(info.OriginModelName)
 		audioRatio = ratio_setting.GetAudioRatio(info.OriginModelName)
 		audioCompletionRatio = ratio_setting.GetAudioCompletionRatio(info.OriginModelName)
+		tierInfo = newPriceTierInfo(info, modelRatio, completionRatio)
+		if tierInfo != nil {
+			modelRatio, completionRatio = applyPriceTier(tierInfo, info.OriginModelName, promptTokens)
+		}
 		ratio := modelRatio * groupRatioInfo.GroupRatio
 		preConsumedQuota = int(float64(preConsumedTokens) * ratio)
 	} else {
@@ -130,6 +160,7 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 		CacheCreation5mRatio: cacheCreationRatio5m,
 		CacheCreation1hRatio: cacheCreationRatio1h,
 		QuotaToPreConsume:    preConsumedQuota,
+		TierInfo:             tierInfo,
 	}
 
 	if common.DebugEnabled {
@@ -139,6 +170,63 @@ func ModelPriceHelper(c *gin.Context, info *relaycommon.RelayInfo, promptTokens
 	return priceData, nil
 }
 
+// newPriceTierInfo 模型配置了阶梯计价时返回阶梯信息，否则返回 nil
+func newPriceTierInfo(info *relaycommon.RelayInfo, modelRatio, completionRatio float64) *types.PriceTierInfo {
+	tieredPrice, ok := ratio_setting.GetTieredPrice(info.OriginModelName)
+	if !ok {
+		return nil
+	}
+	tierInfo := &types.PriceTierInfo{
+		BaseModelRatio:      modelRatio,
+		BaseCompletionRatio: completionRatio,
+		VolumeDiscount:      1,
+	}
+	if len(tieredPrice.VolumeTiers) > 0 && info.UserId != 0 {
+		tierInfo.MonthlyQuota = model.GetUserMonthlyUsedQuota(info.UserId)
+	}
+	return tierInfo
+}
+
+// ApplyTieredPrice 结算时按实际 prompt tokens 重新计算阶梯倍率
+func ApplyTieredPrice(info *relaycommon.RelayInfo, promptTokens int) {
+	if info.PriceData.UsePrice || info.PriceData.TierInfo == nil {
+		return
+	}
+	info.PriceData.ModelRatio, info.PriceData.CompletionRatio = applyPriceTier(info.PriceData.TierInfo, info.OriginModelName, promptTokens)
+}
+
+func applyPriceTier(tierInfo *types.PriceTierInfo, modelName string, promptTokens int) (float64, float64) {
+	modelRatio := tierInfo.BaseModelRatio
+	completionRatio := tierInfo.BaseCompletionRatio
+	tierInfo.PromptTierHit = false
+	tierInfo.PromptTierMinTokens = 0
+	tierInfo.VolumeTierHit = false
+	tierInfo.VolumeMinQuota = 0
+	tierInfo.VolumeDiscount = 1
+
+	tieredPrice, ok := ratio_setting.GetTieredPrice(modelName)
+	if !ok {
+		return modelRatio, completionRatio
+	}
+	if tier, hit := tieredPrice.GetPromptTier(promptTokens); hit {
+		tierInfo.PromptTierHit = true
+		tierInfo.PromptTierMinTokens = tier.MinPromptTokens
+		if tier.ModelRatio > 0 {
+			modelRatio = tier.ModelRatio
+		}
+		if tier.CompletionRatio > 0 {
+			completionRatio = tier.CompletionRatio
+		}
+	}
+	if tier, hit := tieredPrice.GetVolumeTier(tierInfo.MonthlyQuota); hit && tier.Discount > 0 {
+		tierInfo.VolumeTierHit = true
+		tierInfo.VolumeMinQuota = tier.MinMonthlyQuota
+		tierInfo.VolumeDiscount = tier.Discount
+		modelRatio = modelRatio * tier.Discount
+	}
+	return modelRatio, completionRatio
+}
+
 // ModelPriceHelperPerCall 按次计费的 PriceHelper (MJ、Task)
 func ModelPriceHelperPerCall(c *gin.Context, info *relaycommon.RelayInfo) types.PerCallPriceData {
 	groupRatioInfo := HandleGroupRatio(c, info)
@@ -218,6 +218,10 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 			data = strings.TrimLeft(data, " ")
 			data = strings.TrimSuffix(data, "\r")
 			if !strings.HasPrefix(data, "[DONE]") {
+				// 对冲请求：首个 token 到达时竞争输出权，落败的一方停止读取
+				if info.Hedge != nil && !info.Hedge.Claim() {
+					return
+				}
 				info.SetFirstResponseTime()
 
 				// 使用超时机制防止写操作阻塞
@@ -129,7 +129,7 @@ func GetAndValidOpenAIImageRequest(c *gin.Context, relayMode int) (*dto.ImageReq
 	imageRequest := &dto.ImageRequest{}
 
 	switch relayMode {
-	case relayconstant.RelayModeImagesEdits:
+	case relayconstant.RelayModeImagesEdits, relayconstant.RelayModeImagesVariations:
 		if strings.Contains(c.Request.Header.Get("Content-Type"), "multipart/form-data") {
 			_, err := c.MultipartForm()
 			if err != nil {
@@ -141,10 +141,14 @@ func GetAndValidOpenAIImageRequest(c *gin.Context, relayMode int) (*dto.ImageReq
 			imageRequest.N = uint(common.String2Int(formData.Get("n")))
 			imageRequest.Quality = formData.Get("quality")
 			imageRequest.Size = formData.Get("size")
+			imageRequest.ResponseFormat = formData.Get("response_format")
 			if imageValue := formData.Get("image"); imageValue != "" {
 				imageRequest.Image, _ = json.Marshal(imageValue)
 			}
 
+			if relayMode == relayconstant.RelayModeImagesVariations && imageRequest.Model == "" {
+				imageRequest.Model = "dall-e-2"
+			}
 			if imageRequest.Model == "gpt-image-1" {
 				if imageRequest.Quality == "" {
 					imageRequest.Quality = "standard"
@@ -154,6 +158,11 @@ func GetAndValidOpenAIImageRequest(c *gin.Context, relayMode int) (*dto.ImageReq
 				imageRequest.N = 1
 			}
 
+			if formData.Get("stream") == "true" {
+				stream := true
+				imageRequest.Stream = &stream
+			}
+
 			hasWatermark := formData.Has("watermark")
 			if hasWatermark {
 				watermark := formData.Get("watermark") == "true"
@@ -168,6 +177,9 @@ func GetAndValidOpenAIImageRequest(c *gin.Context, relayMode int) (*dto.ImageReq
 			return nil, err
 		}
 
+		if relayMode == relayconstant.RelayModeImagesVariations && imageRequest.Model == "" {
+			imageRequest.Model = "dall-e-2"
+		}
 		if imageRequest.Model == "" {
 			//imageRequest.Model = "dall-e-3"
 			return nil, errors.New("model is required")
@@ -2,6 +2,7 @@ package relay
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,10 +12,14 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -42,9 +47,16 @@ func ImageHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *type
 	if adaptor == nil {
 		return types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
 	}
+	// 仅 OpenAI 类渠道原生支持图像流式输出，其他渠道以非流式请求上游，再将结果转换为流式事件
+	streamRequested := info.IsStream
+	if streamRequested && info.ApiType != constant.APITypeOpenAI {
+		info.IsStream = false
+		request.Stream = nil
+	}
 	adaptor.Init(info)
 
 	var requestBody io.Reader
+	info.UpstreamContentType = ""
 
 	if model_setting.GetGlobalSettings().PassThroughRequestEnabled || info.ChannelSetting.PassThroughBodyEnabled {
 		body, err := common.GetRequestBody(c)
@@ -55,6 +67,10 @@ func ImageHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *type
 	} else {
 		convertedRequest, err := adaptor.ConvertImageRequest(c, info, *request)
 		if err != nil {
+			// 不支持蒙版时仍允许重试到支持蒙版的渠道
+			if errors.Is(err, helper.ErrImageMaskUnsupported) {
+				return types.NewErrorWithStatusCode(err, types.ErrorCodeConvertRequestFailed, http.StatusBadRequest)
+			}
 			return types.NewError(err, types.ErrorCodeConvertRequestFailed)
 		}
 
@@ -79,6 +95,10 @@ func ImageHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *type
 				logger.LogDebug(c, fmt.Sprintf("image request body: %s", string(jsonData)))
 			}
 			requestBody = bytes.NewBuffer(jsonData)
+			// 表单编辑请求转换为 JSON 后，上游请求头不能沿用原始的 multipart Content-Type
+			if strings.Contains(c.Request.Header.Get("Content-Type"), gin.MIMEMultipartPOSTForm) {
+				info.UpstreamContentType = gin.MIMEJSON
+			}
 		}
 	}
 
@@ -91,7 +111,8 @@ func ImageHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *type
 	var httpResp *http.Response
 	if resp != nil {
 		httpResp = resp.(*http.Response)
-		info.IsStream = info.IsStream || strings.HasPrefix(httpResp.Header.Get("Content-Type"), "text/event-stream")
+		// 上游忽略 stream 参数返回 JSON 时同样转换为流式事件
+		info.IsStream = strings.HasPrefix(httpResp.Header.Get("Content-Type"), "text/event-stream")
 		if httpResp.StatusCode != http.StatusOK {
 			if httpResp.StatusCode == http.StatusCreated && info.ApiType == constant.APITypeReplicate {
 				// replicate channel returns 201 Created when using Prefer: wait, treat it as success.
@@ -105,7 +126,25 @@ func ImageHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *type
 		}
 	}
 
+	// 开启图片归档或需要转换为流式事件时先缓存响应，归档后将链接替换为网关签名链接
+	var capture *imageResponseCapture
+	emulateStream := streamRequested && !info.IsStream
+	archive := service.IsMediaArchiveEnabled(model.MediaSourceImage)
+	if !info.IsStream && (archive || emulateStream) {
+		capture = &imageResponseCapture{ResponseWriter: c.Writer, archive: archive}
+		if emulateStream {
+			capture.streamEventType = dto.ImageGenerationCompleted
+			if info.RelayMode == relayconstant.RelayModeImagesEdits {
+				capture.streamEventType = dto.ImageEditCompleted
+			}
+		}
+		c.Writer = capture
+	}
 	usage, newAPIError := adaptor.DoResponse(c, httpResp, info)
+	if capture != nil {
+		c.Writer = capture.ResponseWriter
+		capture.flush(c, info)
+	}
 	if newAPIError != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(newAPIError, statusCodeMappingStr)
@@ -120,8 +159,8 @@ func ImageHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *type
 	}
 
 	quality := "standard"
-	if request.Quality == "hd" {
-		quality = "hd"
+	if request.Quality != "" {
+		quality = request.Quality
 	}
 
 	var logContent string
@@ -130,6 +169,132 @@ func ImageHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *type
 		logContent = fmt.Sprintf("大小 %s, 品质 %s, 张数 %d", request.Size, quality, request.N)
 	}
 
+	// 流式预览图按次计费时加价，按量计费时计入输出 token（上游 completed 事件的用量不含预览图）
+	if partialImages := c.GetInt("image_partial_images"); partialImages > 0 {
+		if info.PriceData.UsePrice {
+			partialTokens := partialImages * operation_setting.GPTImage1PartialImageTokens
+			info.PriceData.ModelPrice += float64(partialTokens) * ratio_setting.GetImageOutputTokenPrice(info.OriginModelName)
+		} else {
+			partialTokens := partialImages * operation_setting.GPTImage1PartialImageTokens
+			usage.(*dto.Usage).CompletionTokens += partialTokens
+			usage.(*dto.Usage).TotalTokens += partialTokens
+		}
+		if logContent != "" {
+			logContent += ", "
+		}
+		logContent += fmt.Sprintf("预览图 %d 张", partialImages)
+	}
+
 	postConsumeQuota(c, info, usage.(*dto.Usage), logContent)
 	return nil
 }
+
+type imageResponseCapture struct {
+	gin.ResponseWriter
+	body            bytes.Buffer
+	archive         bool
+	streamEventType string // 非空时将 JSON 响应转换为对应的流式事件
+}
+
+func (w *imageResponseCapture) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *imageResponseCapture) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// flush 将响应中的图片链接替换为归档签名链接并输出最终响应，预留归档失败时保留原链接
+func (w *imageResponseCapture) flush(c *gin.Context, info *relaycommon.RelayInfo) {
+	body := w.body.Bytes()
+	if len(body) == 0 {
+		return
+	}
+	var imageResp map[string]any
+	if w.archive && w.Status() == http.StatusOK && common.Unmarshal(body, &imageResp) == nil {
+		items, _ := imageResp["data"].([]any)
+		changed := false
+		for _, item := range items {
+			data, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			originUrl, _ := data["url"].(string)
+			if originUrl == "" || strings.HasPrefix(originUrl, "data:") {
+				continue
+			}
+			// 归档在后台完成，响应中先返回签名链接
+			object, err := service.ReserveMediaArchive(service.MediaArchiveRequest{
+				UserId:    info.UserId,
+				Group:     info.UsingGroup,
+				Source:    model.MediaSourceImage,
+				TaskId:    c.GetString(common.RequestIdKey),
+				OriginUrl: originUrl,
+			})
+			if err != nil {
+				logger.LogError(c, fmt.Sprintf("failed to archive image: %s", err.Error()))
+				continue
+			}
+			data["url"] = service.SignMediaURL(object.Id)
+			changed = true
+		}
+		if changed {
+			if rewritten, err := common.Marshal(imageResp); err == nil {
+				body = rewritten
+			}
+		}
+	}
+	w.ResponseWriter.Header().Del("Content-Length")
+	if w.streamEventType != "" && w.Status() == http.StatusOK {
+		err := writeImageStreamEvents(c, w.streamEventType, body)
+		if err == nil {
+			return
+		}
+		logger.LogError(c, fmt.Sprintf("failed to convert image response to stream: %s", err.Error()))
+	}
+	_, _ = w.ResponseWriter.Write(body)
+}
+
+// writeImageStreamEvents 将非流式图像响应转换为 completed 事件，每张图片一个事件，用量附在最后一个事件上
+func writeImageStreamEvents(c *gin.Context, eventType string, body []byte) error {
+	var imageResp struct {
+		dto.ImageResponse
+		Size         string     `json:"size"`
+		Quality      string     `json:"quality"`
+		Background   string     `json:"background"`
+		OutputFormat string     `json:"output_format"`
+		Usage        *dto.Usage `json:"usage"`
+	}
+	if err := common.Unmarshal(body, &imageResp); err != nil {
+		return err
+	}
+	if len(imageResp.Data) == 0 {
+		return fmt.Errorf("empty image data")
+	}
+	helper.SetEventStreamHeaders(c)
+	for i, image := range imageResp.Data {
+		event := dto.ImageStreamEvent{
+			Type:          eventType,
+			B64Json:       image.B64Json,
+			Url:           image.Url,
+			RevisedPrompt: image.RevisedPrompt,
+			CreatedAt:     imageResp.Created,
+			Size:          imageResp.Size,
+			Quality:       imageResp.Quality,
+			Background:    imageResp.Background,
+			OutputFormat:  imageResp.OutputFormat,
+		}
+		if event.CreatedAt == 0 {
+			event.CreatedAt = common.GetTimestamp()
+		}
+		if i == len(imageResp.Data)-1 {
+			event.Usage = imageResp.Usage
+		}
+		data, err := common.Marshal(event)
+		if err != nil {
+			return err
+		}
+		helper.EventChunkData(c, eventType, string(data))
+	}
+	return nil
+}
@@ -27,15 +27,33 @@ import (
 
 func RelayMidjourneyImage(c *gin.Context) {
 	taskId := c.Param("id")
-	midjourneyTask := model.GetByOnlyMJId(taskId)
-	if midjourneyTask == nil {
+	var channelId, mediaObjectId int
+	var imageUrl string
+	midjourneyTask, task := getMidjourneyTask(0, taskId)
+	if midjourneyTask != nil {
+		channelId, mediaObjectId, imageUrl = midjourneyTask.ChannelId, midjourneyTask.MediaObjectId, midjourneyTask.ImageUrl
+	} else if task != nil {
+		var mjDto dto.MidjourneyDto
+		_ = common.Unmarshal(task.Data, &mjDto)
+		channelId, imageUrl = task.ChannelId, midjourneyTaskResultUrl(task, &mjDto)
+		if mjDto.VideoUrl == "" {
+			mediaObjectId = task.PrivateData.MediaObjectId
+		}
+	} else {
 		c.JSON(400, gin.H{
 			"error": "midjourney_task_not_found",
 		})
 		return
 	}
+	if mediaObjectId > 0 {
+		if object, err := model.GetMediaObjectById(mediaObjectId); err == nil {
+			if err := service.ServeMediaObject(c.Writer, c.Request, object); err == nil {
+				return
+			}
+		}
+	}
 	var httpClient *http.Client
-	if channel, err := model.CacheGetChannel(midjourneyTask.ChannelId); err == nil {
+	if channel, err := model.CacheGetChannel(channelId); err == nil {
 		proxy := channel.GetSetting().Proxy
 		if proxy != "" {
 			if httpClient, err = service.NewProxyHttpClient(proxy); err != nil {
@@ -49,7 +67,7 @@ func RelayMidjourneyImage(c *gin.Context) {
 	if httpClient == nil {
 		httpClient = service.GetHttpClient()
 	}
-	resp, err := httpClient.Get(midjourneyTask.ImageUrl)
+	resp, err := httpClient.Get(imageUrl)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "http_get_image_failed",
@@ -91,7 +109,14 @@ func RelayMidjourneyNotify(c *gin.Context) *dto.MidjourneyResponse {
 			Result:      "",
 		}
 	}
-	midjourneyTask := model.GetByOnlyMJId(midjRequest.MjId)
+	midjourneyTask, task := getMidjourneyTask(0, midjRequest.MjId)
+	if midjourneyTask == nil && task != nil {
+		// 统一任务框架中的任务不直接信任回调内容，仅触发一次立即轮询
+		if err := model.TaskBulkUpdateByID([]int64{task.ID}, map[string]any{"next_poll_at": 0}); err != nil {
+			return service.MidjourneyErrorWrapper(constant.MjRequestError, "update_midjourney_task_failed")
+		}
+		return nil
+	}
 	if midjourneyTask == nil {
 		return &dto.MidjourneyResponse{
 			Code:        4,
@@ -110,15 +135,24 @@ func RelayMidjourneyNotify(c *gin.Context) *dto.MidjourneyResponse {
 	midjourneyTask.VideoUrl = midjRequest.VideoUrl
 	videoUrlsStr, _ := json.Marshal(midjRequest.VideoUrls)
 	midjourneyTask.VideoUrls = string(videoUrlsStr)
+	preStatus := midjourneyTask.Status
 	midjourneyTask.Status = midjRequest.Status
 	midjourneyTask.FailReason = midjRequest.FailReason
-	err = midjourneyTask.Update()
+	updated, err := midjourneyTask.UpdateIfNotMigrated()
 	if err != nil {
 		return &dto.MidjourneyResponse{
 			Code:        4,
 			Description: "update_midjourney_task_failed",
 		}
 	}
+	if !updated {
+		// 任务已迁移到 tasks 表，由统一任务框架更新
+		return nil
+	}
+	service.EnqueueMidjourneyArchive(midjourneyTask)
+	if preStatus != midjourneyTask.Status {
+		service.NotifyMidjourneyCallback(midjourneyTask)
+	}
 
 	return nil
 }
@@ -137,6 +171,8 @@ func coverMidjourneyTaskDto(c *gin.Context, originTask *model.Midjourney) (midjo
 		if originTask.Status != "SUCCESS" {
 			midjourneyTask.ImageUrl += "?rand=" + strconv.FormatInt(time.Now().UnixNano(), 10)
 		}
+	} else if originTask.MediaObjectId > 0 {
+		midjourneyTask.ImageUrl = service.SignMediaURL(originTask.MediaObjectId)
 	} else {
 		midjourneyTask.ImageUrl = originTask.ImageUrl
 	}
@@ -184,6 +220,11 @@ func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyR
 	if swapFaceRequest.SourceBase64 == "" || swapFaceRequest.TargetBase64 == "" {
 		return service.MidjourneyErrorWrapper(constant.MjRequestError, "sour_base64_and_target_base64_is_required")
 	}
+	callbackUrl, callbackSecret, err := service.ResolveTaskCallback(c)
+	if err != nil {
+		return service.MidjourneyErrorWrapper(constant.MjRequestError, err.Error())
+	}
+	service.StripTaskCallbackParams(c)
 	modelName := service.CoverActionToModelName(constant.MjActionSwapFace)
 
 	priceData := helper.ModelPriceHelperPerCall(c, info)
@@ -196,7 +237,7 @@ func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyR
 		}
 	}
 
-	if userQuota-priceData.Quota < 0 {
+	if userQuota+info.UserCreditLimit-priceData.Quota < 0 {
 		return &dto.MidjourneyResponse{
 			Code:        4,
 			Description: "quota_not_enough",
@@ -252,6 +293,9 @@ func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyR
 		FailReason:  "",
 		ChannelId:   c.GetInt("channel_id"),
 		Quota:       priceData.Quota,
+
+		CallbackUrl:    callbackUrl,
+		CallbackSecret: callbackSecret,
 	}
 	err = midjourneyTask.Insert()
 	if err != nil {
@@ -272,18 +316,22 @@ func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyR
 func RelayMidjourneyTaskImageSeed(c *gin.Context) *dto.MidjourneyResponse {
 	taskId := c.Param("id")
 	userId := c.GetInt("id")
-	originTask := model.GetByMJId(userId, taskId)
-	if originTask == nil {
+	var channelId int
+	if originTask, task := getMidjourneyTask(userId, taskId); originTask != nil {
+		channelId = originTask.ChannelId
+	} else if task != nil {
+		channelId = task.ChannelId
+	} else {
 		return service.MidjourneyErrorWrapper(constant.MjRequestError, "task_no_found")
 	}
-	channel, err := model.GetChannelById(originTask.ChannelId, true)
+	channel, err := model.GetChannelById(channelId, true)
 	if err != nil {
 		return service.MidjourneyErrorWrapper(constant.MjRequestError, "get_channel_info_failed")
 	}
 	if channel.Status != common.ChannelStatusEnabled {
 		return service.MidjourneyErrorWrapper(constant.MjRequestError, "该任务所属渠道已被禁用")
 	}
-	c.Set("channel_id", originTask.ChannelId)
+	c.Set("channel_id", channelId)
 	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", channel.Key))
 
 	requestURL := getMjRequestPath(c.Request.URL.String())
@@ -309,14 +357,18 @@ func RelayMidjourneyTask(c *gin.Context, relayMode int) *dto.MidjourneyResponse
 	switch relayMode {
 	case relayconstant.RelayModeMidjourneyTaskFetch:
 		taskId := c.Param("id")
-		originTask := model.GetByMJId(userId, taskId)
-		if originTask == nil {
+		originTask, task := getMidjourneyTask(userId, taskId)
+		var midjourneyTask dto.MidjourneyDto
+		if originTask != nil {
+			midjourneyTask = coverMidjourneyTaskDto(c, originTask)
+		} else if task != nil {
+			midjourneyTask = coverTaskToMidjourneyDto(task)
+		} else {
 			return &dto.MidjourneyResponse{
 				Code:        4,
 				Description: "task_no_found",
 			}
 		}
-		midjourneyTask := coverMidjourneyTaskDto(c, originTask)
 		respBody, err = json.Marshal(midjourneyTask)
 		if err != nil {
 			return &dto.MidjourneyResponse{
@@ -337,11 +389,7 @@ func RelayMidjourneyTask(c *gin.Context, relayMode int) *dto.MidjourneyResponse
 		}
 		var tasks []dto.MidjourneyDto
 		if len(condition.IDs) != 0 {
-			originTasks := model.GetByMJIds(userId, condition.IDs)
-			for _, originTask := range originTasks {
-				midjourneyTask := coverMidjourneyTaskDto(c, originTask)
-				tasks = append(tasks, midjourneyTask)
-			}
+			tasks = getMidjourneyTasks(userId, condition.IDs)
 		}
 		if tasks == nil {
 			tasks = make([]dto.MidjourneyDto, 0)
@@ -377,6 +425,11 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 
 	relayInfo.InitChannelMeta(c)
 
+	callbackUrl, callbackSecret, err := service.ResolveTaskCallback(c)
+	if err != nil {
+		return service.MidjourneyErrorWrapper(constant.MjRequestError, err.Error())
+	}
+
 	if relayInfo.RelayMode == relayconstant.RelayModeMidjourneyAction { // midjourney plus，需要从customId中获取任务信息
 		mjErr := service.CoverPlusActionToNormalAction(&midjRequest)
 		if mjErr != nil {
@@ -497,7 +550,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 		}
 	}
 
-	if consumeQuota && userQuota-priceData.Quota < 0 {
+	if consumeQuota && userQuota+relayInfo.UserCreditLimit-priceData.Quota < 0 {
 		return &dto.MidjourneyResponse{
 			Code:        4,
 			Description: "quota_not_enough",
@@ -559,6 +612,9 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 		FailReason:  "",
 		ChannelId:   c.GetInt("channel_id"),
 		Quota:       priceData.Quota,
+
+		CallbackUrl:    callbackUrl,
+		CallbackSecret: callbackSecret,
 	}
 	if midjResponse.Code == 3 {
 		//无实例账号自动禁用渠道（No available account instance）
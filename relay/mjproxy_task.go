@@ -0,0 +1,115 @@
+package relay
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+// getMidjourneyTask 查询 Midjourney 任务：未迁移的旧任务从 midjourneys 表读取，
+// 已迁移或通过统一任务框架提交的任务从 tasks 表读取。userId 为 0 时不校验用户
+func getMidjourneyTask(userId int, mjId string) (*model.Midjourney, *model.Task) {
+	var mj *model.Midjourney
+	if userId == 0 {
+		mj = model.GetByOnlyMJId(mjId)
+	} else {
+		mj = model.GetByMJId(userId, mjId)
+	}
+	if mj != nil && !mj.TaskMigrated {
+		return mj, nil
+	}
+	var task *model.Task
+	var exist bool
+	var err error
+	if userId == 0 {
+		task, exist, err = model.GetByOnlyTaskId(mjId)
+	} else {
+		task, exist, err = model.GetByTaskId(userId, mjId)
+	}
+	if err == nil && exist && task.Platform == constant.TaskPlatformMidjourney {
+		return nil, task
+	}
+	return mj, nil
+}
+
+// getMidjourneyTasks 批量查询，同一任务以 tasks 表中的记录为准
+func getMidjourneyTasks(userId int, mjIds []string) []dto.MidjourneyDto {
+	result := make([]dto.MidjourneyDto, 0, len(mjIds))
+	found := make(map[string]bool, len(mjIds))
+	ids := make([]any, 0, len(mjIds))
+	for _, id := range mjIds {
+		ids = append(ids, id)
+	}
+	if tasks, err := model.GetByTaskIds(userId, ids); err == nil {
+		for _, task := range tasks {
+			if task.Platform != constant.TaskPlatformMidjourney {
+				continue
+			}
+			found[task.TaskID] = true
+			result = append(result, coverTaskToMidjourneyDto(task))
+		}
+	}
+	for _, mj := range model.GetByMJIds(userId, mjIds) {
+		if found[mj.MjId] {
+			continue
+		}
+		result = append(result, coverMidjourneyTaskDto(nil, mj))
+	}
+	return result
+}
+
+// midjourneyTaskResultUrl 返回任务的原始图片地址
+func midjourneyTaskResultUrl(task *model.Task, mjDto *dto.MidjourneyDto) string {
+	// 视频任务的 FailReason 保存的是视频地址，图片取上游返回的封面
+	if task.Status == model.TaskStatusSuccess && mjDto.VideoUrl == "" && task.FailReason != "" {
+		return task.FailReason
+	}
+	return mjDto.ImageUrl
+}
+
+// coverTaskToMidjourneyDto 将统一任务转换为 Midjourney Proxy 格式
+func coverTaskToMidjourneyDto(task *model.Task) (midjourneyTask dto.MidjourneyDto) {
+	_ = common.Unmarshal(task.Data, &midjourneyTask)
+	imageUrl := midjourneyTaskResultUrl(task, &midjourneyTask)
+
+	midjourneyTask.MjId = task.TaskID
+	midjourneyTask.Action = task.Action
+	switch task.Status {
+	case model.TaskStatusSuccess, model.TaskStatusFailure:
+		midjourneyTask.Status = string(task.Status)
+	default:
+		if midjourneyTask.Status == "" {
+			midjourneyTask.Status = string(model.TaskStatusNotStart)
+		}
+	}
+	if midjourneyTask.Progress == "" || task.Status == model.TaskStatusSuccess {
+		midjourneyTask.Progress = task.Progress
+	}
+	midjourneyTask.SubmitTime = task.SubmitTime * 1000
+	midjourneyTask.StartTime = task.StartTime * 1000
+	midjourneyTask.FinishTime = task.FinishTime * 1000
+	midjourneyTask.FailReason = ""
+	if task.Status == model.TaskStatusFailure {
+		midjourneyTask.FailReason = task.FailReason
+	}
+
+	midjourneyTask.ImageUrl = ""
+	if imageUrl != "" && setting.MjForwardUrlEnabled {
+		midjourneyTask.ImageUrl = system_setting.ServerAddress + "/mj/image/" + task.TaskID
+		if task.Status != model.TaskStatusSuccess {
+			midjourneyTask.ImageUrl += "?rand=" + strconv.FormatInt(time.Now().UnixNano(), 10)
+		}
+	} else if task.PrivateData.MediaObjectId > 0 && midjourneyTask.VideoUrl == "" {
+		midjourneyTask.ImageUrl = service.SignMediaURL(task.PrivateData.MediaObjectId)
+	} else {
+		midjourneyTask.ImageUrl = imageUrl
+	}
+	return
+}
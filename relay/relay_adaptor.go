@@ -31,10 +31,13 @@ import (
 	"github.com/QuantumNous/new-api/relay/channel/submodel"
 	taskali "github.com/QuantumNous/new-api/relay/channel/task/ali"
 	taskdoubao "github.com/QuantumNous/new-api/relay/channel/task/doubao"
+	"github.com/QuantumNous/new-api/relay/channel/task/elevenlabs"
 	taskGemini "github.com/QuantumNous/new-api/relay/channel/task/gemini"
 	"github.com/QuantumNous/new-api/relay/channel/task/hailuo"
 	taskjimeng "github.com/QuantumNous/new-api/relay/channel/task/jimeng"
 	"github.com/QuantumNous/new-api/relay/channel/task/kling"
+	taskmidjourney "github.com/QuantumNous/new-api/relay/channel/task/midjourney"
+	taskminimax "github.com/QuantumNous/new-api/relay/channel/task/minimax"
 	tasksora "github.com/QuantumNous/new-api/relay/channel/task/sora"
 	"github.com/QuantumNous/new-api/relay/channel/task/suno"
 	taskvertex "github.com/QuantumNous/new-api/relay/channel/task/vertex"
@@ -129,12 +132,33 @@ func GetTaskPlatform(c *gin.Context) constant.TaskPlatform {
 	return constant.TaskPlatform(c.GetString("platform"))
 }
 
+// GetAudioTaskPlatform 音频生成任务按渠道类型映射到音频任务平台，不支持时返回空
+func GetAudioTaskPlatform(channelType int) constant.TaskPlatform {
+	switch channelType {
+	case constant.ChannelTypeSunoAPI:
+		return constant.TaskPlatformAudioSuno
+	case constant.ChannelTypeMiniMax:
+		return constant.TaskPlatformAudioMiniMax
+	case constant.ChannelTypeElevenLabs:
+		return constant.TaskPlatformAudioElevenLabs
+	}
+	return ""
+}
+
 func GetTaskAdaptor(platform constant.TaskPlatform) channel.TaskAdaptor {
 	switch platform {
 	//case constant.APITypeAIProxyLibrary:
 	//	return &aiproxy.Adaptor{}
 	case constant.TaskPlatformSuno:
 		return &suno.TaskAdaptor{}
+	case constant.TaskPlatformMidjourney:
+		return &taskmidjourney.TaskAdaptor{}
+	case constant.TaskPlatformAudioSuno:
+		return &suno.AudioTaskAdaptor{}
+	case constant.TaskPlatformAudioMiniMax:
+		return &taskminimax.TaskAdaptor{}
+	case constant.TaskPlatformAudioElevenLabs:
+		return &elevenlabs.TaskAdaptor{}
 	}
 	if channelType, err := strconv.ParseInt(string(platform), 10, 64); err == nil {
 		switch channelType {
@@ -156,6 +180,8 @@ func GetTaskAdaptor(platform constant.TaskPlatform) channel.TaskAdaptor {
 			return &taskGemini.TaskAdaptor{}
 		case constant.ChannelTypeMiniMax:
 			return &hailuo.TaskAdaptor{}
+		case constant.ChannelTypeMidjourney, constant.ChannelTypeMidjourneyPlus:
+			return &taskmidjourney.TaskAdaptor{}
 		}
 	}
 	return nil
@@ -15,6 +15,7 @@ import (
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/relay/channel"
+	taskmidjourney "github.com/QuantumNous/new-api/relay/channel/task/midjourney"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/service"
@@ -47,6 +48,10 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 	}
 
 	platform := constant.TaskPlatform(c.GetString("platform"))
+	// Midjourney 放大、变换等操作需从请求体中取得原任务 ID
+	if platform == constant.TaskPlatformMidjourney && info.OriginTaskID == "" {
+		info.OriginTaskID = taskmidjourney.GetOriginTaskId(c, info.RelayMode)
+	}
 
 	// 获取原始任务信息
 	if info.OriginTaskID != "" {
@@ -123,6 +128,12 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 	if platform == "" {
 		platform = GetTaskPlatform(c)
 	}
+	if info.RelayMode == relayconstant.RelayModeAudioGenerationSubmit {
+		platform = GetAudioTaskPlatform(info.ChannelType)
+		if platform == "" {
+			return service.TaskErrorWrapperLocal(fmt.Errorf("channel type %d does not support audio generation", info.ChannelType), "invalid_api_platform", http.StatusBadRequest)
+		}
+	}
 
 	info.InitChannelMeta(c)
 	adaptor := GetTaskAdaptor(platform)
@@ -135,6 +146,21 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 	if taskErr != nil {
 		return
 	}
+	videoCapability, videoReq, taskErr := validateVideoRequest(c, adaptor)
+	if taskErr != nil {
+		return
+	}
+	audioCapability, audioReq := getAudioRequest(c, adaptor)
+	callbackUrl, callbackSecret, err := service.ResolveTaskCallback(c)
+	if err != nil {
+		return service.TaskErrorWrapperLocal(err, "invalid_callback_url", http.StatusBadRequest)
+	}
+	service.StripTaskCallbackParams(c)
+	// 内联返回结果的渠道必须开启媒体归档，否则结果无处保存
+	if provider, ok := adaptor.(channel.InlineTaskResultProvider); ok && provider.InlineTaskResult() &&
+		!service.IsMediaArchiveEnabled(taskMediaSource(platform)) {
+		return service.TaskErrorWrapperLocal(errors.New("media archive must be enabled for this channel"), "media_archive_required", http.StatusBadRequest)
+	}
 
 	modelName := info.OriginModelName
 	if modelName == "" {
@@ -150,6 +176,31 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 		}
 	}
 
+	// 视频按时长×分辨率计费，与已有的计费参数合并
+	if videoReq != nil {
+		if videoPrice, ok := ratio_setting.GetVideoPriceRatio(modelName); ok {
+			videoRatios := map[string]float64{}
+			if duration := videoCapability.BillingDuration(videoReq); videoPrice.PerSecond && duration > 0 {
+				videoRatios["seconds"] = float64(duration)
+			}
+			if resolution := videoCapability.BillingResolution(videoReq); resolution != "" {
+				videoRatios["resolution-"+resolution] = videoPrice.GetResolutionRatio(resolution)
+			}
+			mergeOtherRatios(info, videoRatios)
+		}
+	}
+
+	// 音频按时长计费，任务完成后按实际时长结算
+	billingSeconds := 0.0
+	if audioReq != nil {
+		if audioPrice, ok := ratio_setting.GetAudioPriceRatio(modelName); ok {
+			billingSeconds = audioPrice.BillingSeconds(audioCapability.BillingDuration(audioReq))
+			if billingSeconds > 0 {
+				mergeOtherRatios(info, map[string]float64{"seconds": billingSeconds})
+			}
+		}
+	}
+
 	// 预扣
 	groupRatio := ratio_setting.GetGroupRatio(info.UsingGroup)
 	var ratio float64
@@ -176,7 +227,7 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 		return
 	}
 	quota := int(ratio * common.QuotaPerUnit)
-	if userQuota-quota < 0 {
+	if userQuota+info.UserCreditLimit-quota < 0 {
 		taskErr = service.TaskErrorWrapperLocal(errors.New("user quota is not enough"), "quota_not_enough", http.StatusForbidden)
 		return
 	}
@@ -267,18 +318,85 @@ func RelayTaskSubmit(c *gin.Context, info *relaycommon.RelayInfo) (taskErr *dto.
 	task.Quota = quota
 	task.Data = taskData
 	task.Action = info.Action
+	task.Properties.BillingSeconds = billingSeconds
+	task.PrivateData.CallbackUrl = callbackUrl
+	task.PrivateData.CallbackSecret = callbackSecret
+
+	var syncResult *relaycommon.TaskInfo
+	if provider, ok := adaptor.(channel.SyncTaskResultProvider); ok {
+		syncResult = provider.SyncTaskResult()
+	}
+	if syncResult != nil {
+		// 同步返回的结果已知实际时长，直接按实际时长扣费
+		if syncResult.Duration > 0 && billingSeconds > 0 {
+			quota, billingSeconds = TaskDurationQuota(task, quota, syncResult.Duration)
+			info.PriceData.OtherRatios["seconds"] = billingSeconds
+			task.Quota = quota
+			task.Properties.BillingSeconds = billingSeconds
+		}
+		if err := completeSyncTask(c, task, syncResult); err != nil {
+			taskErr = service.TaskErrorWrapper(err, "save_task_result_failed", http.StatusInternalServerError)
+			return
+		}
+	}
 	err = task.Insert()
 	if err != nil {
 		taskErr = service.TaskErrorWrapper(err, "insert_task_failed", http.StatusInternalServerError)
 		return
 	}
+	if syncResult != nil {
+		if task.PrivateData.MediaObjectId == 0 {
+			service.EnqueueTaskArchive(task, taskMediaSource(platform), syncResult.Url, nil, info.ChannelSetting.Proxy)
+		}
+		service.NotifyTaskCallback(task)
+	}
+	// 音频任务由网关统一返回任务对象
+	if platform.IsAudio() {
+		c.JSON(http.StatusOK, TaskModel2AudioGeneration(task))
+	}
 	return nil
 }
 
+// mergeOtherRatios 将按时长、分辨率得到的计费参数合并到已有参数中，同名参数以新值为准
+func mergeOtherRatios(info *relaycommon.RelayInfo, ratios map[string]float64) {
+	if len(ratios) == 0 {
+		return
+	}
+	if info.PriceData.OtherRatios == nil {
+		info.PriceData.OtherRatios = map[string]float64{}
+	}
+	for key, ratio := range ratios {
+		info.PriceData.OtherRatios[key] = ratio
+	}
+}
+
+// validateVideoRequest 按适配器声明的模型能力校验统一视频参数，不支持的选项在提交上游前直接拒绝。
+// 非统一视频请求（如 Suno、Midjourney、Sora remix）返回 nil
+func validateVideoRequest(c *gin.Context, adaptor channel.TaskAdaptor) (*relaycommon.VideoCapability, *relaycommon.TaskSubmitReq, *dto.TaskError) {
+	req, err := relaycommon.GetTaskRequest(c)
+	if err != nil {
+		return nil, nil, nil
+	}
+	var capability *relaycommon.VideoCapability
+	if provider, ok := adaptor.(channel.VideoCapabilityProvider); ok {
+		capability = provider.GetVideoCapability(req.Model)
+	}
+	if capability != nil {
+		if err := capability.Validate(&req); err != nil {
+			return nil, nil, service.TaskErrorWrapperLocal(err, "unsupported_video_option", http.StatusBadRequest)
+		}
+		// 分辨率可能已对齐到模型支持的档位，更新后供适配器构建请求
+		c.Set("task_request", req)
+	}
+	return capability, &req, nil
+}
+
 var fetchRespBuilders = map[int]func(c *gin.Context) (respBody []byte, taskResp *dto.TaskError){
 	relayconstant.RelayModeSunoFetchByID:  sunoFetchByIDRespBodyBuilder,
 	relayconstant.RelayModeSunoFetch:      sunoFetchRespBodyBuilder,
 	relayconstant.RelayModeVideoFetchByID: videoFetchByIDRespBodyBuilder,
+
+	relayconstant.RelayModeAudioGenerationFetchByID: audioFetchByIDRespBodyBuilder,
 }
 
 func RelayTaskFetch(c *gin.Context, relayMode int) (taskResp *dto.TaskError) {
@@ -449,7 +567,7 @@ func videoFetchByIDRespBodyBuilder(c *gin.Context) (respBody []byte, taskResp *d
 					"metadata": nil,
 					"status":   status,
 					"task_id":  originTask.TaskID,
-					"url":      originTask.FailReason,
+					"url":      taskResultUrl(originTask),
 				}
 				respBody, _ = json.Marshal(dto.TaskResponse[any]{
 					Code: "success",
@@ -491,12 +609,20 @@ func videoFetchByIDRespBodyBuilder(c *gin.Context) (respBody []byte, taskResp *d
 	return
 }
 
+// taskResultUrl 成功任务的结果地址，已归档时返回网关签名链接
+func taskResultUrl(task *model.Task) string {
+	if task.Status == model.TaskStatusSuccess && task.PrivateData.MediaObjectId > 0 {
+		return service.SignMediaURL(task.PrivateData.MediaObjectId)
+	}
+	return task.FailReason
+}
+
 func TaskModel2Dto(task *model.Task) *dto.TaskDto {
 	return &dto.TaskDto{
 		TaskID:     task.TaskID,
 		Action:     task.Action,
 		Status:     string(task.Status),
-		FailReason: task.FailReason,
+		FailReason: taskResultUrl(task),
 		SubmitTime: task.SubmitTime,
 		StartTime:  task.StartTime,
 		FinishTime: task.FinishTime,
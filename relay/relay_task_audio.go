@@ -0,0 +1,131 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getAudioRequest 返回已由音频适配器校验过的统一音频请求及模型能力，非音频任务返回 nil
+func getAudioRequest(c *gin.Context, adaptor channel.TaskAdaptor) (*relaycommon.AudioCapability, *relaycommon.AudioGenerationRequest) {
+	req, err := relaycommon.GetAudioGenerationRequest(c)
+	if err != nil {
+		return nil, nil
+	}
+	var capability *relaycommon.AudioCapability
+	if provider, ok := adaptor.(channel.AudioCapabilityProvider); ok {
+		capability = provider.GetAudioCapability(req.Model)
+	}
+	return capability, &req
+}
+
+// TaskDurationQuota 按实际时长折算按时长计费任务的额度，返回额度与计费时长；
+// 任务未按时长计费或时长未知时原样返回
+func TaskDurationQuota(task *model.Task, quota int, seconds float64) (int, float64) {
+	billed := task.Properties.BillingSeconds
+	if billed <= 0 || seconds <= 0 {
+		return quota, billed
+	}
+	if audioPrice, ok := ratio_setting.GetAudioPriceRatio(task.Properties.OriginModelName); ok {
+		seconds = audioPrice.BillingSeconds(seconds)
+	}
+	return int(float64(quota) * seconds / billed), seconds
+}
+
+// taskMediaSource 返回任务结果归档使用的来源
+func taskMediaSource(platform constant.TaskPlatform) string {
+	if platform.IsAudio() {
+		return model.MediaSourceAudio
+	}
+	return model.MediaSourceVideo
+}
+
+// completeSyncTask 上游同步返回结果时直接结束任务。内联的 data URL 结果不写入任务记录，
+// 在请求内保存到媒体存储，保存失败返回错误，不扣费；远程地址在任务入库后由后台归档
+func completeSyncTask(c *gin.Context, task *model.Task, result *relaycommon.TaskInfo) error {
+	now := time.Now().Unix()
+	task.Status = model.TaskStatusSuccess
+	task.Progress = "100%"
+	task.StartTime = now
+	task.FinishTime = now
+	if !strings.HasPrefix(result.Url, "data:") {
+		task.FailReason = result.Url
+		return nil
+	}
+
+	source := taskMediaSource(task.Platform)
+	if !service.IsMediaArchiveEnabled(source) {
+		return errors.New("media archive is disabled, inline task result cannot be saved")
+	}
+	// 内容已在内存中，无需下载，只限制写入存储的时间
+	archiveCtx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+	object, err := service.ArchiveTaskResult(archiveCtx, task, source, result.Url, nil, "")
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("Failed to archive task %s: %s", task.TaskID, err.Error()))
+		return err
+	}
+	task.PrivateData.MediaObjectId = object.Id
+	return nil
+}
+
+// TaskModel2AudioGeneration 将任务转换为统一音频生成响应
+func TaskModel2AudioGeneration(task *model.Task) *dto.AudioGeneration {
+	audio := dto.NewAudioGeneration()
+	audio.ID = task.TaskID
+	audio.Model = task.Properties.OriginModelName
+	audio.Type = task.Action
+	audio.Status = task.Status.ToVideoStatus()
+	if task.Status == model.TaskStatusNotStart {
+		audio.Status = dto.VideoStatusQueued
+	}
+	audio.SetProgressStr(task.Progress)
+	audio.CreatedAt = task.CreatedAt
+	switch task.Status {
+	case model.TaskStatusSuccess:
+		audio.CompletedAt = task.FinishTime
+		audio.Url = taskResultUrl(task)
+	case model.TaskStatusFailure:
+		audio.CompletedAt = task.FinishTime
+		audio.Error = &dto.OpenAIVideoError{
+			Message: task.FailReason,
+			Code:    "task_failed",
+		}
+	}
+	return audio
+}
+
+func audioFetchByIDRespBodyBuilder(c *gin.Context) (respBody []byte, taskResp *dto.TaskError) {
+	taskId := c.Param("task_id")
+	userId := c.GetInt("id")
+
+	originTask, exist, err := model.GetByTaskId(userId, taskId)
+	if err != nil {
+		taskResp = service.TaskErrorWrapper(err, "get_task_failed", http.StatusInternalServerError)
+		return
+	}
+	if !exist || !originTask.Platform.IsAudio() {
+		taskResp = service.TaskErrorWrapperLocal(errors.New("task_not_exist"), "task_not_exist", http.StatusNotFound)
+		return
+	}
+	respBody, err = json.Marshal(TaskModel2AudioGeneration(originTask))
+	if err != nil {
+		taskResp = service.TaskErrorWrapper(err, "marshal_response_failed", http.StatusInternalServerError)
+	}
+	return
+}
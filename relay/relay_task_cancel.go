@@ -0,0 +1,129 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const taskCancelledReason = "任务已被用户取消"
+
+// RelayTaskCancel 取消未完成的任务或删除已完成任务的结果。
+// 未完成的任务需适配器实现 channel.TaskCanceler，先取消上游任务再按其策略退款；
+// 上游不支持取消时直接拒绝，避免上游继续生成计费而本地却已标记失败。
+// 已完成的任务只清理本地结果，不再请求上游。
+func RelayTaskCancel(c *gin.Context, taskId string) (*model.Task, *dto.TaskError) {
+	userId := c.GetInt("id")
+	task, exist, err := model.GetByTaskId(userId, taskId)
+	if err != nil {
+		return nil, service.TaskErrorWrapper(err, "get_task_failed", http.StatusInternalServerError)
+	}
+	if !exist {
+		return nil, service.TaskErrorWrapperLocal(errors.New("task_not_exist"), "task_not_exist", http.StatusNotFound)
+	}
+
+	switch task.Status {
+	case model.TaskStatusSuccess:
+		// 删除结果：清理归档文件与结果地址
+		if task.PrivateData.MediaObjectId > 0 {
+			if object, err := model.GetMediaObjectById(task.PrivateData.MediaObjectId); err == nil {
+				if err := service.DeleteMediaObject(c.Request.Context(), object); err != nil {
+					logger.LogWarn(c, fmt.Sprintf("failed to delete media object %d: %s", object.Id, err.Error()))
+				}
+			}
+			task.PrivateData.MediaObjectId = 0
+		}
+		task.FailReason = ""
+		if _, err := task.UpdateIfUnchanged(task.Status, task.Progress); err != nil {
+			return nil, service.TaskErrorWrapper(err, "update_task_failed", http.StatusInternalServerError)
+		}
+	case model.TaskStatusFailure:
+	default:
+		refundRatio, taskErr := cancelUpstreamTask(task)
+		if taskErr != nil {
+			return nil, taskErr
+		}
+		now := time.Now().Unix()
+		ok, err := model.FailTaskIfUnfinished(task.ID, taskCancelledReason, now)
+		if err != nil {
+			return nil, service.TaskErrorWrapper(err, "update_task_failed", http.StatusInternalServerError)
+		}
+		if !ok {
+			// 任务已在其他地方结束，返回最新状态
+			if latest, exist, err := model.GetByTaskId(userId, taskId); err == nil && exist {
+				return latest, nil
+			}
+			return task, nil
+		}
+		task.Status = model.TaskStatusFailure
+		task.Progress = "100%"
+		task.FailReason = taskCancelledReason
+		task.FinishTime = now
+		refundQuota := int(float64(task.Quota) * min(max(refundRatio, 0), 1))
+		if refundQuota > 0 {
+			if err := model.IncreaseUserQuota(task.UserId, refundQuota, false); err != nil {
+				logger.LogError(c, "fail to increase user quota: "+err.Error())
+			}
+		}
+		logContent := fmt.Sprintf("取消异步任务 %s，预扣费 %s，退还 %s", task.TaskID, logger.LogQuota(task.Quota), logger.LogQuota(refundQuota))
+		model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
+		service.NotifyTaskCallback(task)
+	}
+	return task, nil
+}
+
+// cancelUpstreamTask 取消未完成的上游任务，返回应退还的预扣费比例
+func cancelUpstreamTask(task *model.Task) (float64, *dto.TaskError) {
+	canceler, ok := GetTaskAdaptor(task.Platform).(channel.TaskCanceler)
+	if !ok {
+		return 0, service.TaskErrorWrapperLocal(channel.ErrTaskCancelUnsupported, "task_cancel_unsupported", http.StatusBadRequest)
+	}
+	channelModel, err := model.CacheGetChannel(task.ChannelId)
+	if err != nil {
+		return 0, service.TaskErrorWrapperLocal(err, "get_channel_failed", http.StatusInternalServerError)
+	}
+	baseURL := constant.ChannelBaseURLs[channelModel.Type]
+	if channelModel.GetBaseURL() != "" {
+		baseURL = channelModel.GetBaseURL()
+	}
+	key := channelModel.Key
+	if task.PrivateData.Key != "" {
+		key = task.PrivateData.Key
+	}
+	refundRatio, err := canceler.CancelTask(baseURL, key, task, channelModel.GetSetting().Proxy)
+	if errors.Is(err, channel.ErrTaskCancelUnsupported) {
+		return 0, service.TaskErrorWrapperLocal(err, "task_cancel_unsupported", http.StatusBadRequest)
+	}
+	if err != nil {
+		return 0, service.TaskErrorWrapper(err, "cancel_task_failed", http.StatusBadRequest)
+	}
+	return refundRatio, nil
+}
+
+// OpenAIVideoDeleted OpenAI 视频删除接口的响应
+type OpenAIVideoDeleted struct {
+	Id      string `json:"id"`
+	Object  string `json:"object"`
+	Deleted bool   `json:"deleted"`
+}
+
+func NewOpenAIVideoDeleted(task *model.Task) OpenAIVideoDeleted {
+	return OpenAIVideoDeleted{Id: task.TaskID, Object: "video.deleted", Deleted: true}
+}
+
+func TaskCancelResponse(task *model.Task) dto.TaskResponse[any] {
+	return dto.TaskResponse[any]{
+		Code: "success",
+		Data: TaskModel2Dto(task),
+	}
+}
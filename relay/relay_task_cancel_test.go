@@ -0,0 +1,105 @@
+package relay
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel"
+	"github.com/QuantumNous/new-api/service"
+)
+
+func TestTaskCancelerSupport(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform constant.TaskPlatform
+		want     bool
+	}{
+		{name: "suno", platform: constant.TaskPlatformSuno, want: false},
+		{name: "kling", platform: constant.TaskPlatform(strconv.Itoa(constant.ChannelTypeKling)), want: true},
+		{name: "jimeng", platform: constant.TaskPlatform(strconv.Itoa(constant.ChannelTypeJimeng)), want: true},
+		{name: "doubao", platform: constant.TaskPlatform(strconv.Itoa(constant.ChannelTypeDoubaoVideo)), want: true},
+		{name: "sora", platform: constant.TaskPlatform(strconv.Itoa(constant.ChannelTypeSora)), want: true},
+		{name: "vidu", platform: constant.TaskPlatform(strconv.Itoa(constant.ChannelTypeVidu)), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ok := GetTaskAdaptor(tt.platform).(channel.TaskCanceler)
+			if ok != tt.want {
+				t.Fatalf("TaskCanceler implemented = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestCancelUpstreamTaskUnsupported(t *testing.T) {
+	task := &model.Task{Platform: constant.TaskPlatformSuno, TaskID: "task_1"}
+	_, taskErr := cancelUpstreamTask(task)
+	if taskErr == nil {
+		t.Fatal("expected unsupported error")
+	}
+	if taskErr.Code != "task_cancel_unsupported" || taskErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("got code %q status %d", taskErr.Code, taskErr.StatusCode)
+	}
+}
+
+func TestNewAPIRelayCancelRefund(t *testing.T) {
+	service.InitHttpClient()
+	tests := []struct {
+		name      string
+		platform  int
+		action    string
+		key       string
+		status    int
+		wantPath  string
+		wantRatio float64
+		wantErr   error
+		wantFail  bool
+	}{
+		{name: "kling official", platform: constant.ChannelTypeKling, key: "ak|sk", wantErr: channel.ErrTaskCancelUnsupported},
+		{name: "jimeng official", platform: constant.ChannelTypeJimeng, key: "ak|sk", wantErr: channel.ErrTaskCancelUnsupported},
+		{name: "kling relay text2video", platform: constant.ChannelTypeKling, action: constant.TaskActionTextGenerate, key: "sk-test", status: http.StatusOK, wantPath: "/kling/v1/videos/text2video/task_1", wantRatio: 1},
+		{name: "kling relay image2video", platform: constant.ChannelTypeKling, action: constant.TaskActionGenerate, key: "sk-test", status: http.StatusOK, wantPath: "/kling/v1/videos/image2video/task_1", wantRatio: 1},
+		{name: "jimeng relay", platform: constant.ChannelTypeJimeng, key: "sk-test", status: http.StatusOK, wantPath: "/jimeng/tasks/task_1", wantRatio: 1},
+		{name: "relay rejected", platform: constant.ChannelTypeKling, key: "sk-test", status: http.StatusBadRequest, wantPath: "/kling/v1/videos/text2video/task_1", wantFail: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				if r.Method != http.MethodDelete {
+					t.Errorf("method = %s, want DELETE", r.Method)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			canceler := GetTaskAdaptor(constant.TaskPlatform(strconv.Itoa(tt.platform))).(channel.TaskCanceler)
+			task := &model.Task{TaskID: "task_1", Action: tt.action, Status: model.TaskStatusInProgress}
+			ratio, err := canceler.CancelTask(server.URL, tt.key, task, "")
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantFail:
+				if err == nil {
+					t.Fatal("expected upstream error")
+				}
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if gotPath != tt.wantPath {
+				t.Fatalf("path = %q, want %q", gotPath, tt.wantPath)
+			}
+			if ratio != tt.wantRatio {
+				t.Fatalf("ratio = %v, want %v", ratio, tt.wantRatio)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package relay
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+)
+
+func TestMergeOtherRatios(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing map[string]float64
+		ratios   map[string]float64
+		want     map[string]float64
+	}{
+		{
+			name:   "nil existing",
+			ratios: map[string]float64{"seconds": 5},
+			want:   map[string]float64{"seconds": 5},
+		},
+		{
+			name:     "keep channel ratios",
+			existing: map[string]float64{"sampleCount": 2},
+			ratios:   map[string]float64{"seconds": 8, "resolution-1080p": 1.5},
+			want:     map[string]float64{"sampleCount": 2, "seconds": 8, "resolution-1080p": 1.5},
+		},
+		{
+			name:     "override same key",
+			existing: map[string]float64{"seconds": 4, "size": 1.666667},
+			ratios:   map[string]float64{"seconds": 10},
+			want:     map[string]float64{"seconds": 10, "size": 1.666667},
+		},
+		{
+			name:     "empty ratios",
+			existing: map[string]float64{"sampleCount": 2},
+			want:     map[string]float64{"sampleCount": 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &relaycommon.RelayInfo{PriceData: types.PriceData{OtherRatios: tt.existing}}
+			mergeOtherRatios(info, tt.ratios)
+			if !reflect.DeepEqual(info.PriceData.OtherRatios, tt.want) {
+				t.Fatalf("OtherRatios = %v, want %v", info.PriceData.OtherRatios, tt.want)
+			}
+		})
+	}
+}
+
+func TestKlingBillingResolutionFollowsMode(t *testing.T) {
+	tests := []struct {
+		name string
+		req  relaycommon.TaskSubmitReq
+		want string
+	}{
+		{name: "default std", req: relaycommon.TaskSubmitReq{}, want: "720p"},
+		{name: "pro without resolution", req: relaycommon.TaskSubmitReq{Mode: "pro"}, want: "1080p"},
+		{name: "pro in metadata", req: relaycommon.TaskSubmitReq{Metadata: map[string]interface{}{"mode": "pro"}}, want: "1080p"},
+		{name: "metadata overrides mode", req: relaycommon.TaskSubmitReq{Mode: "pro", Metadata: map[string]interface{}{"mode": "std"}}, want: "720p"},
+		{name: "mode wins over resolution", req: relaycommon.TaskSubmitReq{Mode: "pro", Resolution: "720p"}, want: "1080p"},
+		{name: "1080p without mode", req: relaycommon.TaskSubmitReq{Resolution: "1080p"}, want: "1080p"},
+	}
+	adaptor := GetTaskAdaptor(constant.TaskPlatform(strconv.Itoa(constant.ChannelTypeKling)))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			capability := adaptor.(channel.VideoCapabilityProvider).GetVideoCapability("kling-v1")
+			req := tt.req
+			if err := capability.Validate(&req); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+			if got := capability.BillingResolution(&req); got != tt.want {
+				t.Fatalf("BillingResolution = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
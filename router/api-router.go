@@ -26,6 +26,8 @@ func SetApiRouter(router *gin.Engine) {
 		//apiRouter.GET("/midjourney", controller.GetMidjourney)
 		apiRouter.GET("/home_page_content", controller.GetHomePageContent)
 		apiRouter.GET("/pricing", middleware.TryUserAuth(), controller.GetPricing)
+		apiRouter.POST("/pricing/simulate", middleware.UserAuth(), controller.SimulatePrice)
+		apiRouter.POST("/pricing/simulate/logs", middleware.AdminAuth(), controller.SimulateRepriceLogs)
 		apiRouter.GET("/verification", middleware.EmailVerificationRateLimit(), middleware.TurnstileCheck(), controller.SendEmailVerification)
 		apiRouter.GET("/reset_password", middleware.CriticalRateLimit(), middleware.TurnstileCheck(), controller.SendPasswordResetEmail)
 		apiRouter.POST("/user/reset", middleware.CriticalRateLimit(), controller.ResetPassword)
@@ -83,11 +85,15 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.PUT("/topup/invoice/batch", controller.BatchUpdateInvoiceStatus)
 				selfRoute.GET("/invoice", controller.GetUserInvoices)
 				selfRoute.GET("/invoice/:id/file", controller.GetInvoiceFile)
+				selfRoute.GET("/statement", controller.GetUserStatements)
+				selfRoute.GET("/statement/:id", controller.GetStatement)
+				selfRoute.GET("/statement/:id/export", controller.ExportStatement)
 				selfRoute.POST("/pay", middleware.CriticalRateLimit(), controller.RequestEpay)
 				selfRoute.POST("/amount", controller.RequestAmount)
 				selfRoute.POST("/stripe/pay", middleware.CriticalRateLimit(), controller.RequestStripePay)
 				selfRoute.POST("/stripe/amount", controller.RequestStripeAmount)
 				selfRoute.POST("/creem/pay", middleware.CriticalRateLimit(), controller.RequestCreemPay)
+				selfRoute.POST("/campaign/check", controller.CheckCampaign)
 				selfRoute.POST("/aff_transfer", controller.TransferAffQuota)
 				selfRoute.PUT("/setting", controller.UpdateUserSetting)
 
@@ -108,6 +114,9 @@ func SetApiRouter(router *gin.Engine) {
 				adminRoute.POST("/invoice/admin", controller.CreateInvoice)
 				adminRoute.GET("/invoice/admin", controller.GetAllInvoices)
 				adminRoute.GET("/invoice/admin/:id/file", controller.GetInvoiceFile)
+				adminRoute.GET("/statement/admin", controller.GetAllStatements)
+				adminRoute.POST("/statement/admin/generate", controller.GenerateStatements)
+				adminRoute.POST("/statement/admin/:id/pay", controller.PayStatement)
 				adminRoute.GET("/search", controller.SearchUsers)
 				adminRoute.GET("/:id", controller.GetUser)
 				adminRoute.POST("/", controller.CreateUser)
@@ -127,6 +136,7 @@ func SetApiRouter(router *gin.Engine) {
 			optionRoute.GET("/", controller.GetOptions)
 			optionRoute.PUT("/", controller.UpdateOption)
 			optionRoute.POST("/rest_model_ratio", controller.ResetModelRatio)
+			optionRoute.POST("/refresh_exchange_rates", controller.RefreshExchangeRates)
 			optionRoute.POST("/migrate_console_setting", controller.MigrateConsoleSetting) // 用于迁移检测的旧键，下个版本会删除
 		}
 		ratioSyncRoute := apiRouter.Group("/ratio_sync")
@@ -135,6 +145,14 @@ func SetApiRouter(router *gin.Engine) {
 			ratioSyncRoute.GET("/channels", controller.GetSyncableChannels)
 			ratioSyncRoute.POST("/fetch", controller.FetchUpstreamRatios)
 		}
+		configRoute := apiRouter.Group("/config")
+		configRoute.Use(middleware.RootAuth())
+		{
+			configRoute.GET("/export", controller.ExportConfigBundle)
+			configRoute.POST("/export", middleware.CriticalRateLimit(), middleware.DisableCache(), middleware.SecureVerificationRequired(), controller.ExportConfigBundleWithKeys)
+			configRoute.POST("/plan", controller.PlanConfigBundle)
+			configRoute.POST("/apply", controller.ApplyConfigBundle)
+		}
 		channelRoute := apiRouter.Group("/channel")
 		channelRoute.Use(middleware.AdminAuth())
 		{
@@ -142,6 +160,7 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.GET("/search", controller.SearchChannels)
 			channelRoute.GET("/models", controller.ChannelListModels)
 			channelRoute.GET("/models_enabled", controller.EnabledListModels)
+			channelRoute.GET("/schedule", controller.GetChannelSchedules)
 			channelRoute.GET("/:id", controller.GetChannel)
 			channelRoute.POST("/:id/key", middleware.RootAuth(), middleware.CriticalRateLimit(), middleware.DisableCache(), middleware.SecureVerificationRequired(), controller.GetChannelKey)
 			channelRoute.GET("/test", controller.TestAllChannels)
@@ -167,6 +186,21 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.GET("/:id/fallback", controller.GetFallbackChannel)
 			channelRoute.PUT("/:id/fallback", controller.SetFallbackChannel)
 			channelRoute.DELETE("/:id/fallback", controller.ClearFallbackChannel)
+			// 金丝雀测试
+			channelRoute.GET("/test_suite", controller.GetChannelTestSuites)
+			channelRoute.POST("/test_suite", controller.AddChannelTestSuite)
+			channelRoute.PUT("/test_suite", controller.UpdateChannelTestSuite)
+			channelRoute.DELETE("/test_suite/:id", controller.DeleteChannelTestSuite)
+			channelRoute.POST("/:id/canary", controller.RunChannelCanary)
+			channelRoute.GET("/:id/canary/runs", controller.GetChannelTestRuns)
+			channelRoute.GET("/substitution", controller.GetModelSubstitution)
+			channelRoute.POST("/:id/substitution_probe", controller.ProbeChannelSubstitution)
+			channelRoute.DELETE("/:id/substitution_flag", controller.ClearChannelSubstitutionFlag)
+			// 渠道配置版本
+			channelRoute.GET("/:id/versions", controller.GetChannelVersions)
+			channelRoute.GET("/:id/versions/diff", controller.DiffChannelVersions)
+			channelRoute.POST("/:id/versions/:version/rollback", controller.RollbackChannel)
+			channelRoute.POST("/tag/rollback", controller.RollbackTagChannels)
 		}
 		tokenRoute := apiRouter.Group("/token")
 		tokenRoute.Use(middleware.UserAuth())
@@ -178,6 +212,7 @@ func SetApiRouter(router *gin.Engine) {
 			tokenRoute.PUT("/", controller.UpdateToken)
 			tokenRoute.DELETE("/:id", controller.DeleteToken)
 			tokenRoute.POST("/batch", controller.DeleteTokenBatch)
+			tokenRoute.POST("/:id/callback_secret", controller.RotateTokenCallbackSecret)
 		}
 		
 		// 支持 token 鉴权的令牌管理接口
@@ -210,6 +245,17 @@ func SetApiRouter(router *gin.Engine) {
 			redemptionRoute.DELETE("/invalid", controller.DeleteInvalidRedemption)
 			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
 		}
+		campaignRoute := apiRouter.Group("/campaign")
+		campaignRoute.Use(middleware.AdminAuth())
+		{
+			campaignRoute.GET("/", controller.GetAllCampaigns)
+			campaignRoute.GET("/:id", controller.GetCampaign)
+			campaignRoute.GET("/:id/usage", controller.GetCampaignUsages)
+			campaignRoute.GET("/:id/report", controller.GetCampaignReport)
+			campaignRoute.POST("/", controller.AddCampaign)
+			campaignRoute.PUT("/", controller.UpdateCampaign)
+			campaignRoute.DELETE("/:id", controller.DeleteCampaign)
+		}
 		logRoute := apiRouter.Group("/log")
 		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
 		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
@@ -245,11 +291,24 @@ func SetApiRouter(router *gin.Engine) {
 		mjRoute := apiRouter.Group("/mj")
 		mjRoute.GET("/self", middleware.UserAuth(), controller.GetUserMidjourney)
 		mjRoute.GET("/", middleware.AdminAuth(), controller.GetAllMidjourney)
+		mjRoute.POST("/migrate", middleware.RootAuth(), controller.MigrateMidjourneyTasks)
 
 		taskRoute := apiRouter.Group("/task")
 		{
 			taskRoute.GET("/self", middleware.UserAuth(), controller.GetUserTask)
 			taskRoute.GET("/", middleware.AdminAuth(), controller.GetAllTask)
+			taskRoute.GET("/callback/self", middleware.UserAuth(), controller.GetUserTaskCallbackDeliveries)
+			taskRoute.POST("/callback/self/:id/replay", middleware.UserAuth(), controller.ReplayUserTaskCallbackDelivery)
+			taskRoute.GET("/callback", middleware.AdminAuth(), controller.GetAllTaskCallbackDeliveries)
+			taskRoute.POST("/callback/:id/replay", middleware.AdminAuth(), controller.ReplayTaskCallbackDelivery)
+		}
+
+		mediaRoute := apiRouter.Group("/media")
+		{
+			mediaRoute.GET("/self", middleware.UserAuth(), controller.GetUserMediaObjects)
+			mediaRoute.DELETE("/self/:id", middleware.UserAuth(), controller.DeleteUserMediaObject)
+			mediaRoute.GET("/", middleware.AdminAuth(), controller.GetAllMediaObjects)
+			mediaRoute.DELETE("/:id", middleware.AdminAuth(), controller.DeleteMediaObject)
 		}
 
 		vendorRoute := apiRouter.Group("/vendors")
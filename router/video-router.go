@@ -16,6 +16,29 @@ func SetVideoRouter(router *gin.Engine) {
 		videoV1Router.GET("/video/generations/:task_id", controller.RelayTask)
 		videoV1Router.POST("/videos/:video_id/remix", controller.RelayTask)
 	}
+	// 异步音频生成：歌曲、音效与长文本语音合成
+	audioV1Router := router.Group("/v1/audio/generations")
+	audioV1Router.Use(middleware.TokenAuth(), middleware.Distribute())
+	{
+		audioV1Router.POST("", controller.RelayTask)
+		audioV1Router.GET("/:task_id", controller.RelayTask)
+	}
+	// 归档结果的签名链接，无需令牌
+	router.GET("/v1/media/:id", controller.GetMedia)
+
+	// 取消任务或删除结果，不需要选择渠道
+	taskCancelRouter := router.Group("")
+	taskCancelRouter.Use(middleware.TokenAuth())
+	{
+		taskCancelRouter.DELETE("/v1/videos/:task_id", controller.RelayTaskCancel)
+		taskCancelRouter.DELETE("/v1/video/generations/:task_id", controller.RelayTaskCancel)
+		taskCancelRouter.DELETE("/v1/audio/generations/:task_id", controller.RelayTaskCancel)
+		taskCancelRouter.DELETE("/kling/v1/videos/text2video/:task_id", controller.RelayTaskCancel)
+		taskCancelRouter.DELETE("/kling/v1/videos/image2video/:task_id", controller.RelayTaskCancel)
+		taskCancelRouter.DELETE("/jimeng/tasks/:task_id", controller.RelayTaskCancel)
+		taskCancelRouter.DELETE("/suno/tasks/:task_id", controller.RelayTaskCancel)
+	}
+
 	// openai compatible API video routes
 	// docs: https://platform.openai.com/docs/api-reference/videos/create
 	{
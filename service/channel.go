@@ -2,11 +2,9 @@ package service
 
 import (
 	"fmt"
-	"net/http"
 	"strings"
 
 	"github.com/QuantumNous/new-api/common"
-	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
@@ -28,6 +26,8 @@ func DisableChannel(channelError types.ChannelError, reason string) {
 	}
 
 	success := model.UpdateChannelStatus(channelError.ChannelId, channelError.UsingKey, common.ChannelStatusAutoDisabled, reason)
+	// 非冷却原因的禁用需要手动或测试后启用，冷却期间（已是自动禁用状态）发生时同样不再随冷却到期自动恢复
+	model.ClearChannelCooldown(channelError.ChannelId, channelError.UsingKey)
 	if success {
 		subject := fmt.Sprintf("通道「%s」（#%d）已被禁用", channelError.ChannelName, channelError.ChannelId)
 		content := fmt.Sprintf("通道「%s」（#%d）已被禁用，原因：%s", channelError.ChannelName, channelError.ChannelId, reason)
@@ -44,6 +44,19 @@ func EnableChannel(channelId int, usingKey string, channelName string) {
 	}
 }
 
+// DisableChannelByError 按命中的错误映射规则禁用 Key 或整个渠道
+func DisableChannelByError(channelError types.ChannelError, err *types.NewAPIError) {
+	rule := err.GetErrorRule()
+	if rule == nil || rule.Action == "" {
+		DisableChannel(channelError, err.Error())
+		return
+	}
+	if rule.Action == dto.ChannelErrorActionDisableChannel {
+		channelError.UsingKey = ""
+	}
+	DisableChannel(channelError, fmt.Sprintf("%s（错误映射规则 %s）", err.Error(), rule.Rule))
+}
+
 func ShouldDisableChannel(channelType int, err *types.NewAPIError) bool {
 	if !common.AutomaticDisableChannelEnabled {
 		return false
@@ -54,42 +67,14 @@ func ShouldDisableChannel(channelType int, err *types.NewAPIError) bool {
 	if types.IsChannelError(err) {
 		return true
 	}
+	// 渠道错误映射规则明确指定了禁用动作
+	if rule := err.GetErrorRule(); rule != nil && rule.Action != "" {
+		return rule.Action != dto.ChannelErrorActionNone
+	}
 	if types.IsSkipRetryError(err) {
 		return false
 	}
-	if err.StatusCode == http.StatusUnauthorized {
-		return true
-	}
-	if err.StatusCode == http.StatusForbidden {
-		switch channelType {
-		case constant.ChannelTypeGemini:
-			return true
-		}
-	}
-	oaiErr := err.ToOpenAIError()
-	switch oaiErr.Code {
-	case "invalid_api_key":
-		return true
-	case "account_deactivated":
-		return true
-	case "billing_not_active":
-		return true
-	case "pre_consume_token_quota_failed":
-		return true
-	case "Arrearage":
-		return true
-	}
-	switch oaiErr.Type {
-	case "insufficient_quota":
-		return true
-	case "insufficient_user_quota":
-		return true
-	// https://docs.anthropic.com/claude/reference/errors
-	case "authentication_error":
-		return true
-	case "permission_error":
-		return true
-	case "forbidden":
+	if matchDefaultChannelErrorRules(channelType, err) {
 		return true
 	}
 
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+)
+
+// CanaryResponse 从测试响应（OpenAI Chat Completions 格式）中提取的内容
+type CanaryResponse struct {
+	Content           string
+	Model             string
+	SystemFingerprint string
+	ToolCalls         []string // 被调用的工具名称
+	FinishReason      string
+	StreamDone        bool // 流式响应是否以 [DONE] 结束
+}
+
+type canaryFingerprint struct {
+	SystemFingerprint *string `json:"system_fingerprint"`
+}
+
+// ParseCanaryResponse 解析测试请求写出的响应体，流式响应按 SSE 逐块合并
+func ParseCanaryResponse(body []byte, isStream bool) (*CanaryResponse, error) {
+	resp := &CanaryResponse{}
+	if !isStream {
+		var textResponse dto.OpenAITextResponse
+		if err := common.Unmarshal(body, &textResponse); err != nil {
+			return nil, fmt.Errorf("响应不是合法的 JSON：%s", err.Error())
+		}
+		resp.Model = textResponse.Model
+		var fingerprint canaryFingerprint
+		if err := common.Unmarshal(body, &fingerprint); err == nil && fingerprint.SystemFingerprint != nil {
+			resp.SystemFingerprint = *fingerprint.SystemFingerprint
+		}
+		if len(textResponse.Choices) > 0 {
+			choice := textResponse.Choices[0]
+			resp.Content = choice.Message.StringContent()
+			resp.FinishReason = choice.FinishReason
+			for _, toolCall := range choice.Message.ParseToolCalls() {
+				resp.ToolCalls = append(resp.ToolCalls, toolCall.Function.Name)
+			}
+		}
+		return resp, nil
+	}
+
+	var content strings.Builder
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			resp.StreamDone = true
+			continue
+		}
+		var chunk dto.ChatCompletionsStreamResponse
+		if err := common.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("流式响应块不是合法的 JSON：%s", err.Error())
+		}
+		if chunk.Model != "" {
+			resp.Model = chunk.Model
+		}
+		if fingerprint := chunk.GetSystemFingerprint(); fingerprint != "" {
+			resp.SystemFingerprint = fingerprint
+		}
+		for _, choice := range chunk.Choices {
+			content.WriteString(choice.Delta.GetContentString())
+			for _, toolCall := range choice.Delta.ToolCalls {
+				if toolCall.Function.Name != "" {
+					resp.ToolCalls = append(resp.ToolCalls, toolCall.Function.Name)
+				}
+			}
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				resp.FinishReason = *choice.FinishReason
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	resp.Content = content.String()
+	return resp, nil
+}
+
+// CheckCanaryAssertions 检查测试用例的断言，返回未通过的断言说明
+func CheckCanaryAssertions(testCase *model.ChannelTestCase, resp *CanaryResponse, isStream bool, upstreamModel string, completionTokens int, latencyMs int64) []string {
+	assertions := testCase.Assertions
+	failures := make([]string, 0)
+	if assertions.Regex != "" {
+		re, err := regexp.Compile(assertions.Regex)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("正则无效：%s", err.Error()))
+		} else if !re.MatchString(resp.Content) {
+			failures = append(failures, fmt.Sprintf("回复内容不匹配正则 %s", assertions.Regex))
+		}
+	}
+	if len(assertions.JSONSchema) > 0 {
+		var value any
+		if err := common.Unmarshal([]byte(extractCanaryJSON(resp.Content)), &value); err != nil {
+			failures = append(failures, "回复内容不是合法的 JSON")
+		} else if err := ValidateJSONSchema(value, assertions.JSONSchema); err != nil {
+			failures = append(failures, fmt.Sprintf("回复内容不符合 JSON Schema：%s", err.Error()))
+		}
+	}
+	if assertions.ToolCall != "" {
+		called := false
+		for _, name := range resp.ToolCalls {
+			if assertions.ToolCall == "*" || name == assertions.ToolCall {
+				called = true
+				break
+			}
+		}
+		if !called {
+			failures = append(failures, fmt.Sprintf("未调用工具 %s", assertions.ToolCall))
+		}
+	}
+	if assertions.MinTokens > 0 && completionTokens < assertions.MinTokens {
+		failures = append(failures, fmt.Sprintf("输出 %d tokens，少于 %d", completionTokens, assertions.MinTokens))
+	}
+	if assertions.MaxLatencyMs > 0 && latencyMs > assertions.MaxLatencyMs {
+		failures = append(failures, fmt.Sprintf("耗时 %dms，超过 %dms", latencyMs, assertions.MaxLatencyMs))
+	}
+	if assertions.StreamIntegrity && isStream {
+		if resp.FinishReason == "" {
+			failures = append(failures, "流式响应缺少结束原因，可能被截断")
+		}
+		if !resp.StreamDone {
+			failures = append(failures, "流式响应未以 [DONE] 结束")
+		}
+	}
+	if assertions.ModelMatch && !ResponseModelMatches(resp.Model, upstreamModel) {
+		failures = append(failures, fmt.Sprintf("响应模型 %s 与请求模型 %s 不一致", resp.Model, upstreamModel))
+	}
+	return failures
+}
+
+// ResponseModelMatches 上游返回的模型名是否与请求一致，上游常在模型名后追加日期版本，如 gpt-4o -> gpt-4o-2024-08-06
+func ResponseModelMatches(responseModel string, upstreamModel string) bool {
+	responseModel = strings.ToLower(strings.TrimPrefix(responseModel, "models/"))
+	upstreamModel = strings.ToLower(upstreamModel)
+	if responseModel == upstreamModel {
+		return true
+	}
+	// 只接受版本后缀，gpt-4o-mini 不能视为 gpt-4o
+	suffix, ok := strings.CutPrefix(responseModel, upstreamModel+"-")
+	return ok && suffix != "" && (suffix[0] >= '0' && suffix[0] <= '9' || suffix == "latest")
+}
+
+// extractCanaryJSON 去掉模型常用的 ```json 代码块包裹
+func extractCanaryJSON(content string) string {
+	content = strings.TrimSpace(content)
+	if strings.HasPrefix(content, "```") {
+		content = strings.TrimPrefix(content, "```json")
+		content = strings.TrimPrefix(content, "```")
+		content = strings.TrimSuffix(content, "```")
+	}
+	return strings.TrimSpace(content)
+}
+
+// ValidateJSONSchema 按 JSON Schema 的常用子集校验：type、properties、required、items、enum、
+// minLength、maxLength、minimum、maximum、minItems、maxItems
+func ValidateJSONSchema(value any, schema map[string]any) error {
+	return validateJSONSchema(value, schema, "$")
+}
+
+func validateJSONSchema(value any, schema map[string]any, path string) error {
+	if schemaType, ok := schema["type"]; ok {
+		types := make([]string, 0)
+		switch t := schemaType.(type) {
+		case string:
+			types = append(types, t)
+		case []any:
+			for _, item := range t {
+				types = append(types, common.Interface2String(item))
+			}
+		}
+		matched := false
+		for _, t := range types {
+			if jsonSchemaTypeMatches(value, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s 的类型应为 %s", path, strings.Join(types, "|"))
+		}
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, item := range enum {
+			if fmt.Sprint(item) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s 的值不在 enum 中", path)
+		}
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		if required, ok := schema["required"].([]any); ok {
+			for _, name := range required {
+				if _, exists := v[common.Interface2String(name)]; !exists {
+					return fmt.Errorf("%s 缺少字段 %v", path, name)
+				}
+			}
+		}
+		if properties, ok := schema["properties"].(map[string]any); ok {
+			for name, propertySchema := range properties {
+				propertyValue, exists := v[name]
+				sub, isMap := propertySchema.(map[string]any)
+				if !exists || !isMap {
+					continue
+				}
+				if err := validateJSONSchema(propertyValue, sub, path+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+	case []any:
+		if minItems, ok := schema["minItems"].(float64); ok && float64(len(v)) < minItems {
+			return fmt.Errorf("%s 至少需要 %v 项", path, minItems)
+		}
+		if maxItems, ok := schema["maxItems"].(float64); ok && float64(len(v)) > maxItems {
+			return fmt.Errorf("%s 最多 %v 项", path, maxItems)
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				if err := validateJSONSchema(item, items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		length := float64(len([]rune(v)))
+		if minLength, ok := schema["minLength"].(float64); ok && length < minLength {
+			return fmt.Errorf("%s 的长度不能小于 %v", path, minLength)
+		}
+		if maxLength, ok := schema["maxLength"].(float64); ok && length > maxLength {
+			return fmt.Errorf("%s 的长度不能大于 %v", path, maxLength)
+		}
+	case float64:
+		if minimum, ok := schema["minimum"].(float64); ok && v < minimum {
+			return fmt.Errorf("%s 不能小于 %v", path, minimum)
+		}
+		if maximum, ok := schema["maximum"].(float64); ok && v > maximum {
+			return fmt.Errorf("%s 不能大于 %v", path, maximum)
+		}
+	}
+	return nil
+}
+
+func jsonSchemaTypeMatches(value any, schemaType string) bool {
+	switch schemaType {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		v, ok := value.(float64)
+		return ok && v == math.Trunc(v)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+)
+
+func TestValidateJSONSchema(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"required": ["name", "age", "tags"],
+		"properties": {
+			"name": {"type": "string", "minLength": 2, "maxLength": 8},
+			"age": {"type": "integer", "minimum": 0, "maximum": 150},
+			"level": {"enum": ["low", "high"]},
+			"score": {"type": ["number", "null"]},
+			"tags": {"type": "array", "minItems": 1, "maxItems": 2, "items": {"type": "string"}}
+		}
+	}`
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{name: "valid", value: `{"name":"bob","age":30,"level":"low","score":null,"tags":["a"]}`},
+		{name: "wrong root type", value: `[]`, wantErr: "$ 的类型应为 object"},
+		{name: "missing required", value: `{"name":"bob","age":30}`, wantErr: "$ 缺少字段 tags"},
+		{name: "integer", value: `{"name":"bob","age":30.5,"tags":["a"]}`, wantErr: "$.age 的类型应为 integer"},
+		{name: "minimum", value: `{"name":"bob","age":-1,"tags":["a"]}`, wantErr: "$.age 不能小于 0"},
+		{name: "maximum", value: `{"name":"bob","age":151,"tags":["a"]}`, wantErr: "$.age 不能大于 150"},
+		{name: "min length counts runes", value: `{"name":"张","age":1,"tags":["a"]}`, wantErr: "$.name 的长度不能小于 2"},
+		{name: "max length", value: `{"name":"abcdefghi","age":1,"tags":["a"]}`, wantErr: "$.name 的长度不能大于 8"},
+		{name: "enum", value: `{"name":"bob","age":1,"level":"mid","tags":["a"]}`, wantErr: "$.level 的值不在 enum 中"},
+		{name: "union type", value: `{"name":"bob","age":1,"score":"x","tags":["a"]}`, wantErr: "$.score 的类型应为 number|null"},
+		{name: "min items", value: `{"name":"bob","age":1,"tags":[]}`, wantErr: "$.tags 至少需要 1 项"},
+		{name: "max items", value: `{"name":"bob","age":1,"tags":["a","b","c"]}`, wantErr: "$.tags 最多 2 项"},
+		{name: "item type", value: `{"name":"bob","age":1,"tags":["a",1]}`, wantErr: "$.tags[1] 的类型应为 string"},
+	}
+	var schemaMap map[string]any
+	if err := common.Unmarshal([]byte(schema), &schemaMap); err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value any
+			if err := common.Unmarshal([]byte(tt.value), &value); err != nil {
+				t.Fatalf("invalid value: %v", err)
+			}
+			err := ValidateJSONSchema(value, schemaMap)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateJSONSchema() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("ValidateJSONSchema() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckCanaryAssertionsJSONSchema(t *testing.T) {
+	testCase := &model.ChannelTestCase{}
+	testCase.Assertions.JSONSchema = map[string]any{
+		"type":     "object",
+		"required": []any{"answer"},
+		"properties": map[string]any{
+			"answer": map[string]any{"type": "integer"},
+		},
+	}
+	tests := []struct {
+		name        string
+		content     string
+		wantFailure string
+	}{
+		{name: "plain json", content: `{"answer": 4}`},
+		{name: "fenced json", content: "```json\n{\"answer\": 4}\n```"},
+		{name: "not json", content: "the answer is 4", wantFailure: "回复内容不是合法的 JSON"},
+		{name: "schema mismatch", content: `{"answer": "four"}`, wantFailure: "回复内容不符合 JSON Schema：$.answer 的类型应为 integer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failures := CheckCanaryAssertions(testCase, &CanaryResponse{Content: tt.content}, false, "gpt-4o", 10, 100)
+			if tt.wantFailure == "" {
+				if len(failures) != 0 {
+					t.Fatalf("failures = %v, want none", failures)
+				}
+				return
+			}
+			if len(failures) != 1 || !strings.Contains(failures[0], tt.wantFailure) {
+				t.Fatalf("failures = %v, want %s", failures, tt.wantFailure)
+			}
+		})
+	}
+}
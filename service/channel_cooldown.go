@@ -0,0 +1,111 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/samber/lo"
+)
+
+// channelCooldownSweepInterval 检查冷却到期的间隔
+const channelCooldownSweepInterval = 10 * time.Second
+
+// ParseRetryAfter 解析 Retry-After 响应头（秒数或 HTTP 日期），无法解析时返回 0
+func ParseRetryAfter(value string) int {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(seconds, 0)
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(int(time.Until(t).Seconds()), 0)
+	}
+	return 0
+}
+
+// isChannelCooldownError 是否为限流、额度耗尽等可恢复的错误
+func isChannelCooldownError(setting *operation_setting.ChannelCooldownSetting, err *types.NewAPIError) bool {
+	if lo.Contains(setting.StatusCodes, err.GetUpstreamStatusCode()) {
+		return true
+	}
+	oaiErr := err.ToOpenAIError()
+	if oaiErr.Code != nil && lo.Contains(setting.ErrorCodes, fmt.Sprintf("%v", oaiErr.Code)) {
+		return true
+	}
+	return lo.Contains(setting.ErrorTypes, oaiErr.Type)
+}
+
+// NeedChannelCooldown 错误是否需要临时冷却 Key 或渠道（而非禁用），只按错误与配置判断，不访问数据库
+func NeedChannelCooldown(err *types.NewAPIError) bool {
+	if !common.AutomaticDisableChannelEnabled || err == nil || types.IsChannelError(err) {
+		return false
+	}
+	if rule := err.GetErrorRule(); rule != nil && rule.Action != "" {
+		return rule.Action != dto.ChannelErrorActionNone && rule.DisableSeconds > 0
+	}
+	setting := operation_setting.GetChannelCooldownSetting()
+	return setting.Enabled && isChannelCooldownError(setting, err)
+}
+
+// GetChannelCooldownSeconds 错误需要临时冷却 Key 或渠道时返回冷却秒数，否则返回 0：
+// 错误映射规则设置了禁用时长时按规则；否则限流、额度耗尽等错误优先使用上游 Retry-After，其次按配置退避，
+// 按配置退避时 Key 或渠道已在冷却中也返回 0
+func GetChannelCooldownSeconds(channelError types.ChannelError, err *types.NewAPIError) int {
+	if !common.AutomaticDisableChannelEnabled || err == nil || types.IsChannelError(err) {
+		return 0
+	}
+	if rule := err.GetErrorRule(); rule != nil && rule.Action != "" {
+		if rule.Action == dto.ChannelErrorActionNone {
+			return 0
+		}
+		return rule.DisableSeconds
+	}
+	setting := operation_setting.GetChannelCooldownSetting()
+	if !setting.Enabled || !isChannelCooldownError(setting, err) {
+		return 0
+	}
+	if setting.RespectRetryAfter && err.GetRetryAfter() > 0 {
+		return min(err.GetRetryAfter(), max(setting.MaxSeconds, 1))
+	}
+	return model.NextChannelCooldownSeconds(channelError.ChannelId, channelError.UsingKey, common.GetTimestamp(), setting.BaseSeconds, setting.MaxSeconds)
+}
+
+// CooldownChannel 按 GetChannelCooldownSeconds 临时禁用 Key 或渠道，到期后自动启用；
+// 需读写渠道信息，应在异步任务中调用。冷却属于可恢复的状态，不通知管理员
+func CooldownChannel(channelError types.ChannelError, err *types.NewAPIError) {
+	if !channelError.AutoBan {
+		return
+	}
+	seconds := GetChannelCooldownSeconds(channelError, err)
+	if seconds <= 0 {
+		return
+	}
+	if rule := err.GetErrorRule(); rule != nil && rule.Action == dto.ChannelErrorActionDisableChannel {
+		channelError.UsingKey = ""
+	}
+	reason := fmt.Sprintf("冷却 %d 秒：%s", seconds, err.Error())
+	if model.CooldownChannel(channelError.ChannelId, channelError.UsingKey, reason, common.GetTimestamp()+int64(seconds)) {
+		common.SysLog(fmt.Sprintf("通道「%s」（#%d）进入冷却 %d 秒，原因：%s", channelError.ChannelName, channelError.ChannelId, seconds, err.Error()))
+	}
+}
+
+// AutomaticallyReinstateChannelCooldowns 定时启用冷却到期的 Key 与渠道，只在Master节点运行
+func AutomaticallyReinstateChannelCooldowns() {
+	for {
+		time.Sleep(channelCooldownSweepInterval)
+		if count := model.ReinstateExpiredChannelCooldowns(common.GetTimestamp()); count > 0 {
+			common.SysLog(fmt.Sprintf("reinstated %d channels or keys after cooldown", count))
+		}
+	}
+}
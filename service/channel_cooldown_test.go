@@ -0,0 +1,78 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantMin int
+		wantMax int
+	}{
+		{"empty", "", 0, 0},
+		{"seconds", "120", 120, 120},
+		{"seconds with spaces", " 30 ", 30, 30},
+		{"negative", "-5", 0, 0},
+		{"http date", time.Now().Add(2 * time.Minute).UTC().Format(http.TimeFormat), 118, 120},
+		{"past http date", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+		{"invalid", "soon", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseRetryAfter(tt.value); got < tt.wantMin || got > tt.wantMax {
+				t.Errorf("ParseRetryAfter(%q) = %d, want [%d, %d]", tt.value, got, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestGetChannelCooldownSeconds(t *testing.T) {
+	withDisableSettings(t)
+	setting := operation_setting.GetChannelCooldownSetting()
+	oldSetting := *setting
+	t.Cleanup(func() { *setting = oldSetting })
+
+	rateLimited := func(ops ...types.NewAPIErrorOptions) *types.NewAPIError {
+		return types.WithOpenAIError(types.OpenAIError{Message: "rate limited", Type: "rate_limit_error"}, http.StatusTooManyRequests, ops...)
+	}
+	withRule := func(err *types.NewAPIError, action string, seconds int) *types.NewAPIError {
+		err.SetErrorRule(&types.ErrorRuleResult{Rule: "rule", Action: action, DisableSeconds: seconds})
+		return err
+	}
+	channelError := types.ChannelError{ChannelId: 1, UsingKey: "sk-test", AutoBan: true}
+	tests := []struct {
+		name    string
+		enabled bool
+		err     *types.NewAPIError
+		want    int
+	}{
+		{"disabled by default ignores rate limit", false, rateLimited(types.ErrOptionWithRetryAfter(30)), 0},
+		{"retry-after respected", true, rateLimited(types.ErrOptionWithRetryAfter(30)), 30},
+		{"retry-after capped at max", true, rateLimited(types.ErrOptionWithRetryAfter(7200)), 3600},
+		{"non cooldown error", true, types.WithOpenAIError(types.OpenAIError{Message: "bad request"}, http.StatusBadRequest), 0},
+		{"rule disable seconds", false, withRule(types.WithOpenAIError(types.OpenAIError{Message: "x"}, http.StatusBadRequest), dto.ChannelErrorActionDisableKey, 90), 90},
+		{"rule without seconds disables permanently", true, withRule(rateLimited(types.ErrOptionWithRetryAfter(30)), dto.ChannelErrorActionDisableKey, 0), 0},
+		{"rule action none", true, withRule(rateLimited(types.ErrOptionWithRetryAfter(30)), dto.ChannelErrorActionNone, 90), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setting.Enabled = tt.enabled
+			setting.MaxSeconds = 3600
+			setting.RespectRetryAfter = true
+			if got := GetChannelCooldownSeconds(channelError, tt.err); got != tt.want {
+				t.Errorf("GetChannelCooldownSeconds() = %d, want %d", got, tt.want)
+			}
+			if got := NeedChannelCooldown(tt.err); got != (tt.want > 0) {
+				t.Errorf("NeedChannelCooldown() = %v, want %v", got, tt.want > 0)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/samber/lo"
+	"github.com/tidwall/gjson"
+)
+
+// defaultChannelErrorRules 未被渠道规则覆盖时的默认禁用条件
+var defaultChannelErrorRules = []dto.ChannelErrorRule{
+	{Name: "unauthorized", StatusCodes: []int{401}, Action: dto.ChannelErrorActionDisableKey},
+	{
+		Name:       "invalid_key_or_billing",
+		ErrorCodes: []string{"invalid_api_key", "account_deactivated", "billing_not_active", "pre_consume_token_quota_failed", "Arrearage"},
+		Action:     dto.ChannelErrorActionDisableKey,
+	},
+	{
+		Name: "quota_or_permission",
+		ErrorTypes: []string{
+			"insufficient_quota",
+			"insufficient_user_quota",
+			// https://docs.anthropic.com/claude/reference/errors
+			"authentication_error",
+			"permission_error",
+			"forbidden",
+		},
+		Action: dto.ChannelErrorActionDisableKey,
+	},
+}
+
+// defaultChannelTypeErrorRules 特定渠道类型的默认禁用条件
+var defaultChannelTypeErrorRules = map[int][]dto.ChannelErrorRule{
+	constant.ChannelTypeGemini: {
+		{Name: "gemini_forbidden", StatusCodes: []int{403}, Action: dto.ChannelErrorActionDisableKey},
+	},
+}
+
+var channelErrorRuleRegexps sync.Map
+
+func getChannelErrorRuleRegexp(pattern string) *regexp.Regexp {
+	if cached, ok := channelErrorRuleRegexps.Load(pattern); ok {
+		return cached.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil
+	}
+	channelErrorRuleRegexps.Store(pattern, re)
+	return re
+}
+
+// channelErrorRuleMatches 判断错误是否命中规则。渠道自定义规则按上游原始状态码匹配；
+// 默认规则（builtin）与原有的禁用判断保持一致，按状态码映射后的状态码和上游返回的字符串错误码匹配
+func channelErrorRuleMatches(rule *dto.ChannelErrorRule, err *types.NewAPIError, oaiErr types.OpenAIError, builtin bool) bool {
+	statusCode := err.GetUpstreamStatusCode()
+	if builtin {
+		statusCode = err.StatusCode
+	}
+	if len(rule.StatusCodes) > 0 && !lo.Contains(rule.StatusCodes, statusCode) {
+		return false
+	}
+	if len(rule.ErrorCodes) > 0 {
+		var code string
+		if builtin {
+			code, _ = oaiErr.Code.(string)
+		} else {
+			code = string(err.GetErrorCode())
+			if oaiErr.Code != nil {
+				code = fmt.Sprintf("%v", oaiErr.Code)
+			}
+		}
+		if !lo.Contains(rule.ErrorCodes, code) {
+			return false
+		}
+	}
+	if len(rule.ErrorTypes) > 0 && !lo.Contains(rule.ErrorTypes, oaiErr.Type) {
+		return false
+	}
+	if len(rule.Match) == 0 {
+		return true
+	}
+	body := err.GetUpstreamBody()
+	if len(body) == 0 {
+		body = []byte(err.Error())
+	}
+	for _, match := range rule.Match {
+		value := string(body)
+		if match.Path != "" {
+			result := gjson.GetBytes(body, match.Path)
+			if !result.Exists() {
+				return false
+			}
+			value = result.String()
+		}
+		if match.Regex != "" {
+			re := getChannelErrorRuleRegexp(match.Regex)
+			if re == nil || !re.MatchString(value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ApplyChannelErrorRules 按顺序匹配渠道的错误映射规则，命中第一条后改写状态码与错误码，
+// 并记录重试与禁用的处理方式；重复调用不会再次改写
+func ApplyChannelErrorRules(rules []dto.ChannelErrorRule, err *types.NewAPIError) *types.ErrorRuleResult {
+	if err == nil {
+		return nil
+	}
+	if result := err.GetErrorRule(); result != nil {
+		return result
+	}
+	oaiErr := err.ToOpenAIError()
+	for i := range rules {
+		rule := &rules[i]
+		if !channelErrorRuleMatches(rule, err, oaiErr, false) {
+			continue
+		}
+		if rule.StatusCode != 0 {
+			err.StatusCode = rule.StatusCode
+		}
+		if rule.ErrorCode != "" {
+			err.SetErrorCode(types.ErrorCode(rule.ErrorCode))
+		}
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		result := &types.ErrorRuleResult{
+			Rule:           name,
+			Retry:          rule.Retry,
+			Action:         rule.Action,
+			DisableSeconds: rule.DisableSeconds,
+		}
+		err.SetErrorRule(result)
+		return result
+	}
+	return nil
+}
+
+// matchDefaultChannelErrorRules 是否命中默认禁用条件
+func matchDefaultChannelErrorRules(channelType int, err *types.NewAPIError) bool {
+	oaiErr := err.ToOpenAIError()
+	for _, rules := range [][]dto.ChannelErrorRule{defaultChannelTypeErrorRules[channelType], defaultChannelErrorRules} {
+		for i := range rules {
+			if channelErrorRuleMatches(&rules[i], err, oaiErr, true) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+)
+
+// legacyShouldDisableChannel 引入错误映射规则前的禁用判断（不含关键词匹配），默认规则需与其保持一致
+func legacyShouldDisableChannel(channelType int, err *types.NewAPIError) bool {
+	if types.IsChannelError(err) {
+		return true
+	}
+	if types.IsSkipRetryError(err) {
+		return false
+	}
+	if err.StatusCode == http.StatusUnauthorized {
+		return true
+	}
+	if err.StatusCode == http.StatusForbidden {
+		switch channelType {
+		case constant.ChannelTypeGemini:
+			return true
+		}
+	}
+	oaiErr := err.ToOpenAIError()
+	switch oaiErr.Code {
+	case "invalid_api_key", "account_deactivated", "billing_not_active", "pre_consume_token_quota_failed", "Arrearage":
+		return true
+	}
+	switch oaiErr.Type {
+	case "insufficient_quota", "insufficient_user_quota", "authentication_error", "permission_error", "forbidden":
+		return true
+	}
+	return false
+}
+
+func withDisableSettings(t *testing.T) {
+	t.Helper()
+	oldEnabled, oldKeywords := common.AutomaticDisableChannelEnabled, operation_setting.AutomaticDisableKeywords
+	common.AutomaticDisableChannelEnabled = true
+	operation_setting.AutomaticDisableKeywords = []string{}
+	t.Cleanup(func() {
+		common.AutomaticDisableChannelEnabled = oldEnabled
+		operation_setting.AutomaticDisableKeywords = oldKeywords
+	})
+}
+
+func TestDefaultChannelErrorRulesMatchLegacy(t *testing.T) {
+	withDisableSettings(t)
+	openAIErr := func(code any, errType string, statusCode int, ops ...types.NewAPIErrorOptions) *types.NewAPIError {
+		return types.WithOpenAIError(types.OpenAIError{Message: "upstream error", Type: errType, Code: code}, statusCode, ops...)
+	}
+	tests := []struct {
+		name        string
+		channelType int
+		err         *types.NewAPIError
+		want        bool
+	}{
+		{"401", constant.ChannelTypeOpenAI, openAIErr(nil, "", http.StatusUnauthorized), true},
+		{"401 mapped to 500", constant.ChannelTypeOpenAI, openAIErr(nil, "", http.StatusInternalServerError, types.ErrOptionWithUpstreamResponse(http.StatusUnauthorized, nil)), false},
+		{"500 mapped to 401", constant.ChannelTypeOpenAI, openAIErr(nil, "", http.StatusUnauthorized, types.ErrOptionWithUpstreamResponse(http.StatusInternalServerError, nil)), true},
+		{"403 gemini", constant.ChannelTypeGemini, openAIErr(nil, "", http.StatusForbidden), true},
+		{"403 openai", constant.ChannelTypeOpenAI, openAIErr(nil, "", http.StatusForbidden), false},
+		{"429", constant.ChannelTypeOpenAI, openAIErr(nil, "", http.StatusTooManyRequests), false},
+		{"invalid_api_key", constant.ChannelTypeOpenAI, openAIErr("invalid_api_key", "", http.StatusBadRequest), true},
+		{"account_deactivated", constant.ChannelTypeOpenAI, openAIErr("account_deactivated", "", http.StatusBadRequest), true},
+		{"billing_not_active", constant.ChannelTypeOpenAI, openAIErr("billing_not_active", "", http.StatusBadRequest), true},
+		{"Arrearage", constant.ChannelTypeAli, openAIErr("Arrearage", "", http.StatusBadRequest), true},
+		{"numeric code", constant.ChannelTypeOpenAI, openAIErr(401, "", http.StatusBadRequest), false},
+		{"insufficient_quota", constant.ChannelTypeOpenAI, openAIErr(nil, "insufficient_quota", http.StatusTooManyRequests), true},
+		{"insufficient_user_quota", constant.ChannelTypeOpenAI, openAIErr(nil, "insufficient_user_quota", http.StatusBadRequest), true},
+		{"forbidden type", constant.ChannelTypeOpenAI, openAIErr(nil, "forbidden", http.StatusBadRequest), true},
+		{"claude authentication_error", constant.ChannelTypeAnthropic, types.WithClaudeError(types.ClaudeError{Type: "authentication_error"}, http.StatusBadRequest), true},
+		{"claude permission_error", constant.ChannelTypeAnthropic, types.WithClaudeError(types.ClaudeError{Type: "permission_error"}, http.StatusBadRequest), true},
+		{"claude overloaded", constant.ChannelTypeAnthropic, types.WithClaudeError(types.ClaudeError{Type: "overloaded_error"}, 529), false},
+		{"local error code is not upstream code", constant.ChannelTypeOpenAI, types.NewError(errors.New("quota"), types.ErrorCodePreConsumeTokenQuotaFailed), false},
+		{"skip retry 401", constant.ChannelTypeOpenAI, openAIErr(nil, "", http.StatusUnauthorized, types.ErrOptionWithSkipRetry()), false},
+		{"channel error", constant.ChannelTypeOpenAI, types.NewError(errors.New("no key"), types.ErrorCodeChannelNoAvailableKey), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			legacy := legacyShouldDisableChannel(tt.channelType, tt.err)
+			if legacy != tt.want {
+				t.Fatalf("legacy = %v, want %v", legacy, tt.want)
+			}
+			if got := ShouldDisableChannel(tt.channelType, tt.err); got != tt.want {
+				t.Errorf("ShouldDisableChannel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyChannelErrorRules(t *testing.T) {
+	retry := false
+	rules := []dto.ChannelErrorRule{
+		{Name: "upstream_401", StatusCodes: []int{401}, StatusCode: 503, Action: dto.ChannelErrorActionDisableChannel, DisableSeconds: 60},
+		{Name: "content_filter", Match: []dto.ChannelErrorMatch{{Path: "error.code", Regex: "^content_"}}, ErrorCode: "content_filtered", Retry: &retry, Action: dto.ChannelErrorActionNone},
+	}
+	tests := []struct {
+		name       string
+		err        *types.NewAPIError
+		wantRule   string
+		wantStatus int
+		wantCode   types.ErrorCode
+	}{
+		{
+			name:       "custom rule matches upstream status before mapping",
+			err:        types.WithOpenAIError(types.OpenAIError{Message: "bad key"}, http.StatusInternalServerError, types.ErrOptionWithUpstreamResponse(http.StatusUnauthorized, nil)),
+			wantRule:   "upstream_401",
+			wantStatus: 503,
+		},
+		{
+			name:       "body match rewrites error code",
+			err:        types.WithOpenAIError(types.OpenAIError{Message: "filtered"}, http.StatusBadRequest, types.ErrOptionWithUpstreamResponse(http.StatusBadRequest, []byte(`{"error":{"code":"content_policy"}}`))),
+			wantRule:   "content_filter",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "content_filtered",
+		},
+		{
+			name:       "no match",
+			err:        types.WithOpenAIError(types.OpenAIError{Message: "overloaded"}, http.StatusServiceUnavailable),
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ApplyChannelErrorRules(rules, tt.err)
+			gotRule := ""
+			if result != nil {
+				gotRule = result.Rule
+			}
+			if gotRule != tt.wantRule {
+				t.Fatalf("rule = %q, want %q", gotRule, tt.wantRule)
+			}
+			if tt.err.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", tt.err.StatusCode, tt.wantStatus)
+			}
+			if tt.wantCode != "" && tt.err.GetErrorCode() != tt.wantCode {
+				t.Errorf("error code = %s, want %s", tt.err.GetErrorCode(), tt.wantCode)
+			}
+			// 重复调用返回同一结果，不再改写
+			if again := ApplyChannelErrorRules(rules, tt.err); again != result {
+				t.Errorf("second apply returned a different result")
+			}
+		})
+	}
+}
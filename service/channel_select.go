@@ -3,12 +3,14 @@ package service
 import (
 	"errors"
 	"fmt"
+	"strconv"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/gin-gonic/gin"
 )
 
@@ -116,7 +118,7 @@ func CacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string,
 			}
 			logger.LogDebug(param.Ctx, "Auto selecting group: %s, priorityRetry: %d", autoGroup, priorityRetry)
 
-			channel, _ = model.GetRandomSatisfiedChannel(autoGroup, param.ModelName, priorityRetry)
+			channel, _ = getRoutedSatisfiedChannel(param, autoGroup, priorityRetry)
 			if channel == nil {
 				// Current group has no available channel for this model, try next group
 				// 当前分组没有该模型的可用渠道，尝试下一个分组
@@ -154,7 +156,7 @@ func CacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string,
 			break
 		}
 	} else {
-		channel, err = model.GetRandomSatisfiedChannel(param.TokenGroup, param.ModelName, param.GetRetry())
+		channel, err = getRoutedSatisfiedChannel(param, param.TokenGroup, param.GetRetry())
 		if err != nil {
 			return nil, param.TokenGroup, err
 		}
@@ -162,6 +164,33 @@ func CacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string,
 	return channel, selectGroup, nil
 }
 
+// getRoutedSatisfiedChannel 按分组与模型配置的路由策略选择渠道，非默认策略写入日志与上下文
+func getRoutedSatisfiedChannel(param *RetryParam, group string, retry int) (*model.Channel, error) {
+	policy := operation_setting.GetRoutingPolicy(group, param.ModelName)
+	channel, err := model.GetRandomSatisfiedChannel(group, param.ModelName, retry, policy, usedChannelIds(param.Ctx))
+	if channel == nil {
+		return channel, err
+	}
+	if policy == operation_setting.RoutingPolicyWeight {
+		common.SetContextKey(param.Ctx, constant.ContextKeyChannelRoutingPolicy, "")
+	} else {
+		common.SetContextKey(param.Ctx, constant.ContextKeyChannelRoutingPolicy, policy)
+		logger.LogInfo(param.Ctx, fmt.Sprintf("[路由策略] 分组 %s 模型 %s 使用 %s 策略选择渠道 #%d", group, param.ModelName, policy, channel.Id))
+	}
+	return channel, err
+}
+
+// usedChannelIds 本次请求已使用过的渠道
+func usedChannelIds(c *gin.Context) map[int]bool {
+	ids := make(map[int]bool)
+	for _, channelIdStr := range c.GetStringSlice("use_channel") {
+		if channelId, err := strconv.Atoi(channelIdStr); err == nil && channelId > 0 {
+			ids[channelId] = true
+		}
+	}
+	return ids
+}
+
 // CacheGetHashSatisfiedChannel 基于 request_id 进行 hash 调度来选择渠道
 // 确保相同的 request_id 总是调度到相同的渠道
 func CacheGetHashSatisfiedChannel(param *RetryParam, requestId string) (*model.Channel, string, error) {
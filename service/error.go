@@ -89,6 +89,11 @@ func RelayErrorHandler(ctx context.Context, resp *http.Response, showBodyWhenFai
 		return
 	}
 	CloseResponseBodyGracefully(resp)
+	defer func() {
+		// 保留上游原始响应，供渠道错误映射规则匹配
+		types.ErrOptionWithUpstreamResponse(resp.StatusCode, responseBody)(newApiErr)
+		types.ErrOptionWithRetryAfter(ParseRetryAfter(resp.Header.Get("Retry-After")))(newApiErr)
+	}()
 	var errResponse dto.GeneralErrorResponse
 
 	err = common.Unmarshal(responseBody, &errResponse)
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+// ExchangeRateSource 汇率来源，返回 1 USD 可兑换的各币种金额
+type ExchangeRateSource interface {
+	FetchRates() (map[string]float64, error)
+}
+
+// manualExchangeRateSource 使用管理员手动配置的汇率
+type manualExchangeRateSource struct{}
+
+func (manualExchangeRateSource) FetchRates() (map[string]float64, error) {
+	return operation_setting.GetCurrencySetting().ExchangeRates, nil
+}
+
+// StubExchangeRateSource 固定汇率，用于本地开发与测试
+type StubExchangeRateSource struct {
+	Rates map[string]float64
+}
+
+func (s StubExchangeRateSource) FetchRates() (map[string]float64, error) {
+	rates := make(map[string]float64, len(s.Rates))
+	for k, v := range s.Rates {
+		rates[k] = v
+	}
+	return rates, nil
+}
+
+var defaultStubRates = map[string]float64{
+	operation_setting.CurrencyUSD: 1,
+	operation_setting.CurrencyCNY: 7.2,
+	operation_setting.CurrencyEUR: 0.9,
+}
+
+// remoteExchangeRateSource 从兼容 {"rates": {"CNY": 7.2}} 格式的接口拉取汇率
+type remoteExchangeRateSource struct {
+	URL string
+}
+
+type remoteExchangeRateResponse struct {
+	Result string             `json:"result"`
+	Rates  map[string]float64 `json:"rates"`
+}
+
+func (s remoteExchangeRateSource) FetchRates() (map[string]float64, error) {
+	if s.URL == "" {
+		return nil, errors.New("rate source url is empty")
+	}
+	fetchSetting := system_setting.GetFetchSetting()
+	if err := common.ValidateURLWithFetchSetting(s.URL, fetchSetting.EnableSSRFProtection, fetchSetting.AllowPrivateIp, fetchSetting.DomainFilterMode, fetchSetting.IpFilterMode, fetchSetting.DomainList, fetchSetting.IpList, fetchSetting.AllowedPorts, fetchSetting.ApplyIPFilterForDomain); err != nil {
+		return nil, fmt.Errorf("request reject: %v", err)
+	}
+	client := GetHttpClient()
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(s.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	var result remoteExchangeRateResponse
+	if err := common.DecodeJson(resp.Body, &result); err != nil {
+		return nil, err
+	}
+	if result.Result != "" && result.Result != "success" {
+		return nil, fmt.Errorf("rate source returned %s", result.Result)
+	}
+	if len(result.Rates) == 0 {
+		return nil, errors.New("rate source returned empty rates")
+	}
+	return result.Rates, nil
+}
+
+// GetExchangeRateSource 根据配置返回当前汇率来源
+func GetExchangeRateSource() ExchangeRateSource {
+	setting := operation_setting.GetCurrencySetting()
+	switch setting.RateSource {
+	case operation_setting.ExchangeRateSourceRemote:
+		return remoteExchangeRateSource{URL: setting.RateSourceURL}
+	case operation_setting.ExchangeRateSourceStub:
+		return StubExchangeRateSource{Rates: defaultStubRates}
+	default:
+		return manualExchangeRateSource{}
+	}
+}
+
+// buildAutoExchangeRates 按已启用币种整理拉取到的汇率，来源缺失的币种保留上次自动汇率
+func buildAutoExchangeRates(currencies []string, fetched map[string]float64, previous map[string]float64) map[string]float64 {
+	rates := make(map[string]float64, len(currencies))
+	for _, currency := range currencies {
+		currency = strings.ToUpper(currency)
+		if currency == operation_setting.CurrencyUSD {
+			rates[currency] = 1
+			continue
+		}
+		if rate, ok := fetched[currency]; ok && rate > 0 {
+			rates[currency] = rate
+		} else if rate, ok := previous[currency]; ok && rate > 0 {
+			rates[currency] = rate
+		}
+	}
+	return rates
+}
+
+// RefreshExchangeRates 从当前来源刷新汇率并持久化到自动汇率，手动汇率与 USDExchangeRate 保持不变
+func RefreshExchangeRates() (map[string]float64, error) {
+	setting := operation_setting.GetCurrencySetting()
+	if !operation_setting.IsAutoExchangeRateSource(setting.RateSource) {
+		return setting.ExchangeRates, nil
+	}
+	fetched, err := GetExchangeRateSource().FetchRates()
+	if err != nil {
+		return nil, err
+	}
+	rates := buildAutoExchangeRates(setting.Currencies, fetched, setting.AutoExchangeRates)
+	ratesJson, err := common.Marshal(rates)
+	if err != nil {
+		return nil, err
+	}
+	if err := model.UpdateOption("currency_setting.auto_exchange_rates", string(ratesJson)); err != nil {
+		return nil, err
+	}
+	if err := model.UpdateOption("currency_setting.rate_updated_at", strconv.FormatInt(common.GetTimestamp(), 10)); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// AutomaticallyUpdateExchangeRates 按配置的间隔定时刷新汇率
+func AutomaticallyUpdateExchangeRates() {
+	for {
+		setting := operation_setting.GetCurrencySetting()
+		interval := setting.RateUpdateInterval
+		if interval <= 0 {
+			interval = 360
+		}
+		if operation_setting.IsAutoExchangeRateSource(setting.RateSource) {
+			if common.GetTimestamp()-setting.RateUpdatedAt >= int64(interval)*60 {
+				if _, err := RefreshExchangeRates(); err != nil {
+					common.SysError("failed to refresh exchange rates: " + err.Error())
+				} else {
+					common.SysLog("exchange rates refreshed")
+				}
+			}
+		}
+		time.Sleep(time.Minute)
+	}
+}
+
+// ResolveDisplayCurrency 返回用户的展示币种，未设置时使用默认币种
+func ResolveDisplayCurrency(setting dto.UserSetting) string {
+	currency := operation_setting.NormalizeCurrency(setting.DisplayCurrency)
+	if !operation_setting.IsSupportedCurrency(currency) {
+		return operation_setting.NormalizeCurrency("")
+	}
+	return currency
+}
+
+// QuotaToCurrencyAmount 将额度换算为指定币种金额
+func QuotaToCurrencyAmount(quota int, currency string) float64 {
+	return operation_setting.ConvertFromUSD(float64(quota)/common.QuotaPerUnit, currency)
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildAutoExchangeRates(t *testing.T) {
+	tests := []struct {
+		name       string
+		currencies []string
+		fetched    map[string]float64
+		previous   map[string]float64
+		want       map[string]float64
+	}{
+		{
+			name:       "fetched rates",
+			currencies: []string{"CNY", "USD", "EUR"},
+			fetched:    map[string]float64{"CNY": 7.1, "EUR": 0.9, "JPY": 150},
+			want:       map[string]float64{"CNY": 7.1, "USD": 1, "EUR": 0.9},
+		},
+		{
+			name:       "missing keeps previous auto rate",
+			currencies: []string{"cny", "eur"},
+			fetched:    map[string]float64{"CNY": 7.1},
+			previous:   map[string]float64{"EUR": 0.88},
+			want:       map[string]float64{"CNY": 7.1, "EUR": 0.88},
+		},
+		{
+			name:       "invalid rate dropped",
+			currencies: []string{"CNY"},
+			fetched:    map[string]float64{"CNY": 0},
+			want:       map[string]float64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildAutoExchangeRates(tt.currencies, tt.fetched, tt.previous)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildAutoExchangeRates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"sync/atomic"
+
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+)
+
+// hedgeStats 对冲请求统计，进程内计数，重启后清零
+type hedgeStats struct {
+	eligible    int64
+	fired       int64
+	primaryWon  int64
+	hedgeWon    int64
+	allFailed   int64
+	loserBilled int64
+}
+
+var globalHedgeStats = &hedgeStats{}
+
+// HedgeStatsInfo 对冲请求统计信息
+type HedgeStatsInfo struct {
+	Eligible     int64   `json:"eligible"`       // 启用对冲的请求数
+	Fired        int64   `json:"fired"`          // 实际发起对冲请求的次数
+	PrimaryWon   int64   `json:"primary_won"`    // 发起对冲后原请求胜出
+	HedgeWon     int64   `json:"hedge_won"`      // 发起对冲后对冲请求胜出
+	AllFailed    int64   `json:"all_failed"`     // 发起对冲后两个请求均失败
+	LoserBilled  int64   `json:"loser_billed"`   // 落败请求按提示词计费的次数
+	FireRate     float64 `json:"fire_rate"`      // 对冲触发率
+	HedgeWinRate float64 `json:"hedge_win_rate"` // 对冲触发后对冲请求胜出的比例
+}
+
+func RecordHedgeEligible() {
+	atomic.AddInt64(&globalHedgeStats.eligible, 1)
+}
+
+func RecordHedgeFired() {
+	atomic.AddInt64(&globalHedgeStats.fired, 1)
+}
+
+// RecordHedgeResult 记录已发起对冲的请求结果，winner 为空表示均失败
+func RecordHedgeResult(winner *relaycommon.HedgeAttempt) {
+	switch {
+	case winner == nil:
+		atomic.AddInt64(&globalHedgeStats.allFailed, 1)
+	case winner.Role == relaycommon.HedgeRoleHedge:
+		atomic.AddInt64(&globalHedgeStats.hedgeWon, 1)
+	default:
+		atomic.AddInt64(&globalHedgeStats.primaryWon, 1)
+	}
+}
+
+func RecordHedgeLoserBilled() {
+	atomic.AddInt64(&globalHedgeStats.loserBilled, 1)
+}
+
+func GetHedgeStats() HedgeStatsInfo {
+	info := HedgeStatsInfo{
+		Eligible:    atomic.LoadInt64(&globalHedgeStats.eligible),
+		Fired:       atomic.LoadInt64(&globalHedgeStats.fired),
+		PrimaryWon:  atomic.LoadInt64(&globalHedgeStats.primaryWon),
+		HedgeWon:    atomic.LoadInt64(&globalHedgeStats.hedgeWon),
+		AllFailed:   atomic.LoadInt64(&globalHedgeStats.allFailed),
+		LoserBilled: atomic.LoadInt64(&globalHedgeStats.loserBilled),
+	}
+	if info.Eligible > 0 {
+		info.FireRate = float64(info.Fired) / float64(info.Eligible)
+	}
+	if info.Fired > 0 {
+		info.HedgeWinRate = float64(info.HedgeWon) / float64(info.Fired)
+	}
+	return info
+}
@@ -49,6 +49,16 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 		other["is_model_mapped"] = true
 		other["upstream_model_name"] = relayInfo.UpstreamModelName
 	}
+	if relayInfo.FallbackFromModel != "" {
+		other["fallback_from"] = relayInfo.FallbackFromModel
+		other["served_model"] = relayInfo.OriginModelName
+	}
+	if routingPolicy := common.GetContextKeyString(ctx, constant.ContextKeyChannelRoutingPolicy); routingPolicy != "" {
+		other["routing_policy"] = routingPolicy
+	}
+	if relayInfo.Hedge.Fired() {
+		other["hedge_role"] = relayInfo.Hedge.Role
+	}
 
 	isSystemPromptOverwritten := common.GetContextKeyBool(ctx, constant.ContextKeySystemPromptOverride)
 	if isSystemPromptOverwritten {
@@ -70,9 +80,39 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 
 	other["admin_info"] = adminInfo
 	appendRequestPath(ctx, relayInfo, other)
+	appendPriceTier(relayInfo, other)
+	appendCampaign(relayInfo.PriceData.GroupRatioInfo, other)
 	return other
 }
 
+func appendCampaign(groupRatioInfo types.GroupRatioInfo, other map[string]interface{}) {
+	if groupRatioInfo.CampaignId == 0 {
+		return
+	}
+	other["campaign_id"] = groupRatioInfo.CampaignId
+	other["campaign_discount"] = groupRatioInfo.CampaignDiscount
+}
+
+func appendPriceTier(relayInfo *relaycommon.RelayInfo, other map[string]interface{}) {
+	tierInfo := relayInfo.PriceData.TierInfo
+	if tierInfo == nil || (!tierInfo.PromptTierHit && !tierInfo.VolumeTierHit) {
+		return
+	}
+	priceTier := map[string]interface{}{
+		"base_model_ratio":      tierInfo.BaseModelRatio,
+		"base_completion_ratio": tierInfo.BaseCompletionRatio,
+	}
+	if tierInfo.PromptTierHit {
+		priceTier["prompt_tokens_above"] = tierInfo.PromptTierMinTokens
+	}
+	if tierInfo.VolumeTierHit {
+		priceTier["monthly_quota"] = tierInfo.MonthlyQuota
+		priceTier["volume_min_quota"] = tierInfo.VolumeMinQuota
+		priceTier["volume_discount"] = tierInfo.VolumeDiscount
+	}
+	other["price_tier"] = priceTier
+}
+
 func GenerateWssOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.RealtimeUsage, modelRatio, groupRatio, completionRatio, audioRatio, audioCompletionRatio, modelPrice, userGroupRatio float64) map[string]interface{} {
 	info := GenerateTextOtherInfo(ctx, relayInfo, modelRatio, groupRatio, completionRatio, 0, 0.0, modelPrice, userGroupRatio)
 	info["ws"] = true
@@ -125,6 +165,7 @@ func GenerateMjOtherInfo(relayInfo *relaycommon.RelayInfo, priceData types.PerCa
 	if priceData.GroupRatioInfo.HasSpecialRatio {
 		other["user_group_ratio"] = priceData.GroupRatioInfo.GroupSpecialRatio
 	}
+	appendCampaign(priceData.GroupRatioInfo, other)
 	appendRequestPath(nil, relayInfo, other)
 	return other
 }
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	mediaArchiveWorkers   = 4
+	mediaArchiveQueueSize = 1024
+	mediaArchiveTimeout   = 5 * time.Minute
+)
+
+// mediaArchiveJob 一次归档作业，name 仅用于日志
+type mediaArchiveJob struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+var (
+	mediaArchiveQueue     = make(chan mediaArchiveJob, mediaArchiveQueueSize)
+	mediaArchiveStartOnce sync.Once
+)
+
+// EnqueueMediaArchive 将归档作业放入后台队列，由固定数量的 worker 执行，不阻塞请求与轮询；
+// 队列已满时丢弃作业并记录日志，结果仍可通过原始地址访问
+func EnqueueMediaArchive(name string, run func(ctx context.Context) error) bool {
+	mediaArchiveStartOnce.Do(func() {
+		for i := 0; i < mediaArchiveWorkers; i++ {
+			gopool.Go(mediaArchiveWorker)
+		}
+	})
+	select {
+	case mediaArchiveQueue <- mediaArchiveJob{name: name, run: run}:
+		return true
+	default:
+		common.SysError(fmt.Sprintf("media archive queue is full, drop %s", name))
+		return false
+	}
+}
+
+func mediaArchiveWorker() {
+	for job := range mediaArchiveQueue {
+		runMediaArchiveJob(job)
+	}
+}
+
+func runMediaArchiveJob(job mediaArchiveJob) {
+	defer func() {
+		if r := recover(); r != nil {
+			common.SysError(fmt.Sprintf("media archive %s panic: %v", job.name, r))
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), mediaArchiveTimeout)
+	defer cancel()
+	if err := job.run(ctx); err != nil {
+		common.SysError(fmt.Sprintf("failed to archive %s: %s", job.name, err.Error()))
+	}
+}
+
+// EnqueueTaskArchive 后台归档任务结果，完成后只回写任务的归档对象
+func EnqueueTaskArchive(task *model.Task, source string, fetchUrl string, header http.Header, proxy string) bool {
+	if fetchUrl == "" || !IsMediaArchiveEnabled(source) {
+		return false
+	}
+	archived := *task
+	return EnqueueMediaArchive("task "+task.TaskID, func(ctx context.Context) error {
+		object, err := ArchiveTaskResult(ctx, &archived, source, fetchUrl, header, proxy)
+		if err != nil {
+			return err
+		}
+		return model.SetTaskMediaObjectId(archived.ID, object.Id)
+	})
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/model"
+)
+
+func TestEnqueueMediaArchiveRunsInBackground(t *testing.T) {
+	done := make(chan bool, 2)
+	ok := EnqueueMediaArchive("test", func(ctx context.Context) error {
+		_, hasDeadline := ctx.Deadline()
+		done <- hasDeadline
+		return nil
+	})
+	if !ok {
+		t.Fatal("EnqueueMediaArchive = false, want true")
+	}
+	// 作业出错或 panic 不影响后续作业
+	EnqueueMediaArchive("failing", func(ctx context.Context) error { return errors.New("boom") })
+	EnqueueMediaArchive("panicking", func(ctx context.Context) error { panic("boom") })
+	EnqueueMediaArchive("after", func(ctx context.Context) error {
+		done <- true
+		return nil
+	})
+	for i := 0; i < 2; i++ {
+		select {
+		case hasDeadline := <-done:
+			if !hasDeadline {
+				t.Fatal("archive job context has no timeout")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("archive job did not run")
+		}
+	}
+}
+
+func TestEnqueueTaskArchiveSkipsWhenDisabled(t *testing.T) {
+	task := &model.Task{TaskID: "task_1"}
+	tests := []struct {
+		name     string
+		fetchUrl string
+	}{
+		{name: "empty url", fetchUrl: ""},
+		{name: "archive disabled", fetchUrl: "https://example.com/audio.mp3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if EnqueueTaskArchive(task, model.MediaSourceAudio, tt.fetchUrl, nil, "") {
+				t.Fatal("EnqueueTaskArchive = true, want false")
+			}
+		})
+	}
+}
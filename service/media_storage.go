@@ -0,0 +1,630 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// MediaStore 生成结果的持久化存储
+type MediaStore interface {
+	Put(ctx context.Context, key string, contentType string, file *os.File, size int64, sha256Hex string) error
+	Delete(ctx context.Context, key string) error
+	// Serve 输出文件内容，支持 Range 请求
+	Serve(w http.ResponseWriter, r *http.Request, key string, contentType string) error
+}
+
+// GetMediaStore 根据配置返回当前存储后端
+func GetMediaStore(backend string) (MediaStore, error) {
+	setting := operation_setting.GetMediaStorageSetting()
+	switch backend {
+	case "", operation_setting.MediaStorageBackendLocal:
+		root := setting.LocalPath
+		if root == "" {
+			root = "./data/media"
+		}
+		return &localMediaStore{root: root}, nil
+	case operation_setting.MediaStorageBackendS3:
+		if setting.S3Endpoint == "" || setting.S3Bucket == "" {
+			return nil, errors.New("s3 endpoint or bucket is not configured")
+		}
+		return &s3MediaStore{
+			endpoint:  strings.TrimRight(setting.S3Endpoint, "/"),
+			region:    setting.S3Region,
+			bucket:    setting.S3Bucket,
+			accessKey: setting.S3AccessKey,
+			secretKey: setting.S3SecretKey,
+			pathStyle: setting.S3PathStyle,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported media storage backend: %s", backend)
+	}
+}
+
+type localMediaStore struct {
+	root string
+}
+
+func (s *localMediaStore) path(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	if strings.Contains(cleaned, "..") {
+		return "", errors.New("invalid media key")
+	}
+	return filepath.Join(s.root, cleaned), nil
+}
+
+func (s *localMediaStore) Put(ctx context.Context, key string, contentType string, file *os.File, size int64, sha256Hex string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, file)
+	return err
+}
+
+func (s *localMediaStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *localMediaStore) Serve(w http.ResponseWriter, r *http.Request, key string, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	http.ServeContent(w, r, filepath.Base(path), stat.ModTime(), file)
+	return nil
+}
+
+// s3MediaStore 通过 SigV4 签名访问 S3 兼容存储
+type s3MediaStore struct {
+	endpoint  string
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+}
+
+func (s *s3MediaStore) objectURL(key string) (string, error) {
+	u, err := url.Parse(s.endpoint)
+	if err != nil {
+		return "", err
+	}
+	escapedKey := (&url.URL{Path: key}).EscapedPath()
+	if s.pathStyle {
+		u.Path = "/" + s.bucket + "/" + strings.TrimLeft(escapedKey, "/")
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = "/" + strings.TrimLeft(escapedKey, "/")
+	}
+	return u.String(), nil
+}
+
+func (s *s3MediaStore) do(ctx context.Context, method string, key string, body io.Reader, size int64, payloadHash string, header http.Header) (*http.Response, error) {
+	objectURL, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, objectURL, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, values := range header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if payloadHash == "" {
+		// 空请求体的 sha256
+		payloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	}
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	region := s.region
+	if region == "" {
+		region = "us-east-1"
+	}
+	signer := v4.NewSigner(func(options *v4.SignerOptions) {
+		options.DisableURIPathEscaping = true
+	})
+	credentials := aws.Credentials{AccessKeyID: s.accessKey, SecretAccessKey: s.secretKey}
+	if err := signer.SignHTTP(ctx, credentials, req, payloadHash, "s3", region, time.Now()); err != nil {
+		return nil, err
+	}
+	return GetHttpClient().Do(req)
+}
+
+func (s *s3MediaStore) Put(ctx context.Context, key string, contentType string, file *os.File, size int64, sha256Hex string) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	resp, err := s.do(ctx, http.MethodPut, key, file, size, sha256Hex, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("s3 put failed with status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (s *s3MediaStore) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("s3 delete failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *s3MediaStore) Serve(w http.ResponseWriter, r *http.Request, key string, contentType string) error {
+	header := http.Header{}
+	for _, name := range []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since"} {
+		if v := r.Header.Get(name); v != "" {
+			header.Set(name, v)
+		}
+	}
+	resp, err := s.do(r.Context(), http.MethodGet, key, nil, 0, "", header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("s3 get failed with status %d", resp.StatusCode)
+	}
+	for _, name := range []string{"Content-Length", "Content-Range", "Accept-Ranges", "ETag", "Last-Modified"} {
+		if v := resp.Header.Get(name); v != "" {
+			w.Header().Set(name, v)
+		}
+	}
+	if contentType == "" {
+		contentType = resp.Header.Get("Content-Type")
+	}
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// IsMediaArchiveEnabled 判断指定来源是否需要归档
+func IsMediaArchiveEnabled(source string) bool {
+	setting := operation_setting.GetMediaStorageSetting()
+	if !setting.Enabled {
+		return false
+	}
+	switch source {
+	case model.MediaSourceVideo:
+		return setting.ArchiveVideo
+	case model.MediaSourceMidjourney:
+		return setting.ArchiveMidjourney
+	case model.MediaSourceImage:
+		return setting.ArchiveImage
+	case model.MediaSourceAudio:
+		return setting.ArchiveAudio
+	}
+	return false
+}
+
+// MediaArchiveRequest 归档参数
+type MediaArchiveRequest struct {
+	UserId    int
+	Group     string
+	Source    string
+	TaskId    string
+	OriginUrl string // 用于去重与展示，不应包含密钥
+	FetchUrl  string // 实际下载地址，为空时使用 OriginUrl
+	Header    http.Header
+	Client    *http.Client
+}
+
+// ArchiveTaskResult 归档异步任务的结果文件：data URL 直接保存内容，其余地址按 fetchUrl 下载，
+// 记录中的原始地址会去掉查询参数，避免保存密钥
+func ArchiveTaskResult(ctx context.Context, task *model.Task, source string, fetchUrl string, header http.Header, proxy string) (*model.MediaObject, error) {
+	archiveReq := MediaArchiveRequest{
+		UserId:   task.UserId,
+		Group:    task.Group,
+		Source:   source,
+		TaskId:   task.TaskID,
+		FetchUrl: fetchUrl,
+		Header:   header,
+	}
+	if strings.HasPrefix(fetchUrl, "data:") {
+		archiveReq.OriginUrl = "inline:" + task.TaskID
+	} else {
+		archiveReq.OriginUrl, _, _ = strings.Cut(fetchUrl, "?")
+	}
+	client, err := GetHttpClientWithProxy(proxy)
+	if err != nil {
+		return nil, err
+	}
+	archiveReq.Client = client
+	return ArchiveMedia(ctx, archiveReq)
+}
+
+// ArchiveMedia 下载生成结果并保存到存储后端，同一任务同一地址只归档一次
+func ArchiveMedia(ctx context.Context, req MediaArchiveRequest) (*model.MediaObject, error) {
+	if req.OriginUrl == "" {
+		return nil, errors.New("origin url is empty")
+	}
+	if existing := model.GetMediaObjectByOrigin(req.Source, req.TaskId, req.OriginUrl); existing != nil {
+		return existing, nil
+	}
+	object := newMediaObject(req)
+	store, err := storeMedia(ctx, req, object)
+	if err != nil {
+		return nil, err
+	}
+	if err := object.Insert(); err != nil {
+		_ = store.Delete(ctx, object.StorageKey)
+		return nil, err
+	}
+	enforceUserMediaQuota(ctx, req.UserId)
+	return object, nil
+}
+
+// ReserveMediaArchive 先创建归档记录，文件由后台队列下载写入，调用方可立即返回签名链接；
+// 写入完成前或写入失败时，签名链接跳转到原始地址
+func ReserveMediaArchive(req MediaArchiveRequest) (*model.MediaObject, error) {
+	if req.OriginUrl == "" {
+		return nil, errors.New("origin url is empty")
+	}
+	if existing := model.GetMediaObjectByOrigin(req.Source, req.TaskId, req.OriginUrl); existing != nil {
+		return existing, nil
+	}
+	object := newMediaObject(req)
+	if err := object.Insert(); err != nil {
+		return nil, err
+	}
+	EnqueueMediaArchive(req.Source+" "+req.TaskId, func(ctx context.Context) error {
+		store, err := storeMedia(ctx, req, object)
+		if err != nil {
+			return err
+		}
+		if err := object.UpdateStorage(); err != nil {
+			_ = store.Delete(ctx, object.StorageKey)
+			return err
+		}
+		enforceUserMediaQuota(ctx, req.UserId)
+		return nil
+	})
+	return object, nil
+}
+
+func newMediaObject(req MediaArchiveRequest) *model.MediaObject {
+	object := &model.MediaObject{
+		UserId:    req.UserId,
+		Source:    req.Source,
+		TaskId:    req.TaskId,
+		Backend:   operation_setting.GetMediaStorageSetting().Backend,
+		OriginUrl: req.OriginUrl,
+	}
+	if days := operation_setting.GetMediaRetentionDays(req.Group); days > 0 {
+		object.ExpiresAt = time.Now().Unix() + int64(days)*24*3600
+	}
+	return object
+}
+
+// storeMedia 下载文件并写入存储后端，填充记录中的存储信息
+func storeMedia(ctx context.Context, req MediaArchiveRequest, object *model.MediaObject) (MediaStore, error) {
+	setting := operation_setting.GetMediaStorageSetting()
+	store, err := GetMediaStore(object.Backend)
+	if err != nil {
+		return nil, err
+	}
+	maxSize := int64(setting.MaxObjectSizeMB) * 1024 * 1024
+	if maxSize <= 0 {
+		maxSize = 512 * 1024 * 1024
+	}
+
+	tmp, err := os.CreateTemp("", "new-api-media-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	contentType, size, sha256Hex, err := downloadMedia(ctx, req, tmp, maxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	ext := ""
+	if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+		ext = exts[0]
+	}
+	key := fmt.Sprintf("%s/%d/%s/%s%s", req.Source, req.UserId, time.Now().Format("20060102"), common.GetUUID(), ext)
+	if object.Backend == operation_setting.MediaStorageBackendS3 && setting.S3Prefix != "" {
+		key = strings.Trim(setting.S3Prefix, "/") + "/" + key
+	}
+	if err := store.Put(ctx, key, contentType, tmp, size, sha256Hex); err != nil {
+		return nil, err
+	}
+	object.StorageKey = key
+	object.ContentType = contentType
+	object.Size = size
+	return store, nil
+}
+
+// downloadMedia 将结果写入临时文件，返回类型、大小与 sha256
+func downloadMedia(ctx context.Context, req MediaArchiveRequest, dst *os.File, maxSize int64) (string, int64, string, error) {
+	hash := sha256.New()
+	writer := io.MultiWriter(dst, hash)
+	fetchUrl := req.FetchUrl
+	if fetchUrl == "" {
+		fetchUrl = req.OriginUrl
+	}
+
+	if strings.HasPrefix(fetchUrl, "data:") {
+		// data:image/png;base64,xxxx
+		meta, data, found := strings.Cut(strings.TrimPrefix(fetchUrl, "data:"), ",")
+		if !found || !strings.HasSuffix(meta, ";base64") {
+			return "", 0, "", errors.New("unsupported data url")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return "", 0, "", err
+		}
+		if int64(len(decoded)) > maxSize {
+			return "", 0, "", errors.New("media size exceeds limit")
+		}
+		if _, err := writer.Write(decoded); err != nil {
+			return "", 0, "", err
+		}
+		return strings.TrimSuffix(meta, ";base64"), int64(len(decoded)), hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	// 结果地址由上游返回，下载前按 fetch_setting 做 SSRF 校验，重定向后的地址同样校验
+	if err := validateMediaFetchUrl(fetchUrl); err != nil {
+		return "", 0, "", err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, fetchUrl, nil)
+	if err != nil {
+		return "", 0, "", err
+	}
+	for k, values := range req.Header {
+		for _, v := range values {
+			httpReq.Header.Add(k, v)
+		}
+	}
+	client := req.Client
+	if client == nil {
+		client = GetHttpClient()
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	checkedClient := *client
+	checkedClient.CheckRedirect = func(redirectReq *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return validateMediaFetchUrl(redirectReq.URL.String())
+	}
+	resp, err := checkedClient.Do(httpReq)
+	if err != nil {
+		return "", 0, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, "", fmt.Errorf("download media failed with status %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxSize {
+		return "", 0, "", errors.New("media size exceeds limit")
+	}
+	size, err := io.Copy(writer, io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return "", 0, "", err
+	}
+	if size > maxSize {
+		return "", 0, "", errors.New("media size exceeds limit")
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" || contentType == "application/octet-stream" {
+		if byExt := mime.TypeByExtension(filepath.Ext(httpReq.URL.Path)); byExt != "" {
+			contentType = byExt
+		} else if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+	}
+	return contentType, size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func validateMediaFetchUrl(fetchUrl string) error {
+	fetchSetting := system_setting.GetFetchSetting()
+	if err := common.ValidateURLWithFetchSetting(fetchUrl, fetchSetting.EnableSSRFProtection, fetchSetting.AllowPrivateIp, fetchSetting.DomainFilterMode, fetchSetting.IpFilterMode, fetchSetting.DomainList, fetchSetting.IpList, fetchSetting.AllowedPorts, fetchSetting.ApplyIPFilterForDomain); err != nil {
+		return fmt.Errorf("request reject: %v", err)
+	}
+	return nil
+}
+
+// EnqueueMidjourneyArchive 将 Midjourney 成功任务的图片提交到后台归档队列，
+// 应在任务更新落库后调用，完成后只回写归档对象
+func EnqueueMidjourneyArchive(task *model.Midjourney) {
+	if task.Status != "SUCCESS" || task.ImageUrl == "" || task.MediaObjectId > 0 || !IsMediaArchiveEnabled(model.MediaSourceMidjourney) {
+		return
+	}
+	id, userId, channelId := task.Id, task.UserId, task.ChannelId
+	archiveReq := MediaArchiveRequest{
+		UserId:    task.UserId,
+		Source:    model.MediaSourceMidjourney,
+		TaskId:    task.MjId,
+		OriginUrl: task.ImageUrl,
+	}
+	EnqueueMediaArchive("midjourney task "+task.MjId, func(ctx context.Context) error {
+		archiveReq.Group, _ = model.GetUserGroup(userId, false)
+		if channel, err := model.CacheGetChannel(channelId); err == nil && channel.GetSetting().Proxy != "" {
+			client, err := NewProxyHttpClient(channel.GetSetting().Proxy)
+			if err != nil {
+				return err
+			}
+			archiveReq.Client = client
+		}
+		object, err := ArchiveMedia(ctx, archiveReq)
+		if err != nil {
+			return err
+		}
+		return model.SetMidjourneyMediaObjectId(id, object.Id)
+	})
+}
+
+// DeleteMediaObject 删除归档文件及记录
+func DeleteMediaObject(ctx context.Context, object *model.MediaObject) error {
+	// 未完成写入的预留记录没有存储文件
+	if object.IsPending() {
+		return object.Delete()
+	}
+	store, err := GetMediaStore(object.Backend)
+	if err != nil {
+		return err
+	}
+	if err := store.Delete(ctx, object.StorageKey); err != nil {
+		return err
+	}
+	return object.Delete()
+}
+
+// enforceUserMediaQuota 超出用户存储上限时删除最早的归档
+func enforceUserMediaQuota(ctx context.Context, userId int) {
+	limit := int64(operation_setting.GetMediaStorageSetting().MaxUserStorageMB) * 1024 * 1024
+	if limit <= 0 {
+		return
+	}
+	usage, err := model.GetUserMediaUsage(userId)
+	if err != nil || usage <= limit {
+		return
+	}
+	objects, err := model.GetUserOldestMediaObjects(userId, 50)
+	if err != nil {
+		return
+	}
+	for _, object := range objects {
+		if usage <= limit {
+			break
+		}
+		if err := DeleteMediaObject(ctx, object); err != nil {
+			common.SysError(fmt.Sprintf("failed to delete media object %d: %s", object.Id, err.Error()))
+			continue
+		}
+		usage -= object.Size
+	}
+}
+
+func mediaSigningKey() []byte {
+	if secret := operation_setting.GetMediaStorageSetting().SigningSecret; secret != "" {
+		return []byte(secret)
+	}
+	return []byte(common.CryptoSecret)
+}
+
+func signMedia(id int, expires int64) string {
+	return common.GenerateHMACWithKey(mediaSigningKey(), fmt.Sprintf("%d:%d", id, expires))
+}
+
+// SignMediaURL 生成带过期时间的网关签名链接
+func SignMediaURL(id int) string {
+	expiry := operation_setting.GetMediaStorageSetting().SignedUrlExpiry
+	if expiry <= 0 {
+		expiry = 3600
+	}
+	expires := time.Now().Unix() + int64(expiry)
+	return fmt.Sprintf("%s/v1/media/%d?expires=%d&signature=%s", system_setting.ServerAddress, id, expires, signMedia(id, expires))
+}
+
+// VerifyMediaSignature 校验签名链接
+func VerifyMediaSignature(id int, expiresStr string, signature string) bool {
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil || expires < time.Now().Unix() {
+		return false
+	}
+	return signature != "" && hmac.Equal([]byte(signature), []byte(signMedia(id, expires)))
+}
+
+// ServeMediaObject 输出归档文件
+func ServeMediaObject(w http.ResponseWriter, r *http.Request, object *model.MediaObject) error {
+	store, err := GetMediaStore(object.Backend)
+	if err != nil {
+		return err
+	}
+	return store.Serve(w, r, object.StorageKey, object.ContentType)
+}
+
+// AutomaticallyCleanMediaObjects 定期删除超过保留期的归档
+func AutomaticallyCleanMediaObjects() {
+	for {
+		time.Sleep(10 * time.Minute)
+		objects, err := model.GetExpiredMediaObjects(time.Now().Unix(), 200)
+		if err != nil {
+			common.SysError("failed to get expired media objects: " + err.Error())
+			continue
+		}
+		for _, object := range objects {
+			if err := DeleteMediaObject(context.Background(), object); err != nil {
+				common.SysError(fmt.Sprintf("failed to delete media object %d: %s", object.Id, err.Error()))
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+func TestVerifyMediaSignature(t *testing.T) {
+	expires := time.Now().Unix() + 60
+	expiresStr := strconv.FormatInt(expires, 10)
+	valid := signMedia(1, expires)
+	tests := []struct {
+		name      string
+		id        int
+		expires   string
+		signature string
+		want      bool
+	}{
+		{name: "valid", id: 1, expires: expiresStr, signature: valid, want: true},
+		{name: "other object", id: 2, expires: expiresStr, signature: valid, want: false},
+		{name: "tampered", id: 1, expires: expiresStr, signature: valid[:len(valid)-1] + "x", want: false},
+		{name: "empty signature", id: 1, expires: expiresStr, signature: "", want: false},
+		{name: "expired", id: 1, expires: strconv.FormatInt(time.Now().Unix()-1, 10), signature: signMedia(1, time.Now().Unix()-1), want: false},
+		{name: "invalid expires", id: 1, expires: "abc", signature: valid, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyMediaSignature(tt.id, tt.expires, tt.signature); got != tt.want {
+				t.Fatalf("VerifyMediaSignature = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadMediaSSRF(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://blocked.example/image.png", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("png"))
+	}))
+	defer server.Close()
+
+	fetchSetting := system_setting.GetFetchSetting()
+	saved := *fetchSetting
+	t.Cleanup(func() { *fetchSetting = saved })
+
+	tests := []struct {
+		name           string
+		allowPrivateIp bool
+		path           string
+		wantErr        string
+	}{
+		{name: "private address rejected", path: "/image.png", wantErr: "request reject"},
+		{name: "private address allowed", allowPrivateIp: true, path: "/image.png"},
+		{name: "redirect target checked", allowPrivateIp: true, path: "/redirect", wantErr: "request reject"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetchSetting.EnableSSRFProtection = true
+			fetchSetting.AllowPrivateIp = tt.allowPrivateIp
+			fetchSetting.DomainFilterMode = false
+			fetchSetting.DomainList = []string{"blocked.example"}
+			fetchSetting.AllowedPorts = nil
+
+			tmp, err := os.CreateTemp(t.TempDir(), "media-*")
+			if err != nil {
+				t.Fatalf("create temp: %v", err)
+			}
+			defer tmp.Close()
+			contentType, size, _, err := downloadMedia(context.Background(), MediaArchiveRequest{OriginUrl: server.URL + tt.path}, tmp, 1024)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("downloadMedia error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("downloadMedia: %v", err)
+			}
+			if contentType != "image/png" || size != 3 {
+				t.Fatalf("downloadMedia = %s %d, want image/png 3", contentType, size)
+			}
+		})
+	}
+}
@@ -162,6 +162,30 @@ func ConvertSimpleChangeParams(content string) *dto.MidjourneyRequest {
 	return changeParams
 }
 
+// FilterMidjourneyRequestBody 按配置移除不透传上游的字段并清理 prompt 中的模式参数
+func FilterMidjourneyRequestBody(mapResult map[string]interface{}) {
+	if mapResult == nil {
+		return
+	}
+	if !setting.MjAccountFilterEnabled {
+		delete(mapResult, "accountFilter")
+	}
+	if !setting.MjNotifyEnabled {
+		delete(mapResult, "notifyHook")
+	}
+	// 回调由网关投递，回调参数不透传上游
+	StripTaskCallbackFields(mapResult)
+	if setting.MjModeClearEnabled {
+		if prompt, ok := mapResult["prompt"].(string); ok {
+			prompt = strings.Replace(prompt, "--fast", "", -1)
+			prompt = strings.Replace(prompt, "--relax", "", -1)
+			prompt = strings.Replace(prompt, "--turbo", "", -1)
+
+			mapResult["prompt"] = prompt
+		}
+	}
+}
+
 func DoMidjourneyHttpRequest(c *gin.Context, timeout time.Duration, fullRequestURL string) (*dto.MidjourneyResponseWithStatusCode, []byte, error) {
 	var nullBytes []byte
 	//var requestBody io.Reader
@@ -174,24 +198,10 @@ func DoMidjourneyHttpRequest(c *gin.Context, timeout time.Duration, fullRequestU
 		if err != nil {
 			return MidjourneyErrorWithStatusCodeWrapper(constant.MjErrorUnknown, "read_request_body_failed", http.StatusInternalServerError), nullBytes, err
 		}
-		if !setting.MjAccountFilterEnabled {
-			delete(mapResult, "accountFilter")
-		}
-		if !setting.MjNotifyEnabled {
-			delete(mapResult, "notifyHook")
-		}
 		//req, err := http.NewRequest(c.Request.Method, fullRequestURL, requestBody)
 		// make new request with mapResult
 	}
-	if setting.MjModeClearEnabled {
-		if prompt, ok := mapResult["prompt"].(string); ok {
-			prompt = strings.Replace(prompt, "--fast", "", -1)
-			prompt = strings.Replace(prompt, "--relax", "", -1)
-			prompt = strings.Replace(prompt, "--turbo", "", -1)
-
-			mapResult["prompt"] = prompt
-		}
-	}
+	FilterMidjourneyRequestBody(mapResult)
 	reqBody, err := json.Marshal(mapResult)
 	if err != nil {
 		return MidjourneyErrorWithStatusCodeWrapper(constant.MjErrorUnknown, "marshal_request_body_failed", http.StatusInternalServerError), nullBytes, err
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+const (
+	// substitutionMinEstimatedTokens 估算 token 过少时比例受固定开销影响较大，不计入样本
+	substitutionMinEstimatedTokens = 100
+	// substitutionMaxSamples 每个渠道模型保留的 token 比例样本数
+	substitutionMaxSamples = 200
+	// substitutionMinPeers 同一模型至少需要多少个渠道才进行横向比较
+	substitutionMinPeers = 3
+)
+
+// ModelSubstitutionStat 单个渠道单个模型的替换检测统计
+type ModelSubstitutionStat struct {
+	ChannelId int    `json:"channel_id"`
+	Model     string `json:"model"`
+	// 实际请求中上游上报与本地估算的提示词 token 比例
+	TokenRatioSamples int     `json:"token_ratio_samples"`
+	TokenRatioMedian  float64 `json:"token_ratio_median"`
+	// 探测结果
+	ProbeCount         int         `json:"probe_count"`
+	ModelMismatches    int         `json:"model_mismatches"` // 连续次数
+	WrongAnswers       int         `json:"wrong_answers"`    // 连续次数
+	ProbePromptTokens  map[int]int `json:"probe_prompt_tokens"`
+	LastResponseModel  string      `json:"last_response_model"`
+	LastFingerprint    string      `json:"last_fingerprint"`
+	FingerprintChanges int         `json:"fingerprint_changes"`
+	LastProbeTime      int64       `json:"last_probe_time"`
+	Flagged            bool        `json:"flagged"`
+}
+
+type substitutionStat struct {
+	ModelSubstitutionStat
+	ratios []float64
+}
+
+var (
+	substitutionStatsLock sync.Mutex
+	substitutionStats     = make(map[string]*substitutionStat)
+)
+
+func getSubstitutionStat(channelId int, modelName string) *substitutionStat {
+	key := fmt.Sprintf("%d|%s", channelId, modelName)
+	stat, ok := substitutionStats[key]
+	if !ok {
+		stat = &substitutionStat{
+			ModelSubstitutionStat: ModelSubstitutionStat{
+				ChannelId:         channelId,
+				Model:             modelName,
+				ProbePromptTokens: make(map[int]int),
+			},
+		}
+		substitutionStats[key] = stat
+	}
+	return stat
+}
+
+// ObserveSubstitutionPromptTokens 记录实际请求中本地估算与上游上报的提示词 token 数，
+// 同一模型下渠道的比例明显偏离其他渠道时，可能使用了不同的分词器，即不同的模型
+func ObserveSubstitutionPromptTokens(channelId int, modelName string, estimated int, reported int) {
+	if !operation_setting.GetModelSubstitutionSetting().Enabled {
+		return
+	}
+	if channelId == 0 || modelName == "" || estimated < substitutionMinEstimatedTokens || reported <= 0 {
+		return
+	}
+	substitutionStatsLock.Lock()
+	defer substitutionStatsLock.Unlock()
+	stat := getSubstitutionStat(channelId, modelName)
+	stat.ratios = append(stat.ratios, float64(reported)/float64(estimated))
+	if len(stat.ratios) > substitutionMaxSamples {
+		stat.ratios = stat.ratios[len(stat.ratios)-substitutionMaxSamples:]
+	}
+}
+
+// RecordSubstitutionProbe 记录一次探测结果：upstreamModel 为模型映射后实际请求的模型，responseModel 与 fingerprint 为上游返回值，
+// answerOk 表示回复是否符合预期答案，promptTokens 为上游上报的提示词 token 数
+func RecordSubstitutionProbe(channelId int, upstreamModel string, probeIdx int, responseModel string, fingerprint string, answerOk bool, promptTokens int) {
+	if upstreamModel == "" {
+		return
+	}
+	substitutionStatsLock.Lock()
+	defer substitutionStatsLock.Unlock()
+	stat := getSubstitutionStat(channelId, upstreamModel)
+	stat.ProbeCount++
+	stat.LastProbeTime = common.GetTimestamp()
+	if responseModel != "" {
+		stat.LastResponseModel = responseModel
+		if ResponseModelMatches(responseModel, upstreamModel) {
+			stat.ModelMismatches = 0
+		} else {
+			stat.ModelMismatches++
+		}
+	}
+	if answerOk {
+		stat.WrongAnswers = 0
+	} else {
+		stat.WrongAnswers++
+	}
+	if fingerprint != "" {
+		if stat.LastFingerprint != "" && stat.LastFingerprint != fingerprint {
+			stat.FingerprintChanges++
+		}
+		stat.LastFingerprint = fingerprint
+	}
+	if promptTokens > 0 {
+		stat.ProbePromptTokens[probeIdx] = promptTokens
+	}
+}
+
+func substitutionMedian(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+func (s *substitutionStat) snapshot() ModelSubstitutionStat {
+	snapshot := s.ModelSubstitutionStat
+	snapshot.TokenRatioSamples = len(s.ratios)
+	snapshot.TokenRatioMedian = math.Round(substitutionMedian(s.ratios)*1000) / 1000
+	snapshot.ProbePromptTokens = make(map[int]int, len(s.ProbePromptTokens))
+	for k, v := range s.ProbePromptTokens {
+		snapshot.ProbePromptTokens[k] = v
+	}
+	return snapshot
+}
+
+// GetModelSubstitutionStats 当前节点的替换检测统计
+func GetModelSubstitutionStats() []ModelSubstitutionStat {
+	substitutionStatsLock.Lock()
+	defer substitutionStatsLock.Unlock()
+	stats := make([]ModelSubstitutionStat, 0, len(substitutionStats))
+	for _, stat := range substitutionStats {
+		stats = append(stats, stat.snapshot())
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].ChannelId != stats[j].ChannelId {
+			return stats[i].ChannelId < stats[j].ChannelId
+		}
+		return stats[i].Model < stats[j].Model
+	})
+	return stats
+}
+
+// ResetModelSubstitutionStats 清除渠道的统计，用于管理员确认渠道正常后重新检测
+func ResetModelSubstitutionStats(channelId int) {
+	substitutionStatsLock.Lock()
+	defer substitutionStatsLock.Unlock()
+	for key, stat := range substitutionStats {
+		if stat.ChannelId == channelId {
+			delete(substitutionStats, key)
+		}
+	}
+}
+
+// deviatesFromPeers 与同模型其他渠道的中位数相比偏差是否超过阈值
+func deviatesFromPeers(value float64, peers []float64, deviation float64) (bool, float64) {
+	if len(peers) < substitutionMinPeers {
+		return false, 0
+	}
+	median := substitutionMedian(peers)
+	if median <= 0 {
+		return false, median
+	}
+	return math.Abs(value-median)/median > deviation, median
+}
+
+// EvaluateModelSubstitution 根据统计判断渠道是否疑似替换了上游模型，新标记的渠道写入 other_info 并通知管理员
+func EvaluateModelSubstitution() {
+	setting := operation_setting.GetModelSubstitutionSetting()
+	threshold := setting.FailureThreshold
+	if threshold <= 0 {
+		threshold = 1
+	}
+
+	substitutionStatsLock.Lock()
+	byModel := make(map[string][]*substitutionStat)
+	for _, stat := range substitutionStats {
+		byModel[stat.Model] = append(byModel[stat.Model], stat)
+	}
+	flags := make(map[*substitutionStat]*model.ChannelSubstitutionFlag)
+	for modelName, stats := range byModel {
+		ratioPeers := make([]float64, 0, len(stats))
+		probePeers := make(map[int][]float64)
+		for _, stat := range stats {
+			if len(stat.ratios) >= setting.MinSamples {
+				ratioPeers = append(ratioPeers, substitutionMedian(stat.ratios))
+			}
+			for idx, tokens := range stat.ProbePromptTokens {
+				probePeers[idx] = append(probePeers[idx], float64(tokens))
+			}
+		}
+		for _, stat := range stats {
+			if stat.Flagged {
+				continue
+			}
+			reasons := make([]string, 0)
+			if stat.ModelMismatches >= threshold {
+				reasons = append(reasons, fmt.Sprintf("连续 %d 次探测返回的模型为 %s", stat.ModelMismatches, stat.LastResponseModel))
+			}
+			if stat.WrongAnswers >= threshold {
+				reasons = append(reasons, fmt.Sprintf("连续 %d 次探测答案错误", stat.WrongAnswers))
+			}
+			if len(stat.ratios) >= setting.MinSamples {
+				ratio := substitutionMedian(stat.ratios)
+				if deviates, median := deviatesFromPeers(ratio, ratioPeers, setting.TokenDeviation); deviates {
+					reasons = append(reasons, fmt.Sprintf("提示词 token 比例 %.3f 偏离同模型渠道中位数 %.3f", ratio, median))
+				}
+			}
+			for idx, tokens := range stat.ProbePromptTokens {
+				if deviates, median := deviatesFromPeers(float64(tokens), probePeers[idx], setting.TokenDeviation); deviates {
+					reasons = append(reasons, fmt.Sprintf("第 %d 条探测的提示词 token 数 %d 偏离同模型渠道中位数 %.0f", idx+1, tokens, median))
+				}
+			}
+			if len(reasons) > 0 {
+				stat.Flagged = true
+				flags[stat] = &model.ChannelSubstitutionFlag{
+					Model:   modelName,
+					Reasons: reasons,
+					Time:    common.GetTimestamp(),
+				}
+			}
+		}
+	}
+	substitutionStatsLock.Unlock()
+
+	for stat, flag := range flags {
+		channel, err := model.GetChannelById(stat.ChannelId, false)
+		if err != nil {
+			continue
+		}
+		alreadyFlagged := channel.GetSubstitutionFlag() != nil
+		if err := model.SetChannelSubstitutionFlag(stat.ChannelId, flag); err != nil {
+			common.SysLog(fmt.Sprintf("failed to set channel substitution flag: channel_id=%d, error=%v", stat.ChannelId, err))
+			continue
+		}
+		common.SysLog(fmt.Sprintf("channel #%d model %s suspected of model substitution: %s", stat.ChannelId, flag.Model, strings.Join(flag.Reasons, "; ")))
+		if setting.NotifyEnabled && !alreadyFlagged {
+			subject := fmt.Sprintf("渠道「%s」（#%d）疑似替换模型 %s", channel.Name, channel.Id, flag.Model)
+			content := fmt.Sprintf("渠道「%s」（#%d）的模型 %s 行为异常：%s", channel.Name, channel.Id, flag.Model, strings.Join(flag.Reasons, "；"))
+			NotifyRootUser(dto.NotifyTypeChannelUpdate, subject, content)
+		}
+	}
+}
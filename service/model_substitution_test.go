@@ -0,0 +1,49 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestRecordSubstitutionProbeComparesUpstreamModel(t *testing.T) {
+	tests := []struct {
+		name           string
+		upstreamModel  string
+		responseModel  string
+		wantRecorded   bool
+		wantMismatches int
+	}{
+		// 渠道把 my-gpt 映射为 gpt-4o，上游返回带日期的 gpt-4o 不算替换
+		{name: "mapped model with version suffix", upstreamModel: "gpt-4o", responseModel: "gpt-4o-2024-08-06", wantRecorded: true, wantMismatches: 0},
+		{name: "different model", upstreamModel: "gpt-4o", responseModel: "gpt-4o-mini", wantRecorded: true, wantMismatches: 1},
+		{name: "latest alias", upstreamModel: "claude-3-5-sonnet", responseModel: "claude-3-5-sonnet-latest", wantRecorded: true, wantMismatches: 0},
+		{name: "gemini models prefix", upstreamModel: "gemini-2.5-pro", responseModel: "models/gemini-2.5-pro", wantRecorded: true, wantMismatches: 0},
+		// 映射前失败时没有上游模型，不计入统计
+		{name: "no upstream model", upstreamModel: "", responseModel: "gpt-4o", wantRecorded: false},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channelId := 95000 + i
+			t.Cleanup(func() { ResetModelSubstitutionStats(channelId) })
+			RecordSubstitutionProbe(channelId, tt.upstreamModel, 0, tt.responseModel, "fp", true, 12)
+			var found *ModelSubstitutionStat
+			for _, stat := range GetModelSubstitutionStats() {
+				if stat.ChannelId == channelId {
+					found = &stat
+					break
+				}
+			}
+			if !tt.wantRecorded {
+				if found != nil {
+					t.Fatalf("recorded stat %+v, want none", *found)
+				}
+				return
+			}
+			if found == nil {
+				t.Fatal("stat not recorded")
+			}
+			if found.Model != tt.upstreamModel || found.ModelMismatches != tt.wantMismatches {
+				t.Fatalf("stat model = %s mismatches = %d, want %s %d", found.Model, found.ModelMismatches, tt.upstreamModel, tt.wantMismatches)
+			}
+		})
+	}
+}
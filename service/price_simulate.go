@@ -0,0 +1,338 @@
+package service
+
+import (
+	"sort"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/shopspring/decimal"
+)
+
+// PriceSimulateUsage 计费模拟使用的用量
+type PriceSimulateUsage struct {
+	ModelName           string `json:"model_name"`
+	PromptTokens        int    `json:"prompt_tokens"`
+	CompletionTokens    int    `json:"completion_tokens"`
+	CachedTokens        int    `json:"cached_tokens"`
+	CacheCreationTokens int    `json:"cache_creation_tokens"`
+	ImageTokens         int    `json:"image_tokens"`
+	AudioInputTokens    int    `json:"audio_input_tokens"`
+	AudioOutputTokens   int    `json:"audio_output_tokens"`
+}
+
+// PriceBreakdown 计费明细，各项额度均已乘以分组倍率
+type PriceBreakdown struct {
+	PriceSimulateUsage
+
+	UsePrice             bool    `json:"use_price"`
+	ModelPrice           float64 `json:"model_price"`
+	ModelRatio           float64 `json:"model_ratio"`
+	CompletionRatio      float64 `json:"completion_ratio"`
+	CacheRatio           float64 `json:"cache_ratio"`
+	CacheCreationRatio   float64 `json:"cache_creation_ratio"`
+	ImageRatio           float64 `json:"image_ratio"`
+	AudioRatio           float64 `json:"audio_ratio"`
+	AudioCompletionRatio float64 `json:"audio_completion_ratio"`
+	GroupRatio           float64 `json:"group_ratio"`
+
+	InputQuota         int `json:"input_quota"`
+	CachedQuota        int `json:"cached_quota"`
+	CacheCreationQuota int `json:"cache_creation_quota"`
+	CompletionQuota    int `json:"completion_quota"`
+	ImageQuota         int `json:"image_quota"`
+	AudioQuota         int `json:"audio_quota"`
+	PerCallQuota       int `json:"per_call_quota"`
+	TotalQuota         int `json:"total_quota"`
+
+	AmountUSD      float64              `json:"amount_usd"`
+	Amount         float64              `json:"amount"`
+	CurrencySymbol string               `json:"currency_symbol"`
+	PriceTier      *types.PriceTierInfo `json:"price_tier,omitempty"`
+}
+
+// QuotaToAmount 将额度换算为美元与站点展示货币
+func QuotaToAmount(quota int) (usd float64, amount float64) {
+	usd = decimal.NewFromInt(int64(quota)).Div(decimal.NewFromFloat(common.QuotaPerUnit)).Round(6).InexactFloat64()
+	rate := operation_setting.GetUsdToCurrencyRate(operation_setting.USDExchangeRate)
+	amount = decimal.NewFromFloat(usd).Mul(decimal.NewFromFloat(rate)).Round(6).InexactFloat64()
+	return usd, amount
+}
+
+// CalculatePriceBreakdown 按与结算一致的口径计算计费明细，不产生任何扣费
+func CalculatePriceBreakdown(priceData types.PriceData, usage PriceSimulateUsage) PriceBreakdown {
+	breakdown := PriceBreakdown{
+		PriceSimulateUsage:   usage,
+		UsePrice:             priceData.UsePrice,
+		ModelPrice:           priceData.ModelPrice,
+		ModelRatio:           priceData.ModelRatio,
+		CompletionRatio:      priceData.CompletionRatio,
+		CacheRatio:           priceData.CacheRatio,
+		CacheCreationRatio:   priceData.CacheCreationRatio,
+		ImageRatio:           priceData.ImageRatio,
+		AudioRatio:           priceData.AudioRatio,
+		AudioCompletionRatio: priceData.AudioCompletionRatio,
+		GroupRatio:           priceData.GroupRatioInfo.GroupRatio,
+		CurrencySymbol:       operation_setting.GetCurrencySymbol(),
+		PriceTier:            priceData.TierInfo,
+	}
+	toQuota := func(d decimal.Decimal) int {
+		return int(d.Round(0).IntPart())
+	}
+
+	var quota QuotaBreakdown
+	totalQuota := 0
+	geminiAudioPrice := operation_setting.GetGeminiInputAudioPricePerMillionTokens(usage.ModelName)
+	if (usage.AudioInputTokens > 0 || usage.AudioOutputTokens > 0) && geminiAudioPrice <= 0 {
+		// 含音频的请求按音频结算口径计费
+		quotaInfo := QuotaInfo{
+			InputDetails: TokenDetails{
+				TextTokens:  usage.PromptTokens - usage.AudioInputTokens,
+				AudioTokens: usage.AudioInputTokens,
+			},
+			OutputDetails: TokenDetails{
+				TextTokens:  usage.CompletionTokens - usage.AudioOutputTokens,
+				AudioTokens: usage.AudioOutputTokens,
+			},
+			ModelName:  usage.ModelName,
+			UsePrice:   priceData.UsePrice,
+			ModelPrice: priceData.ModelPrice,
+			ModelRatio: priceData.ModelRatio,
+			GroupRatio: priceData.GroupRatioInfo.GroupRatio,
+		}
+		if priceData.TierInfo != nil {
+			quotaInfo.CompletionRatio = priceData.CompletionRatio
+		}
+		quota = calculateAudioQuotaBreakdown(quotaInfo)
+		totalQuota = calculateAudioQuota(quotaInfo)
+	} else {
+		textUsage := TextQuotaUsage{
+			PromptTokens:        usage.PromptTokens,
+			CompletionTokens:    usage.CompletionTokens,
+			CachedTokens:        usage.CachedTokens,
+			CacheCreationTokens: usage.CacheCreationTokens,
+			ImageTokens:         usage.ImageTokens,
+			AudioInputTokens:    usage.AudioInputTokens,
+		}
+		if !priceData.UsePrice {
+			textUsage.AudioInputPrice = geminiAudioPrice
+		}
+		quota = CalculateTextQuota(priceData, textUsage)
+		totalQuota = toQuota(quota.Total)
+	}
+	breakdown.InputQuota = toQuota(quota.Input)
+	breakdown.CachedQuota = toQuota(quota.Cached)
+	breakdown.CacheCreationQuota = toQuota(quota.CacheCreation)
+	breakdown.ImageQuota = toQuota(quota.Image)
+	breakdown.CompletionQuota = toQuota(quota.Completion)
+	breakdown.AudioQuota = toQuota(quota.Audio)
+	breakdown.PerCallQuota = toQuota(quota.PerCall)
+	// 与结算一致：没有任何用量时不计费
+	if usage.PromptTokens+usage.CompletionTokens > 0 {
+		breakdown.TotalQuota = totalQuota
+	}
+	breakdown.AmountUSD, breakdown.Amount = QuotaToAmount(breakdown.TotalQuota)
+	return breakdown
+}
+
+// RepriceConfig 拟调整的倍率配置，未出现在配置中的模型/分组沿用日志中的原倍率
+type RepriceConfig struct {
+	ModelRatio      map[string]float64 `json:"model_ratio"`
+	CompletionRatio map[string]float64 `json:"completion_ratio"`
+	CacheRatio      map[string]float64 `json:"cache_ratio"`
+	ModelPrice      map[string]float64 `json:"model_price"`
+	GroupRatio      map[string]float64 `json:"group_ratio"`
+}
+
+type RepriceLogFilter struct {
+	StartTimestamp int64  `json:"start_timestamp"`
+	EndTimestamp   int64  `json:"end_timestamp"`
+	ModelName      string `json:"model_name"`
+	Username       string `json:"username"`
+	ChannelId      int    `json:"channel"`
+	Group          string `json:"group"`
+	Limit          int    `json:"limit"`
+}
+
+type RepriceModelStat struct {
+	ModelName   string  `json:"model_name"`
+	Count       int     `json:"count"`
+	OldQuota    int     `json:"old_quota"`
+	NewQuota    int     `json:"new_quota"`
+	DeltaQuota  int     `json:"delta_quota"`
+	OldAmount   float64 `json:"old_amount"`
+	NewAmount   float64 `json:"new_amount"`
+	DeltaAmount float64 `json:"delta_amount"`
+}
+
+type RepriceResult struct {
+	RepriceModelStat
+	Skipped   int                 `json:"skipped"`
+	Truncated bool                `json:"truncated"`
+	Models    []*RepriceModelStat `json:"models"`
+}
+
+const (
+	repriceBatchSize    = 1000
+	RepriceDefaultLimit = 10000
+	RepriceMaxLimit     = 100000
+)
+
+func (s *RepriceModelStat) add(oldQuota, newQuota int) {
+	s.Count++
+	s.OldQuota += oldQuota
+	s.NewQuota += newQuota
+}
+
+func (s *RepriceModelStat) finish() {
+	s.DeltaQuota = s.NewQuota - s.OldQuota
+	_, s.OldAmount = QuotaToAmount(s.OldQuota)
+	_, s.NewAmount = QuotaToAmount(s.NewQuota)
+	s.DeltaAmount = decimal.NewFromFloat(s.NewAmount).Sub(decimal.NewFromFloat(s.OldAmount)).Round(6).InexactFloat64()
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func otherFloat(other map[string]interface{}, key string, defaultValue float64) float64 {
+	if v, ok := other[key].(float64); ok {
+		return v
+	}
+	return defaultValue
+}
+
+// repriceLog 按新配置重算单条消费日志，返回新额度；无法识别计费参数时返回 false
+// 新额度 = 原额度 + (新倍率下的 token 费用 - 原倍率下的 token 费用)，工具调用等附加费用保持不变
+func repriceLog(log *model.Log, config RepriceConfig) (int, bool) {
+	if log.Other == "" {
+		return 0, false
+	}
+	other, err := common.StrToMap(log.Other)
+	if err != nil || other == nil {
+		return 0, false
+	}
+	if _, ok := other["model_ratio"]; !ok {
+		return 0, false
+	}
+
+	oldGroupRatio := otherFloat(other, "group_ratio", 1)
+	newGroupRatio := oldGroupRatio
+	// 用户分组特殊倍率不受分组倍率调整影响
+	if otherFloat(other, "user_group_ratio", -1) == -1 {
+		if v, ok := config.GroupRatio[log.Group]; ok {
+			newGroupRatio = v
+		}
+	}
+
+	oldModelPrice := otherFloat(other, "model_price", -1)
+	if oldModelPrice != -1 {
+		newModelPrice := oldModelPrice
+		if v, ok := config.ModelPrice[log.ModelName]; ok {
+			newModelPrice = v
+		}
+		oldCost := decimal.NewFromFloat(oldModelPrice).Mul(decimal.NewFromFloat(oldGroupRatio))
+		newCost := decimal.NewFromFloat(newModelPrice).Mul(decimal.NewFromFloat(newGroupRatio))
+		delta := newCost.Sub(oldCost).Mul(decimal.NewFromFloat(common.QuotaPerUnit))
+		return common.Max(log.Quota+int(delta.Round(0).IntPart()), 0), true
+	}
+
+	oldModelRatio := otherFloat(other, "model_ratio", 0)
+	oldCompletionRatio := otherFloat(other, "completion_ratio", 1)
+	oldCacheRatio := otherFloat(other, "cache_ratio", 1)
+	newModelRatio, newCompletionRatio, newCacheRatio := oldModelRatio, oldCompletionRatio, oldCacheRatio
+	if v, ok := config.ModelRatio[log.ModelName]; ok {
+		newModelRatio = v
+	}
+	if v, ok := config.CompletionRatio[log.ModelName]; ok {
+		newCompletionRatio = v
+	}
+	if v, ok := config.CacheRatio[log.ModelName]; ok {
+		newCacheRatio = v
+	}
+
+	cacheTokens := int64(otherFloat(other, "cache_tokens", 0))
+	cacheCreationTokens := int64(otherFloat(other, "cache_creation_tokens", 0))
+	cacheCreationRatio := otherFloat(other, "cache_creation_ratio", 1)
+	baseTokens := int64(log.PromptTokens)
+	// claude 格式的 prompt tokens 已不包含缓存部分
+	if isClaude, _ := other["claude"].(bool); !isClaude {
+		baseTokens -= cacheTokens + cacheCreationTokens
+	}
+	if baseTokens < 0 {
+		baseTokens = 0
+	}
+
+	cost := func(modelRatio, completionRatio, cacheRatio, groupRatio float64) decimal.Decimal {
+		tokens := decimal.NewFromInt(baseTokens).
+			Add(decimal.NewFromInt(cacheTokens).Mul(decimal.NewFromFloat(cacheRatio))).
+			Add(decimal.NewFromInt(cacheCreationTokens).Mul(decimal.NewFromFloat(cacheCreationRatio))).
+			Add(decimal.NewFromInt(int64(log.CompletionTokens)).Mul(decimal.NewFromFloat(completionRatio)))
+		return tokens.Mul(decimal.NewFromFloat(modelRatio)).Mul(decimal.NewFromFloat(groupRatio))
+	}
+	delta := cost(newModelRatio, newCompletionRatio, newCacheRatio, newGroupRatio).
+		Sub(cost(oldModelRatio, oldCompletionRatio, oldCacheRatio, oldGroupRatio))
+	return common.Max(log.Quota+int(delta.Round(0).IntPart()), 0), true
+}
+
+// RepriceLogs 按拟调整的倍率配置重算历史消费日志，用于评估调价对收入的影响
+func RepriceLogs(filter RepriceLogFilter, config RepriceConfig) (*RepriceResult, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = RepriceDefaultLimit
+	}
+	if limit > RepriceMaxLimit {
+		limit = RepriceMaxLimit
+	}
+
+	result := &RepriceResult{}
+	modelStats := make(map[string]*RepriceModelStat)
+	lastId := 0
+	processed := 0
+	for processed < limit {
+		batchSize := min(repriceBatchSize, limit-processed)
+		logs, err := model.GetConsumeLogsAfterId(lastId, batchSize, filter.StartTimestamp, filter.EndTimestamp,
+			filter.ModelName, filter.Username, filter.ChannelId, filter.Group)
+		if err != nil {
+			return nil, err
+		}
+		for _, log := range logs {
+			lastId = log.Id
+			processed++
+			newQuota, ok := repriceLog(log, config)
+			if !ok {
+				result.Skipped++
+				continue
+			}
+			stat, exists := modelStats[log.ModelName]
+			if !exists {
+				stat = &RepriceModelStat{ModelName: log.ModelName}
+				modelStats[log.ModelName] = stat
+			}
+			stat.add(log.Quota, newQuota)
+			result.add(log.Quota, newQuota)
+		}
+		if len(logs) < batchSize {
+			break
+		}
+		if processed >= limit {
+			result.Truncated = true
+		}
+	}
+
+	result.Models = make([]*RepriceModelStat, 0, len(modelStats))
+	for _, stat := range modelStats {
+		stat.finish()
+		result.Models = append(result.Models, stat)
+	}
+	sort.Slice(result.Models, func(i, j int) bool {
+		return absInt(result.Models[i].DeltaQuota) > absInt(result.Models[j].DeltaQuota)
+	})
+	result.finish()
+	return result, nil
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/shopspring/decimal"
+)
+
+// TextQuotaUsage 文本结算用量，口径与上游返回的 usage 一致（prompt tokens 包含缓存、图片与音频部分）
+type TextQuotaUsage struct {
+	PromptTokens        int
+	CompletionTokens    int
+	CachedTokens        int
+	CacheCreationTokens int
+	ImageTokens         int
+	AudioInputTokens    int
+	// AudioInputPrice 音频输入独立单价（美元/百万 tokens），为 0 时音频输入按文本计费
+	AudioInputPrice float64
+}
+
+// QuotaBreakdown 结算额度明细，各项均已乘以分组倍率，Total 不含工具调用等附加费用
+type QuotaBreakdown struct {
+	Input         decimal.Decimal
+	Cached        decimal.Decimal
+	CacheCreation decimal.Decimal
+	Image         decimal.Decimal
+	Completion    decimal.Decimal
+	Audio         decimal.Decimal
+	PerCall       decimal.Decimal
+	Total         decimal.Decimal
+}
+
+// CalculateTextQuota 计算文本请求的结算额度，实际结算与计费模拟共用
+func CalculateTextQuota(priceData types.PriceData, usage TextQuotaUsage) QuotaBreakdown {
+	var breakdown QuotaBreakdown
+	dGroupRatio := decimal.NewFromFloat(priceData.GroupRatioInfo.GroupRatio)
+	dQuotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
+	if priceData.UsePrice {
+		breakdown.PerCall = decimal.NewFromFloat(priceData.ModelPrice).Mul(dQuotaPerUnit).Mul(dGroupRatio)
+		breakdown.Total = breakdown.PerCall
+		return breakdown
+	}
+
+	ratio := decimal.NewFromFloat(priceData.ModelRatio).Mul(dGroupRatio)
+	dCacheTokens := decimal.NewFromInt(int64(usage.CachedTokens))
+	dCacheCreationTokens := decimal.NewFromInt(int64(usage.CacheCreationTokens))
+	dImageTokens := decimal.NewFromInt(int64(usage.ImageTokens))
+	baseTokens := decimal.NewFromInt(int64(usage.PromptTokens)).Sub(dCacheTokens).Sub(dCacheCreationTokens).Sub(dImageTokens)
+	// Gemini 音频输入按独立单价计费
+	if usage.AudioInputTokens > 0 && usage.AudioInputPrice > 0 {
+		dAudioTokens := decimal.NewFromInt(int64(usage.AudioInputTokens))
+		baseTokens = baseTokens.Sub(dAudioTokens)
+		breakdown.Audio = decimal.NewFromFloat(usage.AudioInputPrice).Div(decimal.NewFromInt(1000000)).Mul(dAudioTokens).Mul(dGroupRatio).Mul(dQuotaPerUnit)
+	}
+
+	breakdown.Input = baseTokens.Mul(ratio)
+	breakdown.Cached = dCacheTokens.Mul(decimal.NewFromFloat(priceData.CacheRatio)).Mul(ratio)
+	breakdown.CacheCreation = dCacheCreationTokens.Mul(decimal.NewFromFloat(priceData.CacheCreationRatio)).Mul(ratio)
+	breakdown.Image = dImageTokens.Mul(decimal.NewFromFloat(priceData.ImageRatio)).Mul(ratio)
+	breakdown.Completion = decimal.NewFromInt(int64(usage.CompletionTokens)).Mul(decimal.NewFromFloat(priceData.CompletionRatio)).Mul(ratio)
+
+	breakdown.Total = breakdown.Input.Add(breakdown.Cached).Add(breakdown.CacheCreation).Add(breakdown.Image).Add(breakdown.Completion)
+	if !ratio.IsZero() && breakdown.Total.LessThanOrEqual(decimal.Zero) {
+		breakdown.Total = decimal.NewFromInt(1)
+	}
+	breakdown.Total = breakdown.Total.Add(breakdown.Audio)
+	return breakdown
+}
+
+// calculateAudioQuotaBreakdown 计算音频请求的结算额度明细
+func calculateAudioQuotaBreakdown(info QuotaInfo) QuotaBreakdown {
+	var breakdown QuotaBreakdown
+	if info.UsePrice {
+		modelPrice := decimal.NewFromFloat(info.ModelPrice)
+		quotaPerUnit := decimal.NewFromFloat(common.QuotaPerUnit)
+		groupRatio := decimal.NewFromFloat(info.GroupRatio)
+
+		breakdown.PerCall = modelPrice.Mul(quotaPerUnit).Mul(groupRatio)
+		breakdown.Total = breakdown.PerCall
+		return breakdown
+	}
+
+	completionRatio := decimal.NewFromFloat(ratio_setting.GetCompletionRatio(info.ModelName))
+	if info.CompletionRatio > 0 {
+		completionRatio = decimal.NewFromFloat(info.CompletionRatio)
+	}
+	audioRatio := decimal.NewFromFloat(ratio_setting.GetAudioRatio(info.ModelName))
+	audioCompletionRatio := decimal.NewFromFloat(ratio_setting.GetAudioCompletionRatio(info.ModelName))
+
+	groupRatio := decimal.NewFromFloat(info.GroupRatio)
+	modelRatio := decimal.NewFromFloat(info.ModelRatio)
+	ratio := groupRatio.Mul(modelRatio)
+
+	inputTextTokens := decimal.NewFromInt(int64(info.InputDetails.TextTokens))
+	outputTextTokens := decimal.NewFromInt(int64(info.OutputDetails.TextTokens))
+	inputAudioTokens := decimal.NewFromInt(int64(info.InputDetails.AudioTokens))
+	outputAudioTokens := decimal.NewFromInt(int64(info.OutputDetails.AudioTokens))
+
+	breakdown.Input = inputTextTokens.Mul(ratio)
+	breakdown.Completion = outputTextTokens.Mul(completionRatio).Mul(ratio)
+	breakdown.Audio = inputAudioTokens.Mul(audioRatio).
+		Add(outputAudioTokens.Mul(audioRatio).Mul(audioCompletionRatio)).
+		Mul(ratio)
+
+	breakdown.Total = breakdown.Input.Add(breakdown.Completion).Add(breakdown.Audio)
+	// If ratio is not zero and quota is less than or equal to zero, set quota to 1
+	if !ratio.IsZero() && breakdown.Total.LessThanOrEqual(decimal.Zero) {
+		breakdown.Total = decimal.NewFromInt(1)
+	}
+	return breakdown
+}
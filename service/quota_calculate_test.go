@@ -0,0 +1,116 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/types"
+)
+
+func TestCalculateTextQuota(t *testing.T) {
+	ratioPrice := types.PriceData{
+		ModelRatio:         2,
+		CompletionRatio:    4,
+		CacheRatio:         0.1,
+		CacheCreationRatio: 1.25,
+		ImageRatio:         2,
+		GroupRatioInfo:     types.GroupRatioInfo{GroupRatio: 0.5},
+	}
+	tests := []struct {
+		name      string
+		priceData types.PriceData
+		usage     TextQuotaUsage
+		wantTotal int64
+		wantAudio int64
+	}{
+		{
+			name:      "prompt and completion",
+			priceData: ratioPrice,
+			usage:     TextQuotaUsage{PromptTokens: 1000, CompletionTokens: 100},
+			// (1000 + 100*4) * 2 * 0.5
+			wantTotal: 1400,
+		},
+		{
+			name:      "cache, cache creation and image tokens",
+			priceData: ratioPrice,
+			usage:     TextQuotaUsage{PromptTokens: 1000, CachedTokens: 500, CacheCreationTokens: 200, ImageTokens: 100},
+			// (200 + 500*0.1 + 200*1.25 + 100*2) * 2 * 0.5
+			wantTotal: 700,
+		},
+		{
+			name:      "audio input without separate price",
+			priceData: ratioPrice,
+			usage:     TextQuotaUsage{PromptTokens: 1000, AudioInputTokens: 400},
+			wantTotal: 1000,
+		},
+		{
+			name:      "audio input with separate price",
+			priceData: ratioPrice,
+			usage:     TextQuotaUsage{PromptTokens: 1000, AudioInputTokens: 400, AudioInputPrice: 1},
+			// 600 * 2 * 0.5 + 1/1e6 * 400 * 0.5 * QuotaPerUnit
+			wantTotal: 600 + int64(400*0.5*common.QuotaPerUnit/1000000),
+			wantAudio: int64(400 * 0.5 * common.QuotaPerUnit / 1000000),
+		},
+		{
+			name:      "minimum one quota",
+			priceData: ratioPrice,
+			usage:     TextQuotaUsage{PromptTokens: 10, CachedTokens: 10},
+			wantTotal: 1,
+		},
+		{
+			name:      "per call price",
+			priceData: types.PriceData{UsePrice: true, ModelPrice: 0.02, GroupRatioInfo: types.GroupRatioInfo{GroupRatio: 2}},
+			usage:     TextQuotaUsage{PromptTokens: 1000, CompletionTokens: 100},
+			wantTotal: int64(0.02 * 2 * common.QuotaPerUnit),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CalculateTextQuota(tt.priceData, tt.usage)
+			if total := got.Total.Round(0).IntPart(); total != tt.wantTotal {
+				t.Errorf("Total = %d, want %d", total, tt.wantTotal)
+			}
+			if audio := got.Audio.Round(0).IntPart(); audio != tt.wantAudio {
+				t.Errorf("Audio = %d, want %d", audio, tt.wantAudio)
+			}
+		})
+	}
+}
+
+func TestCalculatePriceBreakdownMatchesSettlement(t *testing.T) {
+	priceData := types.PriceData{
+		ModelRatio:         1.5,
+		CompletionRatio:    3,
+		CacheRatio:         0.25,
+		CacheCreationRatio: 1.25,
+		ImageRatio:         1,
+		GroupRatioInfo:     types.GroupRatioInfo{GroupRatio: 0.8},
+	}
+	tests := []struct {
+		name  string
+		usage PriceSimulateUsage
+	}{
+		{"text", PriceSimulateUsage{ModelName: "gpt-4o", PromptTokens: 1234, CompletionTokens: 567}},
+		{"cached", PriceSimulateUsage{ModelName: "gpt-4o", PromptTokens: 3000, CompletionTokens: 10, CachedTokens: 2048, CacheCreationTokens: 500}},
+		{"tiny", PriceSimulateUsage{ModelName: "gpt-4o", PromptTokens: 1}},
+		{"empty", PriceSimulateUsage{ModelName: "gpt-4o"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breakdown := CalculatePriceBreakdown(priceData, tt.usage)
+			want := 0
+			if tt.usage.PromptTokens+tt.usage.CompletionTokens > 0 {
+				settled := CalculateTextQuota(priceData, TextQuotaUsage{
+					PromptTokens:        tt.usage.PromptTokens,
+					CompletionTokens:    tt.usage.CompletionTokens,
+					CachedTokens:        tt.usage.CachedTokens,
+					CacheCreationTokens: tt.usage.CacheCreationTokens,
+				})
+				want = int(settled.Total.Round(0).IntPart())
+			}
+			if breakdown.TotalQuota != want {
+				t.Errorf("TotalQuota = %d, want %d", breakdown.TotalQuota, want)
+			}
+		})
+	}
+}
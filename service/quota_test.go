@@ -0,0 +1,36 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+)
+
+func TestCalculateAudioQuotaTierCompletionRatio(t *testing.T) {
+	modelName := "gpt-4o-realtime-preview"
+	baseCompletionRatio := ratio_setting.GetCompletionRatio(modelName)
+	tests := []struct {
+		name            string
+		completionRatio float64
+		wantRatio       float64
+	}{
+		{name: "model completion ratio", completionRatio: 0, wantRatio: baseCompletionRatio},
+		{name: "tier completion ratio", completionRatio: 8, wantRatio: 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quota := calculateAudioQuota(QuotaInfo{
+				InputDetails:    TokenDetails{TextTokens: 1000},
+				OutputDetails:   TokenDetails{TextTokens: 1000},
+				ModelName:       modelName,
+				ModelRatio:      2,
+				GroupRatio:      1,
+				CompletionRatio: tt.completionRatio,
+			})
+			want := int((1000 + 1000*tt.wantRatio) * 2)
+			if quota != want {
+				t.Fatalf("quota = %d, want %d", quota, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	RealtimeDirectionClient = "client"
+	RealtimeDirectionServer = "server"
+)
+
+// RealtimeEventText 提取实时事件中需要审核的文本：客户端创建的会话条目与上游返回的完整转写
+func RealtimeEventText(event *dto.RealtimeEvent) string {
+	if event == nil {
+		return ""
+	}
+	switch event.Type {
+	case dto.RealtimeEventTypeConversationCreate, dto.RealtimeEventConversationItemCreated:
+		if event.Item == nil {
+			return ""
+		}
+		var sb strings.Builder
+		for _, content := range event.Item.Content {
+			if content.Text != "" {
+				sb.WriteString(content.Text)
+				sb.WriteString("\n")
+			}
+			if content.Transcript != "" {
+				sb.WriteString(content.Transcript)
+				sb.WriteString("\n")
+			}
+		}
+		return sb.String()
+	case dto.RealtimeEventInputAudioTranscriptionCompleted, dto.RealtimeEventResponseAudioTranscriptDone:
+		return event.Transcript
+	case dto.RealtimeEventResponseTextDone:
+		return event.Text
+	}
+	return ""
+}
+
+// CheckRealtimeSessionPrice 按次计价的模型每个会话只按价格计费一次，价格超过会话额度上限时拒绝建立会话；
+// 按倍率计价的模型在会话中按用量累计检查
+func CheckRealtimeSessionPrice(info *relaycommon.RelayInfo) *types.NewAPIError {
+	maxQuota := operation_setting.GetRealtimeSetting().MaxSessionQuota
+	if maxQuota <= 0 || !info.PriceData.UsePrice {
+		return nil
+	}
+	quota := calculateAudioQuota(QuotaInfo{
+		ModelName:  info.OriginModelName,
+		UsePrice:   true,
+		ModelPrice: info.PriceData.ModelPrice,
+		GroupRatio: info.PriceData.GroupRatioInfo.GroupRatio,
+	})
+	if quota <= maxQuota {
+		return nil
+	}
+	return types.NewErrorWithStatusCode(fmt.Errorf("realtime session price %s exceeds the max session cost of %s", logger.FormatQuota(quota), logger.FormatQuota(maxQuota)),
+		types.ErrorCodeModelPriceError, http.StatusForbidden, types.ErrOptionWithSkipRetry())
+}
+
+// CheckRealtimeEventSensitive 检查实时事件中的敏感词，未开启敏感词检查或实时审核时直接放行
+func CheckRealtimeEventSensitive(event *dto.RealtimeEvent) (bool, []string) {
+	if !setting.CheckSensitiveEnabled || !operation_setting.GetRealtimeSetting().ModerationEnabled {
+		return false, nil
+	}
+	text := RealtimeEventText(event)
+	if text == "" {
+		return false, nil
+	}
+	return CheckSensitiveText(text)
+}
+
+// RealtimeRecorder 按会话记录实时事件（JSON Lines），音频数据只记录长度
+type RealtimeRecorder struct {
+	mu        sync.Mutex
+	file      *os.File
+	writer    *bufio.Writer
+	path      string
+	count     int
+	maxEvents int
+}
+
+type realtimeRecord struct {
+	Time      int64  `json:"time"`
+	Direction string `json:"direction"`
+	Event     any    `json:"event"`
+}
+
+// NewRealtimeRecorder 未开启会话记录时返回 nil，nil 记录器的方法均为空操作
+func NewRealtimeRecorder(c *gin.Context, info *relaycommon.RelayInfo) *RealtimeRecorder {
+	realtimeSetting := operation_setting.GetRealtimeSetting()
+	if !realtimeSetting.RecordEnabled {
+		return nil
+	}
+	dir := filepath.Join(realtimeSetting.RecordPath, time.Now().Format("20060102"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logger.LogError(c, "create realtime record dir failed: "+err.Error())
+		return nil
+	}
+	requestId := c.GetString(common.RequestIdKey)
+	if requestId == "" {
+		requestId = common.GetUUID()
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%d_%s.jsonl", info.UserId, requestId))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logger.LogError(c, "open realtime record file failed: "+err.Error())
+		return nil
+	}
+	return &RealtimeRecorder{
+		file:      file,
+		writer:    bufio.NewWriter(file),
+		path:      path,
+		maxEvents: realtimeSetting.RecordMaxEvents,
+	}
+}
+
+func (r *RealtimeRecorder) Record(direction string, message []byte) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.maxEvents > 0 && r.count >= r.maxEvents {
+		return
+	}
+	var event map[string]any
+	if err := common.Unmarshal(message, &event); err != nil {
+		return
+	}
+	redactRealtimeAudio(event)
+	data, err := common.Marshal(realtimeRecord{
+		Time:      time.Now().UnixMilli(),
+		Direction: direction,
+		Event:     event,
+	})
+	if err != nil {
+		return
+	}
+	_, _ = r.writer.Write(data)
+	_ = r.writer.WriteByte('\n')
+	r.count++
+}
+
+// Close 写入剩余数据并关闭文件，返回记录文件路径
+func (r *RealtimeRecorder) Close() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_ = r.writer.Flush()
+	_ = r.file.Close()
+	return r.path
+}
+
+// redactRealtimeAudio 将事件中的 base64 音频替换为长度说明，避免记录文件过大及保存用户语音
+func redactRealtimeAudio(event map[string]any) {
+	eventType, _ := event["type"].(string)
+	for key, value := range event {
+		switch v := value.(type) {
+		case string:
+			if key == "audio" || (key == "delta" && eventType == dto.RealtimeEventResponseAudioDelta) {
+				event[key] = fmt.Sprintf("[audio omitted, %d chars]", len(v))
+			}
+		case map[string]any:
+			redactRealtimeAudio(v)
+		case []any:
+			for _, item := range v {
+				if m, ok := item.(map[string]any); ok {
+					redactRealtimeAudio(m)
+				}
+			}
+		}
+	}
+}
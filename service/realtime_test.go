@@ -0,0 +1,41 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+)
+
+func TestCheckRealtimeSessionPrice(t *testing.T) {
+	setting := operation_setting.GetRealtimeSetting()
+	oldMax := setting.MaxSessionQuota
+	t.Cleanup(func() { setting.MaxSessionQuota = oldMax })
+
+	quotaOf := func(price float64, groupRatio float64) int {
+		return int(price * common.QuotaPerUnit * groupRatio)
+	}
+	tests := []struct {
+		name      string
+		maxQuota  int
+		priceData types.PriceData
+		wantErr   bool
+	}{
+		{"no limit", 0, types.PriceData{UsePrice: true, ModelPrice: 1, GroupRatioInfo: types.GroupRatioInfo{GroupRatio: 1}}, false},
+		{"ratio model checked by usage", 1, types.PriceData{ModelRatio: 10}, false},
+		{"price within limit", quotaOf(1, 1), types.PriceData{UsePrice: true, ModelPrice: 1, GroupRatioInfo: types.GroupRatioInfo{GroupRatio: 1}}, false},
+		{"price above limit", quotaOf(1, 1), types.PriceData{UsePrice: true, ModelPrice: 2, GroupRatioInfo: types.GroupRatioInfo{GroupRatio: 1}}, true},
+		{"group ratio applied", quotaOf(1, 1), types.PriceData{UsePrice: true, ModelPrice: 1, GroupRatioInfo: types.GroupRatioInfo{GroupRatio: 1.5}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setting.MaxSessionQuota = tt.maxQuota
+			err := CheckRealtimeSessionPrice(&relaycommon.RelayInfo{PriceData: tt.priceData})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckRealtimeSessionPrice() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// GetStatementPeriod 返回 t 所在自然月的账期 [start, end)
+func GetStatementPeriod(t time.Time) (time.Time, time.Time) {
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 1, 0)
+}
+
+// GenerateStatement 根据用户用量生成用户指定账期的账单，账单已存在时直接返回
+func GenerateStatement(userId int, periodStart time.Time, periodEnd time.Time) (*model.Statement, error) {
+	if statement := model.GetStatementByUserPeriod(userId, periodStart.Unix()); statement != nil {
+		return statement, nil
+	}
+	items, err := model.GetStatementLineItems(userId, periodStart.Unix())
+	if err != nil {
+		return nil, err
+	}
+	totalQuota := 0
+	for _, item := range items {
+		totalQuota += item.Quota
+	}
+	username, _ := model.GetUsernameById(userId, false)
+	now := common.GetTimestamp()
+	statement := &model.Statement{
+		UserId:      userId,
+		Username:    username,
+		PeriodStart: periodStart.Unix(),
+		PeriodEnd:   periodEnd.Unix(),
+		Quota:       totalQuota,
+		Amount:      float64(totalQuota) / common.QuotaPerUnit,
+		Status:      common.StatementStatusPending,
+		DueTime:     now + int64(operation_setting.GetCreditSetting().DueDays)*24*3600,
+		CreateTime:  now,
+	}
+	statement.SetLineItems(items)
+	// 账期内无消费，无需付款
+	if totalQuota <= 0 {
+		statement.Status = common.StatementStatusPaid
+		statement.PaidTime = now
+	}
+	if err := statement.Insert(); err != nil {
+		return nil, err
+	}
+	if statement.Status == common.StatementStatusPending {
+		notifyStatement(statement)
+	}
+	return statement, nil
+}
+
+// GenerateMonthlyStatements 为所有后付费用户生成 month 所在月份的账单
+func GenerateMonthlyStatements(month time.Time) {
+	userIds, err := model.GetCreditUserIds()
+	if err != nil {
+		common.SysError("failed to get credit users: " + err.Error())
+		return
+	}
+	periodStart, periodEnd := GetStatementPeriod(month)
+	for _, userId := range userIds {
+		if _, err := GenerateStatement(userId, periodStart, periodEnd); err != nil {
+			common.SysError(fmt.Sprintf("failed to generate statement for user %d: %s", userId, err.Error()))
+		}
+	}
+}
+
+// CheckOverdueStatements 标记逾期账单，并按配置暂停逾期用户的信用额度
+func CheckOverdueStatements() {
+	userIds, err := model.MarkOverdueStatements(common.GetTimestamp())
+	if err != nil {
+		common.SysError("failed to mark overdue statements: " + err.Error())
+		return
+	}
+	if !operation_setting.GetCreditSetting().SuspendOverdue {
+		return
+	}
+	for _, userId := range userIds {
+		if err := model.SetUserCreditSuspended(userId, true); err != nil {
+			common.SysError(fmt.Sprintf("failed to suspend credit of user %d: %s", userId, err.Error()))
+			continue
+		}
+		model.RecordLog(userId, model.LogTypeSystem, "账单逾期未付，信用额度已暂停")
+	}
+}
+
+// SettleStatement 结清账单，若用户已无逾期账单则恢复其信用额度
+func SettleStatement(id int) (*model.Statement, error) {
+	statement, err := model.PayStatement(id)
+	if err != nil {
+		return nil, err
+	}
+	overdue, err := model.CountOverdueStatements(statement.UserId)
+	if err == nil && overdue == 0 {
+		if err := model.SetUserCreditSuspended(statement.UserId, false); err != nil {
+			common.SysError(fmt.Sprintf("failed to resume credit of user %d: %s", statement.UserId, err.Error()))
+		}
+	}
+	return statement, nil
+}
+
+// AutomaticallyProcessStatements 每小时检查一次，生成上个月的账单并处理逾期
+func AutomaticallyProcessStatements() {
+	for {
+		if operation_setting.GetCreditSetting().StatementEnabled {
+			currentStart, _ := GetStatementPeriod(time.Now())
+			GenerateMonthlyStatements(currentStart.AddDate(0, -1, 0))
+			CheckOverdueStatements()
+		}
+		time.Sleep(time.Hour)
+	}
+}
+
+func notifyStatement(statement *model.Statement) {
+	userCache, err := model.GetUserCache(statement.UserId)
+	if err != nil {
+		return
+	}
+	period := time.Unix(statement.PeriodStart, 0).Format("2006-01")
+	dueDate := time.Unix(statement.DueTime, 0).Format("2006-01-02")
+	content := "您 {{value}} 的账单已生成，应付金额 {{value}}，请于 {{value}} 前完成付款，逾期将暂停信用额度。"
+	values := []interface{}{period, logger.FormatQuota(statement.Quota), dueDate}
+	err = NotifyUser(userCache.Id, userCache.Email, userCache.GetSetting(), dto.NewNotify(dto.NotifyTypeStatement, "月度账单已生成", content, values))
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to send statement notify to user %d: %s", statement.UserId, err.Error()))
+	}
+}
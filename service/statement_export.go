@@ -0,0 +1,153 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+)
+
+const (
+	statementPDFLinesPerPage = 60
+	statementPDFFontSize     = 9
+	statementPDFLeading      = 12
+)
+
+func formatStatementAmount(quota int) string {
+	return fmt.Sprintf("%.6f", float64(quota)/common.QuotaPerUnit)
+}
+
+// ExportStatementCSV 导出账单明细为 CSV
+func ExportStatementCSV(statement *model.Statement) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	// UTF-8 BOM，方便 Excel 正确识别中文
+	buf.WriteString("\xEF\xBB\xBF")
+	w := csv.NewWriter(buf)
+	records := [][]string{
+		{"statement_id", strconv.Itoa(statement.Id)},
+		{"user_id", strconv.Itoa(statement.UserId)},
+		{"username", statement.Username},
+		{"period_start", time.Unix(statement.PeriodStart, 0).Format(time.RFC3339)},
+		{"period_end", time.Unix(statement.PeriodEnd, 0).Format(time.RFC3339)},
+		{"due_time", time.Unix(statement.DueTime, 0).Format(time.RFC3339)},
+		{"status", statement.Status},
+		{"total_quota", strconv.Itoa(statement.Quota)},
+		{"total_amount_usd", formatStatementAmount(statement.Quota)},
+		{},
+		{"model_name", "token_name", "request_count", "prompt_tokens", "completion_tokens", "quota", "amount_usd"},
+	}
+	for _, item := range statement.GetLineItems() {
+		records = append(records, []string{
+			item.ModelName,
+			item.TokenName,
+			strconv.Itoa(item.RequestCount),
+			strconv.Itoa(item.PromptTokens),
+			strconv.Itoa(item.CompletionTokens),
+			strconv.Itoa(item.Quota),
+			formatStatementAmount(item.Quota),
+		})
+	}
+	if err := w.WriteAll(records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ExportStatementPDF 导出账单为 PDF，使用内置 Courier 字体，非 ASCII 字符会被替换
+func ExportStatementPDF(statement *model.Statement) ([]byte, error) {
+	lines := []string{
+		fmt.Sprintf("Statement #%d", statement.Id),
+		"",
+		fmt.Sprintf("User:     %s (#%d)", statement.Username, statement.UserId),
+		fmt.Sprintf("Period:   %s - %s", time.Unix(statement.PeriodStart, 0).Format("2006-01-02"), time.Unix(statement.PeriodEnd, 0).Add(-time.Second).Format("2006-01-02")),
+		fmt.Sprintf("Due date: %s", time.Unix(statement.DueTime, 0).Format("2006-01-02")),
+		fmt.Sprintf("Status:   %s", statement.Status),
+		fmt.Sprintf("Total:    $%s (%d quota)", formatStatementAmount(statement.Quota), statement.Quota),
+		"",
+		fmt.Sprintf("%-28s %-16s %8s %12s %12s %12s", "Model", "Token", "Requests", "Prompt", "Completion", "Amount($)"),
+		strings.Repeat("-", 93),
+	}
+	for _, item := range statement.GetLineItems() {
+		lines = append(lines, fmt.Sprintf("%-28s %-16s %8d %12d %12d %12s",
+			truncateStatementCell(item.ModelName, 28), truncateStatementCell(item.TokenName, 16),
+			item.RequestCount, item.PromptTokens, item.CompletionTokens, formatStatementAmount(item.Quota)))
+	}
+	return buildTextPDF(lines), nil
+}
+
+func truncateStatementCell(s string, width int) string {
+	if len(s) <= width {
+		return s
+	}
+	return s[:width-1] + "~"
+}
+
+// buildTextPDF 生成只包含等宽文本的最小 PDF 文档
+func buildTextPDF(lines []string) []byte {
+	var pages [][]string
+	for len(lines) > statementPDFLinesPerPage {
+		pages = append(pages, lines[:statementPDFLinesPerPage])
+		lines = lines[statementPDFLinesPerPage:]
+	}
+	pages = append(pages, lines)
+
+	// 对象编号：1 Catalog，2 Pages，3 Font，之后每页占用 Page 与 Contents 两个对象
+	var objects []string
+	kids := make([]string, 0, len(pages))
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 4+i*2))
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>",
+	)
+	for i, pageLines := range pages {
+		content := &strings.Builder{}
+		content.WriteString(fmt.Sprintf("BT /F1 %d Tf %d TL 36 806 Td\n", statementPDFFontSize, statementPDFLeading))
+		for _, line := range pageLines {
+			content.WriteString("(" + escapePDFText(line) + ") Tj T*\n")
+		}
+		content.WriteString("ET")
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", 5+i*2),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		)
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		buf.WriteString(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", i+1, obj))
+	}
+	xrefOffset := buf.Len()
+	buf.WriteString(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", len(objects)+1))
+	for _, offset := range offsets {
+		buf.WriteString(fmt.Sprintf("%010d 00000 n \n", offset))
+	}
+	buf.WriteString(fmt.Sprintf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xrefOffset))
+	return buf.Bytes()
+}
+
+func escapePDFText(s string) string {
+	b := &strings.Builder{}
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteRune('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
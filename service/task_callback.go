@@ -0,0 +1,312 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	TaskCallbackEventUpdated   = "task.updated"
+	TaskCallbackEventCompleted = "task.completed"
+)
+
+// TaskCallbackPayload 异步任务回调负载
+type TaskCallbackPayload struct {
+	Event      string `json:"event"`
+	TaskId     string `json:"task_id"`
+	Platform   string `json:"platform"`
+	Action     string `json:"action"`
+	Status     string `json:"status"`
+	Progress   string `json:"progress"`
+	ResultUrl  string `json:"result_url,omitempty"`
+	FailReason string `json:"fail_reason,omitempty"`
+	Timestamp  int64  `json:"timestamp"`
+}
+
+type taskCallbackRequest struct {
+	CallbackUrl string `json:"callback_url" form:"callback_url"`
+}
+
+// ValidateTaskCallbackURL 校验回调地址，空地址视为未设置
+func ValidateTaskCallbackURL(callbackURL string) error {
+	if callbackURL == "" {
+		return nil
+	}
+	if len(callbackURL) > 512 {
+		return errors.New("回调地址过长")
+	}
+	parsed, err := url.ParseRequestURI(callbackURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return errors.New("回调地址必须以http://或https://开头")
+	}
+	fetchSetting := system_setting.GetFetchSetting()
+	if err := common.ValidateURLWithFetchSetting(callbackURL, fetchSetting.EnableSSRFProtection, fetchSetting.AllowPrivateIp, fetchSetting.DomainFilterMode, fetchSetting.IpFilterMode, fetchSetting.DomainList, fetchSetting.IpList, fetchSetting.AllowedPorts, fetchSetting.ApplyIPFilterForDomain); err != nil {
+		return fmt.Errorf("回调地址不可用: %v", err)
+	}
+	return nil
+}
+
+// ResolveTaskCallback 解析本次任务的回调地址与签名密钥：优先使用请求中的 callback_url，其次为令牌配置，
+// 密钥优先使用令牌配置，其次为用户通知设置中的 webhook 密钥
+func ResolveTaskCallback(c *gin.Context) (string, string, error) {
+	if !operation_setting.GetTaskCallbackSetting().Enabled {
+		return "", "", nil
+	}
+	req := taskCallbackRequest{}
+	if c.Request.Method == "POST" {
+		_ = common.UnmarshalBodyReusable(c, &req)
+	}
+	callbackURL := strings.TrimSpace(req.CallbackUrl)
+	if callbackURL == "" {
+		callbackURL = common.GetContextKeyString(c, constant.ContextKeyTokenCallbackUrl)
+	}
+	if callbackURL == "" {
+		return "", "", nil
+	}
+	if err := ValidateTaskCallbackURL(callbackURL); err != nil {
+		return "", "", err
+	}
+	secret := common.GetContextKeyString(c, constant.ContextKeyTokenCallbackSecret)
+	if secret == "" {
+		if userCache, err := model.GetUserCache(c.GetInt("id")); err == nil {
+			secret = userCache.GetSetting().WebhookSecret
+		}
+	}
+	return callbackURL, secret, nil
+}
+
+// taskCallbackFields 由网关处理的回调参数，不透传上游
+var taskCallbackFields = []string{"callback_url", "callback_secret"}
+
+// StripTaskCallbackFields 从请求体中移除网关回调参数，返回是否有字段被移除
+func StripTaskCallbackFields(body map[string]any) bool {
+	stripped := false
+	for _, field := range taskCallbackFields {
+		if _, ok := body[field]; ok {
+			delete(body, field)
+			stripped = true
+		}
+	}
+	return stripped
+}
+
+// StripTaskCallbackParams 移除请求中的网关回调参数，所有任务平台均不透传上游，
+// 包括 JSON 请求体中的回调字段以及 metadata 中的同名字段
+func StripTaskCallbackParams(c *gin.Context) {
+	if req, err := relaycommon.GetTaskRequest(c); err == nil && req.Metadata != nil {
+		StripTaskCallbackFields(req.Metadata)
+	}
+	if !strings.HasPrefix(c.Request.Header.Get("Content-Type"), "application/json") {
+		// 表单请求由各渠道按字段构建上游请求
+		return
+	}
+	body, err := common.GetRequestBody(c)
+	if err != nil || len(body) == 0 {
+		return
+	}
+	var bodyMap map[string]any
+	if err := common.Unmarshal(body, &bodyMap); err != nil {
+		return
+	}
+	if !StripTaskCallbackFields(bodyMap) {
+		return
+	}
+	if stripped, err := common.Marshal(bodyMap); err == nil {
+		c.Set(common.KeyRequestBody, stripped)
+		// 直接读取原始请求体转发的路径（如 Midjourney 代理）同样使用移除后的请求体
+		c.Request.Body = io.NopCloser(bytes.NewReader(stripped))
+	}
+}
+
+func taskCallbackEvent(status string) string {
+	if status == model.TaskStatusSuccess || status == model.TaskStatusFailure {
+		return TaskCallbackEventCompleted
+	}
+	return TaskCallbackEventUpdated
+}
+
+// NotifyTaskCallback 任务状态变化时向用户回调地址投递通知
+func NotifyTaskCallback(task *model.Task) {
+	if task == nil || task.PrivateData.CallbackUrl == "" {
+		return
+	}
+	status := string(task.Status)
+	payload := TaskCallbackPayload{
+		Event:     taskCallbackEvent(status),
+		TaskId:    task.TaskID,
+		Platform:  string(task.Platform),
+		Action:    task.Action,
+		Status:    status,
+		Progress:  task.Progress,
+		Timestamp: common.GetTimestamp(),
+	}
+	// 成功时 FailReason 字段保存的是结果地址
+	if status == model.TaskStatusSuccess {
+		payload.ResultUrl = task.FailReason
+	} else {
+		payload.FailReason = task.FailReason
+	}
+	enqueueTaskCallback(task.UserId, payload, task.PrivateData.CallbackUrl, task.PrivateData.CallbackSecret)
+}
+
+// NotifyMidjourneyCallback Midjourney 任务状态变化时投递通知
+func NotifyMidjourneyCallback(task *model.Midjourney) {
+	if task == nil || task.CallbackUrl == "" {
+		return
+	}
+	payload := TaskCallbackPayload{
+		Event:      taskCallbackEvent(task.Status),
+		TaskId:     task.MjId,
+		Platform:   string(constant.TaskPlatformMidjourney),
+		Action:     task.Action,
+		Status:     task.Status,
+		Progress:   task.Progress,
+		ResultUrl:  task.ImageUrl,
+		FailReason: task.FailReason,
+		Timestamp:  common.GetTimestamp(),
+	}
+	if task.VideoUrl != "" {
+		payload.ResultUrl = task.VideoUrl
+	}
+	enqueueTaskCallback(task.UserId, payload, task.CallbackUrl, task.CallbackSecret)
+}
+
+func enqueueTaskCallback(userId int, payload TaskCallbackPayload, callbackURL string, secret string) {
+	if !operation_setting.GetTaskCallbackSetting().Enabled {
+		return
+	}
+	payloadBytes, err := common.Marshal(payload)
+	if err != nil {
+		common.SysError("failed to marshal task callback payload: " + err.Error())
+		return
+	}
+	delivery := &model.TaskCallbackDelivery{
+		UserId:   userId,
+		TaskId:   payload.TaskId,
+		Platform: payload.Platform,
+		Event:    payload.Status,
+		Url:      callbackURL,
+		Secret:   secret,
+		Payload:  string(payloadBytes),
+		Status:   common.CallbackDeliveryStatusPending,
+		// 首次投递未完成（如进程退出）时由重试任务兜底
+		NextRetryAt: common.GetTimestamp() + taskCallbackLeaseSeconds,
+	}
+	if err := delivery.Insert(); err != nil {
+		common.SysError("failed to insert task callback delivery: " + err.Error())
+		return
+	}
+	gopool.Go(func() {
+		DeliverTaskCallback(delivery)
+	})
+}
+
+// taskCallbackBackoff 第 attempts 次失败后的重试间隔：base * 2^(attempts-1)，不超过上限
+func taskCallbackBackoff(attempts int) int64 {
+	setting := operation_setting.GetTaskCallbackSetting()
+	base := int64(max(setting.BaseBackoffSeconds, 1))
+	limit := int64(max(setting.MaxBackoffSeconds, setting.BaseBackoffSeconds, 1))
+	backoff := base
+	for i := 1; i < attempts && backoff < limit; i++ {
+		backoff *= 2
+	}
+	return min(backoff, limit)
+}
+
+// taskCallbackLeaseSeconds 投递租约时长，需大于单次投递的超时时间
+const taskCallbackLeaseSeconds = 300
+
+// DeliverTaskCallback 领取投递记录后投递一次回调并更新投递记录，已被其他流程领取时跳过
+func DeliverTaskCallback(delivery *model.TaskCallbackDelivery) {
+	claimed, err := model.ClaimTaskCallbackDelivery(delivery, common.GetTimestamp()+taskCallbackLeaseSeconds)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to claim task callback delivery %d: %s", delivery.Id, err.Error()))
+		return
+	}
+	if !claimed {
+		return
+	}
+	deliverClaimedTaskCallback(delivery)
+}
+
+func deliverClaimedTaskCallback(delivery *model.TaskCallbackDelivery) {
+	delivery.Attempts++
+	statusCode, err := PostSignedWebhook(delivery.Url, delivery.Secret, []byte(delivery.Payload))
+	delivery.ResponseCode = statusCode
+	if err == nil {
+		delivery.Status = common.CallbackDeliveryStatusSuccess
+		delivery.LastError = ""
+		delivery.NextRetryAt = 0
+	} else {
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= max(operation_setting.GetTaskCallbackSetting().MaxAttempts, 1) {
+			delivery.Status = common.CallbackDeliveryStatusFailed
+			delivery.NextRetryAt = 0
+		} else {
+			delivery.Status = common.CallbackDeliveryStatusPending
+			delivery.NextRetryAt = common.GetTimestamp() + taskCallbackBackoff(delivery.Attempts)
+		}
+	}
+	if err := delivery.Update(); err != nil {
+		common.SysError(fmt.Sprintf("failed to update task callback delivery %d: %s", delivery.Id, err.Error()))
+	}
+}
+
+// ReplayTaskCallbackDelivery 重新投递回调，重置重试次数
+func ReplayTaskCallbackDelivery(delivery *model.TaskCallbackDelivery) error {
+	if delivery.Status == common.CallbackDeliveryStatusSuccess {
+		return errors.New("回调已投递成功，无需重放")
+	}
+	if err := ValidateTaskCallbackURL(delivery.Url); err != nil {
+		return err
+	}
+	claimed, err := model.ClaimTaskCallbackDelivery(delivery, common.GetTimestamp()+taskCallbackLeaseSeconds)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return errors.New("回调正在投递中，请稍后再试")
+	}
+	delivery.Attempts = 0
+	deliverClaimedTaskCallback(delivery)
+	return nil
+}
+
+// AutomaticallyRetryTaskCallbacks 定时重试到期的回调并清理过期记录
+func AutomaticallyRetryTaskCallbacks() {
+	lastCleanup := int64(0)
+	for {
+		time.Sleep(10 * time.Second)
+		setting := operation_setting.GetTaskCallbackSetting()
+		now := common.GetTimestamp()
+		deliveries, err := model.GetDueTaskCallbackDeliveries(now, 100)
+		if err != nil {
+			common.SysError("failed to get due task callback deliveries: " + err.Error())
+			continue
+		}
+		for _, delivery := range deliveries {
+			DeliverTaskCallback(delivery)
+		}
+		if setting.RetentionDays > 0 && now-lastCleanup > 3600 {
+			lastCleanup = now
+			if _, err := model.DeleteTaskCallbackDeliveriesBefore(now - int64(setting.RetentionDays)*24*3600); err != nil {
+				common.SysError("failed to clean task callback deliveries: " + err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStripTaskCallbackParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantBody    string
+	}{
+		{"json callback removed", "application/json", `{"prompt":"cat","callback_url":"https://example.com/cb"}`, `{"prompt":"cat"}`},
+		{"json callback secret removed", "application/json", `{"prompt":"cat","callback_url":"https://example.com/cb","callback_secret":"s"}`, `{"prompt":"cat"}`},
+		{"json without callback untouched", "application/json", `{"prompt":"cat"}`, `{"prompt":"cat"}`},
+		{"form body untouched", "application/x-www-form-urlencoded", `prompt=cat&callback_url=x`, `prompt=cat&callback_url=x`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/video/generations", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", tt.contentType)
+			// 与各提交接口一致，先解析请求体
+			var req map[string]any
+			_ = common.UnmarshalBodyReusable(c, &req)
+			StripTaskCallbackParams(c)
+			body, err := common.GetRequestBody(c)
+			if err != nil {
+				t.Fatalf("GetRequestBody: %v", err)
+			}
+			if string(body) != tt.wantBody {
+				t.Errorf("body = %s, want %s", body, tt.wantBody)
+			}
+			// 直接读取原始请求体转发的路径也不包含回调参数
+			raw, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				t.Fatalf("read request body: %v", err)
+			}
+			if string(raw) != tt.wantBody {
+				t.Errorf("request body = %s, want %s", raw, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestFilterMidjourneyRequestBodyStripsCallback(t *testing.T) {
+	tests := []struct {
+		name string
+		body map[string]interface{}
+		want map[string]interface{}
+	}{
+		{"callback fields removed", map[string]interface{}{"prompt": "cat", "callback_url": "https://example.com/cb", "callback_secret": "s"}, map[string]interface{}{"prompt": "cat"}},
+		{"no callback", map[string]interface{}{"prompt": "cat"}, map[string]interface{}{"prompt": "cat"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			FilterMidjourneyRequestBody(tt.body)
+			if !reflect.DeepEqual(tt.body, tt.want) {
+				t.Errorf("body = %v, want %v", tt.body, tt.want)
+			}
+		})
+	}
+}
@@ -5,3 +5,6 @@ var MjAccountFilterEnabled = false
 var MjModeClearEnabled = false
 var MjForwardUrlEnabled = true
 var MjActionCheckSuccessEnabled = true
+
+// MjTaskAdaptorEnabled 通过通用异步任务框架（tasks 表、任务轮询、重试）处理 Midjourney 提交
+var MjTaskAdaptorEnabled = false
@@ -0,0 +1,60 @@
+package model_setting
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// ModelFallbackSettings 跨模型兜底：某模型的所有渠道都不可用时，依次改用链中的模型
+//
+//	chains: {"gpt-4o": {"*": ["claude-sonnet-4-20250514", "qwen-max"], "vip": ["gpt-4.1"]}}
+//
+// 外层键为请求的模型，内层键为分组，"*" 为未单独配置分组时的默认链
+type ModelFallbackSettings struct {
+	Enabled bool                           `json:"enabled"`
+	Chains  map[string]map[string][]string `json:"chains"`
+}
+
+const ModelFallbackAnyGroup = "*"
+
+// 默认配置
+var modelFallbackSettings = ModelFallbackSettings{
+	Enabled: false,
+	Chains:  map[string]map[string][]string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_fallback", &modelFallbackSettings)
+}
+
+func GetModelFallbackSettings() *ModelFallbackSettings {
+	return &modelFallbackSettings
+}
+
+// GetModelFallbackChain 返回模型在分组下的兜底模型链，已去除空值与模型自身
+func GetModelFallbackChain(group string, modelName string) []string {
+	if !modelFallbackSettings.Enabled {
+		return nil
+	}
+	groupChains, ok := modelFallbackSettings.Chains[modelName]
+	if !ok {
+		return nil
+	}
+	chain, ok := groupChains[group]
+	if !ok {
+		chain = groupChains[ModelFallbackAnyGroup]
+	}
+	result := make([]string, 0, len(chain))
+	seen := map[string]bool{modelName: true}
+	for _, m := range chain {
+		m = strings.TrimSpace(m)
+		if m == "" || seen[m] {
+			continue
+		}
+		seen[m] = true
+		result = append(result, m)
+	}
+	return result
+}
@@ -0,0 +1,36 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// ChannelCooldownSetting 渠道临时冷却：限流、额度耗尽等可恢复的错误不再永久禁用 Key 或渠道，
+// 而是按上游 Retry-After 或退避时长暂停使用，到期后无需测试自动恢复
+type ChannelCooldownSetting struct {
+	Enabled     bool     `json:"enabled"`
+	StatusCodes []int    `json:"status_codes"` // 触发冷却的上游状态码
+	ErrorCodes  []string `json:"error_codes"`  // 触发冷却的错误码
+	ErrorTypes  []string `json:"error_types"`  // 触发冷却的错误类型
+	// 上游未返回 Retry-After 时的退避时长：从 BaseSeconds 起，连续冷却时翻倍，不超过 MaxSeconds
+	BaseSeconds       int  `json:"base_seconds"`
+	MaxSeconds        int  `json:"max_seconds"`
+	RespectRetryAfter bool `json:"respect_retry_after"`
+}
+
+// 默认配置
+var channelCooldownSetting = ChannelCooldownSetting{
+	Enabled:           false,
+	StatusCodes:       []int{429},
+	ErrorCodes:        []string{"insufficient_quota", "rate_limit_exceeded"},
+	ErrorTypes:        []string{"insufficient_quota", "rate_limit_error"},
+	BaseSeconds:       60,
+	MaxSeconds:        3600,
+	RespectRetryAfter: true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_cooldown_setting", &channelCooldownSetting)
+}
+
+func GetChannelCooldownSetting() *ChannelCooldownSetting {
+	return &channelCooldownSetting
+}
@@ -0,0 +1,25 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+type CreditSetting struct {
+	StatementEnabled bool `json:"statement_enabled"` // 是否为后付费用户自动生成月度账单
+	DueDays          int  `json:"due_days"`          // 账单生成后的付款期限（天）
+	SuspendOverdue   bool `json:"suspend_overdue"`   // 账单逾期后是否暂停用户信用额度
+}
+
+// 默认配置
+var creditSetting = CreditSetting{
+	StatementEnabled: false,
+	DueDays:          15,
+	SuspendOverdue:   true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("credit_setting", &creditSetting)
+}
+
+func GetCreditSetting() *CreditSetting {
+	return &creditSetting
+}
@@ -0,0 +1,153 @@
+package operation_setting
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+const (
+	CurrencyCNY = "CNY"
+	CurrencyUSD = "USD"
+	CurrencyEUR = "EUR"
+)
+
+const (
+	ExchangeRateSourceManual = "manual" // 手动维护汇率
+	ExchangeRateSourceRemote = "remote" // 定时从 RateSourceURL 拉取
+	ExchangeRateSourceStub   = "stub"   // 固定汇率，仅用于本地测试
+)
+
+type CurrencySetting struct {
+	Currencies      []string `json:"currencies"`
+	DefaultCurrency string   `json:"default_currency"`
+	// ExchangeRates 1 USD 可兑换的各币种金额，由管理员手动维护
+	ExchangeRates map[string]float64 `json:"exchange_rates"`
+	// AutoExchangeRates 自动来源拉取的汇率，不覆盖手动配置
+	AutoExchangeRates  map[string]float64 `json:"auto_exchange_rates"`
+	RateSource         string             `json:"rate_source"`
+	RateSourceURL      string             `json:"rate_source_url"`
+	RateUpdateInterval int                `json:"rate_update_interval"` // 拉取间隔（分钟）
+	RateUpdatedAt      int64              `json:"rate_updated_at"`
+	// UnitPrices 各币种购买 1 美元额度的单价，未配置时按汇率由 CNY 单价（Price）换算
+	UnitPrices map[string]float64 `json:"unit_prices"`
+	// PackagePrices 各币种充值套餐价格（充值数量 -> 售价），优先于单价
+	PackagePrices map[string]map[int]float64 `json:"package_prices"`
+}
+
+// 默认配置
+var currencySetting = CurrencySetting{
+	Currencies:      []string{CurrencyCNY, CurrencyUSD, CurrencyEUR},
+	DefaultCurrency: CurrencyCNY,
+	ExchangeRates: map[string]float64{
+		CurrencyUSD: 1,
+		CurrencyCNY: 7.3,
+		CurrencyEUR: 0.92,
+	},
+	AutoExchangeRates:  map[string]float64{},
+	RateSource:         ExchangeRateSourceManual,
+	RateSourceURL:      "https://open.er-api.com/v6/latest/USD",
+	RateUpdateInterval: 360,
+	UnitPrices:         map[string]float64{},
+	PackagePrices:      map[string]map[int]float64{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("currency_setting", &currencySetting)
+}
+
+func GetCurrencySetting() *CurrencySetting {
+	return &currencySetting
+}
+
+func IsSupportedCurrency(currency string) bool {
+	for _, c := range currencySetting.Currencies {
+		if strings.EqualFold(c, currency) {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeCurrency 统一为大写币种代码，为空时返回默认币种
+func NormalizeCurrency(currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		currency = strings.ToUpper(currencySetting.DefaultCurrency)
+	}
+	if currency == "" {
+		currency = CurrencyCNY
+	}
+	return currency
+}
+
+// IsAutoExchangeRateSource 判断汇率来源是否为自动拉取
+func IsAutoExchangeRateSource(source string) bool {
+	return source == ExchangeRateSourceRemote || source == ExchangeRateSourceStub
+}
+
+// GetExchangeRate 返回 1 USD = X <currency> 的 X
+func GetExchangeRate(currency string) float64 {
+	currency = NormalizeCurrency(currency)
+	if currency == CurrencyUSD {
+		return 1
+	}
+	if IsAutoExchangeRateSource(currencySetting.RateSource) {
+		if rate, ok := currencySetting.AutoExchangeRates[currency]; ok && rate > 0 {
+			return rate
+		}
+	}
+	if rate, ok := currencySetting.ExchangeRates[currency]; ok && rate > 0 {
+		return rate
+	}
+	if currency == CurrencyCNY && USDExchangeRate > 0 {
+		return USDExchangeRate
+	}
+	return 1
+}
+
+// ConvertFromUSD 将美元金额换算为指定币种
+func ConvertFromUSD(usd float64, currency string) float64 {
+	return usd * GetExchangeRate(currency)
+}
+
+// GetTopUpUnitPrice 返回指定币种购买 1 美元额度的单价
+func GetTopUpUnitPrice(currency string) float64 {
+	currency = NormalizeCurrency(currency)
+	if price, ok := currencySetting.UnitPrices[currency]; ok && price > 0 {
+		return price
+	}
+	if currency == CurrencyCNY {
+		return Price
+	}
+	// 兼容旧配置：Price 为人民币单价，按汇率换算
+	return Price / GetExchangeRate(CurrencyCNY) * GetExchangeRate(currency)
+}
+
+// GetPackagePrice 返回指定币种充值套餐的售价
+func GetPackagePrice(currency string, amount int) (float64, bool) {
+	prices, ok := currencySetting.PackagePrices[NormalizeCurrency(currency)]
+	if !ok {
+		return 0, false
+	}
+	price, ok := prices[amount]
+	if !ok || price <= 0 {
+		return 0, false
+	}
+	return price, true
+}
+
+// GetCurrencySymbolByCode 返回币种符号
+func GetCurrencySymbolByCode(currency string) string {
+	switch NormalizeCurrency(currency) {
+	case CurrencyCNY:
+		return "¥"
+	case CurrencyUSD:
+		return "$"
+	case CurrencyEUR:
+		return "€"
+	default:
+		return currency
+	}
+}
@@ -0,0 +1,40 @@
+package operation_setting
+
+import "testing"
+
+func TestGetExchangeRate(t *testing.T) {
+	old := currencySetting
+	oldUSD := USDExchangeRate
+	t.Cleanup(func() {
+		currencySetting = old
+		USDExchangeRate = oldUSD
+	})
+	USDExchangeRate = 7.0
+	manual := map[string]float64{CurrencyCNY: 7.3, CurrencyEUR: 0.92}
+	auto := map[string]float64{CurrencyCNY: 7.1}
+	tests := []struct {
+		name     string
+		source   string
+		manual   map[string]float64
+		auto     map[string]float64
+		currency string
+		want     float64
+	}{
+		{"usd", ExchangeRateSourceRemote, manual, auto, CurrencyUSD, 1},
+		{"manual source ignores auto", ExchangeRateSourceManual, manual, auto, CurrencyCNY, 7.3},
+		{"remote source uses auto", ExchangeRateSourceRemote, manual, auto, CurrencyCNY, 7.1},
+		{"stub source uses auto", ExchangeRateSourceStub, manual, auto, CurrencyCNY, 7.1},
+		{"auto missing falls back to manual", ExchangeRateSourceRemote, manual, auto, CurrencyEUR, 0.92},
+		{"cny falls back to legacy rate", ExchangeRateSourceManual, map[string]float64{}, auto, CurrencyCNY, 7.0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			currencySetting.RateSource = tt.source
+			currencySetting.ExchangeRates = tt.manual
+			currencySetting.AutoExchangeRates = tt.auto
+			if got := GetExchangeRate(tt.currency); got != tt.want {
+				t.Errorf("GetExchangeRate(%s) = %v, want %v", tt.currency, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package operation_setting
+
+import (
+	"slices"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// HedgeSetting 对冲请求：首个 token 超过延迟仍未返回时向另一渠道再发一次请求，采用先返回的结果
+type HedgeSetting struct {
+	Enabled         bool     `json:"enabled"`
+	DelayMs         int      `json:"delay_ms"`          // 发起对冲请求前等待首个 token 的时间（毫秒）
+	Groups          []string `json:"groups"`            // 对这些分组的请求启用对冲，令牌也可单独开启
+	BillLoserPrompt bool     `json:"bill_loser_prompt"` // 落败的请求是否按提示词计费
+	// 非流式请求在完整结果返回前无法判断胜负，两个尝试都会跑完整个生成，默认只对冲流式请求
+	NonStreamEnabled bool `json:"non_stream_enabled"`
+}
+
+// 默认配置
+var hedgeSetting = HedgeSetting{
+	Enabled:          false,
+	DelayMs:          2000,
+	Groups:           []string{},
+	BillLoserPrompt:  false,
+	NonStreamEnabled: false,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("hedge_setting", &hedgeSetting)
+}
+
+func GetHedgeSetting() *HedgeSetting {
+	return &hedgeSetting
+}
+
+// IsHedgeEnabledFor 对冲总开关开启时，令牌开启对冲或分组在对冲分组中即启用；非流式请求需额外开启 NonStreamEnabled
+func IsHedgeEnabledFor(group string, tokenHedgeEnabled bool, isStream bool) bool {
+	if !hedgeSetting.Enabled || hedgeSetting.DelayMs <= 0 {
+		return false
+	}
+	if !isStream && !hedgeSetting.NonStreamEnabled {
+		return false
+	}
+	return tokenHedgeEnabled || slices.Contains(hedgeSetting.Groups, group)
+}
@@ -0,0 +1,32 @@
+package operation_setting
+
+import "testing"
+
+func TestIsHedgeEnabledFor(t *testing.T) {
+	old := hedgeSetting
+	t.Cleanup(func() { hedgeSetting = old })
+	tests := []struct {
+		name      string
+		setting   HedgeSetting
+		group     string
+		token     bool
+		isStream  bool
+		wantHedge bool
+	}{
+		{"disabled", HedgeSetting{Enabled: false, DelayMs: 2000}, "default", true, true, false},
+		{"no delay", HedgeSetting{Enabled: true, DelayMs: 0}, "default", true, true, false},
+		{"token stream", HedgeSetting{Enabled: true, DelayMs: 2000}, "default", true, true, true},
+		{"group stream", HedgeSetting{Enabled: true, DelayMs: 2000, Groups: []string{"vip"}}, "vip", false, true, true},
+		{"group not listed", HedgeSetting{Enabled: true, DelayMs: 2000, Groups: []string{"vip"}}, "default", false, true, false},
+		{"non stream needs opt-in", HedgeSetting{Enabled: true, DelayMs: 2000}, "default", true, false, false},
+		{"non stream opted in", HedgeSetting{Enabled: true, DelayMs: 2000, NonStreamEnabled: true}, "default", true, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hedgeSetting = tt.setting
+			if got := IsHedgeEnabledFor(tt.group, tt.token, tt.isStream); got != tt.wantHedge {
+				t.Errorf("IsHedgeEnabledFor() = %v, want %v", got, tt.wantHedge)
+			}
+		})
+	}
+}
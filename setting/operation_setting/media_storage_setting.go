@@ -0,0 +1,68 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+const (
+	MediaStorageBackendLocal = "local"
+	MediaStorageBackendS3    = "s3"
+)
+
+type MediaStorageSetting struct {
+	Enabled           bool   `json:"enabled"`            // 是否归档生成结果
+	ArchiveVideo      bool   `json:"archive_video"`      // 归档视频任务结果
+	ArchiveMidjourney bool   `json:"archive_midjourney"` // 归档 Midjourney 图片
+	ArchiveImage      bool   `json:"archive_image"`      // 归档图像生成接口返回的图片链接
+	ArchiveAudio      bool   `json:"archive_audio"`      // 归档音频生成任务结果
+	Backend           string `json:"backend"`            // local / s3
+	LocalPath         string `json:"local_path"`
+	// S3 兼容存储
+	S3Endpoint  string `json:"s3_endpoint"`
+	S3Region    string `json:"s3_region"`
+	S3Bucket    string `json:"s3_bucket"`
+	S3AccessKey string `json:"s3_access_key"`
+	S3SecretKey string `json:"s3_secret_key"`
+	S3PathStyle bool   `json:"s3_path_style"`
+	S3Prefix    string `json:"s3_prefix"`
+	// 签名链接
+	SigningSecret   string `json:"signing_secret"`    // 为空时使用 CRYPTO_SECRET，多节点部署需配置
+	SignedUrlExpiry int    `json:"signed_url_expiry"` // 签名链接有效期（秒）
+	// 保留策略
+	RetentionDays      int            `json:"retention_days"`       // 默认保留天数，0 表示永久保留
+	GroupRetentionDays map[string]int `json:"group_retention_days"` // 按用户分组覆盖保留天数
+	MaxUserStorageMB   int            `json:"max_user_storage_mb"`  // 每用户存储上限，超出时删除最早的文件，0 表示不限
+	MaxObjectSizeMB    int            `json:"max_object_size_mb"`   // 单个文件大小上限
+}
+
+// 默认配置
+var mediaStorageSetting = MediaStorageSetting{
+	Enabled:            false,
+	ArchiveVideo:       true,
+	ArchiveMidjourney:  true,
+	ArchiveImage:       false,
+	ArchiveAudio:       true,
+	Backend:            MediaStorageBackendLocal,
+	LocalPath:          "./data/media",
+	S3Region:           "us-east-1",
+	SignedUrlExpiry:    3600,
+	RetentionDays:      7,
+	GroupRetentionDays: map[string]int{},
+	MaxUserStorageMB:   0,
+	MaxObjectSizeMB:    512,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("media_storage_setting", &mediaStorageSetting)
+}
+
+func GetMediaStorageSetting() *MediaStorageSetting {
+	return &mediaStorageSetting
+}
+
+// GetMediaRetentionDays 返回指定分组的媒体保留天数
+func GetMediaRetentionDays(group string) int {
+	if days, ok := mediaStorageSetting.GroupRetentionDays[group]; ok {
+		return days
+	}
+	return mediaStorageSetting.RetentionDays
+}
@@ -0,0 +1,34 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// RealtimeSetting 实时语音（/v1/realtime）会话的计费与安全控制
+type RealtimeSetting struct {
+	MaxSessionSeconds     int    `json:"max_session_seconds"`     // 单个会话最长时长（秒），0 表示不限
+	MaxSessionQuota       int    `json:"max_session_quota"`       // 单个会话最多消耗额度，0 表示不限；按次计价的模型在会话开始时按价格检查
+	SettleIntervalSeconds int    `json:"settle_interval_seconds"` // 按本地估算用量周期结算的间隔（秒），0 表示仅在响应完成时结算
+	ModerationEnabled     bool   `json:"moderation_enabled"`      // 检查会话文本与转写内容中的敏感词
+	RecordEnabled         bool   `json:"record_enabled"`          // 记录会话事件用于排查问题，音频数据不落盘
+	RecordPath            string `json:"record_path"`             // 事件记录目录
+	RecordMaxEvents       int    `json:"record_max_events"`       // 单个会话最多记录的事件数
+}
+
+// 默认配置
+var realtimeSetting = RealtimeSetting{
+	MaxSessionSeconds:     0,
+	MaxSessionQuota:       0,
+	SettleIntervalSeconds: 0,
+	ModerationEnabled:     true,
+	RecordEnabled:         false,
+	RecordPath:            "./data/realtime",
+	RecordMaxEvents:       2000,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("realtime_setting", &realtimeSetting)
+}
+
+func GetRealtimeSetting() *RealtimeSetting {
+	return &realtimeSetting
+}
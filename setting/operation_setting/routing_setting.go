@@ -0,0 +1,87 @@
+package operation_setting
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// 同一优先级内选择渠道的路由策略
+const (
+	RoutingPolicyWeight        = "weight"         // 按权重随机（默认）
+	RoutingPolicyLatency       = "latency"        // 首字延迟中位数最低
+	RoutingPolicyCost          = "cost"           // 渠道成本倍率最低
+	RoutingPolicyLeastInFlight = "least_inflight" // 在途请求最少
+	RoutingPolicyPowerOfTwo    = "p2c"            // 按权重随机取两个渠道，选在途请求较少的一个
+)
+
+const RoutingAnyKey = "*"
+
+// RoutingSetting 渠道路由策略，仅在启用内存缓存时生效
+//
+//	policies: {"default": {"gpt-4o": "latency", "*": "p2c"}, "*": {"claude-sonnet-4-20250514": "cost"}}
+//
+// 外层键为分组，内层键为模型，"*" 匹配任意分组或模型；均未匹配时使用 default_policy
+type RoutingSetting struct {
+	DefaultPolicy string                       `json:"default_policy"`
+	Policies      map[string]map[string]string `json:"policies"`
+}
+
+// 默认配置
+var routingSetting = RoutingSetting{
+	DefaultPolicy: RoutingPolicyWeight,
+	Policies:      map[string]map[string]string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("routing_setting", &routingSetting)
+}
+
+func GetRoutingSetting() *RoutingSetting {
+	return &routingSetting
+}
+
+func IsValidRoutingPolicy(policy string) bool {
+	switch policy {
+	case RoutingPolicyWeight, RoutingPolicyLatency, RoutingPolicyCost, RoutingPolicyLeastInFlight, RoutingPolicyPowerOfTwo:
+		return true
+	}
+	return false
+}
+
+// GetRoutingPolicy 返回分组与模型适用的路由策略，优先匹配具体分组，再匹配具体模型；未知策略按权重随机处理
+func GetRoutingPolicy(group string, modelName string) string {
+	for _, g := range []string{group, RoutingAnyKey} {
+		models, ok := routingSetting.Policies[g]
+		if !ok {
+			continue
+		}
+		for _, m := range []string{modelName, RoutingAnyKey} {
+			if policy, ok := models[m]; ok && IsValidRoutingPolicy(policy) {
+				return policy
+			}
+		}
+	}
+	if IsValidRoutingPolicy(routingSetting.DefaultPolicy) {
+		return routingSetting.DefaultPolicy
+	}
+	return RoutingPolicyWeight
+}
+
+// ValidateRoutingPolicies 校验 policies 配置中的策略名称
+func ValidateRoutingPolicies(jsonStr string) error {
+	policies := make(map[string]map[string]string)
+	if err := common.Unmarshal([]byte(jsonStr), &policies); err != nil {
+		return err
+	}
+	for group, models := range policies {
+		for modelName, policy := range models {
+			if !IsValidRoutingPolicy(policy) {
+				return fmt.Errorf("分组 %s 模型 %s 的路由策略 %s 无效", group, modelName, policy)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package operation_setting
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// SubstitutionProbe 已知答案的探测提示词，Expect 为回复需匹配的正则
+type SubstitutionProbe struct {
+	Prompt string `json:"prompt"`
+	Expect string `json:"expect"`
+}
+
+// ModelSubstitutionSetting 上游模型替换检测：定期用已知答案的提示词探测渠道，比较上游返回的模型名、
+// system_fingerprint 与提示词 token 数，并结合实际请求中本地估算与上游上报 token 数的比例，
+// 在同一模型的多个渠道之间比较，标记行为明显不一致的渠道
+type ModelSubstitutionSetting struct {
+	Enabled              bool                `json:"enabled"`
+	ProbeIntervalMinutes int                 `json:"probe_interval_minutes"`
+	ProbeModels          []string            `json:"probe_models"` // 为空时探测渠道的测试模型
+	Probes               []SubstitutionProbe `json:"probes"`
+	// 提示词 token 数与同模型其他渠道中位数的最大偏差比例
+	TokenDeviation float64 `json:"token_deviation"`
+	// 比较实际请求的 token 比例时每个渠道至少需要的样本数
+	MinSamples int `json:"min_samples"`
+	// 连续多少次探测返回的模型不一致或答案错误时标记渠道
+	FailureThreshold int  `json:"failure_threshold"`
+	NotifyEnabled    bool `json:"notify_enabled"`
+}
+
+// 默认配置
+var modelSubstitutionSetting = ModelSubstitutionSetting{
+	Enabled:              false,
+	ProbeIntervalMinutes: 60,
+	ProbeModels:          []string{},
+	Probes: []SubstitutionProbe{
+		{Prompt: "What is 17 * 23? Reply with the number only.", Expect: `\b391\b`},
+		{Prompt: "Spell the word \"strawberry\" backwards. Reply with the result only.", Expect: `(?i)yrrebwarts`},
+	},
+	TokenDeviation:   0.2,
+	MinSamples:       20,
+	FailureThreshold: 3,
+	NotifyEnabled:    true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_substitution_setting", &modelSubstitutionSetting)
+}
+
+func GetModelSubstitutionSetting() *ModelSubstitutionSetting {
+	return &modelSubstitutionSetting
+}
+
+// ValidateSubstitutionProbes 校验 probes 配置中的提示词与正则
+func ValidateSubstitutionProbes(jsonStr string) error {
+	probes := make([]SubstitutionProbe, 0)
+	if err := common.Unmarshal([]byte(jsonStr), &probes); err != nil {
+		return err
+	}
+	for i, probe := range probes {
+		if strings.TrimSpace(probe.Prompt) == "" {
+			return fmt.Errorf("第 %d 条探测的提示词不能为空", i+1)
+		}
+		if _, err := regexp.Compile(probe.Expect); err != nil {
+			return fmt.Errorf("第 %d 条探测的正则无效：%s", i+1, err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+type TaskCallbackSetting struct {
+	Enabled            bool `json:"enabled"`              // 是否启用异步任务回调
+	MaxAttempts        int  `json:"max_attempts"`         // 最大投递次数（含首次）
+	BaseBackoffSeconds int  `json:"base_backoff_seconds"` // 首次重试间隔，之后指数退避
+	MaxBackoffSeconds  int  `json:"max_backoff_seconds"`  // 单次重试间隔上限
+	RetentionDays      int  `json:"retention_days"`       // 投递记录保留天数，0 表示不清理
+}
+
+// 默认配置
+var taskCallbackSetting = TaskCallbackSetting{
+	Enabled:            true,
+	MaxAttempts:        6,
+	BaseBackoffSeconds: 10,
+	MaxBackoffSeconds:  3600,
+	RetentionDays:      30,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("task_callback_setting", &taskCallbackSetting)
+}
+
+func GetTaskCallbackSetting() *TaskCallbackSetting {
+	return &taskCallbackSetting
+}
@@ -0,0 +1,58 @@
+package operation_setting
+
+import (
+	"math"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+type TaskPollSetting struct {
+	Distributed            bool           `json:"distributed"`              // 所有节点参与轮询，通过租约分配任务；关闭时仅主节点轮询
+	BatchSize              int            `json:"batch_size"`               // 每次领取的到期任务数
+	MinIntervalSeconds     int            `json:"min_interval_seconds"`     // 进度有变化时的轮询间隔
+	MaxIntervalSeconds     int            `json:"max_interval_seconds"`     // 退避后的最大轮询间隔
+	BackoffFactor          float64        `json:"backoff_factor"`           // 进度无变化时间隔的增长倍数
+	LeaseSeconds           int            `json:"lease_seconds"`            // 任务租约时长，节点宕机后租约到期由其他节点接管
+	ChannelConcurrency     int            `json:"channel_concurrency"`      // 每个渠道同时进行的查询数
+	TimeoutMinutes         int            `json:"timeout_minutes"`          // 任务超过该时长仍未完成则判定失败并退款，0 表示不限
+	PlatformTimeoutMinutes map[string]int `json:"platform_timeout_minutes"` // 按平台覆盖超时时长
+}
+
+// 默认配置
+var taskPollSetting = TaskPollSetting{
+	Distributed:            false,
+	BatchSize:              200,
+	MinIntervalSeconds:     10,
+	MaxIntervalSeconds:     120,
+	BackoffFactor:          1.5,
+	LeaseSeconds:           120,
+	ChannelConcurrency:     4,
+	TimeoutMinutes:         0,
+	PlatformTimeoutMinutes: map[string]int{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("task_poll_setting", &taskPollSetting)
+}
+
+func GetTaskPollSetting() *TaskPollSetting {
+	return &taskPollSetting
+}
+
+// GetTaskPollInterval 返回连续 attempts 次无进展后的轮询间隔（秒）
+func GetTaskPollInterval(attempts int) int64 {
+	minInterval := float64(max(taskPollSetting.MinIntervalSeconds, 1))
+	maxInterval := math.Max(float64(taskPollSetting.MaxIntervalSeconds), minInterval)
+	factor := math.Max(taskPollSetting.BackoffFactor, 1)
+	interval := minInterval * math.Pow(factor, float64(max(attempts, 0)))
+	return int64(math.Min(interval, maxInterval))
+}
+
+// GetTaskTimeoutMinutes 返回指定平台的任务超时时长（分钟）
+func GetTaskTimeoutMinutes(platform string) int {
+	if minutes, ok := taskPollSetting.PlatformTimeoutMinutes[platform]; ok {
+		return minutes
+	}
+	return taskPollSetting.TimeoutMinutes
+}
@@ -20,6 +20,8 @@ const (
 	GPTImage1High1024x1024   = 0.167
 	GPTImage1High1024x1536   = 0.25
 	GPTImage1High1536x1024   = 0.25
+	// 流式输出的每张预览图（partial image）额外产生 100 个图像输出 token
+	GPTImage1PartialImageTokens = 100
 )
 
 const (
@@ -108,3 +110,32 @@ func GetGPTImage1PriceOnceCall(quality string, size string) float64 {
 
 	return GPTImage1High1024x1024
 }
+
+// GetGPTImage1OutputTokens 返回单张图片的图像输出 token 数，用于上游未返回用量时估算
+func GetGPTImage1OutputTokens(quality string, size string) int {
+	tokens := map[string]map[string]int{
+		"low": {
+			"1024x1024": 272,
+			"1024x1536": 408,
+			"1536x1024": 400,
+		},
+		"medium": {
+			"1024x1024": 1056,
+			"1024x1536": 1584,
+			"1536x1024": 1568,
+		},
+		"high": {
+			"1024x1024": 4160,
+			"1024x1536": 6240,
+			"1536x1024": 6208,
+		},
+	}
+
+	if qualityMap, exists := tokens[quality]; exists {
+		if count, exists := qualityMap[size]; exists {
+			return count
+		}
+	}
+
+	return tokens["high"]["1024x1024"]
+}
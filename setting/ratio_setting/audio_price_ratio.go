@@ -0,0 +1,63 @@
+package ratio_setting
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// AudioPriceRatio 音频生成任务按时长计费：per_second 开启时模型价格视为每秒单价，
+// 提交时按请求时长（未传时取模型默认时长，语音合成按文本估算）预扣费，完成后按实际时长结算；
+// min_seconds 为单次最低计费时长
+type AudioPriceRatio struct {
+	PerSecond  bool    `json:"per_second,omitempty"`
+	MinSeconds float64 `json:"min_seconds,omitempty"`
+}
+
+// 示例:
+//
+//	{
+//	  "music-1.5": {"per_second": true, "min_seconds": 30},
+//	  "eleven_text_to_sound_v2": {"per_second": true, "min_seconds": 1},
+//	  "speech-2.5-hd-preview": {"per_second": true}
+//	}
+var audioPriceRatioMap = map[string]AudioPriceRatio{}
+var audioPriceRatioMapMutex sync.RWMutex
+
+func AudioPriceRatio2JSONString() string {
+	audioPriceRatioMapMutex.RLock()
+	defer audioPriceRatioMapMutex.RUnlock()
+	jsonBytes, err := json.Marshal(audioPriceRatioMap)
+	if err != nil {
+		common.SysLog("error marshalling audio price ratio: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateAudioPriceRatioByJSONString(jsonStr string) error {
+	newMap := make(map[string]AudioPriceRatio)
+	if err := json.Unmarshal([]byte(jsonStr), &newMap); err != nil {
+		return err
+	}
+	audioPriceRatioMapMutex.Lock()
+	audioPriceRatioMap = newMap
+	audioPriceRatioMapMutex.Unlock()
+	return nil
+}
+
+// GetAudioPriceRatio 返回模型的音频计费配置，未配置或未开启按秒计费时返回 false，按次计费不变
+func GetAudioPriceRatio(name string) (AudioPriceRatio, bool) {
+	audioPriceRatioMapMutex.RLock()
+	defer audioPriceRatioMapMutex.RUnlock()
+	config, ok := audioPriceRatioMap[FormatMatchingModelName(name)]
+	return config, ok && config.PerSecond
+}
+
+// BillingSeconds 计费时长，不足最低计费时长时按最低时长计
+func (a AudioPriceRatio) BillingSeconds(seconds float64) float64 {
+	if seconds < a.MinSeconds {
+		return a.MinSeconds
+	}
+	return seconds
+}
@@ -0,0 +1,78 @@
+package ratio_setting
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// ImageSizeQualityRatio 按次计费的图片模型按尺寸、品质调整单张价格，最终倍率为两者相乘，未命中的尺寸或品质按 1 计
+type ImageSizeQualityRatio struct {
+	Size    map[string]float64 `json:"size,omitempty"`
+	Quality map[string]float64 `json:"quality,omitempty"`
+}
+
+// 示例:
+//
+//	{
+//	  "gemini-3-pro-image-preview": {"quality": {"4K": 1.8}},
+//	  "doubao-seedream-4-0-250828": {"size": {"2048x2048": 1.5, "4096x4096": 3}},
+//	  "qwen-image-edit": {"size": {"1328x1328": 1}, "quality": {"hd": 2}}
+//	}
+var imageSizeQualityRatioMap = map[string]ImageSizeQualityRatio{}
+var imageSizeQualityRatioMapMutex sync.RWMutex
+
+func ImageSizeQualityRatio2JSONString() string {
+	imageSizeQualityRatioMapMutex.RLock()
+	defer imageSizeQualityRatioMapMutex.RUnlock()
+	jsonBytes, err := json.Marshal(imageSizeQualityRatioMap)
+	if err != nil {
+		common.SysLog("error marshalling image size quality ratio: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateImageSizeQualityRatioByJSONString(jsonStr string) error {
+	newMap := make(map[string]ImageSizeQualityRatio)
+	if err := json.Unmarshal([]byte(jsonStr), &newMap); err != nil {
+		return err
+	}
+	imageSizeQualityRatioMapMutex.Lock()
+	imageSizeQualityRatioMap = newMap
+	imageSizeQualityRatioMapMutex.Unlock()
+	return nil
+}
+
+// GetImageSizeQualityRatio 返回模型在指定尺寸、品质下的单张倍率，模型未配置时返回 false
+func GetImageSizeQualityRatio(name string, size string, quality string) (float64, bool) {
+	imageSizeQualityRatioMapMutex.RLock()
+	defer imageSizeQualityRatioMapMutex.RUnlock()
+	config, ok := imageSizeQualityRatioMap[FormatMatchingModelName(name)]
+	if !ok {
+		return 1, false
+	}
+	ratio := 1.0
+	if sizeRatio, ok := config.Size[strings.TrimSpace(size)]; ok {
+		ratio *= sizeRatio
+	}
+	if qualityRatio, ok := config.Quality[strings.TrimSpace(quality)]; ok {
+		ratio *= qualityRatio
+	}
+	return ratio, true
+}
+
+// GetImageOutputTokenPrice 返回图片模型每个图像输出 token 的价格（美元），按模型倍率×补全倍率折算，
+// 用于流式预览图计费；模型未配置倍率（如仅配置了按次价格）时按 gpt-image-1 的倍率计
+func GetImageOutputTokenPrice(name string) float64 {
+	modelRatioMapMutex.RLock()
+	_, configured := modelRatioMap[FormatMatchingModelName(name)]
+	modelRatioMapMutex.RUnlock()
+	if !configured {
+		name = "gpt-image-1"
+	}
+	modelRatio, _, _ := GetModelRatio(name)
+	// 倍率 1 对应 $0.002 / 1K tokens
+	return modelRatio * GetCompletionRatio(name) / USD / 1000
+}
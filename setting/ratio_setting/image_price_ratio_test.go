@@ -0,0 +1,51 @@
+package ratio_setting
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGetImageOutputTokenPrice(t *testing.T) {
+	modelRatioMapMutex.Lock()
+	savedModelRatio := modelRatioMap
+	modelRatioMap = map[string]float64{"gpt-image-1": 2.5, "my-image": 5}
+	modelRatioMapMutex.Unlock()
+	CompletionRatioMutex.Lock()
+	savedCompletionRatio := CompletionRatio
+	CompletionRatio = map[string]float64{"gpt-image-1": 8, "my-image": 2}
+	CompletionRatioMutex.Unlock()
+	t.Cleanup(func() {
+		modelRatioMapMutex.Lock()
+		modelRatioMap = savedModelRatio
+		modelRatioMapMutex.Unlock()
+		CompletionRatioMutex.Lock()
+		CompletionRatio = savedCompletionRatio
+		CompletionRatioMutex.Unlock()
+	})
+
+	tests := []struct {
+		name  string
+		model string
+		want  float64 // 美元/百万 token
+	}{
+		{name: "gpt-image-1", model: "gpt-image-1", want: 40},
+		{name: "configured ratio", model: "my-image", want: 20},
+		{name: "price only model falls back to gpt-image-1", model: "price-only-image", want: 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GetImageOutputTokenPrice(tt.model) * 1000000
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Fatalf("GetImageOutputTokenPrice(%s) = $%.4f / 1M, want $%.4f / 1M", tt.model, got, tt.want)
+			}
+		})
+	}
+
+	// 调整倍率后价格随之变化
+	modelRatioMapMutex.Lock()
+	modelRatioMap["gpt-image-1"] = 5
+	modelRatioMapMutex.Unlock()
+	if got := GetImageOutputTokenPrice("gpt-image-1") * 1000000; math.Abs(got-80) > 1e-9 {
+		t.Fatalf("GetImageOutputTokenPrice after ratio change = $%.4f / 1M, want $80 / 1M", got)
+	}
+}
@@ -0,0 +1,143 @@
+package ratio_setting
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// PromptTokenTier 按上下文长度（prompt tokens）分段计价，超过 MinPromptTokens 时使用该段倍率
+type PromptTokenTier struct {
+	MinPromptTokens int     `json:"min_prompt_tokens"`
+	ModelRatio      float64 `json:"model_ratio"`
+	CompletionRatio float64 `json:"completion_ratio,omitempty"`
+}
+
+// VolumeTier 按用户当月已用额度分段折扣，当月用量达到 MinMonthlyQuota 后生效
+type VolumeTier struct {
+	MinMonthlyQuota int     `json:"min_monthly_quota"`
+	Discount        float64 `json:"discount"`
+}
+
+type TieredPrice struct {
+	PromptTiers []PromptTokenTier `json:"prompt_tiers,omitempty"`
+	VolumeTiers []VolumeTier      `json:"volume_tiers,omitempty"`
+}
+
+// 模型自身的配置与 "*" 合并：模型未配置 prompt_tiers 时使用 "*" 的 prompt_tiers，
+// volume_tiers 两者合并，命中多个分段时取折扣最大（discount 最小）的一段，
+// 因此为模型单独配置分段不会使其失去全局用量折扣。
+//
+// 示例:
+//
+//	{
+//	  "gemini-2.5-pro": {
+//	    "prompt_tiers": [{"min_prompt_tokens": 200000, "model_ratio": 1.25, "completion_ratio": 6}],
+//	    "volume_tiers": [{"min_monthly_quota": 50000000, "discount": 0.9}]
+//	  },
+//	  "*": {"volume_tiers": [{"min_monthly_quota": 500000000, "discount": 0.95}]}
+//	}
+var tieredPriceMap = map[string]TieredPrice{}
+var tieredPriceMapMutex sync.RWMutex
+
+func TieredPrice2JSONString() string {
+	tieredPriceMapMutex.RLock()
+	defer tieredPriceMapMutex.RUnlock()
+	jsonBytes, err := json.Marshal(tieredPriceMap)
+	if err != nil {
+		common.SysLog("error marshalling tiered price: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateTieredPriceByJSONString(jsonStr string) error {
+	newMap := make(map[string]TieredPrice)
+	if err := json.Unmarshal([]byte(jsonStr), &newMap); err != nil {
+		return err
+	}
+	for name, tp := range newMap {
+		// 阈值升序，便于查找
+		sort.Slice(tp.PromptTiers, func(i, j int) bool {
+			return tp.PromptTiers[i].MinPromptTokens < tp.PromptTiers[j].MinPromptTokens
+		})
+		sort.Slice(tp.VolumeTiers, func(i, j int) bool {
+			return tp.VolumeTiers[i].MinMonthlyQuota < tp.VolumeTiers[j].MinMonthlyQuota
+		})
+		newMap[name] = tp
+	}
+	tieredPriceMapMutex.Lock()
+	tieredPriceMap = newMap
+	tieredPriceMapMutex.Unlock()
+	InvalidateExposedDataCache()
+	return nil
+}
+
+// GetTieredPrice 获取模型的分段计价配置，与 "*" 的配置合并
+func GetTieredPrice(name string) (TieredPrice, bool) {
+	tieredPriceMapMutex.RLock()
+	defer tieredPriceMapMutex.RUnlock()
+	tp, ok := tieredPriceMap[FormatMatchingModelName(name)]
+	global, globalOk := tieredPriceMap["*"]
+	if !globalOk {
+		return tp, ok
+	}
+	if !ok {
+		return global, true
+	}
+	merged := TieredPrice{PromptTiers: tp.PromptTiers}
+	if len(merged.PromptTiers) == 0 {
+		merged.PromptTiers = global.PromptTiers
+	}
+	if len(global.VolumeTiers) > 0 {
+		merged.VolumeTiers = make([]VolumeTier, 0, len(tp.VolumeTiers)+len(global.VolumeTiers))
+		merged.VolumeTiers = append(merged.VolumeTiers, tp.VolumeTiers...)
+		merged.VolumeTiers = append(merged.VolumeTiers, global.VolumeTiers...)
+		sort.SliceStable(merged.VolumeTiers, func(i, j int) bool {
+			return merged.VolumeTiers[i].MinMonthlyQuota < merged.VolumeTiers[j].MinMonthlyQuota
+		})
+	} else {
+		merged.VolumeTiers = tp.VolumeTiers
+	}
+	return merged, true
+}
+
+func GetTieredPriceCopy() map[string]TieredPrice {
+	tieredPriceMapMutex.RLock()
+	defer tieredPriceMapMutex.RUnlock()
+	copyMap := make(map[string]TieredPrice, len(tieredPriceMap))
+	for k, v := range tieredPriceMap {
+		copyMap[k] = v
+	}
+	return copyMap
+}
+
+// GetPromptTier 返回 promptTokens 命中的最高分段
+func (t TieredPrice) GetPromptTier(promptTokens int) (PromptTokenTier, bool) {
+	var hit PromptTokenTier
+	found := false
+	for _, tier := range t.PromptTiers {
+		if promptTokens > tier.MinPromptTokens {
+			hit = tier
+			found = true
+		}
+	}
+	return hit, found
+}
+
+// GetVolumeTier 返回当月用量命中的分段中折扣最大（discount 最小）的一段
+func (t TieredPrice) GetVolumeTier(monthlyQuota int) (VolumeTier, bool) {
+	var hit VolumeTier
+	found := false
+	for _, tier := range t.VolumeTiers {
+		if monthlyQuota < tier.MinMonthlyQuota || tier.Discount <= 0 {
+			continue
+		}
+		if !found || tier.Discount <= hit.Discount {
+			hit = tier
+			found = true
+		}
+	}
+	return hit, found
+}
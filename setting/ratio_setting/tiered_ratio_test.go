@@ -0,0 +1,57 @@
+package ratio_setting
+
+import "testing"
+
+func TestGetTieredPriceMergesGlobalTiers(t *testing.T) {
+	saved := TieredPrice2JSONString()
+	t.Cleanup(func() {
+		if err := UpdateTieredPriceByJSONString(saved); err != nil {
+			t.Fatalf("restore tiered price: %v", err)
+		}
+	})
+	err := UpdateTieredPriceByJSONString(`{
+		"long-context": {"prompt_tiers": [{"min_prompt_tokens": 200000, "model_ratio": 2}]},
+		"own-volume": {"volume_tiers": [{"min_monthly_quota": 1000, "discount": 0.8}]},
+		"*": {
+			"prompt_tiers": [{"min_prompt_tokens": 100000, "model_ratio": 3}],
+			"volume_tiers": [{"min_monthly_quota": 500, "discount": 0.9}, {"min_monthly_quota": 5000, "discount": 0.7}]
+		}
+	}`)
+	if err != nil {
+		t.Fatalf("update tiered price: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		model          string
+		promptTokens   int
+		monthlyQuota   int
+		wantPromptHit  bool
+		wantModelRatio float64
+		wantVolumeHit  bool
+		wantDiscount   float64
+	}{
+		{"model prompt tiers keep global volume", "long-context", 300000, 600, true, 2, true, 0.9},
+		{"model prompt tier below threshold", "long-context", 150000, 0, false, 0, false, 0},
+		{"model volume tiers inherit global prompt tiers", "own-volume", 150000, 0, true, 3, false, 0},
+		{"model volume tier beats global", "own-volume", 0, 1000, false, 0, true, 0.8},
+		{"global volume tier beats model", "own-volume", 0, 6000, false, 0, true, 0.7},
+		{"unknown model uses global", "other", 150000, 600, true, 3, true, 0.9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp, ok := GetTieredPrice(tt.model)
+			if !ok {
+				t.Fatalf("GetTieredPrice(%s) not found", tt.model)
+			}
+			promptTier, promptHit := tp.GetPromptTier(tt.promptTokens)
+			if promptHit != tt.wantPromptHit || (promptHit && promptTier.ModelRatio != tt.wantModelRatio) {
+				t.Errorf("prompt tier = %+v (hit %v), want ratio %v (hit %v)", promptTier, promptHit, tt.wantModelRatio, tt.wantPromptHit)
+			}
+			volumeTier, volumeHit := tp.GetVolumeTier(tt.monthlyQuota)
+			if volumeHit != tt.wantVolumeHit || (volumeHit && volumeTier.Discount != tt.wantDiscount) {
+				t.Errorf("volume tier = %+v (hit %v), want discount %v (hit %v)", volumeTier, volumeHit, tt.wantDiscount, tt.wantVolumeHit)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package ratio_setting
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// VideoPriceRatio 视频任务按时长×分辨率计费：per_second 开启时模型价格视为每秒单价，
+// 分辨率倍率按请求（或模型默认）分辨率取值，未命中的分辨率按 1 计。
+// 配置后会替换渠道自带的计费参数（如 Sora、通义万相的 seconds 与分辨率倍率）
+type VideoPriceRatio struct {
+	PerSecond  bool               `json:"per_second,omitempty"`
+	Resolution map[string]float64 `json:"resolution,omitempty"`
+}
+
+// 示例:
+//
+//	{
+//	  "kling-v2-master": {"per_second": true, "resolution": {"1080p": 1.75}},
+//	  "doubao-seedance-1-0-pro-250528": {"per_second": true, "resolution": {"480p": 0.5, "1080p": 2.2}},
+//	  "veo-3.0-generate-001": {"per_second": true}
+//	}
+var videoPriceRatioMap = map[string]VideoPriceRatio{}
+var videoPriceRatioMapMutex sync.RWMutex
+
+func VideoPriceRatio2JSONString() string {
+	videoPriceRatioMapMutex.RLock()
+	defer videoPriceRatioMapMutex.RUnlock()
+	jsonBytes, err := json.Marshal(videoPriceRatioMap)
+	if err != nil {
+		common.SysLog("error marshalling video price ratio: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateVideoPriceRatioByJSONString(jsonStr string) error {
+	newMap := make(map[string]VideoPriceRatio)
+	if err := json.Unmarshal([]byte(jsonStr), &newMap); err != nil {
+		return err
+	}
+	// 分辨率统一小写，便于与请求中的 720p、1080P 等写法匹配
+	for name, config := range newMap {
+		if len(config.Resolution) == 0 {
+			continue
+		}
+		resolution := make(map[string]float64, len(config.Resolution))
+		for key, ratio := range config.Resolution {
+			resolution[strings.ToLower(strings.TrimSpace(key))] = ratio
+		}
+		config.Resolution = resolution
+		newMap[name] = config
+	}
+	videoPriceRatioMapMutex.Lock()
+	videoPriceRatioMap = newMap
+	videoPriceRatioMapMutex.Unlock()
+	return nil
+}
+
+// GetVideoPriceRatio 返回模型的视频计费配置，未配置时返回 false，按次计费不变
+func GetVideoPriceRatio(name string) (VideoPriceRatio, bool) {
+	videoPriceRatioMapMutex.RLock()
+	defer videoPriceRatioMapMutex.RUnlock()
+	config, ok := videoPriceRatioMap[FormatMatchingModelName(name)]
+	return config, ok
+}
+
+// GetResolutionRatio 返回分辨率倍率，未配置的分辨率按 1 计
+func (v VideoPriceRatio) GetResolutionRatio(resolution string) float64 {
+	if ratio, ok := v.Resolution[strings.ToLower(strings.TrimSpace(resolution))]; ok {
+		return ratio
+	}
+	return 1
+}
@@ -92,6 +92,19 @@ type NewAPIError struct {
 	errorType      ErrorType
 	errorCode      ErrorCode
 	StatusCode     int
+	// 上游原始响应，供渠道错误映射规则匹配
+	upstreamStatusCode int
+	upstreamBody       []byte
+	retryAfter         int // 上游 Retry-After 指定的秒数
+	errorRule          *ErrorRuleResult
+}
+
+// ErrorRuleResult 命中的渠道错误映射规则给出的处理方式
+type ErrorRuleResult struct {
+	Rule           string
+	Retry          *bool  // 为空时按默认规则判断是否重试
+	Action         string // 为空时按默认规则判断是否禁用
+	DisableSeconds int
 }
 
 // Unwrap enables errors.Is / errors.As to work with NewAPIError by exposing the underlying error.
@@ -145,6 +158,51 @@ func (e *NewAPIError) SetMessage(message string) {
 	e.Err = errors.New(message)
 }
 
+// SetErrorCode 改写错误码，上游 OpenAI 格式错误中的 code 同步改写
+func (e *NewAPIError) SetErrorCode(code ErrorCode) {
+	e.errorCode = code
+	if openAIError, ok := e.RelayError.(OpenAIError); ok {
+		openAIError.Code = string(code)
+		e.RelayError = openAIError
+	}
+}
+
+// GetUpstreamStatusCode 上游返回的原始状态码（状态码复写之前），非上游错误时为 StatusCode
+func (e *NewAPIError) GetUpstreamStatusCode() int {
+	if e == nil {
+		return 0
+	}
+	if e.upstreamStatusCode != 0 {
+		return e.upstreamStatusCode
+	}
+	return e.StatusCode
+}
+
+func (e *NewAPIError) GetUpstreamBody() []byte {
+	if e == nil {
+		return nil
+	}
+	return e.upstreamBody
+}
+
+func (e *NewAPIError) GetRetryAfter() int {
+	if e == nil {
+		return 0
+	}
+	return e.retryAfter
+}
+
+func (e *NewAPIError) GetErrorRule() *ErrorRuleResult {
+	if e == nil {
+		return nil
+	}
+	return e.errorRule
+}
+
+func (e *NewAPIError) SetErrorRule(result *ErrorRuleResult) {
+	e.errorRule = result
+}
+
 func (e *NewAPIError) ToOpenAIError() OpenAIError {
 	var result OpenAIError
 	switch e.errorType {
@@ -345,6 +403,19 @@ func ErrOptionWithSkipRetry() NewAPIErrorOptions {
 	}
 }
 
+func ErrOptionWithUpstreamResponse(statusCode int, body []byte) NewAPIErrorOptions {
+	return func(e *NewAPIError) {
+		e.upstreamStatusCode = statusCode
+		e.upstreamBody = body
+	}
+}
+
+func ErrOptionWithRetryAfter(seconds int) NewAPIErrorOptions {
+	return func(e *NewAPIError) {
+		e.retryAfter = seconds
+	}
+}
+
 func ErrOptionWithNoRecordErrorLog() NewAPIErrorOptions {
 	return func(e *NewAPIError) {
 		e.recordErrorLog = common.GetPointer(false)
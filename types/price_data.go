@@ -6,6 +6,20 @@ type GroupRatioInfo struct {
 	GroupRatio        float64
 	GroupSpecialRatio float64
 	HasSpecialRatio   bool
+	CampaignId        int     // 命中的折扣活动，0 表示未命中
+	CampaignDiscount  float64 // 已计入 GroupRatio 的活动折扣
+}
+
+// PriceTierInfo 阶梯计价信息，Base* 为未应用阶梯前的倍率
+type PriceTierInfo struct {
+	BaseModelRatio      float64
+	BaseCompletionRatio float64
+	MonthlyQuota        int
+	PromptTierHit       bool
+	PromptTierMinTokens int
+	VolumeTierHit       bool
+	VolumeMinQuota      int
+	VolumeDiscount      float64
 }
 
 type PriceData struct {
@@ -24,6 +38,7 @@ type PriceData struct {
 	UsePrice             bool
 	QuotaToPreConsume    int // 预消耗额度
 	GroupRatioInfo       GroupRatioInfo
+	TierInfo             *PriceTierInfo // 未配置阶梯计价时为 nil
 }
 
 type PerCallPriceData struct {